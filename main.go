@@ -1,19 +1,228 @@
 package main
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"flag"
+	"fmt"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/andreaskaris/metallb-converter/pkg/converter"
-	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	"github.com/andreaskaris/metallb-converter/pkg/envtest"
+	"github.com/andreaskaris/metallb-converter/pkg/features"
+	"github.com/andreaskaris/metallb-converter/pkg/metrics"
+	"github.com/andreaskaris/metallb-converter/pkg/options"
+	"github.com/andreaskaris/metallb-converter/pkg/schema"
+	"github.com/andreaskaris/metallb-converter/pkg/server"
+	"github.com/andreaskaris/metallb-converter/pkg/tracing"
+	"github.com/andreaskaris/metallb-converter/pkg/version"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	authorizationv1client "k8s.io/client-go/kubernetes/typed/authorization/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/record"
 
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
+	"sigs.k8s.io/yaml"
 )
 
+// injectFailuresFlagName is the name of the hidden flag that arms failure points for testing the recovery from failed
+// migrations.
+const injectFailuresFlagName = "inject-failures"
+
+// validateWithEnvtest is the value of validate-with that validates against a local etcd and kube-apiserver.
+const validateWithEnvtest = "envtest"
+
+// hiddenFlags are not shown in the usage message.
+var hiddenFlags = map[string]bool{injectFailuresFlagName: true}
+
+// stringList is a flag.Value that collects the values of a flag that may be given multiple times.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+var (
+	addLabelFlags              stringList
+	addAnnotationFlags         stringList
+	advertisementMetadataFlags stringList
+	asGroupFlags               stringList
+	helmValuesFlags            stringList
+	pushGroupingFlags          stringList
+	envtestCRDDirFlags         stringList
+)
+
+func init() {
+	flag.Var(&addLabelFlags, "add-label", "Add the label key=value to every generated resource, e.g. for ownership "+
+		"or GitOps pruning.\nMay be given multiple times.")
+	flag.Var(&addAnnotationFlags, "add-annotation", "Add the annotation key=value to every generated resource.\n"+
+		"May be given multiple times.")
+	flag.Var(&advertisementMetadataFlags, "advertisement-metadata", "Label or annotation key of the legacy "+
+		"AddressPools that is copied to the\nadvertisements as well, e.g. team or example.com/*. May be given "+
+		"multiple times.")
+	flag.Var(&asGroupFlags, "as-group", "Group to impersonate for all requests to the cluster. Requires as. May be "+
+		"given\nmultiple times.")
+	flag.Var(&helmValuesFlags, "input-helm-values", "Values file that input-helm-chart is rendered with. May be "+
+		"given multiple times.")
+	flag.Var(&pushGroupingFlags, "pushgateway-grouping", "Add the grouping label name=value to the metrics that are "+
+		"pushed to\npushgateway-url, e.g. cluster=prod-1. May be given multiple times.")
+	flag.Var(&envtestCRDDirFlags, "envtest-crd-dir", "Directory with the MetalLB CRD manifests that validate-with "+
+		"envtest installs,\ne.g. config/crd/bases of the MetalLB release. May be given multiple times.")
+}
+
 var (
-	jsonFlag      = flag.Bool("json", false, "Write output in JSON format (default YAML).")
+	configFlag = flag.String(options.ConfigFlag, "", "Read flag values from this YAML file, a map of flag names to "+
+		"values.\nEvery flag can also be set via the environment, e.g. backup-dir via "+options.EnvName("backup-dir")+
+		".\nCommand line flags take precedence over environment variables, which take precedence over the file.")
+	profileFlag = flag.String(options.ProfileFlag, "", "Apply a predefined set of flag values, one of: "+
+		strings.Join(options.ProfileNames(), ", ")+".\nFlags that are set explicitly take precedence over the profile.")
+	jsonFlag   = flag.Bool("json", false, "Write output in JSON format (default YAML). Shorthand for -o json.")
+	outputFlag = flag.String("o", converter.OutputFormatYAML, "Output format, one of: "+
+		strings.Join(converter.SupportedOutputFormats, ", ")+".\n"+
+		"The dot and mermaid formats render the relationship graph of the converted objects, csv writes a flat\n"+
+		"inventory of the converted pools, name prints only kind/namespace/name identifiers and capacity prints\n"+
+		"the number of usable addresses per address family.")
+	quietFlag = flag.Bool("quiet", false, "Suppress progress output of online migrations and print only the\n"+
+		"kind/namespace/name identifiers of created objects.")
+	duplicatesFlag = flag.String("duplicates", converter.DuplicatePolicyFail, "How to handle AddressPools that are "+
+		"defined more than once in input-dir, one of: "+strings.Join(converter.SupportedDuplicatePolicies, ", ")+
+		".\nkeep-last keeps the last definition in file name order, merge combines addresses and BGP advertisements.")
+	nameCollisionsFlag = flag.String("name-collisions", converter.NameCollisionsFail, "How to handle converted "+
+		"objects whose names collide with each other or, in online\nmigrations, with objects of the cluster, one of: "+
+		strings.Join(converter.SupportedNameCollisionPolicies, ", ")+".\nsuffix renames the colliding objects with "+
+		"the first free -2, -3, ... suffix.")
+	ignoreUnknownFlag = flag.Bool("ignore-unknown", false, "Skip the documents of input-dir that are not legacy "+
+		"MetalLB objects, e.g. other\nKubernetes manifests and files that are no YAML or JSON, instead of failing. "+
+		"The\nskipped documents are summarized in a log message.")
+	outputGroupByFlag = flag.String("output-group-by", converter.OutputGroupByKind, "How to distribute the "+
+		"converted resources over the files of output-dir, one of: "+
+		strings.Join(converter.SupportedOutputGroupings, ", ")+".\npool writes one file per IPAddressPool with "+
+		"all of its advertisements.")
+	outputLayoutFlag = flag.String("output-layout", converter.OutputLayoutFlat, "How to lay out the files of "+
+		"output-dir, one of: "+strings.Join(converter.SupportedOutputLayouts, ", ")+".\nper-kind-dir writes one "+
+		"file per resource into one subdirectory per kind, e.g. ipaddresspools/.")
+	advertisementNamesFlag = flag.String("advertisement-names", converter.AdvertisementNamingIndex, "How to name "+
+		"the BGPAdvertisements of legacy bgpAdvertisements, one of: "+
+		strings.Join(converter.SupportedAdvertisementNamings, ", ")+".\nhash derives the name from the advertisement "+
+		"spec, so that reordering bgpAdvertisements does not rename them.")
+	communityResourcesFlag = flag.Bool("community-resources", false, "Extract all distinct BGP community values into "+
+		"one Community CR per namespace\n("+converter.CommunityResourceName+") and reference its aliases from the "+
+		"BGPAdvertisements.")
+	expandCommunityAliasesFlag = flag.Bool("expand-community-aliases", false, "Replace references to the aliases of "+
+		"existing Community CRs with their values.\nReferences to unknown aliases are always an error when reading "+
+		"from the cluster.")
+	assignPrioritiesFlag = flag.Bool("assign-priorities", false, "Record an allocation priority for every "+
+		"IPAddressPool in the "+converter.PriorityAnnotation+"\nannotation, derived from the order of the legacy "+
+		"AddressPools. Not supported for online migrations.")
+	normalizeAddressesFlag = flag.Bool("normalize-addresses", false, "Sort the addresses of every IPAddressPool, "+
+		"remove exact duplicates and canonicalize\ntheir textual form (e.g. IPv6 addresses according to RFC 5952).")
+	mergeIdenticalPoolsFlag = flag.Bool("merge-identical-pools", false, "Merge AddressPools of the same namespace "+
+		"with identical address sets into a single\nIPAddressPool with the advertisements of all merged pools. Not "+
+		"supported for online migrations.")
+	mergeL2Flag = flag.Bool("merge-l2", false, "Emit a single L2Advertisement ("+converter.MergedL2AdvertisementName+
+		") per namespace that references every\nlayer2 pool instead of one per pool. Not supported for online "+
+		"migrations.")
+	failOnLocalPrefConflictsFlag = flag.Bool("fail-on-localpref-conflicts", false, "Fail instead of warn if the "+
+		"same prefixes of a pool are advertised to the same peers\nwith different localPref values.")
+	splitL2AddressFamiliesFlag = flag.Bool("split-l2-address-families", false, "Split dual-stack layer2 pools into "+
+		"an IPv4 and an IPv6 IPAddressPool with one\nL2Advertisement each.")
+	splitPoolsPerRangeFlag = flag.Bool("split-pools-per-range", false, "Emit one IPAddressPool per address range of "+
+		"a legacy pool, named <name>-<index>,\nwith a copy of every advertisement of the pool for each of them.")
+	peersPolicyFlag = flag.String("peers-policy", "", "YAML file that maps legacy AddressPools (<namespace>/<name> or "+
+		"<name>) to lists of BGPPeer names.\nThe BGPAdvertisements of a mapped pool are only sent to these peers.")
+	mergePolicyFlag = flag.String("merge-policy", "", "YAML file with groups of AddressPools (name patterns or a "+
+		"label selector) that are\nmerged into one IPAddressPool per group. Not supported for online migrations.")
+	validateForFlag = flag.String("validate-for", "", "Validate the converted resources against the embedded CRD "+
+		"schemas of this MetalLB\nrelease, e.g. v0.13.12. Works without cluster access.\nSupported releases: "+
+		strings.Join(schema.Versions(), ", ")+".")
+	sourceAPIGroupFlag = flag.String("source-api-group", "", "API group that the legacy AddressPools are read "+
+		"from, for distributions\nthat serve the MetalLB types under a forked group. Defaults to metallb.io.")
+	targetAPIGroupFlag = flag.String("target-api-group", "", "API group of the converted resources, for "+
+		"distributions that serve\nthe MetalLB types under a forked group. Defaults to metallb.io.")
+	validateLiveFlag = flag.Bool("validate-live", false, "Validate the converted resources against the schemas "+
+		"of the metallb.io CRDs\nthat the target cluster serves, to catch version skew between the converter and the "+
+		"cluster.")
+	validateWithFlag = flag.String("validate-with", "", "Validate the converted resources with dry-run creates "+
+		"against a local API server.\nenvtest runs the etcd and kube-apiserver binaries of envtest-assets-dir with "+
+		"the CRDs\nof envtest-crd-dir installed, without cluster access. Supported: "+validateWithEnvtest+".")
+	envtestAssetsDirFlag = flag.String("envtest-assets-dir", "", "Directory with the etcd and kube-apiserver "+
+		"binaries for validate-with envtest.\nDefaults to $"+envtest.AssetsEnv+".")
+	targetVersionFlag = flag.String("target-version", "", "MetalLB release that the converted resources are meant "+
+		"for, e.g. v0.13.7.\nFields that this release does not support are refused.\nSupported releases: "+
+		strings.Join(schema.Versions(), ", ")+".")
+	stripUnsupportedFieldsFlag = flag.Bool("strip-unsupported-fields", false, "Strip the fields that the "+
+		"target-version does not support instead of failing.")
+	operatorCompatFlag = flag.Bool("operator-compat", false, "Compatibility mode for clusters where the MetalLB "+
+		"Operator or another controller\nmanages the legacy AddressPools: copy their labels and owner references to "+
+		"the\nconverted resources.")
+	provenanceFlag = flag.Bool("provenance", false, "Stamp every converted IPAddressPool and advertisement with the "+
+		"name, UID and spec hash\nof its legacy AddressPool and the time of the conversion.")
+	propagateMetadataFlag = flag.Bool("propagate-metadata", true, "Copy the labels and annotations of the legacy "+
+		"AddressPools to their\nIPAddressPools, apart from those of kubectl, Kubernetes, the converter, Helm and\n"+
+		"ArgoCD. Disable with -propagate-metadata=false.")
+	simulateAssignmentsFlag = flag.Bool("simulate-assignments", false, "Simulate MetalLB's IP assignment for all "+
+		"LoadBalancer Services over the converted pools\nand warn about every Service whose IP would change after the "+
+		"migration. Requires cluster access.")
+	strictFlag = flag.Bool("strict", false, "Fail if the legacy AddressPools or the converted resources have "+
+		"unparseable addresses,\nmalformed communities, aggregation lengths that do not fit their addresses or "+
+		"names\nthat are not DNS-1123 subdomains. By default, these problems are logged as warnings.")
+	injectFailuresFlag = flag.String(injectFailuresFlagName, "", "Development only: fail at the given points, "+
+		"e.g. after-delete:2,before-create.")
+	recordFlag = flag.String("record", "", "Record all objects that are read from the API and all mutations of this "+
+		"run into\nthis directory, so that the run can be reproduced with replay without cluster access.")
+	replayFlag = flag.String("replay", "", "Re-run against the objects of a recording directory instead of a cluster "+
+		"and report the\ndifferences between the recorded and the replayed mutations. Mutations are not sent to any "+
+		"cluster.")
+	detectDriftFlag = flag.Bool("detect-drift", false, "Compare the legacy AddressPools in the cluster with the "+
+		"resources that they were migrated to\nand report inconsistencies, e.g. an IPAddressPool that was edited while "+
+		"its AddressPool was not.\nExits with an error if drift is detected, unless drift-interval is set.")
+	driftIntervalFlag = flag.Duration("drift-interval", 0, "Repeat drift detection with this interval, e.g. 10m, "+
+		"and log the inconsistencies\nof every run. Requires detect-drift.")
+	argoCDCMPFlag = flag.Bool("argocd-cmp", false, "Run as the generate command of an ArgoCD Config Management "+
+		"Plugin: convert the legacy\nAddressPools of all YAML and JSON files below input-dir (default: the current "+
+		"directory) and\nwrite them, together with all other documents unchanged, as YAML to stdout. Flags can also "+
+		"be set\nvia Application plugin environment variables, e.g. "+converter.ArgoCDEnvPrefix+
+		options.EnvName("assign-priorities")+".")
+	serveFlag = flag.String("serve", "", "Run an HTTP server on this address, e.g. :8080, that converts the legacy "+
+		"resources\nposted to /convert and returns the converted manifests and warnings. The conversion flags apply\n"+
+		"to every request.")
+	exportLegacyConfigMapFlag = flag.String("export-legacy-configmap", "", "Downgrade: render the current "+
+		"MetalLB resources (from the cluster or\ninput-dir) into the legacy ConfigMap <namespace>/<name> instead of "+
+		"converting.\nFeatures that the legacy format cannot represent are reported as warnings.")
+	exportFlag = flag.Bool("export", false, "Export the current IPAddressPools, advertisements, BGPPeers, "+
+		"BFDProfiles and Communities\nof the cluster to output-dir or stdout, e.g. to bootstrap a GitOps repository. "+
+		"The\noutput-group-by and output-layout options apply.")
+	backupFlag = flag.Bool("backup", false, "Write a backup of the legacy AddressPools of the cluster to backup-dir "+
+		"without migrating\nthem, e.g. before a manual migration. The backup can be restored with restore.")
+	restoreFlag = flag.String("restore", "", "Restore the legacy AddressPools of the backup-dir of an online "+
+		"migration from this directory.\nAddressPools that exist are left alone.")
+	dryRunFlag = flag.Bool("dry-run", false, "With restore, only verify that the backup decodes into complete "+
+		"legacy AddressPools\nand print what a restore would recreate, without modifying the cluster.")
+	verifyFlag = flag.String("verify", "", "Verify a completed online migration against the backup-dir of the "+
+		"migration in this\ndirectory: no legacy AddressPool may remain, every converted object must exist and "+
+		"every\nLoadBalancer Service must still have an IP of the legacy AddressPools. Prints a report in the\n"+
+		"output format and exits with a non-zero code on any discrepancy.")
 	migrationFlag = flag.Bool("online-migration", false, "Trigger an online migration from legacy to new resources.\n"+
 		"WARNING: This will reset your BGP sessions, L2 advertisements, and SVC external IPs.\n"+
 		"Migration cannot rollback on errors; instead, it will leave resources in a potentially inconsistent state.",
@@ -21,58 +230,1098 @@ var (
 	backupDirFlag = flag.String("backup-dir", "", "Directory that backups of legacy AddressPools will we written to.\n"+
 		"Required when migration-flag is set.")
 	inDirFlag = flag.String("input-dir", "", "Input directory with legacy style YAML or JSON files.\n"+
-		"If empty, read directly from Kubernetes cluster.")
+		"If empty, read directly from Kubernetes cluster. If -, read a YAML or JSON stream from stdin.")
+	fromConfigMapFlag = flag.String("from-configmap", "", "Read the address-pools of a legacy MetalLB ConfigMap "+
+		"(<namespace>/<name>,\ne.g. metallb-system/config) from the cluster instead of AddressPools. Its peers are "+
+		"converted\ninto BGPPeers.")
+	qpsFlag = flag.Float64("qps", 20, "Maximum number of requests per second to the cluster, to throttle the "+
+		"converter on\nbusy API servers or to speed up large migrations. A negative value disables the\nclient-side "+
+		"throttling.")
+	burstFlag          = flag.Int("burst", 30, "Maximum burst of requests to the cluster on top of qps.")
+	requestTimeoutFlag = flag.Duration("request-timeout", 0, "Time after which a request to the cluster is "+
+		"abandoned, e.g. 30s, or 0 for no\ntimeout.")
+	contextFlag = flag.String("context", "", "Name of the kubeconfig context of the cluster to use. If empty, the "+
+		"current\ncontext is used.")
+	asFlag        = flag.String("as", "", "User to impersonate for all requests to the cluster, e.g. for audit.")
+	namespaceFlag = flag.String("namespace", "", "Only read the legacy AddressPools, BGPPeers and BFDProfiles of "+
+		"this namespace from the\ncluster. Applies to conversions and migrations.")
+	allNamespacesFlag = flag.Bool("all-namespaces", false, "Read the legacy AddressPools of all namespaces from the "+
+		"cluster (default). Cannot\nbe combined with namespace.")
+	selectorFlag = flag.String("selector", "", "Only read the legacy AddressPools whose labels match this label "+
+		"selector from the\ncluster, e.g. tenant=a,tier!=test. Applies to conversions and migrations.")
+	peerPasswordsFlag = flag.String("peer-passwords", converter.PeerPasswordsSecret, "How to convert the passwords "+
+		"of the peers of from-configmap and of v1beta1 BGPPeers,\none of: "+
+		strings.Join(converter.SupportedPeerPasswords, ", ")+".\nsecret moves them into Secrets that the BGPPeers "+
+		"reference, inline keeps them in the BGPPeers.")
 	outDirFlag = flag.String("output-dir", "", "Output directory with new style YAML or JSON files.\n"+
 		"If empty, write to stdout.")
+	otlpEndpointFlag = flag.String("otlp-endpoint", os.Getenv(tracing.EndpointEnvVar),
+		"OTLP/HTTP endpoint (e.g. http://localhost:4318) that traces of the migration phases are exported to.\n"+
+			"Defaults to $"+tracing.EndpointEnvVar+". If empty, tracing is disabled.")
+	auditLogFlag = flag.String("audit-log", "", "Append-only file that every API mutation of an online migration, a "+
+		"sync or a restore is recorded in.")
+	auditConfigMapFlag = flag.String("audit-configmap", "", "Also append the audit records of this run to the "+
+		"ConfigMap <namespace>/<name>.\nRequires audit-log.")
+	operatorFlag = flag.String("operator", "", "Operator identity recorded in the audit log.\n"+
+		"If empty, the local user name, the kubeconfig user of the selected context\nand the impersonated user are used.")
+	historyNamespaceFlag = flag.String("history-namespace", "metallb-system", "Namespace of the "+
+		converter.HistoryConfigMapName+" ConfigMap that online migrations are recorded in.")
+	keepLegacyFlag = flag.Bool("keep-legacy", false, "Non-destructive online migration: create the new resources but "+
+		"keep the legacy\nAddressPools and mark them with the "+converter.MigratedAnnotation+" annotation instead. "+
+		"Marked\nAddressPools are skipped by subsequent online migrations.")
+	phaseFlag = flag.String("phase", "", "Run one phase of a two-phase online migration, one of: "+
+		converter.PhaseMark+", "+converter.PhaseFinalize+".\n"+
+		converter.PhaseMark+" creates the new resources and marks the legacy AddressPools as superseded "+
+		"(same as keep-legacy).\n"+converter.PhaseFinalize+" verifies the new resources of all marked AddressPools "+
+		"and only then deletes the marked AddressPools.")
+	deleteAfterFlag = flag.Duration("delete-after", 0, "Grace period for the deletion of legacy AddressPools, e.g. "+
+		"24h. Implies phase "+converter.PhaseMark+"\nunless phase "+converter.PhaseFinalize+" is set. With phase "+
+		converter.PhaseFinalize+", only AddressPools that were marked\nat least this long ago are deleted.")
+	deleteIntervalFlag = flag.Duration("delete-interval", 0, "Minimum time between the deletion of two legacy "+
+		"AddressPools during an online\nmigration, e.g. 30s. Deletions are what disturbs traffic.")
+	createIntervalFlag = flag.Duration("create-interval", 0, "Minimum time between the creation of the new "+
+		"resources of two AddressPools\nduring an online migration.")
+	createFirstFlag = flag.Bool("create-first", false, "Online migrations create the new resources of an AddressPool "+
+		"first, wait until\nthey are accepted and only then delete the AddressPool, minimizing BGP and L2 disruption.")
+	acceptTimeoutFlag = flag.Duration("accept-timeout", converter.DefaultAcceptTimeout, "Time that create-first "+
+		"waits for the new resources of an AddressPool to be\naccepted. The AddressPool is kept and the new resources are "+
+		"removed if they are not accepted in time.")
+	waitServicesFlag = flag.Bool("wait-services", false, "Online migrations wait after every AddressPool until the "+
+		"LoadBalancer Services\nthat had one of its IPs are assigned an IP again, turning a big-bang outage into a "+
+		"rolling\none.")
+	waitServicesTimeoutFlag = flag.Duration("wait-services-timeout", converter.DefaultServiceTimeout, "Time that "+
+		"wait-services waits for the Services of an AddressPool. The migration\nstops if they do not get an IP in "+
+		"time.")
+	serverSideApplyFlag = flag.Bool("server-side-apply", false, "Online migrations apply the new resources with "+
+		"server-side apply and the\n"+converter.FieldManager+" field manager instead of creating them, so that "+
+		"resources of\na previous, partial run are updated instead of failing with AlreadyExists.")
+	stateFileFlag = flag.String("state-file", "", "Journal of the online migration. It records which AddressPools "+
+		"were migrated\nand which are in progress, so that a migration that is run again after it was\ninterrupted "+
+		"skips the migrated AddressPools and completes the ones in progress.")
+	operationPolicyFlag = flag.String("operation-policy", "", "YAML file with the retries, backoff and timeout of "+
+		"every operation of online\nmigrations and finalizations, one of: "+
+		strings.Join(converter.SupportedOperations, ", ")+".")
+	maxRetriesFlag = flag.Int("max-retries", converter.DefaultMaxRetries, "Number of times that the API requests of "+
+		"online migrations and finalizations\nare retried when the API server fails transiently, e.g. with a timeout, "+
+		"or 0 to\nnot retry them. Requests of operations that -operation-policy retries are\nattempted once.")
+	retryIntervalFlag = flag.Duration("retry-interval", converter.DefaultRetryInterval, "Wait before the first "+
+		"retry of a failed API request, doubled for every further retry.")
+	eventsFlag = flag.Bool("events", true, "Online migrations record a Kubernetes Event about every AddressPool that "+
+		"is\nconverted, marked, deleted or fails to migrate.")
+	versionFlag = flag.Bool("version", false, "Print the version, git commit, build date and the MetalLB API module "+
+		"version\nthat the converter was compiled against and exit. Use -o json for JSON output.")
+	yesFlag = flag.Bool("yes", false, "Do not ask for confirmation before destructive operations. Required when "+
+		"stdin is not a terminal.")
+	pprofAddressFlag = flag.String("pprof-address", "", "Serve the net/http/pprof endpoints on this address, e.g. "+
+		"localhost:6060, while the\nconverter runs, to profile CPU and memory usage of long-running modes in place.\n"+
+		"Do not expose this address publicly.")
+	metricsAddressFlag = flag.String("metrics-address", "", "Serve the Prometheus metrics of this run on this "+
+		"address under /metrics, e.g. :8080,\nwhile the converter runs, for long-running migrations and modes. "+
+		"Cannot be combined with\nserve, which serves its own metrics.")
+	monitorManifestFlag = flag.String("monitor-manifest", "", "Print a Prometheus Operator manifest that scrapes the "+
+		"metrics of the serve mode and exit,\none of: "+strings.Join(server.SupportedMonitorKinds, ", ")+". It "+
+		"selects the Service or pods with the label\n"+server.AppLabel+"="+server.AppName+". Use -o json for JSON "+
+		"output.")
+	monitorNamespaceFlag = flag.String("monitor-namespace", "metallb-system", "Namespace of the manifest of "+
+		"monitor-manifest.")
+	monitorPortFlag = flag.String("monitor-port", "http", "Name of the port of the serve mode that monitor-manifest "+
+		"scrapes.")
+	noColorFlag = flag.Bool("no-color", false, "Disable colored output. Output is only colored if stderr is a "+
+		"terminal and the\n"+converter.NoColorEnvVar+" environment variable is not set.")
+	kustomizeFlag = flag.Bool("kustomize", false, "If the input directory contains a kustomization.yaml, render "+
+		"it with kustomize-command\nand convert the rendered resources instead of the files of the directory.")
+	kustomizeCommandFlag = flag.String("kustomize-command", strings.Join(converter.DefaultKustomizeCommand, " "),
+		"Command that renders the kustomization of kustomize, the input directory is\nappended as last argument, e.g. "+
+			"\"kubectl kustomize\".")
+	helmChartFlag = flag.String("input-helm-chart", "", "Render this Helm chart locally with helm-command and "+
+		"convert the legacy MetalLB\nobjects among the rendered resources. Other resources of the chart are ignored.")
+	helmCommandFlag = flag.String("helm-command", strings.Join(converter.DefaultHelmCommand, " "), "Command that "+
+		"renders the chart of input-helm-chart, the chart and a -f argument\nfor every values file are appended.")
+	watchInputFlag = flag.Bool("watch-input", false, "Convert input-dir, then watch it and convert it again "+
+		"whenever a file changes,\nuntil interrupted. Conversion errors are logged and do not stop the watch.")
+	incrementalFlag = flag.Bool("incremental", false, "Skip the conversion into output-dir if neither the legacy "+
+		"resources nor the\nversion and flags changed since the previous incremental run, and otherwise only\n"+
+		"rewrite the output files whose content changes. Requires output-dir.")
+	kustomizationFlag = flag.Bool("kustomization", false, "Write a kustomization.yaml that lists the converted "+
+		"resources into output-dir,\nand a legacy-cleanup component that deletes the legacy AddressPools. Requires "+
+		"output-dir\nand the yaml or json output format.")
+	validateOnlyFlag = flag.Bool("validate-only", false, "Convert and validate the legacy resources, e.g. with "+
+		"validate-for or validate-with,\nbut print nothing.")
+	goldenDirFlag = flag.String("golden-dir", "", "Compare the converted output with the committed files of this "+
+		"directory instead of\nprinting it, e.g. in CI until cut-over. Differences are printed as a unified diff and "+
+		"fail\nthe run with GOLDEN_MISMATCH.")
+	diffClusterFlag = flag.Bool("diff-cluster", false, "Compare the converted output with the IPAddressPools, "+
+		"advertisements and Communities\nof the cluster that it would create or update instead of printing it, e.g. "+
+		"after a partial\nmanual migration. Differences are printed as a unified diff and fail the run with\n"+
+		"CLUSTER_MISMATCH. The legacy resources may be read from the cluster or from input-dir.")
+	streamFlag = flag.Bool("stream", false, "Read, convert and print the legacy resources in batches instead of all at "+
+		"once, for\ninputs with tens of thousands of AddressPools: pages of the API or of standard input,\nor the "+
+		"files of input-dir. Settings that need all AddressPools at once, e.g. merge-l2,\nare rejected. Requires the "+
+		"yaml or json output format.")
+	streamPageSizeFlag = flag.Int("stream-page-size", converter.DefaultStreamPageSize, "Number of AddressPools that "+
+		"stream reads from the API or from standard input at once.")
+	reportFlag = flag.String("report", "", "Write a machine-readable report of the conversion or migration to this "+
+		"file: every\nlegacy object that was processed, the new objects generated from it, the skipped\nobjects and "+
+		"the errors. Written as JSON if the file ends in .json, as YAML otherwise.")
+	syncFlag = flag.Bool("sync", false, "Keep the legacy AddressPools in the cluster and the resources that they were "+
+		"migrated to\nconsistent in both directions, for clusters that run with both for a while. Changes to either "+
+		"side\nare propagated, AddressPools whose sides both changed are reported as conflicts.\nExits with an "+
+		"error if there are conflicts, unless sync-interval is set.")
+	syncIntervalFlag = flag.Duration("sync-interval", 0, "Repeat sync with this interval, e.g. 1m, and log the "+
+		"updates and conflicts of every\nrun. Requires sync.")
+	complianceReportFlag = flag.Bool("compliance-report", false, "Print a read-only compliance report of the "+
+		"cluster and exit: the legacy and new\nresources that exist, whether every legacy AddressPool is equivalent to "+
+		"its\nconverted resources, and the deprecated resources and fields in use. Makes no\nchanges. Use -o json "+
+		"for JSON output.")
+	featureGatesFlag = flag.String("feature-gates", "", "Comma separated list of <feature>=<bool> pairs that enable "+
+		"or disable\nexperimental behaviors, e.g. MergeAdvertisements=true. Known feature gates:\n"+
+		strings.Join(features.Known(), "\n"))
+	pushgatewayURLFlag = flag.String("pushgateway-url", "", "Push the metrics of this run to the Prometheus "+
+		"Pushgateway at this URL when\nthe run ends, e.g. http://pushgateway:9091.")
+	pushgatewayJobFlag = flag.String("pushgateway-job", metrics.DefaultJob, "Job label of the metrics that are pushed "+
+		"to pushgateway-url.")
+	forceFlag = flag.Bool("force", false, "Run an online migration even if the migration history shows that a full "+
+		"migration already completed.")
 )
 
 func main() {
-	flag.Parse()
+	flag.Usage = usage
+	if len(os.Args) > 1 && lookupSubcommand(os.Args[1]) != nil {
+		if err := lookupSubcommand(os.Args[1]).parse(flag.CommandLine, os.Args[2:], flag.ExitOnError); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		flag.Parse()
+	}
+	configFile := *configFlag
+	if configFile == "" {
+		configFile = os.Getenv(options.EnvName(options.ConfigFlag))
+	}
+	getenv := os.Getenv
+	if *argoCDCMPFlag {
+		getenv = argoCDGetenv
+	}
+	if err := options.Apply(flag.CommandLine, configFile, getenv); err != nil {
+		log.Fatal(err)
+	}
+	if err := options.ApplyProfile(flag.CommandLine, *profileFlag); err != nil {
+		log.Fatal(err)
+	}
+	if !*noColorFlag && os.Getenv(converter.NoColorEnvVar) == "" && isTerminal(os.Stderr) {
+		converter.SetColor(true)
+		log.SetOutput(converter.NewColorWriter(os.Stderr))
+	}
+
+	if *versionFlag {
+		info := version.Get()
+		if *jsonFlag || *outputFlag == converter.OutputFormatJSON {
+			out, err := json.MarshalIndent(info, "", "  ")
+			if err != nil {
+				log.Fatal(err)
+			}
+			fmt.Println(string(out))
+			return
+		}
+		fmt.Println(info)
+		return
+	}
+
+	if *monitorManifestFlag != "" {
+		monitor, err := server.MonitorManifest(*monitorManifestFlag, *monitorNamespaceFlag, *monitorPortFlag)
+		if err != nil {
+			log.Fatal(err)
+		}
+		var out []byte
+		if *jsonFlag || *outputFlag == converter.OutputFormatJSON {
+			out, err = json.MarshalIndent(monitor.Object, "", "  ")
+		} else {
+			out, err = yaml.Marshal(monitor.Object)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(strings.TrimSpace(string(out)))
+		return
+	}
 
 	var c client.Client
 	var scheme = runtime.NewScheme()
-	err := metallbv1beta1.AddToScheme(scheme)
+	opts, err := converterOptions()
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = converter.AddToScheme(scheme, opts)
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = corev1.AddToScheme(scheme)
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = apiextensionsv1.AddToScheme(scheme)
 	if err != nil {
 		log.Fatal(err)
 	}
 
 	// Verify parameters.
+	selected, err := selectMode(flag.CommandLine)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := checkModeFlags(flag.CommandLine, selected); err != nil {
+		log.Fatal(err)
+	}
+	isOutputFlagSet := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "o" {
+			isOutputFlagSet = true
+		}
+	})
+	if *jsonFlag {
+		if isOutputFlagSet && *outputFlag != converter.OutputFormatJSON {
+			log.Fatal("json and o are mutually exclusive")
+		}
+		*outputFlag = converter.OutputFormatJSON
+	}
+	if !isSupportedOutputFormat(*outputFlag) {
+		log.Fatalf("unsupported output format %q, must be one of: %s", *outputFlag,
+			strings.Join(converter.SupportedOutputFormats, ", "))
+	}
+	if *replayFlag != "" {
+		if *recordFlag != "" || *inDirFlag != "" || *serveFlag != "" || *argoCDCMPFlag {
+			log.Fatal("replay cannot be combined with record, input-dir, serve or argocd-cmp")
+		}
+	}
+	if *validateLiveFlag && (*validateForFlag != "" || *inDirFlag != "" || *helmChartFlag != "" || *serveFlag != "" ||
+		*argoCDCMPFlag || *replayFlag != "") {
+		log.Fatal("validate-live cannot be combined with validate-for, input-dir, input-helm-chart, serve, argocd-cmp " +
+			"or replay")
+	}
+	if *validateWithFlag != "" {
+		if *validateWithFlag != validateWithEnvtest {
+			log.Fatalf("unsupported validate-with %q, must be one of: %s", *validateWithFlag, validateWithEnvtest)
+		}
+		if len(envtestCRDDirFlags) == 0 {
+			log.Fatal("validate-with envtest requires envtest-crd-dir")
+		}
+		if selected.name != defaultMode && selected.name != "watch-input" && selected.name != "stream" {
+			log.Fatalf("validate-with cannot be combined with %s", selected.flag)
+		}
+	} else if len(envtestCRDDirFlags) > 0 || *envtestAssetsDirFlag != "" {
+		log.Fatal("envtest-crd-dir and envtest-assets-dir require validate-with envtest")
+	}
+	if *recordFlag != "" && (*inDirFlag != "" || *serveFlag != "" || *argoCDCMPFlag) {
+		log.Fatal("record cannot be combined with input-dir, serve or argocd-cmp")
+	}
+	// These modes work on the cluster.
+	switch selected.name {
+	case "detect-drift", "sync", "compliance-report", "serve", "backup", "restore", "verify":
+		if *inDirFlag != "" || *outDirFlag != "" || *fromConfigMapFlag != "" {
+			log.Fatalf("%s cannot be combined with input-dir, output-dir or from-configmap", selected.flag)
+		}
+	}
+	if *complianceReportFlag {
+		if *outputFlag != converter.OutputFormatYAML && *outputFlag != converter.OutputFormatJSON {
+			log.Fatalf("compliance-report only supports the %s and %s output formats", converter.OutputFormatYAML,
+				converter.OutputFormatJSON)
+		}
+	}
+	if *syncIntervalFlag < 0 {
+		log.Fatal("sync-interval must not be negative")
+	}
+	if *syncIntervalFlag > 0 && !*syncFlag {
+		log.Fatal("sync-interval requires sync")
+	}
+	if *driftIntervalFlag < 0 {
+		log.Fatal("drift-interval must not be negative")
+	}
+	if *driftIntervalFlag > 0 && !*detectDriftFlag {
+		log.Fatal("drift-interval requires detect-drift")
+	}
+	if *argoCDCMPFlag {
+		if *outDirFlag != "" || *fromConfigMapFlag != "" || *outputFlag != converter.OutputFormatYAML {
+			log.Fatal("argocd-cmp cannot be combined with output-dir, from-configmap or output formats other than " +
+				"yaml")
+		}
+		if *inDirFlag == "" {
+			*inDirFlag = "."
+		}
+	}
+	if *namespaceFlag != "" || *allNamespacesFlag || *selectorFlag != "" {
+		if *inDirFlag != "" || *fromConfigMapFlag != "" || *helmChartFlag != "" || *serveFlag != "" {
+			log.Fatal("namespace, all-namespaces and selector filter the AddressPools of the cluster and cannot be " +
+				"combined with input-dir, from-configmap, input-helm-chart, serve or argocd-cmp")
+		}
+		if *namespaceFlag != "" && *allNamespacesFlag {
+			log.Fatal("namespace cannot be combined with all-namespaces")
+		}
+	}
+	if *exportFlag {
+		if *inDirFlag != "" || *fromConfigMapFlag != "" {
+			log.Fatal("export cannot be combined with input-dir or from-configmap")
+		}
+		if *outputFlag != converter.OutputFormatYAML && *outputFlag != converter.OutputFormatJSON {
+			log.Fatalf("export only supports the %s and %s output formats", converter.OutputFormatYAML,
+				converter.OutputFormatJSON)
+		}
+	}
+	if *backupFlag {
+		if *backupDirFlag == "" {
+			log.Fatal("backup requires backup-dir")
+		}
+		if *outputFlag != converter.OutputFormatYAML && *outputFlag != converter.OutputFormatJSON {
+			log.Fatalf("backup only supports the %s and %s output formats", converter.OutputFormatYAML,
+				converter.OutputFormatJSON)
+		}
+	}
+	if *dryRunFlag && *restoreFlag == "" {
+		log.Fatal("dry-run requires restore")
+	}
+	if *verifyFlag != "" {
+		if *outputFlag != converter.OutputFormatYAML && *outputFlag != converter.OutputFormatJSON {
+			log.Fatalf("verify only supports the %s and %s output formats", converter.OutputFormatYAML,
+				converter.OutputFormatJSON)
+		}
+	}
 	if *migrationFlag {
-		if *inDirFlag != "" || *outDirFlag != "" || *jsonFlag {
+		if *inDirFlag != "" || *outDirFlag != "" || *jsonFlag || isOutputFlagSet || *fromConfigMapFlag != "" {
 			log.Fatal("no other option may be set if online-migration is requested")
 		}
 		if *backupDirFlag == "" {
 			log.Fatal("you must set a backup directory when migrating resources")
 		}
-	} else {
-		if *backupDirFlag != "" {
-			log.Fatal("backup-dir is only allowed for migrations")
+		if *assignPrioritiesFlag {
+			log.Fatal("assign-priorities is not supported for online migrations, pools are migrated one by one")
+		}
+		if *mergeIdenticalPoolsFlag {
+			log.Fatal("merge-identical-pools is not supported for online migrations, pools are migrated one by one")
+		}
+		if *mergeL2Flag {
+			log.Fatal("merge-l2 is not supported for online migrations, pools are migrated one by one")
+		}
+		if *mergePolicyFlag != "" {
+			log.Fatal("merge-policy is not supported for online migrations, pools are migrated one by one")
+		}
+		if *strictFlag {
+			log.Fatal("strict is not supported for online migrations, it applies to offline conversions")
+		}
+	}
+	if *deleteIntervalFlag < 0 || *createIntervalFlag < 0 {
+		log.Fatal("delete-interval and create-interval must not be negative")
+	}
+	if *deleteAfterFlag < 0 {
+		log.Fatal("delete-after must not be negative")
+	}
+	if *acceptTimeoutFlag <= 0 {
+		log.Fatal("accept-timeout must be positive")
+	}
+	if *waitServicesTimeoutFlag <= 0 {
+		log.Fatal("wait-services-timeout must be positive")
+	}
+	if *maxRetriesFlag < 0 {
+		log.Fatal("max-retries must not be negative")
+	}
+	if *retryIntervalFlag <= 0 {
+		log.Fatal("retry-interval must be positive")
+	}
+	if *deleteAfterFlag > 0 && *phaseFlag == "" {
+		*phaseFlag = converter.PhaseMark
+	}
+	switch *phaseFlag {
+	case "":
+	case converter.PhaseMark:
+		*keepLegacyFlag = true
+	case converter.PhaseFinalize:
+		if *keepLegacyFlag {
+			log.Fatal("keep-legacy cannot be combined with the finalize phase")
+		}
+		if *createFirstFlag {
+			log.Fatal("create-first cannot be combined with the finalize phase")
+		}
+		if *stateFileFlag != "" {
+			log.Fatal("state-file cannot be combined with the finalize phase")
+		}
+	default:
+		log.Fatalf("unsupported phase %q, must be one of: %s, %s", *phaseFlag, converter.PhaseMark,
+			converter.PhaseFinalize)
+	}
+	if *createFirstFlag && *keepLegacyFlag {
+		log.Fatal("create-first cannot be combined with keep-legacy, delete-after or the mark phase")
+	}
+	if *simulateAssignmentsFlag && *inDirFlag != "" {
+		log.Fatal("simulate-assignments cannot be combined with input-dir, it requires cluster access")
+	}
+	if *stripUnsupportedFieldsFlag && *targetVersionFlag == "" {
+		log.Fatal("strip-unsupported-fields requires target-version")
+	}
+	if len(asGroupFlags) > 0 && *asFlag == "" {
+		log.Fatal("as-group requires as")
+	}
+	if *qpsFlag == 0 {
+		log.Fatal("qps must not be 0")
+	}
+	if *burstFlag <= 0 {
+		log.Fatal("burst must be positive")
+	}
+	if *requestTimeoutFlag < 0 {
+		log.Fatal("request-timeout must not be negative")
+	}
+	if *auditConfigMapFlag != "" && *auditLogFlag == "" {
+		log.Fatal("audit-configmap requires audit-log")
+	}
+	var offlineOpts converter.OfflineMigrationOptions
+	if *fromConfigMapFlag != "" {
+		if *inDirFlag != "" {
+			log.Fatal("from-configmap and input-dir are mutually exclusive")
+		}
+		offlineOpts.FromConfigMap, err = converter.ParseNamespacedName(*fromConfigMapFlag)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *inDirFlag == converter.StdinInput && (*kustomizeFlag || *watchInputFlag || *argoCDCMPFlag ||
+		*exportLegacyConfigMapFlag != "") {
+		log.Fatal("input-dir - cannot be combined with kustomize, watch-input, argocd-cmp or export-legacy-configmap, " +
+			"they read a directory")
+	}
+	if *kustomizeFlag {
+		if *inDirFlag == "" {
+			log.Fatal("kustomize requires input-dir")
+		}
+		offlineOpts.Kustomize = strings.Fields(*kustomizeCommandFlag)
+		if len(offlineOpts.Kustomize) == 0 {
+			log.Fatal("kustomize-command must not be empty")
+		}
+	}
+	if *helmChartFlag != "" {
+		if *inDirFlag != "" || *fromConfigMapFlag != "" || *simulateAssignmentsFlag {
+			log.Fatal("input-helm-chart cannot be combined with input-dir, from-configmap or simulate-assignments")
+		}
+		offlineOpts.HelmChart = *helmChartFlag
+		offlineOpts.HelmValues = helmValuesFlags
+		offlineOpts.HelmCommand = strings.Fields(*helmCommandFlag)
+		if len(offlineOpts.HelmCommand) == 0 {
+			log.Fatal("helm-command must not be empty")
+		}
+	} else if len(helmValuesFlags) > 0 {
+		log.Fatal("input-helm-values requires input-helm-chart")
+	}
+	if *incrementalFlag {
+		if *outDirFlag == "" {
+			log.Fatal("incremental requires output-dir")
+		}
+		offlineOpts.CacheKey = cacheKey()
+	}
+	if *kustomizationFlag {
+		if *outDirFlag == "" {
+			log.Fatal("kustomization requires output-dir")
+		}
+		if *outputFlag != converter.OutputFormatYAML && *outputFlag != converter.OutputFormatJSON {
+			log.Fatal("kustomization requires the yaml or json output format")
+		}
+		offlineOpts.Kustomization = true
+	}
+	if *goldenDirFlag != "" {
+		if *outDirFlag != "" || *incrementalFlag {
+			log.Fatal("golden-dir cannot be combined with output-dir or incremental")
+		}
+		offlineOpts.Golden = *goldenDirFlag
+	}
+	if *diffClusterFlag {
+		if *outDirFlag != "" || *goldenDirFlag != "" || *incrementalFlag {
+			log.Fatal("diff-cluster cannot be combined with output-dir, golden-dir or incremental")
+		}
+		if *outputFlag != converter.OutputFormatYAML && *outputFlag != converter.OutputFormatJSON {
+			log.Fatalf("diff-cluster only supports the %s and %s output formats", converter.OutputFormatYAML,
+				converter.OutputFormatJSON)
+		}
+		offlineOpts.DiffCluster = true
+	}
+	if *validateOnlyFlag {
+		if *outDirFlag != "" || *goldenDirFlag != "" || *diffClusterFlag || *incrementalFlag {
+			log.Fatal("validate-only cannot be combined with output-dir, golden-dir, diff-cluster or incremental")
+		}
+		offlineOpts.ValidateOnly = true
+	}
+	if *streamFlag {
+		if *kustomizationFlag || *fromConfigMapFlag != "" || *kustomizeFlag {
+			log.Fatal("stream cannot be combined with kustomization, from-configmap or kustomize")
+		}
+		if *outputFlag != converter.OutputFormatYAML && *outputFlag != converter.OutputFormatJSON {
+			log.Fatalf("stream only supports the %s and %s output formats", converter.OutputFormatYAML,
+				converter.OutputFormatJSON)
+		}
+		if *streamPageSizeFlag <= 0 {
+			log.Fatal("stream-page-size must be positive")
+		}
+	}
+	var report *converter.MigrationReport
+	if *reportFlag != "" {
+		if *phaseFlag == converter.PhaseFinalize {
+			log.Fatal("report cannot be combined with the finalize phase")
+		}
+		report = &converter.MigrationReport{}
+		offlineOpts.Report = report
+	}
+	if *watchInputFlag {
+		if *inDirFlag == "" {
+			log.Fatal("watch-input requires input-dir")
+		}
+		if *outDirFlag != "" && filepath.Clean(*outDirFlag) == filepath.Clean(*inDirFlag) {
+			log.Fatal("watch-input requires an output-dir that differs from input-dir")
+		}
+	}
+	var pushGrouping map[string]string
+	if *pushgatewayURLFlag != "" {
+		if *serveFlag != "" || *watchInputFlag {
+			log.Fatal("pushgateway-url cannot be combined with serve or watch-input")
+		}
+		pushGrouping, err = metrics.ParseGrouping(pushGroupingFlags)
+		if err != nil {
+			log.Fatal(err)
+		}
+	} else if len(pushGroupingFlags) > 0 {
+		log.Fatal("pushgateway-grouping requires pushgateway-url")
+	}
+	if *metricsAddressFlag != "" && *serveFlag != "" {
+		log.Fatal("metrics-address cannot be combined with serve")
+	}
+	var exportConfigMap types.NamespacedName
+	if *exportLegacyConfigMapFlag != "" {
+		if *fromConfigMapFlag != "" {
+			log.Fatal("export-legacy-configmap and from-configmap are mutually exclusive")
+		}
+		if *outputFlag != converter.OutputFormatYAML && *outputFlag != converter.OutputFormatJSON {
+			log.Fatalf("export-legacy-configmap only supports the %s and %s output formats",
+				converter.OutputFormatYAML, converter.OutputFormatJSON)
+		}
+		exportConfigMap, err = converter.ParseNamespacedName(*exportLegacyConfigMapFlag)
+		if err != nil {
+			log.Fatal(err)
 		}
 	}
 
 	// Set up the client.
-	if *inDirFlag == "" {
-		conf, err := config.GetConfig()
+	var recording *converter.Recording
+	var accessReviewer converter.AccessReviewer
+	if *replayFlag != "" {
+		recording, err = converter.LoadRecording(*replayFlag)
 		if err != nil {
-			log.Fatalf("error getting kubernetes configuration, did you export KUBECONFIG? Received error: %q", err)
+			log.Fatal(err)
+		}
+		c = recording.Client(scheme)
+	} else if (*inDirFlag == "" && *helmChartFlag == "" || *diffClusterFlag) && *serveFlag == "" {
+		// A diff against the cluster needs the cluster even if the legacy resources are read from elsewhere.
+		conf, err := kubeConfig()
+		if err != nil {
+			log.Fatalf("error getting kubernetes configuration, did you export KUBECONFIG or pass -kubeconfig? "+
+				"Received error: %q", err)
 		}
 		c, err = client.New(conf, client.Options{Scheme: scheme})
 		if err != nil {
 			log.Fatal(err)
 		}
+		// The preflight checks of an online migration review the permissions of the user.
+		if *migrationFlag {
+			authorizationClient, err := authorizationv1client.NewForConfig(conf)
+			if err != nil {
+				log.Fatal(err)
+			}
+			accessReviewer = authorizationClient.SelfSubjectAccessReviews()
+		}
+	}
+	// Retry the requests of migrations when the API server fails transiently. Only the outcome of the last attempt
+	// of a request is recorded, counted and audited.
+	if *migrationFlag && recording == nil && c != nil {
+		c = converter.RetryPolicy{MaxRetries: *maxRetriesFlag, Interval: *retryIntervalFlag}.WrapClient(c)
+	}
+	// Events are created with this client so that they are neither recorded, counted nor audited.
+	var eventRecorder record.EventRecorder
+	if *migrationFlag && *eventsFlag && recording == nil && c != nil {
+		eventRecorder = converter.NewEventRecorder(c)
+	}
+	var recorder *converter.Recorder
+	if *recordFlag != "" || recording != nil {
+		if *recordFlag != "" {
+			if err := os.MkdirAll(*recordFlag, 0755); err != nil {
+				log.Fatalf("cannot create recording directory, err: %q", err)
+			}
+		}
+		recorder = converter.NewRecorder(version.Version, os.Args[1:])
+		c = recorder.WrapClient(c)
+	}
+
+	// Check the migration history and count the mutations of this run. The history and audit ConfigMaps are
+	// written with the original client so that their updates are neither counted nor audited.
+	rawClient := c
+	var history *converter.HistoryRecorder
+	if *migrationFlag {
+		history = converter.NewHistoryRecorder(*historyNamespaceFlag)
+		previous, err := history.LastCompletedFullMigration(context.Background(), c)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if previous != nil && !*forceFlag && !*keepLegacyFlag && *phaseFlag != converter.PhaseFinalize {
+			log.Fatalf("a full migration already completed at %s (version %s), refusing to run again without -force",
+				previous.Timestamp, previous.Version)
+		}
+		c = history.WrapClient(c)
 	}
 
-	// Either print to stdout or to directory ..o
-	if !*migrationFlag {
-		err = converter.OfflineMigration(c, scheme, *inDirFlag, *outDirFlag, *jsonFlag)
+	// Record the metrics of this run for the Pushgateway or for scrapes of metrics-address.
+	var run *metrics.Run
+	if *pushgatewayURLFlag != "" || *metricsAddressFlag != "" {
+		run = metrics.NewRun(runMode(opts))
+		opts.ConversionObserver = run.ObserveConversion
+		opts.MigrationObserver = func(_, _ string, duration time.Duration, err error) {
+			errorCode := ""
+			if err != nil {
+				errorCode = string(converter.Code(err))
+			}
+			run.ObserveMigration(duration, errorCode)
+		}
+		if c != nil {
+			c = run.WrapClient(c)
+		}
+	}
+
+	// Summarize the destructive actions of this run and ask for confirmation.
+	if *migrationFlag && !*yesFlag {
+		var actions []string
+		if *phaseFlag == converter.PhaseFinalize {
+			actions, err = converter.PlanFinalizeMigration(context.Background(), rawClient, *deleteAfterFlag, opts)
+		} else {
+			actions, err = converter.PlanOnlineMigration(context.Background(), rawClient, converter.OnlineMigrationOptions{
+				Options:    opts,
+				KeepLegacy: *keepLegacyFlag,
+			})
+		}
 		if err != nil {
 			log.Fatal(err)
 		}
-		return
+		if len(actions) > 0 {
+			if !isTerminal(os.Stdin) {
+				log.Fatal("stdin is not a terminal, refusing to perform destructive actions without -yes")
+			}
+			confirmed, err := converter.Confirm(os.Stdin, os.Stderr, actions)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if !confirmed {
+				log.Fatal("aborted by user")
+			}
+		}
+	}
+
+	// Set up the audit log.
+	var auditLog *converter.AuditLog
+	if *auditLogFlag != "" {
+		operator := *operatorFlag
+		if operator == "" {
+			operator = operatorIdentity()
+		}
+		auditLog, err = converter.NewAuditLog(*auditLogFlag, operator)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if *auditConfigMapFlag != "" {
+			key, err := converter.ParseNamespacedName(*auditConfigMapFlag)
+			if err != nil {
+				log.Fatalf("invalid audit-configmap, err: %q", err)
+			}
+			auditLog.SetConfigMap(key.Namespace, key.Name)
+		}
+		c = auditLog.WrapClient(c)
+	}
+
+	// Set up tracing.
+	tracer := tracing.NewTracer(*otlpEndpointFlag)
+	converter.SetTracer(tracer)
+	stopSignals := exitOnSignal(tracer)
+	converter.SetQuiet(*quietFlag)
+	if err := features.Set(*featureGatesFlag); err != nil {
+		fatalf(tracer, "%v", err)
+	}
+	if features.Enabled(features.WebhookBypass) && !*createFirstFlag {
+		fatalf(tracer, "feature gate %s requires create-first", features.WebhookBypass)
+	}
+	if *validateLiveFlag {
+		bundle, err := converter.LoadServedSchemas(context.Background(), c, opts)
+		if err != nil {
+			fatalf(tracer, "%v", err)
+		}
+		opts.ValidateAgainst = bundle
+	}
+
+	if *pprofAddressFlag != "" {
+		if !*quietFlag {
+			log.Printf("serving pprof endpoints on %s/debug/pprof/ ...", *pprofAddressFlag)
+		}
+		go func() {
+			if err := server.NewHTTPServer(*pprofAddressFlag, server.DebugHandler()).ListenAndServe(); err != nil {
+				log.Printf("warning: cannot serve pprof endpoints, err: %v", err)
+			}
+		}()
+	}
+	if *metricsAddressFlag != "" {
+		if !*quietFlag {
+			log.Printf("serving metrics on %s%s ...", *metricsAddressFlag, server.MetricsPath)
+		}
+		mux := http.NewServeMux()
+		mux.Handle(server.MetricsPath, run.Handler())
+		go func() {
+			if err := server.NewHTTPServer(*metricsAddressFlag, mux).ListenAndServe(); err != nil {
+				log.Printf("warning: cannot serve metrics, err: %v", err)
+			}
+		}()
+	}
+
+	var env *envtest.Environment
+	if *validateWithFlag == validateWithEnvtest {
+		if !*quietFlag {
+			log.Print("starting a local API server for validation ...")
+		}
+		env = &envtest.Environment{AssetsDir: *envtestAssetsDirFlag, CRDDirs: envtestCRDDirFlags}
+		validator, err := env.Start(scheme)
+		if err != nil {
+			if stopErr := env.Stop(); stopErr != nil {
+				log.Printf("could not stop the local API server, err: %q", stopErr)
+			}
+			fatalf(tracer, "cannot start the local API server for validate-with %s, err: %q", validateWithEnvtest, err)
+		}
+		opts.DryRunValidator = validator
+	}
+	offlineOpts.Options = opts
+
+	err = selected.run(context.Background(), &modeEnv{
+		c:               c,
+		scheme:          scheme,
+		opts:            opts,
+		offlineOpts:     offlineOpts,
+		tracer:          tracer,
+		exportConfigMap: exportConfigMap,
+		accessReviewer:  accessReviewer,
+		eventRecorder:   eventRecorder,
+		report:          report,
+		stopSignals:     stopSignals,
+	})
+	if env != nil {
+		if stopErr := env.Stop(); stopErr != nil {
+			log.Printf("could not stop the local API server, err: %q", stopErr)
+		}
+	}
+	if history != nil {
+		entry := converter.HistoryEntry{
+			Version:   version.Version,
+			Mode:      runMode(opts),
+			Flags:     map[string]string{},
+			Result:    converter.HistoryResultSuccess,
+			BackupDir: *backupDirFlag,
+		}
+		flag.Visit(func(f *flag.Flag) {
+			entry.Flags[f.Name] = f.Value.String()
+		})
+		if err != nil {
+			entry.Result = converter.HistoryResultFailure
+			entry.Error = err.Error()
+			entry.ErrorCode = converter.Code(err)
+		}
+		if recordErr := history.Record(context.Background(), rawClient, entry); recordErr != nil {
+			log.Printf("could not record migration history, err: %q", recordErr)
+		}
+	}
+	if run != nil {
+		exitCode := 0
+		if err != nil {
+			exitCode = converter.ExitCode(err)
+			run.ObserveError(string(converter.Code(err)))
+		} else if *migrationFlag {
+			run.MigrationComplete()
+		}
+		run.Finish(exitCode)
+		if *pushgatewayURLFlag != "" {
+			pushCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			if pushErr := run.Push(pushCtx, *pushgatewayURLFlag, *pushgatewayJobFlag, pushGrouping); pushErr != nil {
+				log.Printf("could not push metrics, err: %q", pushErr)
+			}
+			cancel()
+		}
+	}
+	if auditLog != nil {
+		if closeErr := auditLog.Close(context.Background(), rawClient); closeErr != nil {
+			log.Printf("could not finalize audit log, err: %q", closeErr)
+		}
+	}
+	if report != nil {
+		if reportErr := report.WriteFile(*reportFlag); reportErr != nil {
+			log.Printf("could not write migration report, err: %q", reportErr)
+		}
+	}
+	if *recordFlag != "" {
+		if saveErr := recorder.Save(*recordFlag); saveErr != nil {
+			log.Printf("could not save recording, err: %q", saveErr)
+		}
+	}
+	if recording != nil {
+		differences := recording.CompareMutations(recorder.Mutations())
+		for _, difference := range differences {
+			log.Printf("replay: %s", difference)
+		}
+		if len(differences) == 0 && !*quietFlag {
+			log.Printf("replay: all %d recorded mutations were reproduced", len(recording.Mutations))
+		}
+	}
+	shutdownTracer(tracer)
+	if errors.Is(err, converter.ErrWebhookRejected) {
+		log.Print("the API server rejected a converted resource, set -target-version to the running MetalLB " +
+			"release to only generate fields that it supports")
 	}
-	// or migrate the API objects directly.
-	err = converter.OnlineMigration(c, scheme, *backupDirFlag, *jsonFlag)
 	if err != nil {
-		log.Fatal(err)
+		log.Printf("error [%s]: %v", converter.Code(err), err)
+		os.Exit(converter.ExitCode(err))
+	}
+}
+
+// shutdownTracer exports the spans of tracer that were not exported yet.
+func shutdownTracer(tracer *tracing.Tracer) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := tracer.Shutdown(ctx); err != nil {
+		log.Printf("could not export traces, err: %q", err)
+	}
+}
+
+// fatalf exports the spans of tracer that were not exported yet, like the regular exit of main, and exits like
+// log.Fatalf.
+func fatalf(tracer *tracing.Tracer, format string, v ...interface{}) {
+	shutdownTracer(tracer)
+	log.Fatalf(format, v...)
+}
+
+// exitOnSignal exports the spans of tracer that were not exported yet and exits when the process receives a SIGTERM
+// or SIGINT, which would otherwise terminate it without exporting them. The returned function stops the handling of
+// the signals. If tracing is disabled, the signals are not handled.
+func exitOnSignal(tracer *tracing.Tracer) (stop func()) {
+	if tracer == nil {
+		return func() {}
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+	go func() {
+		received, ok := <-signals
+		if !ok {
+			return
+		}
+		shutdownTracer(tracer)
+		exitCode := 1
+		if s, ok := received.(syscall.Signal); ok {
+			exitCode = 128 + int(s)
+		}
+		os.Exit(exitCode)
+	}()
+	return func() {
+		signal.Stop(signals)
+		close(signals)
+	}
+}
+
+// usage prints the usage message of the subcommands and of all flags except for the hiddenFlags.
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s <command> [flags] | %s [flags]\n\n", os.Args[0], os.Args[0])
+	printSubcommands()
+	visible := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	visible.SetOutput(flag.CommandLine.Output())
+	flag.VisitAll(func(f *flag.Flag) {
+		if !hiddenFlags[f.Name] {
+			visible.Var(f.Value, f.Name, f.Usage)
+		}
+	})
+	visible.PrintDefaults()
+}
+
+// cacheKey returns a key that identifies the version and the flags of this run, for incremental conversions.
+func cacheKey() string {
+	settings := []string{version.Version}
+	flag.Visit(func(f *flag.Flag) {
+		settings = append(settings, f.Name+"="+f.Value.String())
+	})
+	sum := sha256.Sum256([]byte(strings.Join(settings, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// converterOptions returns the conversion and migration options that the flags select. Options that need a client,
+// such as the schemas of validate-live, are set by main.
+func converterOptions() (converter.Options, error) {
+	opts := converter.Options{
+		SourceAPIGroup:            *sourceAPIGroupFlag,
+		TargetAPIGroup:            *targetAPIGroupFlag,
+		IgnoreUnknown:             *ignoreUnknownFlag,
+		DuplicatePolicy:           *duplicatesFlag,
+		Namespace:                 *namespaceFlag,
+		NormalizeAddresses:        *normalizeAddressesFlag,
+		CommunityResources:        *communityResourcesFlag,
+		ExpandCommunityAliases:    *expandCommunityAliasesFlag,
+		AssignPriorities:          *assignPrioritiesFlag,
+		MergeIdenticalPools:       *mergeIdenticalPoolsFlag,
+		MergeL2:                   *mergeL2Flag,
+		SplitL2AddressFamilies:    *splitL2AddressFamiliesFlag,
+		SplitPoolsPerRange:        *splitPoolsPerRangeFlag,
+		NameCollisionPolicy:       *nameCollisionsFlag,
+		AdvertisementNaming:       *advertisementNamesFlag,
+		SkipMetadataPropagation:   !*propagateMetadataFlag,
+		AdvertisementMetadataKeys: advertisementMetadataFlags,
+		OperatorCompat:            *operatorCompatFlag,
+		Provenance:                *provenanceFlag,
+		PeerPasswords:             *peerPasswordsFlag,
+		StripUnsupportedFields:    *stripUnsupportedFieldsFlag,
+		FailOnLocalPrefConflicts:  *failOnLocalPrefConflictsFlag,
+		StrictValidation:          *strictFlag,
+		SimulateAssignments:       *simulateAssignmentsFlag,
+		OutputGroupBy:             *outputGroupByFlag,
+		OutputLayout:              *outputLayoutFlag,
+		ServerSideApply:           *serverSideApplyFlag,
+	}
+	if err := opts.Validate(); err != nil {
+		return opts, err
+	}
+	var err error
+	if opts.Selector, err = converter.ParseSelector(*selectorFlag); err != nil {
+		return opts, err
+	}
+	if *validateForFlag != "" {
+		if opts.ValidateAgainst, err = schema.Load(*validateForFlag); err != nil {
+			return opts, err
+		}
+	}
+	if *targetVersionFlag != "" {
+		if opts.Target, err = schema.Load(*targetVersionFlag); err != nil {
+			return opts, err
+		}
+	}
+	if opts.FailurePoints, err = converter.ParseFailurePoints(*injectFailuresFlag); err != nil {
+		return opts, err
+	}
+	if *peersPolicyFlag != "" {
+		if opts.PeersPolicy, err = converter.LoadPeersPolicy(*peersPolicyFlag); err != nil {
+			return opts, err
+		}
+	}
+	if *mergePolicyFlag != "" {
+		if opts.MergePolicy, err = converter.LoadMergePolicy(*mergePolicyFlag); err != nil {
+			return opts, err
+		}
+	}
+	if *operationPolicyFlag != "" {
+		if opts.OperationPolicies, err = converter.LoadOperationPolicies(*operationPolicyFlag); err != nil {
+			return opts, err
+		}
+	}
+	if opts.ExtraLabels, err = converter.ParseLabels(addLabelFlags); err != nil {
+		return opts, err
+	}
+	if opts.ExtraAnnotations, err = converter.ParseAnnotations(addAnnotationFlags); err != nil {
+		return opts, err
+	}
+	return opts, nil
+}
+
+// runMode returns the mode of this run, as selected by the flags and opts. The modes of online migrations are the
+// modes of their history entries.
+func runMode(opts converter.Options) string {
+	switch {
+	case *detectDriftFlag:
+		return "detect-drift"
+	case *complianceReportFlag:
+		return "compliance-report"
+	case *syncFlag:
+		return "sync"
+	case *argoCDCMPFlag:
+		return "argocd-cmp"
+	case *exportLegacyConfigMapFlag != "":
+		return "export-legacy-configmap"
+	case *exportFlag:
+		return "export"
+	case *backupFlag:
+		return "backup"
+	case *restoreFlag != "":
+		return "restore"
+	case *verifyFlag != "":
+		return "verify"
+	case !*migrationFlag:
+		return "offline-migration"
+	case *phaseFlag == converter.PhaseFinalize:
+		return converter.HistoryModeFinalize
+	}
+	return converter.MigrationHistoryMode(converter.OnlineMigrationOptions{Options: opts, KeepLegacy: *keepLegacyFlag})
+}
+
+// isSupportedOutputFormat returns true if format is one of converter.SupportedOutputFormats.
+func isSupportedOutputFormat(format string) bool {
+	for _, f := range converter.SupportedOutputFormats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// kubeConfig returns the configuration of the cluster that the kubeconfig and context flags select, impersonating the
+// user and groups of the as and as-group flags, with the rate limits of the qps and burst flags and the timeout of the
+// request-timeout flag.
+func kubeConfig() (*rest.Config, error) {
+	conf, err := config.GetConfigWithContext(*contextFlag)
+	if err != nil {
+		return nil, err
+	}
+	conf.Impersonate = rest.ImpersonationConfig{UserName: *asFlag, Groups: asGroupFlags}
+	conf.QPS = float32(*qpsFlag)
+	conf.Burst = *burstFlag
+	conf.Timeout = *requestTimeoutFlag
+	return conf, nil
+}
+
+// operatorIdentity returns the local user name and, if available, the kubeconfig user of the selected context and the
+// impersonated user.
+func operatorIdentity() string {
+	identity := "unknown"
+	if u, err := user.Current(); err == nil {
+		identity = u.Username
+	}
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	if kubeconfig := flag.Lookup(config.KubeconfigFlagName); kubeconfig != nil {
+		loadingRules.ExplicitPath = kubeconfig.Value.String()
+	}
+	rawConfig, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules,
+		&clientcmd.ConfigOverrides{}).RawConfig()
+	if err == nil {
+		contextName := rawConfig.CurrentContext
+		if *contextFlag != "" {
+			contextName = *contextFlag
+		}
+		if kubeContext, ok := rawConfig.Contexts[contextName]; ok && kubeContext.AuthInfo != "" {
+			identity = fmt.Sprintf("%s (kubeconfig user %s)", identity, kubeContext.AuthInfo)
+		}
+	}
+	if *asFlag != "" {
+		identity = fmt.Sprintf("%s as %s", identity, *asFlag)
+	}
+	return identity
+}
+
+// isTerminal returns true if f is a character device such as an interactive terminal.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
+// argoCDGetenv looks up key among the environment variables that ArgoCD passes to Config Management Plugins, which
+// carry the converter.ArgoCDEnvPrefix, and falls back to the unprefixed variable.
+func argoCDGetenv(key string) string {
+	if value, ok := os.LookupEnv(converter.ArgoCDEnvPrefix + key); ok {
+		return value
 	}
+	return os.Getenv(key)
 }
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"strings"
+	"testing"
+)
+
+// testValue is a flag.Value that stores the value of a flag of flag.CommandLine, so that tests can parse command lines
+// without changing the flags of the tool.
+type testValue struct {
+	value  string
+	isBool bool
+}
+
+func (v *testValue) String() string {
+	return v.value
+}
+
+func (v *testValue) Set(value string) error {
+	v.value = value
+	return nil
+}
+
+func (v *testValue) IsBoolFlag() bool {
+	return v.isBool
+}
+
+// newTestCommandLine returns a flag.FlagSet with all flags of flag.CommandLine, set to their defaults.
+func newTestCommandLine() *flag.FlagSet {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	flag.VisitAll(func(f *flag.Flag) {
+		b, ok := f.Value.(interface{ IsBoolFlag() bool })
+		fs.Var(&testValue{value: f.DefValue, isBool: ok && b.IsBoolFlag()}, f.Name, f.Usage)
+	})
+	return fs
+}
+
+// parseCommandLine parses args like main, with or without a subcommand, and returns the flags and the selected mode.
+func parseCommandLine(args []string) (*flag.FlagSet, *mode, error) {
+	fs := newTestCommandLine()
+	var err error
+	if len(args) > 0 && lookupSubcommand(args[0]) != nil {
+		err = lookupSubcommand(args[0]).parse(fs, args[1:], flag.ContinueOnError)
+	} else {
+		err = fs.Parse(args)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	m, err := selectMode(fs)
+	if err != nil {
+		return nil, nil, err
+	}
+	return fs, m, checkModeFlags(fs, m)
+}
+
+func TestParseCommandLine(t *testing.T) {
+	tcs := map[string]struct {
+		args          []string
+		expectedMode  string
+		expectedFlags map[string]string
+		expectedError string
+	}{
+		"no flags": {
+			args:         nil,
+			expectedMode: defaultMode,
+		},
+		"convert": {
+			args:          []string{"convert", "-input-dir", "legacy", "-o", "json"},
+			expectedMode:  defaultMode,
+			expectedFlags: map[string]string{"input-dir": "legacy", "o": "json"},
+		},
+		"migrate": {
+			args:          []string{"migrate", "-backup-dir", "backup", "-yes"},
+			expectedMode:  "migrate",
+			expectedFlags: map[string]string{"online-migration": "true", "backup-dir": "backup", "yes": "true"},
+		},
+		"backup": {
+			args:          []string{"backup", "backup"},
+			expectedMode:  "backup",
+			expectedFlags: map[string]string{"backup": "true", "backup-dir": "backup"},
+		},
+		"flat backup": {
+			args:          []string{"-backup", "-backup-dir", "backup"},
+			expectedMode:  "backup",
+			expectedFlags: map[string]string{"backup": "true", "backup-dir": "backup"},
+		},
+		"restore with audit log": {
+			args:          []string{"-restore", "backup", "-audit-log", "audit.jsonl"},
+			expectedMode:  "restore",
+			expectedFlags: map[string]string{"restore": "backup", "audit-log": "audit.jsonl"},
+		},
+		"diff against the cluster": {
+			args:          []string{"diff"},
+			expectedMode:  defaultMode,
+			expectedFlags: map[string]string{"diff-cluster": "true", "golden-dir": ""},
+		},
+		"diff against a directory": {
+			args:          []string{"diff", "golden"},
+			expectedMode:  defaultMode,
+			expectedFlags: map[string]string{"diff-cluster": "false", "golden-dir": "golden"},
+		},
+		"explicitly disabled mode": {
+			args:         []string{"-online-migration=false"},
+			expectedMode: defaultMode,
+		},
+		"sync with interval": {
+			args:          []string{"-sync", "-sync-interval", "1m"},
+			expectedMode:  "sync",
+			expectedFlags: map[string]string{"sync-interval": "1m"},
+		},
+		"unknown subcommand flag": {
+			args:          []string{"convert", "-backup-dir", "backup"},
+			expectedError: "flag provided but not defined: -backup-dir",
+		},
+		"missing argument": {
+			args:          []string{"restore"},
+			expectedError: "restore requires exactly one argument: <dir>",
+		},
+		"too many arguments": {
+			args:          []string{"diff", "a", "b"},
+			expectedError: "diff takes at most one argument: [<dir>]",
+		},
+		"unexpected argument": {
+			args:          []string{"version", "v1"},
+			expectedError: `version takes no arguments, got "v1"`,
+		},
+		"two modes": {
+			args:          []string{"-sync", "-restore", "backup"},
+			expectedError: "sync cannot be combined with restore",
+		},
+		"three modes": {
+			args:          []string{"-online-migration", "-serve", ":8080", "-export"},
+			expectedError: "serve cannot be combined with export, online-migration",
+		},
+		"migration flag of another mode": {
+			args:          []string{"-sync", "-keep-legacy"},
+			expectedError: "keep-legacy is only allowed for migrate",
+		},
+		"audit log of a conversion": {
+			args:          []string{"-audit-log", "audit.jsonl"},
+			expectedError: "audit-log is only allowed for migrate, sync, restore",
+		},
+		"report of a migration": {
+			args:          []string{"migrate", "-report", "report.json"},
+			expectedMode:  "migrate",
+			expectedFlags: map[string]string{"report": "report.json"},
+		},
+		"golden dir of a migration": {
+			args:          []string{"-online-migration", "-golden-dir", "golden"},
+			expectedError: "golden-dir is only allowed for convert",
+		},
+	}
+	for desc, tc := range tcs {
+		fs, m, err := parseCommandLine(tc.args)
+		if tc.expectedError != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.expectedError) {
+				t.Fatalf("TestParseCommandLine(%s): expected error %q but got %v", desc, tc.expectedError, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("TestParseCommandLine(%s): unexpected error, err: %q", desc, err)
+		}
+		if m.name != tc.expectedMode {
+			t.Fatalf("TestParseCommandLine(%s): expected mode %q but got %q", desc, tc.expectedMode, m.name)
+		}
+		for name, expected := range tc.expectedFlags {
+			if got := fs.Lookup(name).Value.String(); got != expected {
+				t.Fatalf("TestParseCommandLine(%s): expected flag %s to be %q but got %q", desc, name, expected, got)
+			}
+		}
+	}
+}
+
+func TestSubcommandFlags(t *testing.T) {
+	// Every flag that the subcommands, the modes and modeFlags refer to must exist.
+	fs := newTestCommandLine()
+	for _, s := range subcommands {
+		for _, group := range append([][]string{commonFlags}, s.flags...) {
+			for _, name := range group {
+				if fs.Lookup(name) == nil {
+					t.Fatalf("TestSubcommandFlags(%s): unknown flag %q", s.name, name)
+				}
+			}
+		}
+		for _, set := range []map[string]string{s.set, s.withoutArg} {
+			for name := range set {
+				if fs.Lookup(name) == nil {
+					t.Fatalf("TestSubcommandFlags(%s): unknown mode flag %q", s.name, name)
+				}
+			}
+		}
+	}
+	for _, m := range modes {
+		if m.flag != "" && fs.Lookup(m.flag) == nil {
+			t.Fatalf("TestSubcommandFlags(%s): unknown mode flag %q", m.name, m.flag)
+		}
+	}
+	for name := range modeFlags {
+		if fs.Lookup(name) == nil {
+			t.Fatalf("TestSubcommandFlags: unknown flag %q of modeFlags", name)
+		}
+		for _, m := range modeFlags[name] {
+			if lookupMode(m) == nil {
+				t.Fatalf("TestSubcommandFlags: unknown mode %q of flag %q", m, name)
+			}
+		}
+	}
+}
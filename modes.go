@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/andreaskaris/metallb-converter/pkg/converter"
+	"github.com/andreaskaris/metallb-converter/pkg/server"
+	"github.com/andreaskaris/metallb-converter/pkg/tracing"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// defaultMode is the mode of runs that select no mode: the legacy resources are converted offline.
+const defaultMode = "convert"
+
+// mode is a mode of the tool, e.g. sync. Modes exclude each other. A mode is selected by its flag on the flat command
+// line, or by the subcommand that sets this flag.
+type mode struct {
+	name string
+	// flag is the flag that selects the mode, empty for defaultMode.
+	flag string
+	run  func(ctx context.Context, env *modeEnv) error
+}
+
+// modeEnv is what main prepared for the run of a mode.
+type modeEnv struct {
+	c               client.Client
+	scheme          *runtime.Scheme
+	opts            converter.Options
+	offlineOpts     converter.OfflineMigrationOptions
+	tracer          *tracing.Tracer
+	exportConfigMap types.NamespacedName
+	accessReviewer  converter.AccessReviewer
+	eventRecorder   record.EventRecorder
+	report          *converter.MigrationReport
+	// stopSignals stops the handling of SIGTERM and SIGINT of exitOnSignal, for modes that handle them themselves.
+	stopSignals func()
+}
+
+var (
+	// modes are all modes of the tool.
+	modes = []mode{
+		{name: "detect-drift", flag: "detect-drift", run: runDetectDrift},
+		{name: "compliance-report", flag: "compliance-report", run: runComplianceReport},
+		{name: "sync", flag: "sync", run: runSync},
+		{name: "argocd-cmp", flag: "argocd-cmp", run: runArgoCDCMP},
+		{name: "serve", flag: "serve", run: runServe},
+		{name: "export-legacy-configmap", flag: "export-legacy-configmap", run: runExportLegacyConfigMap},
+		{name: "export", flag: "export", run: runExport},
+		{name: "backup", flag: "backup", run: runBackup},
+		{name: "restore", flag: "restore", run: runRestore},
+		{name: "verify", flag: "verify", run: runVerify},
+		{name: "watch-input", flag: "watch-input", run: runWatchInput},
+		{name: "stream", flag: "stream", run: runStream},
+		{name: "migrate", flag: "online-migration", run: runMigrate},
+		{name: defaultMode, run: runConvert},
+	}
+
+	// modeFlags are the flags that only some modes support, and these modes.
+	modeFlags = map[string][]string{
+		"backup-dir":        {"migrate", "backup"},
+		"keep-legacy":       {"migrate"},
+		"phase":             {"migrate"},
+		"delete-after":      {"migrate"},
+		"delete-interval":   {"migrate"},
+		"create-interval":   {"migrate"},
+		"create-first":      {"migrate"},
+		"wait-services":     {"migrate"},
+		"server-side-apply": {"migrate"},
+		"state-file":        {"migrate"},
+		"audit-log":         {"migrate", "sync", "restore"},
+		"report":            {"migrate", defaultMode},
+		"golden-dir":        {defaultMode},
+		"diff-cluster":      {defaultMode},
+		"validate-only":     {defaultMode},
+		"input-helm-chart":  {defaultMode},
+		"incremental":       {defaultMode, "watch-input"},
+	}
+)
+
+// selectMode returns the mode that the flags of fs select, or an error if they select more than one.
+func selectMode(fs *flag.FlagSet) (*mode, error) {
+	var selected []*mode
+	for i := range modes {
+		if modes[i].flag != "" && isFlagSet(fs, modes[i].flag) {
+			selected = append(selected, &modes[i])
+		}
+	}
+	switch len(selected) {
+	case 0:
+		return lookupMode(defaultMode), nil
+	case 1:
+		return selected[0], nil
+	}
+	var names []string
+	for _, m := range selected[1:] {
+		names = append(names, m.flag)
+	}
+	return nil, fmt.Errorf("%s cannot be combined with %s", selected[0].flag, strings.Join(names, ", "))
+}
+
+// checkModeFlags returns an error if a flag of fs is set that m does not support, see modeFlags.
+func checkModeFlags(fs *flag.FlagSet, m *mode) error {
+	var names []string
+	for name := range modeFlags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if isFlagSet(fs, name) && !contains(modeFlags[name], m.name) {
+			return fmt.Errorf("%s is only allowed for %s", name, strings.Join(modeFlags[name], ", "))
+		}
+	}
+	return nil
+}
+
+// lookupMode returns the mode of name, or nil if there is none.
+func lookupMode(name string) *mode {
+	for i := range modes {
+		if modes[i].name == name {
+			return &modes[i]
+		}
+	}
+	return nil
+}
+
+// isFlagSet returns true if the flag name of fs has a value other than its default.
+func isFlagSet(fs *flag.FlagSet, name string) bool {
+	f := fs.Lookup(name)
+	return f != nil && f.Value.String() != f.DefValue
+}
+
+// contains returns true if values contains value.
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// runDetectDrift reports drift between legacy and new resources.
+func runDetectDrift(ctx context.Context, env *modeEnv) error {
+	return converter.MonitorDrift(ctx, env.c, *driftIntervalFlag, env.opts)
+}
+
+// runComplianceReport reports the compliance of the cluster without changing it.
+func runComplianceReport(ctx context.Context, env *modeEnv) error {
+	report, err := converter.CheckCompliance(ctx, env.c, env.opts)
+	if err != nil {
+		return err
+	}
+	return report.Print(os.Stdout, *outputFlag == converter.OutputFormatJSON)
+}
+
+// runSync syncs legacy and new resources in both directions until a SIGTERM or SIGINT, so that the audit log is
+// finalized.
+func runSync(ctx context.Context, env *modeEnv) error {
+	env.stopSignals()
+	syncCtx, stopSync := signal.NotifyContext(ctx, syscall.SIGTERM, os.Interrupt)
+	defer stopSync()
+	return converter.MonitorSync(syncCtx, env.c, *syncIntervalFlag, env.opts)
+}
+
+// runArgoCDCMP renders manifests for ArgoCD.
+func runArgoCDCMP(_ context.Context, env *modeEnv) error {
+	return converter.GenerateManifests(env.scheme, *inDirFlag, os.Stdout, env.opts)
+}
+
+// runServe serves conversions over HTTP.
+func runServe(_ context.Context, env *modeEnv) error {
+	if !*quietFlag {
+		log.Printf("serving conversions on %s ...", *serveFlag)
+	}
+	return server.NewHTTPServer(*serveFlag, server.New(env.scheme, env.opts)).ListenAndServe()
+}
+
+// runExportLegacyConfigMap exports the current resources into a legacy ConfigMap.
+func runExportLegacyConfigMap(ctx context.Context, env *modeEnv) error {
+	return converter.ExportLegacyConfigMap(ctx, env.c, env.scheme, *inDirFlag, *outDirFlag,
+		*outputFlag == converter.OutputFormatJSON, env.exportConfigMap)
+}
+
+// runExport exports the current resources of the cluster.
+func runExport(ctx context.Context, env *modeEnv) error {
+	return converter.Export(ctx, env.c, *outDirFlag, *outputFlag, env.opts)
+}
+
+// runBackup only backs up the legacy AddressPools.
+func runBackup(ctx context.Context, env *modeEnv) error {
+	return converter.Backup(ctx, env.c, *backupDirFlag, *outputFlag == converter.OutputFormatJSON, env.opts)
+}
+
+// runRestore restores the legacy AddressPools of a backup.
+func runRestore(ctx context.Context, env *modeEnv) error {
+	return converter.Restore(ctx, env.c, env.scheme, *restoreFlag, *dryRunFlag, env.opts)
+}
+
+// runVerify verifies a completed migration against its backup.
+func runVerify(ctx context.Context, env *modeEnv) error {
+	return converter.VerifyMigration(ctx, env.c, env.scheme, *verifyFlag, *outputFlag == converter.OutputFormatJSON,
+		env.opts)
+}
+
+// runWatchInput converts the input directory whenever it changes, until a SIGTERM or SIGINT.
+func runWatchInput(ctx context.Context, env *modeEnv) error {
+	env.stopSignals()
+	watchCtx, stopWatch := signal.NotifyContext(ctx, syscall.SIGTERM, os.Interrupt)
+	defer stopWatch()
+	return converter.WatchInput(watchCtx, env.c, env.scheme, *inDirFlag, *outDirFlag, *outputFlag, env.offlineOpts)
+}
+
+// runStream prints to stdout or to a directory batch by batch.
+func runStream(ctx context.Context, env *modeEnv) error {
+	return converter.StreamingMigration(ctx, env.c, env.scheme, *inDirFlag, *outDirFlag, *outputFlag,
+		*streamPageSizeFlag, env.opts)
+}
+
+// runConvert prints to stdout or to a directory.
+func runConvert(ctx context.Context, env *modeEnv) error {
+	return converter.OfflineMigration(ctx, env.c, env.scheme, *inDirFlag, *outDirFlag, *outputFlag, env.offlineOpts)
+}
+
+// runMigrate finalizes a two-phase migration or migrates the API objects directly. A SIGTERM or SIGINT stops the
+// migration after the AddressPool in flight, a second one exits immediately.
+func runMigrate(ctx context.Context, env *modeEnv) error {
+	if *phaseFlag == converter.PhaseFinalize {
+		return converter.FinalizeMigration(ctx, env.c, *backupDirFlag, *jsonFlag, *deleteAfterFlag, env.opts)
+	}
+	env.stopSignals()
+	stop, restoreSignals := signal.NotifyContext(ctx, syscall.SIGTERM, os.Interrupt)
+	go func() {
+		<-stop.Done()
+		exitOnSignal(env.tracer)
+		restoreSignals()
+		if !*quietFlag {
+			log.Print("received termination signal, stopping after the AddressPool in flight ...")
+		}
+	}()
+	onlineOpts := converter.OnlineMigrationOptions{
+		Options:        env.opts,
+		KeepLegacy:     *keepLegacyFlag,
+		DeleteInterval: *deleteIntervalFlag,
+		CreateInterval: *createIntervalFlag,
+		Stop:           stop,
+		CreateFirst:    *createFirstFlag,
+		AcceptTimeout:  *acceptTimeoutFlag,
+		WaitServices:   *waitServicesFlag,
+		ServiceTimeout: *waitServicesTimeoutFlag,
+		AccessReviewer: env.accessReviewer,
+		Report:         env.report,
+		StateFile:      *stateFileFlag,
+		EventRecorder:  env.eventRecorder,
+	}
+	err := converter.OnlineMigration(ctx, env.c, env.scheme, *backupDirFlag, *jsonFlag, onlineOpts)
+	if err == nil && *deleteAfterFlag > 0 && !*quietFlag {
+		log.Printf("legacy AddressPools were kept, run again with -phase %s -delete-after %s after %s "+
+			"to delete them", converter.PhaseFinalize, *deleteAfterFlag,
+			time.Now().Add(*deleteAfterFlag).Format(time.RFC3339))
+	}
+	return err
+}
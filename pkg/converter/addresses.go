@@ -0,0 +1,118 @@
+package converter
+
+import (
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+
+	"github.com/andreaskaris/metallb-converter/pkg/validate"
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+)
+
+// parseAddressRange parses a pool address, either a CIDR, a <first>-<last> range or a single address, and returns
+// its first and last address.
+func parseAddressRange(address string) (netip.Addr, netip.Addr, error) {
+	if addr, err := netip.ParseAddr(address); err == nil {
+		return addr, addr, nil
+	}
+	if first, last, found := strings.Cut(address, "-"); found {
+		from, err := netip.ParseAddr(strings.TrimSpace(first))
+		if err != nil {
+			return netip.Addr{}, netip.Addr{}, fmt.Errorf("invalid range %q, err: %w", address, err)
+		}
+		to, err := netip.ParseAddr(strings.TrimSpace(last))
+		if err != nil {
+			return netip.Addr{}, netip.Addr{}, fmt.Errorf("invalid range %q, err: %w", address, err)
+		}
+		return from, to, nil
+	}
+	prefix, err := netip.ParsePrefix(address)
+	if err != nil {
+		return netip.Addr{}, netip.Addr{}, fmt.Errorf("invalid CIDR %q, err: %w", address, err)
+	}
+	prefix = prefix.Masked()
+	first := prefix.Addr()
+	last := first
+	for i := prefix.Bits(); i < first.BitLen(); i++ {
+		last = setBit(last, i)
+	}
+	return first, last, nil
+}
+
+// setBit returns addr with bit i (counted from the most significant bit) set.
+func setBit(addr netip.Addr, i int) netip.Addr {
+	b := addr.AsSlice()
+	b[i/8] |= 0x80 >> (i % 8)
+	result, _ := netip.AddrFromSlice(b)
+	return result
+}
+
+// validateAddress returns an error if address is not a valid CIDR, range or single address, see validate.Address.
+func validateAddress(address string) error {
+	return validate.Address(address)
+}
+
+// validateAddressPools validates the addresses of all AddressPools and returns an error that lists every invalid
+// address.
+func validateAddressPools(addressPools []metallbv1beta1.AddressPool) error {
+	var problems []string
+	for _, ap := range addressPools {
+		for _, address := range ap.Spec.Addresses {
+			if err := validateAddress(address); err != nil {
+				problems = append(problems, fmt.Sprintf("AddressPool %s/%s: %v", ap.Namespace, ap.Name, err))
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%w:\n%s", ErrInvalidAddresses, strings.Join(problems, "\n"))
+	}
+	return nil
+}
+
+// canonicalAddress returns the canonical textual form of a pool address. Addresses that cannot be parsed are
+// returned unchanged.
+func canonicalAddress(address string) string {
+	if first, last, found := strings.Cut(address, "-"); found {
+		from, err1 := netip.ParseAddr(strings.TrimSpace(first))
+		to, err2 := netip.ParseAddr(strings.TrimSpace(last))
+		if err1 != nil || err2 != nil {
+			return address
+		}
+		return fmt.Sprintf("%s-%s", from, to)
+	}
+	prefix, err := netip.ParsePrefix(address)
+	if err != nil {
+		return address
+	}
+	return prefix.String()
+}
+
+// normalizeAddressList returns the canonical, sorted and deduplicated form of addresses. Addresses that cannot be
+// parsed are sorted after all valid addresses.
+func normalizeAddressList(addresses []string) []string {
+	seen := map[string]bool{}
+	var normalized []string
+	for _, address := range addresses {
+		address = canonicalAddress(address)
+		if seen[address] {
+			continue
+		}
+		seen[address] = true
+		normalized = append(normalized, address)
+	}
+	sort.SliceStable(normalized, func(i, j int) bool {
+		firstI, _, errI := parseAddressRange(normalized[i])
+		firstJ, _, errJ := parseAddressRange(normalized[j])
+		switch {
+		case errI != nil && errJ != nil:
+			return normalized[i] < normalized[j]
+		case errI != nil || errJ != nil:
+			return errJ != nil
+		case firstI != firstJ:
+			return firstI.Less(firstJ)
+		}
+		return normalized[i] < normalized[j]
+	})
+	return normalized
+}
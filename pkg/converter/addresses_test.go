@@ -0,0 +1,119 @@
+package converter
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+)
+
+func TestNormalizeAddressList(t *testing.T) {
+	tcs := map[string]struct {
+		addresses []string
+		expected  []string
+	}{
+		"sorted": {
+			addresses: []string{"192.168.10.0/24", "192.168.2.0/24", "10.0.0.1-10.0.0.5"},
+			expected:  []string{"10.0.0.1-10.0.0.5", "192.168.2.0/24", "192.168.10.0/24"},
+		},
+		"duplicates": {
+			addresses: []string{"192.168.2.0/24", "192.168.2.0/24"},
+			expected:  []string{"192.168.2.0/24"},
+		},
+		"ipv6 canonicalization": {
+			addresses: []string{"2001:DB8:0:0::1 - 2001:db8::00ff", "2001:0db8:0000::/64", "2001:db8::/64"},
+			expected:  []string{"2001:db8::/64", "2001:db8::1-2001:db8::ff"},
+		},
+		"ipv4 before ipv6": {
+			addresses: []string{"fd00::/64", "10.0.0.0/8"},
+			expected:  []string{"10.0.0.0/8", "fd00::/64"},
+		},
+		"invalid addresses last": {
+			addresses: []string{"not-an-address", "10.0.0.0/8"},
+			expected:  []string{"10.0.0.0/8", "not-an-address"},
+		},
+	}
+	for desc, tc := range tcs {
+		got := normalizeAddressList(tc.addresses)
+		if !reflect.DeepEqual(got, tc.expected) {
+			t.Fatalf("TestNormalizeAddressList(%s): expected %v but got %v", desc, tc.expected, got)
+		}
+	}
+}
+
+func TestParseAddressRange(t *testing.T) {
+	tcs := map[string]struct {
+		address       string
+		expectedFirst string
+		expectedLast  string
+		expectError   bool
+	}{
+		"ipv4 cidr":  {address: "192.168.0.0/30", expectedFirst: "192.168.0.0", expectedLast: "192.168.0.3"},
+		"host bits":  {address: "192.168.0.5/30", expectedFirst: "192.168.0.4", expectedLast: "192.168.0.7"},
+		"ipv6 cidr":  {address: "2001:db8::/126", expectedFirst: "2001:db8::", expectedLast: "2001:db8::3"},
+		"ipv4 range": {address: "10.0.0.1 - 10.0.0.5", expectedFirst: "10.0.0.1", expectedLast: "10.0.0.5"},
+		"invalid":    {address: "10.0.0.1-foo", expectError: true},
+	}
+	for desc, tc := range tcs {
+		first, last, err := parseAddressRange(tc.address)
+		if tc.expectError {
+			if err == nil {
+				t.Fatalf("TestParseAddressRange(%s): expected an error but got none", desc)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("TestParseAddressRange(%s): unexpected error, err: %q", desc, err)
+		}
+		if first.String() != tc.expectedFirst || last.String() != tc.expectedLast {
+			t.Fatalf("TestParseAddressRange(%s): expected %s-%s but got %s-%s", desc, tc.expectedFirst,
+				tc.expectedLast, first, last)
+		}
+	}
+}
+
+func TestValidateAddress(t *testing.T) {
+	tcs := map[string]struct {
+		address     string
+		expectError bool
+	}{
+		"ipv4 cidr":         {address: "192.168.0.0/24"},
+		"ipv6 cidr":         {address: "2001:db8::/64"},
+		"ipv4 range":        {address: "192.168.0.1-192.168.0.10"},
+		"ipv6 range":        {address: "2001:db8::1 - 2001:db8::ff"},
+		"single address":    {address: "192.168.100.100"},
+		"malformed ipv6":    {address: "2001:db8:::1/64", expectError: true},
+		"malformed range":   {address: "2001:db8::1-2001:db8::zz", expectError: true},
+		"zone identifier":   {address: "fe80::1%eth0-fe80::ff%eth0", expectError: true},
+		"zone in cidr":      {address: "fe80::1%eth0/64", expectError: true},
+		"reversed range":    {address: "2001:db8::ff-2001:db8::1", expectError: true},
+		"mixed families":    {address: "192.168.0.1-2001:db8::1", expectError: true},
+		"prefix too long":   {address: "192.168.0.0/33", expectError: true},
+		"empty":             {address: "", expectError: true},
+		"garbage":           {address: "not-an-address", expectError: true},
+		"reversed ipv4":     {address: "10.0.0.5-10.0.0.1", expectError: true},
+		"single ipv6 range": {address: "2001:db8::1-2001:db8::1"},
+	}
+	for desc, tc := range tcs {
+		err := validateAddress(tc.address)
+		if tc.expectError && err == nil {
+			t.Fatalf("TestValidateAddress(%s): expected an error for %q but got none", desc, tc.address)
+		}
+		if !tc.expectError && err != nil {
+			t.Fatalf("TestValidateAddress(%s): unexpected error, err: %q", desc, err)
+		}
+	}
+}
+
+func TestConvertRejectsInvalidAddresses(t *testing.T) {
+	ap := *validAddressPools0[0].DeepCopy()
+	ap.Spec.Addresses = []string{"192.168.0.0/24", "2001:db8::ff-2001:db8::1"}
+	legacyObjects := &LegacyObjects{AddressPoolList: &metallbv1beta1.AddressPoolList{
+		Items: []metallbv1beta1.AddressPool{ap},
+	}}
+	_, err := legacyObjects.Convert(Options{})
+	if err == nil || !strings.Contains(err.Error(), "AddressPool metallb-system/ap-l2: invalid range") {
+		t.Fatalf("TestConvertRejectsInvalidAddresses: expected an invalid range error but got %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+package converter
+
+import (
+	"reflect"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	metallbv1beta2 "go.universe.tf/metallb/api/v1beta2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// legacyKinds are the kinds that are read from the source API group. All other MetalLB kinds belong to the target API
+// group.
+var legacyKinds = map[string]bool{"AddressPool": true, "AddressPoolList": true}
+
+// AddToScheme registers the MetalLB types with scheme. AddressPools are registered under the source API group and all
+// other types under the target API group of opts, so that every type maps to exactly one group.
+func AddToScheme(scheme *runtime.Scheme, opts Options) error {
+	metallbScheme := runtime.NewScheme()
+	if err := metallbv1beta1.AddToScheme(metallbScheme); err != nil {
+		return err
+	}
+	if err := metallbv1beta2.AddToScheme(metallbScheme); err != nil {
+		return err
+	}
+	metav1PkgPath := reflect.TypeOf(metav1.Status{}).PkgPath()
+	groupVersions := map[schema.GroupVersion]bool{}
+	for gvk, t := range metallbScheme.AllKnownTypes() {
+		// Skip the option and event types that metav1.AddToGroupVersion registers with every group version.
+		if gvk.Group != metallbAPIGroup || t.PkgPath() == metav1PkgPath {
+			continue
+		}
+		gvk.Group = opts.apiGroupOf(gvk.Kind)
+		scheme.AddKnownTypeWithName(gvk, reflect.New(t).Interface().(runtime.Object))
+		groupVersions[gvk.GroupVersion()] = true
+	}
+	for groupVersion := range groupVersions {
+		metav1.AddToGroupVersion(scheme, groupVersion)
+	}
+	return nil
+}
+
+// sourceAPIGroup returns the API group that legacy AddressPools are read from.
+func (o Options) sourceAPIGroup() string {
+	if o.SourceAPIGroup == "" {
+		return metallbAPIGroup
+	}
+	return o.SourceAPIGroup
+}
+
+// targetAPIGroup returns the API group of the converted resources.
+func (o Options) targetAPIGroup() string {
+	if o.TargetAPIGroup == "" {
+		return metallbAPIGroup
+	}
+	return o.TargetAPIGroup
+}
+
+// apiGroupOf returns the API group of the MetalLB kind.
+func (o Options) apiGroupOf(kind string) string {
+	if legacyKinds[kind] {
+		return o.sourceAPIGroup()
+	}
+	return o.targetAPIGroup()
+}
+
+// isMetalLBAPIGroup returns true if group is metallb.io or one of the API groups of the options.
+func (o Options) isMetalLBAPIGroup(group string) bool {
+	return group == metallbAPIGroup || group == o.sourceAPIGroup() || group == o.targetAPIGroup()
+}
+
+// withAPIGroup returns obj, or a copy of obj in the API group of the options if obj is a MetalLB object whose type
+// meta still carries metallb.io.
+func (o Options) withAPIGroup(obj runtime.Object) runtime.Object {
+	gvk := obj.GetObjectKind().GroupVersionKind()
+	if gvk.Group != metallbAPIGroup || o.apiGroupOf(gvk.Kind) == metallbAPIGroup {
+		return obj
+	}
+	obj = obj.DeepCopyObject()
+	gvk.Group = o.apiGroupOf(gvk.Kind)
+	obj.GetObjectKind().SetGroupVersionKind(gvk)
+	return obj
+}
@@ -0,0 +1,90 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestAPIGroups(t *testing.T) {
+	tcs := map[string]struct {
+		source              string
+		target              string
+		input               string
+		expectedOutput      []string
+		expectedErrorString string
+	}{
+		"default groups": {
+			input:          renderedKustomization,
+			expectedOutput: []string{"apiVersion: metallb.io/v1beta1\nkind: IPAddressPool"},
+		},
+		"forked groups": {
+			source: "metallb.vendor.io",
+			target: "lb.vendor.io",
+			input:  strings.Replace(renderedKustomization, "metallb.io", "metallb.vendor.io", 1),
+			expectedOutput: []string{
+				"apiVersion: lb.vendor.io/v1beta1\nkind: IPAddressPool",
+				"apiVersion: lb.vendor.io/v1beta1\nkind: L2Advertisement",
+			},
+		},
+		"input of another group": {
+			source:              "metallb.vendor.io",
+			input:               renderedKustomization,
+			expectedErrorString: "no kind \"AddressPool\" is registered for version \"metallb.io/v1beta1\"",
+		},
+		"invalid group": {
+			source:              "Metallb_IO",
+			expectedErrorString: "invalid API group \"Metallb_IO\"",
+		},
+	}
+	for desc, tc := range tcs {
+		opts := Options{SourceAPIGroup: tc.source, TargetAPIGroup: tc.target}
+		err := opts.Validate()
+		if err == nil {
+			err = offlineMigrationOfGroups(t, tc.input, opts)
+		}
+		if tc.expectedErrorString != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.expectedErrorString) {
+				t.Fatalf("TestAPIGroups(%s): expected error %q but got %v", desc, tc.expectedErrorString, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("TestAPIGroups(%s): unexpected error, err: %q", desc, err)
+		}
+		for _, expected := range tc.expectedOutput {
+			if !strings.Contains(fmt.Sprint(stdout), expected) {
+				t.Fatalf("TestAPIGroups(%s): expected %q in output but got:\n%s", desc, expected, stdout)
+			}
+		}
+	}
+}
+
+// offlineMigrationOfGroups converts input with a scheme of the API groups of opts and prints the result as YAML to
+// stdout.
+func offlineMigrationOfGroups(t *testing.T, input string, opts Options) error {
+	scheme := runtime.NewScheme()
+	if err := AddToScheme(scheme, opts); err != nil {
+		return err
+	}
+	gvks, _, err := scheme.ObjectKinds(&metallbv1beta1.IPAddressPool{})
+	if err != nil || len(gvks) != 1 || gvks[0].Group != opts.targetAPIGroup() {
+		return fmt.Errorf("expected IPAddressPools in group %s but got %v, err: %v", opts.targetAPIGroup(), gvks,
+			err)
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "pools.yaml"), []byte(input), 0644); err != nil {
+		return err
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+	stdout = bytes.NewBuffer([]byte{})
+	return OfflineMigration(context.TODO(), c, scheme, dir, "", OutputFormatYAML, OfflineMigrationOptions{Options: opts})
+}
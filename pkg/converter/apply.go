@@ -0,0 +1,21 @@
+package converter
+
+import (
+	"context"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// FieldManager is the field manager of the fields that CurrentObjects.Create applies with server-side apply.
+const FieldManager = "metallb-converter"
+
+// createObject creates obj, or applies it with server-side apply if the ServerSideApply of opts is enabled. Fields of
+// obj that other field managers own are taken over.
+func createObject(ctx context.Context, cl client.Client, obj client.Object, opts Options) error {
+	if !opts.ServerSideApply {
+		return cl.Create(ctx, obj)
+	}
+	// The object is sent as is, so its type meta must carry the API group that it is served under.
+	return cl.Patch(ctx, opts.withAPIGroup(obj).(client.Object), client.Apply, client.FieldOwner(FieldManager),
+		client.ForceOwnership)
+}
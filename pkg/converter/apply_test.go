@@ -0,0 +1,116 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// applyClient emulates server-side apply on top of the fake client, which only applies to existing objects, and
+// records the field managers of the apply requests.
+type applyClient struct {
+	client.Client
+	fieldManagers []string
+}
+
+func (ac *applyClient) Patch(ctx context.Context, obj client.Object, patch client.Patch,
+	opts ...client.PatchOption) error {
+	if patch.Type() != types.ApplyPatchType {
+		return ac.Client.Patch(ctx, obj, patch, opts...)
+	}
+	patchOptions := &client.PatchOptions{}
+	patchOptions.ApplyOptions(opts)
+	if patchOptions.Force == nil || !*patchOptions.Force {
+		ac.fieldManagers = append(ac.fieldManagers, patchOptions.FieldManager+" (not forced)")
+	} else {
+		ac.fieldManagers = append(ac.fieldManagers, patchOptions.FieldManager)
+	}
+	err := ac.Client.Get(ctx, client.ObjectKeyFromObject(obj), obj.DeepCopyObject().(client.Object))
+	if apierrors.IsNotFound(err) {
+		return ac.Client.Create(ctx, obj)
+	}
+	if err != nil {
+		return err
+	}
+	return ac.Client.Patch(ctx, obj, patch, opts...)
+}
+
+func TestServerSideApplyOnlineMigration(t *testing.T) {
+	tcs := map[string]struct {
+		serverSideApply       bool
+		expectNameCollision   bool
+		expectedFieldManagers int
+	}{
+		"create refuses objects of a previous run": {
+			expectNameCollision: true,
+		},
+		"server-side apply takes over objects of a previous run": {
+			serverSideApply: true,
+			// The IPAddressPools and advertisements of the three AddressPools, ap-bgp has two BGPAdvertisements.
+			expectedFieldManagers: 7,
+		},
+	}
+	var scheme = runtime.NewScheme()
+	if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestServerSideApplyOnlineMigration: error adding to scheme, err: %q", err)
+	}
+	SetQuiet(true)
+	defer SetQuiet(false)
+	stdout = bytes.NewBuffer([]byte{})
+	for desc, tc := range tcs {
+		c := fake.NewClientBuilder().WithScheme(scheme).Build()
+		for _, ap := range validAddressPools0 {
+			if err := c.Create(context.TODO(), ap.DeepCopy()); err != nil {
+				t.Fatalf("TestServerSideApplyOnlineMigration(%s): error building fake client, err: %q", desc, err)
+			}
+		}
+		// A previous run created the IPAddressPool of ap-l2 but failed before it deleted the AddressPool.
+		partial := &metallbv1beta1.IPAddressPool{
+			ObjectMeta: metav1.ObjectMeta{Name: "ap-l2", Namespace: "metallb-system"},
+			Spec:       metallbv1beta1.IPAddressPoolSpec{Addresses: []string{"10.0.0.0/24"}},
+		}
+		if err := c.Create(context.TODO(), partial); err != nil {
+			t.Fatalf("TestServerSideApplyOnlineMigration(%s): error building fake client, err: %q", desc, err)
+		}
+		ac := &applyClient{Client: c}
+		err := OnlineMigration(context.TODO(), ac, scheme, t.TempDir(), false, OnlineMigrationOptions{
+			Options: Options{ServerSideApply: tc.serverSideApply},
+		})
+		if tc.expectNameCollision {
+			if !errors.Is(err, ErrNameCollision) {
+				t.Fatalf("TestServerSideApplyOnlineMigration(%s): expected a name collision but got %v", desc, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("TestServerSideApplyOnlineMigration(%s): unexpected error, err: %q", desc, err)
+		}
+		if len(ac.fieldManagers) != tc.expectedFieldManagers {
+			t.Fatalf("TestServerSideApplyOnlineMigration(%s): expected %d apply requests but got %v", desc,
+				tc.expectedFieldManagers, ac.fieldManagers)
+		}
+		for _, fieldManager := range ac.fieldManagers {
+			if fieldManager != FieldManager {
+				t.Fatalf("TestServerSideApplyOnlineMigration(%s): expected forced field manager %q but got %q", desc,
+					FieldManager, fieldManager)
+			}
+		}
+		iap := &metallbv1beta1.IPAddressPool{}
+		if err := c.Get(context.TODO(), client.ObjectKeyFromObject(partial), iap); err != nil {
+			t.Fatalf("TestServerSideApplyOnlineMigration(%s): cannot get IPAddressPool, err: %q", desc, err)
+		}
+		if len(iap.Spec.Addresses) != 1 || iap.Spec.Addresses[0] != "192.168.100.100" {
+			t.Fatalf("TestServerSideApplyOnlineMigration(%s): expected the converted addresses but got %v", desc,
+				iap.Spec.Addresses)
+		}
+	}
+}
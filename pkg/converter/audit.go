@@ -0,0 +1,225 @@
+package converter
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+const (
+	// AuditConfigMapKey is the key inside the audit ConfigMap that holds the audit records in JSON lines format.
+	AuditConfigMapKey = "audit.jsonl"
+	// AuditConfigMapMaxSize is the maximum size in bytes of the records in the audit ConfigMap. The oldest records are
+	// dropped from the ConfigMap to stay below it and below the size limit of ConfigMaps, the audit file keeps all of
+	// them.
+	AuditConfigMapMaxSize = 512 << 10
+
+	auditOperationCreate = "create"
+	auditOperationDelete = "delete"
+	auditOperationUpdate = "update"
+	auditOperationPatch  = "patch"
+)
+
+// AuditRecord describes a single API mutation.
+type AuditRecord struct {
+	Timestamp  time.Time `json:"timestamp"`
+	Operator   string    `json:"operator"`
+	Operation  string    `json:"operation"`
+	Kind       string    `json:"kind"`
+	Namespace  string    `json:"namespace,omitempty"`
+	Name       string    `json:"name"`
+	ObjectHash string    `json:"objectHash"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// AuditLog writes an append-only record of every API mutation to a file and, optionally, to a ConfigMap.
+type AuditLog struct {
+	mu        sync.Mutex
+	operator  string
+	file      *os.File
+	configMap *types.NamespacedName
+	// pending holds the records of this run that Close appends to the ConfigMap, at most AuditConfigMapMaxSize bytes.
+	pending string
+}
+
+// NewAuditLog opens (or creates) the append-only audit file at filePath. operator identifies who runs the converter.
+func NewAuditLog(filePath string, operator string) (*AuditLog, error) {
+	f, err := os.OpenFile(filePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open audit log file, err: %w", err)
+	}
+	return &AuditLog{operator: operator, file: f}, nil
+}
+
+// SetConfigMap makes Close also append all records of this run to the ConfigMap namespace/name.
+func (a *AuditLog) SetConfigMap(namespace, name string) {
+	a.configMap = &types.NamespacedName{Namespace: namespace, Name: name}
+}
+
+// WrapClient returns a client that records every Create, Delete, Update and Patch call in the audit log.
+func (a *AuditLog) WrapClient(c client.Client) client.Client {
+	return &auditingClient{Client: c, audit: a}
+}
+
+// record appends an AuditRecord for obj to the audit file. The object hash is computed from the object as it was
+// sent to the API.
+func (a *AuditLog) record(c client.Client, operation string, obj client.Object, hash string, opErr error) error {
+	kind := obj.GetObjectKind().GroupVersionKind().Kind
+	if gvk, err := apiutil.GVKForObject(obj, c.Scheme()); err == nil {
+		kind = gvk.Kind
+	}
+	r := AuditRecord{
+		Timestamp:  time.Now().UTC(),
+		Operator:   a.operator,
+		Operation:  operation,
+		Kind:       kind,
+		Namespace:  obj.GetNamespace(),
+		Name:       obj.GetName(),
+		ObjectHash: hash,
+	}
+	if opErr != nil {
+		r.Error = opErr.Error()
+	}
+	line, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("cannot marshal audit record, err: %w", err)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.configMap != nil {
+		a.pending = trimAuditRecords(a.pending+string(line)+"\n", AuditConfigMapMaxSize)
+	}
+	if _, err := a.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("cannot write audit record, err: %w", err)
+	}
+	return a.file.Sync()
+}
+
+// Close closes the audit file. If a ConfigMap was configured, the records of this run are appended to it using the
+// provided client (which should not be a wrapped client, so that the ConfigMap update itself is not audited). Updates
+// that conflict with concurrent runs are retried, and the oldest records are dropped from the ConfigMap once it
+// exceeds AuditConfigMapMaxSize.
+func (a *AuditLog) Close(ctx context.Context, c client.Client) error {
+	defer a.file.Close()
+	a.mu.Lock()
+	pending := a.pending
+	a.mu.Unlock()
+	if a.configMap == nil || pending == "" {
+		return nil
+	}
+	return retryOnConflict(ctx, func() error {
+		return a.appendToConfigMap(ctx, c, pending)
+	})
+}
+
+// appendToConfigMap appends records to the audit ConfigMap, creating it if needed.
+func (a *AuditLog) appendToConfigMap(ctx context.Context, c client.Client, records string) error {
+	cm := &corev1.ConfigMap{}
+	err := c.Get(ctx, *a.configMap, cm)
+	if apierrors.IsNotFound(err) {
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: a.configMap.Name, Namespace: a.configMap.Namespace},
+			Data:       map[string]string{AuditConfigMapKey: records},
+		}
+		err = c.Create(ctx, cm)
+		if !apierrors.IsAlreadyExists(err) {
+			if err != nil {
+				return fmt.Errorf("cannot create audit ConfigMap %s, err: %w", a.configMap, err)
+			}
+			return nil
+		}
+		// A concurrent run created the ConfigMap in the meantime.
+		cm = &corev1.ConfigMap{}
+		err = c.Get(ctx, *a.configMap, cm)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot get audit ConfigMap %s, err: %w", a.configMap, err)
+	}
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	cm.Data[AuditConfigMapKey] = trimAuditRecords(cm.Data[AuditConfigMapKey]+records, AuditConfigMapMaxSize)
+	if err := c.Update(ctx, cm); err != nil {
+		return fmt.Errorf("cannot update audit ConfigMap %s, err: %w", a.configMap, err)
+	}
+	return nil
+}
+
+// trimAuditRecords drops the oldest of the JSON lines records until they fit into maxSize bytes.
+func trimAuditRecords(records string, maxSize int) string {
+	for len(records) > maxSize {
+		i := strings.IndexByte(records, '\n')
+		if i < 0 {
+			return ""
+		}
+		records = records[i+1:]
+	}
+	return records
+}
+
+// objectHash returns the hex encoded sha256 sum of the JSON representation of obj.
+func objectHash(obj client.Object) string {
+	b, err := json.Marshal(obj)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// auditingClient records all mutating calls in an AuditLog.
+type auditingClient struct {
+	client.Client
+	audit *AuditLog
+}
+
+func (a *auditingClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	hash := objectHash(obj)
+	err := a.Client.Create(ctx, obj, opts...)
+	if auditErr := a.audit.record(a.Client, auditOperationCreate, obj, hash, err); auditErr != nil && err == nil {
+		return auditErr
+	}
+	return err
+}
+
+func (a *auditingClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	hash := objectHash(obj)
+	err := a.Client.Delete(ctx, obj, opts...)
+	if auditErr := a.audit.record(a.Client, auditOperationDelete, obj, hash, err); auditErr != nil && err == nil {
+		return auditErr
+	}
+	return err
+}
+
+func (a *auditingClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	hash := objectHash(obj)
+	err := a.Client.Update(ctx, obj, opts...)
+	if auditErr := a.audit.record(a.Client, auditOperationUpdate, obj, hash, err); auditErr != nil && err == nil {
+		return auditErr
+	}
+	return err
+}
+
+func (a *auditingClient) Patch(ctx context.Context, obj client.Object, patch client.Patch,
+	opts ...client.PatchOption) error {
+	hash := objectHash(obj)
+	err := a.Client.Patch(ctx, obj, patch, opts...)
+	if auditErr := a.audit.record(a.Client, auditOperationPatch, obj, hash, err); auditErr != nil && err == nil {
+		return auditErr
+	}
+	return err
+}
@@ -0,0 +1,195 @@
+package converter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestAuditLog(t *testing.T) {
+	var scheme = runtime.NewScheme()
+	if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestAuditLog: error adding to scheme, err: %q", err)
+	}
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestAuditLog: error adding to scheme, err: %q", err)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+	auditFile := path.Join(t.TempDir(), "audit.jsonl")
+
+	// Run the audited migration twice to make sure that both the file and the ConfigMap are appended to.
+	for run := 0; run < 2; run++ {
+		auditLog, err := NewAuditLog(auditFile, "tester")
+		if err != nil {
+			t.Fatalf("TestAuditLog: cannot create audit log, err: %q", err)
+		}
+		auditLog.SetConfigMap("metallb-system", "converter-audit")
+		ac := auditLog.WrapClient(c)
+		for _, ap := range validAddressPools0 {
+			if err := ac.Create(context.TODO(), ap.DeepCopy()); err != nil {
+				t.Fatalf("TestAuditLog: cannot create AddressPool, err: %q", err)
+			}
+		}
+		if err := OnlineMigration(context.TODO(), ac, scheme, t.TempDir(), false, OnlineMigrationOptions{}); err != nil {
+			t.Fatalf("TestAuditLog: online migration failed, err: %q", err)
+		}
+		if err := auditLog.Close(context.TODO(), c); err != nil {
+			t.Fatalf("TestAuditLog: cannot close audit log, err: %q", err)
+		}
+		// Clean up the new objects so that the next run starts from scratch.
+		legacyObjects := &LegacyObjects{AddressPoolList: &metallbv1beta1.AddressPoolList{Items: validAddressPools0}}
+		currentObjects, err := legacyObjects.Convert(Options{})
+		if err != nil {
+			t.Fatalf("TestAuditLog: conversion failed, err: %q", err)
+		}
+		if err := currentObjects.Delete(context.TODO(), c); err != nil {
+			t.Fatalf("TestAuditLog: cleanup failed, err: %q", err)
+		}
+	}
+
+	// 3 AddressPool creations, 3 deletions, 3 IPAddressPool, 3 BGPAdvertisement and 1 L2Advertisement creations.
+	expectedRecordsPerRun := 13
+	content, err := os.ReadFile(auditFile)
+	if err != nil {
+		t.Fatalf("TestAuditLog: cannot read audit file, err: %q", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if len(lines) != 2*expectedRecordsPerRun {
+		t.Fatalf("TestAuditLog: expected %d audit records but got %d:\n%s", 2*expectedRecordsPerRun, len(lines),
+			content)
+	}
+	kinds := map[string]int{}
+	for _, line := range lines {
+		var r AuditRecord
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatalf("TestAuditLog: cannot unmarshal audit record %q, err: %q", line, err)
+		}
+		if r.Operator != "tester" || r.ObjectHash == "" || r.Name == "" || r.Timestamp.IsZero() || r.Error != "" {
+			t.Fatalf("TestAuditLog: incomplete audit record %+v", r)
+		}
+		kinds[r.Operation+"/"+r.Kind]++
+	}
+	if kinds["delete/AddressPool"] != 6 || kinds["create/IPAddressPool"] != 6 ||
+		kinds["create/L2Advertisement"] != 2 {
+		t.Fatalf("TestAuditLog: unexpected audit record distribution %v", kinds)
+	}
+
+	cm := &corev1.ConfigMap{}
+	err = c.Get(context.TODO(), types.NamespacedName{Namespace: "metallb-system", Name: "converter-audit"}, cm)
+	if err != nil {
+		t.Fatalf("TestAuditLog: cannot get audit ConfigMap, err: %q", err)
+	}
+	if cm.Data[AuditConfigMapKey] != string(content) {
+		t.Fatalf("TestAuditLog: ConfigMap content does not match audit file.\nGot\n'%s'\nExpected\n'%s'",
+			cm.Data[AuditConfigMapKey], content)
+	}
+}
+
+func TestAuditLogConflicts(t *testing.T) {
+	var scheme = runtime.NewScheme()
+	if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestAuditLogConflicts: error adding to scheme, err: %q", err)
+	}
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestAuditLogConflicts: error adding to scheme, err: %q", err)
+	}
+	resource := schema.GroupResource{Resource: "configmaps"}
+	conflict := apierrors.NewConflict(resource, "converter-audit", fmt.Errorf("the object has been modified"))
+	tcs := map[string]struct {
+		failures      int
+		expectedError bool
+	}{
+		"no conflict": {
+			failures: 0,
+		},
+		"conflicts are retried": {
+			failures: conflictAttempts - 1,
+		},
+		"too many conflicts": {
+			failures:      conflictAttempts,
+			expectedError: true,
+		},
+	}
+	for desc, tc := range tcs {
+		previous := `{"operation":"create"}` + "\n"
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "metallb-system", Name: "converter-audit"},
+			Data:       map[string]string{AuditConfigMapKey: previous},
+		}).Build()
+		auditLog, err := NewAuditLog(path.Join(t.TempDir(), "audit.jsonl"), "tester")
+		if err != nil {
+			t.Fatalf("TestAuditLogConflicts(%s): cannot create audit log, err: %q", desc, err)
+		}
+		auditLog.SetConfigMap("metallb-system", "converter-audit")
+		ac := auditLog.WrapClient(c)
+		if err := ac.Create(context.TODO(), validAddressPools0[0].DeepCopy()); err != nil {
+			t.Fatalf("TestAuditLogConflicts(%s): cannot create AddressPool, err: %q", desc, err)
+		}
+		fc := &flakyClient{Client: c, err: conflict, failures: tc.failures}
+		err = auditLog.Close(context.TODO(), fc)
+		if tc.expectedError {
+			if !apierrors.IsConflict(err) {
+				t.Fatalf("TestAuditLogConflicts(%s): expected a conflict but got %v", desc, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("TestAuditLogConflicts(%s): cannot close audit log, err: %q", desc, err)
+		}
+		if fc.requests != tc.failures+1 {
+			t.Fatalf("TestAuditLogConflicts(%s): expected %d updates but got %d", desc, tc.failures+1, fc.requests)
+		}
+		cm := &corev1.ConfigMap{}
+		err = c.Get(context.TODO(), types.NamespacedName{Namespace: "metallb-system", Name: "converter-audit"}, cm)
+		if err != nil {
+			t.Fatalf("TestAuditLogConflicts(%s): cannot get audit ConfigMap, err: %q", desc, err)
+		}
+		lines := strings.Split(strings.TrimSpace(cm.Data[AuditConfigMapKey]), "\n")
+		if len(lines) != 2 || lines[0]+"\n" != previous {
+			t.Fatalf("TestAuditLogConflicts(%s): expected the previous and one new record but got\n%s", desc,
+				cm.Data[AuditConfigMapKey])
+		}
+	}
+}
+
+func TestTrimAuditRecords(t *testing.T) {
+	tcs := map[string]struct {
+		records  string
+		maxSize  int
+		expected string
+	}{
+		"below the limit": {
+			records:  "a\nb\n",
+			maxSize:  4,
+			expected: "a\nb\n",
+		},
+		"oldest records dropped": {
+			records:  "a\nbb\nc\n",
+			maxSize:  5,
+			expected: "bb\nc\n",
+		},
+		"record larger than the limit": {
+			records:  "aaaaaa\n",
+			maxSize:  5,
+			expected: "",
+		},
+	}
+	for desc, tc := range tcs {
+		if got := trimAuditRecords(tc.records, tc.maxSize); got != tc.expected {
+			t.Fatalf("TestTrimAuditRecords(%s): expected %q but got %q", desc, tc.expected, got)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Backup writes the legacy AddressPools of the cluster to dir like the backup step of an online migration, without
+// migrating anything. dir is checked like the backup directory of an online migration, and the backup can be restored
+// with Restore. The AddressPools are read and printed according to opts. The API requests are bound to ctx.
+func Backup(ctx context.Context, c client.Client, dir string, toJSON bool, opts Options) error {
+	if dir == "" {
+		return fmt.Errorf("backup requires a backup directory")
+	}
+	legacyObjects, err := ReadLegacyObjectsFromAPI(ctx, c, 0, opts)
+	if err != nil {
+		return fmt.Errorf("error during retrieval step, err: %w", err)
+	}
+	count := len(legacyObjects.AddressPoolList.Items)
+	if count == 0 {
+		if !quiet {
+			log.Printf("there are no legacy AddressPools to back up")
+		}
+		return nil
+	}
+	backup := &bytes.Buffer{}
+	err = legacyObjects.encode(backup, toJSON, opts)
+	if err == nil {
+		err = checkBackupDir(dir, int64(backup.Len()))
+	}
+	if err == nil {
+		err = legacyObjects.Print(dir, toJSON, opts)
+	}
+	if err != nil {
+		return fmt.Errorf("error during backup step, err: %w", err)
+	}
+	if !quiet {
+		log.Printf("wrote a backup of %d AddressPools to %s", count, dir)
+	}
+	return nil
+}
+
+// writeBackup writes the AddressPools to the file name of dir like Print, e.g. to AddressPool.yaml for the name
+// AddressPool, or to stdout if dir is empty, within the limits of ctx, e.g. the timeout of the backup operation policy.
+// The file is written in the background to a temporary file that then replaces it: writeBackup returns when ctx is
+// done even if the write hangs, and a write that it abandoned or that a retry overtakes never leaves a truncated
+// backup behind.
+func (l LegacyObjects) writeBackup(ctx context.Context, dir, name string, toJSON bool, opts Options) error {
+	if dir == "" || len(l.AddressPoolList.Items) == 0 {
+		return l.Print(dir, toJSON, opts)
+	}
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("cannot write the backup to %s, err: %w", dir, err)
+	}
+	backup := &bytes.Buffer{}
+	if err := l.encode(backup, toJSON, opts); err != nil {
+		return err
+	}
+	fileExtension := "yaml"
+	if toJSON {
+		fileExtension = "json"
+	}
+	target := filepath.Join(dir, fmt.Sprintf("%s.%s", name, fileExtension))
+	done := make(chan error, 1)
+	go func() {
+		tmp, err := os.CreateTemp(dir, "."+name+".*")
+		if err == nil {
+			_, err = tmp.Write(backup.Bytes())
+			if closeErr := tmp.Close(); err == nil {
+				err = closeErr
+			}
+			if err == nil {
+				err = os.Rename(tmp.Name(), target)
+			}
+			if err != nil {
+				os.Remove(tmp.Name())
+			}
+		}
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("cannot create destination file, err: %w", err)
+		}
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("cannot write the backup to %s, err: %w", dir, ctx.Err())
+	}
+}
+
+// finalizeBackupName returns the name of the file in dir that the backup of a finalization at now is written to with
+// writeBackup. It is AddressPool like for the backup of an online migration, unless dir already holds a backup, e.g.
+// the one of the mark phase, which must stay intact to restore the AddressPools as they were before the migration. The
+// backup of the finalization is then written next to it, to a file named after now that does not exist yet.
+func finalizeBackupName(dir string, now time.Time) string {
+	exists := func(name string) bool {
+		for _, fileExtension := range []string{"yaml", "json"} {
+			if _, err := os.Lstat(filepath.Join(dir, fmt.Sprintf("%s.%s", name, fileExtension))); err == nil {
+				return true
+			}
+		}
+		return false
+	}
+	if dir == "" || !exists("AddressPool") {
+		return "AddressPool"
+	}
+	name := "finalize-" + now.UTC().Format("20060102T150405Z")
+	for i := 2; exists(name); i++ {
+		name = fmt.Sprintf("finalize-%s-%d", now.UTC().Format("20060102T150405Z"), i)
+	}
+	return name
+}
+
+// backupCovers returns true if dir holds a backup that contains every AddressPool of legacyObjects, e.g. the backup
+// of an interrupted migration that is resumed. The backup is read according to opts.
+func backupCovers(scheme *runtime.Scheme, dir string, legacyObjects *LegacyObjects, opts Options) bool {
+	backup, err := ReadLegacyObjectsFromDirectory(scheme, dir, opts)
+	if err != nil {
+		return false
+	}
+	backedUp := map[string]bool{}
+	for _, ap := range backup.AddressPoolList.Items {
+		backedUp[objectKey("AddressPool", ap.Namespace, ap.Name)] = true
+	}
+	for _, ap := range legacyObjects.AddressPoolList.Items {
+		if !backedUp[objectKey("AddressPool", ap.Namespace, ap.Name)] {
+			return false
+		}
+	}
+	return true
+}
+
+// checkBackupDir verifies that an online migration can write a backup of size bytes to dir before it modifies
+// anything. dir is created if it does not exist. It must be writable, empty or versioned in a git working tree, so that
+// earlier backups cannot be overwritten unnoticed, and it must have at least size bytes of free space.
+func checkBackupDir(dir string, size int64) error {
+	info, err := os.Stat(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		if !quiet {
+			log.Printf("creating backup directory %s", dir)
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("%w: cannot create %s, err: %v", ErrBackupDirUnusable, dir, err)
+		}
+		info, err = os.Stat(dir)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: cannot access %s, err: %v", ErrBackupDirUnusable, dir, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%w: %s is not a directory", ErrBackupDirUnusable, dir)
+	}
+	probe, err := os.CreateTemp(dir, ".metallb-converter-probe-")
+	if err != nil {
+		return fmt.Errorf("%w: %s is not writable, err: %v", ErrBackupDirUnusable, dir, err)
+	}
+	probe.Close()
+	if err := os.Remove(probe.Name()); err != nil {
+		return fmt.Errorf("%w: cannot remove %s, err: %v", ErrBackupDirUnusable, probe.Name(), err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("%w: cannot read %s, err: %v", ErrBackupDirUnusable, dir, err)
+	}
+	if len(entries) > 0 && !isVersioned(dir) {
+		return fmt.Errorf("%w: %s is neither empty nor versioned in a git working tree, use an empty directory to "+
+			"not overwrite earlier backups", ErrBackupDirUnusable, dir)
+	}
+	free, err := freeSpace(dir)
+	if err != nil {
+		return fmt.Errorf("%w: cannot determine the free space of %s, err: %v", ErrBackupDirUnusable, dir, err)
+	}
+	if free >= 0 && free < size {
+		return fmt.Errorf("%w: %s has %d bytes of free space but the backup needs %d bytes", ErrBackupDirUnusable,
+			dir, free, size)
+	}
+	return nil
+}
+
+// isVersioned returns true if dir or one of its parent directories contains a .git directory or file.
+func isVersioned(dir string) bool {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	for {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return false
+		}
+		dir = parent
+	}
+}
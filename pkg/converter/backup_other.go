@@ -0,0 +1,8 @@
+//go:build !linux && !darwin
+
+package converter
+
+// freeSpace returns -1, the free space of file systems is not determined on this platform.
+func freeSpace(dir string) (int64, error) {
+	return -1, nil
+}
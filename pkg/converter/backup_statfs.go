@@ -0,0 +1,14 @@
+//go:build linux || darwin
+
+package converter
+
+import "syscall"
+
+// freeSpace returns the number of bytes that unprivileged users can still write to the file system of dir.
+func freeSpace(dir string) (int64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return 0, err
+	}
+	return int64(stat.Bavail) * int64(stat.Bsize), nil
+}
@@ -0,0 +1,183 @@
+package converter
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestCheckBackupDir(t *testing.T) {
+	tcs := map[string]struct {
+		prepare             func(dir string) error
+		subDir              string
+		size                int64
+		expectedErrorString string
+	}{
+		"empty": {},
+		"missing": {
+			subDir: "a/b",
+		},
+		"not empty": {
+			prepare: func(dir string) error {
+				return os.WriteFile(filepath.Join(dir, "AddressPool.yaml"), []byte("old"), 0644)
+			},
+			expectedErrorString: "is neither empty nor versioned in a git working tree",
+		},
+		"versioned": {
+			prepare: func(dir string) error {
+				if err := os.Mkdir(filepath.Join(dir, ".git"), 0755); err != nil {
+					return err
+				}
+				return os.MkdirAll(filepath.Join(dir, "backups", "old"), 0755)
+			},
+			subDir: "backups",
+		},
+		"not a directory": {
+			prepare: func(dir string) error {
+				return os.WriteFile(filepath.Join(dir, "file"), []byte("old"), 0644)
+			},
+			subDir:              "file",
+			expectedErrorString: "is not a directory",
+		},
+		"insufficient free space": {
+			size:                1 << 62,
+			expectedErrorString: "bytes of free space but the backup needs 4611686018427387904 bytes",
+		},
+	}
+	for desc, tc := range tcs {
+		dir := t.TempDir()
+		if tc.prepare != nil {
+			if err := tc.prepare(dir); err != nil {
+				t.Fatalf("TestCheckBackupDir(%s): cannot prepare the directory, err: %q", desc, err)
+			}
+		}
+		backupDir := filepath.Join(dir, tc.subDir)
+		err := checkBackupDir(backupDir, tc.size)
+		if tc.expectedErrorString != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.expectedErrorString) ||
+				!errors.Is(err, ErrBackupDirUnusable) {
+				t.Fatalf("TestCheckBackupDir(%s): expected error %q but got %v", desc, tc.expectedErrorString, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("TestCheckBackupDir(%s): unexpected error, err: %q", desc, err)
+		}
+		if info, err := os.Stat(backupDir); err != nil || !info.IsDir() {
+			t.Fatalf("TestCheckBackupDir(%s): expected directory %s, err: %v", desc, backupDir, err)
+		}
+	}
+}
+
+func TestBackup(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := AddToScheme(scheme, Options{}); err != nil {
+		t.Fatalf("TestBackup: error adding to scheme, err: %q", err)
+	}
+	ap := &metallbv1beta1.AddressPool{
+		ObjectMeta: metav1.ObjectMeta{Name: "pool", Namespace: "metallb-system"},
+		Spec:       metallbv1beta1.AddressPoolSpec{Protocol: ProtocolLayer2, Addresses: []string{"10.0.0.0/24"}},
+	}
+	dir := filepath.Join(t.TempDir(), "backup")
+
+	empty := fake.NewClientBuilder().WithScheme(scheme).Build()
+	if err := Backup(context.TODO(), empty, dir, false, Options{}); err != nil {
+		t.Fatalf("TestBackup: unexpected error without AddressPools, err: %q", err)
+	}
+	if _, err := os.Stat(dir); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("TestBackup: expected no backup without AddressPools but got err %v", err)
+	}
+
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(ap).Build()
+	if err := Backup(context.TODO(), c, dir, false, Options{}); err != nil {
+		t.Fatalf("TestBackup: unexpected error, err: %q", err)
+	}
+	backup, err := ReadBackup(scheme, dir, Options{})
+	if err != nil || len(backup.AddressPoolList.Items) != 1 || backup.AddressPoolList.Items[0].Name != "pool" {
+		t.Fatalf("TestBackup: expected a backup of AddressPool pool but got %+v, err: %v", backup, err)
+	}
+	if err := Backup(context.TODO(), c, dir, false, Options{}); !errors.Is(err, ErrBackupDirUnusable) {
+		t.Fatalf("TestBackup: expected an unusable backup directory for a second backup but got %v", err)
+	}
+	err = Backup(context.TODO(), c, "", false, Options{})
+	if err == nil || !strings.Contains(err.Error(), "requires a backup directory") {
+		t.Fatalf("TestBackup: expected an error without backup directory but got %v", err)
+	}
+}
+
+func TestWriteBackup(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := AddToScheme(scheme, Options{}); err != nil {
+		t.Fatalf("TestWriteBackup: error adding to scheme, err: %q", err)
+	}
+	legacyObjects := LegacyObjects{AddressPoolList: &metallbv1beta1.AddressPoolList{Items: []metallbv1beta1.AddressPool{{
+		ObjectMeta: metav1.ObjectMeta{Name: "pool", Namespace: "metallb-system"},
+		Spec:       metallbv1beta1.AddressPoolSpec{Protocol: ProtocolLayer2, Addresses: []string{"10.0.0.0/24"}},
+	}}}}
+	dir := t.TempDir()
+
+	expired, cancel := context.WithDeadline(context.TODO(), time.Now().Add(-time.Second))
+	defer cancel()
+	err := legacyObjects.writeBackup(expired, dir, "AddressPool", false, Options{})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("TestWriteBackup: expected the write to time out but got %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Fatalf("TestWriteBackup: expected no backup after the timeout but got %v", entries)
+	}
+
+	if err := legacyObjects.writeBackup(context.TODO(), dir, "AddressPool", false, Options{}); err != nil {
+		t.Fatalf("TestWriteBackup: unexpected error, err: %q", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 || entries[0].Name() != "AddressPool.yaml" {
+		t.Fatalf("TestWriteBackup: expected only AddressPool.yaml in the backup directory but got %v", entries)
+	}
+	backup, err := ReadBackup(scheme, dir, Options{})
+	if err != nil || len(backup.AddressPoolList.Items) != 1 || backup.AddressPoolList.Items[0].Name != "pool" {
+		t.Fatalf("TestWriteBackup: expected a backup of AddressPool pool but got %+v, err: %v", backup, err)
+	}
+}
+
+func TestFinalizeBackupName(t *testing.T) {
+	now := time.Date(2022, 3, 4, 5, 6, 7, 0, time.UTC)
+	tcs := map[string]struct {
+		files        []string
+		expectedName string
+	}{
+		"empty": {
+			expectedName: "AddressPool",
+		},
+		"backup of the mark phase": {
+			files:        []string{"AddressPool.yaml"},
+			expectedName: "finalize-20220304T050607Z",
+		},
+		"JSON backup of the mark phase": {
+			files:        []string{"AddressPool.json"},
+			expectedName: "finalize-20220304T050607Z",
+		},
+		"finalization at the same time": {
+			files:        []string{"AddressPool.yaml", "finalize-20220304T050607Z.yaml"},
+			expectedName: "finalize-20220304T050607Z-2",
+		},
+	}
+	for desc, tc := range tcs {
+		dir := t.TempDir()
+		for _, file := range tc.files {
+			if err := os.WriteFile(filepath.Join(dir, file), []byte("old"), 0644); err != nil {
+				t.Fatalf("TestFinalizeBackupName(%s): cannot write %s, err: %q", desc, file, err)
+			}
+		}
+		if name := finalizeBackupName(dir, now); name != tc.expectedName {
+			t.Fatalf("TestFinalizeBackupName(%s): expected %q but got %q", desc, tc.expectedName, name)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package converter
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	metallbv1beta2 "go.universe.tf/metallb/api/v1beta2"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	defaultAggregationLength   = 32
+	defaultAggregationLengthV6 = 128
+)
+
+// peersOverlap returns true if two advertisements can be sent to at least one common peer. An empty peer list
+// selects all peers.
+func peersOverlap(a, b []string) bool {
+	if len(a) == 0 || len(b) == 0 {
+		return true
+	}
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// aggregationLengths returns the effective IPv4 and IPv6 aggregation lengths of an advertisement.
+func aggregationLengths(ba metallbv1beta1.BGPAdvertisement) (int32, int32) {
+	v4, v6 := int32(defaultAggregationLength), int32(defaultAggregationLengthV6)
+	if ba.Spec.AggregationLength != nil {
+		v4 = *ba.Spec.AggregationLength
+	}
+	if ba.Spec.AggregationLengthV6 != nil {
+		v6 = *ba.Spec.AggregationLengthV6
+	}
+	return v4, v6
+}
+
+// LocalPrefConflicts returns a description of every case where the same prefixes of a pool would be advertised to
+// the same peers with different localPref values. MetalLB rejects such configurations. Advertisements of a pool
+// produce the same prefixes if they use the same aggregation lengths.
+func (c *CurrentObjects) LocalPrefConflicts() []string {
+	var conflicts []string
+	items := c.BGPAdvertisementList.Items
+	for i := 0; i < len(items); i++ {
+		for j := i + 1; j < len(items); j++ {
+			a, b := items[i], items[j]
+			if a.Namespace != b.Namespace || a.Spec.LocalPref == b.Spec.LocalPref ||
+				!peersOverlap(a.Spec.Peers, b.Spec.Peers) {
+				continue
+			}
+			aV4, aV6 := aggregationLengths(a)
+			bV4, bV6 := aggregationLengths(b)
+			if aV4 != bV4 && aV6 != bV6 {
+				continue
+			}
+			for _, pool := range a.Spec.IPAddressPools {
+				if !contains(b.Spec.IPAddressPools, pool) {
+					continue
+				}
+				conflicts = append(conflicts, fmt.Sprintf("IPAddressPool %s/%s is advertised with localPref %d by "+
+					"BGPAdvertisement %s and with localPref %d by BGPAdvertisement %s to the same peers; use the "+
+					"same localPref, different aggregation lengths or disjoint peers", a.Namespace, pool,
+					a.Spec.LocalPref, a.Name, b.Spec.LocalPref, b.Name))
+			}
+		}
+	}
+	return conflicts
+}
+
+// checkLocalPrefs logs a warning for every localPref conflict, or returns an error that lists all conflicts if fail is
+// true.
+func (c *CurrentObjects) checkLocalPrefs(fail bool) error {
+	conflicts := c.LocalPrefConflicts()
+	if len(conflicts) == 0 {
+		return nil
+	}
+	if fail {
+		return fmt.Errorf("conflicting localPref:\n%s", strings.Join(conflicts, "\n"))
+	}
+	if !quiet {
+		for _, conflict := range conflicts {
+			log.Printf("warning: %s", conflict)
+		}
+	}
+	return nil
+}
+
+// countBGPPeers returns the number of BGPPeers in the cluster. It lists v1beta2 BGPPeers and falls back to v1beta1
+// if v1beta2 is not registered in the scheme of the client. A cluster without the BGPPeer CRD has no BGPPeers.
+func countBGPPeers(ctx context.Context, cl client.Client) (int, error) {
+	v1beta2List := &metallbv1beta2.BGPPeerList{}
+	err := cl.List(ctx, v1beta2List)
+	if err == nil {
+		return len(v1beta2List.Items), nil
+	}
+	if !runtime.IsNotRegisteredError(err) {
+		if meta.IsNoMatchError(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to list BGPPeers in cluster: %w", err)
+	}
+	v1beta1List := &metallbv1beta1.BGPPeerList{}
+	err = cl.List(ctx, v1beta1List)
+	if err != nil && !meta.IsNoMatchError(err) {
+		return 0, fmt.Errorf("failed to list BGPPeers in cluster: %w", err)
+	}
+	return len(v1beta1List.Items), nil
+}
+
+// checkBGPPeers logs a warning if BGPAdvertisements were converted, advertisements is their number, but neither the
+// cluster nor the legacy configuration, which holds legacyPeers peers, holds a single BGP peer. Such advertisements
+// silently advertise nothing.
+func checkBGPPeers(ctx context.Context, cl client.Client, advertisements, legacyPeers int) error {
+	if advertisements == 0 || legacyPeers > 0 {
+		return nil
+	}
+	peers, err := countBGPPeers(ctx, cl)
+	if err != nil {
+		return err
+	}
+	if peers == 0 && !quiet {
+		log.Printf("warning: %d BGPAdvertisements were converted but there are no BGPPeers, nothing will be "+
+			"advertised until BGPPeer resources are created; convert the peers of the legacy ConfigMap or "+
+			"create them manually", advertisements)
+	}
+	return nil
+}
+
+// contains returns true if list contains s.
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,155 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"strings"
+	"testing"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	metallbv1beta2 "go.universe.tf/metallb/api/v1beta2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestLocalPrefConflicts(t *testing.T) {
+	tcs := map[string]struct {
+		advertisements    []metallbv1beta1.LegacyBgpAdvertisement
+		peers             [][]string
+		expectedConflicts int
+	}{
+		"same localPref": {
+			advertisements: []metallbv1beta1.LegacyBgpAdvertisement{{LocalPref: 10}, {LocalPref: 10}},
+		},
+		"different localPref": {
+			advertisements:    []metallbv1beta1.LegacyBgpAdvertisement{{LocalPref: 10}, {LocalPref: 11}},
+			expectedConflicts: 1,
+		},
+		"different aggregation lengths": {
+			advertisements: []metallbv1beta1.LegacyBgpAdvertisement{
+				{LocalPref: 10, AggregationLength: pointer.Int32(24), AggregationLengthV6: pointer.Int32(64)},
+				{LocalPref: 11},
+			},
+		},
+		"same effective aggregation length": {
+			advertisements: []metallbv1beta1.LegacyBgpAdvertisement{
+				{LocalPref: 10, AggregationLength: pointer.Int32(32)},
+				{LocalPref: 11},
+			},
+			expectedConflicts: 1,
+		},
+		"disjoint peers": {
+			advertisements: []metallbv1beta1.LegacyBgpAdvertisement{{LocalPref: 10}, {LocalPref: 11}},
+			peers:          [][]string{{"peer-a"}, {"peer-b"}},
+		},
+		"overlapping peers": {
+			advertisements:    []metallbv1beta1.LegacyBgpAdvertisement{{LocalPref: 10}, {LocalPref: 11}},
+			peers:             [][]string{{"peer-a"}, {}},
+			expectedConflicts: 1,
+		},
+	}
+	for desc, tc := range tcs {
+		ap := *validAddressPools0[2].DeepCopy()
+		ap.Spec.BGPAdvertisements = tc.advertisements
+		legacyObjects := &LegacyObjects{AddressPoolList: &metallbv1beta1.AddressPoolList{
+			Items: []metallbv1beta1.AddressPool{ap},
+		}}
+		currentObjects, err := legacyObjects.Convert(Options{})
+		if err != nil {
+			t.Fatalf("TestLocalPrefConflicts(%s): unexpected error during conversion, err: %q", desc, err)
+		}
+		for i, peers := range tc.peers {
+			currentObjects.BGPAdvertisementList.Items[i].Spec.Peers = peers
+		}
+		if conflicts := currentObjects.LocalPrefConflicts(); len(conflicts) != tc.expectedConflicts {
+			t.Fatalf("TestLocalPrefConflicts(%s): expected %d conflicts but got %v", desc, tc.expectedConflicts,
+				conflicts)
+		}
+	}
+}
+
+func TestCheckLocalPrefs(t *testing.T) {
+	legacyObjects := &LegacyObjects{AddressPoolList: &metallbv1beta1.AddressPoolList{Items: validAddressPools0}}
+	currentObjects, err := legacyObjects.Convert(Options{})
+	if err != nil {
+		t.Fatalf("TestCheckLocalPrefs: unexpected error during conversion, err: %q", err)
+	}
+	if err := currentObjects.checkLocalPrefs(false); err != nil {
+		t.Fatalf("TestCheckLocalPrefs: conflicts must only be reported as warnings by default, err: %q", err)
+	}
+	err = currentObjects.checkLocalPrefs(true)
+	if err == nil || !strings.Contains(err.Error(), "IPAddressPool metallb-system/ap-bgp is advertised") {
+		t.Fatalf("TestCheckLocalPrefs: expected a localPref conflict error but got %v", err)
+	}
+}
+
+func TestCheckBGPPeers(t *testing.T) {
+	tcs := map[string]struct {
+		addressPools    []metallbv1beta1.AddressPool
+		v1beta2         bool
+		peers           int
+		legacyPeers     int
+		expectedWarning bool
+	}{
+		"no peers": {
+			addressPools:    validAddressPools0,
+			v1beta2:         true,
+			expectedWarning: true,
+		},
+		"no peers v1beta1 only": {
+			addressPools:    validAddressPools0,
+			expectedWarning: true,
+		},
+		"peers": {
+			addressPools: validAddressPools0,
+			v1beta2:      true,
+			peers:        1,
+		},
+		"legacy peers": {
+			addressPools: validAddressPools0,
+			v1beta2:      true,
+			legacyPeers:  1,
+		},
+		"no BGP advertisements": {
+			addressPools: validAddressPools0[:1],
+			v1beta2:      true,
+		},
+	}
+	for desc, tc := range tcs {
+		var scheme = runtime.NewScheme()
+		if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+			t.Fatalf("TestCheckBGPPeers(%s): error adding to scheme, err: %q", desc, err)
+		}
+		if tc.v1beta2 {
+			if err := metallbv1beta2.AddToScheme(scheme); err != nil {
+				t.Fatalf("TestCheckBGPPeers(%s): error adding to scheme, err: %q", desc, err)
+			}
+		}
+		c := fake.NewClientBuilder().WithScheme(scheme).Build()
+		for i := 0; i < tc.peers; i++ {
+			peer := &metallbv1beta2.BGPPeer{ObjectMeta: metav1.ObjectMeta{Name: "peer", Namespace: "metallb-system"}}
+			if err := c.Create(context.TODO(), peer); err != nil {
+				t.Fatalf("TestCheckBGPPeers(%s): cannot create BGPPeer, err: %q", desc, err)
+			}
+		}
+		legacyObjects := &LegacyObjects{AddressPoolList: &metallbv1beta1.AddressPoolList{Items: tc.addressPools}}
+		currentObjects, err := legacyObjects.Convert(Options{})
+		if err != nil {
+			t.Fatalf("TestCheckBGPPeers(%s): unexpected error during conversion, err: %q", desc, err)
+		}
+		logs := bytes.NewBuffer([]byte{})
+		log.SetOutput(logs)
+		err = checkBGPPeers(context.TODO(), c, len(currentObjects.BGPAdvertisementList.Items), tc.legacyPeers)
+		log.SetOutput(os.Stderr)
+		if err != nil {
+			t.Fatalf("TestCheckBGPPeers(%s): unexpected error, err: %q", desc, err)
+		}
+		if warned := strings.Contains(logs.String(), "there are no BGPPeers"); warned != tc.expectedWarning {
+			t.Fatalf("TestCheckBGPPeers(%s): expected warning %t but got logs %q", desc, tc.expectedWarning, logs)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package converter
+
+import (
+	"fmt"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	metallbv1beta2 "go.universe.tf/metallb/api/v1beta2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// convertBGPResources converts the v1beta1 BGPPeers that were read along with the AddressPools into v1beta2 BGPPeers
+// and passes their BFDProfiles through. Passwords are converted according to passwordsMode, see setPeerPassword. It
+// returns the BFDProfiles, the BGPPeers and the Secrets grouped by kind, or nil if there are none.
+func (l LegacyObjects) convertBGPResources(passwordsMode string) ([][]runtime.Object, error) {
+	var profiles, peers, secrets []runtime.Object
+	if l.BFDProfileList != nil {
+		for i := range l.BFDProfileList.Items {
+			profile := l.BFDProfileList.Items[i].DeepCopy()
+			profile.TypeMeta = metav1.TypeMeta{Kind: "BFDProfile", APIVersion: metallbAPIVersion}
+			profiles = append(profiles, profile)
+		}
+	}
+	if l.BGPPeerList != nil {
+		for i := range l.BGPPeerList.Items {
+			peer, err := convertBGPPeer(&l.BGPPeerList.Items[i])
+			if err != nil {
+				return nil, err
+			}
+			if secret := setPeerPassword(peer, peer.Spec.Password, passwordsMode); secret != nil {
+				secrets = append(secrets, secret)
+			}
+			peers = append(peers, peer)
+		}
+	}
+	var groups [][]runtime.Object
+	for _, group := range [][]runtime.Object{profiles, peers, secrets} {
+		if len(group) > 0 {
+			groups = append(groups, group)
+		}
+	}
+	return groups, nil
+}
+
+// convertBGPPeer converts a v1beta1 BGPPeer into a v1beta2 BGPPeer, the way the conversion webhook of MetalLB does.
+func convertBGPPeer(src *metallbv1beta1.BGPPeer) (*metallbv1beta2.BGPPeer, error) {
+	peer := &metallbv1beta2.BGPPeer{}
+	if err := src.DeepCopy().ConvertTo(peer); err != nil {
+		return nil, fmt.Errorf("cannot convert BGPPeer %s/%s, err: %w", src.Namespace, src.Name, err)
+	}
+	peer.TypeMeta = metav1.TypeMeta{Kind: "BGPPeer", APIVersion: metallbV1beta2APIVersion}
+	return peer, nil
+}
@@ -0,0 +1,140 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	metallbv1beta2 "go.universe.tf/metallb/api/v1beta2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const legacyBGPResources = `apiVersion: metallb.io/v1beta1
+kind: AddressPool
+metadata:
+  name: bgp
+  namespace: metallb-system
+spec:
+  addresses:
+  - 192.168.10.0/24
+  protocol: bgp
+---
+apiVersion: metallb.io/v1beta1
+kind: BGPPeer
+metadata:
+  name: peer-a
+  namespace: metallb-system
+  resourceVersion: "42"
+spec:
+  myASN: 64500
+  peerASN: 64501
+  peerAddress: 10.0.0.1
+  password: s3cret
+  bfdProfile: fast
+  nodeSelectors:
+  - matchLabels:
+      rack: a
+---
+apiVersion: metallb.io/v1beta1
+kind: BFDProfile
+metadata:
+  name: fast
+  namespace: metallb-system
+spec:
+  receiveInterval: 100
+`
+
+func TestConvertBGPResources(t *testing.T) {
+	tcs := map[string]struct {
+		passwords              string
+		expectedGroups         int
+		expectedPassword       string
+		expectedPasswordSecret string
+	}{
+		"password moved into a secret": {
+			passwords:              PeerPasswordsSecret,
+			expectedGroups:         3,
+			expectedPasswordSecret: "peer-a-bgp-password",
+		},
+		"inline password": {
+			passwords:        PeerPasswordsInline,
+			expectedGroups:   2,
+			expectedPassword: "s3cret",
+		},
+	}
+	var scheme = runtime.NewScheme()
+	if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestConvertBGPResources: error adding to scheme, err: %q", err)
+	}
+	SetQuiet(true)
+	defer SetQuiet(false)
+	for desc, tc := range tcs {
+		legacyObjects, err := ReadLegacyObjects(scheme, []byte(legacyBGPResources), "test", Options{})
+		if err != nil {
+			t.Fatalf("TestConvertBGPResources(%s): cannot read legacy objects, err: %q", desc, err)
+		}
+		groups, err := legacyObjects.convertBGPResources(tc.passwords)
+		if err != nil {
+			t.Fatalf("TestConvertBGPResources(%s): unexpected error, err: %q", desc, err)
+		}
+		if len(groups) != tc.expectedGroups {
+			t.Fatalf("TestConvertBGPResources(%s): expected %d groups but got %d", desc, tc.expectedGroups,
+				len(groups))
+		}
+		profile := groups[0][0].(*metallbv1beta1.BFDProfile)
+		if profile.Name != "fast" || profile.Spec.ReceiveInterval == nil || *profile.Spec.ReceiveInterval != 100 {
+			t.Fatalf("TestConvertBGPResources(%s): unexpected BFDProfile %+v", desc, profile)
+		}
+		peer := groups[1][0].(*metallbv1beta2.BGPPeer)
+		if peer.APIVersion != metallbV1beta2APIVersion || peer.ResourceVersion != "" ||
+			peer.Spec.BFDProfile != "fast" || len(peer.Spec.NodeSelectors) != 1 ||
+			peer.Spec.NodeSelectors[0].MatchLabels["rack"] != "a" {
+			t.Fatalf("TestConvertBGPResources(%s): unexpected BGPPeer %+v", desc, peer)
+		}
+		if peer.Spec.Password != tc.expectedPassword || peer.Spec.PasswordSecret.Name != tc.expectedPasswordSecret {
+			t.Fatalf("TestConvertBGPResources(%s): expected password %q and passwordSecret %q but got %q and %q",
+				desc, tc.expectedPassword, tc.expectedPasswordSecret, peer.Spec.Password, peer.Spec.PasswordSecret.Name)
+		}
+		if tc.expectedPasswordSecret != "" {
+			secret := groups[2][0].(*corev1.Secret)
+			if string(secret.Data[peerPasswordKey]) != "s3cret" || secret.Namespace != "metallb-system" {
+				t.Fatalf("TestConvertBGPResources(%s): unexpected Secret %+v", desc, secret)
+			}
+		}
+	}
+}
+
+func TestOfflineMigrationBGPResources(t *testing.T) {
+	var scheme = runtime.NewScheme()
+	if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestOfflineMigrationBGPResources: error adding to scheme, err: %q", err)
+	}
+	inDir := t.TempDir()
+	if err := os.WriteFile(path.Join(inDir, "config.yaml"), []byte(legacyBGPResources), 0644); err != nil {
+		t.Fatal(err)
+	}
+	SetQuiet(true)
+	defer SetQuiet(false)
+	stdout = bytes.NewBuffer([]byte{})
+	err := OfflineMigration(context.TODO(), nil, scheme, inDir, "", OutputFormatYAML, OfflineMigrationOptions{})
+	if err != nil {
+		t.Fatalf("TestOfflineMigrationBGPResources: unexpected error, err: %q", err)
+	}
+	output := stdout.(*bytes.Buffer).String()
+	for _, expected := range []string{
+		"kind: IPAddressPool\n",
+		"apiVersion: metallb.io/v1beta1\nkind: BFDProfile\n",
+		"apiVersion: metallb.io/v1beta2\nkind: BGPPeer\n",
+		"  passwordSecret:\n    name: peer-a-bgp-password\n    namespace: metallb-system\n",
+		"kind: Secret\n",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Fatalf("TestOfflineMigrationBGPResources: expected %q in output but got:\n%s", expected, output)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package converter
+
+import (
+	"fmt"
+	"math/big"
+	"net/netip"
+	"text/tabwriter"
+)
+
+// poolCapacity is the number of usable addresses of a pool per address family.
+type poolCapacity struct {
+	namespace string
+	name      string
+	ipv4      *big.Int
+	ipv6      *big.Int
+}
+
+// addressCount returns the number of addresses in the range first-last. If avoidBuggyIPs is set, IPv4 addresses that
+// end in .0 or .255 are not counted, just like MetalLB does not assign them.
+func addressCount(first, last netip.Addr, avoidBuggyIPs bool) *big.Int {
+	from := new(big.Int).SetBytes(first.AsSlice())
+	to := new(big.Int).SetBytes(last.AsSlice())
+	if to.Cmp(from) < 0 {
+		return new(big.Int)
+	}
+	count := new(big.Int).Sub(to, from)
+	count.Add(count, big.NewInt(1))
+	if !avoidBuggyIPs || !first.Is4() {
+		return count
+	}
+	// Addresses are at most 2^32 here, so int64 arithmetic is safe.
+	a, b := from.Int64(), to.Int64()
+	for _, octet := range []int64{0, 255} {
+		// Number of n in [a, b] with n % 256 == octet.
+		buggy := floorDiv(b-octet, 256) - floorDiv(a-1-octet, 256)
+		count.Sub(count, big.NewInt(buggy))
+	}
+	return count
+}
+
+// floorDiv divides a by b and rounds towards negative infinity.
+func floorDiv(a, b int64) int64 {
+	q := a / b
+	if (a%b != 0) && ((a < 0) != (b < 0)) {
+		q--
+	}
+	return q
+}
+
+// capacities computes the usable address count per address family of every IPAddressPool.
+func (c *CurrentObjects) capacities() ([]poolCapacity, error) {
+	var result []poolCapacity
+	for _, iap := range c.IPAddressPoolList.Items {
+		pc := poolCapacity{namespace: iap.Namespace, name: iap.Name, ipv4: new(big.Int), ipv6: new(big.Int)}
+		for _, address := range iap.Spec.Addresses {
+			first, last, err := parseAddressRange(address)
+			if err != nil {
+				return nil, fmt.Errorf("cannot compute capacity of IPAddressPool %s/%s, err: %w", iap.Namespace,
+					iap.Name, err)
+			}
+			count := addressCount(first, last, iap.Spec.AvoidBuggyIPs)
+			if first.Is4() {
+				pc.ipv4.Add(pc.ipv4, count)
+			} else {
+				pc.ipv6.Add(pc.ipv6, count)
+			}
+		}
+		result = append(result, pc)
+	}
+	return result, nil
+}
+
+// PrintCapacity writes the number of usable addresses per address family of every IPAddressPool and in total either
+// to the targetDirectory or to stdout if targetDirectory == "".
+func (c *CurrentObjects) PrintCapacity(targetDirectory string) error {
+	capacities, err := c.capacities()
+	if err != nil {
+		return err
+	}
+	outWriter, closeFn, err := outputWriter(targetDirectory, "capacity.txt")
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+	w := tabwriter.NewWriter(outWriter, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "NAMESPACE\tNAME\tIPV4\tIPV6")
+	totalIPv4, totalIPv6 := new(big.Int), new(big.Int)
+	for _, pc := range capacities {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", pc.namespace, pc.name, pc.ipv4, pc.ipv6)
+		totalIPv4.Add(totalIPv4, pc.ipv4)
+		totalIPv6.Add(totalIPv6, pc.ipv6)
+	}
+	fmt.Fprintf(w, "TOTAL\t\t%s\t%s\n", totalIPv4, totalIPv6)
+	return w.Flush()
+}
@@ -0,0 +1,72 @@
+package converter
+
+import (
+	"bytes"
+	"fmt"
+	"net/netip"
+	"testing"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestAddressCount(t *testing.T) {
+	tcs := map[string]struct {
+		address       string
+		avoidBuggyIPs bool
+		expected      string
+	}{
+		"ipv4 cidr":                 {address: "192.168.0.0/24", expected: "256"},
+		"ipv4 cidr avoid buggy IPs": {address: "192.168.0.0/24", avoidBuggyIPs: true, expected: "254"},
+		"ipv4 range avoid buggy":    {address: "10.0.0.250-10.0.1.5", avoidBuggyIPs: true, expected: "10"},
+		"ipv4 single address":       {address: "10.0.0.1/32", expected: "1"},
+		"ipv6 cidr":                 {address: "2001:db8::/64", expected: "18446744073709551616"},
+		"ipv6 ignores avoid buggy":  {address: "2001:db8::/120", avoidBuggyIPs: true, expected: "256"},
+		"reversed range":            {address: "10.0.0.5-10.0.0.1", expected: "0"},
+	}
+	for desc, tc := range tcs {
+		first, last, err := parseAddressRange(tc.address)
+		if err != nil {
+			t.Fatalf("TestAddressCount(%s): unexpected error, err: %q", desc, err)
+		}
+		if got := addressCount(first, last, tc.avoidBuggyIPs).String(); got != tc.expected {
+			t.Fatalf("TestAddressCount(%s): expected %s but got %s", desc, tc.expected, got)
+		}
+	}
+	// Make sure that the first address of a range is not dropped by accident.
+	if got := addressCount(netip.MustParseAddr("10.0.0.0"), netip.MustParseAddr("10.0.0.0"), true); got.Sign() != 0 {
+		t.Fatalf("TestAddressCount: expected 10.0.0.0 to be avoided but got %s", got)
+	}
+}
+
+var capacityOutput = `NAMESPACE       NAME       IPV4  IPV6
+metallb-system  dualstack  254   256
+metallb-system  v4         2     0
+TOTAL                      256   256
+`
+
+func TestPrintCapacity(t *testing.T) {
+	currentObjects := &CurrentObjects{IPAddressPoolList: &metallbv1beta1.IPAddressPoolList{
+		Items: []metallbv1beta1.IPAddressPool{
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "dualstack", Namespace: "metallb-system"},
+				Spec: metallbv1beta1.IPAddressPoolSpec{
+					Addresses:     []string{"192.168.0.0/24", "2001:db8::/120"},
+					AvoidBuggyIPs: true,
+				},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "v4", Namespace: "metallb-system"},
+				Spec:       metallbv1beta1.IPAddressPoolSpec{Addresses: []string{"10.0.0.1-10.0.0.2"}},
+			},
+		},
+	}}
+	stdout = bytes.NewBuffer([]byte{})
+	if err := currentObjects.PrintCapacity(""); err != nil {
+		t.Fatalf("TestPrintCapacity: unexpected error, err: %q", err)
+	}
+	if fmt.Sprint(stdout) != capacityOutput {
+		t.Fatalf("TestPrintCapacity: Generated output does not match expected output.\nGenerated output:\n===\n"+
+			"```%s```\n\nExpected output:\n===\n```%s```", stdout, capacityOutput)
+	}
+}
@@ -0,0 +1,135 @@
+package converter
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// clusterDiffLabel replaces the temporary directory of the cluster objects in the file names of a cluster diff.
+const clusterDiffLabel = "cluster"
+
+// clusterObjects returns the objects of the cluster that have the kind, namespace and name of an object of c, in the
+// order of c and sanitized like the objects of ReadExportObjectsFromAPI. Objects that do not exist in the cluster, or
+// whose CRD is not installed, are left out.
+func (c CurrentObjects) clusterObjects(ctx context.Context, cl client.Client) (*CurrentObjects, error) {
+	existing := &CurrentObjects{
+		IPAddressPoolList:    &metallbv1beta1.IPAddressPoolList{},
+		L2AdvertisementList:  &metallbv1beta1.L2AdvertisementList{},
+		BGPAdvertisementList: &metallbv1beta1.BGPAdvertisementList{},
+		CommunityList:        &metallbv1beta1.CommunityList{},
+	}
+	// get reads the object key into obj and returns false if it does not exist.
+	get := func(key types.NamespacedName, obj client.Object) (bool, error) {
+		err := cl.Get(ctx, key, obj)
+		if apierrors.IsNotFound(err) || meta.IsNoMatchError(err) {
+			return false, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("cannot get %T %s, err: %w", obj, key, err)
+		}
+		return true, nil
+	}
+	for _, iap := range c.IPAddressPoolList.Items {
+		current := &metallbv1beta1.IPAddressPool{}
+		found, err := get(types.NamespacedName{Namespace: iap.Namespace, Name: iap.Name}, current)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			current.TypeMeta = metav1.TypeMeta{Kind: "IPAddressPool", APIVersion: metallbAPIVersion}
+			current.ObjectMeta = sanitizeObjectMeta(current.ObjectMeta)
+			current.Status = metallbv1beta1.IPAddressPoolStatus{}
+			existing.IPAddressPoolList.Items = append(existing.IPAddressPoolList.Items, *current)
+		}
+	}
+	for _, l2a := range c.L2AdvertisementList.Items {
+		current := &metallbv1beta1.L2Advertisement{}
+		found, err := get(types.NamespacedName{Namespace: l2a.Namespace, Name: l2a.Name}, current)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			current.TypeMeta = metav1.TypeMeta{Kind: "L2Advertisement", APIVersion: metallbAPIVersion}
+			current.ObjectMeta = sanitizeObjectMeta(current.ObjectMeta)
+			current.Status = metallbv1beta1.L2AdvertisementStatus{}
+			existing.L2AdvertisementList.Items = append(existing.L2AdvertisementList.Items, *current)
+		}
+	}
+	for _, ba := range c.BGPAdvertisementList.Items {
+		current := &metallbv1beta1.BGPAdvertisement{}
+		found, err := get(types.NamespacedName{Namespace: ba.Namespace, Name: ba.Name}, current)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			current.TypeMeta = metav1.TypeMeta{Kind: "BGPAdvertisement", APIVersion: metallbAPIVersion}
+			current.ObjectMeta = sanitizeObjectMeta(current.ObjectMeta)
+			current.Status = metallbv1beta1.BGPAdvertisementStatus{}
+			existing.BGPAdvertisementList.Items = append(existing.BGPAdvertisementList.Items, *current)
+		}
+	}
+	for _, community := range c.CommunityList.Items {
+		current := &metallbv1beta1.Community{}
+		found, err := get(types.NamespacedName{Namespace: community.Namespace, Name: community.Name}, current)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			current.TypeMeta = metav1.TypeMeta{Kind: "Community", APIVersion: metallbAPIVersion}
+			current.ObjectMeta = sanitizeObjectMeta(current.ObjectMeta)
+			current.Status = metallbv1beta1.CommunityStatus{}
+			existing.CommunityList.Items = append(existing.CommunityList.Items, *current)
+		}
+	}
+	return existing, nil
+}
+
+// checkCluster compares the files that a conversion of c printed into generatedDir with the same files printed from
+// the objects of the cluster that c would create or update. If they differ, it prints a unified diff to standard out
+// and returns an error that wraps ErrClusterMismatch. Objects of the cluster that c does not hold are disregarded. The
+// objects of the cluster are printed according to opts, like those of c.
+func checkCluster(ctx context.Context, cl client.Client, c *CurrentObjects, generatedDir string, toJSON bool,
+	opts Options) error {
+	if cl == nil {
+		return fmt.Errorf("diffing against the cluster requires a connection to the cluster")
+	}
+	existing, err := c.clusterObjects(ctx, cl)
+	if err != nil {
+		return err
+	}
+	clusterDir, err := os.MkdirTemp("", "metallb-converter-")
+	if err != nil {
+		return fmt.Errorf("cannot print cluster objects, err: %w", err)
+	}
+	defer os.RemoveAll(clusterDir)
+	groups, err := existing.outputGroups(true, nil, opts)
+	if err == nil {
+		err = printGroups(clusterDir, groups, toJSON, opts)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot print cluster objects, err: %w", err)
+	}
+	diff, files, err := compareGolden(generatedDir, clusterDir, clusterDiffLabel)
+	if err != nil {
+		return err
+	}
+	if files == 0 {
+		if !quiet {
+			log.Printf("no differences to the cluster")
+		}
+		return nil
+	}
+	if _, err := fmt.Fprint(stdout, diff); err != nil {
+		return fmt.Errorf("cannot print cluster diff, err: %w", err)
+	}
+	return fmt.Errorf("%w: %d files differ", ErrClusterMismatch, files)
+}
@@ -0,0 +1,123 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestDiffClusterOfflineMigration(t *testing.T) {
+	var scheme = runtime.NewScheme()
+	if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestDiffClusterOfflineMigration: error adding to scheme, err: %q", err)
+	}
+	inDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(inDir, "pools.yaml"), []byte(renderedKustomization), 0644); err != nil {
+		t.Fatalf("TestDiffClusterOfflineMigration: cannot write input, err: %q", err)
+	}
+	legacyObjects, err := ReadLegacyObjectsFromDirectory(scheme, inDir, Options{})
+	if err != nil {
+		t.Fatalf("TestDiffClusterOfflineMigration: cannot read input, err: %q", err)
+	}
+	currentObjects, err := legacyObjects.Convert(Options{})
+	if err != nil {
+		t.Fatalf("TestDiffClusterOfflineMigration: unexpected error during conversion, err: %q", err)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+	// An IPAddressPool that the conversion does not create is disregarded.
+	other := &metallbv1beta1.IPAddressPool{
+		ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "metallb-system"},
+		Spec:       metallbv1beta1.IPAddressPoolSpec{Addresses: []string{"10.10.10.0/24"}},
+	}
+	if err := c.Create(context.TODO(), other); err != nil {
+		t.Fatalf("TestDiffClusterOfflineMigration: cannot create IPAddressPool, err: %q", err)
+	}
+
+	tcs := []struct {
+		desc                string
+		modify              func() error
+		expectedDiff        []string
+		expectedErrorString string
+	}{
+		{
+			desc:   "not migrated",
+			modify: func() error { return nil },
+			expectedDiff: []string{
+				"--- /dev/null\n+++ converted/IPAddressPool.yaml\n",
+				"--- /dev/null\n+++ converted/L2Advertisement.yaml\n",
+			},
+			expectedErrorString: "2 files differ",
+		},
+		{
+			desc: "migrated",
+			modify: func() error {
+				for _, obj := range currentObjects.objects() {
+					if err := c.Create(context.TODO(), obj.(client.Object)); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+		{
+			desc: "divergent",
+			modify: func() error {
+				iap := &metallbv1beta1.IPAddressPool{}
+				err := c.Get(context.TODO(), types.NamespacedName{Namespace: "metallb-system", Name: "overlay-pool"},
+					iap)
+				if err != nil {
+					return err
+				}
+				iap.Spec.Addresses = []string{"10.0.0.0/24"}
+				return c.Update(context.TODO(), iap)
+			},
+			expectedDiff: []string{
+				"--- cluster/IPAddressPool.yaml\n+++ converted/IPAddressPool.yaml\n",
+				"-  - 10.0.0.0/24\n+  - 192.168.10.0/24\n",
+			},
+			expectedErrorString: "1 files differ",
+		},
+	}
+	SetQuiet(true)
+	defer SetQuiet(false)
+	for _, tc := range tcs {
+		if err := tc.modify(); err != nil {
+			t.Fatalf("TestDiffClusterOfflineMigration(%s): cannot modify cluster, err: %q", tc.desc, err)
+		}
+		stdout = bytes.NewBuffer([]byte{})
+		err := OfflineMigration(context.TODO(), c, scheme, inDir, "", OutputFormatYAML,
+			OfflineMigrationOptions{DiffCluster: true})
+		diff := stdout.(*bytes.Buffer).String()
+		if strings.Contains(diff, "10.10.10.0/24") {
+			t.Fatalf("TestDiffClusterOfflineMigration(%s): unexpected IPAddressPool other in diff:\n%s", tc.desc, diff)
+		}
+		if tc.expectedErrorString == "" {
+			if err != nil || diff != "" {
+				t.Fatalf("TestDiffClusterOfflineMigration(%s): expected no differences but got err %v and diff:\n%s",
+					tc.desc, err, diff)
+			}
+			continue
+		}
+		if !errors.Is(err, ErrClusterMismatch) || !strings.Contains(err.Error(), tc.expectedErrorString) {
+			t.Fatalf("TestDiffClusterOfflineMigration(%s): expected error %q but got %v", tc.desc,
+				tc.expectedErrorString, err)
+		}
+		for _, expected := range tc.expectedDiff {
+			if !strings.Contains(diff, expected) {
+				t.Fatalf("TestDiffClusterOfflineMigration(%s): expected %q in diff but got:\n%s", tc.desc, expected,
+					diff)
+			}
+		}
+	}
+}
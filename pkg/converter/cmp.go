@@ -0,0 +1,155 @@
+package converter
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+)
+
+// ArgoCDEnvPrefix is the prefix that ArgoCD adds to the environment variables that an Application passes to a Config
+// Management Plugin.
+const ArgoCDEnvPrefix = "ARGOCD_ENV_"
+
+// manifestExtensions are the file extensions that GenerateManifests reads.
+var manifestExtensions = map[string]bool{".yaml": true, ".yml": true, ".json": true}
+
+// GenerateManifests implements the generate command of an ArgoCD Config Management Plugin (CMP). It reads all YAML
+// and JSON files below dir, converts the legacy AddressPools among them and writes the converted resources, followed
+// by every other document unchanged, as a YAML stream to w. Hidden files and directories, and those that the
+// IgnoreFileName of dir excludes, are skipped. Repositories can therefore be migrated incrementally: legacy and new
+// resources may be mixed freely. The AddressPools are read and converted according to opts.
+func GenerateManifests(scheme *runtime.Scheme, dir string, w io.Writer, opts Options) error {
+	var pools []sourcedAddressPool
+	var passthrough [][]byte
+	ignore, err := loadIgnoreFile(dir)
+	if err != nil {
+		return err
+	}
+	err = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		source, _ := filepath.Rel(dir, p)
+		if p != dir && (strings.HasPrefix(d.Name(), ".") || ignore.ignores(source, d.IsDir())) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || !manifestExtensions[filepath.Ext(p)] {
+			return nil
+		}
+		content, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		elements, err := splitDocuments(content)
+		if err != nil {
+			return fmt.Errorf("invalid file %s, err: %w", source, err)
+		}
+		for _, element := range elements {
+			if len(bytes.TrimSpace(element)) == 0 {
+				continue
+			}
+			legacy, err := isLegacyDocument(element, opts.sourceAPIGroup())
+			if err != nil {
+				return fmt.Errorf("invalid document in %s, err: %w", source, err)
+			}
+			if !legacy {
+				document, err := yaml.JSONToYAML(element)
+				if err != nil {
+					return fmt.Errorf("invalid document in %s, err: %w", source, err)
+				}
+				passthrough = append(passthrough, document)
+				continue
+			}
+			decoded, err := decodeLegacyContent(scheme, element, source, opts)
+			if err != nil {
+				return err
+			}
+			pools = append(pools, decoded.pools...)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("could not read manifests, err: %w", err)
+	}
+	items, err := resolveDuplicates(pools, opts.DuplicatePolicy)
+	if err != nil {
+		return fmt.Errorf("could not read manifests, err: %w", err)
+	}
+	legacyObjects := &LegacyObjects{AddressPoolList: &metallbv1beta1.AddressPoolList{Items: items}}
+	if !quiet {
+		for _, group := range IdenticalAddressPools(legacyObjects) {
+			log.Printf("AddressPools %s have identical addresses, consider merging them", strings.Join(group, ", "))
+		}
+	}
+	currentObjects, err := legacyObjects.Convert(opts)
+	if err == nil {
+		err = currentObjects.checkLocalPrefs(opts.FailOnLocalPrefConflicts)
+	}
+	if err == nil {
+		err = currentObjects.validateSchema(opts.ValidateAgainst)
+	}
+	if err != nil {
+		return fmt.Errorf("error during conversion step, err: %w", err)
+	}
+	buf := &bytes.Buffer{}
+	if err := currentObjects.Encode(buf, false, opts); err != nil {
+		return err
+	}
+	for _, document := range passthrough {
+		if buf.Len() > 0 {
+			buf.WriteString("---\n")
+		}
+		buf.Write(document)
+	}
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// isLegacyDocument returns true if the YAML or JSON document is a legacy AddressPool, an AddressPoolList or a v1 List
+// that holds at least one of those, in sourceAPIGroup.
+func isLegacyDocument(document []byte, sourceAPIGroup string) (bool, error) {
+	typeMeta := metav1.TypeMeta{}
+	if err := yaml.Unmarshal(document, &typeMeta); err != nil {
+		return false, err
+	}
+	if typeMeta.APIVersion == "v1" && typeMeta.Kind == "List" {
+		list := struct {
+			Items []metav1.TypeMeta `json:"items"`
+		}{}
+		if err := yaml.Unmarshal(document, &list); err != nil {
+			return false, err
+		}
+		for _, item := range list.Items {
+			if isLegacyTypeMeta(item, sourceAPIGroup) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	return isLegacyTypeMeta(typeMeta, sourceAPIGroup), nil
+}
+
+// isLegacyTypeMeta returns true if typeMeta describes a legacy AddressPool or AddressPoolList in sourceAPIGroup.
+func isLegacyTypeMeta(typeMeta metav1.TypeMeta, sourceAPIGroup string) bool {
+	group, version, found := strings.Cut(typeMeta.APIVersion, "/")
+	if !found || group != sourceAPIGroup {
+		return false
+	}
+	if _, ok := supportedLegacyGKVVersions[version]; !ok {
+		return false
+	}
+	return typeMeta.Kind == "AddressPool" || typeMeta.Kind == "AddressPoolList"
+}
@@ -0,0 +1,58 @@
+package converter
+
+import (
+	"bytes"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestGenerateManifests(t *testing.T) {
+	var scheme = runtime.NewScheme()
+	if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestGenerateManifests: error adding to scheme, err: %q", err)
+	}
+	dir := t.TempDir()
+	files := map[string]string{
+		"legacy.yaml": "apiVersion: metallb.io/v1beta1\nkind: AddressPool\nmetadata:\n  name: ap-l2\n" +
+			"  namespace: metallb-system\nspec:\n  protocol: layer2\n  addresses:\n  - 192.168.0.0/24\n---\n" +
+			"apiVersion: v1\nkind: Service\nmetadata:\n  name: svc\n",
+		"apps/migrated.json": `{"apiVersion": "metallb.io/v1beta1", "kind": "IPAddressPool", ` +
+			`"metadata": {"name": "migrated", "namespace": "metallb-system"}, ` +
+			`"spec": {"addresses": ["10.0.0.0/24"]}}`,
+		".git/ignored.yaml": "apiVersion: metallb.io/v1beta1\nkind: AddressPool\nmetadata:\n  name: hidden\n",
+		"README.md":         "# not a manifest\n",
+	}
+	for name, content := range files {
+		if err := os.MkdirAll(path.Dir(path.Join(dir, name)), 0755); err != nil {
+			t.Fatalf("TestGenerateManifests: cannot create directory, err: %q", err)
+		}
+		if err := os.WriteFile(path.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatalf("TestGenerateManifests: cannot write file, err: %q", err)
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	if err := GenerateManifests(scheme, dir, buf, Options{}); err != nil {
+		t.Fatalf("TestGenerateManifests: unexpected error, err: %q", err)
+	}
+	output := buf.String()
+	for _, expected := range []string{"name: ap-l2\n", "kind: L2Advertisement",
+		"kind: Service", "name: migrated"} {
+		if !strings.Contains(output, expected) {
+			t.Fatalf("TestGenerateManifests: expected output to contain %q, got:\n%s", expected, output)
+		}
+	}
+	for _, unexpected := range []string{"kind: AddressPool\n", "name: hidden", "not a manifest"} {
+		if strings.Contains(output, unexpected) {
+			t.Fatalf("TestGenerateManifests: expected output not to contain %q, got:\n%s", unexpected, output)
+		}
+	}
+	if separators := strings.Count(output, "---\n"); separators != 3 {
+		t.Fatalf("TestGenerateManifests: expected 4 documents, got %d:\n%s", separators+1, output)
+	}
+}
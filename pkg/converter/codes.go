@@ -0,0 +1,83 @@
+package converter
+
+import "errors"
+
+// ErrorCode is a stable, machine-readable identifier of a class of failures, e.g. for runbooks and automation.
+type ErrorCode string
+
+// The error codes. Codes and exit statuses never change once released.
+const (
+	ErrorCodeUnknown             ErrorCode = "UNKNOWN"
+	ErrorCodeUnsupportedKind     ErrorCode = "UNSUPPORTED_KIND"
+	ErrorCodeInvalidProtocol     ErrorCode = "INVALID_PROTOCOL"
+	ErrorCodeInvalidAddresses    ErrorCode = "INVALID_ADDRESSES"
+	ErrorCodeSchemaViolation     ErrorCode = "SCHEMA_VIOLATION"
+	ErrorCodeServiceIPsUncovered ErrorCode = "SERVICE_IPS_UNCOVERED"
+	ErrorCodeStrictValidation    ErrorCode = "STRICT_VALIDATION"
+	ErrorCodeNameCollision       ErrorCode = "NAME_COLLISION"
+	ErrorCodeWebhookRejected     ErrorCode = "WEBHOOK_REJECTED"
+	ErrorCodeVerificationFailed  ErrorCode = "VERIFICATION_FAILED"
+	ErrorCodeDriftDetected       ErrorCode = "DRIFT_DETECTED"
+	ErrorCodeMigrationStopped    ErrorCode = "MIGRATION_STOPPED"
+	ErrorCodeInjectedFailure     ErrorCode = "INJECTED_FAILURE"
+	ErrorCodeSyncConflict        ErrorCode = "SYNC_CONFLICT"
+	ErrorCodeBackupDirUnusable   ErrorCode = "BACKUP_DIR_UNUSABLE"
+	ErrorCodeGoldenMismatch      ErrorCode = "GOLDEN_MISMATCH"
+	ErrorCodeClusterMismatch     ErrorCode = "CLUSTER_MISMATCH"
+	ErrorCodePreflightFailed     ErrorCode = "PREFLIGHT_FAILED"
+)
+
+// errorClass maps a sentinel error to its code and the exit status of the command line tool.
+type errorClass struct {
+	err      error
+	code     ErrorCode
+	exitCode int
+}
+
+// errorClasses is checked in order, the first class whose sentinel error matches wins.
+var errorClasses = []errorClass{
+	{err: ErrUnsupportedKind, code: ErrorCodeUnsupportedKind, exitCode: 10},
+	{err: ErrInvalidProtocol, code: ErrorCodeInvalidProtocol, exitCode: 11},
+	{err: ErrInvalidAddresses, code: ErrorCodeInvalidAddresses, exitCode: 12},
+	{err: ErrSchemaViolation, code: ErrorCodeSchemaViolation, exitCode: 13},
+	{err: ErrServiceIPsUncovered, code: ErrorCodeServiceIPsUncovered, exitCode: 14},
+	{err: ErrStrictValidation, code: ErrorCodeStrictValidation, exitCode: 15},
+	{err: ErrNameCollision, code: ErrorCodeNameCollision, exitCode: 16},
+	{err: ErrWebhookRejected, code: ErrorCodeWebhookRejected, exitCode: 20},
+	{err: ErrVerificationFailed, code: ErrorCodeVerificationFailed, exitCode: 21},
+	{err: ErrDriftDetected, code: ErrorCodeDriftDetected, exitCode: 22},
+	{err: ErrMigrationStopped, code: ErrorCodeMigrationStopped, exitCode: 23},
+	{err: ErrInjectedFailure, code: ErrorCodeInjectedFailure, exitCode: 24},
+	{err: ErrSyncConflict, code: ErrorCodeSyncConflict, exitCode: 25},
+	{err: ErrBackupDirUnusable, code: ErrorCodeBackupDirUnusable, exitCode: 26},
+	{err: ErrGoldenMismatch, code: ErrorCodeGoldenMismatch, exitCode: 27},
+	{err: ErrClusterMismatch, code: ErrorCodeClusterMismatch, exitCode: 28},
+	{err: ErrPreflightFailed, code: ErrorCodePreflightFailed, exitCode: 29},
+}
+
+// Code returns the ErrorCode of err, ErrorCodeUnknown if err does not belong to a known class and "" if err is nil.
+func Code(err error) ErrorCode {
+	if err == nil {
+		return ""
+	}
+	for _, class := range errorClasses {
+		if errors.Is(err, class.err) {
+			return class.code
+		}
+	}
+	return ErrorCodeUnknown
+}
+
+// ExitCode returns the exit status of the command line tool for err: 0 if err is nil, 1 if err does not belong to a
+// known class.
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	for _, class := range errorClasses {
+		if errors.Is(err, class.err) {
+			return class.exitCode
+		}
+	}
+	return 1
+}
@@ -0,0 +1,52 @@
+package converter
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestCodes(t *testing.T) {
+	tcs := map[string]struct {
+		err              error
+		expectedCode     ErrorCode
+		expectedExitCode int
+	}{
+		"no error": {},
+		"unknown": {
+			err:              errors.New("boom"),
+			expectedCode:     ErrorCodeUnknown,
+			expectedExitCode: 1,
+		},
+		"wrapped sentinel": {
+			err:              fmt.Errorf("error during conversion step, err: %w", ErrServiceIPsUncovered),
+			expectedCode:     ErrorCodeServiceIPsUncovered,
+			expectedExitCode: 14,
+		},
+		"webhook": {
+			err:              fmt.Errorf("cannot create, err: %w", &WebhookError{Err: errors.New("denied")}),
+			expectedCode:     ErrorCodeWebhookRejected,
+			expectedExitCode: 20,
+		},
+		"stopped": {
+			err:              fmt.Errorf("%w before AddressPool a/b", ErrMigrationStopped),
+			expectedCode:     ErrorCodeMigrationStopped,
+			expectedExitCode: 23,
+		},
+	}
+	for desc, tc := range tcs {
+		if code := Code(tc.err); code != tc.expectedCode {
+			t.Fatalf("TestCodes(%s): expected code %q but got %q", desc, tc.expectedCode, code)
+		}
+		if exitCode := ExitCode(tc.err); exitCode != tc.expectedExitCode {
+			t.Fatalf("TestCodes(%s): expected exit code %d but got %d", desc, tc.expectedExitCode, exitCode)
+		}
+	}
+	codes, exitCodes := map[ErrorCode]bool{}, map[int]bool{}
+	for _, class := range errorClasses {
+		if codes[class.code] || exitCodes[class.exitCode] || class.exitCode <= 1 {
+			t.Fatalf("TestCodes: code %q or exit code %d is not unique", class.code, class.exitCode)
+		}
+		codes[class.code], exitCodes[class.exitCode] = true, true
+	}
+}
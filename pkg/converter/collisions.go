@@ -0,0 +1,218 @@
+package converter
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// NameCollisionsFail refuses to print or create converted objects whose names collide.
+	NameCollisionsFail = "fail"
+	// NameCollisionsSuffix renames the colliding objects with the first free -2, -3, ... suffix in order of
+	// appearance. IPAddressPools that are generated more than once still fail, the advertisements that reference them
+	// would be ambiguous.
+	NameCollisionsSuffix = "suffix"
+)
+
+// SupportedNameCollisionPolicies lists all policies that can be set as Options.NameCollisionPolicy.
+var SupportedNameCollisionPolicies = []string{NameCollisionsFail, NameCollisionsSuffix}
+
+// namedObject is a converted object together with its kind.
+type namedObject struct {
+	kind string
+	obj  client.Object
+}
+
+// key returns the kind, namespace and name of the object.
+func (o namedObject) key() string {
+	return objectKey(o.kind, o.obj.GetNamespace(), o.obj.GetName())
+}
+
+// objectKey returns the identifier of an object of kind.
+func objectKey(kind, namespace, name string) string {
+	return fmt.Sprintf("%s %s/%s", kind, namespace, name)
+}
+
+// namedObjects returns pointers to all objects, so that they can be renamed in place.
+func (c *CurrentObjects) namedObjects() []namedObject {
+	var objects []namedObject
+	for i := range c.IPAddressPoolList.Items {
+		objects = append(objects, namedObject{kind: "IPAddressPool", obj: &c.IPAddressPoolList.Items[i]})
+	}
+	for i := range c.L2AdvertisementList.Items {
+		objects = append(objects, namedObject{kind: "L2Advertisement", obj: &c.L2AdvertisementList.Items[i]})
+	}
+	for i := range c.BGPAdvertisementList.Items {
+		objects = append(objects, namedObject{kind: "BGPAdvertisement", obj: &c.BGPAdvertisementList.Items[i]})
+	}
+	for i := range c.CommunityList.Items {
+		objects = append(objects, namedObject{kind: "Community", obj: &c.CommunityList.Items[i]})
+	}
+	return objects
+}
+
+// freeName returns the first name of the form <name>-2, <name>-3, ... that is not taken for kind in namespace.
+func freeName(kind, namespace, name string, taken map[string]bool) string {
+	for n := 2; ; n++ {
+		candidate := fmt.Sprintf("%s-%d", name, n)
+		if !taken[objectKey(kind, namespace, candidate)] {
+			return candidate
+		}
+	}
+}
+
+// resolveNameCollisions fails with ErrNameCollision if objects of the same kind, namespace and name were generated
+// more than once, or renames all but the first if policy is NameCollisionsSuffix.
+func (c *CurrentObjects) resolveNameCollisions(policy string) error {
+	objects := c.namedObjects()
+	taken := map[string]bool{}
+	for _, o := range objects {
+		taken[o.key()] = true
+	}
+	seen := map[string]bool{}
+	var collisions []string
+	for _, o := range objects {
+		if !seen[o.key()] {
+			seen[o.key()] = true
+			continue
+		}
+		if policy != NameCollisionsSuffix || o.kind == "IPAddressPool" {
+			collisions = append(collisions, fmt.Sprintf("%s is generated more than once", o.key()))
+			continue
+		}
+		name := freeName(o.kind, o.obj.GetNamespace(), o.obj.GetName(), taken)
+		if !quiet {
+			log.Printf("renaming the colliding %s to %s", o.key(), name)
+		}
+		o.obj.SetName(name)
+		taken[o.key()], seen[o.key()] = true, true
+	}
+	if len(collisions) > 0 {
+		return fmt.Errorf("%w:\n%s", ErrNameCollision, strings.Join(collisions, "\n"))
+	}
+	return nil
+}
+
+// resolveExistingNames fails with ErrNameCollision if IPAddressPools or advertisements that the conversion generated
+// already exist in the Namespace of opts, or renames them with NameCollisionsSuffix. The advertisements of a renamed
+// IPAddressPool are updated to reference its new name. Communities are merged into existing ones and not checked.
+func (c *CurrentObjects) resolveExistingNames(ctx context.Context, cl client.Client, opts Options) error {
+	taken := map[string]bool{}
+	lists := []struct {
+		kind string
+		list client.ObjectList
+	}{
+		{kind: "IPAddressPool", list: &metallbv1beta1.IPAddressPoolList{}},
+		{kind: "L2Advertisement", list: &metallbv1beta1.L2AdvertisementList{}},
+		{kind: "BGPAdvertisement", list: &metallbv1beta1.BGPAdvertisementList{}},
+	}
+	for _, l := range lists {
+		if err := cl.List(ctx, l.list, client.InNamespace(opts.Namespace)); err != nil {
+			return fmt.Errorf("cannot list %ss, err: %w", l.kind, err)
+		}
+		err := meta.EachListItem(l.list, func(obj runtime.Object) error {
+			accessor, err := meta.Accessor(obj)
+			if err == nil {
+				taken[objectKey(l.kind, accessor.GetNamespace(), accessor.GetName())] = true
+			}
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("cannot read %ss, err: %w", l.kind, err)
+		}
+	}
+	objects := c.namedObjects()
+	existing := map[string]bool{}
+	for _, o := range objects {
+		existing[o.key()] = taken[o.key()]
+		taken[o.key()] = true
+	}
+	var collisions []string
+	for _, o := range objects {
+		if o.kind == "Community" || !existing[o.key()] {
+			continue
+		}
+		if opts.NameCollisionPolicy != NameCollisionsSuffix {
+			collisions = append(collisions, fmt.Sprintf("%s already exists in the cluster", o.key()))
+			continue
+		}
+		namespace, oldName := o.obj.GetNamespace(), o.obj.GetName()
+		name := freeName(o.kind, namespace, oldName, taken)
+		taken[objectKey(o.kind, namespace, name)] = true
+		o.obj.SetName(name)
+		if o.kind == "IPAddressPool" {
+			c.renamePoolReferences(namespace, oldName, name)
+			if !quiet {
+				log.Printf("IPAddressPool %s/%s already exists in the cluster, renaming it to %s, update Services "+
+					"that request it with the %s annotation", namespace, oldName, name, ServiceAddressPoolAnnotation)
+			}
+		} else if !quiet {
+			log.Printf("%s already exists in the cluster, renaming it to %s", objectKey(o.kind, namespace, oldName),
+				name)
+		}
+	}
+	if len(collisions) > 0 {
+		return fmt.Errorf("%w:\n%s\nrename them, take them over with server-side apply or rename the converted "+
+			"objects with the %s name collision policy", ErrNameCollision, strings.Join(collisions, "\n"),
+			NameCollisionsSuffix)
+	}
+	return nil
+}
+
+// renamePoolReferences replaces the references of the advertisements of namespace to the IPAddressPool oldName with
+// name.
+func (c *CurrentObjects) renamePoolReferences(namespace, oldName, name string) {
+	rename := func(pools []string) []string {
+		renamed := make([]string, 0, len(pools))
+		for _, pool := range pools {
+			if pool == oldName {
+				pool = name
+			}
+			renamed = append(renamed, pool)
+		}
+		return renamed
+	}
+	for i := range c.L2AdvertisementList.Items {
+		if l2a := &c.L2AdvertisementList.Items[i]; l2a.Namespace == namespace {
+			l2a.Spec.IPAddressPools = rename(l2a.Spec.IPAddressPools)
+		}
+	}
+	for i := range c.BGPAdvertisementList.Items {
+		if ba := &c.BGPAdvertisementList.Items[i]; ba.Namespace == namespace {
+			ba.Spec.IPAddressPools = rename(ba.Spec.IPAddressPools)
+		}
+	}
+}
+
+// checkExistingNames runs resolveExistingNames on the objects of the pending AddressPools of an online migration
+// before anything is modified. Nothing is checked with server-side apply, which takes existing objects over, and the
+// AddressPools whose migration state is in progress are skipped, the interrupted migration may have created their
+// objects.
+func checkExistingNames(ctx context.Context, cl client.Client, pending *LegacyObjects, pendingObjects *CurrentObjects,
+	state *migrationState, opts Options) error {
+	if opts.ServerSideApply {
+		return nil
+	}
+	var fresh []metallbv1beta1.AddressPool
+	for _, ap := range pending.AddressPoolList.Items {
+		if pool := state.lookup(ap.Namespace, ap.Name); pool == nil || pool.State != StateInProgress {
+			fresh = append(fresh, ap)
+		}
+	}
+	objects := pendingObjects
+	if len(fresh) != len(pending.AddressPoolList.Items) {
+		var err error
+		objects, err = (&LegacyObjects{AddressPoolList: &metallbv1beta1.AddressPoolList{Items: fresh}}).Convert(opts)
+		if err != nil {
+			return err
+		}
+	}
+	return objects.resolveExistingNames(ctx, cl, opts)
+}
@@ -0,0 +1,196 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestResolveNameCollisions(t *testing.T) {
+	bgpAdvertisement := func(name string) metallbv1beta1.BGPAdvertisement {
+		return metallbv1beta1.BGPAdvertisement{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "metallb-system"}}
+	}
+	tcs := map[string]struct {
+		policy              string
+		pools               []string
+		advertisements      []string
+		expectedNames       []string
+		expectedErrorString string
+	}{
+		"no collisions": {
+			policy:         NameCollisionsFail,
+			pools:          []string{"a", "b"},
+			advertisements: []string{"a-bgp-advertisement-0", "b-bgp-advertisement-0"},
+			expectedNames:  []string{"a-bgp-advertisement-0", "b-bgp-advertisement-0"},
+		},
+		"advertisement collision fails": {
+			policy:              NameCollisionsFail,
+			pools:               []string{"a"},
+			advertisements:      []string{"a-bgp-advertisement-0", "a-bgp-advertisement-0"},
+			expectedErrorString: "BGPAdvertisement metallb-system/a-bgp-advertisement-0 is generated more than once",
+		},
+		"advertisement collision gets the first free suffix": {
+			policy:         NameCollisionsSuffix,
+			pools:          []string{"a"},
+			advertisements: []string{"adv", "adv-2", "adv", "adv"},
+			expectedNames:  []string{"adv", "adv-2", "adv-3", "adv-4"},
+		},
+		"pool collision fails with suffix": {
+			policy:              NameCollisionsSuffix,
+			pools:               []string{"a", "a"},
+			expectedErrorString: "IPAddressPool metallb-system/a is generated more than once",
+		},
+	}
+	SetQuiet(true)
+	defer SetQuiet(false)
+	for desc, tc := range tcs {
+		currentObjects := &CurrentObjects{
+			IPAddressPoolList:    &metallbv1beta1.IPAddressPoolList{},
+			L2AdvertisementList:  &metallbv1beta1.L2AdvertisementList{},
+			BGPAdvertisementList: &metallbv1beta1.BGPAdvertisementList{},
+			CommunityList:        &metallbv1beta1.CommunityList{},
+		}
+		for _, name := range tc.pools {
+			currentObjects.IPAddressPoolList.Items = append(currentObjects.IPAddressPoolList.Items,
+				metallbv1beta1.IPAddressPool{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "metallb-system"}})
+		}
+		for _, name := range tc.advertisements {
+			currentObjects.BGPAdvertisementList.Items = append(currentObjects.BGPAdvertisementList.Items,
+				bgpAdvertisement(name))
+		}
+		err := currentObjects.resolveNameCollisions(tc.policy)
+		if tc.expectedErrorString != "" {
+			if !errors.Is(err, ErrNameCollision) || !strings.Contains(err.Error(), tc.expectedErrorString) {
+				t.Fatalf("TestResolveNameCollisions(%s): expected error %q but got %v", desc, tc.expectedErrorString,
+					err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("TestResolveNameCollisions(%s): unexpected error, err: %q", desc, err)
+		}
+		var names []string
+		for _, ba := range currentObjects.BGPAdvertisementList.Items {
+			names = append(names, ba.Name)
+		}
+		if !reflect.DeepEqual(names, tc.expectedNames) {
+			t.Fatalf("TestResolveNameCollisions(%s): expected names %v but got %v", desc, tc.expectedNames, names)
+		}
+	}
+}
+
+func TestConvertSplitPoolNameCollision(t *testing.T) {
+	// Splitting AddressPool a per range yields IPAddressPool a-1, which the AddressPool a-1 yields as well.
+	legacyObjects := &LegacyObjects{AddressPoolList: &metallbv1beta1.AddressPoolList{
+		Items: []metallbv1beta1.AddressPool{
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "a", Namespace: "metallb-system"},
+				Spec: metallbv1beta1.AddressPoolSpec{
+					Protocol:  ProtocolLayer2,
+					Addresses: []string{"192.168.0.0/24", "192.168.1.0/24"},
+				},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "a-1", Namespace: "metallb-system"},
+				Spec:       metallbv1beta1.AddressPoolSpec{Protocol: ProtocolLayer2, Addresses: []string{"10.0.0.0/24"}},
+			},
+		},
+	}}
+	SetQuiet(true)
+	defer SetQuiet(false)
+	_, err := legacyObjects.Convert(Options{SplitPoolsPerRange: true})
+	if !errors.Is(err, ErrNameCollision) || !strings.Contains(err.Error(), "IPAddressPool metallb-system/a-1") {
+		t.Fatalf("TestConvertSplitPoolNameCollision: expected a name collision of IPAddressPool a-1 but got %v", err)
+	}
+	if code := Code(err); code != ErrorCodeNameCollision {
+		t.Fatalf("TestConvertSplitPoolNameCollision: expected code %q but got %q", ErrorCodeNameCollision, code)
+	}
+}
+
+func TestOnlineMigrationNameCollisions(t *testing.T) {
+	tcs := map[string]struct {
+		policy              string
+		expectedPool        string
+		expectedErrorString string
+	}{
+		"existing IPAddressPool fails before anything is modified": {
+			policy:              NameCollisionsFail,
+			expectedErrorString: "IPAddressPool metallb-system/ap-l2 already exists in the cluster",
+		},
+		"existing IPAddressPool is suffixed": {
+			policy:       NameCollisionsSuffix,
+			expectedPool: "ap-l2-2",
+		},
+	}
+	var scheme = runtime.NewScheme()
+	if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestOnlineMigrationNameCollisions: error adding to scheme, err: %q", err)
+	}
+	SetQuiet(true)
+	defer SetQuiet(false)
+	stdout = bytes.NewBuffer([]byte{})
+	for desc, tc := range tcs {
+		c := fake.NewClientBuilder().WithScheme(scheme).Build()
+		for _, ap := range validAddressPools0 {
+			if err := c.Create(context.TODO(), ap.DeepCopy()); err != nil {
+				t.Fatalf("TestOnlineMigrationNameCollisions(%s): error building fake client, err: %q", desc, err)
+			}
+		}
+		// An unrelated IPAddressPool that was created by hand.
+		unrelated := &metallbv1beta1.IPAddressPool{
+			ObjectMeta: metav1.ObjectMeta{Name: "ap-l2", Namespace: "metallb-system"},
+			Spec:       metallbv1beta1.IPAddressPoolSpec{Addresses: []string{"10.0.0.0/24"}},
+		}
+		if err := c.Create(context.TODO(), unrelated); err != nil {
+			t.Fatalf("TestOnlineMigrationNameCollisions(%s): error building fake client, err: %q", desc, err)
+		}
+		err := OnlineMigration(context.TODO(), c, scheme, t.TempDir(), false, OnlineMigrationOptions{
+			Options: Options{NameCollisionPolicy: tc.policy},
+		})
+		if tc.expectedErrorString != "" {
+			if !errors.Is(err, ErrNameCollision) || !strings.Contains(err.Error(), tc.expectedErrorString) {
+				t.Fatalf("TestOnlineMigrationNameCollisions(%s): expected error %q but got %v", desc,
+					tc.expectedErrorString, err)
+			}
+			addressPoolList := &metallbv1beta1.AddressPoolList{}
+			if err := c.List(context.TODO(), addressPoolList); err != nil {
+				t.Fatalf("TestOnlineMigrationNameCollisions(%s): cannot list AddressPools, err: %q", desc, err)
+			}
+			if len(addressPoolList.Items) != len(validAddressPools0) {
+				t.Fatalf("TestOnlineMigrationNameCollisions(%s): expected all AddressPools to be kept but got %d",
+					desc, len(addressPoolList.Items))
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("TestOnlineMigrationNameCollisions(%s): unexpected error, err: %q", desc, err)
+		}
+		iap := &metallbv1beta1.IPAddressPool{}
+		key := client.ObjectKey{Namespace: "metallb-system", Name: tc.expectedPool}
+		if err := c.Get(context.TODO(), key, iap); err != nil {
+			t.Fatalf("TestOnlineMigrationNameCollisions(%s): cannot get IPAddressPool %s, err: %q", desc,
+				tc.expectedPool, err)
+		}
+		if err := c.Get(context.TODO(), client.ObjectKeyFromObject(unrelated), iap); err != nil ||
+			!reflect.DeepEqual(iap.Spec.Addresses, unrelated.Spec.Addresses) {
+			t.Fatalf("TestOnlineMigrationNameCollisions(%s): expected the unrelated IPAddressPool to be unchanged "+
+				"but got %v, err: %v", desc, iap.Spec.Addresses, err)
+		}
+		l2a := &metallbv1beta1.L2Advertisement{}
+		key = client.ObjectKey{Namespace: "metallb-system", Name: "ap-l2-l2-advertisement"}
+		if err := c.Get(context.TODO(), key, l2a); err != nil ||
+			!reflect.DeepEqual(l2a.Spec.IPAddressPools, []string{tc.expectedPool}) {
+			t.Fatalf("TestOnlineMigrationNameCollisions(%s): expected the L2Advertisement to reference %s but got "+
+				"%+v, err: %v", desc, tc.expectedPool, l2a.Spec, err)
+		}
+	}
+}
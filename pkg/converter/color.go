@@ -0,0 +1,89 @@
+package converter
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// NoColorEnvVar disables colored output if it is set to any non-empty value, see https://no-color.org.
+const NoColorEnvVar = "NO_COLOR"
+
+const (
+	colorReset  = "\x1b[0m"
+	colorRed    = "\x1b[31m"
+	colorGreen  = "\x1b[32m"
+	colorYellow = "\x1b[33m"
+)
+
+var color bool
+
+// SetColor makes the output for humans, e.g. the actions of Confirm, use ANSI colors.
+func SetColor(enabled bool) {
+	color = enabled
+}
+
+// colorize wraps s in the ANSI color code if colored output is enabled.
+func colorize(code, s string) string {
+	if !color {
+		return s
+	}
+	return code + s + colorReset
+}
+
+// logHeader matches the date and time that the standard logger prefixes its lines with.
+var logHeader = regexp.MustCompile(`^(\d{4}/\d{2}/\d{2} )?(\d{2}:\d{2}:\d{2}(\.\d+)? )?`)
+
+// lineColors maps the prefixes of log messages to their colors. The first matching prefix wins.
+var lineColors = []struct {
+	prefix string
+	code   string
+}{
+	{prefix: "warning:", code: colorYellow},
+	{prefix: "error", code: colorRed},
+	{prefix: "could not", code: colorRed},
+	{prefix: "cannot", code: colorRed},
+	{prefix: "drift:", code: colorRed},
+	{prefix: "conflict:", code: colorRed},
+	{prefix: "replay: recorded", code: colorRed},
+	{prefix: "replay: replayed", code: colorRed},
+	{prefix: "no drift", code: colorGreen},
+	{prefix: "replay: all", code: colorGreen},
+	{prefix: "no differences", code: colorGreen},
+}
+
+// colorWriter colors the messages of the lines written to it according to lineColors.
+type colorWriter struct {
+	w io.Writer
+}
+
+// NewColorWriter returns a writer for the standard logger that colors warnings yellow, errors and differences such as
+// drift red, and the absence of differences green. The date and time of a line are not colored.
+func NewColorWriter(w io.Writer) io.Writer {
+	return &colorWriter{w: w}
+}
+
+func (cw *colorWriter) Write(p []byte) (int, error) {
+	var out bytes.Buffer
+	for _, line := range strings.SplitAfter(string(p), "\n") {
+		out.WriteString(colorLine(line))
+	}
+	if _, err := cw.w.Write(out.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// colorLine returns line with its message wrapped in the color of the first matching entry of lineColors.
+func colorLine(line string) string {
+	message := strings.TrimSuffix(line, "\n")
+	header := logHeader.FindString(message)
+	message = strings.TrimPrefix(message, header)
+	for _, lc := range lineColors {
+		if strings.HasPrefix(message, lc.prefix) {
+			return header + lc.code + message + colorReset + strings.TrimPrefix(line, header+message)
+		}
+	}
+	return line
+}
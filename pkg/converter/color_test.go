@@ -0,0 +1,64 @@
+package converter
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestColorWriter(t *testing.T) {
+	tcs := map[string]struct {
+		input    string
+		expected string
+	}{
+		"warning with header": {
+			input:    "2026/10/16 12:00:00 warning: pool a has no addresses\n",
+			expected: "2026/10/16 12:00:00 \x1b[33mwarning: pool a has no addresses\x1b[0m\n",
+		},
+		"error without header": {
+			input:    "error [UNKNOWN]: boom\n",
+			expected: "\x1b[31merror [UNKNOWN]: boom\x1b[0m\n",
+		},
+		"drift": {
+			input:    "2026/10/16 12:00:00 drift: AddressPool a/b: not migrated\n",
+			expected: "2026/10/16 12:00:00 \x1b[31mdrift: AddressPool a/b: not migrated\x1b[0m\n",
+		},
+		"no drift": {
+			input:    "2026/10/16 12:00:00 no drift between legacy and new resources detected\n",
+			expected: "2026/10/16 12:00:00 \x1b[32mno drift between legacy and new resources detected\x1b[0m\n",
+		},
+		"progress is not colored": {
+			input:    "2026/10/16 12:00:00 migrating AddressPool a/b\n",
+			expected: "2026/10/16 12:00:00 migrating AddressPool a/b\n",
+		},
+		"multiple lines": {
+			input:    "warning: a\nmigrating b\n",
+			expected: "\x1b[33mwarning: a\x1b[0m\nmigrating b\n",
+		},
+	}
+	for desc, tc := range tcs {
+		var out bytes.Buffer
+		n, err := NewColorWriter(&out).Write([]byte(tc.input))
+		if err != nil || n != len(tc.input) {
+			t.Fatalf("TestColorWriter(%s): unexpected write result %d, err: %v", desc, n, err)
+		}
+		if out.String() != tc.expected {
+			t.Fatalf("TestColorWriter(%s): expected %q but got %q", desc, tc.expected, out.String())
+		}
+	}
+}
+
+func TestConfirmColor(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		SetColor(enabled)
+		var out bytes.Buffer
+		if _, err := Confirm(strings.NewReader("n\n"), &out, []string{"delete AddressPool a/b"}); err != nil {
+			t.Fatalf("TestConfirmColor(%t): unexpected error, err: %q", enabled, err)
+		}
+		colored := strings.Contains(out.String(), colorRed+"delete AddressPool a/b"+colorReset)
+		if colored != enabled || strings.Contains(out.String(), "\x1b[") != enabled {
+			t.Fatalf("TestConfirmColor(%t): unexpected output %q", enabled, out.String())
+		}
+	}
+	SetColor(false)
+}
@@ -0,0 +1,223 @@
+package converter
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// CommunityResourceName is the name of the Community CR that holds the extracted community aliases.
+const CommunityResourceName = "metallb-converter-communities"
+
+// wellKnownCommunities maps the well-known BGP communities of RFC 1997 and RFC 3765 to their names.
+var wellKnownCommunities = map[string]string{
+	"65535:65281": "no-export",
+	"65535:65282": "no-advertise",
+	"65535:65283": "no-export-subconfed",
+	"65535:65284": "no-peer",
+}
+
+// communityAlias returns the alias name for a community value. ok is false if value is not a standard
+// (<asn>:<value>) or large (large:<asn>:<value>:<value>) community, e.g. because it already is an alias.
+func communityAlias(value string) (alias string, ok bool) {
+	if name, ok := wellKnownCommunities[value]; ok {
+		return name, true
+	}
+	fields := strings.Split(value, ":")
+	prefix := "community"
+	if len(fields) == 4 && fields[0] == "large" {
+		fields = fields[1:]
+		prefix = "large-community"
+	} else if len(fields) != 2 {
+		return "", false
+	}
+	for _, f := range fields {
+		if _, err := strconv.ParseUint(f, 10, 32); err != nil {
+			return "", false
+		}
+	}
+	return fmt.Sprintf("%s-%s", prefix, strings.Join(fields, "-")), true
+}
+
+// extractCommunities moves all distinct community values of the BGPAdvertisements into one Community CR per
+// namespace and replaces the values in the advertisements with the alias names.
+func (c *CurrentObjects) extractCommunities() {
+	aliases := map[string]map[string]string{}
+	for i, ba := range c.BGPAdvertisementList.Items {
+		for j, value := range ba.Spec.Communities {
+			alias, ok := communityAlias(value)
+			if !ok {
+				continue
+			}
+			if aliases[ba.Namespace] == nil {
+				aliases[ba.Namespace] = map[string]string{}
+			}
+			aliases[ba.Namespace][alias] = value
+			c.BGPAdvertisementList.Items[i].Spec.Communities[j] = alias
+		}
+	}
+	var namespaces []string
+	for namespace := range aliases {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+	for _, namespace := range namespaces {
+		community := metallbv1beta1.Community{
+			TypeMeta:   metav1.TypeMeta{Kind: "Community", APIVersion: metallbAPIVersion},
+			ObjectMeta: metav1.ObjectMeta{Name: CommunityResourceName, Namespace: namespace},
+		}
+		var names []string
+		for alias := range aliases[namespace] {
+			names = append(names, alias)
+		}
+		sort.Strings(names)
+		for _, alias := range names {
+			community.Spec.Communities = append(community.Spec.Communities, metallbv1beta1.CommunityAlias{
+				Name:  alias,
+				Value: aliases[namespace][alias],
+			})
+		}
+		c.CommunityList.Items = append(c.CommunityList.Items, community)
+	}
+}
+
+// createOrMergeCommunity creates community. If the Community already exists, e.g. because an earlier pool of the same
+// online migration created it, the missing aliases are added to it instead. Aliases with the same name but a
+// different value are an error.
+func createOrMergeCommunity(ctx context.Context, cl client.Client, community metallbv1beta1.Community) error {
+	err := cl.Create(ctx, &community)
+	if !apierrors.IsAlreadyExists(err) {
+		return err
+	}
+	current := &metallbv1beta1.Community{}
+	key := types.NamespacedName{Namespace: community.Namespace, Name: community.Name}
+	if err := cl.Get(ctx, key, current); err != nil {
+		return err
+	}
+	existing := map[string]string{}
+	for _, alias := range current.Spec.Communities {
+		existing[alias.Name] = alias.Value
+	}
+	changed := false
+	for _, alias := range community.Spec.Communities {
+		value, ok := existing[alias.Name]
+		if ok && value != alias.Value {
+			return fmt.Errorf("alias %q already exists with value %q instead of %q", alias.Name, value, alias.Value)
+		}
+		if !ok {
+			current.Spec.Communities = append(current.Spec.Communities, alias)
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return cl.Update(ctx, current)
+}
+
+// removeCommunityAliases removes the aliases of community from the Community with its name, and deletes that
+// Community if no other aliases are left. It is the counterpart of createOrMergeCommunity.
+func removeCommunityAliases(ctx context.Context, cl client.Client, community metallbv1beta1.Community) error {
+	current := &metallbv1beta1.Community{}
+	key := types.NamespacedName{Namespace: community.Namespace, Name: community.Name}
+	if err := cl.Get(ctx, key, current); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	removed := map[string]bool{}
+	for _, alias := range community.Spec.Communities {
+		removed[alias.Name] = true
+	}
+	var kept []metallbv1beta1.CommunityAlias
+	for _, alias := range current.Spec.Communities {
+		if !removed[alias.Name] {
+			kept = append(kept, alias)
+		}
+	}
+	if len(kept) == len(current.Spec.Communities) {
+		return nil
+	}
+	if len(kept) == 0 {
+		if err := cl.Delete(ctx, current); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		return nil
+	}
+	current.Spec.Communities = kept
+	return cl.Update(ctx, current)
+}
+
+// ResolveCommunityAliases validates that every community of the BGPAdvertisements that is an alias rather than a
+// community value is defined, either by a Community CR in the namespace of the advertisement or by the Communities of
+// c. It returns an error that lists all unknown aliases. If expand is true, aliases of existing Community CRs are
+// replaced with their values. The cluster is only queried if an alias is referenced.
+func (c *CurrentObjects) ResolveCommunityAliases(ctx context.Context, cl client.Client, expand bool) error {
+	referenced := false
+	for _, ba := range c.BGPAdvertisementList.Items {
+		for _, community := range ba.Spec.Communities {
+			if _, ok := communityAlias(community); !ok {
+				referenced = true
+			}
+		}
+	}
+	if !referenced {
+		return nil
+	}
+
+	existing := map[string]map[string]string{}
+	communityList := &metallbv1beta1.CommunityList{}
+	err := cl.List(ctx, communityList)
+	if err != nil && !meta.IsNoMatchError(err) {
+		return fmt.Errorf("failed to list Communities in cluster: %w", err)
+	}
+	for _, community := range communityList.Items {
+		for _, alias := range community.Spec.Communities {
+			if existing[community.Namespace] == nil {
+				existing[community.Namespace] = map[string]string{}
+			}
+			existing[community.Namespace][alias.Name] = alias.Value
+		}
+	}
+	generated := map[string]map[string]bool{}
+	for _, community := range c.CommunityList.Items {
+		for _, alias := range community.Spec.Communities {
+			if generated[community.Namespace] == nil {
+				generated[community.Namespace] = map[string]bool{}
+			}
+			generated[community.Namespace][alias.Name] = true
+		}
+	}
+
+	var problems []string
+	for i, ba := range c.BGPAdvertisementList.Items {
+		for j, community := range ba.Spec.Communities {
+			if _, ok := communityAlias(community); ok || generated[ba.Namespace][community] {
+				continue
+			}
+			value, ok := existing[ba.Namespace][community]
+			if !ok {
+				problems = append(problems, fmt.Sprintf("BGPAdvertisement %s/%s: unknown community alias %q",
+					ba.Namespace, ba.Name, community))
+				continue
+			}
+			if expand {
+				c.BGPAdvertisementList.Items[i].Spec.Communities[j] = value
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("community alias resolution failed:\n%s", strings.Join(problems, "\n"))
+	}
+	return nil
+}
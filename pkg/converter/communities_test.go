@@ -0,0 +1,195 @@
+package converter
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestCommunityAlias(t *testing.T) {
+	tcs := map[string]struct {
+		value         string
+		expectedAlias string
+		expectedOk    bool
+	}{
+		"well known":      {value: "65535:65282", expectedAlias: "no-advertise", expectedOk: true},
+		"standard":        {value: "65432:12345", expectedAlias: "community-65432-12345", expectedOk: true},
+		"large":           {value: "large:1:2:3", expectedAlias: "large-community-1-2-3", expectedOk: true},
+		"alias":           {value: "my-alias", expectedOk: false},
+		"invalid numbers": {value: "abc:123", expectedOk: false},
+	}
+	for desc, tc := range tcs {
+		alias, ok := communityAlias(tc.value)
+		if ok != tc.expectedOk || alias != tc.expectedAlias {
+			t.Fatalf("TestCommunityAlias(%s): expected (%q, %t) but got (%q, %t)", desc, tc.expectedAlias,
+				tc.expectedOk, alias, ok)
+		}
+	}
+}
+
+func TestExtractCommunities(t *testing.T) {
+	legacyObjects := &LegacyObjects{AddressPoolList: &metallbv1beta1.AddressPoolList{Items: validAddressPools0}}
+	currentObjects, err := legacyObjects.Convert(Options{CommunityResources: true})
+	if err != nil {
+		t.Fatalf("TestExtractCommunities: unexpected error during conversion, err: %q", err)
+	}
+	if len(currentObjects.CommunityList.Items) != 1 {
+		t.Fatalf("TestExtractCommunities: expected 1 Community but got %d", len(currentObjects.CommunityList.Items))
+	}
+	aliases := map[string]string{}
+	for _, alias := range currentObjects.CommunityList.Items[0].Spec.Communities {
+		aliases[alias.Name] = alias.Value
+	}
+	for _, ba := range currentObjects.BGPAdvertisementList.Items {
+		for _, community := range ba.Spec.Communities {
+			if _, ok := aliases[community]; !ok {
+				t.Fatalf("TestExtractCommunities: BGPAdvertisement %s references %q which is not an alias of %v",
+					ba.Name, community, aliases)
+			}
+		}
+	}
+	// The legacy objects must not be modified by the extraction.
+	for _, ap := range validAddressPools0 {
+		for _, adv := range ap.Spec.BGPAdvertisements {
+			for _, community := range adv.Communities {
+				if _, ok := communityAlias(community); !ok {
+					t.Fatalf("TestExtractCommunities: legacy AddressPool %s was modified: %v", ap.Name, adv)
+				}
+			}
+		}
+	}
+
+	// Pools are migrated one by one, the second pool that uses the Community must not fail.
+	var scheme = runtime.NewScheme()
+	if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestExtractCommunities: error adding to scheme, err: %q", err)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+	for _, ap := range validAddressPools0 {
+		if err := c.Create(context.TODO(), ap.DeepCopy()); err != nil {
+			t.Fatalf("TestExtractCommunities: error building fake client, err: %q", err)
+		}
+	}
+	opts := OnlineMigrationOptions{Options: Options{CommunityResources: true}}
+	if err := OnlineMigration(context.TODO(), c, scheme, t.TempDir(), false, opts); err != nil {
+		t.Fatalf("TestExtractCommunities: unexpected error during online migration, err: %q", err)
+	}
+	community := &metallbv1beta1.Community{}
+	key := types.NamespacedName{Namespace: "metallb-system", Name: CommunityResourceName}
+	if err := c.Get(context.TODO(), key, community); err != nil {
+		t.Fatalf("TestExtractCommunities: cannot get Community, err: %q", err)
+	}
+	if len(community.Spec.Communities) != len(aliases) {
+		t.Fatalf("TestExtractCommunities: expected aliases %v but got %v", aliases, community.Spec.Communities)
+	}
+}
+
+func TestCreateOrMergeCommunity(t *testing.T) {
+	var scheme = runtime.NewScheme()
+	if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestCreateOrMergeCommunity: error adding to scheme, err: %q", err)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+	newCommunity := func(aliases ...metallbv1beta1.CommunityAlias) metallbv1beta1.Community {
+		community := metallbv1beta1.Community{Spec: metallbv1beta1.CommunitySpec{Communities: aliases}}
+		community.Name, community.Namespace = CommunityResourceName, "metallb-system"
+		return community
+	}
+	a := metallbv1beta1.CommunityAlias{Name: "community-1-1", Value: "1:1"}
+	b := metallbv1beta1.CommunityAlias{Name: "community-2-2", Value: "2:2"}
+
+	if err := createOrMergeCommunity(context.TODO(), c, newCommunity(a)); err != nil {
+		t.Fatalf("TestCreateOrMergeCommunity: unexpected error on create, err: %q", err)
+	}
+	if err := createOrMergeCommunity(context.TODO(), c, newCommunity(a, b)); err != nil {
+		t.Fatalf("TestCreateOrMergeCommunity: unexpected error on merge, err: %q", err)
+	}
+	community := &metallbv1beta1.Community{}
+	key := types.NamespacedName{Namespace: "metallb-system", Name: CommunityResourceName}
+	if err := c.Get(context.TODO(), key, community); err != nil {
+		t.Fatalf("TestCreateOrMergeCommunity: cannot get Community, err: %q", err)
+	}
+	if len(community.Spec.Communities) != 2 {
+		t.Fatalf("TestCreateOrMergeCommunity: expected 2 aliases but got %v", community.Spec.Communities)
+	}
+	conflict := metallbv1beta1.CommunityAlias{Name: "community-1-1", Value: "3:3"}
+	if err := createOrMergeCommunity(context.TODO(), c, newCommunity(conflict)); err == nil {
+		t.Fatalf("TestCreateOrMergeCommunity: expected an error for a conflicting alias")
+	}
+}
+
+func TestResolveCommunityAliases(t *testing.T) {
+	existing := &metallbv1beta1.Community{Spec: metallbv1beta1.CommunitySpec{
+		Communities: []metallbv1beta1.CommunityAlias{{Name: "known", Value: "1:1"}},
+	}}
+	existing.Name, existing.Namespace = "communities", "metallb-system"
+
+	tcs := map[string]struct {
+		communities         []string
+		extract             bool
+		expand              bool
+		expectedCommunities []string
+		expectedErrorString string
+	}{
+		"values only": {
+			communities:         []string{"65432:12345"},
+			expectedCommunities: []string{"65432:12345"},
+		},
+		"known alias": {
+			communities:         []string{"known", "2:2"},
+			expectedCommunities: []string{"known", "2:2"},
+		},
+		"known alias expanded": {
+			communities:         []string{"known", "2:2"},
+			expand:              true,
+			expectedCommunities: []string{"1:1", "2:2"},
+		},
+		"unknown alias": {
+			communities:         []string{"unknown"},
+			expectedErrorString: `unknown community alias "unknown"`,
+		},
+		"generated alias": {
+			communities:         []string{"known", "2:2"},
+			extract:             true,
+			expand:              true,
+			expectedCommunities: []string{"1:1", "community-2-2"},
+		},
+	}
+	for desc, tc := range tcs {
+		var scheme = runtime.NewScheme()
+		if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+			t.Fatalf("TestResolveCommunityAliases(%s): error adding to scheme, err: %q", desc, err)
+		}
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(existing.DeepCopy()).Build()
+		ap := *validAddressPools0[2].DeepCopy()
+		ap.Spec.BGPAdvertisements = []metallbv1beta1.LegacyBgpAdvertisement{{Communities: tc.communities}}
+		legacyObjects := &LegacyObjects{AddressPoolList: &metallbv1beta1.AddressPoolList{
+			Items: []metallbv1beta1.AddressPool{ap},
+		}}
+		currentObjects, err := legacyObjects.Convert(Options{CommunityResources: tc.extract})
+		if err == nil {
+			err = currentObjects.ResolveCommunityAliases(context.TODO(), c, tc.expand)
+		}
+		if tc.expectedErrorString != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.expectedErrorString) {
+				t.Fatalf("TestResolveCommunityAliases(%s): expected error %q but got %v", desc,
+					tc.expectedErrorString, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("TestResolveCommunityAliases(%s): unexpected error, err: %q", desc, err)
+		}
+		got := currentObjects.BGPAdvertisementList.Items[0].Spec.Communities
+		if !reflect.DeepEqual(got, tc.expectedCommunities) {
+			t.Fatalf("TestResolveCommunityAliases(%s): expected communities %v but got %v", desc,
+				tc.expectedCommunities, got)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package converter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// ComplianceNotMigrated is the status of a legacy AddressPool whose IPAddressPool does not exist.
+	ComplianceNotMigrated = "not-migrated"
+	// ComplianceEquivalent is the status of a legacy AddressPool whose converted resources match the live ones.
+	ComplianceEquivalent = "equivalent"
+	// ComplianceDiffers is the status of a legacy AddressPool whose converted resources differ from the live ones.
+	ComplianceDiffers = "differs"
+	// ComplianceUnconvertible is the status of a legacy AddressPool that cannot be converted.
+	ComplianceUnconvertible = "unconvertible"
+)
+
+const (
+	// legacyConfigMapName is the name of the legacy MetalLB ConfigMap that CheckCompliance looks for.
+	legacyConfigMapName = "config"
+	// legacyConfigMapNamespace is the namespace in which CheckCompliance looks for a legacy MetalLB ConfigMap, in
+	// addition to the namespaces of the legacy AddressPools.
+	legacyConfigMapNamespace = "metallb-system"
+)
+
+// ComplianceReport is the read-only assessment of a cluster that CheckCompliance produces.
+type ComplianceReport struct {
+	Timestamp        time.Time            `json:"timestamp"`
+	Summary          ComplianceSummary    `json:"summary"`
+	LegacyResources  []ComplianceResource `json:"legacyResources"`
+	CurrentResources []ComplianceResource `json:"currentResources"`
+	Pools            []PoolCompliance     `json:"pools"`
+	// Deprecations lists every use of a deprecated resource or field.
+	Deprecations []string `json:"deprecations"`
+}
+
+// ComplianceSummary counts the legacy AddressPools by their status.
+type ComplianceSummary struct {
+	LegacyAddressPools int `json:"legacyAddressPools"`
+	NotMigrated        int `json:"notMigrated"`
+	Equivalent         int `json:"equivalent"`
+	Differs            int `json:"differs"`
+	Unconvertible      int `json:"unconvertible"`
+	Deprecations       int `json:"deprecations"`
+}
+
+// ComplianceResource identifies a resource of the report.
+type ComplianceResource struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+// PoolCompliance is the status of a single legacy AddressPool, with the differences between its converted resources
+// and the live ones if they are not equivalent.
+type PoolCompliance struct {
+	Namespace   string   `json:"namespace"`
+	Name        string   `json:"name"`
+	Status      string   `json:"status"`
+	Differences []string `json:"differences,omitempty"`
+}
+
+// CheckCompliance reports which legacy and which new MetalLB resources exist in the cluster, whether every legacy
+// AddressPool is equivalent to the resources that it was migrated to, and which deprecated resources and fields are
+// still in use. It makes no changes. Kinds whose CRD is not installed are treated as empty. The AddressPools are
+// converted according to opts.
+func CheckCompliance(ctx context.Context, cl client.Client, opts Options) (*ComplianceReport, error) {
+	report := &ComplianceReport{
+		Timestamp:        time.Now().UTC(),
+		LegacyResources:  []ComplianceResource{},
+		CurrentResources: []ComplianceResource{},
+		Pools:            []PoolCompliance{},
+		Deprecations:     []string{},
+	}
+	addressPoolList := &metallbv1beta1.AddressPoolList{}
+	if err := cl.List(ctx, addressPoolList); err != nil && !meta.IsNoMatchError(err) {
+		return nil, fmt.Errorf("failed to list AddressPools in cluster: %w", err)
+	}
+	current, err := ReadDowngradeObjectsFromAPI(ctx, cl)
+	if err != nil {
+		return nil, err
+	}
+	namespaces := map[string]bool{legacyConfigMapNamespace: true}
+	for _, ap := range addressPoolList.Items {
+		namespaces[ap.Namespace] = true
+		report.LegacyResources = append(report.LegacyResources, ComplianceResource{
+			Kind: "AddressPool", Namespace: ap.Namespace, Name: ap.Name,
+		})
+		report.Deprecations = append(report.Deprecations, fmt.Sprintf("AddressPool %s/%s: the AddressPool kind is "+
+			"deprecated, use IPAddressPool", ap.Namespace, ap.Name))
+		if len(ap.Spec.BGPAdvertisements) > 0 {
+			report.Deprecations = append(report.Deprecations, fmt.Sprintf("AddressPool %s/%s: "+
+				"spec.bgpAdvertisements is deprecated, use BGPAdvertisement", ap.Namespace, ap.Name))
+		}
+		pool, err := poolCompliance(ctx, cl, ap, opts)
+		if err != nil {
+			return nil, err
+		}
+		report.Pools = append(report.Pools, pool)
+	}
+	var sortedNamespaces []string
+	for namespace := range namespaces {
+		sortedNamespaces = append(sortedNamespaces, namespace)
+	}
+	sort.Strings(sortedNamespaces)
+	for _, namespace := range sortedNamespaces {
+		cm := &corev1.ConfigMap{}
+		err := cl.Get(ctx, types.NamespacedName{Namespace: namespace, Name: legacyConfigMapName}, cm)
+		if apierrors.IsNotFound(err) || meta.IsNoMatchError(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot get ConfigMap %s/%s, err: %w", namespace, legacyConfigMapName, err)
+		}
+		if _, ok := cm.Data[LegacyConfigMapKey]; !ok {
+			continue
+		}
+		report.LegacyResources = append(report.LegacyResources, ComplianceResource{
+			Kind: "ConfigMap", Namespace: namespace, Name: cm.Name,
+		})
+		report.Deprecations = append(report.Deprecations, fmt.Sprintf("ConfigMap %s/%s: the legacy MetalLB "+
+			"ConfigMap is no longer read by MetalLB, convert it with -from-configmap", namespace, cm.Name))
+	}
+	addCurrent := func(kind, namespace, name string) {
+		report.CurrentResources = append(report.CurrentResources, ComplianceResource{
+			Kind: kind, Namespace: namespace, Name: name,
+		})
+	}
+	for _, iap := range current.IPAddressPoolList.Items {
+		addCurrent("IPAddressPool", iap.Namespace, iap.Name)
+	}
+	for _, l2a := range current.L2AdvertisementList.Items {
+		addCurrent("L2Advertisement", l2a.Namespace, l2a.Name)
+	}
+	for _, ba := range current.BGPAdvertisementList.Items {
+		addCurrent("BGPAdvertisement", ba.Namespace, ba.Name)
+	}
+	for _, community := range current.CommunityList.Items {
+		addCurrent("Community", community.Namespace, community.Name)
+	}
+	for _, peer := range current.BGPPeerList.Items {
+		addCurrent("BGPPeer", peer.Namespace, peer.Name)
+	}
+	report.Summary = summarizeCompliance(report)
+	return report, nil
+}
+
+// poolCompliance converts the AddressPool on its own according to opts, as an online migration would, and compares
+// the converted resources with the live ones.
+func poolCompliance(ctx context.Context, cl client.Client, ap metallbv1beta1.AddressPool,
+	opts Options) (PoolCompliance, error) {
+	pool := PoolCompliance{Namespace: ap.Namespace, Name: ap.Name}
+	desired, err := convertSingle(ctx, cl, ap, opts)
+	if err != nil {
+		pool.Status = ComplianceUnconvertible
+		pool.Differences = []string{err.Error()}
+		return pool, nil
+	}
+	migrated, err := desired.migrated(ctx, cl)
+	if err != nil {
+		return pool, err
+	}
+	if !migrated {
+		pool.Status = ComplianceNotMigrated
+		return pool, nil
+	}
+	pool.Differences = desired.discrepancies(ctx, cl)
+	pool.Status = ComplianceEquivalent
+	if len(pool.Differences) > 0 {
+		pool.Status = ComplianceDiffers
+	}
+	return pool, nil
+}
+
+// summarizeCompliance counts the pools of report by their status.
+func summarizeCompliance(report *ComplianceReport) ComplianceSummary {
+	summary := ComplianceSummary{
+		LegacyAddressPools: len(report.Pools),
+		Deprecations:       len(report.Deprecations),
+	}
+	for _, pool := range report.Pools {
+		switch pool.Status {
+		case ComplianceNotMigrated:
+			summary.NotMigrated++
+		case ComplianceEquivalent:
+			summary.Equivalent++
+		case ComplianceDiffers:
+			summary.Differs++
+		case ComplianceUnconvertible:
+			summary.Unconvertible++
+		}
+	}
+	return summary
+}
+
+// Print writes the report to w in YAML or JSON format.
+func (r *ComplianceReport) Print(w io.Writer, toJSON bool) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err == nil && !toJSON {
+		data, err = yaml.JSONToYAML(data)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot encode compliance report, err: %w", err)
+	}
+	if toJSON {
+		data = append(data, '\n')
+	}
+	_, err = w.Write(data)
+	return err
+}
@@ -0,0 +1,91 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	metallbv1beta2 "go.universe.tf/metallb/api/v1beta2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestCheckCompliance(t *testing.T) {
+	var scheme = runtime.NewScheme()
+	for _, addToScheme := range []func(*runtime.Scheme) error{
+		metallbv1beta1.AddToScheme, metallbv1beta2.AddToScheme, corev1.AddToScheme,
+	} {
+		if err := addToScheme(scheme); err != nil {
+			t.Fatalf("TestCheckCompliance: error adding to scheme, err: %q", err)
+		}
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "config", Namespace: "metallb-system"},
+		Data:       map[string]string{LegacyConfigMapKey: legacyConfig0},
+	}).Build()
+	for _, ap := range validAddressPools0 {
+		if err := c.Create(context.TODO(), ap.DeepCopy()); err != nil {
+			t.Fatalf("TestCheckCompliance: error building fake client, err: %q", err)
+		}
+	}
+	err := OnlineMigration(context.TODO(), c, scheme, t.TempDir(), false, OnlineMigrationOptions{KeepLegacy: true})
+	if err != nil {
+		t.Fatalf("TestCheckCompliance: unexpected error during migration, err: %q", err)
+	}
+	if err := modifyIPAddressPool(c, "ap-l2", func(spec *metallbv1beta1.IPAddressPoolSpec) {
+		spec.Addresses = []string{"10.0.0.0/24"}
+	}); err != nil {
+		t.Fatalf("TestCheckCompliance: cannot modify IPAddressPool, err: %q", err)
+	}
+	notMigrated := validAddressPools0[2].DeepCopy()
+	notMigrated.Name = "ap-new"
+	if err := c.Create(context.TODO(), notMigrated); err != nil {
+		t.Fatalf("TestCheckCompliance: cannot create AddressPool, err: %q", err)
+	}
+
+	report, err := CheckCompliance(context.TODO(), c, Options{})
+	if err != nil {
+		t.Fatalf("TestCheckCompliance: unexpected error, err: %q", err)
+	}
+	expectedSummary := ComplianceSummary{
+		LegacyAddressPools: 4, NotMigrated: 1, Equivalent: 2, Differs: 1, Deprecations: 6,
+	}
+	if report.Summary != expectedSummary {
+		t.Fatalf("TestCheckCompliance: expected summary %+v but got %+v", expectedSummary, report.Summary)
+	}
+	statuses := map[string]string{}
+	for _, pool := range report.Pools {
+		statuses[pool.Name] = pool.Status
+	}
+	expectedStatuses := "map[ap-bgp:equivalent ap-bgp2:equivalent ap-l2:differs ap-new:not-migrated]"
+	if fmt.Sprint(statuses) != expectedStatuses {
+		t.Fatalf("TestCheckCompliance: expected statuses %s but got %v", expectedStatuses, statuses)
+	}
+	for _, expected := range []string{
+		"AddressPool metallb-system/ap-bgp: spec.bgpAdvertisements is deprecated, use BGPAdvertisement",
+		"ConfigMap metallb-system/config: the legacy MetalLB ConfigMap is no longer read by MetalLB",
+	} {
+		if !strings.Contains(strings.Join(report.Deprecations, "\n"), expected) {
+			t.Fatalf("TestCheckCompliance: expected deprecation %q but got %v", expected, report.Deprecations)
+		}
+	}
+	if len(report.LegacyResources) != 5 || len(report.CurrentResources) == 0 {
+		t.Fatalf("TestCheckCompliance: unexpected resources %v and %v", report.LegacyResources,
+			report.CurrentResources)
+	}
+
+	var out bytes.Buffer
+	if err := report.Print(&out, true); err != nil {
+		t.Fatalf("TestCheckCompliance: cannot print report, err: %q", err)
+	}
+	decoded := ComplianceReport{}
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil || decoded.Summary != expectedSummary {
+		t.Fatalf("TestCheckCompliance: cannot decode printed report %s, err: %v", out.String(), err)
+	}
+}
@@ -0,0 +1,270 @@
+package converter
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+// LegacyConfigMapKey is the key of the legacy MetalLB ConfigMap that holds the configuration.
+const LegacyConfigMapKey = "config"
+
+// LegacyCommunitiesResourceName is the name of the Community CR that the bgp-communities of a legacy MetalLB ConfigMap
+// are converted to.
+const LegacyCommunitiesResourceName = "bgp-communities"
+
+// legacyConfig is the legacy MetalLB ConfigMap configuration.
+type legacyConfig struct {
+	Peers          []legacyPeer            `json:"peers,omitempty"`
+	BGPCommunities map[string]string       `json:"bgp-communities,omitempty"`
+	BFDProfiles    []legacyBFDProfile      `json:"bfd-profiles,omitempty"`
+	AddressPools   []legacyAddressPoolSpec `json:"address-pools,omitempty"`
+}
+
+// legacyPeer is a single entry of peers in the legacy MetalLB ConfigMap.
+type legacyPeer struct {
+	PeerAddress   string               `json:"peer-address"`
+	PeerASN       uint32               `json:"peer-asn"`
+	MyASN         uint32               `json:"my-asn"`
+	PeerPort      uint16               `json:"peer-port,omitempty"`
+	SourceAddress string               `json:"source-address,omitempty"`
+	HoldTime      string               `json:"hold-time,omitempty"`
+	KeepaliveTime string               `json:"keepalive-time,omitempty"`
+	RouterID      string               `json:"router-id,omitempty"`
+	Password      string               `json:"password,omitempty"`
+	BFDProfile    string               `json:"bfd-profile,omitempty"`
+	EBGPMultiHop  bool                 `json:"ebgp-multihop,omitempty"`
+	NodeSelectors []legacyNodeSelector `json:"node-selectors,omitempty"`
+}
+
+// legacyBFDProfile is a single entry of bfd-profiles in the legacy MetalLB ConfigMap.
+type legacyBFDProfile struct {
+	Name             string  `json:"name"`
+	ReceiveInterval  *uint32 `json:"receive-interval,omitempty"`
+	TransmitInterval *uint32 `json:"transmit-interval,omitempty"`
+	DetectMultiplier *uint32 `json:"detect-multiplier,omitempty"`
+	EchoInterval     *uint32 `json:"echo-interval,omitempty"`
+	EchoMode         *bool   `json:"echo-mode,omitempty"`
+	PassiveMode      *bool   `json:"passive-mode,omitempty"`
+	MinimumTTL       *uint32 `json:"minimum-ttl,omitempty"`
+}
+
+// legacyNodeSelector is a single entry of node-selectors of a peer in the legacy MetalLB ConfigMap.
+type legacyNodeSelector struct {
+	MatchLabels      map[string]string           `json:"match-labels,omitempty"`
+	MatchExpressions []legacySelectorRequirement `json:"match-expressions,omitempty"`
+}
+
+// legacySelectorRequirement is a single entry of match-expressions of a legacy node selector.
+type legacySelectorRequirement struct {
+	Key      string   `json:"key"`
+	Operator string   `json:"operator"`
+	Values   []string `json:"values,omitempty"`
+}
+
+// legacyAddressPoolSpec is a single entry of address-pools in the legacy MetalLB ConfigMap.
+type legacyAddressPoolSpec struct {
+	Name              string                   `json:"name"`
+	Protocol          string                   `json:"protocol"`
+	Addresses         []string                 `json:"addresses"`
+	AvoidBuggyIPs     bool                     `json:"avoid-buggy-ips,omitempty"`
+	AutoAssign        *bool                    `json:"auto-assign,omitempty"`
+	BGPAdvertisements []legacyBGPAdvertisement `json:"bgp-advertisements,omitempty"`
+}
+
+// legacyBGPAdvertisement is a single entry of bgp-advertisements in the legacy MetalLB ConfigMap.
+type legacyBGPAdvertisement struct {
+	AggregationLength   *int32   `json:"aggregation-length,omitempty"`
+	AggregationLengthV6 *int32   `json:"aggregation-length-v6,omitempty"`
+	LocalPref           *uint32  `json:"localpref,omitempty"`
+	Communities         []string `json:"communities,omitempty"`
+}
+
+// ParseLegacyConfig parses the configuration of a legacy MetalLB ConfigMap and returns its address-pools as
+// AddressPools in namespace. Community aliases from bgp-communities are resolved to their values. The configuration is
+// kept to convert the settings that the AddressPool CR cannot express, such as avoid-buggy-ips, and the peers,
+// bfd-profiles and bgp-communities, see convertLegacyConfig.
+func ParseLegacyConfig(data []byte, namespace string) (*LegacyObjects, error) {
+	config := legacyConfig{}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("cannot parse legacy configuration, err: %w", err)
+	}
+	addressPoolList := &metallbv1beta1.AddressPoolList{}
+	for _, pool := range config.AddressPools {
+		ap := metallbv1beta1.AddressPool{
+			TypeMeta: metav1.TypeMeta{
+				Kind:       "AddressPool",
+				APIVersion: fmt.Sprintf("%s/%s", metallbAPIGroup, "v1beta1"),
+			},
+			ObjectMeta: metav1.ObjectMeta{Name: pool.Name, Namespace: namespace},
+			Spec: metallbv1beta1.AddressPoolSpec{
+				Protocol:   pool.Protocol,
+				Addresses:  pool.Addresses,
+				AutoAssign: pool.AutoAssign,
+			},
+		}
+		for _, adv := range pool.BGPAdvertisements {
+			legacyAdv := metallbv1beta1.LegacyBgpAdvertisement{
+				AggregationLength:   adv.AggregationLength,
+				AggregationLengthV6: adv.AggregationLengthV6,
+			}
+			if adv.LocalPref != nil {
+				legacyAdv.LocalPref = *adv.LocalPref
+			}
+			for _, community := range adv.Communities {
+				if value, ok := config.BGPCommunities[community]; ok {
+					community = value
+				}
+				legacyAdv.Communities = append(legacyAdv.Communities, community)
+			}
+			ap.Spec.BGPAdvertisements = append(ap.Spec.BGPAdvertisements, legacyAdv)
+		}
+		addressPoolList.Items = append(addressPoolList.Items, ap)
+	}
+	return &LegacyObjects{AddressPoolList: addressPoolList, legacyPeers: len(config.Peers), config: &config,
+		configMap: types.NamespacedName{Namespace: namespace}}, nil
+}
+
+// decodeLegacyConfigMap returns the AddressPools and the configuration of document if it is a legacy MetalLB
+// ConfigMap, i.e. a ConfigMap with a LegacyConfigMapKey key, see ParseLegacyConfig. The AddressPools are in the
+// namespace of the ConfigMap. ok is false for all other documents.
+func decodeLegacyConfigMap(document []byte, source string) (decoded legacyContent, ok bool, err error) {
+	cm := corev1.ConfigMap{}
+	if err := yaml.Unmarshal(document, &cm); err != nil || cm.APIVersion != "v1" || cm.Kind != "ConfigMap" {
+		return legacyContent{}, false, nil
+	}
+	data, ok := cm.Data[LegacyConfigMapKey]
+	if !ok {
+		return legacyContent{}, false, nil
+	}
+	key := types.NamespacedName{Namespace: cm.Namespace, Name: cm.Name}
+	legacyObjects, err := ParseLegacyConfig([]byte(data), key.Namespace)
+	if err != nil {
+		return legacyContent{}, true, fmt.Errorf("invalid legacy ConfigMap %s in %s, err: %w", key, source, err)
+	}
+	legacyObjects.configMap = key
+	for _, ap := range legacyObjects.AddressPoolList.Items {
+		decoded.pools = append(decoded.pools, sourcedAddressPool{addressPool: ap, source: source})
+	}
+	decoded.configs = []*LegacyObjects{legacyObjects}
+	return decoded, true, nil
+}
+
+// avoidsBuggyIPs returns true if the address pool name of the configuration sets avoid-buggy-ips. The AddressPool CR
+// cannot express it, so Convert looks it up here. A nil configuration sets nothing.
+func (config *legacyConfig) avoidsBuggyIPs(name string) bool {
+	if config == nil {
+		return false
+	}
+	for _, pool := range config.AddressPools {
+		if pool.Name == name {
+			return pool.AvoidBuggyIPs
+		}
+	}
+	return false
+}
+
+// convertLegacyConfig converts the sections of the legacy ConfigMap configuration that the AddressPools were read from
+// besides its address-pools into objects in namespace. The bgp-communities are added to c as a Community CR named
+// LegacyCommunitiesResourceName; the BGPAdvertisements keep the resolved values. The BFDProfiles of bfd-profiles and
+// the BGPPeers of peers, see convertPeers, are returned grouped by kind, with the passwords converted according to
+// passwordsMode.
+func (l LegacyObjects) convertLegacyConfig(c *CurrentObjects, namespace, passwordsMode string) ([][]runtime.Object,
+	error) {
+	if l.config == nil {
+		return nil, nil
+	}
+	if len(l.config.BGPCommunities) > 0 {
+		community := metallbv1beta1.Community{
+			TypeMeta:   metav1.TypeMeta{Kind: "Community", APIVersion: metallbAPIVersion},
+			ObjectMeta: metav1.ObjectMeta{Name: LegacyCommunitiesResourceName, Namespace: namespace},
+		}
+		var aliases []string
+		for alias := range l.config.BGPCommunities {
+			aliases = append(aliases, alias)
+		}
+		sort.Strings(aliases)
+		for _, alias := range aliases {
+			community.Spec.Communities = append(community.Spec.Communities, metallbv1beta1.CommunityAlias{
+				Name:  alias,
+				Value: l.config.BGPCommunities[alias],
+			})
+		}
+		c.CommunityList.Items = append(c.CommunityList.Items, community)
+	}
+
+	var groups [][]runtime.Object
+	var profiles []runtime.Object
+	known := map[string]bool{}
+	for _, profile := range l.config.BFDProfiles {
+		if profile.Name == "" || known[profile.Name] {
+			return nil, fmt.Errorf("bfd-profiles must have distinct, non-empty names, got %q", profile.Name)
+		}
+		known[profile.Name] = true
+		profiles = append(profiles, &metallbv1beta1.BFDProfile{
+			TypeMeta:   metav1.TypeMeta{Kind: "BFDProfile", APIVersion: metallbAPIVersion},
+			ObjectMeta: metav1.ObjectMeta{Name: profile.Name, Namespace: namespace},
+			Spec: metallbv1beta1.BFDProfileSpec{
+				ReceiveInterval:  profile.ReceiveInterval,
+				TransmitInterval: profile.TransmitInterval,
+				DetectMultiplier: profile.DetectMultiplier,
+				EchoInterval:     profile.EchoInterval,
+				EchoMode:         profile.EchoMode,
+				PassiveMode:      profile.PassiveMode,
+				MinimumTTL:       profile.MinimumTTL,
+			},
+		})
+	}
+	if len(profiles) > 0 {
+		groups = append(groups, profiles)
+	}
+	for _, peer := range l.config.Peers {
+		if peer.BFDProfile != "" && !known[peer.BFDProfile] {
+			return nil, fmt.Errorf("peer %s references bfd-profile %q which is not defined", peer.PeerAddress,
+				peer.BFDProfile)
+		}
+	}
+	peers, err := l.convertPeers(namespace, passwordsMode)
+	if err != nil {
+		return nil, err
+	}
+	return append(groups, peers...), nil
+}
+
+// ReadLegacyObjectsFromConfigMap reads the legacy MetalLB ConfigMap key from the cluster and returns its
+// address-pools as AddressPools in the namespace of the ConfigMap.
+func ReadLegacyObjectsFromConfigMap(ctx context.Context, c client.Client,
+	key types.NamespacedName) (*LegacyObjects, error) {
+	cm := &corev1.ConfigMap{}
+	if err := c.Get(ctx, key, cm); err != nil {
+		return nil, fmt.Errorf("cannot get legacy ConfigMap %s, err: %w", key, err)
+	}
+	data, ok := cm.Data[LegacyConfigMapKey]
+	if !ok {
+		return nil, fmt.Errorf("legacy ConfigMap %s has no %q key", key, LegacyConfigMapKey)
+	}
+	legacyObjects, err := ParseLegacyConfig([]byte(data), key.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	legacyObjects.configMap = key
+	return legacyObjects, nil
+}
+
+// ParseNamespacedName parses a <namespace>/<name> string.
+func ParseNamespacedName(s string) (types.NamespacedName, error) {
+	namespace, name, found := strings.Cut(s, "/")
+	if !found || namespace == "" || name == "" {
+		return types.NamespacedName{}, fmt.Errorf("invalid reference %q, expected <namespace>/<name>", s)
+	}
+	return types.NamespacedName{Namespace: namespace, Name: name}, nil
+}
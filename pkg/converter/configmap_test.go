@@ -0,0 +1,306 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	metallbv1beta2 "go.universe.tf/metallb/api/v1beta2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var legacyConfig0 = `peers:
+- peer-address: 10.0.0.1
+  peer-asn: 64501
+  my-asn: 64500
+bgp-communities:
+  no-advertise: 65535:65282
+address-pools:
+- name: ap-l2
+  protocol: layer2
+  addresses:
+  - 192.168.100.0/24
+- name: ap-bgp
+  protocol: bgp
+  auto-assign: false
+  addresses:
+  - 192.168.200.0/24
+  bgp-advertisements:
+  - aggregation-length: 32
+    localpref: 100
+    communities:
+    - no-advertise
+    - 65432:12345
+`
+
+func TestParseLegacyConfig(t *testing.T) {
+	tcs := map[string]struct {
+		config              string
+		expectedPools       int
+		expectedPeers       int
+		expectedCommunities []string
+		expectedErrorString string
+	}{
+		"valid config": {
+			config:              legacyConfig0,
+			expectedPools:       2,
+			expectedPeers:       1,
+			expectedCommunities: []string{"65535:65282", "65432:12345"},
+		},
+		"invalid yaml": {
+			config:              "address-pools: {",
+			expectedErrorString: "cannot parse legacy configuration",
+		},
+	}
+	for desc, tc := range tcs {
+		legacyObjects, err := ParseLegacyConfig([]byte(tc.config), "metallb-system")
+		if tc.expectedErrorString != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.expectedErrorString) {
+				t.Fatalf("TestParseLegacyConfig(%s): expected error %q but got %v", desc, tc.expectedErrorString, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("TestParseLegacyConfig(%s): unexpected error, err: %q", desc, err)
+		}
+		pools := legacyObjects.AddressPoolList.Items
+		if len(pools) != tc.expectedPools {
+			t.Fatalf("TestParseLegacyConfig(%s): expected %d pools but got %d", desc, tc.expectedPools, len(pools))
+		}
+		if legacyObjects.legacyPeers != tc.expectedPeers {
+			t.Fatalf("TestParseLegacyConfig(%s): expected %d peers but got %d", desc, tc.expectedPeers,
+				legacyObjects.legacyPeers)
+		}
+		bgp := pools[1]
+		if bgp.Namespace != "metallb-system" || bgp.Spec.AutoAssign == nil || *bgp.Spec.AutoAssign ||
+			bgp.Spec.BGPAdvertisements[0].LocalPref != 100 {
+			t.Fatalf("TestParseLegacyConfig(%s): unexpected pool %+v", desc, bgp)
+		}
+		if fmt.Sprint(bgp.Spec.BGPAdvertisements[0].Communities) != fmt.Sprint(tc.expectedCommunities) {
+			t.Fatalf("TestParseLegacyConfig(%s): expected communities %v but got %v", desc, tc.expectedCommunities,
+				bgp.Spec.BGPAdvertisements[0].Communities)
+		}
+	}
+}
+
+func TestConvertLegacyConfig(t *testing.T) {
+	receiveInterval, echoMode := uint32(300), true
+	tcs := map[string]struct {
+		config                 string
+		expectedAvoidBuggyIPs  []bool
+		expectedCommunities    []metallbv1beta1.CommunityAlias
+		expectedProfiles       []metallbv1beta1.BFDProfileSpec
+		expectedPeerBFDProfile string
+		expectedErrorString    string
+	}{
+		"full config": {
+			config: `peers:
+- peer-address: 10.0.0.1
+  peer-asn: 64501
+  my-asn: 64500
+  bfd-profile: fast
+bfd-profiles:
+- name: fast
+  receive-interval: 300
+  echo-mode: true
+- name: default
+bgp-communities:
+  no-advertise: 65535:65282
+  vpn-only: 1234:1
+address-pools:
+- name: ap-l2
+  protocol: layer2
+  avoid-buggy-ips: true
+  addresses:
+  - 192.168.100.0/24
+- name: ap-bgp
+  protocol: bgp
+  addresses:
+  - 192.168.200.0/24
+  bgp-advertisements:
+  - communities:
+    - vpn-only
+`,
+			expectedAvoidBuggyIPs: []bool{true, false},
+			expectedCommunities: []metallbv1beta1.CommunityAlias{
+				{Name: "no-advertise", Value: "65535:65282"},
+				{Name: "vpn-only", Value: "1234:1"},
+			},
+			expectedProfiles: []metallbv1beta1.BFDProfileSpec{
+				{ReceiveInterval: &receiveInterval, EchoMode: &echoMode},
+				{},
+			},
+			expectedPeerBFDProfile: "fast",
+		},
+		"undefined bfd profile": {
+			config:              "peers:\n- peer-address: 10.0.0.1\n  bfd-profile: slow\n",
+			expectedErrorString: `peer 10.0.0.1 references bfd-profile "slow" which is not defined`,
+		},
+		"duplicate bfd profile": {
+			config:              "bfd-profiles:\n- name: fast\n- name: fast\n",
+			expectedErrorString: `bfd-profiles must have distinct, non-empty names, got "fast"`,
+		},
+	}
+	for desc, tc := range tcs {
+		legacyObjects, err := ParseLegacyConfig([]byte(tc.config), "metallb-system")
+		if err != nil {
+			t.Fatalf("TestConvertLegacyConfig(%s): cannot parse config, err: %q", desc, err)
+		}
+		currentObjects, err := legacyObjects.Convert(Options{})
+		if err != nil {
+			t.Fatalf("TestConvertLegacyConfig(%s): unexpected conversion error, err: %q", desc, err)
+		}
+		groups, err := legacyObjects.convertLegacyConfig(currentObjects, "metallb-system", PeerPasswordsSecret)
+		if tc.expectedErrorString != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.expectedErrorString) {
+				t.Fatalf("TestConvertLegacyConfig(%s): expected error %q but got %v", desc, tc.expectedErrorString,
+					err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("TestConvertLegacyConfig(%s): unexpected error, err: %q", desc, err)
+		}
+		var avoidBuggyIPs []bool
+		for _, iap := range currentObjects.IPAddressPoolList.Items {
+			avoidBuggyIPs = append(avoidBuggyIPs, iap.Spec.AvoidBuggyIPs)
+		}
+		if !reflect.DeepEqual(avoidBuggyIPs, tc.expectedAvoidBuggyIPs) {
+			t.Fatalf("TestConvertLegacyConfig(%s): expected avoidBuggyIPs %v but got %v", desc,
+				tc.expectedAvoidBuggyIPs, avoidBuggyIPs)
+		}
+		communities := currentObjects.CommunityList.Items
+		if len(communities) != 1 || communities[0].Name != LegacyCommunitiesResourceName ||
+			!reflect.DeepEqual(communities[0].Spec.Communities, tc.expectedCommunities) {
+			t.Fatalf("TestConvertLegacyConfig(%s): expected communities %v but got %+v", desc,
+				tc.expectedCommunities, communities)
+		}
+		if fmt.Sprint(currentObjects.BGPAdvertisementList.Items[0].Spec.Communities) != "[1234:1]" {
+			t.Fatalf("TestConvertLegacyConfig(%s): expected resolved communities but got %v", desc,
+				currentObjects.BGPAdvertisementList.Items[0].Spec.Communities)
+		}
+		var profiles []metallbv1beta1.BFDProfileSpec
+		peerBFDProfile := ""
+		for _, group := range groups {
+			for _, obj := range group {
+				switch o := obj.(type) {
+				case *metallbv1beta1.BFDProfile:
+					profiles = append(profiles, o.Spec)
+				case *metallbv1beta2.BGPPeer:
+					peerBFDProfile = o.Spec.BFDProfile
+				}
+			}
+		}
+		if !reflect.DeepEqual(profiles, tc.expectedProfiles) || peerBFDProfile != tc.expectedPeerBFDProfile {
+			t.Fatalf("TestConvertLegacyConfig(%s): expected BFD profiles %+v and peer profile %q but got %+v and %q",
+				desc, tc.expectedProfiles, tc.expectedPeerBFDProfile, profiles, peerBFDProfile)
+		}
+	}
+}
+
+func TestOfflineMigrationFromConfigMap(t *testing.T) {
+	var scheme = runtime.NewScheme()
+	if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestOfflineMigrationFromConfigMap: error adding to scheme, err: %q", err)
+	}
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestOfflineMigrationFromConfigMap: error adding to scheme, err: %q", err)
+	}
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "config", Namespace: "metallb-system"},
+		Data:       map[string]string{LegacyConfigMapKey: legacyConfig0},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(cm).Build()
+
+	stdout = bytes.NewBuffer([]byte{})
+	err := OfflineMigration(context.TODO(), c, scheme, "", "", OutputFormatName, OfflineMigrationOptions{
+		FromConfigMap: types.NamespacedName{Namespace: "metallb-system", Name: "config"},
+	})
+	if err != nil {
+		t.Fatalf("TestOfflineMigrationFromConfigMap: unexpected error, err: %q", err)
+	}
+	for _, expected := range []string{"IPAddressPool/metallb-system/ap-l2", "IPAddressPool/metallb-system/ap-bgp"} {
+		if !strings.Contains(fmt.Sprint(stdout), expected) {
+			t.Fatalf("TestOfflineMigrationFromConfigMap: expected %q in output but got:\n%s", expected, stdout)
+		}
+	}
+
+	err = OfflineMigration(context.TODO(), c, scheme, "", "", OutputFormatName, OfflineMigrationOptions{
+		FromConfigMap: types.NamespacedName{Namespace: "metallb-system", Name: "does-not-exist"},
+	})
+	if err == nil {
+		t.Fatalf("TestOfflineMigrationFromConfigMap: expected an error for a missing ConfigMap")
+	}
+}
+
+func TestOfflineMigrationConfigMapFromDirectory(t *testing.T) {
+	var scheme = runtime.NewScheme()
+	if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestOfflineMigrationConfigMapFromDirectory: error adding to scheme, err: %q", err)
+	}
+	fixture, err := os.ReadFile(filepath.Join("testdata", "legacy-configmap", "config.yaml"))
+	if err != nil {
+		t.Fatalf("TestOfflineMigrationConfigMapFromDirectory: cannot read fixture, err: %q", err)
+	}
+	twoConfigMaps := t.TempDir()
+	for _, name := range []string{"a.yaml", "b.yaml"} {
+		content := strings.Replace(string(fixture), "name: config", "name: "+strings.TrimSuffix(name, ".yaml"), 1)
+		if err := os.WriteFile(filepath.Join(twoConfigMaps, name), []byte(content), 0600); err != nil {
+			t.Fatalf("TestOfflineMigrationConfigMapFromDirectory: cannot write input, err: %q", err)
+		}
+	}
+
+	tcs := map[string]struct {
+		inDir               string
+		expectedOutput      []string
+		expectedErrorString string
+	}{
+		"legacy ConfigMap": {
+			inDir: filepath.Join("testdata", "legacy-configmap"),
+			expectedOutput: []string{
+				"  name: ap-l2\n  namespace: metallb-system\nspec:\n  addresses:\n  - 192.168.100.0/24\n" +
+					"  avoidBuggyIPs: true",
+				"kind: BGPAdvertisement",
+				"- 65535:65282",
+				"  name: bgp-communities\n  namespace: metallb-system",
+				"  name: slow\n  namespace: metallb-system\nspec:\n  receiveInterval: 500",
+				"  name: peer-10-0-0-1\n  namespace: metallb-system",
+			},
+		},
+		"two legacy ConfigMaps": {
+			inDir:               twoConfigMaps,
+			expectedErrorString: "only one legacy ConfigMap can be converted at once",
+		},
+	}
+	for desc, tc := range tcs {
+		stdout = bytes.NewBuffer([]byte{})
+		err := OfflineMigration(context.TODO(), nil, scheme, tc.inDir, "", OutputFormatYAML,
+			OfflineMigrationOptions{})
+		if tc.expectedErrorString != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.expectedErrorString) {
+				t.Fatalf("TestOfflineMigrationConfigMapFromDirectory(%s): expected error %q but got %v", desc,
+					tc.expectedErrorString, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("TestOfflineMigrationConfigMapFromDirectory(%s): unexpected error, err: %q", desc, err)
+		}
+		for _, expected := range tc.expectedOutput {
+			if !strings.Contains(fmt.Sprint(stdout), expected) {
+				t.Fatalf("TestOfflineMigrationConfigMapFromDirectory(%s): expected %q in output but got:\n%s", desc,
+					expected, stdout)
+			}
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package converter
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// PlanOnlineMigration returns a description of every destructive action that OnlineMigration with opts would perform
+// against the API. Non-destructive migrations do not delete anything and thus yield no actions.
+func PlanOnlineMigration(ctx context.Context, c client.Client, opts OnlineMigrationOptions) ([]string, error) {
+	if opts.KeepLegacy {
+		return nil, nil
+	}
+	legacyObjects, err := ReadLegacyObjectsFromAPI(ctx, c, 0, opts.Options)
+	if err != nil {
+		return nil, fmt.Errorf("cannot plan online migration, err: %w", err)
+	}
+	var actions []string
+	for _, ap := range legacyObjects.AddressPoolList.Items {
+		if isMigrated(ap) {
+			continue
+		}
+		action := fmt.Sprintf("delete AddressPool %s/%s and replace it with its converted counterparts",
+			ap.Namespace, ap.Name)
+		if managers := poolManagers(ap); len(managers) > 0 {
+			action = fmt.Sprintf("%s (managed by %s, the change may be reverted)", action, strings.Join(managers, ", "))
+		}
+		actions = append(actions, action)
+	}
+	return actions, nil
+}
+
+// PlanFinalizeMigration returns a description of every destructive action that FinalizeMigration with deleteAfter and
+// opts would perform against the API.
+func PlanFinalizeMigration(ctx context.Context, c client.Client, deleteAfter time.Duration,
+	opts Options) ([]string, error) {
+	legacyObjects, err := ReadLegacyObjectsFromAPI(ctx, c, 0, opts)
+	if err != nil {
+		return nil, fmt.Errorf("cannot plan finalization, err: %w", err)
+	}
+	now := time.Now().UTC()
+	var actions []string
+	for _, ap := range legacyObjects.AddressPoolList.Items {
+		if isMigrated(ap) && isDue(ap, deleteAfter, now) {
+			actions = append(actions, fmt.Sprintf("delete superseded AddressPool %s/%s", ap.Namespace, ap.Name))
+		}
+	}
+	return actions, nil
+}
+
+// Confirm prints actions to out and asks for confirmation. It returns true only if the answer read from in is "y" or
+// "yes" (case insensitive).
+func Confirm(in io.Reader, out io.Writer, actions []string) (bool, error) {
+	fmt.Fprintln(out, "The following destructive actions will be performed:")
+	for _, action := range actions {
+		fmt.Fprintf(out, "  - %s\n", colorize(colorRed, action))
+	}
+	fmt.Fprint(out, colorize(colorYellow, "Deleting AddressPools resets the BGP sessions and L2 announcements of "+
+		"the affected addresses.")+"\nDo you want to continue? [y/N]: ")
+	answer, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false, fmt.Errorf("cannot read confirmation, err: %w", err)
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	}
+	return false, nil
+}
@@ -0,0 +1,82 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestConfirm(t *testing.T) {
+	tcs := map[string]struct {
+		input    string
+		expected bool
+	}{
+		"yes":          {input: "yes\n", expected: true},
+		"y":            {input: "Y\n", expected: true},
+		"no":           {input: "n\n", expected: false},
+		"empty":        {input: "\n", expected: false},
+		"eof":          {input: "", expected: false},
+		"no linebreak": {input: "y", expected: true},
+	}
+	for desc, tc := range tcs {
+		out := bytes.NewBuffer([]byte{})
+		confirmed, err := Confirm(strings.NewReader(tc.input), out, []string{"delete AddressPool ns/name"})
+		if err != nil {
+			t.Fatalf("TestConfirm(%s): unexpected error, err: %q", desc, err)
+		}
+		if confirmed != tc.expected {
+			t.Fatalf("TestConfirm(%s): expected %t but got %t", desc, tc.expected, confirmed)
+		}
+		if !strings.Contains(out.String(), "  - delete AddressPool ns/name\n") {
+			t.Fatalf("TestConfirm(%s): summary does not list the planned action, got:\n%s", desc, out)
+		}
+	}
+}
+
+func TestPlanOnlineMigration(t *testing.T) {
+	var scheme = runtime.NewScheme()
+	if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestPlanOnlineMigration: error adding to scheme, err: %q", err)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+	for _, ap := range validAddressPools0 {
+		if err := c.Create(context.TODO(), ap.DeepCopy()); err != nil {
+			t.Fatalf("TestPlanOnlineMigration: error building fake client, err: %q", err)
+		}
+	}
+
+	actions, err := PlanOnlineMigration(context.TODO(), c, OnlineMigrationOptions{})
+	if err != nil || len(actions) != len(validAddressPools0) {
+		t.Fatalf("TestPlanOnlineMigration: expected %d actions but got %v, err: %v", len(validAddressPools0),
+			actions, err)
+	}
+	actions, err = PlanOnlineMigration(context.TODO(), c, OnlineMigrationOptions{KeepLegacy: true})
+	if err != nil || len(actions) != 0 {
+		t.Fatalf("TestPlanOnlineMigration: expected no actions for a non-destructive migration but got %v, err: %v",
+			actions, err)
+	}
+	actions, err = PlanFinalizeMigration(context.TODO(), c, 0, Options{})
+	if err != nil || len(actions) != 0 {
+		t.Fatalf("TestPlanOnlineMigration: expected no finalize actions before marking but got %v, err: %v",
+			actions, err)
+	}
+
+	err = OnlineMigration(context.TODO(), c, scheme, t.TempDir(), false, OnlineMigrationOptions{KeepLegacy: true})
+	if err != nil {
+		t.Fatalf("TestPlanOnlineMigration: unexpected error during mark phase, err: %q", err)
+	}
+	actions, err = PlanOnlineMigration(context.TODO(), c, OnlineMigrationOptions{})
+	if err != nil || len(actions) != 0 {
+		t.Fatalf("TestPlanOnlineMigration: expected marked pools to be skipped but got %v, err: %v", actions, err)
+	}
+	actions, err = PlanFinalizeMigration(context.TODO(), c, 0, Options{})
+	if err != nil || len(actions) != len(validAddressPools0) {
+		t.Fatalf("TestPlanOnlineMigration: expected %d finalize actions but got %v, err: %v",
+			len(validAddressPools0), actions, err)
+	}
+}
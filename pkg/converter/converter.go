@@ -3,20 +3,32 @@ package converter
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"path"
 	"reflect"
+	"strings"
+	"time"
 
+	"github.com/andreaskaris/metallb-converter/pkg/features"
+	"github.com/andreaskaris/metallb-converter/pkg/tracing"
 	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/cli-runtime/pkg/printers"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
 )
 
 const (
@@ -25,52 +37,124 @@ const (
 	ProtocolLayer2    = "layer2"
 	metallbAPIGroup   = "metallb.io"
 	metallbAPIVersion = "metallb.io/v1beta1"
+
+	// MigratedAnnotation marks legacy AddressPools that were already migrated by a non-destructive online migration.
+	// Its value is the RFC3339 timestamp of the migration.
+	MigratedAnnotation = "metallb-converter.io/migrated"
+
+	// OutputFormatYAML prints the converted objects as YAML manifests.
+	OutputFormatYAML = "yaml"
+	// OutputFormatJSON prints the converted objects as JSON manifests.
+	OutputFormatJSON = "json"
+	// OutputFormatDot prints the relationship graph of the converted objects in graphviz DOT format.
+	OutputFormatDot = "dot"
+	// OutputFormatMermaid prints the relationship graph of the converted objects as a mermaid flowchart.
+	OutputFormatMermaid = "mermaid"
+	// OutputFormatCSV prints a flat CSV inventory of the converted pools.
+	OutputFormatCSV = "csv"
+	// OutputFormatName prints only the kind/namespace/name identifiers of the converted objects.
+	OutputFormatName = "name"
+	// OutputFormatCapacity prints the number of usable addresses per address family of every pool and in total.
+	OutputFormatCapacity = "capacity"
 )
 
+// StdinInput is the input directory of OfflineMigration that reads the legacy objects from standard input instead,
+// e.g. the output of kubectl get addresspools -o yaml.
+const StdinInput = "-"
+
 var (
 	supportedLegacyGKVVersions = map[string]struct{}{
 		"v1beta1": {},
 	}
+	// SupportedOutputFormats lists all formats that OfflineMigration can print.
+	SupportedOutputFormats = []string{OutputFormatYAML, OutputFormatJSON, OutputFormatDot, OutputFormatMermaid,
+		OutputFormatCSV, OutputFormatName, OutputFormatCapacity}
 	stdout io.Writer = os.Stdout
+	stdin  io.Reader = os.Stdin
+	tracer *tracing.Tracer
+	quiet  bool
 )
 
 type Objects interface {
 	LegacyObjects | CurrentObjects
-	Delete(client.Client) error
-	Create(client.Client) error
-	Print(targetDirectory string, toJSON bool) error
+	Delete(context.Context, client.Client) error
+	Print(targetDirectory string, toJSON bool, opts Options) error
 }
 
 // LegacyObjects holds metallb legacy objects that shall be converted to the new format.
 type LegacyObjects struct {
 	AddressPoolList *metallbv1beta1.AddressPoolList
+	// BGPPeerList holds the v1beta1 BGPPeers that were read along with the AddressPools, if any. They are converted to
+	// v1beta2 BGPPeers, see convertBGPResources.
+	BGPPeerList *metallbv1beta1.BGPPeerList
+	// BFDProfileList holds the BFDProfiles that were read along with the AddressPools, if any. They are passed through.
+	BFDProfileList *metallbv1beta1.BFDProfileList
+	// legacyPeers is the number of peers of the legacy ConfigMap that the AddressPools were read from.
+	legacyPeers int
+	// config is the configuration of the legacy ConfigMap configMap that the AddressPools were read from, if any.
+	config    *legacyConfig
+	configMap types.NamespacedName
 }
 
 // Delete deletes all objects that belong to this object from the API.
-func (l LegacyObjects) Delete(c client.Client) error {
+func (l LegacyObjects) Delete(ctx context.Context, c client.Client) error {
 	for _, ap := range l.AddressPoolList.Items {
-		err := c.Delete(context.TODO(), &ap)
+		err := c.Delete(ctx, &ap)
 		if err != nil && !apierrors.IsNotFound(err) {
-			return fmt.Errorf("cannot delete legacyObject AddressPool '%s', err: %w", ap.Name, err)
+			return fmt.Errorf("cannot delete legacyObject AddressPool '%s', err: %w", ap.Name, classifyAPIError(err))
 		}
 	}
 	return nil
 }
 
 // Create posts all objects to the API.
-func (l LegacyObjects) Create(c client.Client) error {
+func (l LegacyObjects) Create(ctx context.Context, c client.Client) error {
 	for _, ap := range l.AddressPoolList.Items {
-		err := c.Create(context.TODO(), &ap)
+		err := c.Create(ctx, &ap)
 		if err != nil {
-			return fmt.Errorf("cannot create legacyObject AddressPool '%s', err: %w", ap.Name, err)
+			return fmt.Errorf("cannot create legacyObject AddressPool '%s', err: %w", ap.Name,
+				classifyAPIError(err))
 		}
 	}
 	return nil
 }
 
-// Convert converts provided LegacyObjects into current objects.
-func (l *LegacyObjects) Convert() (*CurrentObjects, error) {
+// MarkMigrated sets MigratedAnnotation on all AddressPools in the API.
+func (l LegacyObjects) MarkMigrated(ctx context.Context, c client.Client) error {
+	now := time.Now().UTC().Format(time.RFC3339)
+	for _, ap := range l.AddressPoolList.Items {
+		current := &metallbv1beta1.AddressPool{}
+		err := c.Get(ctx, types.NamespacedName{Namespace: ap.Namespace, Name: ap.Name}, current)
+		if err != nil {
+			return fmt.Errorf("cannot get legacyObject AddressPool '%s', err: %w", ap.Name, classifyAPIError(err))
+		}
+		if current.Annotations == nil {
+			current.Annotations = map[string]string{}
+		}
+		current.Annotations[MigratedAnnotation] = now
+		if err := c.Update(ctx, current); err != nil {
+			return fmt.Errorf("cannot mark legacyObject AddressPool '%s' as migrated, err: %w", ap.Name,
+				classifyAPIError(err))
+		}
+	}
+	return nil
+}
+
+// isMigrated returns true if the AddressPool carries MigratedAnnotation.
+func isMigrated(ap metallbv1beta1.AddressPool) bool {
+	_, ok := ap.Annotations[MigratedAnnotation]
+	return ok
+}
+
+// Convert converts provided LegacyObjects into current objects according to opts.
+func (l *LegacyObjects) Convert(opts Options) (*CurrentObjects, error) {
 	apl := l.AddressPoolList
+	if err := validateAddressPools(apl.Items); err != nil {
+		return nil, err
+	}
+	if err := validateOverrides(apl.Items); err != nil {
+		return nil, err
+	}
 	iapl := &metallbv1beta1.IPAddressPoolList{
 		TypeMeta: metav1.TypeMeta{Kind: "IPAddressPoolList", APIVersion: metallbAPIVersion},
 	}
@@ -81,24 +165,31 @@ func (l *LegacyObjects) Convert() (*CurrentObjects, error) {
 		TypeMeta: metav1.TypeMeta{Kind: "BGPAdvertisementList", APIVersion: metallbAPIVersion},
 	}
 	for _, ap := range apl.Items {
+		poolName := convertedPoolName(ap)
+		if poolName != ap.Name && !quiet {
+			log.Printf("AddressPool %s/%s is converted to IPAddressPool %s/%s, update Services that request it with "+
+				"the %s annotation", ap.Namespace, ap.Name, ap.Namespace, poolName, ServiceAddressPoolAnnotation)
+		}
 		iap := metallbv1beta1.IPAddressPool{
 			TypeMeta:   metav1.TypeMeta{Kind: "IPAddressPool", APIVersion: metallbAPIVersion},
-			ObjectMeta: metav1.ObjectMeta{Name: ap.ObjectMeta.Name, Namespace: ap.ObjectMeta.Namespace},
+			ObjectMeta: opts.convertedObjectMeta(ap, poolName, false),
 			Spec: metallbv1beta1.IPAddressPoolSpec{
-				Addresses:  ap.Spec.Addresses,
-				AutoAssign: ap.Spec.AutoAssign,
+				Addresses:     ap.Spec.Addresses,
+				AutoAssign:    ap.Spec.AutoAssign,
+				AvoidBuggyIPs: l.config.avoidsBuggyIPs(ap.Name),
 			},
 			Status: metallbv1beta1.IPAddressPoolStatus{},
 		}
 		iapl.Items = append(iapl.Items, iap)
 
 		if ap.Spec.Protocol == ProtocolLayer2 {
-			name := fmt.Sprintf("%s-l2-advertisement", ap.Name)
+			name := fmt.Sprintf("%s-l2-advertisement", poolName)
 			l2a := metallbv1beta1.L2Advertisement{
 				TypeMeta:   metav1.TypeMeta{Kind: "L2Advertisement", APIVersion: metallbAPIVersion},
-				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ap.Namespace},
+				ObjectMeta: opts.convertedObjectMeta(ap, name, true),
 				Spec: metallbv1beta1.L2AdvertisementSpec{
-					IPAddressPools: []string{ap.Name},
+					IPAddressPools: []string{poolName},
+					Interfaces:     l2Interfaces(ap),
 				},
 			}
 			l2al.Items = append(l2al.Items, l2a)
@@ -110,58 +201,107 @@ func (l *LegacyObjects) Convert() (*CurrentObjects, error) {
 			if len(legacyBGPAdvertisements) == 0 {
 				legacyBGPAdvertisements = append(legacyBGPAdvertisements, metallbv1beta1.LegacyBgpAdvertisement{})
 			}
+			usedNames := map[string]bool{}
 			for i := 0; i < len(legacyBGPAdvertisements); i++ {
-				name := fmt.Sprintf("%s-bgp-advertisement-%d", ap.Name, i)
 				advertisement := legacyBGPAdvertisements[i]
+				name := opts.bgpAdvertisementName(poolName, i, advertisement, usedNames)
 				ba := metallbv1beta1.BGPAdvertisement{
 					TypeMeta:   metav1.TypeMeta{Kind: "BGPAdvertisement", APIVersion: metallbAPIVersion},
-					ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ap.Namespace},
+					ObjectMeta: opts.convertedObjectMeta(ap, name, true),
 					Spec: metallbv1beta1.BGPAdvertisementSpec{
 						AggregationLength:   advertisement.AggregationLength,
 						AggregationLengthV6: advertisement.AggregationLengthV6,
 						LocalPref:           advertisement.LocalPref,
 						Communities:         advertisement.Communities,
-						IPAddressPools:      []string{ap.Name},
+						IPAddressPools:      []string{poolName},
 					},
 					Status: metallbv1beta1.BGPAdvertisementStatus{},
 				}
 				bal.Items = append(bal.Items, ba)
 			}
 		} else {
-			return nil, fmt.Errorf("unsupported Spec.Protocol for AddressPool, %v", ap)
+			return nil, fmt.Errorf("%w %q of AddressPool %s/%s", ErrInvalidProtocol, ap.Spec.Protocol, ap.Namespace,
+				ap.Name)
 		}
 	}
-	return &CurrentObjects{
+	currentObjects := &CurrentObjects{
 		IPAddressPoolList:    iapl,
 		L2AdvertisementList:  l2al,
 		BGPAdvertisementList: bal,
-	}, nil
+		CommunityList: &metallbv1beta1.CommunityList{
+			TypeMeta: metav1.TypeMeta{Kind: "CommunityList", APIVersion: metallbAPIVersion},
+		},
+	}
+	if opts.CommunityResources {
+		currentObjects.extractCommunities()
+	}
+	if opts.AssignPriorities {
+		currentObjects.assignPoolPriorities()
+	}
+	if opts.PeersPolicy != nil {
+		currentObjects.applyPeersPolicy(opts.PeersPolicy)
+	}
+	if opts.MergeIdenticalPools {
+		currentObjects.mergePools()
+	}
+	groups := map[string]string{}
+	for _, ap := range apl.Items {
+		group := ap.Annotations[MergeGroupAnnotation]
+		if group == "" {
+			group = opts.MergePolicy.groupFor(ap)
+		}
+		if group != "" {
+			groups[fmt.Sprintf("%s/%s", ap.Namespace, convertedPoolName(ap))] = group
+		}
+	}
+	if len(groups) > 0 {
+		if err := currentObjects.mergeByPolicy(groups); err != nil {
+			return nil, err
+		}
+	}
+	if opts.SplitL2AddressFamilies {
+		currentObjects.splitL2Families()
+	}
+	if opts.SplitPoolsPerRange {
+		currentObjects.splitPerRange()
+	}
+	if features.Enabled(features.MergeAdvertisements) {
+		currentObjects.mergeAdvertisements()
+	}
+	if opts.MergeL2 {
+		currentObjects.mergeL2Advertisements()
+	}
+	if opts.NormalizeAddresses {
+		for i := range currentObjects.IPAddressPoolList.Items {
+			spec := &currentObjects.IPAddressPoolList.Items[i].Spec
+			spec.Addresses = normalizeAddressList(spec.Addresses)
+		}
+	}
+	if err := currentObjects.resolveNameCollisions(opts.NameCollisionPolicy); err != nil {
+		return nil, err
+	}
+	if len(opts.ExtraLabels) > 0 || len(opts.ExtraAnnotations) > 0 {
+		if err := currentObjects.addExtraMetadata(opts.ExtraLabels, opts.ExtraAnnotations); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Target != nil {
+		if err := currentObjects.shapeForTarget(opts.Target, opts.StripUnsupportedFields); err != nil {
+			return nil, err
+		}
+	}
+	return currentObjects, nil
 }
 
 // Print the YAML or JSON representation of the objects either to the  targetDirectory or to stdout if
-// targetDirectory == "".
-func (l LegacyObjects) Print(targetDirectory string, toJSON bool) error {
+// targetDirectory == "". The AddressPools are printed in the source API group of opts.
+func (l LegacyObjects) Print(targetDirectory string, toJSON bool, opts Options) error {
 	// Skip if there's nothing to do.
-	addressPoolList := l.AddressPoolList
-	if len(addressPoolList.Items) == 0 {
+	if len(l.AddressPoolList.Items) == 0 {
 		return nil
 	}
-	// Set Kind and APIVersion - the YAML and JSON printers expects those to be set.
-	for i := range addressPoolList.Items {
-		if addressPoolList.Items[i].Kind == "" {
-			addressPoolList.Items[i].Kind = "AddressPool"
-		}
-		if addressPoolList.Items[i].APIVersion == "" {
-			addressPoolList.Items[i].APIVersion = metallbAPIVersion
-		}
-	}
 	// Prepare the output channel and writers.
 	outWriter := stdout
-	var printer printers.ResourcePrinter = &printers.YAMLPrinter{}
-	if toJSON {
-		printer = &printers.JSONPrinter{}
-	}
-
 	if targetDirectory != "" {
 		fileExtension := "yaml"
 		if toJSON {
@@ -178,12 +318,31 @@ func (l LegacyObjects) Print(targetDirectory string, toJSON bool) error {
 		defer f.Close()
 		outWriter = f
 	}
+	return l.encode(outWriter, toJSON, opts)
+}
+
+// encode writes the YAML or JSON representation of the AddressPools to w, in the source API group of opts.
+func (l LegacyObjects) encode(w io.Writer, toJSON bool, opts Options) error {
+	addressPoolList := l.AddressPoolList
+	// Set Kind and APIVersion - the YAML and JSON printers expects those to be set.
+	for i := range addressPoolList.Items {
+		if addressPoolList.Items[i].Kind == "" {
+			addressPoolList.Items[i].Kind = "AddressPool"
+		}
+		if addressPoolList.Items[i].APIVersion == "" {
+			addressPoolList.Items[i].APIVersion = metallbAPIVersion
+		}
+	}
+	var printer printers.ResourcePrinter = &printers.YAMLPrinter{}
+	if toJSON {
+		printer = &printers.JSONPrinter{}
+	}
 	for _, ap := range addressPoolList.Items {
-		printedObj, err := printObj(&ap, printer)
+		printedObj, err := printObj(opts.withAPIGroup(&ap), printer)
 		if err != nil {
 			return fmt.Errorf("cannot print object, err: %w\nruntime object: %+v", err, ap)
 		}
-		fmt.Fprint(outWriter, printedObj)
+		fmt.Fprint(w, printedObj)
 	}
 	return nil
 }
@@ -193,69 +352,180 @@ type CurrentObjects struct {
 	IPAddressPoolList    *metallbv1beta1.IPAddressPoolList
 	L2AdvertisementList  *metallbv1beta1.L2AdvertisementList
 	BGPAdvertisementList *metallbv1beta1.BGPAdvertisementList
+	CommunityList        *metallbv1beta1.CommunityList
 }
 
 // Delete deletes all instances from the API if they exist.
-func (c CurrentObjects) Delete(cl client.Client) error {
+func (c CurrentObjects) Delete(ctx context.Context, cl client.Client) error {
 	for _, iap := range c.IPAddressPoolList.Items {
-		err := cl.Delete(context.TODO(), &iap)
+		err := cl.Delete(ctx, &iap)
 		if err != nil && !apierrors.IsNotFound(err) {
-			return fmt.Errorf("cannot delete currentObject IPAddressPool '%s', err: %w", iap.Name, err)
+			return fmt.Errorf("cannot delete currentObject IPAddressPool '%s', err: %w", iap.Name,
+				classifyAPIError(err))
 		}
 	}
 	for _, ba := range c.BGPAdvertisementList.Items {
-		err := cl.Delete(context.TODO(), &ba)
+		err := cl.Delete(ctx, &ba)
 		if err != nil && !apierrors.IsNotFound(err) {
-			return fmt.Errorf("cannot delete currentObject BGPAdvertisement '%s', err: %w", ba.Name, err)
+			return fmt.Errorf("cannot delete currentObject BGPAdvertisement '%s', err: %w", ba.Name,
+				classifyAPIError(err))
 		}
 	}
 	for _, l2a := range c.L2AdvertisementList.Items {
-		err := cl.Delete(context.TODO(), &l2a)
+		err := cl.Delete(ctx, &l2a)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("cannot delete currentObject L2Advertisement '%s', err: %w", l2a.Name,
+				classifyAPIError(err))
+		}
+	}
+	for _, community := range c.CommunityList.Items {
+		err := cl.Delete(ctx, &community)
 		if err != nil && !apierrors.IsNotFound(err) {
-			return fmt.Errorf("cannot delete currentObject L2Advertisement '%s', err: %w", l2a.Name, err)
+			return fmt.Errorf("cannot delete currentObject Community '%s', err: %w", community.Name,
+				classifyAPIError(err))
 		}
 	}
 	return nil
 }
 
-// Create pods the object to the API.
-func (c CurrentObjects) Create(cl client.Client) error {
+// Create pods the object to the API. Existing Communities are merged, and with the ServerSideApply of opts the other
+// objects are applied instead of created.
+func (c CurrentObjects) Create(ctx context.Context, cl client.Client, opts Options) error {
+	// Communities must exist before the BGPAdvertisements that reference them.
+	for _, community := range c.CommunityList.Items {
+		err := createOrMergeCommunity(ctx, cl, community)
+		if err != nil {
+			return fmt.Errorf("cannot create currentObject Community '%s', err: %w", community.Name,
+				classifyAPIError(err))
+		}
+	}
 	for _, iap := range c.IPAddressPoolList.Items {
-		err := cl.Create(context.TODO(), &iap)
+		err := createObject(ctx, cl, &iap, opts)
 		if err != nil {
-			return fmt.Errorf("cannot create currentObject IPAddressPool '%s', err: %w", iap.Name, err)
+			return fmt.Errorf("cannot create currentObject IPAddressPool '%s', err: %w", iap.Name,
+				classifyAPIError(err))
 		}
 	}
 	for _, ba := range c.BGPAdvertisementList.Items {
-		err := cl.Create(context.TODO(), &ba)
+		err := createObject(ctx, cl, &ba, opts)
 		if err != nil {
-			return fmt.Errorf("cannot create currentObject BGPAdvertisement '%s', err: %w", ba.Name, err)
+			return fmt.Errorf("cannot create currentObject BGPAdvertisement '%s', err: %w", ba.Name,
+				classifyAPIError(err))
 		}
 	}
 	for _, l2a := range c.L2AdvertisementList.Items {
-		err := cl.Create(context.TODO(), &l2a)
+		err := createObject(ctx, cl, &l2a, opts)
 		if err != nil {
-			return fmt.Errorf("cannot create currentObject L2Advertisement '%s', err: %w", l2a.Name, err)
+			return fmt.Errorf("cannot create currentObject L2Advertisement '%s', err: %w", l2a.Name,
+				classifyAPIError(err))
 		}
 	}
 	return nil
 }
 
 // PrintObjects outputs the YAML or JSON representation of the objects (currentObjects or legacyObjects) either to the
-// targetDirectory or to stdout if targetDirectory == "".
-func (objects CurrentObjects) Print(targetDirectory string, toJSON bool) error {
-	outWriter := stdout
-	var printer printers.ResourcePrinter = &printers.YAMLPrinter{}
+// targetDirectory or to stdout if targetDirectory == "". The objects are grouped into files as set by the
+// OutputGroupBy of opts, and the files are laid out as set by its OutputLayout.
+func (objects CurrentObjects) Print(targetDirectory string, toJSON bool, opts Options) error {
+	groups, err := objects.outputGroups(targetDirectory != "", nil, opts)
+	if err != nil {
+		return err
+	}
+	return printGroups(targetDirectory, groups, toJSON, opts)
+}
+
+// outputGroups returns the objects grouped into files as set by the OutputGroupBy and the OutputLayout of opts,
+// followed by the extra objects of other kinds, which are grouped by kind. toDirectory is false if the objects are
+// printed to stdout, where the layout does not apply.
+func (objects CurrentObjects) outputGroups(toDirectory bool, extra [][]runtime.Object,
+	opts Options) ([]outputGroup, error) {
+	var groups []outputGroup
+	if opts.OutputGroupBy == OutputGroupByPool {
+		groups = objects.runtimeObjectsByPool()
+	} else {
+		kindGroups, err := objects.runtimeObjectsByKind()
+		if err != nil {
+			return nil, err
+		}
+		extra = append(kindGroups, extra...)
+	}
+	if toDirectory && opts.OutputLayout == OutputLayoutPerKindDir {
+		perKind, err := runtimeObjectsPerKindDir(extra)
+		if err != nil {
+			return nil, err
+		}
+		return append(groups, perKind...), nil
+	}
+	for _, runtimeObjects := range extra {
+		if len(runtimeObjects) == 0 {
+			continue
+		}
+		// We know that we have a least one element, get its type.
+		kind := runtimeObjects[0].GetObjectKind().GroupVersionKind().Kind
+		groups = append(groups, outputGroup{name: kind, objects: runtimeObjects})
+	}
+	return groups, nil
+}
+
+// printGroups writes one file per group to targetDirectory, or all groups to stdout if targetDirectory == "".
+func printGroups(targetDirectory string, groups []outputGroup, toJSON bool, opts Options) error {
+	if targetDirectory == "" {
+		var runtimeObjects []runtime.Object
+		for _, group := range groups {
+			runtimeObjects = append(runtimeObjects, group.objects...)
+		}
+		return printObjects(stdout, runtimeObjects, toJSON, opts)
+	}
+	fileExtension := "yaml"
 	if toJSON {
-		printer = &printers.JSONPrinter{}
+		fileExtension = "json"
 	}
+	for _, group := range groups {
+		fileName := path.Join(targetDirectory, fmt.Sprintf("%s.%s", group.name, fileExtension))
+		if err := os.MkdirAll(path.Dir(fileName), 0755); err != nil {
+			return fmt.Errorf("cannot create destination directory, err: %w", err)
+		}
+		f, err := os.OpenFile(
+			fileName,
+			os.O_RDWR|os.O_CREATE|os.O_TRUNC,
+			0644,
+		)
+		if err != nil {
+			return fmt.Errorf("cannot create destination file, err: %w", err)
+		}
+		defer f.Close()
+		// We also must allocate a new printer each time we create a new file (for consistency with "---").
+		if err := printObjects(f, group.objects, toJSON, opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Encode writes the YAML or JSON representation of all objects to w, in the target API group of opts.
+func (objects CurrentObjects) Encode(w io.Writer, toJSON bool, opts Options) error {
+	groups, err := objects.runtimeObjectsByKind()
+	if err != nil {
+		return err
+	}
+	var runtimeObjects []runtime.Object
+	for _, group := range groups {
+		runtimeObjects = append(runtimeObjects, group...)
+	}
+	return printObjects(w, runtimeObjects, toJSON, opts)
+}
+
+// runtimeObjectsByKind returns the objects as runtime.Objects, grouped by kind in field order. Kinds without objects
+// are skipped.
+func (objects CurrentObjects) runtimeObjectsByKind() ([][]runtime.Object, error) {
+	var groups [][]runtime.Object
 	// Iterate over all fields in the struct.
 	v := reflect.ValueOf(objects)
 	for i := 0; i < v.NumField(); i++ {
 		// We expect that each field is a pointer to a List, so it must match runtime.Object.
 		currentObject, ok := v.Field(i).Interface().(runtime.Object)
 		if !ok {
-			return fmt.Errorf("cannot convert field interface to runtime.Object, %s", v.Type().Field(i).Name)
+			return nil, fmt.Errorf("cannot convert field interface to runtime.Object, %s", v.Type().Field(i).Name)
 		}
 		// Now, reflect the List and get the length of <ListType>.Items. Skip further steps if the list is empty.
 		items := reflect.ValueOf(currentObject).Elem().FieldByName("Items")
@@ -271,50 +541,72 @@ func (objects CurrentObjects) Print(targetDirectory string, toJSON bool) error {
 			runtimeObject := t.Interface().(runtime.Object)
 			runtimeObjects = append(runtimeObjects, runtimeObject)
 		}
-		// We know that we have a least one element, get its type.
-		kind := runtimeObjects[0].GetObjectKind().GroupVersionKind().Kind
-		if targetDirectory != "" {
-			fileExtension := "yaml"
-			if toJSON {
-				fileExtension = "json"
-			}
-			f, err := os.OpenFile(
-				path.Join(targetDirectory, fmt.Sprintf("%s.%s", kind, fileExtension)),
-				os.O_RDWR|os.O_CREATE|os.O_TRUNC,
-				0644,
-			)
-			if err != nil {
-				return fmt.Errorf("cannot create destination file, err: %w", err)
-			}
-			defer f.Close()
-			outWriter = f
-			// We also must allocate a new printer each time we create a new file (for consistency with "---").
-			printer = &printers.YAMLPrinter{}
-			if toJSON {
-				printer = &printers.JSONPrinter{}
-			}
-		}
-		for _, runtimeObject := range runtimeObjects {
-			printedObj, err := printObj(runtimeObject, printer)
-			if err != nil {
-				return fmt.Errorf("cannot print object, err: %w\nruntime object: %+v", err, runtimeObject)
-			}
-			fmt.Fprint(outWriter, printedObj)
+		groups = append(groups, runtimeObjects)
+	}
+	return groups, nil
+}
+
+// printObjects writes the YAML or JSON representation of runtimeObjects to w, using a single printer. MetalLB objects
+// are printed in the API groups of opts.
+func printObjects(w io.Writer, runtimeObjects []runtime.Object, toJSON bool, opts Options) error {
+	var printer printers.ResourcePrinter = &printers.YAMLPrinter{}
+	if toJSON {
+		printer = &printers.JSONPrinter{}
+	}
+	for _, runtimeObject := range runtimeObjects {
+		printedObj, err := printObj(opts.withAPIGroup(runtimeObject), printer)
+		if err != nil {
+			return fmt.Errorf("cannot print object, err: %w\nruntime object: %+v", err, runtimeObject)
 		}
+		fmt.Fprint(w, printedObj)
+	}
+	return nil
+}
+
+// Names returns the kind/namespace/name identifiers of all objects.
+func (c CurrentObjects) Names() []string {
+	var names []string
+	for _, iap := range c.IPAddressPoolList.Items {
+		names = append(names, fmt.Sprintf("IPAddressPool/%s/%s", iap.Namespace, iap.Name))
+	}
+	for _, l2a := range c.L2AdvertisementList.Items {
+		names = append(names, fmt.Sprintf("L2Advertisement/%s/%s", l2a.Namespace, l2a.Name))
+	}
+	for _, ba := range c.BGPAdvertisementList.Items {
+		names = append(names, fmt.Sprintf("BGPAdvertisement/%s/%s", ba.Namespace, ba.Name))
+	}
+	for _, community := range c.CommunityList.Items {
+		names = append(names, fmt.Sprintf("Community/%s/%s", community.Namespace, community.Name))
+	}
+	return names
+}
+
+// PrintNames prints one kind/namespace/name identifier per line either to the targetDirectory or to stdout if
+// targetDirectory == "".
+func (c CurrentObjects) PrintNames(targetDirectory string) error {
+	outWriter, closeFn, err := outputWriter(targetDirectory, "names.txt")
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+	for _, name := range c.Names() {
+		fmt.Fprintln(outWriter, name)
 	}
 	return nil
 }
 
-// ReadLegacyObjectsFromAPI reads legacy metallb objects from the API.
-func ReadLegacyObjectsFromAPI(c client.Client, limit int) (*LegacyObjects, error) {
+// ReadLegacyObjectsFromAPI reads legacy metallb objects from the API. Only the AddressPools that match the Namespace
+// and the Selector of opts are read.
+func ReadLegacyObjectsFromAPI(ctx context.Context, c client.Client, limit int, opts Options) (*LegacyObjects, error) {
 	if limit < 0 {
 		return nil, fmt.Errorf("invalid limit %d", limit)
 	}
 
+	filter := opts.listOptions()
 	addressPoolList := &metallbv1beta1.AddressPoolList{}
-	err := c.List(context.Background(), addressPoolList, client.Limit(limit))
+	err := c.List(ctx, addressPoolList, &filter, client.Limit(limit))
 	if err != nil {
-		return nil, fmt.Errorf("failed to list AddressPools in cluster: %v\n", err)
+		return nil, fmt.Errorf("failed to list AddressPools in cluster, err: %w", classifyAPIError(err))
 	}
 	// We need the following to accomodate the fake client: https://github.com/kubernetes/client-go/issues/793
 	if limit > 0 {
@@ -324,64 +616,277 @@ func ReadLegacyObjectsFromAPI(c client.Client, limit int) (*LegacyObjects, error
 	}
 	// Get rid of metadata that we are not interested in.
 	for i := range addressPoolList.Items {
-		newObjectMeta := metav1.ObjectMeta{
-			Name:            addressPoolList.Items[i].Name,
-			Namespace:       addressPoolList.Items[i].Namespace,
-			Labels:          addressPoolList.Items[i].Labels,
-			Annotations:     addressPoolList.Items[i].Annotations,
-			OwnerReferences: addressPoolList.Items[i].OwnerReferences,
-			Finalizers:      addressPoolList.Items[i].Finalizers,
-		}
-		addressPoolList.Items[i].ObjectMeta = newObjectMeta
+		addressPoolList.Items[i].ObjectMeta = sanitizeObjectMeta(addressPoolList.Items[i].ObjectMeta)
+	}
+	bgpPeerList, bfdProfileList, err := listBGPResources(ctx, c, filter.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LegacyObjects{
+		AddressPoolList: addressPoolList,
+		BGPPeerList:     bgpPeerList,
+		BFDProfileList:  bfdProfileList,
+	}, nil
+}
+
+// listBGPResources reads the BGPPeers and BFDProfiles of namespace, or of all namespaces if it is empty, from the API.
+// They are optional, clusters whose MetalLB does not serve them have none.
+func listBGPResources(ctx context.Context, c client.Client, namespace string) (*metallbv1beta1.BGPPeerList,
+	*metallbv1beta1.BFDProfileList, error) {
+	inNamespace := client.InNamespace(namespace)
+	bgpPeerList := &metallbv1beta1.BGPPeerList{}
+	if err := c.List(ctx, bgpPeerList, inNamespace); err != nil && !meta.IsNoMatchError(err) {
+		return nil, nil, fmt.Errorf("failed to list BGPPeers in cluster: %w", err)
+	}
+	for i := range bgpPeerList.Items {
+		bgpPeerList.Items[i].ObjectMeta = sanitizeObjectMeta(bgpPeerList.Items[i].ObjectMeta)
+	}
+	bfdProfileList := &metallbv1beta1.BFDProfileList{}
+	if err := c.List(ctx, bfdProfileList, inNamespace); err != nil && !meta.IsNoMatchError(err) {
+		return nil, nil, fmt.Errorf("failed to list BFDProfiles in cluster: %w", err)
 	}
+	for i := range bfdProfileList.Items {
+		bfdProfileList.Items[i].ObjectMeta = sanitizeObjectMeta(bfdProfileList.Items[i].ObjectMeta)
+	}
+	return bgpPeerList, bfdProfileList, nil
+}
 
+// readLegacyObjectFromAPI reads the single AddressPool namespace/name from the API. If the AddressPool does not exist,
+// the returned LegacyObjects are empty.
+func readLegacyObjectFromAPI(ctx context.Context, c client.Client, namespace, name string) (*LegacyObjects, error) {
+	addressPoolList := &metallbv1beta1.AddressPoolList{}
+	ap := metallbv1beta1.AddressPool{}
+	err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, &ap)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to get AddressPool %s/%s in cluster: %w", namespace, name, err)
+	}
+	if err == nil {
+		ap.ObjectMeta = sanitizeObjectMeta(ap.ObjectMeta)
+		addressPoolList.Items = append(addressPoolList.Items, ap)
+	}
 	return &LegacyObjects{
 		AddressPoolList: addressPoolList,
 	}, nil
 }
 
+// sanitizeObjectMeta drops all metadata that is managed by the API server.
+func sanitizeObjectMeta(objectMeta metav1.ObjectMeta) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Name:            objectMeta.Name,
+		Namespace:       objectMeta.Namespace,
+		Labels:          objectMeta.Labels,
+		Annotations:     objectMeta.Annotations,
+		OwnerReferences: objectMeta.OwnerReferences,
+		Finalizers:      objectMeta.Finalizers,
+	}
+}
+
 // ReadLegacyObjectsFromAPI reads legacy metallb objects from a given directory.
 // A lot of the logic was derived from:
 // https://medium.com/@harshjniitr/reading-and-writing-k8s-resource-as-yaml-in-golang-81dc8c7ea800
-func ReadLegacyObjectsFromDirectory(scheme *runtime.Scheme, dir string) (*LegacyObjects, error) {
-	addressPoolList := &metallbv1beta1.AddressPoolList{}
-	files, err := os.ReadDir(dir)
+// AddressPools that are defined more than once are handled according to the DuplicatePolicy of opts.
+// Files that the IgnoreFileName of dir excludes are skipped.
+func ReadLegacyObjectsFromDirectory(scheme *runtime.Scheme, dir string, opts Options) (*LegacyObjects, error) {
+	next, err := directoryBatches(scheme, dir, opts)
+	if err != nil {
+		return nil, err
+	}
+	return readAllBatches(next, opts.DuplicatePolicy)
+}
+
+// ReadLegacyObjects reads legacy metallb objects from YAML or JSON content, in any of the formats that
+// ReadLegacyObjectsFromDirectory accepts for a single file. source names the content in error messages.
+func ReadLegacyObjects(scheme *runtime.Scheme, content []byte, source string, opts Options) (*LegacyObjects, error) {
+	decoded, err := decodeLegacyContent(scheme, content, source, opts)
 	if err != nil {
-		return nil, fmt.Errorf("could not read legacy objects from directory, err: %q", err)
+		return nil, fmt.Errorf("could not read legacy objects, err: %w", err)
+	}
+	legacyObjects, err := decoded.legacyObjects(opts.DuplicatePolicy)
+	if err != nil {
+		return nil, fmt.Errorf("could not read legacy objects, err: %w", err)
+	}
+	return legacyObjects, nil
+}
+
+// ReadLegacyObjectsFromReader reads legacy metallb objects from a YAML or JSON stream, e.g. standard input, in any of
+// the formats that ReadLegacyObjects accepts. source names the stream in error messages.
+func ReadLegacyObjectsFromReader(scheme *runtime.Scheme, r io.Reader, source string,
+	opts Options) (*LegacyObjects, error) {
+	return readAllBatches(readerBatches(scheme, r, source, 0, opts), opts.DuplicatePolicy)
+}
+
+// legacyContent holds the objects that were decoded from legacy manifests.
+type legacyContent struct {
+	pools       []sourcedAddressPool
+	bgpPeers    []metallbv1beta1.BGPPeer
+	bfdProfiles []metallbv1beta1.BFDProfile
+	// configs holds the legacy objects of every legacy ConfigMap, see decodeLegacyConfigMap.
+	configs []*LegacyObjects
+	// skipped describes the documents that were skipped with Options.IgnoreUnknown.
+	skipped []string
+}
+
+// add appends the objects of other.
+func (lc *legacyContent) add(other legacyContent) {
+	lc.pools = append(lc.pools, other.pools...)
+	lc.bgpPeers = append(lc.bgpPeers, other.bgpPeers...)
+	lc.bfdProfiles = append(lc.bfdProfiles, other.bfdProfiles...)
+	lc.configs = append(lc.configs, other.configs...)
+	lc.skipped = append(lc.skipped, other.skipped...)
+}
+
+// legacyObjects returns the decoded objects as LegacyObjects. Duplicate AddressPools are resolved according to
+// duplicatePolicy. At most one legacy ConfigMap can be converted at once. The skipped documents are logged.
+func (lc legacyContent) legacyObjects(duplicatePolicy string) (*LegacyObjects, error) {
+	if len(lc.configs) > 1 {
+		return nil, fmt.Errorf("found the legacy ConfigMaps %s and %s, only one legacy ConfigMap can be converted "+
+			"at once", lc.configs[0].configMap, lc.configs[1].configMap)
+	}
+	items, err := resolveDuplicates(lc.pools, duplicatePolicy)
+	if err != nil {
+		return nil, err
+	}
+	logSkipped(lc.skipped)
+	legacyObjects := &LegacyObjects{
+		AddressPoolList: &metallbv1beta1.AddressPoolList{Items: items},
+		BGPPeerList:     &metallbv1beta1.BGPPeerList{Items: lc.bgpPeers},
+		BFDProfileList:  &metallbv1beta1.BFDProfileList{Items: lc.bfdProfiles},
+	}
+	if len(lc.configs) == 1 {
+		config := lc.configs[0]
+		legacyObjects.legacyPeers, legacyObjects.config, legacyObjects.configMap = config.legacyPeers, config.config,
+			config.configMap
+	}
+	return legacyObjects, nil
+}
+
+// decodeLegacyContent decodes all AddressPools, BGPPeers, BFDProfiles and legacy ConfigMaps of the YAML or JSON
+// content that was read from source.
+func decodeLegacyContent(scheme *runtime.Scheme, content []byte, source string,
+	opts Options) (legacyContent, error) {
+	decode := serializer.NewCodecFactory(scheme).UniversalDeserializer().Decode
+	elements, err := splitDocuments(content)
+	if err != nil && opts.IgnoreUnknown {
+		return legacyContent{skipped: []string{fmt.Sprintf("%s: not a YAML or JSON object", source)}}, nil
+	}
+	if err != nil {
+		return legacyContent{}, fmt.Errorf("invalid file %s, err: %w", source, err)
+	}
+	var decoded legacyContent
+	for _, element := range elements {
+		if len(bytes.TrimSpace(element)) == 0 {
+			continue
+		}
+		document, err := decodeLegacyDocument(decode, element, source, opts)
+		if err != nil {
+			return legacyContent{}, err
+		}
+		decoded.add(document)
 	}
-	for _, file := range files {
-		decode := serializer.NewCodecFactory(scheme).UniversalDeserializer().Decode
-		fileContent, err := os.ReadFile(path.Join(dir, file.Name()))
+	return decoded, nil
+}
+
+// splitDocuments splits content into its documents. YAML documents are separated by "---". Content that starts with
+// "{" is read as a stream of JSON objects, such as newline-delimited JSON (NDJSON) or the converter's own JSON output.
+func splitDocuments(content []byte) ([][]byte, error) {
+	if !bytes.HasPrefix(bytes.TrimSpace(content), []byte("{")) {
+		return bytes.Split(content, []byte("\n---")), nil
+	}
+	var documents [][]byte
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	for {
+		var document json.RawMessage
+		err := decoder.Decode(&document)
+		if err == io.EOF {
+			return documents, nil
+		}
 		if err != nil {
-			return nil, fmt.Errorf("could not read legacy objects from directory, err: %q", err)
+			return nil, fmt.Errorf("cannot decode JSON stream, err: %w", err)
+		}
+		documents = append(documents, document)
+	}
+}
+
+// decodeLegacyDocument decodes a single YAML or JSON document that was read from source. The document may either be
+// an AddressPool, a v1beta1 BGPPeer, a BFDProfile, a list of one of those kinds or a v1 List of those (as produced by
+// kubectl get -o yaml). Metadata that is managed by the API server is dropped.
+func decodeLegacyDocument(decode func([]byte, *schema.GroupVersionKind, runtime.Object) (runtime.Object,
+	*schema.GroupVersionKind, error), document []byte, source string, opts Options) (legacyContent, error) {
+	if decoded, ok, err := decodeLegacyConfigMap(document, source); ok {
+		return decoded, err
+	}
+	if opts.IgnoreUnknown {
+		if unknown := unknownDocument(document, source, opts.sourceAPIGroup()); unknown != "" {
+			return legacyContent{skipped: []string{unknown}}, nil
+		}
+	}
+	typeMeta := metav1.TypeMeta{}
+	if err := yaml.Unmarshal(document, &typeMeta); err != nil {
+		return legacyContent{}, fmt.Errorf("invalid document in %s, err: %w", source, err)
+	}
+	if typeMeta.APIVersion == "v1" && typeMeta.Kind == "List" {
+		list := struct {
+			Items []json.RawMessage `json:"items"`
+		}{}
+		if err := yaml.Unmarshal(document, &list); err != nil {
+			return legacyContent{}, fmt.Errorf("invalid List in %s, err: %w", source, err)
 		}
-		elements := bytes.Split(fileContent, []byte("\n---"))
-		for _, element := range elements {
-			obj, gkv, err := decode(element, nil, nil)
+		var decoded legacyContent
+		for _, item := range list.Items {
+			content, err := decodeLegacyDocument(decode, item, source, opts)
 			if err != nil {
-				return nil, fmt.Errorf("could not read legacy objects from directory, err: %q", err)
-			}
-			if gkv.Group != metallbAPIGroup {
-				return nil, fmt.Errorf("could not read legacy objects from directory, invalid gkv.Group %q", gkv.Group)
-			}
-			if _, ok := supportedLegacyGKVVersions[gkv.Version]; !ok {
-				return nil, fmt.Errorf("could not read legacy objects from directory, invalid gkv.Version %q", gkv.Version)
-			}
-			switch gkv.Kind {
-			case "AddressPool":
-				ap := obj.(*metallbv1beta1.AddressPool)
-				addressPoolList.Items = append(addressPoolList.Items, *ap)
-			case "AddressPoolList":
-				apl := obj.(*metallbv1beta1.AddressPoolList)
-				addressPoolList.Items = append(addressPoolList.Items, apl.Items...)
-			default:
-				return nil, fmt.Errorf("could not read legacy objects from directory, unsupported GKV: %s", gkv.Kind)
+				return legacyContent{}, err
 			}
+			decoded.add(content)
 		}
+		return decoded, nil
 	}
-	return &LegacyObjects{
-		AddressPoolList: addressPoolList,
-	}, nil
+
+	obj, gkv, err := decode(document, nil, nil)
+	if err != nil {
+		return legacyContent{}, err
+	}
+	if gkv.Group != opts.sourceAPIGroup() {
+		return legacyContent{}, fmt.Errorf("%w, invalid gkv.Group %q", ErrUnsupportedKind, gkv.Group)
+	}
+	if _, ok := supportedLegacyGKVVersions[gkv.Version]; !ok {
+		return legacyContent{}, fmt.Errorf("%w, invalid gkv.Version %q", ErrUnsupportedKind, gkv.Version)
+	}
+	var decoded legacyContent
+	switch gkv.Kind {
+	case "AddressPool":
+		ap := obj.(*metallbv1beta1.AddressPool)
+		ap.ObjectMeta = sanitizeObjectMeta(ap.ObjectMeta)
+		decoded.pools = append(decoded.pools, sourcedAddressPool{addressPool: *ap, source: source})
+	case "AddressPoolList":
+		apl := obj.(*metallbv1beta1.AddressPoolList)
+		for _, ap := range apl.Items {
+			ap.ObjectMeta = sanitizeObjectMeta(ap.ObjectMeta)
+			decoded.pools = append(decoded.pools, sourcedAddressPool{addressPool: ap, source: source})
+		}
+	case "BGPPeer":
+		peer := obj.(*metallbv1beta1.BGPPeer)
+		peer.ObjectMeta = sanitizeObjectMeta(peer.ObjectMeta)
+		decoded.bgpPeers = append(decoded.bgpPeers, *peer)
+	case "BGPPeerList":
+		for _, peer := range obj.(*metallbv1beta1.BGPPeerList).Items {
+			peer.ObjectMeta = sanitizeObjectMeta(peer.ObjectMeta)
+			decoded.bgpPeers = append(decoded.bgpPeers, peer)
+		}
+	case "BFDProfile":
+		profile := obj.(*metallbv1beta1.BFDProfile)
+		profile.ObjectMeta = sanitizeObjectMeta(profile.ObjectMeta)
+		decoded.bfdProfiles = append(decoded.bfdProfiles, *profile)
+	case "BFDProfileList":
+		for _, profile := range obj.(*metallbv1beta1.BFDProfileList).Items {
+			profile.ObjectMeta = sanitizeObjectMeta(profile.ObjectMeta)
+			decoded.bfdProfiles = append(decoded.bfdProfiles, profile)
+		}
+	default:
+		return legacyContent{}, fmt.Errorf("%w %s", ErrUnsupportedKind, gkv.Kind)
+	}
+	return decoded, nil
 }
 
 // printObj converts a single runtime.Object to its YAML or JSON representation, depending on the provided
@@ -395,80 +900,769 @@ func printObj(obj runtime.Object, printer printers.ResourcePrinter) (string, err
 	return buf.String(), nil
 }
 
-// OfflineMigration runs an offline migration. In other words, it reads input from the API or from a source directory
-// and either prints it to standard out or a destination directory.
-func OfflineMigration(c client.Client, scheme *runtime.Scheme, inDirFlag string, outDirFlag string, jsonFlag bool) error {
+// outputWriter returns stdout if targetDirectory == "" and the truncated file fileName inside targetDirectory
+// otherwise. The returned function must be called once the caller is done writing.
+func outputWriter(targetDirectory, fileName string) (io.Writer, func(), error) {
+	if targetDirectory == "" {
+		return stdout, func() {}, nil
+	}
+	f, err := os.OpenFile(path.Join(targetDirectory, fileName), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot create destination file, err: %w", err)
+	}
+	return f, func() { f.Close() }, nil
+}
+
+// listAnnotatedServices returns all Services that are pinned to an address pool via ServiceAddressPoolAnnotation.
+func listAnnotatedServices(ctx context.Context, c client.Client) ([]corev1.Service, error) {
+	serviceList := &corev1.ServiceList{}
+	err := c.List(ctx, serviceList)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list Services in cluster: %w", err)
+	}
+	var services []corev1.Service
+	for _, svc := range serviceList.Items {
+		if _, ok := svc.Annotations[ServiceAddressPoolAnnotation]; ok {
+			services = append(services, svc)
+		}
+	}
+	return services, nil
+}
+
+// SetQuiet suppresses the progress log of OnlineMigration. Instead, the kind/namespace/name identifiers of all
+// created objects are printed to stdout. The setting applies to the whole process and must not be changed while
+// conversions or migrations run.
+func SetQuiet(q bool) {
+	quiet = q
+}
+
+// SetTracer sets the tracer that OfflineMigration and OnlineMigration report their phases to. A nil tracer disables
+// tracing. Like SetQuiet, it applies to the whole process and must be called before the first migration starts.
+func SetTracer(t *tracing.Tracer) {
+	tracer = t
+}
+
+// OfflineMigrationOptions tunes the behavior of OfflineMigration.
+type OfflineMigrationOptions struct {
+	// Options configures the conversion, validation and printing of the legacy objects.
+	Options
+	// FromConfigMap reads the legacy configuration from the address-pools of this legacy MetalLB ConfigMap instead
+	// of from AddressPools. Ignored if its name is empty.
+	FromConfigMap types.NamespacedName
+	// Kustomize renders the input directory with this command, e.g. DefaultKustomizeCommand, and converts the
+	// rendered resources instead of the files of the directory if it contains a kustomization. Ignored if empty.
+	Kustomize []string
+	// HelmChart renders this Helm chart with HelmCommand, e.g. DefaultHelmCommand, and converts the legacy MetalLB
+	// objects among the rendered resources instead of reading the input directory. Ignored if empty.
+	HelmChart string
+	// HelmValues are the values files that the Helm chart is rendered with.
+	HelmValues []string
+	// HelmCommand renders HelmChart.
+	HelmCommand []string
+	// CacheKey enables incremental conversions into the output directory if it is not empty. It identifies the
+	// settings of the conversion, e.g. a hash of the version and the flags. If the sources and the CacheKey match the
+	// IncrementalCacheFileName of the output directory and the outputs are unchanged, the conversion is skipped.
+	// Otherwise, only the output files whose content changes are rewritten.
+	CacheKey string
+	// Golden compares the outputs with the committed files of this directory instead of printing them. If they
+	// differ, a unified diff is printed to standard out and the conversion fails with ErrGoldenMismatch. Ignored if
+	// empty.
+	Golden string
+	// DiffCluster compares the outputs with the objects of the cluster that they would create or update instead of
+	// printing them. If they differ, a unified diff is printed to standard out and the conversion fails with
+	// ErrClusterMismatch. The BGPPeers and BFDProfiles that are converted along with the AddressPools are not
+	// compared.
+	DiffCluster bool
+	// ValidateOnly stops after the conversion step, which includes all validations, and prints nothing.
+	ValidateOnly bool
+	// Report is filled with the legacy objects that were converted, the objects generated from them and the errors
+	// of the migration. Ignored if nil.
+	Report *MigrationReport
+	// Kustomization writes a KustomizationFileName that lists the YAML or JSON output files into the output
+	// directory, so that it can be applied with kubectl apply -k, and a kustomize component that deletes the legacy
+	// AddressPools into its LegacyCleanupDir. Ignored without output directory.
+	Kustomization bool
+}
+
+// OfflineMigration runs an offline migration. In other words, it reads input from the API, from a source directory or
+// from standard input if inDirFlag is StdinInput, and either prints it to standard out or a destination directory in
+// the requested outputFormat. The API requests are bound to ctx.
+func OfflineMigration(ctx context.Context, c client.Client, scheme *runtime.Scheme, inDirFlag string,
+	outDirFlag string, outputFormat string, opts OfflineMigrationOptions) error {
+	ctx, span := tracer.Start(ctx, "OfflineMigration")
+	defer span.End()
+	opts.Report.start(MigrationModeOffline)
+	err := offlineMigration(ctx, c, scheme, inDirFlag, outDirFlag, outputFormat, opts)
+	opts.Report.finish(err)
+	span.RecordError(err)
+	return err
+}
+
+func offlineMigration(ctx context.Context, c client.Client, scheme *runtime.Scheme, inDirFlag string,
+	outDirFlag string, outputFormat string, opts OfflineMigrationOptions) error {
 	var err error
 	var legacyObjects *LegacyObjects
 	// Retrieval step.
-	if inDirFlag == "" {
-		legacyObjects, err = ReadLegacyObjectsFromAPI(c, 0)
-		if err != nil {
-			return fmt.Errorf("error during retrieval step, err: %w", err)
+	_, span := tracer.Start(ctx, "retrieve")
+	fromDirectory := inDirFlag != "" && inDirFlag != StdinInput
+	if opts.FromConfigMap.Name != "" {
+		span.SetAttribute("source", "configmap/"+opts.FromConfigMap.String())
+		legacyObjects, err = ReadLegacyObjectsFromConfigMap(ctx, c, opts.FromConfigMap)
+	} else if opts.HelmChart != "" {
+		span.SetAttribute("source", "helm/"+opts.HelmChart)
+		var rendered []byte
+		rendered, err = RenderHelmChart(opts.HelmCommand, opts.HelmChart, opts.HelmValues, opts.Options)
+		if err == nil {
+			legacyObjects, err = ReadLegacyObjects(scheme, rendered, "Helm chart "+opts.HelmChart, opts.Options)
+		}
+	} else if fromDirectory && len(opts.Kustomize) > 0 && IsKustomization(inDirFlag) {
+		span.SetAttribute("source", "kustomize/"+inDirFlag)
+		var rendered []byte
+		rendered, err = RenderKustomization(opts.Kustomize, inDirFlag)
+		if err == nil {
+			legacyObjects, err = ReadLegacyObjects(scheme, rendered, "kustomization "+inDirFlag, opts.Options)
 		}
 	} else {
-		legacyObjects, err = ReadLegacyObjectsFromDirectory(scheme, inDirFlag)
+		// The API, standard input and directories are read with the decoder of StreamingMigration, in a single batch.
+		switch inDirFlag {
+		case "":
+			span.SetAttribute("source", "api")
+		case StdinInput:
+			span.SetAttribute("source", "stdin")
+		default:
+			span.SetAttribute("source", inDirFlag)
+		}
+		var next legacyBatches
+		next, err = newLegacyBatches(ctx, c, scheme, inDirFlag, 0, opts.Options)
+		if err == nil {
+			legacyObjects, err = readAllBatches(next, opts.DuplicatePolicy)
+		}
+		if err != nil && fromDirectory && IsKustomization(inDirFlag) {
+			err = fmt.Errorf("input directory %q contains a kustomization that must be rendered first, err: %w",
+				inDirFlag, err)
+		}
+	}
+	span.RecordError(err)
+	span.End()
+	if err != nil {
+		return fmt.Errorf("error during retrieval step, err: %w", err)
+	}
+	if !quiet {
+		for _, group := range IdenticalAddressPools(legacyObjects) {
+			if opts.MergeIdenticalPools {
+				log.Printf("merging AddressPools %s with identical addresses into IPAddressPool %s",
+					strings.Join(group, ", "), group[0])
+			} else {
+				log.Printf("AddressPools %s have identical addresses, consider merging them", strings.Join(group, ", "))
+			}
+		}
+	}
+	var cache *incrementalCache
+	var sources map[string]string
+	if opts.CacheKey != "" && outDirFlag != "" {
+		sources, err = sourceHashes(legacyObjects)
 		if err != nil {
 			return fmt.Errorf("error during retrieval step, err: %w", err)
 		}
+		cache = loadIncrementalCache(outDirFlag)
+		if cache.upToDate(outDirFlag, opts.CacheKey, sources) {
+			if !quiet {
+				log.Printf("the sources of output directory %s are unchanged, skipping the conversion", outDirFlag)
+			}
+			opts.Report.addPools(legacyObjects, MigrationSkipped, "the sources of the output directory are unchanged",
+				nil)
+			return nil
+		}
 	}
-	// Conversion step.
-	currentObjects, err := legacyObjects.Convert()
+	// Conversion step. Community aliases and BGPPeers can only be looked up when we are connected to a cluster.
+	fromCluster := inDirFlag == "" && opts.HelmChart == ""
+	_, span = tracer.Start(ctx, "convert")
+	currentObjects, configObjects, err := convertLegacyObjects(ctx, c, legacyObjects, fromCluster, opts.Options)
+	if err == nil && fromCluster {
+		var checks clusterChecks
+		checks.add(legacyObjects, currentObjects)
+		err = checks.run(ctx, c, opts.SimulateAssignments)
+	}
+	span.RecordError(err)
+	span.End()
 	if err != nil {
+		opts.Report.addPools(legacyObjects, MigrationFailed, "", err)
 		return fmt.Errorf("error during conversion step, err: %w", err)
 	}
+	opts.observeConversion(legacyObjects, currentObjects)
+	opts.Report.addConversion(legacyObjects, currentObjects, configObjects, legacyObjects.configMap)
+	if opts.ValidateOnly {
+		if !quiet {
+			log.Printf("the conversion of %d AddressPools into %d resources is valid",
+				len(legacyObjects.AddressPoolList.Items), len(currentObjects.Names()))
+		}
+		return nil
+	}
 
 	// Print step.
-	err = currentObjects.Print(outDirFlag, jsonFlag)
+	_, span = tracer.Start(ctx, "print")
+	span.SetAttribute("format", outputFormat)
+	defer span.End()
+	// Incremental conversions print into a temporary directory first, to only rewrite the outputs that change, and
+	// golden conversions to compare the outputs with the golden directory.
+	printDir := outDirFlag
+	if cache != nil || opts.Golden != "" || opts.DiffCluster {
+		printDir, err = os.MkdirTemp("", "metallb-converter-")
+		if err != nil {
+			span.RecordError(err)
+			return fmt.Errorf("error during print step, err: %w", err)
+		}
+		defer os.RemoveAll(printDir)
+	}
+	switch outputFormat {
+	case OutputFormatYAML, OutputFormatJSON:
+		var groups []outputGroup
+		if opts.DiffCluster {
+			configObjects = nil
+		}
+		groups, err = currentObjects.outputGroups(printDir != "", configObjects, opts.Options)
+		if err == nil {
+			err = printGroups(printDir, groups, outputFormat == OutputFormatJSON, opts.Options)
+		}
+		if err == nil && opts.Kustomization && printDir != "" {
+			err = writeKustomizations(printDir, groups, outputFormat == OutputFormatJSON, legacyObjects, opts.Options)
+		}
+	case OutputFormatDot, OutputFormatMermaid:
+		// Services can only be looked up when we are connected to a cluster.
+		var services []corev1.Service
+		if fromCluster {
+			services, err = listAnnotatedServices(ctx, c)
+			if err != nil {
+				span.RecordError(err)
+				return fmt.Errorf("error during retrieval step, err: %w", err)
+			}
+		}
+		err = currentObjects.PrintGraph(printDir, outputFormat, services)
+	case OutputFormatCSV:
+		err = currentObjects.PrintCSV(printDir)
+	case OutputFormatName:
+		err = currentObjects.PrintNames(printDir)
+	case OutputFormatCapacity:
+		err = currentObjects.PrintCapacity(printDir)
+	default:
+		err = fmt.Errorf("unsupported output format %q", outputFormat)
+	}
+	if err == nil && cache != nil {
+		cache.Outputs, err = syncOutputs(printDir, outDirFlag, cache.Outputs)
+		if err == nil {
+			cache.Key, cache.Sources = opts.CacheKey, sources
+			err = cache.save(outDirFlag)
+		}
+	}
+	span.RecordError(err)
 	if err != nil {
 		return fmt.Errorf("error during print step, err: %w", err)
 	}
+	if opts.Golden != "" {
+		return checkGolden(printDir, opts.Golden)
+	}
+	if opts.DiffCluster {
+		return checkCluster(ctx, c, currentObjects, printDir, outputFormat == OutputFormatJSON, opts.Options)
+	}
 	return nil
 }
 
+// convertLegacyObjects runs the conversion step of offline and streaming migrations: it converts legacyObjects and
+// validates the converted objects. The sections of a legacy ConfigMap besides its address-pools, and the BGPPeers and
+// BFDProfiles that were read along with the AddressPools, are converted into the returned objects, which are printed
+// along with the other objects. fromCluster tells whether the legacy objects were read from the cluster, whose
+// Community CRs are then resolved and whose owned AddressPools are reported.
+func convertLegacyObjects(ctx context.Context, c client.Client, legacyObjects *LegacyObjects, fromCluster bool,
+	opts Options) (*CurrentObjects, [][]runtime.Object, error) {
+	currentObjects, err := legacyObjects.Convert(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	if fromCluster {
+		if err := currentObjects.ResolveCommunityAliases(ctx, c, opts.ExpandCommunityAliases); err != nil {
+			return nil, nil, err
+		}
+		warnOwnedAddressPools(legacyObjects, opts.OperatorCompat)
+	}
+	configObjects, err := legacyObjects.convertLegacyConfig(currentObjects, legacyObjects.configMap.Namespace,
+		opts.PeerPasswords)
+	if err == nil {
+		var bgpObjects [][]runtime.Object
+		bgpObjects, err = legacyObjects.convertBGPResources(opts.PeerPasswords)
+		configObjects = append(configObjects, bgpObjects...)
+	}
+	if err == nil {
+		err = currentObjects.checkLocalPrefs(opts.FailOnLocalPrefConflicts)
+	}
+	if err == nil {
+		err = checkValidate(legacyObjects, currentObjects, opts.StrictValidation)
+	}
+	if err == nil {
+		err = currentObjects.validateSchema(opts.ValidateAgainst)
+	}
+	if err == nil {
+		err = currentObjects.validateWithDryRun(ctx, opts.DryRunValidator)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return currentObjects, configObjects, nil
+}
+
+// clusterChecks collects what the checks of offline and streaming migrations against the cluster need of the converted
+// objects: the number of BGPAdvertisements and legacy peers, and the address ranges of the legacy and the converted
+// pools. Streaming migrations add every batch, so that the checks see all AddressPools without holding them in memory.
+type clusterChecks struct {
+	advertisements int
+	legacyPeers    int
+	legacyPools    []simulatedPool
+	convertedPools []simulatedPool
+	// converted holds the namespace/name of every converted IPAddressPool.
+	converted map[string]bool
+}
+
+// add adds the legacy objects of a batch and the objects that were converted from them.
+func (cc *clusterChecks) add(legacy *LegacyObjects, current *CurrentObjects) {
+	if cc.converted == nil {
+		cc.converted = map[string]bool{}
+	}
+	cc.advertisements += len(current.BGPAdvertisementList.Items)
+	cc.legacyPeers += legacy.legacyPeers
+	cc.legacyPools = append(cc.legacyPools, legacySimulatedPools(legacy)...)
+	for _, iap := range current.IPAddressPoolList.Items {
+		cc.converted[fmt.Sprintf("%s/%s", iap.Namespace, iap.Name)] = true
+		cc.convertedPools = append(cc.convertedPools, newSimulatedPool(iap.Name, iap.Spec.Addresses,
+			iap.Spec.AutoAssign, iap.Spec.AvoidBuggyIPs))
+	}
+}
+
+// run logs a warning if the MetalLB Operator manages the cluster or if the converted BGPAdvertisements have no peers,
+// and checks the LoadBalancer Services of the cluster against the added pools, see checkServices.
+func (cc *clusterChecks) run(ctx context.Context, c client.Client, simulate bool) error {
+	if err := warnMetalLBOperator(ctx, c); err != nil {
+		return err
+	}
+	if err := checkBGPPeers(ctx, c, cc.advertisements, cc.legacyPeers); err != nil {
+		return err
+	}
+	return cc.checkServices(ctx, c, simulate)
+}
+
+// OnlineMigrationOptions tunes the behavior of OnlineMigration.
+type OnlineMigrationOptions struct {
+	// Options configures the conversion of the legacy AddressPools and the creation of the new objects.
+	Options
+	// KeepLegacy creates the new objects but keeps the legacy AddressPools instead of deleting them. Migrated
+	// AddressPools are marked with MigratedAnnotation.
+	KeepLegacy bool
+	// DeleteInterval is the minimum time between the deletion (or marking) of two legacy AddressPools.
+	DeleteInterval time.Duration
+	// CreateInterval is the minimum time between the creation of the new objects of two AddressPools.
+	CreateInterval time.Duration
+	// Stop requests a graceful stop when it is done: the AddressPool that is being migrated is migrated completely,
+	// but no further AddressPools are started. The migration then returns an error that wraps ErrMigrationStopped.
+	Stop context.Context
+	// CreateFirst creates the new objects of an AddressPool first, waits until they are accepted by the API and only
+	// then deletes the AddressPool, so that its addresses stay configured throughout the migration. With the
+	// WebhookBypass feature gate, an AddressPool whose new objects an admission webhook rejects while it exists is
+	// deleted first instead. Ignored if KeepLegacy is set.
+	CreateFirst bool
+	// AcceptTimeout is the time that CreateFirst waits for the new objects to be accepted. The AddressPool is kept and
+	// the new objects are removed if they are not accepted in time. Defaults to DefaultAcceptTimeout.
+	AcceptTimeout time.Duration
+	// Report is filled with the legacy AddressPools that were migrated or skipped, the objects generated from them
+	// and the errors of the migration. Ignored if nil.
+	Report *MigrationReport
+	// StateFile is the journal of the migration. It records which AddressPools were migrated and which are in
+	// progress, so that a migration that is run again after it was interrupted skips the migrated AddressPools and
+	// completes the ones in progress, even if they were already deleted. Ignored if empty.
+	StateFile string
+	// WaitServices waits after the migration of an AddressPool until every LoadBalancer Service that had an IP of the
+	// AddressPool is assigned an IP again, before the next AddressPool is migrated. An outage caused by a pool is
+	// then limited to its Services instead of all Services of the cluster.
+	WaitServices bool
+	// ServiceTimeout is the time that WaitServices waits for the Services of an AddressPool. The migration stops if
+	// they do not get an IP in time. Defaults to DefaultServiceTimeout.
+	ServiceTimeout time.Duration
+	// AccessReviewer makes the preflight checks of the migration verify that the user may create the new objects and
+	// delete (or mark) the AddressPools. The permissions are not checked if it is nil.
+	AccessReviewer AccessReviewer
+	// EventRecorder records Kubernetes Events about every AddressPool whose new resources were created, that was
+	// deleted or marked as migrated, or whose migration failed, e.g. the recorder of NewEventRecorder. No Events are
+	// recorded if it is nil.
+	EventRecorder record.EventRecorder
+}
+
 // OnlineMigration exectues online migration. It will migrate legacy API resources one by one to their current API
-// counterparts.
-// Currently, this function cannot roll back. In case of failure, modified objects will be left as is.
-func OnlineMigration(c client.Client, scheme *runtime.Scheme, backupDirFlag string, jsonFlag bool) error {
+// counterparts. AddressPools that carry MigratedAnnotation were migrated by a previous run and are skipped.
+// Currently, this function cannot roll back. In case of failure, modified objects will be left as is. The API
+// requests are bound to ctx; unlike opts.Stop, canceling ctx aborts the migration of the AddressPool in flight.
+func OnlineMigration(ctx context.Context, c client.Client, scheme *runtime.Scheme, backupDirFlag string,
+	jsonFlag bool, opts OnlineMigrationOptions) error {
+	ctx, span := tracer.Start(ctx, "OnlineMigration")
+	defer span.End()
+	opts.Report.start(MigrationModeOnline)
+	err := onlineMigration(ctx, c, scheme, backupDirFlag, jsonFlag, opts)
+	opts.Report.finish(err)
+	span.RecordError(err)
+	return err
+}
+
+func onlineMigration(ctx context.Context, c client.Client, scheme *runtime.Scheme, backupDirFlag string,
+	jsonFlag bool, opts OnlineMigrationOptions) error {
 	// Backup as an individual step. This avoids issues with file truncation later down the road and the
 	// additional API call shouldn't hurt.
-	legacyObjects, err := ReadLegacyObjectsFromAPI(c, 0)
+	_, span := tracer.Start(ctx, "backup")
+	span.SetAttribute("backup-dir", backupDirFlag)
+	legacyObjects, err := ReadLegacyObjectsFromAPI(ctx, c, 0, opts.Options)
 	if err != nil {
+		span.RecordError(err)
+		span.End()
 		return fmt.Errorf("error during retrieval step, err: %w", err)
 	}
-	err = legacyObjects.Print(backupDirFlag, jsonFlag)
+	// Fail fast if the migration cannot run to completion, before the backup is written or anything is modified.
+	if err := preflight(ctx, c, pendingAddressPools(legacyObjects), opts); err != nil {
+		span.RecordError(err)
+		span.End()
+		return fmt.Errorf("error during preflight step, err: %w", err)
+	}
+
+	// The journal of an interrupted migration, if any.
+	state, err := loadMigrationState(opts.StateFile)
+	if err != nil {
+		span.RecordError(err)
+		span.End()
+		return fmt.Errorf("error during resume step, err: %w", err)
+	}
+	// A resumed migration keeps the backup that the interrupted one wrote before it modified anything.
+	keepBackup := false
+	if backupDirFlag != "" && len(legacyObjects.AddressPoolList.Items) > 0 && state != nil && len(state.Pools) > 0 {
+		keepBackup = backupCovers(scheme, backupDirFlag, legacyObjects, opts.Options)
+	}
+	if keepBackup && !quiet {
+		log.Printf("keeping the backup of the interrupted migration in %s", backupDirFlag)
+	}
+	if backupDirFlag != "" && len(legacyObjects.AddressPoolList.Items) > 0 && !keepBackup {
+		// Check the backup directory against the size of the backup before anything is written or deleted.
+		backup := &bytes.Buffer{}
+		err = legacyObjects.encode(backup, jsonFlag, opts.Options)
+		if err == nil {
+			err = checkBackupDir(backupDirFlag, int64(backup.Len()))
+		}
+	}
+	if err == nil {
+		err = opts.FailurePoints.inject(FailurePointBackup)
+	}
+	if err == nil && !keepBackup {
+		err = runOperation(ctx, c, opts.OperationPolicies, OperationBackup, func(ctx context.Context, _ client.Client) error {
+			return legacyObjects.writeBackup(ctx, backupDirFlag, "AddressPool", jsonFlag, opts.Options)
+		})
+	}
+	span.RecordError(err)
+	span.End()
 	if err != nil {
-		log.Fatal(err)
+		return fmt.Errorf("error during backup step, err: %w", err)
+	}
+
+	// Validate the community alias references, localPrefs, BGPPeers and names of all pools before anything is
+	// modified.
+	_, span = tracer.Start(ctx, "validate")
+	pending := pendingAddressPools(legacyObjects)
+	err = rejectMergeGroups(pending)
+	var pendingObjects *CurrentObjects
+	if err == nil {
+		pendingObjects, err = pending.Convert(opts.Options)
+	}
+	if err == nil {
+		err = pendingObjects.ResolveCommunityAliases(ctx, c, opts.ExpandCommunityAliases)
+	}
+	if err == nil {
+		err = pendingObjects.checkLocalPrefs(opts.FailOnLocalPrefConflicts)
+	}
+	if err == nil {
+		err = pendingObjects.validateSchema(opts.ValidateAgainst)
+	}
+	if err == nil {
+		err = checkBGPPeers(ctx, c, len(pendingObjects.BGPAdvertisementList.Items), 0)
+	}
+	if err == nil {
+		err = warnMetalLBOperator(ctx, c)
+	}
+	if err == nil {
+		warnOwnedAddressPools(pending, opts.OperatorCompat)
+	}
+	if err == nil {
+		warnManagedAddressPools(pending)
+	}
+	if err == nil {
+		err = checkServices(ctx, c, legacyObjects, pendingObjects, opts.SimulateAssignments)
+	}
+	if err == nil {
+		err = checkExistingNames(ctx, c, pending, pendingObjects, state, opts.Options)
+	}
+	span.RecordError(err)
+	span.End()
+	if err != nil {
+		opts.Report.addPools(pending, MigrationFailed, "", err)
+		return fmt.Errorf("error during conversion step, err: %w", err)
+	}
+
+	// Complete the AddressPools that an interrupted migration deleted but did not recreate.
+	if err := state.resumeDeleted(ctx, c, legacyObjects, opts); err != nil {
+		return fmt.Errorf("error during resume step, err: %w", err)
 	}
 
 	// Now, retrieve, convert, delete and recreate one by one.
-	for {
-		// Retrieval step.
-		legacyObjects, err := ReadLegacyObjectsFromAPI(c, 1)
+	pacers := newMigrationPacers(opts)
+	for i, ap := range legacyObjects.AddressPoolList.Items {
+		if isMigrated(ap) || state.migrated(ap.Namespace, ap.Name) {
+			if !quiet {
+				log.Printf("skipping AddressPool %s/%s, it was already migrated", ap.Namespace, ap.Name)
+			}
+			opts.Report.addPool(ap.Namespace, ap.Name, MigrationSkipped, "already migrated", nil, nil)
+			continue
+		}
+		err := pacers.stop.Err()
+		if err == nil {
+			err = migratePool(ctx, c, ap.Namespace, ap.Name, opts, pacers, state)
+		} else {
+			err = fmt.Errorf("%w before AddressPool %s/%s, run the migration again to migrate the remaining "+
+				"AddressPools", ErrMigrationStopped, ap.Namespace, ap.Name)
+		}
 		if err != nil {
-			return fmt.Errorf("error during retrieval step, err: %w", err)
+			// The AddressPool in flight is recorded by migratePool unless the migration was stopped before it.
+			rest, reason := legacyObjects.AddressPoolList.Items[i+1:], "a previous AddressPool failed"
+			if errors.Is(err, ErrMigrationStopped) {
+				rest, reason = legacyObjects.AddressPoolList.Items[i:], "the migration was stopped"
+			}
+			for _, ap := range rest {
+				opts.Report.addPool(ap.Namespace, ap.Name, MigrationSkipped, reason, nil, nil)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// pendingAddressPools returns the AddressPools of l that were not migrated by a previous run.
+func pendingAddressPools(l *LegacyObjects) *LegacyObjects {
+	pending := &LegacyObjects{AddressPoolList: &metallbv1beta1.AddressPoolList{}}
+	for _, ap := range l.AddressPoolList.Items {
+		if !isMigrated(ap) {
+			pending.AddressPoolList.Items = append(pending.AddressPoolList.Items, ap)
+		}
+	}
+	return pending
+}
+
+// migratePool retrieves, converts, deletes (or marks) and recreates a single AddressPool. The deletion and the
+// creation wait for their pacers. The result is recorded in opts.Report, unless the migration was stopped before the
+// AddressPool, and its progress in state.
+func migratePool(ctx context.Context, c client.Client, namespace, name string, opts OnlineMigrationOptions,
+	pacers migrationPacers, state *migrationState) (err error) {
+	var currentObjects *CurrentObjects
+	skipped := false
+	start := time.Now()
+	// eventObject is the AddressPool that Events are recorded about, it is replaced by the AddressPool of the API once
+	// it was read.
+	var eventObject client.Object = &metallbv1beta1.AddressPool{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+	}
+	defer func() {
+		var generated []ComplianceResource
+		if currentObjects != nil {
+			for _, obj := range currentObjects.objects() {
+				generated = append(generated, reportResource(obj))
+			}
 		}
-		if len(legacyObjects.AddressPoolList.Items) == 0 {
-			break
+		switch {
+		case skipped:
+			opts.Report.addPool(namespace, name, MigrationSkipped, "deleted or migrated by a concurrent run", nil, nil)
+		case errors.Is(err, ErrMigrationStopped):
+		case err != nil:
+			opts.Report.addPool(namespace, name, MigrationFailed, "", generated, err)
+			opts.observeMigration(namespace, name, time.Since(start), err)
+			opts.event(ctx, eventObject, corev1.EventTypeWarning, EventReasonMigrationFailed,
+				"Migration of AddressPool %s/%s failed: %v", namespace, name, err)
+		default:
+			opts.Report.addPool(namespace, name, MigrationMigrated, "", generated, nil)
+			opts.observeMigration(namespace, name, time.Since(start), nil)
 		}
+	}()
+	ctx, poolSpan := tracer.Start(ctx, "migrate-pool")
+	defer poolSpan.End()
+	poolSpan.SetAttribute("addresspool", fmt.Sprintf("%s/%s", namespace, name))
+
+	// Retrieval step.
+	_, span := tracer.Start(ctx, "retrieve")
+	legacyObjects, err := readLegacyObjectFromAPI(ctx, c, namespace, name)
+	span.RecordError(err)
+	span.End()
+	if err != nil {
+		poolSpan.RecordError(err)
+		return fmt.Errorf("error during retrieval step, err: %w", err)
+	}
+	// The AddressPool was deleted in the meantime or was marked as migrated by a concurrent run.
+	if len(legacyObjects.AddressPoolList.Items) == 0 || isMigrated(legacyObjects.AddressPoolList.Items[0]) {
+		skipped = true
+		return nil
+	}
+	eventObject = legacyObjects.AddressPoolList.Items[0].DeepCopy()
+
+	if !quiet {
+		log.Printf("migrating AddressPool %s/%s ...", namespace, name)
+	}
+
+	// An interrupted migration of the AddressPool may have created some of its resources already.
+	resuming := false
+	if pool := state.lookup(namespace, name); pool != nil && pool.State == StateInProgress {
+		resuming = true
+	}
 
-		log.Printf("migrating AddressPool %s/%s ...", legacyObjects.AddressPoolList.Items[0].Namespace,
-			legacyObjects.AddressPoolList.Items[0].Name)
+	// Conversion step.
+	_, span = tracer.Start(ctx, "convert")
+	currentObjects, err = legacyObjects.Convert(opts.Options)
+	if err == nil {
+		err = currentObjects.ResolveCommunityAliases(ctx, c, opts.ExpandCommunityAliases)
+	}
+	if err == nil && !opts.ServerSideApply && !resuming {
+		err = currentObjects.resolveExistingNames(ctx, c, opts.Options)
+	}
+	span.RecordError(err)
+	span.End()
+	if err != nil {
+		poolSpan.RecordError(err)
+		return fmt.Errorf("error during conversion step, err: %w", err)
+	}
 
-		// Conversion step.
-		currentObjects, err := legacyObjects.Convert()
+	// The Services that are assigned an IP of the AddressPool before it is migrated.
+	var services []types.NamespacedName
+	if opts.WaitServices {
+		services, err = poolServices(ctx, c, legacyObjects.AddressPoolList.Items[0])
 		if err != nil {
-			return fmt.Errorf("error during conversion step, err: %w", err)
+			poolSpan.RecordError(err)
+			return fmt.Errorf("error during service lookup step, err: %w", err)
 		}
+	}
 
-		// Migration step.
-		err = legacyObjects.Delete(c)
+	// Migration step.
+	if err := pacers.delete.wait(pacers.stop); err != nil {
+		return fmt.Errorf("%w before AddressPool %s/%s, run the migration again to migrate the remaining "+
+			"AddressPools", ErrMigrationStopped, namespace, name)
+	}
+	createClient := c
+	if resuming {
+		createClient = &resumingClient{Client: c}
+	}
+	if err := state.start(legacyObjects.AddressPoolList.Items[0]); err != nil {
+		return err
+	}
+	create := func() error {
+		_, span := tracer.Start(ctx, "create")
+		// A stop request ends the wait but the pool is always completed.
+		_ = pacers.create.wait(pacers.stop)
+		err := opts.FailurePoints.inject(FailurePointBeforeCreate)
+		if err == nil {
+			err = runOperation(ctx, createClient, opts.OperationPolicies, OperationCreate,
+				func(ctx context.Context, cl client.Client) error {
+					return currentObjects.Create(ctx, cl, opts.Options)
+				})
+		}
+		span.RecordError(err)
+		span.End()
 		if err != nil {
+			poolSpan.RecordError(err)
+			return fmt.Errorf("online migration failed during current object creation, err: %w", err)
+		}
+		return nil
+	}
+	created := false
+	if opts.CreateFirst && !opts.KeepLegacy {
+		// Only what the AddressPool adds to the API is removed again if its new objects are not accepted.
+		var added *CurrentObjects
+		added, err = currentObjects.addedObjects(ctx, c, resuming)
+		if err != nil {
+			poolSpan.RecordError(err)
+			return fmt.Errorf("online migration failed before current object creation, err: %w", err)
+		}
+		err = create()
+		if err == nil {
+			_, span := tracer.Start(ctx, "accept")
+			err = currentObjects.waitAccepted(ctx, c, opts.AcceptTimeout)
+			span.RecordError(err)
+			span.End()
+			if err != nil {
+				poolSpan.RecordError(err)
+				return rollbackUnaccepted(ctx, c, state, added, namespace, name, err, opts)
+			}
+		}
+		if errors.Is(err, ErrWebhookRejected) && features.Enabled(features.WebhookBypass) {
+			if !quiet {
+				log.Printf("warning: the converted resources of AddressPool %s/%s were rejected while it exists, "+
+					"deleting it first, err: %v", namespace, name, err)
+			}
+			// Remove what the rejected attempt created, the resources are created again after the deletion.
+			err := runOperation(ctx, c, opts.OperationPolicies, OperationDelete, added.remove)
+			if err != nil {
+				return fmt.Errorf("online migration failed while removing rejected objects, err: %w", err)
+			}
+		} else if err != nil {
+			return err
+		} else {
+			created = true
+		}
+	}
+	if opts.KeepLegacy {
+		_, span = tracer.Start(ctx, "mark")
+		err = runOperation(ctx, c, opts.OperationPolicies, OperationMark, legacyObjects.MarkMigrated)
+		span.RecordError(err)
+		span.End()
+		if err != nil {
+			poolSpan.RecordError(err)
+			return fmt.Errorf("online migration failed while marking legacy object as migrated, err: %w", err)
+		}
+		opts.event(ctx, eventObject, corev1.EventTypeNormal, EventReasonMarkedMigrated,
+			"Marked legacy AddressPool %s/%s as migrated", namespace, name)
+	} else {
+		_, span = tracer.Start(ctx, "delete")
+		err = runOperation(ctx, c, opts.OperationPolicies, OperationDelete, legacyObjects.Delete)
+		if err == nil {
+			err = opts.FailurePoints.inject(FailurePointAfterDelete)
+		}
+		span.RecordError(err)
+		span.End()
+		if err != nil {
+			poolSpan.RecordError(err)
 			return fmt.Errorf("online migration failed during legacy object deletion, err: %w", err)
 		}
-		err = currentObjects.Create(c)
+		opts.event(ctx, eventObject, corev1.EventTypeNormal, EventReasonDeleted, "Deleted legacy AddressPool %s/%s",
+			namespace, name)
+	}
+	if !created {
+		if err := create(); err != nil {
+			return err
+		}
+	}
+	if err := state.finish(namespace, name); err != nil {
+		return err
+	}
+	opts.event(ctx, eventObject, corev1.EventTypeNormal, EventReasonConverted, "%s",
+		convertedMessage(namespace, name, currentObjects))
+	opts.observeConversion(legacyObjects, currentObjects)
+	if quiet {
+		for _, name := range currentObjects.Names() {
+			fmt.Fprintln(stdout, name)
+		}
+	}
+	if opts.WaitServices {
+		_, span = tracer.Start(ctx, "wait-services")
+		err = waitServices(ctx, c, services, opts.ServiceTimeout)
+		span.RecordError(err)
+		span.End()
 		if err != nil {
-			return fmt.Errorf("online migration failed during current object creation, err: %w", err)
+			poolSpan.RecordError(err)
+			return fmt.Errorf("AddressPool %s/%s was migrated, but its Services did not get an IP again, run the "+
+				"migration again to migrate the remaining AddressPools, err: %w", namespace, name, err)
 		}
 	}
 	return nil
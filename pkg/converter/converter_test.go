@@ -3,6 +3,7 @@ package converter
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -10,9 +11,12 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/andreaskaris/metallb-converter/pkg/features"
 	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/cli-runtime/pkg/printers"
 	"k8s.io/utils/pointer"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -636,7 +640,7 @@ func TestReadLegacyObjectsFromAPI(t *testing.T) {
 		}
 	}
 
-	legacyObjects, err := ReadLegacyObjectsFromAPI(c, 0)
+	legacyObjects, err := ReadLegacyObjectsFromAPI(context.TODO(), c, 0, Options{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -683,7 +687,7 @@ func TestReadLegacyObjectsFromDirectory(t *testing.T) {
 				}
 			}
 		}
-		legacyObjects, err := ReadLegacyObjectsFromDirectory(scheme, tmpDir)
+		legacyObjects, err := ReadLegacyObjectsFromDirectory(scheme, tmpDir, Options{})
 		if tc.expectedErrorString != "" && err == nil ||
 			err != nil && tc.expectedErrorString == "" ||
 			err != nil && !strings.Contains(err.Error(), tc.expectedErrorString) {
@@ -711,6 +715,7 @@ func TestOfflineMigration(t *testing.T) {
 		expectedTargetFiles map[string]string
 		expectedErrorString string
 		json                bool
+		outputFormat        string
 	}{
 		"valid test case 0": {
 			addressPoolList:     validAddressPools0,
@@ -739,6 +744,12 @@ func TestOfflineMigration(t *testing.T) {
 			expectedErrorString: "",
 			json:                false,
 		},
+		"valid test case 5": {
+			addressPoolList:     validAddressPools1,
+			expectedOutput:      "IPAddressPool/metallb-system/ap-bgp\nBGPAdvertisement/metallb-system/ap-bgp-bgp-advertisement-0\n",
+			expectedErrorString: "",
+			outputFormat:        OutputFormatName,
+		},
 	}
 	for desc, tc := range tcs {
 		c := fake.NewClientBuilder().WithScheme(scheme).Build()
@@ -768,7 +779,14 @@ func TestOfflineMigration(t *testing.T) {
 			}
 		}
 
-		err := OfflineMigration(c, scheme, sourceDir, targetDir, tc.json)
+		outputFormat := OutputFormatYAML
+		if tc.outputFormat != "" {
+			outputFormat = tc.outputFormat
+		}
+		if tc.json {
+			outputFormat = OutputFormatJSON
+		}
+		err := OfflineMigration(context.TODO(), c, scheme, sourceDir, targetDir, outputFormat, OfflineMigrationOptions{})
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -914,7 +932,7 @@ func TestOnlineMigration(t *testing.T) {
 			}
 		}
 		// Migration step.
-		err = OnlineMigration(c, scheme, backupDir, json)
+		err = OnlineMigration(context.TODO(), c, scheme, backupDir, json, OnlineMigrationOptions{})
 		if err != nil {
 			if tc.errorStr == "" || !strings.Contains(err.Error(), tc.errorStr) {
 				log.Fatalf("TestOnlineMigration(%s): expected error does not match. Expected: %q but got %q", desc,
@@ -992,24 +1010,402 @@ func TestObjectCreateAndDelete(t *testing.T) {
 			t.Fatalf("TestObjectCreateAndDelete: error building fake client, err: %q", err)
 		}
 	}
-	legacyObjects, err := ReadLegacyObjectsFromAPI(c, 0)
+	legacyObjects, err := ReadLegacyObjectsFromAPI(context.TODO(), c, 0, Options{})
 	if err != nil {
 		t.Fatalf("TestObjectCreateAndDelete: error reading legacy objects from API, err: %q", err)
 	}
-	if err := legacyObjects.Delete(c); err != nil {
+	if err := legacyObjects.Delete(context.TODO(), c); err != nil {
 		t.Fatalf("TestObjectCreateAndDelete: error deleting legacy objects from API, err: %q", err)
 	}
-	if err := legacyObjects.Create(c); err != nil {
+	if err := legacyObjects.Create(context.TODO(), c); err != nil {
 		t.Fatalf("TestObjectCreateAndDelete: error creating legacy objects again in API, err: %q", err)
 	}
-	currentObjects, err := legacyObjects.Convert()
+	currentObjects, err := legacyObjects.Convert(Options{})
 	if err != nil {
 		t.Fatalf("TestObjectCreateAndDelete: error converting legacy objects in API, err: %q", err)
 	}
-	if err := currentObjects.Create(c); err != nil {
+	if err := currentObjects.Create(context.TODO(), c, Options{}); err != nil {
 		t.Fatalf("TestObjectCreateAndDelete: error creating current objects in API, err: %q", err)
 	}
-	if err := currentObjects.Delete(c); err != nil {
+	if err := currentObjects.Delete(context.TODO(), c); err != nil {
 		t.Fatalf("TestObjectCreateAndDelete: error deleting current objects from API, err: %q", err)
 	}
 }
+
+func TestQuietOnlineMigration(t *testing.T) {
+	var scheme = runtime.NewScheme()
+	err := metallbv1beta1.AddToScheme(scheme)
+	if err != nil {
+		t.Fatalf("TestQuietOnlineMigration: error adding to scheme, err: %q", err)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+	for _, ap := range validAddressPools1 {
+		err := c.Create(context.TODO(), &ap)
+		if err != nil {
+			t.Fatalf("TestQuietOnlineMigration: error building fake client, err: %q", err)
+		}
+	}
+	stdout = bytes.NewBuffer([]byte{})
+	SetQuiet(true)
+	defer SetQuiet(false)
+	if err := OnlineMigration(context.TODO(), c, scheme, t.TempDir(), false, OnlineMigrationOptions{}); err != nil {
+		t.Fatalf("TestQuietOnlineMigration: unexpected error, err: %q", err)
+	}
+	expectedOutput := "IPAddressPool/metallb-system/ap-bgp\nBGPAdvertisement/metallb-system/ap-bgp-bgp-advertisement-0\n"
+	if fmt.Sprint(stdout) != expectedOutput {
+		t.Fatalf("TestQuietOnlineMigration: Generated output does not match expected output.\nGot\n'%s'\nExpected\n'%s'",
+			stdout, expectedOutput)
+	}
+}
+
+func TestKeepLegacyOnlineMigration(t *testing.T) {
+	var scheme = runtime.NewScheme()
+	err := metallbv1beta1.AddToScheme(scheme)
+	if err != nil {
+		t.Fatalf("TestKeepLegacyOnlineMigration: error adding to scheme, err: %q", err)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+	for _, ap := range validAddressPools0 {
+		err := c.Create(context.TODO(), &ap)
+		if err != nil {
+			t.Fatalf("TestKeepLegacyOnlineMigration: error building fake client, err: %q", err)
+		}
+	}
+	// The second run must skip all AddressPools that were marked by the first run. Otherwise, it would fail with
+	// AlreadyExists errors.
+	for run := 0; run < 2; run++ {
+		err := OnlineMigration(context.TODO(), c, scheme, t.TempDir(), false, OnlineMigrationOptions{KeepLegacy: true})
+		if err != nil {
+			t.Fatalf("TestKeepLegacyOnlineMigration(run %d): unexpected error, err: %q", run, err)
+		}
+	}
+	var addressPoolList metallbv1beta1.AddressPoolList
+	if err := c.List(context.TODO(), &addressPoolList); err != nil {
+		t.Fatalf("TestKeepLegacyOnlineMigration: cannot list AddressPools, err: %q", err)
+	}
+	if len(addressPoolList.Items) != len(validAddressPools0) {
+		t.Fatalf("TestKeepLegacyOnlineMigration: expected %d AddressPools to be kept but got %d",
+			len(validAddressPools0), len(addressPoolList.Items))
+	}
+	for _, ap := range addressPoolList.Items {
+		if _, ok := ap.Annotations[MigratedAnnotation]; !ok {
+			t.Fatalf("TestKeepLegacyOnlineMigration: AddressPool %s is not marked as migrated", ap.Name)
+		}
+	}
+	var ipAddressPoolList metallbv1beta1.IPAddressPoolList
+	if err := c.List(context.TODO(), &ipAddressPoolList); err != nil {
+		t.Fatalf("TestKeepLegacyOnlineMigration: cannot list IPAddressPools, err: %q", err)
+	}
+	if len(ipAddressPoolList.Items) != len(validAddressPools0) {
+		t.Fatalf("TestKeepLegacyOnlineMigration: expected %d IPAddressPools but got %d",
+			len(validAddressPools0), len(ipAddressPoolList.Items))
+	}
+}
+
+var kubectlDumpAddressPoolFiles = map[string]string{
+	"list.yaml": `apiVersion: v1
+items:
+- apiVersion: metallb.io/v1beta1
+  kind: AddressPool
+  metadata:
+    annotations:
+      kubectl.kubernetes.io/last-applied-configuration: |
+        {"apiVersion":"metallb.io/v1beta1","kind":"AddressPool"}
+    creationTimestamp: "2022-11-16T10:00:00Z"
+    generation: 1
+    managedFields:
+    - apiVersion: metallb.io/v1beta1
+      fieldsType: FieldsV1
+      manager: kubectl-client-side-apply
+      operation: Update
+    name: ap-l2
+    namespace: metallb-system
+    resourceVersion: "12345"
+    uid: 0b2b5a4c-6a39-4c55-9d0a-3f0e0f6b3d6e
+  spec:
+    addresses:
+    - 192.168.100.0/24
+    protocol: layer2
+  status: {}
+kind: List
+metadata:
+  resourceVersion: ""
+`,
+	"addresspoollist.json": `{
+    "apiVersion": "metallb.io/v1beta1",
+    "kind": "AddressPoolList",
+    "metadata": {"continue": "", "resourceVersion": "12345"},
+    "items": [
+        {
+            "apiVersion": "metallb.io/v1beta1",
+            "kind": "AddressPool",
+            "metadata": {"name": "ap-bgp", "namespace": "metallb-system", "resourceVersion": "12346", "uid": "x"},
+            "spec": {"addresses": ["192.168.200.0/24"], "protocol": "bgp"}
+        }
+    ]
+}
+`,
+}
+
+func TestReadLegacyObjectsFromKubectlDump(t *testing.T) {
+	var scheme = runtime.NewScheme()
+	if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestReadLegacyObjectsFromKubectlDump: error adding to scheme, err: %q", err)
+	}
+	tmpDir := t.TempDir()
+	for fileName, fileContent := range kubectlDumpAddressPoolFiles {
+		if err := os.WriteFile(path.Join(tmpDir, fileName), []byte(fileContent), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	legacyObjects, err := ReadLegacyObjectsFromDirectory(scheme, tmpDir, Options{})
+	if err != nil {
+		t.Fatalf("TestReadLegacyObjectsFromKubectlDump: unexpected error, err: %q", err)
+	}
+	if len(legacyObjects.AddressPoolList.Items) != 2 {
+		t.Fatalf("TestReadLegacyObjectsFromKubectlDump: expected 2 AddressPools but got %d",
+			len(legacyObjects.AddressPoolList.Items))
+	}
+	for _, ap := range legacyObjects.AddressPoolList.Items {
+		if ap.ResourceVersion != "" || ap.UID != "" || len(ap.ManagedFields) != 0 || ap.Generation != 0 {
+			t.Fatalf("TestReadLegacyObjectsFromKubectlDump: server side metadata was not stripped: %+v", ap.ObjectMeta)
+		}
+	}
+}
+
+func TestSplitDocuments(t *testing.T) {
+	tcs := map[string]struct {
+		content             string
+		expectedDocuments   int
+		expectedErrorString string
+	}{
+		"yaml": {
+			content:           "kind: AddressPool\n---\nkind: AddressPool\n",
+			expectedDocuments: 2,
+		},
+		"ndjson": {
+			content:           "{\"kind\":\"AddressPool\"}\n{\"kind\":\"AddressPool\"}\n{\"kind\":\"AddressPool\"}\n",
+			expectedDocuments: 3,
+		},
+		"pretty printed json stream": {
+			content:           "{\n  \"kind\": \"AddressPool\"\n}\n{\n  \"kind\": \"AddressPool\"\n}\n",
+			expectedDocuments: 2,
+		},
+		"truncated json": {
+			content:             "{\"kind\":\"AddressPool\"}\n{\"kind\":",
+			expectedErrorString: "cannot decode JSON stream",
+		},
+	}
+	for desc, tc := range tcs {
+		documents, err := splitDocuments([]byte(tc.content))
+		if tc.expectedErrorString != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.expectedErrorString) {
+				t.Fatalf("TestSplitDocuments(%s): expected error %q but got %v", desc, tc.expectedErrorString, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("TestSplitDocuments(%s): unexpected error, err: %q", desc, err)
+		}
+		if len(documents) != tc.expectedDocuments {
+			t.Fatalf("TestSplitDocuments(%s): expected %d documents but got %d", desc, tc.expectedDocuments,
+				len(documents))
+		}
+	}
+}
+
+func TestReadLegacyObjectsFromNDJSON(t *testing.T) {
+	var scheme = runtime.NewScheme()
+	if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestReadLegacyObjectsFromNDJSON: error adding to scheme, err: %q", err)
+	}
+	tmpDir := t.TempDir()
+	var ndjson string
+	for _, name := range []string{"ap-0", "ap-1", "ap-2"} {
+		ndjson += `{"apiVersion":"metallb.io/v1beta1","kind":"AddressPool","metadata":{"name":"` + name +
+			`","namespace":"metallb-system"},"spec":{"addresses":["192.168.0.0/24"],"protocol":"layer2"}}` + "\n"
+	}
+	if err := os.WriteFile(path.Join(tmpDir, "pools.ndjson"), []byte(ndjson), 0644); err != nil {
+		t.Fatal(err)
+	}
+	legacyObjects, err := ReadLegacyObjectsFromDirectory(scheme, tmpDir, Options{})
+	if err != nil {
+		t.Fatalf("TestReadLegacyObjectsFromNDJSON: unexpected error, err: %q", err)
+	}
+	if len(legacyObjects.AddressPoolList.Items) != 3 {
+		t.Fatalf("TestReadLegacyObjectsFromNDJSON: expected 3 AddressPools but got %d",
+			len(legacyObjects.AddressPoolList.Items))
+	}
+}
+
+func TestOfflineMigrationFromStdin(t *testing.T) {
+	var scheme = runtime.NewScheme()
+	if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestOfflineMigrationFromStdin: error adding to scheme, err: %q", err)
+	}
+	SetQuiet(true)
+	defer SetQuiet(false)
+	defer func() { stdin = os.Stdin }()
+	for fileName, fileContent := range kubectlDumpAddressPoolFiles {
+		stdin = strings.NewReader(fileContent)
+		out := bytes.NewBuffer([]byte{})
+		stdout = out
+		err := OfflineMigration(context.TODO(), nil, scheme, StdinInput, "", OutputFormatName, OfflineMigrationOptions{})
+		if err != nil {
+			t.Fatalf("TestOfflineMigrationFromStdin(%s): unexpected error, err: %q", fileName, err)
+		}
+		if !strings.Contains(out.String(), "IPAddressPool/metallb-system/") {
+			t.Fatalf("TestOfflineMigrationFromStdin(%s): expected converted IPAddressPools but got %q", fileName,
+				out.String())
+		}
+	}
+	stdin = strings.NewReader("kind: [AddressPool\n")
+	stdout = bytes.NewBuffer([]byte{})
+	err := OfflineMigration(context.TODO(), nil, scheme, StdinInput, "", OutputFormatYAML, OfflineMigrationOptions{})
+	if err == nil || !strings.Contains(err.Error(), "invalid document in stdin") {
+		t.Fatalf("TestOfflineMigrationFromStdin: expected an error that names stdin but got %v", err)
+	}
+}
+
+// overlapWebhookClient rejects the creation of IPAddressPools while the AddressPool of the same name exists, like the
+// validating webhook of MetalLB rejects overlapping addresses.
+type overlapWebhookClient struct {
+	client.Client
+}
+
+func (oc *overlapWebhookClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	if _, ok := obj.(*metallbv1beta1.IPAddressPool); ok {
+		err := oc.Client.Get(ctx, client.ObjectKeyFromObject(obj), &metallbv1beta1.AddressPool{})
+		if err == nil {
+			return apierrors.NewForbidden(schema.GroupResource{Group: "metallb.io", Resource: "ipaddresspools"},
+				obj.GetName(), errors.New(`admission webhook "ipaddresspoolvalidationwebhook" denied the request: `+
+					"overlapping CIDRs"))
+		}
+	}
+	return oc.Client.Create(ctx, obj, opts...)
+}
+
+func TestCreateBeforeDeleteOnlineMigration(t *testing.T) {
+	tcs := map[string]struct {
+		featureGates           string
+		failurePoints          string
+		webhook                bool
+		expectedErrorString    string
+		expectedAddressPools   int
+		expectedIPAddressPools int
+	}{
+		"created before the deletion": {
+			failurePoints:          FailurePointAfterDelete,
+			expectedErrorString:    "injected failure",
+			expectedAddressPools:   len(validAddressPools0) - 1,
+			expectedIPAddressPools: 1,
+		},
+		"rejected by webhook": {
+			webhook:                true,
+			expectedErrorString:    "denied the request",
+			expectedAddressPools:   len(validAddressPools0),
+			expectedIPAddressPools: 0,
+		},
+		"webhook bypass": {
+			featureGates:           "WebhookBypass=true",
+			webhook:                true,
+			expectedAddressPools:   0,
+			expectedIPAddressPools: len(validAddressPools0),
+		},
+	}
+	defer features.Set("")
+	for desc, tc := range tcs {
+		if err := features.Set(tc.featureGates); err != nil {
+			t.Fatalf("TestCreateBeforeDeleteOnlineMigration(%s): cannot set feature gates, err: %q", desc, err)
+		}
+		failurePoints, err := ParseFailurePoints(tc.failurePoints)
+		if err != nil {
+			t.Fatalf("TestCreateBeforeDeleteOnlineMigration(%s): cannot arm failure points, err: %q", desc, err)
+		}
+		var scheme = runtime.NewScheme()
+		if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+			t.Fatalf("TestCreateBeforeDeleteOnlineMigration(%s): error adding to scheme, err: %q", desc, err)
+		}
+		var c client.Client = fake.NewClientBuilder().WithScheme(scheme).Build()
+		for _, ap := range validAddressPools0 {
+			if err := c.Create(context.TODO(), ap.DeepCopy()); err != nil {
+				t.Fatalf("TestCreateBeforeDeleteOnlineMigration(%s): error building fake client, err: %q", desc, err)
+			}
+		}
+		if tc.webhook {
+			c = &overlapWebhookClient{Client: c}
+		}
+		err = OnlineMigration(context.TODO(), c, scheme, t.TempDir(), false, OnlineMigrationOptions{
+			Options:     Options{FailurePoints: failurePoints},
+			CreateFirst: true,
+		})
+		if tc.expectedErrorString == "" && err != nil {
+			t.Fatalf("TestCreateBeforeDeleteOnlineMigration(%s): unexpected error, err: %q", desc, err)
+		}
+		if tc.expectedErrorString != "" && (err == nil || !strings.Contains(err.Error(), tc.expectedErrorString)) {
+			t.Fatalf("TestCreateBeforeDeleteOnlineMigration(%s): expected error %q but got %v", desc,
+				tc.expectedErrorString, err)
+		}
+		addressPoolList := &metallbv1beta1.AddressPoolList{}
+		if err := c.List(context.TODO(), addressPoolList); err != nil {
+			t.Fatalf("TestCreateBeforeDeleteOnlineMigration(%s): cannot list AddressPools, err: %q", desc, err)
+		}
+		ipAddressPoolList := &metallbv1beta1.IPAddressPoolList{}
+		if err := c.List(context.TODO(), ipAddressPoolList); err != nil {
+			t.Fatalf("TestCreateBeforeDeleteOnlineMigration(%s): cannot list IPAddressPools, err: %q", desc, err)
+		}
+		if len(addressPoolList.Items) != tc.expectedAddressPools ||
+			len(ipAddressPoolList.Items) != tc.expectedIPAddressPools {
+			t.Fatalf("TestCreateBeforeDeleteOnlineMigration(%s): expected %d AddressPools and %d IPAddressPools, got "+
+				"%d and %d", desc, tc.expectedAddressPools, tc.expectedIPAddressPools, len(addressPoolList.Items),
+				len(ipAddressPoolList.Items))
+		}
+	}
+}
+
+// contextClient fails every List with the error of the context that it is passed, like a real client does once the
+// context is done.
+type contextClient struct {
+	client.Client
+}
+
+func (cc *contextClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return cc.Client.List(ctx, list, opts...)
+}
+
+func TestMigrationContextCanceled(t *testing.T) {
+	var scheme = runtime.NewScheme()
+	if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestMigrationContextCanceled: error adding to scheme, err: %q", err)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+	for _, ap := range validAddressPools0 {
+		if err := c.Create(context.TODO(), ap.DeepCopy()); err != nil {
+			t.Fatalf("TestMigrationContextCanceled: error building fake client, err: %q", err)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	stdout = bytes.NewBuffer([]byte{})
+	err := OfflineMigration(ctx, &contextClient{Client: c}, scheme, "", "", OutputFormatYAML, OfflineMigrationOptions{})
+	if err == nil || !strings.Contains(err.Error(), context.Canceled.Error()) {
+		t.Fatalf("TestMigrationContextCanceled: expected the offline migration to fail with %q but got %v",
+			context.Canceled, err)
+	}
+	err = OnlineMigration(ctx, &contextClient{Client: c}, scheme, t.TempDir(), false, OnlineMigrationOptions{})
+	if err == nil || !strings.Contains(err.Error(), context.Canceled.Error()) {
+		t.Fatalf("TestMigrationContextCanceled: expected the online migration to fail with %q but got %v",
+			context.Canceled, err)
+	}
+	addressPools := &metallbv1beta1.AddressPoolList{}
+	if err := c.List(context.TODO(), addressPools); err != nil {
+		t.Fatalf("TestMigrationContextCanceled: cannot list AddressPools, err: %q", err)
+	}
+	if len(addressPools.Items) != len(validAddressPools0) {
+		t.Fatalf("TestMigrationContextCanceled: expected all AddressPools to be kept but got %d",
+			len(addressPools.Items))
+	}
+}
@@ -0,0 +1,138 @@
+package converter
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DefaultAcceptTimeout is the time that CreateFirst migrations wait for the new resources of an AddressPool to be
+// accepted if OnlineMigrationOptions.AcceptTimeout is not set.
+const DefaultAcceptTimeout = 30 * time.Second
+
+// acceptPollInterval is the time between two checks whether the new resources were accepted.
+var acceptPollInterval = time.Second
+
+// addedObjects returns what creating c adds to the API: the IPAddressPools and advertisements that do not exist yet
+// and, for every Community, the aliases that it does not define yet. Only these may be removed again if the objects
+// of an AddressPool are not accepted: existing objects may have been taken over, e.g. with server-side apply, and the
+// Community of a namespace holds the aliases of all of its pools. If resuming, the IPAddressPools and advertisements
+// count as added, the interrupted migration of the AddressPool created them.
+func (c CurrentObjects) addedObjects(ctx context.Context, cl client.Client, resuming bool) (*CurrentObjects, error) {
+	added := &CurrentObjects{
+		IPAddressPoolList:    &metallbv1beta1.IPAddressPoolList{},
+		L2AdvertisementList:  &metallbv1beta1.L2AdvertisementList{},
+		BGPAdvertisementList: &metallbv1beta1.BGPAdvertisementList{},
+		CommunityList:        &metallbv1beta1.CommunityList{},
+	}
+	for _, o := range c.namedObjects() {
+		if community, ok := o.obj.(*metallbv1beta1.Community); ok {
+			current := &metallbv1beta1.Community{}
+			err := cl.Get(ctx, client.ObjectKeyFromObject(community), current)
+			if err != nil && !apierrors.IsNotFound(err) {
+				return nil, fmt.Errorf("cannot get %s, err: %w", o.key(), classifyAPIError(err))
+			}
+			defined := map[string]bool{}
+			for _, alias := range current.Spec.Communities {
+				defined[alias.Name] = true
+			}
+			missing := *community.DeepCopy()
+			missing.Spec.Communities = nil
+			for _, alias := range community.Spec.Communities {
+				if !defined[alias.Name] {
+					missing.Spec.Communities = append(missing.Spec.Communities, alias)
+				}
+			}
+			if len(missing.Spec.Communities) > 0 {
+				added.CommunityList.Items = append(added.CommunityList.Items, missing)
+			}
+			continue
+		}
+		if !resuming {
+			err := cl.Get(ctx, client.ObjectKeyFromObject(o.obj), o.obj.DeepCopyObject().(client.Object))
+			if err == nil {
+				continue
+			}
+			if !apierrors.IsNotFound(err) {
+				return nil, fmt.Errorf("cannot get %s, err: %w", o.key(), classifyAPIError(err))
+			}
+		}
+		switch obj := o.obj.(type) {
+		case *metallbv1beta1.IPAddressPool:
+			added.IPAddressPoolList.Items = append(added.IPAddressPoolList.Items, *obj)
+		case *metallbv1beta1.L2Advertisement:
+			added.L2AdvertisementList.Items = append(added.L2AdvertisementList.Items, *obj)
+		case *metallbv1beta1.BGPAdvertisement:
+			added.BGPAdvertisementList.Items = append(added.BGPAdvertisementList.Items, *obj)
+		}
+	}
+	return added, nil
+}
+
+// remove deletes the IPAddressPools and advertisements of c from the API and removes the aliases of its Communities
+// from the Communities in the API. Communities that are left without aliases are deleted.
+func (c CurrentObjects) remove(ctx context.Context, cl client.Client) error {
+	objects := c
+	objects.CommunityList = &metallbv1beta1.CommunityList{}
+	if err := objects.Delete(ctx, cl); err != nil {
+		return err
+	}
+	for _, community := range c.CommunityList.Items {
+		err := retryOnConflict(ctx, func() error {
+			return removeCommunityAliases(ctx, cl, community)
+		})
+		if err != nil {
+			return fmt.Errorf("cannot remove the aliases of currentObject Community '%s', err: %w", community.Name,
+				classifyAPIError(err))
+		}
+	}
+	return nil
+}
+
+// rollbackUnaccepted removes the objects that the AddressPool namespace/name added, see addedObjects, because its
+// new resources were not accepted in time with acceptErr, and drops the AddressPool from the journal again. If they
+// cannot be removed, the AddressPool stays in progress in the journal, so that a migration that is run again with the
+// same state file completes it.
+func rollbackUnaccepted(ctx context.Context, c client.Client, state *migrationState, added *CurrentObjects,
+	namespace, name string, acceptErr error, opts OnlineMigrationOptions) error {
+	err := runOperation(ctx, c, opts.OperationPolicies, OperationDelete, added.remove)
+	if err != nil {
+		return fmt.Errorf("online migration kept AddressPool %s/%s because its new resources were not accepted, "+
+			"and could not remove them (%v), remove them manually or run the migration again, err: %w",
+			namespace, name, err, acceptErr)
+	}
+	if err := state.abort(namespace, name); err != nil {
+		return err
+	}
+	return fmt.Errorf("online migration kept AddressPool %s/%s and removed its new resources because they were not "+
+		"accepted, err: %w", namespace, name, acceptErr)
+}
+
+// waitAccepted waits until every object can be read from the API with the converted spec, i.e. it passed admission
+// and was persisted, or until timeout. It returns an error that wraps ErrVerificationFailed and lists the objects that
+// were not accepted in time.
+func (c CurrentObjects) waitAccepted(ctx context.Context, cl client.Client, timeout time.Duration) error {
+	if timeout <= 0 {
+		timeout = DefaultAcceptTimeout
+	}
+	deadline := time.After(timeout)
+	for {
+		problems := c.discrepancies(ctx, cl)
+		if len(problems) == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-deadline:
+			return fmt.Errorf("%w: the new resources were not accepted within %s:\n%s", ErrVerificationFailed,
+				timeout, strings.Join(problems, "\n"))
+		case <-time.After(acceptPollInterval):
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package converter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"testing"
+	"time"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// unacceptedClient never returns IPAddressPools, or only the IPAddressPool name if it is set, like an API server that
+// does not persist them in time. If failDeletes is set, deletions of IPAddressPools fail, too.
+type unacceptedClient struct {
+	client.Client
+	name        string
+	failDeletes bool
+}
+
+func (uc *unacceptedClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object,
+	opts ...client.GetOption) error {
+	if _, ok := obj.(*metallbv1beta1.IPAddressPool); ok && (uc.name == "" || uc.name == key.Name) {
+		return apierrors.NewNotFound(schema.GroupResource{Group: "metallb.io", Resource: "ipaddresspools"},
+			key.Name)
+	}
+	return uc.Client.Get(ctx, key, obj, opts...)
+}
+
+func (uc *unacceptedClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	if _, ok := obj.(*metallbv1beta1.IPAddressPool); ok && uc.failDeletes {
+		return apierrors.NewForbidden(schema.GroupResource{Group: "metallb.io", Resource: "ipaddresspools"},
+			obj.GetName(), errors.New("deletion denied"))
+	}
+	return uc.Client.Delete(ctx, obj, opts...)
+}
+
+// admissionClient sets the defaults of the CRDs on the IPAddressPools and BGPAdvertisements that it creates, like the
+// API server. If mutate is set, it also changes their addresses, like a mutating admission webhook.
+type admissionClient struct {
+	client.Client
+	mutate bool
+}
+
+func (ac *admissionClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	switch o := obj.(type) {
+	case *metallbv1beta1.IPAddressPool:
+		if o.Spec.AutoAssign == nil {
+			o.Spec.AutoAssign = pointer.Bool(true)
+		}
+		if ac.mutate {
+			o.Spec.Addresses = []string{"10.0.0.0/24"}
+		}
+	case *metallbv1beta1.BGPAdvertisement:
+		if o.Spec.AggregationLength == nil {
+			o.Spec.AggregationLength = pointer.Int32(32)
+		}
+		if o.Spec.AggregationLengthV6 == nil {
+			o.Spec.AggregationLengthV6 = pointer.Int32(128)
+		}
+	}
+	return ac.Client.Create(ctx, obj, opts...)
+}
+
+func TestCreateFirstOnlineMigration(t *testing.T) {
+	tcs := map[string]struct {
+		admission              bool
+		mutate                 bool
+		unaccepted             bool
+		failDeletes            bool
+		expectedErr            error
+		expectedAddressPools   int
+		expectedIPAddressPools int
+		expectedAdvertisements int
+		expectedStates         []string
+	}{
+		"accepted": {
+			expectedAddressPools:   0,
+			expectedIPAddressPools: len(validAddressPools0),
+			expectedAdvertisements: 4,
+			expectedStates:         []string{StateMigrated, StateMigrated, StateMigrated},
+		},
+		"accepted with the defaults of the API server": {
+			admission:              true,
+			expectedAddressPools:   0,
+			expectedIPAddressPools: len(validAddressPools0),
+			expectedAdvertisements: 4,
+			expectedStates:         []string{StateMigrated, StateMigrated, StateMigrated},
+		},
+		"changed by a mutating webhook": {
+			admission:              true,
+			mutate:                 true,
+			expectedErr:            ErrVerificationFailed,
+			expectedAddressPools:   len(validAddressPools0),
+			expectedIPAddressPools: 0,
+			expectedAdvertisements: 0,
+		},
+		"not accepted": {
+			unaccepted:             true,
+			expectedErr:            ErrVerificationFailed,
+			expectedAddressPools:   len(validAddressPools0),
+			expectedIPAddressPools: 0,
+			expectedAdvertisements: 0,
+		},
+		"not accepted and not removable": {
+			unaccepted:             true,
+			failDeletes:            true,
+			expectedErr:            ErrVerificationFailed,
+			expectedAddressPools:   len(validAddressPools0),
+			expectedIPAddressPools: 1,
+			expectedAdvertisements: 2,
+			expectedStates:         []string{StateInProgress},
+		},
+	}
+	defer func(interval time.Duration) { acceptPollInterval = interval }(acceptPollInterval)
+	acceptPollInterval = time.Millisecond
+	for desc, tc := range tcs {
+		var scheme = runtime.NewScheme()
+		if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+			t.Fatalf("TestCreateFirstOnlineMigration(%s): error adding to scheme, err: %q", desc, err)
+		}
+		c := fake.NewClientBuilder().WithScheme(scheme).Build()
+		for _, ap := range validAddressPools0 {
+			if err := c.Create(context.TODO(), ap.DeepCopy()); err != nil {
+				t.Fatalf("TestCreateFirstOnlineMigration(%s): error building fake client, err: %q", desc, err)
+			}
+		}
+		var migrationClient client.Client = c
+		if tc.admission {
+			migrationClient = &admissionClient{Client: c, mutate: tc.mutate}
+		}
+		if tc.unaccepted {
+			migrationClient = &unacceptedClient{Client: c, failDeletes: tc.failDeletes}
+		}
+		stateFile := path.Join(t.TempDir(), "state.json")
+		err := OnlineMigration(context.TODO(), migrationClient, scheme, t.TempDir(), false,
+			OnlineMigrationOptions{CreateFirst: true, AcceptTimeout: 20 * time.Millisecond, StateFile: stateFile})
+		if tc.expectedErr == nil && err != nil {
+			t.Fatalf("TestCreateFirstOnlineMigration(%s): unexpected error, err: %q", desc, err)
+		}
+		if tc.expectedErr != nil && !errors.Is(err, tc.expectedErr) {
+			t.Fatalf("TestCreateFirstOnlineMigration(%s): expected error %q but got %v", desc, tc.expectedErr, err)
+		}
+		addressPoolList := &metallbv1beta1.AddressPoolList{}
+		if err := c.List(context.TODO(), addressPoolList); err != nil {
+			t.Fatalf("TestCreateFirstOnlineMigration(%s): cannot list AddressPools, err: %q", desc, err)
+		}
+		ipAddressPoolList := &metallbv1beta1.IPAddressPoolList{}
+		if err := c.List(context.TODO(), ipAddressPoolList); err != nil {
+			t.Fatalf("TestCreateFirstOnlineMigration(%s): cannot list IPAddressPools, err: %q", desc, err)
+		}
+		if len(addressPoolList.Items) != tc.expectedAddressPools ||
+			len(ipAddressPoolList.Items) != tc.expectedIPAddressPools {
+			t.Fatalf("TestCreateFirstOnlineMigration(%s): expected %d AddressPools and %d IPAddressPools, got "+
+				"%d and %d", desc, tc.expectedAddressPools, tc.expectedIPAddressPools, len(addressPoolList.Items),
+				len(ipAddressPoolList.Items))
+		}
+		bgpAdvertisementList := &metallbv1beta1.BGPAdvertisementList{}
+		if err := c.List(context.TODO(), bgpAdvertisementList); err != nil {
+			t.Fatalf("TestCreateFirstOnlineMigration(%s): cannot list BGPAdvertisements, err: %q", desc, err)
+		}
+		l2AdvertisementList := &metallbv1beta1.L2AdvertisementList{}
+		if err := c.List(context.TODO(), l2AdvertisementList); err != nil {
+			t.Fatalf("TestCreateFirstOnlineMigration(%s): cannot list L2Advertisements, err: %q", desc, err)
+		}
+		if advertisements := len(bgpAdvertisementList.Items) + len(l2AdvertisementList.Items); advertisements !=
+			tc.expectedAdvertisements {
+			t.Fatalf("TestCreateFirstOnlineMigration(%s): expected %d advertisements, got %d", desc,
+				tc.expectedAdvertisements, advertisements)
+		}
+		state, err := loadMigrationState(stateFile)
+		if err != nil {
+			t.Fatalf("TestCreateFirstOnlineMigration(%s): cannot load state file, err: %q", desc, err)
+		}
+		var states []string
+		for _, pool := range state.Pools {
+			states = append(states, pool.State)
+		}
+		if fmt.Sprint(states) != fmt.Sprint(tc.expectedStates) {
+			t.Fatalf("TestCreateFirstOnlineMigration(%s): expected the journal states %v, got %v", desc,
+				tc.expectedStates, states)
+		}
+	}
+}
+
+func TestCreateFirstRollbackScope(t *testing.T) {
+	var scheme = runtime.NewScheme()
+	if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestCreateFirstRollbackScope: error adding to scheme, err: %q", err)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+	for name, communities := range map[string][]string{
+		"ap-a": {"65000:1", "65000:2"},
+		"ap-b": {"65000:2", "65000:3"},
+	} {
+		ap := &metallbv1beta1.AddressPool{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "metallb-system"},
+			Spec: metallbv1beta1.AddressPoolSpec{
+				Protocol:  ProtocolBGP,
+				Addresses: []string{"192.168.100.100"},
+				BGPAdvertisements: []metallbv1beta1.LegacyBgpAdvertisement{
+					{Communities: communities},
+				},
+			},
+		}
+		if err := c.Create(context.TODO(), ap); err != nil {
+			t.Fatalf("TestCreateFirstRollbackScope: error building fake client, err: %q", err)
+		}
+	}
+	defer func(interval time.Duration) { acceptPollInterval = interval }(acceptPollInterval)
+	acceptPollInterval = time.Millisecond
+
+	err := OnlineMigration(context.TODO(), &unacceptedClient{Client: c, name: "ap-b"}, scheme, t.TempDir(), false,
+		OnlineMigrationOptions{
+			Options:       Options{CommunityResources: true},
+			CreateFirst:   true,
+			AcceptTimeout: 20 * time.Millisecond,
+		})
+	if !errors.Is(err, ErrVerificationFailed) {
+		t.Fatalf("TestCreateFirstRollbackScope: expected error %q but got %v", ErrVerificationFailed, err)
+	}
+	ipAddressPoolList := &metallbv1beta1.IPAddressPoolList{}
+	if err := c.List(context.TODO(), ipAddressPoolList); err != nil {
+		t.Fatalf("TestCreateFirstRollbackScope: cannot list IPAddressPools, err: %q", err)
+	}
+	var pools []string
+	for _, iap := range ipAddressPoolList.Items {
+		pools = append(pools, iap.Name)
+	}
+	if fmt.Sprint(pools) != "[ap-a]" {
+		t.Fatalf("TestCreateFirstRollbackScope: expected the IPAddressPools [ap-a], got %v", pools)
+	}
+	bgpAdvertisementList := &metallbv1beta1.BGPAdvertisementList{}
+	if err := c.List(context.TODO(), bgpAdvertisementList); err != nil {
+		t.Fatalf("TestCreateFirstRollbackScope: cannot list BGPAdvertisements, err: %q", err)
+	}
+	if len(bgpAdvertisementList.Items) != 1 || fmt.Sprint(bgpAdvertisementList.Items[0].Spec.IPAddressPools) !=
+		"[ap-a]" {
+		t.Fatalf("TestCreateFirstRollbackScope: expected only the BGPAdvertisement of ap-a, got %v",
+			bgpAdvertisementList.Items)
+	}
+	community := &metallbv1beta1.Community{}
+	key := types.NamespacedName{Namespace: "metallb-system", Name: CommunityResourceName}
+	if err := c.Get(context.TODO(), key, community); err != nil {
+		t.Fatalf("TestCreateFirstRollbackScope: cannot get the Community, err: %q", err)
+	}
+	var aliases []string
+	for _, alias := range community.Spec.Communities {
+		aliases = append(aliases, alias.Name)
+	}
+	if expected := "[community-65000-1 community-65000-2]"; fmt.Sprint(aliases) != expected {
+		t.Fatalf("TestCreateFirstRollbackScope: expected the aliases %s, got %v", expected, aliases)
+	}
+	addressPool := &metallbv1beta1.AddressPool{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Namespace: "metallb-system", Name: "ap-b"},
+		addressPool); err != nil {
+		t.Fatalf("TestCreateFirstRollbackScope: expected AddressPool ap-b to be kept, err: %q", err)
+	}
+}
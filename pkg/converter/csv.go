@@ -0,0 +1,95 @@
+package converter
+
+import (
+	"encoding/csv"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// csvHeader is the header row of the CSV inventory.
+var csvHeader = []string{
+	"namespace", "name", "protocol", "addresses", "autoAssign", "advertisements", "communities",
+}
+
+// csvListSeparator separates multiple values inside a single CSV cell.
+const csvListSeparator = ";"
+
+// inventoryRows builds one CSV row per IPAddressPool. The protocol column is derived from the advertisements that
+// reference the pool.
+func inventoryRows(c *CurrentObjects) [][]string {
+	protocols := map[string]map[string]struct{}{}
+	advertisementCount := map[string]int{}
+	communities := map[string]map[string]struct{}{}
+	addTo := func(m map[string]map[string]struct{}, key, value string) {
+		if _, ok := m[key]; !ok {
+			m[key] = map[string]struct{}{}
+		}
+		m[key][value] = struct{}{}
+	}
+	for _, l2a := range c.L2AdvertisementList.Items {
+		for _, pool := range l2a.Spec.IPAddressPools {
+			key := l2a.Namespace + "/" + pool
+			addTo(protocols, key, ProtocolLayer2)
+			advertisementCount[key]++
+		}
+	}
+	for _, ba := range c.BGPAdvertisementList.Items {
+		for _, pool := range ba.Spec.IPAddressPools {
+			key := ba.Namespace + "/" + pool
+			addTo(protocols, key, ProtocolBGP)
+			advertisementCount[key]++
+			for _, community := range ba.Spec.Communities {
+				addTo(communities, key, community)
+			}
+		}
+	}
+
+	var rows [][]string
+	for _, iap := range c.IPAddressPoolList.Items {
+		key := iap.Namespace + "/" + iap.Name
+		autoAssign := ""
+		if iap.Spec.AutoAssign != nil {
+			autoAssign = strconv.FormatBool(*iap.Spec.AutoAssign)
+		}
+		rows = append(rows, []string{
+			iap.Namespace,
+			iap.Name,
+			strings.Join(sortedKeys(protocols[key]), csvListSeparator),
+			strings.Join(iap.Spec.Addresses, csvListSeparator),
+			autoAssign,
+			strconv.Itoa(advertisementCount[key]),
+			strings.Join(sortedKeys(communities[key]), csvListSeparator),
+		})
+	}
+	return rows
+}
+
+// sortedKeys returns the keys of m in lexical order.
+func sortedKeys(m map[string]struct{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// PrintCSV writes a flat CSV inventory of all IPAddressPools either to the targetDirectory or to stdout if
+// targetDirectory == "".
+func (c CurrentObjects) PrintCSV(targetDirectory string) error {
+	outWriter, closeFn, err := outputWriter(targetDirectory, "inventory.csv")
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+	w := csv.NewWriter(outWriter)
+	if err := w.Write(csvHeader); err != nil {
+		return fmt.Errorf("cannot write CSV header, err: %w", err)
+	}
+	if err := w.WriteAll(inventoryRows(&c)); err != nil {
+		return fmt.Errorf("cannot write CSV inventory, err: %w", err)
+	}
+	return nil
+}
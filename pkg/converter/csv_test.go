@@ -0,0 +1,82 @@
+package converter
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"testing"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+)
+
+var validAddressPools0CSV = `namespace,name,protocol,addresses,autoAssign,advertisements,communities
+metallb-system,ap-l2,layer2,192.168.100.100,true,1,
+metallb-system,ap-bgp,bgp,192.168.100.100,true,2,65432:12345;65433:12346
+metallb-system,ap-bgp2,bgp,192.168.100.100,true,1,
+`
+
+func TestPrintCSV(t *testing.T) {
+	tcs := map[string]struct {
+		targetDir      string
+		expectedOutput string
+	}{
+		"stdout": {
+			expectedOutput: validAddressPools0CSV,
+		},
+		"target directory": {
+			targetDir:      "tmpDir",
+			expectedOutput: validAddressPools0CSV,
+		},
+	}
+	for desc, tc := range tcs {
+		stdout = bytes.NewBuffer([]byte{})
+		targetDir := tc.targetDir
+		if tc.targetDir == "tmpDir" {
+			targetDir = t.TempDir()
+		}
+		legacyObjects := &LegacyObjects{AddressPoolList: &metallbv1beta1.AddressPoolList{Items: validAddressPools0}}
+		currentObjects, err := legacyObjects.Convert(Options{})
+		if err != nil {
+			t.Fatalf("TestPrintCSV(%s): unexpected error during conversion, err: %q", desc, err)
+		}
+		if err := currentObjects.PrintCSV(targetDir); err != nil {
+			t.Fatalf("TestPrintCSV(%s): unexpected error during print, err: %q", desc, err)
+		}
+		output := fmt.Sprint(stdout)
+		if targetDir != "" {
+			content, err := os.ReadFile(path.Join(targetDir, "inventory.csv"))
+			if err != nil {
+				t.Fatalf("TestPrintCSV(%s): Could not read expected file, err: %q", desc, err)
+			}
+			output = string(content)
+		}
+		if output != tc.expectedOutput {
+			t.Fatalf("TestPrintCSV(%s): Generated output does not match expected output.\nGenerated output:\n===\n```%s```\n\nExpected output:\n===\n```%s```",
+				desc, output, tc.expectedOutput)
+		}
+	}
+}
+
+func TestSupportedOutputFormats(t *testing.T) {
+	tcs := map[string]struct {
+		format string
+	}{
+		"csv": {
+			format: OutputFormatCSV,
+		},
+		"name": {
+			format: OutputFormatName,
+		},
+	}
+	for desc, tc := range tcs {
+		supported := false
+		for _, format := range SupportedOutputFormats {
+			supported = supported || format == tc.format
+		}
+		if !supported {
+			t.Fatalf("TestSupportedOutputFormats(%s): format %q is not in SupportedOutputFormats %v", desc, tc.format,
+				SupportedOutputFormats)
+		}
+	}
+}
@@ -0,0 +1,420 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"strings"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	metallbv1beta2 "go.universe.tf/metallb/api/v1beta2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/cli-runtime/pkg/printers"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+// DowngradeObjects holds the current MetalLB objects that are exported into a legacy MetalLB ConfigMap.
+type DowngradeObjects struct {
+	CurrentObjects
+	BGPPeerList *metallbv1beta2.BGPPeerList
+	// ignored lists the objects that were read but cannot be exported at all.
+	ignored []string
+}
+
+// newDowngradeObjects returns empty DowngradeObjects.
+func newDowngradeObjects() *DowngradeObjects {
+	return &DowngradeObjects{
+		CurrentObjects: CurrentObjects{
+			IPAddressPoolList:    &metallbv1beta1.IPAddressPoolList{},
+			L2AdvertisementList:  &metallbv1beta1.L2AdvertisementList{},
+			BGPAdvertisementList: &metallbv1beta1.BGPAdvertisementList{},
+			CommunityList:        &metallbv1beta1.CommunityList{},
+		},
+		BGPPeerList: &metallbv1beta2.BGPPeerList{},
+	}
+}
+
+// ReadDowngradeObjectsFromAPI reads the current MetalLB objects from the API. Kinds whose CRD is not installed are
+// treated as empty.
+func ReadDowngradeObjectsFromAPI(ctx context.Context, c client.Client) (*DowngradeObjects, error) {
+	d := newDowngradeObjects()
+	for _, list := range []client.ObjectList{
+		d.IPAddressPoolList, d.L2AdvertisementList, d.BGPAdvertisementList, d.CommunityList, d.BGPPeerList,
+	} {
+		err := c.List(ctx, list)
+		if err != nil && !meta.IsNoMatchError(err) {
+			return nil, fmt.Errorf("failed to list %T in cluster: %w", list, err)
+		}
+	}
+	return d, nil
+}
+
+// ReadDowngradeObjectsFromDirectory reads the current MetalLB objects from the YAML or JSON files in dir. Objects of
+// other kinds are not exported and are reported by LegacyConfigMap. Files that the IgnoreFileName of dir excludes are
+// skipped.
+func ReadDowngradeObjectsFromDirectory(scheme *runtime.Scheme, dir string) (*DowngradeObjects, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("could not read current objects from directory, err: %w", err)
+	}
+	ignore, err := loadIgnoreFile(dir)
+	if err != nil {
+		return nil, fmt.Errorf("could not read current objects from directory, err: %w", err)
+	}
+	d := newDowngradeObjects()
+	decode := serializer.NewCodecFactory(scheme).UniversalDeserializer().Decode
+	for _, file := range files {
+		if ignore.ignores(file.Name(), file.IsDir()) {
+			continue
+		}
+		fileContent, err := os.ReadFile(path.Join(dir, file.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("could not read current objects from directory, err: %w", err)
+		}
+		elements, err := splitDocuments(fileContent)
+		if err != nil {
+			return nil, fmt.Errorf("could not read current objects from directory, invalid file %s, err: %w",
+				file.Name(), err)
+		}
+		for _, element := range elements {
+			if len(bytes.TrimSpace(element)) == 0 {
+				continue
+			}
+			if err := d.decode(decode, element, file.Name()); err != nil {
+				return nil, fmt.Errorf("could not read current objects from directory, err: %w", err)
+			}
+		}
+	}
+	return d, nil
+}
+
+// decode adds the objects of a single YAML or JSON document that was read from source. The document may either be
+// a single object, a list or a v1 List of those.
+func (d *DowngradeObjects) decode(decode func([]byte, *schema.GroupVersionKind, runtime.Object) (runtime.Object,
+	*schema.GroupVersionKind, error), document []byte, source string) error {
+	typeMeta := metav1.TypeMeta{}
+	if err := yaml.Unmarshal(document, &typeMeta); err != nil {
+		return fmt.Errorf("invalid document in %s, err: %w", source, err)
+	}
+	if typeMeta.APIVersion == "v1" && typeMeta.Kind == "List" {
+		list := struct {
+			Items []json.RawMessage `json:"items"`
+		}{}
+		if err := yaml.Unmarshal(document, &list); err != nil {
+			return fmt.Errorf("invalid List in %s, err: %w", source, err)
+		}
+		for _, item := range list.Items {
+			if err := d.decode(decode, item, source); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	obj, gvk, err := decode(document, nil, nil)
+	if err != nil {
+		d.ignored = append(d.ignored, fmt.Sprintf("%s %s in %s was ignored", typeMeta.APIVersion,
+			typeMeta.Kind, source))
+		return nil
+	}
+	switch o := obj.(type) {
+	case *metallbv1beta1.IPAddressPool:
+		d.IPAddressPoolList.Items = append(d.IPAddressPoolList.Items, *o)
+	case *metallbv1beta1.IPAddressPoolList:
+		d.IPAddressPoolList.Items = append(d.IPAddressPoolList.Items, o.Items...)
+	case *metallbv1beta1.L2Advertisement:
+		d.L2AdvertisementList.Items = append(d.L2AdvertisementList.Items, *o)
+	case *metallbv1beta1.L2AdvertisementList:
+		d.L2AdvertisementList.Items = append(d.L2AdvertisementList.Items, o.Items...)
+	case *metallbv1beta1.BGPAdvertisement:
+		d.BGPAdvertisementList.Items = append(d.BGPAdvertisementList.Items, *o)
+	case *metallbv1beta1.BGPAdvertisementList:
+		d.BGPAdvertisementList.Items = append(d.BGPAdvertisementList.Items, o.Items...)
+	case *metallbv1beta1.Community:
+		d.CommunityList.Items = append(d.CommunityList.Items, *o)
+	case *metallbv1beta1.CommunityList:
+		d.CommunityList.Items = append(d.CommunityList.Items, o.Items...)
+	case *metallbv1beta2.BGPPeer:
+		d.BGPPeerList.Items = append(d.BGPPeerList.Items, *o)
+	case *metallbv1beta2.BGPPeerList:
+		d.BGPPeerList.Items = append(d.BGPPeerList.Items, o.Items...)
+	default:
+		d.ignored = append(d.ignored, fmt.Sprintf("%s %s in %s was ignored", gvk.GroupVersion(), gvk.Kind,
+			source))
+	}
+	return nil
+}
+
+// selectsPool returns true if an advertisement with the given pool names and pool selectors applies to iap. An
+// advertisement without names and selectors applies to all pools.
+func selectsPool(pools []string, selectors []metav1.LabelSelector, iap metallbv1beta1.IPAddressPool) (bool, error) {
+	if len(pools) == 0 && len(selectors) == 0 {
+		return true, nil
+	}
+	if contains(pools, iap.Name) {
+		return true, nil
+	}
+	for i := range selectors {
+		selector, err := metav1.LabelSelectorAsSelector(&selectors[i])
+		if err != nil {
+			return false, fmt.Errorf("invalid IPAddressPool selector, err: %w", err)
+		}
+		if selector.Matches(labels.Set(iap.Labels)) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// legacyNodeSelectors converts label selectors to the node-selectors of a legacy peer.
+func legacyNodeSelectors(selectors []metav1.LabelSelector) []legacyNodeSelector {
+	var result []legacyNodeSelector
+	for _, selector := range selectors {
+		ns := legacyNodeSelector{MatchLabels: selector.MatchLabels}
+		for _, expression := range selector.MatchExpressions {
+			ns.MatchExpressions = append(ns.MatchExpressions, legacySelectorRequirement{
+				Key:      expression.Key,
+				Operator: string(expression.Operator),
+				Values:   expression.Values,
+			})
+		}
+		result = append(result, ns)
+	}
+	return result
+}
+
+// toLegacyConfig renders the objects of namespace into the configuration format of the legacy MetalLB ConfigMap. It
+// returns a description of every feature that the legacy format cannot represent; such features are dropped or
+// approximated as described. Objects of other namespaces are ignored by MetalLB and are reported as well.
+func (d *DowngradeObjects) toLegacyConfig(namespace string) (*legacyConfig, []string, error) {
+	unsupported := append([]string{}, d.ignored...)
+	config := &legacyConfig{}
+
+	for _, peer := range d.BGPPeerList.Items {
+		if peer.Namespace != namespace {
+			unsupported = append(unsupported, fmt.Sprintf("BGPPeer %s/%s is not in namespace %s and was ignored",
+				peer.Namespace, peer.Name, namespace))
+			continue
+		}
+		lp := legacyPeer{
+			PeerAddress:   peer.Spec.Address,
+			PeerASN:       peer.Spec.ASN,
+			MyASN:         peer.Spec.MyASN,
+			PeerPort:      peer.Spec.Port,
+			SourceAddress: peer.Spec.SrcAddress,
+			RouterID:      peer.Spec.RouterID,
+			Password:      peer.Spec.Password,
+			BFDProfile:    peer.Spec.BFDProfile,
+			EBGPMultiHop:  peer.Spec.EBGPMultiHop,
+			NodeSelectors: legacyNodeSelectors(peer.Spec.NodeSelectors),
+		}
+		if peer.Spec.HoldTime.Duration != 0 {
+			lp.HoldTime = peer.Spec.HoldTime.Duration.String()
+		}
+		if peer.Spec.KeepaliveTime.Duration != 0 {
+			lp.KeepaliveTime = peer.Spec.KeepaliveTime.Duration.String()
+		}
+		if peer.Spec.PasswordSecret.Name != "" {
+			unsupported = append(unsupported, fmt.Sprintf("BGPPeer %s/%s: passwordSecret %s cannot be referenced, "+
+				"set the password of the peer manually", peer.Namespace, peer.Name, peer.Spec.PasswordSecret.Name))
+		}
+		if peer.Spec.BFDProfile != "" {
+			unsupported = append(unsupported, fmt.Sprintf("BGPPeer %s/%s: BFDProfile %s is not exported, add it to "+
+				"bfd-profiles manually", peer.Namespace, peer.Name, peer.Spec.BFDProfile))
+		}
+		config.Peers = append(config.Peers, lp)
+	}
+
+	for _, community := range d.CommunityList.Items {
+		if community.Namespace != namespace {
+			unsupported = append(unsupported, fmt.Sprintf("Community %s/%s is not in namespace %s and was ignored",
+				community.Namespace, community.Name, namespace))
+			continue
+		}
+		for _, alias := range community.Spec.Communities {
+			if config.BGPCommunities == nil {
+				config.BGPCommunities = map[string]string{}
+			}
+			config.BGPCommunities[alias.Name] = alias.Value
+		}
+	}
+
+	for _, l2a := range d.L2AdvertisementList.Items {
+		if l2a.Namespace == namespace && (len(l2a.Spec.NodeSelectors) > 0 || len(l2a.Spec.Interfaces) > 0) {
+			unsupported = append(unsupported, fmt.Sprintf("L2Advertisement %s/%s: nodeSelectors and interfaces "+
+				"cannot be represented, the pools are announced from all nodes and interfaces", l2a.Namespace,
+				l2a.Name))
+		}
+	}
+	for _, ba := range d.BGPAdvertisementList.Items {
+		if ba.Namespace == namespace && (len(ba.Spec.NodeSelectors) > 0 || len(ba.Spec.Peers) > 0) {
+			unsupported = append(unsupported, fmt.Sprintf("BGPAdvertisement %s/%s: nodeSelectors and peers "+
+				"cannot be represented, the pools are advertised from all nodes to all peers", ba.Namespace, ba.Name))
+		}
+	}
+
+	for _, iap := range d.IPAddressPoolList.Items {
+		if iap.Namespace != namespace {
+			unsupported = append(unsupported, fmt.Sprintf("IPAddressPool %s/%s is not in namespace %s and was "+
+				"ignored", iap.Namespace, iap.Name, namespace))
+			continue
+		}
+		pool := legacyAddressPoolSpec{
+			Name:          iap.Name,
+			Addresses:     iap.Spec.Addresses,
+			AvoidBuggyIPs: iap.Spec.AvoidBuggyIPs,
+			AutoAssign:    iap.Spec.AutoAssign,
+		}
+		l2 := false
+		for _, l2a := range d.L2AdvertisementList.Items {
+			if l2a.Namespace != namespace {
+				continue
+			}
+			selected, err := selectsPool(l2a.Spec.IPAddressPools, l2a.Spec.IPAddressPoolSelectors, iap)
+			if err != nil {
+				return nil, nil, fmt.Errorf("L2Advertisement %s/%s: %w", l2a.Namespace, l2a.Name, err)
+			}
+			l2 = l2 || selected
+		}
+		for _, ba := range d.BGPAdvertisementList.Items {
+			if ba.Namespace != namespace {
+				continue
+			}
+			selected, err := selectsPool(ba.Spec.IPAddressPools, ba.Spec.IPAddressPoolSelectors, iap)
+			if err != nil {
+				return nil, nil, fmt.Errorf("BGPAdvertisement %s/%s: %w", ba.Namespace, ba.Name, err)
+			}
+			if !selected {
+				continue
+			}
+			adv := legacyBGPAdvertisement{
+				AggregationLength:   ba.Spec.AggregationLength,
+				AggregationLengthV6: ba.Spec.AggregationLengthV6,
+			}
+			if ba.Spec.LocalPref != 0 {
+				localPref := ba.Spec.LocalPref
+				adv.LocalPref = &localPref
+			}
+			for _, community := range ba.Spec.Communities {
+				if strings.HasPrefix(community, "large:") {
+					unsupported = append(unsupported, fmt.Sprintf("BGPAdvertisement %s/%s: large community %s "+
+						"cannot be represented and was dropped", ba.Namespace, ba.Name, community))
+					continue
+				}
+				if _, ok := communityAlias(community); !ok {
+					if _, ok := config.BGPCommunities[community]; !ok {
+						unsupported = append(unsupported, fmt.Sprintf("BGPAdvertisement %s/%s: unknown community "+
+							"alias %s was dropped", ba.Namespace, ba.Name, community))
+						continue
+					}
+				}
+				adv.Communities = append(adv.Communities, community)
+			}
+			pool.BGPAdvertisements = append(pool.BGPAdvertisements, adv)
+		}
+		switch {
+		case len(pool.BGPAdvertisements) > 0:
+			pool.Protocol = ProtocolBGP
+			if l2 {
+				unsupported = append(unsupported, fmt.Sprintf("IPAddressPool %s/%s is advertised in both layer2 and "+
+					"bgp mode, only bgp mode was exported", iap.Namespace, iap.Name))
+			}
+		case l2:
+			pool.Protocol = ProtocolLayer2
+		default:
+			pool.Protocol = ProtocolLayer2
+			unsupported = append(unsupported, fmt.Sprintf("IPAddressPool %s/%s is not advertised, the legacy format "+
+				"always advertises pools, it was exported in layer2 mode", iap.Namespace, iap.Name))
+		}
+		config.AddressPools = append(config.AddressPools, pool)
+	}
+	return config, unsupported, nil
+}
+
+// LegacyConfigMap renders the objects of key.Namespace into the legacy MetalLB ConfigMap key. It also returns a
+// description of every feature that the legacy format cannot represent.
+func (d *DowngradeObjects) LegacyConfigMap(key types.NamespacedName) (*corev1.ConfigMap, []string, error) {
+	config, unsupported, err := d.toLegacyConfig(key.Namespace)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot marshal legacy configuration, err: %w", err)
+	}
+	return &corev1.ConfigMap{
+		TypeMeta:   metav1.TypeMeta{Kind: "ConfigMap", APIVersion: "v1"},
+		ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+		Data:       map[string]string{LegacyConfigMapKey: string(data)},
+	}, unsupported, nil
+}
+
+// ExportLegacyConfigMap renders the current MetalLB objects into the legacy MetalLB ConfigMap key, for clusters that
+// must be downgraded to a MetalLB release without CRD support. The objects are read from inDirFlag, or from the API
+// if inDirFlag == "". The ConfigMap is printed either to outDirFlag or to stdout if outDirFlag == "". Features that
+// the legacy format cannot represent are logged as warnings.
+func ExportLegacyConfigMap(ctx context.Context, c client.Client, scheme *runtime.Scheme, inDirFlag string,
+	outDirFlag string, toJSON bool, key types.NamespacedName) error {
+	ctx, span := tracer.Start(ctx, "ExportLegacyConfigMap")
+	defer span.End()
+
+	var d *DowngradeObjects
+	var err error
+	_, childSpan := tracer.Start(ctx, "retrieve")
+	if inDirFlag == "" {
+		childSpan.SetAttribute("source", "api")
+		d, err = ReadDowngradeObjectsFromAPI(ctx, c)
+	} else {
+		childSpan.SetAttribute("source", inDirFlag)
+		d, err = ReadDowngradeObjectsFromDirectory(scheme, inDirFlag)
+	}
+	childSpan.RecordError(err)
+	childSpan.End()
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("error during retrieval step, err: %w", err)
+	}
+
+	cm, unsupported, err := d.LegacyConfigMap(key)
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("error during export step, err: %w", err)
+	}
+	if !quiet {
+		for _, problem := range unsupported {
+			log.Printf("warning: %s", problem)
+		}
+	}
+
+	var printer printers.ResourcePrinter = &printers.YAMLPrinter{}
+	fileName := "ConfigMap.yaml"
+	if toJSON {
+		printer = &printers.JSONPrinter{}
+		fileName = "ConfigMap.json"
+	}
+	outWriter, closeFn, err := outputWriter(outDirFlag, fileName)
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("error during print step, err: %w", err)
+	}
+	defer closeFn()
+	printed, err := printObj(cm, printer)
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("error during print step, err: %w", err)
+	}
+	fmt.Fprint(outWriter, printed)
+	return nil
+}
@@ -0,0 +1,171 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	metallbv1beta2 "go.universe.tf/metallb/api/v1beta2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var downgradePeer = metallbv1beta2.BGPPeer{
+	TypeMeta:   metav1.TypeMeta{Kind: "BGPPeer", APIVersion: "metallb.io/v1beta2"},
+	ObjectMeta: metav1.ObjectMeta{Name: "peer", Namespace: "metallb-system"},
+	Spec: metallbv1beta2.BGPPeerSpec{
+		MyASN:          64500,
+		ASN:            64501,
+		Address:        "10.0.0.1",
+		HoldTime:       metav1.Duration{Duration: 90000000000},
+		PasswordSecret: corev1.SecretReference{Name: "peer-password"},
+		NodeSelectors:  []metav1.LabelSelector{{MatchLabels: map[string]string{"rack": "a"}}},
+	},
+}
+
+func TestLegacyConfigMap(t *testing.T) {
+	tcs := map[string]struct {
+		modify              func(d *DowngradeObjects)
+		expectedProtocols   map[string]string
+		expectedUnsupported []string
+	}{
+		"round trip": {
+			modify:              func(d *DowngradeObjects) {},
+			expectedProtocols:   map[string]string{"ap-l2": ProtocolLayer2, "ap-bgp": ProtocolBGP, "ap-bgp2": ProtocolBGP},
+			expectedUnsupported: []string{"passwordSecret peer-password cannot be referenced"},
+		},
+		"unrepresentable advertisements": {
+			modify: func(d *DowngradeObjects) {
+				d.L2AdvertisementList.Items[0].Spec.Interfaces = []string{"eth0"}
+				d.BGPAdvertisementList.Items[0].Spec.Peers = []string{"peer"}
+				d.L2AdvertisementList.Items[0].Spec.IPAddressPools = []string{"ap-l2", "ap-bgp"}
+			},
+			expectedProtocols: map[string]string{"ap-l2": ProtocolLayer2, "ap-bgp": ProtocolBGP, "ap-bgp2": ProtocolBGP},
+			expectedUnsupported: []string{
+				"L2Advertisement metallb-system/ap-l2-l2-advertisement: nodeSelectors and interfaces",
+				"BGPAdvertisement metallb-system/ap-bgp-bgp-advertisement-0: nodeSelectors and peers",
+				"IPAddressPool metallb-system/ap-bgp is advertised in both layer2 and bgp mode",
+			},
+		},
+		"not advertised and other namespace": {
+			modify: func(d *DowngradeObjects) {
+				d.L2AdvertisementList.Items = nil
+				d.IPAddressPoolList.Items[2].Namespace = "other"
+			},
+			expectedProtocols: map[string]string{"ap-l2": ProtocolLayer2, "ap-bgp": ProtocolBGP},
+			expectedUnsupported: []string{
+				"IPAddressPool metallb-system/ap-l2 is not advertised",
+				"IPAddressPool other/ap-bgp2 is not in namespace metallb-system",
+			},
+		},
+	}
+	for desc, tc := range tcs {
+		legacyObjects := &LegacyObjects{AddressPoolList: &metallbv1beta1.AddressPoolList{Items: validAddressPools0}}
+		currentObjects, err := legacyObjects.Convert(Options{})
+		if err != nil {
+			t.Fatalf("TestLegacyConfigMap(%s): unexpected error during conversion, err: %q", desc, err)
+		}
+		d := &DowngradeObjects{
+			CurrentObjects: *currentObjects,
+			BGPPeerList:    &metallbv1beta2.BGPPeerList{Items: []metallbv1beta2.BGPPeer{downgradePeer}},
+		}
+		tc.modify(d)
+		key := types.NamespacedName{Namespace: "metallb-system", Name: "config"}
+		cm, unsupported, err := d.LegacyConfigMap(key)
+		if err != nil {
+			t.Fatalf("TestLegacyConfigMap(%s): unexpected error, err: %q", desc, err)
+		}
+		for _, expected := range tc.expectedUnsupported {
+			if !strings.Contains(strings.Join(unsupported, "\n"), expected) {
+				t.Fatalf("TestLegacyConfigMap(%s): expected %q to be reported but got %v", desc, expected, unsupported)
+			}
+		}
+		if !strings.Contains(cm.Data[LegacyConfigMapKey], "hold-time: 1m30s") {
+			t.Fatalf("TestLegacyConfigMap(%s): expected the peer in the configuration but got:\n%s", desc,
+				cm.Data[LegacyConfigMapKey])
+		}
+		parsed, err := ParseLegacyConfig([]byte(cm.Data[LegacyConfigMapKey]), key.Namespace)
+		if err != nil {
+			t.Fatalf("TestLegacyConfigMap(%s): cannot parse the exported configuration, err: %q", desc, err)
+		}
+		if parsed.legacyPeers != 1 || len(parsed.AddressPoolList.Items) != len(tc.expectedProtocols) {
+			t.Fatalf("TestLegacyConfigMap(%s): unexpected configuration:\n%s", desc, cm.Data[LegacyConfigMapKey])
+		}
+		for _, ap := range parsed.AddressPoolList.Items {
+			if ap.Spec.Protocol != tc.expectedProtocols[ap.Name] {
+				t.Fatalf("TestLegacyConfigMap(%s): expected protocol %q for %s but got %q", desc,
+					tc.expectedProtocols[ap.Name], ap.Name, ap.Spec.Protocol)
+			}
+			if desc == "round trip" && ap.Name == "ap-bgp" &&
+				!equality.Semantic.DeepEqual(ap.Spec, validAddressPools0[1].Spec) {
+				t.Fatalf("TestLegacyConfigMap(%s): expected spec %+v but got %+v", desc, validAddressPools0[1].Spec,
+					ap.Spec)
+			}
+		}
+	}
+}
+
+func TestExportLegacyConfigMap(t *testing.T) {
+	var scheme = runtime.NewScheme()
+	if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestExportLegacyConfigMap: error adding to scheme, err: %q", err)
+	}
+	if err := metallbv1beta2.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestExportLegacyConfigMap: error adding to scheme, err: %q", err)
+	}
+	legacyObjects := &LegacyObjects{AddressPoolList: &metallbv1beta1.AddressPoolList{Items: validAddressPools0}}
+	currentObjects, err := legacyObjects.Convert(Options{})
+	if err != nil {
+		t.Fatalf("TestExportLegacyConfigMap: unexpected error during conversion, err: %q", err)
+	}
+	inDir := t.TempDir()
+	stdout = bytes.NewBuffer([]byte{})
+	if err := currentObjects.Print(inDir, false, Options{}); err != nil {
+		t.Fatalf("TestExportLegacyConfigMap: cannot print current objects, err: %q", err)
+	}
+	err = os.WriteFile(path.Join(inDir, "other.yaml"), []byte("apiVersion: v1\nkind: Service\nmetadata:\n  name: svc\n"),
+		0600)
+	if err != nil {
+		t.Fatalf("TestExportLegacyConfigMap: cannot write file, err: %q", err)
+	}
+	fromDirectory, err := ReadDowngradeObjectsFromDirectory(scheme, inDir)
+	if err != nil {
+		t.Fatalf("TestExportLegacyConfigMap: cannot read directory, err: %q", err)
+	}
+	if len(fromDirectory.IPAddressPoolList.Items) != 3 || len(fromDirectory.BGPAdvertisementList.Items) != 3 ||
+		len(fromDirectory.ignored) != 1 {
+		t.Fatalf("TestExportLegacyConfigMap: unexpected objects read from directory: %+v", fromDirectory)
+	}
+
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(downgradePeer.DeepCopy()).Build()
+	for _, iap := range currentObjects.IPAddressPoolList.Items {
+		if err := c.Create(context.TODO(), iap.DeepCopy()); err != nil {
+			t.Fatalf("TestExportLegacyConfigMap: cannot create IPAddressPool, err: %q", err)
+		}
+	}
+	key := types.NamespacedName{Namespace: "metallb-system", Name: "config"}
+	expectedOutputs := map[string][]string{
+		"":    {"kind: ConfigMap", "name: config", "address-pools:", "peer-address: 10.0.0.1"},
+		inDir: {"kind: ConfigMap", "name: config", "address-pools:"},
+	}
+	for source, expectedOutput := range expectedOutputs {
+		stdout = bytes.NewBuffer([]byte{})
+		if err := ExportLegacyConfigMap(context.TODO(), c, scheme, source, "", false, key); err != nil {
+			t.Fatalf("TestExportLegacyConfigMap(%q): unexpected error, err: %q", source, err)
+		}
+		for _, expected := range expectedOutput {
+			if !strings.Contains(fmt.Sprint(stdout), expected) {
+				t.Fatalf("TestExportLegacyConfigMap(%q): expected %q in output but got:\n%s", source, expected, stdout)
+			}
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package converter
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DetectDrift compares the legacy AddressPools that are still present in the API with the new resources that they
+// were migrated to. Every AddressPool is converted on its own, as an online migration would, and the converted
+// resources are compared with the live ones. AddressPools whose IPAddressPool does not exist are only reported if
+// they are marked as migrated; all others are considered not migrated yet. DetectDrift returns a description of every
+// inconsistency, e.g. an IPAddressPool that was edited while its AddressPool was not. The AddressPools are read and
+// converted according to opts.
+func DetectDrift(ctx context.Context, cl client.Client, opts Options) ([]string, error) {
+	legacyObjects, err := ReadLegacyObjectsFromAPI(ctx, cl, 0, opts)
+	if err != nil {
+		return nil, err
+	}
+	var drift []string
+	for _, ap := range legacyObjects.AddressPoolList.Items {
+		single := &LegacyObjects{AddressPoolList: &metallbv1beta1.AddressPoolList{
+			Items: []metallbv1beta1.AddressPool{ap},
+		}}
+		currentObjects, err := single.Convert(opts)
+		if err == nil {
+			err = currentObjects.ResolveCommunityAliases(ctx, cl, opts.ExpandCommunityAliases)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot convert AddressPool %s/%s, err: %w", ap.Namespace, ap.Name, err)
+		}
+		migrated, err := currentObjects.migrated(ctx, cl)
+		if err != nil {
+			return nil, err
+		}
+		if !migrated {
+			if isMigrated(ap) {
+				drift = append(drift, fmt.Sprintf("AddressPool %s/%s: marked as migrated, but its IPAddressPool "+
+					"does not exist", ap.Namespace, ap.Name))
+			}
+			continue
+		}
+		for _, problem := range currentObjects.discrepancies(ctx, cl) {
+			drift = append(drift, fmt.Sprintf("AddressPool %s/%s: %s", ap.Namespace, ap.Name, problem))
+		}
+	}
+	return drift, nil
+}
+
+// migrated returns true if any of the IPAddressPools exists in the API.
+func (c CurrentObjects) migrated(ctx context.Context, cl client.Client) (bool, error) {
+	for _, iap := range c.IPAddressPoolList.Items {
+		key := types.NamespacedName{Namespace: iap.Namespace, Name: iap.Name}
+		err := cl.Get(ctx, key, &metallbv1beta1.IPAddressPool{})
+		if err == nil {
+			return true, nil
+		}
+		if !apierrors.IsNotFound(err) {
+			return false, fmt.Errorf("cannot get IPAddressPool %s, err: %w", key, err)
+		}
+	}
+	return false, nil
+}
+
+// MonitorDrift runs DetectDrift every interval and logs all inconsistencies until ctx is done. If interval is 0, drift
+// is detected once and an error is returned if there is any, so that the result can be used in scripts.
+func MonitorDrift(ctx context.Context, cl client.Client, interval time.Duration, opts Options) error {
+	for {
+		drift, err := DetectDrift(ctx, cl, opts)
+		if err != nil {
+			if interval == 0 {
+				return fmt.Errorf("cannot detect drift, err: %w", err)
+			}
+			log.Printf("cannot detect drift, err: %q", err)
+		}
+		for _, d := range drift {
+			log.Printf("drift: %s", d)
+		}
+		if err == nil && len(drift) == 0 && !quiet {
+			log.Print("no drift between legacy and new resources detected")
+		}
+		if interval == 0 {
+			if len(drift) > 0 {
+				return fmt.Errorf("%w: %d inconsistencies between legacy and new resources", ErrDriftDetected,
+					len(drift))
+			}
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package converter
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestDetectDrift(t *testing.T) {
+	tcs := map[string]struct {
+		migrate       bool
+		modify        func(c client.Client) error
+		expectedDrift []string
+	}{
+		"not migrated": {},
+		"migrated without drift": {
+			migrate: true,
+		},
+		"edited IPAddressPool": {
+			migrate: true,
+			modify: func(c client.Client) error {
+				iap := &metallbv1beta1.IPAddressPool{}
+				key := types.NamespacedName{Namespace: "metallb-system", Name: "ap-l2"}
+				if err := c.Get(context.TODO(), key, iap); err != nil {
+					return err
+				}
+				iap.Spec.Addresses = []string{"10.0.0.0/24"}
+				return c.Update(context.TODO(), iap)
+			},
+			expectedDrift: []string{
+				"AddressPool metallb-system/ap-l2: IPAddressPool metallb-system/ap-l2: spec differs from the converted spec",
+			},
+		},
+		"deleted IPAddressPool": {
+			migrate: true,
+			modify: func(c client.Client) error {
+				iap := &metallbv1beta1.IPAddressPool{}
+				iap.Namespace, iap.Name = "metallb-system", "ap-bgp"
+				return c.Delete(context.TODO(), iap)
+			},
+			expectedDrift: []string{
+				"AddressPool metallb-system/ap-bgp: marked as migrated, but its IPAddressPool does not exist",
+			},
+		},
+	}
+	for desc, tc := range tcs {
+		var scheme = runtime.NewScheme()
+		if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+			t.Fatalf("TestDetectDrift(%s): error adding to scheme, err: %q", desc, err)
+		}
+		c := fake.NewClientBuilder().WithScheme(scheme).Build()
+		for _, ap := range validAddressPools0 {
+			if err := c.Create(context.TODO(), ap.DeepCopy()); err != nil {
+				t.Fatalf("TestDetectDrift(%s): error building fake client, err: %q", desc, err)
+			}
+		}
+		if tc.migrate {
+			err := OnlineMigration(context.TODO(), c, scheme, t.TempDir(), false, OnlineMigrationOptions{KeepLegacy: true})
+			if err != nil {
+				t.Fatalf("TestDetectDrift(%s): unexpected error during migration, err: %q", desc, err)
+			}
+		}
+		if tc.modify != nil {
+			if err := tc.modify(c); err != nil {
+				t.Fatalf("TestDetectDrift(%s): cannot modify resources, err: %q", desc, err)
+			}
+		}
+		drift, err := DetectDrift(context.TODO(), c, Options{})
+		if err != nil {
+			t.Fatalf("TestDetectDrift(%s): unexpected error, err: %q", desc, err)
+		}
+		if strings.Join(drift, "\n") != strings.Join(tc.expectedDrift, "\n") {
+			t.Fatalf("TestDetectDrift(%s): expected drift %v but got %v", desc, tc.expectedDrift, drift)
+		}
+		err = MonitorDrift(context.TODO(), c, 0, Options{})
+		if (err != nil) != (len(tc.expectedDrift) > 0) {
+			t.Fatalf("TestDetectDrift(%s): unexpected result of MonitorDrift, err: %v", desc, err)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	"k8s.io/apimachinery/pkg/api/equality"
+)
+
+const (
+	// DuplicatePolicyFail refuses to convert AddressPools that are defined more than once.
+	DuplicatePolicyFail = "fail"
+	// DuplicatePolicyKeepLast keeps the last definition of an AddressPool in file name order.
+	DuplicatePolicyKeepLast = "keep-last"
+	// DuplicatePolicyMerge merges all definitions of an AddressPool. Addresses and BGP advertisements are combined,
+	// protocol and autoAssign must be identical.
+	DuplicatePolicyMerge = "merge"
+)
+
+// SupportedDuplicatePolicies lists all policies that can be set as Options.DuplicatePolicy.
+var SupportedDuplicatePolicies = []string{DuplicatePolicyFail, DuplicatePolicyKeepLast, DuplicatePolicyMerge}
+
+// sourcedAddressPool is an AddressPool together with the file that it was read from.
+type sourcedAddressPool struct {
+	addressPool metallbv1beta1.AddressPool
+	source      string
+}
+
+// resolveDuplicates returns one AddressPool per namespace and name according to policy, in order of first
+// appearance.
+func resolveDuplicates(pools []sourcedAddressPool, policy string) ([]metallbv1beta1.AddressPool, error) {
+	var keys []string
+	byKey := map[string][]sourcedAddressPool{}
+	for _, p := range pools {
+		key := fmt.Sprintf("%s/%s", p.addressPool.Namespace, p.addressPool.Name)
+		if _, ok := byKey[key]; !ok {
+			keys = append(keys, key)
+		}
+		byKey[key] = append(byKey[key], p)
+	}
+
+	var result []metallbv1beta1.AddressPool
+	var problems []string
+	for _, key := range keys {
+		definitions := byKey[key]
+		if len(definitions) == 1 {
+			result = append(result, definitions[0].addressPool)
+			continue
+		}
+		switch policy {
+		case DuplicatePolicyKeepLast:
+			result = append(result, definitions[len(definitions)-1].addressPool)
+		case DuplicatePolicyMerge:
+			merged, err := mergeAddressPools(definitions)
+			if err != nil {
+				problems = append(problems, err.Error())
+				continue
+			}
+			result = append(result, merged)
+		default:
+			var sources []string
+			for _, d := range definitions {
+				sources = append(sources, d.source)
+			}
+			problems = append(problems, fmt.Sprintf("AddressPool %s is defined %d times, in: %s", key,
+				len(definitions), strings.Join(sources, ", ")))
+		}
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("duplicate AddressPools found:\n%s", strings.Join(problems, "\n"))
+	}
+	return result, nil
+}
+
+// mergeAddressPools merges definitions into a single AddressPool. Addresses and BGP advertisements are combined
+// without duplicates. Protocol and AutoAssign must be identical across all definitions.
+func mergeAddressPools(definitions []sourcedAddressPool) (metallbv1beta1.AddressPool, error) {
+	merged := *definitions[0].addressPool.DeepCopy()
+	seenAddresses := map[string]bool{}
+	for _, a := range merged.Spec.Addresses {
+		seenAddresses[a] = true
+	}
+	for _, d := range definitions[1:] {
+		spec := d.addressPool.Spec
+		if spec.Protocol != merged.Spec.Protocol || !equality.Semantic.DeepEqual(spec.AutoAssign, merged.Spec.AutoAssign) {
+			return merged, fmt.Errorf("AddressPool %s/%s in %s cannot be merged with %s: protocol and autoAssign "+
+				"must match", merged.Namespace, merged.Name, d.source, definitions[0].source)
+		}
+		for _, a := range spec.Addresses {
+			if !seenAddresses[a] {
+				seenAddresses[a] = true
+				merged.Spec.Addresses = append(merged.Spec.Addresses, a)
+			}
+		}
+	outer:
+		for _, adv := range spec.BGPAdvertisements {
+			for _, existing := range merged.Spec.BGPAdvertisements {
+				if equality.Semantic.DeepEqual(adv, existing) {
+					continue outer
+				}
+			}
+			merged.Spec.BGPAdvertisements = append(merged.Spec.BGPAdvertisements, *adv.DeepCopy())
+		}
+	}
+	return merged, nil
+}
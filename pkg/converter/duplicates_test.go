@@ -0,0 +1,120 @@
+package converter
+
+import (
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func duplicateTestPool(protocol string, addresses ...string) metallbv1beta1.AddressPool {
+	return metallbv1beta1.AddressPool{
+		ObjectMeta: metav1.ObjectMeta{Name: "ap", Namespace: "metallb-system"},
+		Spec:       metallbv1beta1.AddressPoolSpec{Protocol: protocol, Addresses: addresses},
+	}
+}
+
+func TestResolveDuplicates(t *testing.T) {
+	other := duplicateTestPool(ProtocolLayer2, "10.0.0.0/24")
+	other.Name = "other"
+	tcs := map[string]struct {
+		pools               []sourcedAddressPool
+		policy              string
+		expectedAddresses   [][]string
+		expectedErrorString string
+	}{
+		"no duplicates": {
+			pools: []sourcedAddressPool{
+				{addressPool: duplicateTestPool(ProtocolLayer2, "192.168.0.0/24"), source: "a.yaml"},
+				{addressPool: other, source: "b.yaml"},
+			},
+			policy:            DuplicatePolicyFail,
+			expectedAddresses: [][]string{{"192.168.0.0/24"}, {"10.0.0.0/24"}},
+		},
+		"fail": {
+			pools: []sourcedAddressPool{
+				{addressPool: duplicateTestPool(ProtocolLayer2, "192.168.0.0/24"), source: "a.yaml"},
+				{addressPool: duplicateTestPool(ProtocolLayer2, "192.168.1.0/24"), source: "b.yaml"},
+			},
+			policy:              DuplicatePolicyFail,
+			expectedErrorString: "AddressPool metallb-system/ap is defined 2 times, in: a.yaml, b.yaml",
+		},
+		"keep last": {
+			pools: []sourcedAddressPool{
+				{addressPool: duplicateTestPool(ProtocolLayer2, "192.168.0.0/24"), source: "a.yaml"},
+				{addressPool: other, source: "b.yaml"},
+				{addressPool: duplicateTestPool(ProtocolLayer2, "192.168.1.0/24"), source: "c.yaml"},
+			},
+			policy:            DuplicatePolicyKeepLast,
+			expectedAddresses: [][]string{{"192.168.1.0/24"}, {"10.0.0.0/24"}},
+		},
+		"merge": {
+			pools: []sourcedAddressPool{
+				{addressPool: duplicateTestPool(ProtocolLayer2, "192.168.0.0/24"), source: "a.yaml"},
+				{addressPool: duplicateTestPool(ProtocolLayer2, "192.168.1.0/24", "192.168.0.0/24"), source: "b.yaml"},
+			},
+			policy:            DuplicatePolicyMerge,
+			expectedAddresses: [][]string{{"192.168.0.0/24", "192.168.1.0/24"}},
+		},
+		"merge with conflicting protocol": {
+			pools: []sourcedAddressPool{
+				{addressPool: duplicateTestPool(ProtocolLayer2, "192.168.0.0/24"), source: "a.yaml"},
+				{addressPool: duplicateTestPool(ProtocolBGP, "192.168.1.0/24"), source: "b.yaml"},
+			},
+			policy:              DuplicatePolicyMerge,
+			expectedErrorString: "protocol and autoAssign must match",
+		},
+	}
+	for desc, tc := range tcs {
+		pools, err := resolveDuplicates(tc.pools, tc.policy)
+		if tc.expectedErrorString != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.expectedErrorString) {
+				t.Fatalf("TestResolveDuplicates(%s): expected error %q but got %v", desc, tc.expectedErrorString, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("TestResolveDuplicates(%s): unexpected error, err: %q", desc, err)
+		}
+		var addresses [][]string
+		for _, p := range pools {
+			addresses = append(addresses, p.Spec.Addresses)
+		}
+		if !reflect.DeepEqual(addresses, tc.expectedAddresses) {
+			t.Fatalf("TestResolveDuplicates(%s): expected addresses %v but got %v", desc, tc.expectedAddresses,
+				addresses)
+		}
+	}
+}
+
+func TestReadLegacyObjectsFromDirectoryDuplicates(t *testing.T) {
+	var scheme = runtime.NewScheme()
+	if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestReadLegacyObjectsFromDirectoryDuplicates: error adding to scheme, err: %q", err)
+	}
+	tmpDir := t.TempDir()
+	for _, fileName := range []string{"a.yaml", "b.yaml"} {
+		content := validAddressPoolFiles["bgp-addresspools.yaml"]
+		if err := os.WriteFile(path.Join(tmpDir, fileName), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err := ReadLegacyObjectsFromDirectory(scheme, tmpDir, Options{})
+	if err == nil || !strings.Contains(err.Error(), "in: a.yaml, b.yaml") {
+		t.Fatalf("TestReadLegacyObjectsFromDirectoryDuplicates: expected a duplicate error but got %v", err)
+	}
+
+	opts := Options{DuplicatePolicy: DuplicatePolicyKeepLast}
+	if _, err := ReadLegacyObjectsFromDirectory(scheme, tmpDir, opts); err != nil {
+		t.Fatalf("TestReadLegacyObjectsFromDirectoryDuplicates: unexpected error with %s, err: %q",
+			DuplicatePolicyKeepLast, err)
+	}
+	if err := (Options{DuplicatePolicy: "invalid"}).Validate(); err == nil {
+		t.Fatalf("TestReadLegacyObjectsFromDirectoryDuplicates: expected an error for an invalid policy")
+	}
+}
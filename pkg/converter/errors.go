@@ -0,0 +1,82 @@
+package converter
+
+import (
+	"errors"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+var (
+	// ErrUnsupportedKind is wrapped by the errors about input documents that are not legacy MetalLB resources.
+	ErrUnsupportedKind = errors.New("unsupported kind")
+	// ErrInvalidProtocol is wrapped by the errors about legacy AddressPools whose protocol is neither layer2 nor bgp.
+	ErrInvalidProtocol = errors.New("invalid protocol")
+	// ErrWebhookRejected matches the errors of API requests that an admission webhook denied, see WebhookError.
+	ErrWebhookRejected = errors.New("rejected by admission webhook")
+	// ErrInvalidAddresses is wrapped by the error about legacy AddressPools with addresses that cannot be parsed.
+	ErrInvalidAddresses = errors.New("invalid addresses")
+	// ErrSchemaViolation is wrapped by the errors about converted resources that violate the CRD schemas.
+	ErrSchemaViolation = errors.New("schema validation failed")
+	// ErrStrictValidation is wrapped by the error about legacy AddressPools or converted resources that package
+	// validate finds problems in, see Options.StrictValidation.
+	ErrStrictValidation = errors.New("strict validation failed")
+	// ErrNameCollision is wrapped by the errors about converted objects whose names collide with each other or with
+	// objects of the cluster, see Options.NameCollisionPolicy.
+	ErrNameCollision = errors.New("name collision")
+	// ErrServiceIPsUncovered is wrapped by the error about assigned IPs of LoadBalancer Services that no IPAddressPool
+	// would cover after the migration.
+	ErrServiceIPsUncovered = errors.New("the following assigned IPs of LoadBalancer Services would no longer be " +
+		"covered by any IPAddressPool")
+	// ErrVerificationFailed is wrapped by the error about converted resources that do not match the API.
+	ErrVerificationFailed = errors.New("verification failed")
+	// ErrDriftDetected is wrapped by the error of a drift detection that found inconsistencies.
+	ErrDriftDetected = errors.New("drift detected")
+	// ErrSyncConflict is wrapped by the error of a sync that found AddressPools that could not be synced.
+	ErrSyncConflict = errors.New("sync conflict")
+	// ErrMigrationStopped is wrapped by the error of an online migration that was stopped by
+	// OnlineMigrationOptions.Stop.
+	ErrMigrationStopped = errors.New("online migration stopped")
+	// ErrBackupDirUnusable is wrapped by the errors about backup directories that an online migration cannot safely
+	// write its backup to.
+	ErrBackupDirUnusable = errors.New("unusable backup directory")
+	// ErrGoldenMismatch is wrapped by the error of a conversion whose output differs from its golden directory.
+	ErrGoldenMismatch = errors.New("converted output differs from golden directory")
+	// ErrPreflightFailed is wrapped by the error of an online migration whose preflight checks found problems before
+	// anything was modified.
+	ErrPreflightFailed = errors.New("preflight checks failed")
+	// ErrClusterMismatch is wrapped by the error of a conversion whose output differs from the objects of the cluster.
+	ErrClusterMismatch = errors.New("converted output differs from cluster")
+)
+
+// WebhookError is the error of an API request that an admission webhook denied, e.g. MetalLB's validating webhook.
+// It matches ErrWebhookRejected with errors.Is and unwraps to the error of the API request.
+type WebhookError struct {
+	Err error
+}
+
+func (e *WebhookError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *WebhookError) Unwrap() error {
+	return e.Err
+}
+
+// Is returns true if target is ErrWebhookRejected.
+func (e *WebhookError) Is(target error) bool {
+	return target == ErrWebhookRejected
+}
+
+// classifyAPIError returns err as a WebhookError if an admission webhook denied the request, and err otherwise.
+func classifyAPIError(err error) error {
+	var statusErr apierrors.APIStatus
+	if err == nil || !errors.As(err, &statusErr) {
+		return err
+	}
+	message := statusErr.Status().Message
+	if strings.Contains(message, "admission webhook") && strings.Contains(message, "denied the request") {
+		return &WebhookError{Err: err}
+	}
+	return err
+}
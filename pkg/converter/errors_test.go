@@ -0,0 +1,83 @@
+package converter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestClassifyAPIError(t *testing.T) {
+	gr := schema.GroupResource{Group: "metallb.io", Resource: "ipaddresspools"}
+	tcs := map[string]struct {
+		err             error
+		expectedWebhook bool
+	}{
+		"no error": {},
+		"denied by webhook": {
+			err: apierrors.NewForbidden(gr, "iap", errors.New(`admission webhook "ipaddresspoolvalidationwebhook" `+
+				`denied the request: overlapping CIDRs`)),
+			expectedWebhook: true,
+		},
+		"wrapped denial": {
+			err: fmt.Errorf("cannot create, err: %w", apierrors.NewBadRequest(`admission webhook "x" denied the `+
+				`request: invalid`)),
+			expectedWebhook: true,
+		},
+		"other API error": {
+			err: apierrors.NewAlreadyExists(gr, "iap"),
+		},
+		"not an API error": {
+			err: errors.New("admission webhook denied the request"),
+		},
+	}
+	for desc, tc := range tcs {
+		err := classifyAPIError(tc.err)
+		if errors.Is(err, ErrWebhookRejected) != tc.expectedWebhook {
+			t.Fatalf("TestClassifyAPIError(%s): expected errors.Is(ErrWebhookRejected) to be %t, got %v", desc,
+				tc.expectedWebhook, err)
+		}
+		if tc.err != nil && !errors.Is(err, tc.err) {
+			t.Fatalf("TestClassifyAPIError(%s): expected %v to unwrap to the original error", desc, err)
+		}
+		var webhookErr *WebhookError
+		if errors.As(err, &webhookErr) != tc.expectedWebhook {
+			t.Fatalf("TestClassifyAPIError(%s): expected errors.As(*WebhookError) to be %t", desc, tc.expectedWebhook)
+		}
+	}
+}
+
+func TestSentinelErrors(t *testing.T) {
+	var scheme = runtime.NewScheme()
+	if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestSentinelErrors: error adding to scheme, err: %q", err)
+	}
+	_, err := ReadLegacyObjects(scheme, []byte("apiVersion: metallb.io/v1beta1\nkind: IPAddressPool\n"+
+		"metadata:\n  name: iap\n"), "test", Options{})
+	if !errors.Is(err, ErrUnsupportedKind) {
+		t.Fatalf("TestSentinelErrors: expected ErrUnsupportedKind but got %v", err)
+	}
+	legacyObjects := &LegacyObjects{AddressPoolList: &metallbv1beta1.AddressPoolList{
+		Items: []metallbv1beta1.AddressPool{{
+			ObjectMeta: metav1.ObjectMeta{Name: "ap", Namespace: "metallb-system"},
+			Spec:       metallbv1beta1.AddressPoolSpec{Protocol: "ospf", Addresses: []string{"10.0.0.0/24"}},
+		}},
+	}}
+	if _, err := legacyObjects.Convert(Options{}); !errors.Is(err, ErrInvalidProtocol) {
+		t.Fatalf("TestSentinelErrors: expected ErrInvalidProtocol but got %v", err)
+	}
+	timeout := apierrors.NewServerTimeout(schema.GroupResource{Group: "metallb.io", Resource: "addresspools"}, "list",
+		1)
+	c := &flakyClient{Client: fake.NewClientBuilder().WithScheme(scheme).Build(), err: timeout, failures: 1}
+	if _, err := ReadLegacyObjectsFromAPI(context.TODO(), c, 0, Options{}); !errors.Is(err, timeout) ||
+		!isTransientAPIError(err, false) {
+		t.Fatalf("TestSentinelErrors: expected a transient error that wraps %v but got %v", timeout, err)
+	}
+}
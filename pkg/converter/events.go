@@ -0,0 +1,129 @@
+package converter
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/tools/reference"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// EventReasonConverted is the reason of the Events about AddressPools whose new resources were created.
+	EventReasonConverted = "Converted"
+	// EventReasonDeleted is the reason of the Events about deleted legacy AddressPools.
+	EventReasonDeleted = "Deleted"
+	// EventReasonMarkedMigrated is the reason of the Events about legacy AddressPools that were marked as migrated.
+	EventReasonMarkedMigrated = "MarkedMigrated"
+	// EventReasonMigrationFailed is the reason of the Events about AddressPools whose migration failed.
+	EventReasonMigrationFailed = "MigrationFailed"
+
+	// eventComponent is the source component of the Events of NewEventRecorder.
+	eventComponent = "metallb-converter"
+)
+
+// NewEventRecorder returns an EventRecorder that creates Events through c. Unlike the recorders of client-go, it
+// creates every Event before it returns, so that no Event is lost when the converter exits right after a migration.
+// Events are best effort: the first Event that cannot be created is logged, e.g. for lack of permissions, and all
+// failures are otherwise ignored.
+func NewEventRecorder(c client.Client) record.EventRecorder {
+	return &eventRecorder{client: c}
+}
+
+// contextEventRecorder is implemented by the EventRecorders that bind the creation of an Event to a context.
+type contextEventRecorder interface {
+	eventf(ctx context.Context, object runtime.Object, eventtype, reason, messageFmt string, args ...interface{})
+}
+
+// eventRecorder creates Events synchronously through a client.
+type eventRecorder struct {
+	client client.Client
+	warn   sync.Once
+}
+
+func (r *eventRecorder) Event(object runtime.Object, eventtype, reason, message string) {
+	r.record(context.Background(), object, nil, eventtype, reason, message)
+}
+
+func (r *eventRecorder) Eventf(object runtime.Object, eventtype, reason, messageFmt string, args ...interface{}) {
+	r.record(context.Background(), object, nil, eventtype, reason, fmt.Sprintf(messageFmt, args...))
+}
+
+func (r *eventRecorder) AnnotatedEventf(object runtime.Object, annotations map[string]string, eventtype, reason,
+	messageFmt string, args ...interface{}) {
+	r.record(context.Background(), object, annotations, eventtype, reason, fmt.Sprintf(messageFmt, args...))
+}
+
+func (r *eventRecorder) eventf(ctx context.Context, object runtime.Object, eventtype, reason, messageFmt string,
+	args ...interface{}) {
+	r.record(ctx, object, nil, eventtype, reason, fmt.Sprintf(messageFmt, args...))
+}
+
+// record creates an Event about object in its namespace, like the recorders of client-go. The methods of
+// record.EventRecorder do not take a context, so they record with context.Background(); migrations record through
+// eventf with their own context.
+func (r *eventRecorder) record(ctx context.Context, object runtime.Object, annotations map[string]string, eventtype,
+	reason, message string) {
+	ref, err := reference.GetReference(r.client.Scheme(), object)
+	if err == nil {
+		now := metav1.NewTime(time.Now())
+		namespace := ref.Namespace
+		if namespace == "" {
+			namespace = metav1.NamespaceDefault
+		}
+		err = r.client.Create(ctx, &corev1.Event{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        fmt.Sprintf("%v.%x", ref.Name, now.UnixNano()),
+				Namespace:   namespace,
+				Annotations: annotations,
+			},
+			InvolvedObject: *ref,
+			Reason:         reason,
+			Message:        message,
+			FirstTimestamp: now,
+			LastTimestamp:  now,
+			Count:          1,
+			Type:           eventtype,
+			Source:         corev1.EventSource{Component: eventComponent},
+		})
+	}
+	if err != nil && !quiet {
+		r.warn.Do(func() {
+			log.Printf("warning: cannot record Events, further failures are not logged, err: %v", err)
+		})
+	}
+}
+
+// convertedMessage returns the message of the EventReasonConverted Event of the AddressPool namespace/name, e.g.
+// "Converted AddressPool metallb-system/pool to IPAddressPool pool and 2 advertisements".
+func convertedMessage(namespace, name string, c *CurrentObjects) string {
+	var pools []string
+	for _, iap := range c.IPAddressPoolList.Items {
+		pools = append(pools, iap.Name)
+	}
+	kind := "IPAddressPool"
+	if len(pools) != 1 {
+		kind = "IPAddressPools"
+	}
+	return fmt.Sprintf("Converted AddressPool %s/%s to %s %s and %d advertisements", namespace, name, kind,
+		strings.Join(pools, ", "), len(c.L2AdvertisementList.Items)+len(c.BGPAdvertisementList.Items))
+}
+
+// event records an Event about object with opts.EventRecorder, if any. The Event is created with ctx if the recorder
+// supports it.
+func (opts OnlineMigrationOptions) event(ctx context.Context, object runtime.Object, eventtype, reason,
+	messageFmt string, args ...interface{}) {
+	if recorder, ok := opts.EventRecorder.(contextEventRecorder); ok {
+		recorder.eventf(ctx, object, eventtype, reason, messageFmt, args...)
+	} else if opts.EventRecorder != nil {
+		opts.EventRecorder.Eventf(object, eventtype, reason, messageFmt, args...)
+	}
+}
@@ -0,0 +1,188 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestOnlineMigrationEvents(t *testing.T) {
+	tcs := map[string]struct {
+		keepLegacy     bool
+		failurePoints  string
+		expectedEvents []string
+		expectedCounts map[string]int
+	}{
+		"migrated": {
+			expectedEvents: []string{
+				"Normal Deleted Deleted legacy AddressPool metallb-system/ap-l2",
+				"Normal Converted Converted AddressPool metallb-system/ap-l2 to IPAddressPool ap-l2 and 1 " +
+					"advertisements",
+			},
+			expectedCounts: map[string]int{EventReasonDeleted: 3, EventReasonConverted: 3},
+		},
+		"keep legacy": {
+			keepLegacy: true,
+			expectedEvents: []string{
+				"Normal MarkedMigrated Marked legacy AddressPool metallb-system/ap-bgp as migrated",
+			},
+			expectedCounts: map[string]int{EventReasonMarkedMigrated: 3, EventReasonConverted: 3},
+		},
+		"failure": {
+			failurePoints:  FailurePointBeforeCreate,
+			expectedEvents: []string{"Warning MigrationFailed Migration of AddressPool metallb-system/"},
+			expectedCounts: map[string]int{EventReasonDeleted: 1, EventReasonMigrationFailed: 1},
+		},
+	}
+	var scheme = runtime.NewScheme()
+	if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestOnlineMigrationEvents: error adding to scheme, err: %q", err)
+	}
+	SetQuiet(true)
+	defer SetQuiet(false)
+	for desc, tc := range tcs {
+		c := fake.NewClientBuilder().WithScheme(scheme).Build()
+		for _, ap := range validAddressPools0 {
+			if err := c.Create(context.TODO(), ap.DeepCopy()); err != nil {
+				t.Fatalf("TestOnlineMigrationEvents(%s): error building fake client, err: %q", desc, err)
+			}
+		}
+		failurePoints, err := ParseFailurePoints(tc.failurePoints)
+		if err != nil {
+			t.Fatalf("TestOnlineMigrationEvents(%s): cannot set failure points, err: %q", desc, err)
+		}
+		stdout = bytes.NewBuffer([]byte{})
+		recorder := record.NewFakeRecorder(100)
+		err = OnlineMigration(context.TODO(), c, scheme, t.TempDir(), false, OnlineMigrationOptions{
+			Options:       Options{FailurePoints: failurePoints},
+			KeepLegacy:    tc.keepLegacy,
+			EventRecorder: recorder,
+		})
+		if (tc.failurePoints == "") != (err == nil) {
+			t.Fatalf("TestOnlineMigrationEvents(%s): unexpected result of the migration, err: %v", desc, err)
+		}
+		close(recorder.Events)
+		var events []string
+		counts := map[string]int{}
+		for event := range recorder.Events {
+			events = append(events, event)
+			counts[strings.Fields(event)[1]]++
+		}
+		for _, expected := range tc.expectedEvents {
+			found := false
+			for _, event := range events {
+				found = found || strings.HasPrefix(event, expected)
+			}
+			if !found {
+				t.Fatalf("TestOnlineMigrationEvents(%s): expected event %q but got %v", desc, expected, events)
+			}
+		}
+		if len(counts) != len(tc.expectedCounts) {
+			t.Fatalf("TestOnlineMigrationEvents(%s): expected events %v but got %v", desc, tc.expectedCounts, events)
+		}
+		for reason, count := range tc.expectedCounts {
+			if counts[reason] != count {
+				t.Fatalf("TestOnlineMigrationEvents(%s): expected events %v but got %v", desc, tc.expectedCounts,
+					events)
+			}
+		}
+	}
+}
+
+func TestEventRecorder(t *testing.T) {
+	var scheme = runtime.NewScheme()
+	if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestEventRecorder: error adding to scheme, err: %q", err)
+	}
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestEventRecorder: error adding to scheme, err: %q", err)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+	ap := &metallbv1beta1.AddressPool{ObjectMeta: metav1.ObjectMeta{Name: "pool", Namespace: "tenant-a", UID: "uid"}}
+	NewEventRecorder(c).Eventf(ap, corev1.EventTypeNormal, EventReasonDeleted, "Deleted legacy AddressPool %s/%s",
+		ap.Namespace, ap.Name)
+
+	eventList := &corev1.EventList{}
+	if err := c.List(context.TODO(), eventList); err != nil {
+		t.Fatalf("TestEventRecorder: cannot list Events, err: %q", err)
+	}
+	if len(eventList.Items) != 1 {
+		t.Fatalf("TestEventRecorder: expected 1 Event but got %d", len(eventList.Items))
+	}
+	event := eventList.Items[0]
+	if event.Namespace != "tenant-a" || event.InvolvedObject.Kind != "AddressPool" ||
+		event.InvolvedObject.Name != "pool" || event.InvolvedObject.UID != "uid" ||
+		event.Reason != EventReasonDeleted || event.Message != "Deleted legacy AddressPool tenant-a/pool" ||
+		event.Type != corev1.EventTypeNormal || event.Source.Component != eventComponent || event.Count != 1 {
+		t.Fatalf("TestEventRecorder: unexpected Event %+v", event)
+	}
+
+	// Events that cannot be created, here because the scheme lacks them, are ignored.
+	withoutEvents := runtime.NewScheme()
+	if err := metallbv1beta1.AddToScheme(withoutEvents); err != nil {
+		t.Fatalf("TestEventRecorder: error adding to scheme, err: %q", err)
+	}
+	SetQuiet(true)
+	defer SetQuiet(false)
+	NewEventRecorder(fake.NewClientBuilder().WithScheme(withoutEvents).Build()).Event(ap, corev1.EventTypeWarning,
+		EventReasonMigrationFailed, "failed")
+}
+
+// migrationContextKey marks the context of a migration in tests.
+type migrationContextKey struct{}
+
+// eventContextClient counts the Events that are created with and without the context of a migration.
+type eventContextClient struct {
+	client.Client
+	withContext    int
+	withoutContext int
+}
+
+func (c *eventContextClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	if _, ok := obj.(*corev1.Event); ok {
+		if ctx.Value(migrationContextKey{}) != nil {
+			c.withContext++
+		} else {
+			c.withoutContext++
+		}
+	}
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func TestOnlineMigrationEventContext(t *testing.T) {
+	var scheme = runtime.NewScheme()
+	if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestOnlineMigrationEventContext: error adding to scheme, err: %q", err)
+	}
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestOnlineMigrationEventContext: error adding to scheme, err: %q", err)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+	for _, ap := range validAddressPools0 {
+		if err := c.Create(context.TODO(), ap.DeepCopy()); err != nil {
+			t.Fatalf("TestOnlineMigrationEventContext: error building fake client, err: %q", err)
+		}
+	}
+	SetQuiet(true)
+	defer SetQuiet(false)
+	stdout = bytes.NewBuffer([]byte{})
+	ec := &eventContextClient{Client: c}
+	ctx := context.WithValue(context.Background(), migrationContextKey{}, true)
+	err := OnlineMigration(ctx, c, scheme, t.TempDir(), false, OnlineMigrationOptions{EventRecorder: NewEventRecorder(ec)})
+	if err != nil {
+		t.Fatalf("TestOnlineMigrationEventContext: unexpected error during migration, err: %q", err)
+	}
+	if ec.withContext != 6 || ec.withoutContext != 0 {
+		t.Fatalf("TestOnlineMigrationEventContext: expected 6 Events created with the context of the migration, "+
+			"got %d with and %d without it", ec.withContext, ec.withoutContext)
+	}
+}
@@ -0,0 +1,120 @@
+package converter
+
+import (
+	"context"
+	"fmt"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	metallbv1beta2 "go.universe.tf/metallb/api/v1beta2"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// metallbV1beta2APIVersion is the API version of BGPPeers.
+const metallbV1beta2APIVersion = metallbAPIGroup + "/v1beta2"
+
+// ExportObjects holds the current MetalLB objects of a cluster, e.g. to bootstrap a GitOps repository from a cluster
+// that was migrated with OnlineMigration.
+type ExportObjects struct {
+	CurrentObjects
+	BGPPeerList    *metallbv1beta2.BGPPeerList
+	BFDProfileList *metallbv1beta1.BFDProfileList
+}
+
+// ReadExportObjectsFromAPI reads the current MetalLB objects from the API. Metadata that is managed by the API server
+// and the status are dropped, as for the legacy AddressPools that are converted. Kinds whose CRD is not installed are
+// treated as empty.
+func ReadExportObjectsFromAPI(ctx context.Context, c client.Client) (*ExportObjects, error) {
+	e := &ExportObjects{
+		CurrentObjects: CurrentObjects{
+			IPAddressPoolList:    &metallbv1beta1.IPAddressPoolList{},
+			L2AdvertisementList:  &metallbv1beta1.L2AdvertisementList{},
+			BGPAdvertisementList: &metallbv1beta1.BGPAdvertisementList{},
+			CommunityList:        &metallbv1beta1.CommunityList{},
+		},
+		BGPPeerList:    &metallbv1beta2.BGPPeerList{},
+		BFDProfileList: &metallbv1beta1.BFDProfileList{},
+	}
+	for _, list := range []client.ObjectList{
+		e.IPAddressPoolList, e.L2AdvertisementList, e.BGPAdvertisementList, e.CommunityList, e.BGPPeerList,
+		e.BFDProfileList,
+	} {
+		err := c.List(ctx, list)
+		if err != nil && !meta.IsNoMatchError(err) {
+			return nil, fmt.Errorf("failed to list %T in cluster: %w", list, err)
+		}
+	}
+	for i := range e.IPAddressPoolList.Items {
+		iap := &e.IPAddressPoolList.Items[i]
+		iap.TypeMeta = metav1.TypeMeta{Kind: "IPAddressPool", APIVersion: metallbAPIVersion}
+		iap.ObjectMeta = sanitizeObjectMeta(iap.ObjectMeta)
+		iap.Status = metallbv1beta1.IPAddressPoolStatus{}
+	}
+	for i := range e.L2AdvertisementList.Items {
+		l2a := &e.L2AdvertisementList.Items[i]
+		l2a.TypeMeta = metav1.TypeMeta{Kind: "L2Advertisement", APIVersion: metallbAPIVersion}
+		l2a.ObjectMeta = sanitizeObjectMeta(l2a.ObjectMeta)
+		l2a.Status = metallbv1beta1.L2AdvertisementStatus{}
+	}
+	for i := range e.BGPAdvertisementList.Items {
+		ba := &e.BGPAdvertisementList.Items[i]
+		ba.TypeMeta = metav1.TypeMeta{Kind: "BGPAdvertisement", APIVersion: metallbAPIVersion}
+		ba.ObjectMeta = sanitizeObjectMeta(ba.ObjectMeta)
+		ba.Status = metallbv1beta1.BGPAdvertisementStatus{}
+	}
+	for i := range e.CommunityList.Items {
+		community := &e.CommunityList.Items[i]
+		community.TypeMeta = metav1.TypeMeta{Kind: "Community", APIVersion: metallbAPIVersion}
+		community.ObjectMeta = sanitizeObjectMeta(community.ObjectMeta)
+		community.Status = metallbv1beta1.CommunityStatus{}
+	}
+	for i := range e.BGPPeerList.Items {
+		peer := &e.BGPPeerList.Items[i]
+		peer.TypeMeta = metav1.TypeMeta{Kind: "BGPPeer", APIVersion: metallbV1beta2APIVersion}
+		peer.ObjectMeta = sanitizeObjectMeta(peer.ObjectMeta)
+		peer.Status = metallbv1beta2.BGPPeerStatus{}
+	}
+	for i := range e.BFDProfileList.Items {
+		profile := &e.BFDProfileList.Items[i]
+		profile.TypeMeta = metav1.TypeMeta{Kind: "BFDProfile", APIVersion: metallbAPIVersion}
+		profile.ObjectMeta = sanitizeObjectMeta(profile.ObjectMeta)
+		profile.Status = metallbv1beta1.BFDProfileStatus{}
+	}
+	return e, nil
+}
+
+// Print writes the YAML or JSON representation of the objects either to the targetDirectory or to stdout if
+// targetDirectory == "", with the same grouping and layout as CurrentObjects.Print with opts. BGPPeers and BFDProfiles
+// are grouped by kind.
+func (e ExportObjects) Print(targetDirectory string, toJSON bool, opts Options) error {
+	var peers, profiles []runtime.Object
+	for i := range e.BGPPeerList.Items {
+		peers = append(peers, &e.BGPPeerList.Items[i])
+	}
+	for i := range e.BFDProfileList.Items {
+		profiles = append(profiles, &e.BFDProfileList.Items[i])
+	}
+	groups, err := e.CurrentObjects.outputGroups(targetDirectory != "", [][]runtime.Object{peers, profiles}, opts)
+	if err != nil {
+		return err
+	}
+	return printGroups(targetDirectory, groups, toJSON, opts)
+}
+
+// Export reads the current MetalLB objects from the API and prints them to outDir, or to stdout if outDir is empty,
+// in outputFormat, grouped and laid out according to opts.
+func Export(ctx context.Context, c client.Client, outDir string, outputFormat string, opts Options) error {
+	if outputFormat != OutputFormatYAML && outputFormat != OutputFormatJSON {
+		return fmt.Errorf("export only supports the %s and %s output formats", OutputFormatYAML, OutputFormatJSON)
+	}
+	e, err := ReadExportObjectsFromAPI(ctx, c)
+	if err != nil {
+		return fmt.Errorf("error during retrieval step, err: %w", err)
+	}
+	if err := e.Print(outDir, outputFormat == OutputFormatJSON, opts); err != nil {
+		return fmt.Errorf("error during print step, err: %w", err)
+	}
+	return nil
+}
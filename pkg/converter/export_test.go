@@ -0,0 +1,137 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	metallbv1beta2 "go.universe.tf/metallb/api/v1beta2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestExport(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := AddToScheme(scheme, Options{}); err != nil {
+		t.Fatalf("TestExport: error adding to scheme, err: %q", err)
+	}
+	objectMeta := func(name string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{Name: name, Namespace: "metallb-system", Labels: map[string]string{"team": "net"}}
+	}
+	objects := []client.Object{
+		&metallbv1beta1.IPAddressPool{
+			ObjectMeta: objectMeta("pool"),
+			Spec:       metallbv1beta1.IPAddressPoolSpec{Addresses: []string{"10.0.0.0/24"}},
+		},
+		&metallbv1beta1.BGPAdvertisement{
+			ObjectMeta: objectMeta("pool-bgp-advertisement-0"),
+			Spec:       metallbv1beta1.BGPAdvertisementSpec{IPAddressPools: []string{"pool"}},
+		},
+		&metallbv1beta2.BGPPeer{
+			ObjectMeta: objectMeta("peer"),
+			Spec:       metallbv1beta2.BGPPeerSpec{MyASN: 64500, ASN: 64501, Address: "10.0.0.1"},
+		},
+		&metallbv1beta1.BFDProfile{ObjectMeta: objectMeta("bfd")},
+	}
+	tcs := map[string]struct {
+		layout              string
+		groupBy             string
+		toDirectory         bool
+		outputFormat        string
+		expectedFiles       []string
+		expectedOutput      []string
+		expectedErrorString string
+	}{
+		"stdout": {
+			outputFormat: OutputFormatYAML,
+			expectedOutput: []string{
+				"apiVersion: metallb.io/v1beta1\nkind: IPAddressPool\n",
+				"    team: net\n",
+				"apiVersion: metallb.io/v1beta2\nkind: BGPPeer\n",
+				"kind: BFDProfile\n",
+			},
+		},
+		"flat": {
+			toDirectory:  true,
+			outputFormat: OutputFormatJSON,
+			expectedFiles: []string{
+				"BFDProfile.json", "BGPAdvertisement.json", "BGPPeer.json", "IPAddressPool.json",
+			},
+		},
+		"per kind dir": {
+			layout:       OutputLayoutPerKindDir,
+			toDirectory:  true,
+			outputFormat: OutputFormatYAML,
+			expectedFiles: []string{
+				"bfdprofiles/metallb-system_bfd.yaml",
+				"bgpadvertisements/metallb-system_pool-bgp-advertisement-0.yaml",
+				"bgppeers/metallb-system_peer.yaml",
+				"ipaddresspools/metallb-system_pool.yaml",
+			},
+		},
+		"by pool": {
+			groupBy:       OutputGroupByPool,
+			toDirectory:   true,
+			outputFormat:  OutputFormatYAML,
+			expectedFiles: []string{"BFDProfile.yaml", "BGPPeer.yaml", "metallb-system_pool.yaml"},
+		},
+		"unsupported output format": {
+			outputFormat:        OutputFormatCSV,
+			expectedErrorString: "export only supports the yaml and json output formats",
+		},
+	}
+	for desc, tc := range tcs {
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+		dir := ""
+		if tc.toDirectory {
+			dir = t.TempDir()
+		}
+		stdout = bytes.NewBuffer([]byte{})
+		err := Export(context.TODO(), c, dir, tc.outputFormat, Options{OutputLayout: tc.layout, OutputGroupBy: tc.groupBy})
+		if tc.expectedErrorString != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.expectedErrorString) {
+				t.Fatalf("TestExport(%s): expected error %q but got %v", desc, tc.expectedErrorString, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("TestExport(%s): unexpected error, err: %q", desc, err)
+		}
+		output := stdout.(*bytes.Buffer).String()
+		for _, expected := range tc.expectedOutput {
+			if !strings.Contains(output, expected) {
+				t.Fatalf("TestExport(%s): expected %q in output but got:\n%s", desc, expected, output)
+			}
+		}
+		if !tc.toDirectory {
+			if strings.Contains(output, "resourceVersion") {
+				t.Fatalf("TestExport(%s): expected sanitized metadata but got:\n%s", desc, output)
+			}
+			continue
+		}
+		var files []string
+		err = filepath.WalkDir(dir, func(p string, entry os.DirEntry, err error) error {
+			if err != nil || entry.IsDir() {
+				return err
+			}
+			name, err := filepath.Rel(dir, p)
+			files = append(files, filepath.ToSlash(name))
+			return err
+		})
+		if err != nil {
+			t.Fatalf("TestExport(%s): unexpected error, err: %q", desc, err)
+		}
+		sort.Strings(files)
+		if !reflect.DeepEqual(files, tc.expectedFiles) {
+			t.Fatalf("TestExport(%s): expected files %v but got %v", desc, tc.expectedFiles, files)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package converter
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// FailurePointBackup fails the backup step of online migrations and finalizations before anything is written.
+	FailurePointBackup = "backup"
+	// FailurePointAfterDelete fails right after legacy AddressPools were deleted.
+	FailurePointAfterDelete = "after-delete"
+	// FailurePointBeforeCreate fails right before the converted resources of an AddressPool are created.
+	FailurePointBeforeCreate = "before-create"
+)
+
+var (
+	// SupportedFailurePoints lists all points that can be passed to ParseFailurePoints.
+	SupportedFailurePoints = []string{FailurePointBackup, FailurePointAfterDelete, FailurePointBeforeCreate}
+
+	// ErrInjectedFailure is wrapped by all errors that are returned at an injected failure point.
+	ErrInjectedFailure = errors.New("injected failure")
+)
+
+// FailurePoints are the armed failure points of a migration, see Options.FailurePoints.
+type FailurePoints struct {
+	mu sync.Mutex
+	// points maps every armed failure point to the hit that fails, starting with 1.
+	points map[string]int
+	// hits counts how often every failure point was reached.
+	hits map[string]int
+}
+
+// ParseFailurePoints arms failure points for testing the recovery from failed migrations. spec is a comma separated
+// list of failure points, each optionally followed by the hit that fails, e.g. "after-delete:2" fails after the
+// AddressPool of the second pool was deleted. Without a hit, the first hit fails. An empty spec arms no points.
+func ParseFailurePoints(spec string) (*FailurePoints, error) {
+	points := map[string]int{}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		point, hit, found := strings.Cut(entry, ":")
+		n := 1
+		if found {
+			var err error
+			if n, err = strconv.Atoi(hit); err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid hit %q of failure point %q, must be a positive integer", hit, point)
+			}
+		}
+		if !contains(SupportedFailurePoints, point) {
+			return nil, fmt.Errorf("unsupported failure point %q, must be one of: %s", point,
+				strings.Join(SupportedFailurePoints, ", "))
+		}
+		points[point] = n
+	}
+	return &FailurePoints{points: points, hits: map[string]int{}}, nil
+}
+
+// inject returns an error that wraps ErrInjectedFailure if point is armed and reached for the configured time. Nothing
+// is injected into nil FailurePoints.
+func (f *FailurePoints) inject(point string) error {
+	if f == nil {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n, ok := f.points[point]
+	if !ok {
+		return nil
+	}
+	f.hits[point]++
+	if f.hits[point] != n {
+		return nil
+	}
+	return fmt.Errorf("%w at %s (hit %d)", ErrInjectedFailure, point, n)
+}
@@ -0,0 +1,99 @@
+package converter
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestParseFailurePoints(t *testing.T) {
+	tcs := map[string]struct {
+		spec                string
+		expectedErrorString string
+	}{
+		"empty":            {spec: ""},
+		"single":           {spec: FailurePointBackup},
+		"multiple":         {spec: "after-delete:2, before-create"},
+		"unsupported":      {spec: "during-lunch", expectedErrorString: "unsupported failure point"},
+		"invalid hit":      {spec: "after-delete:0", expectedErrorString: "invalid hit"},
+		"non-numeric hit":  {spec: "after-delete:x", expectedErrorString: "invalid hit"},
+		"missing point":    {spec: ":1", expectedErrorString: "unsupported failure point"},
+		"trailing comma":   {spec: "backup,"},
+		"whitespace only":  {spec: " "},
+		"repeated entries": {spec: "backup:1,backup:2"},
+	}
+	for desc, tc := range tcs {
+		_, err := ParseFailurePoints(tc.spec)
+		if tc.expectedErrorString == "" && err != nil {
+			t.Fatalf("TestParseFailurePoints(%s): unexpected error, err: %q", desc, err)
+		}
+		if tc.expectedErrorString != "" && (err == nil || !strings.Contains(err.Error(), tc.expectedErrorString)) {
+			t.Fatalf("TestParseFailurePoints(%s): expected error %q but got %v", desc, tc.expectedErrorString, err)
+		}
+	}
+}
+
+func TestOnlineMigrationInjectedFailures(t *testing.T) {
+	tcs := map[string]struct {
+		spec                   string
+		expectedAddressPools   int
+		expectedIPAddressPools int
+	}{
+		"backup": {
+			spec:                   FailurePointBackup,
+			expectedAddressPools:   len(validAddressPools0),
+			expectedIPAddressPools: 0,
+		},
+		"after the first delete": {
+			spec:                   FailurePointAfterDelete,
+			expectedAddressPools:   len(validAddressPools0) - 1,
+			expectedIPAddressPools: 0,
+		},
+		"before the second create": {
+			spec:                   FailurePointBeforeCreate + ":2",
+			expectedAddressPools:   len(validAddressPools0) - 2,
+			expectedIPAddressPools: 1,
+		},
+	}
+	for desc, tc := range tcs {
+		var scheme = runtime.NewScheme()
+		if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+			t.Fatalf("TestOnlineMigrationInjectedFailures(%s): error adding to scheme, err: %q", desc, err)
+		}
+		c := fake.NewClientBuilder().WithScheme(scheme).Build()
+		for _, ap := range validAddressPools0 {
+			if err := c.Create(context.TODO(), ap.DeepCopy()); err != nil {
+				t.Fatalf("TestOnlineMigrationInjectedFailures(%s): error building fake client, err: %q", desc, err)
+			}
+		}
+		failurePoints, err := ParseFailurePoints(tc.spec)
+		if err != nil {
+			t.Fatalf("TestOnlineMigrationInjectedFailures(%s): cannot arm failure points, err: %q", desc, err)
+		}
+		err = OnlineMigration(context.TODO(), c, scheme, t.TempDir(), false, OnlineMigrationOptions{
+			Options: Options{FailurePoints: failurePoints},
+		})
+		if !errors.Is(err, ErrInjectedFailure) {
+			t.Fatalf("TestOnlineMigrationInjectedFailures(%s): expected an injected failure but got %v", desc, err)
+		}
+		addressPoolList := &metallbv1beta1.AddressPoolList{}
+		if err := c.List(context.TODO(), addressPoolList); err != nil {
+			t.Fatalf("TestOnlineMigrationInjectedFailures(%s): cannot list AddressPools, err: %q", desc, err)
+		}
+		ipAddressPoolList := &metallbv1beta1.IPAddressPoolList{}
+		if err := c.List(context.TODO(), ipAddressPoolList); err != nil {
+			t.Fatalf("TestOnlineMigrationInjectedFailures(%s): cannot list IPAddressPools, err: %q", desc, err)
+		}
+		if len(addressPoolList.Items) != tc.expectedAddressPools ||
+			len(ipAddressPoolList.Items) != tc.expectedIPAddressPools {
+			t.Fatalf("TestOnlineMigrationInjectedFailures(%s): expected %d AddressPools and %d IPAddressPools, got "+
+				"%d and %d", desc, tc.expectedAddressPools, tc.expectedIPAddressPools, len(addressPoolList.Items),
+				len(ipAddressPoolList.Items))
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package converter
+
+import (
+	"fmt"
+	"log"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+)
+
+// addressesByFamily returns the IPv4 and the IPv6 addresses of a pool. Addresses that cannot be parsed are kept
+// with the IPv4 addresses, they were already rejected by validateAddressPools.
+func addressesByFamily(addresses []string) ([]string, []string) {
+	var v4, v6 []string
+	for _, address := range addresses {
+		first, _, err := parseAddressRange(address)
+		if err == nil && !first.Is4() {
+			v6 = append(v6, address)
+			continue
+		}
+		v4 = append(v4, address)
+	}
+	return v4, v6
+}
+
+// splitL2Families splits the dual-stack IPAddressPools that are referenced by an L2Advertisement into a <name>-ipv4
+// and a <name>-ipv6 IPAddressPool. An L2Advertisement that only references the split pool is replaced by one
+// L2Advertisement per family. All other references are replaced by references to both new pools.
+func (c *CurrentObjects) splitL2Families() {
+	advertised := map[string]bool{}
+	for _, l2a := range c.L2AdvertisementList.Items {
+		for _, pool := range l2a.Spec.IPAddressPools {
+			advertised[fmt.Sprintf("%s/%s", l2a.Namespace, pool)] = true
+		}
+	}
+	split := map[string][]string{}
+	var items []metallbv1beta1.IPAddressPool
+	for _, iap := range c.IPAddressPoolList.Items {
+		v4, v6 := addressesByFamily(iap.Spec.Addresses)
+		if !advertised[fmt.Sprintf("%s/%s", iap.Namespace, iap.Name)] || len(v4) == 0 || len(v6) == 0 {
+			items = append(items, iap)
+			continue
+		}
+		v4Pool, v6Pool := *iap.DeepCopy(), *iap.DeepCopy()
+		v4Pool.Name, v4Pool.Spec.Addresses = fmt.Sprintf("%s-ipv4", iap.Name), v4
+		v6Pool.Name, v6Pool.Spec.Addresses = fmt.Sprintf("%s-ipv6", iap.Name), v6
+		items = append(items, v4Pool, v6Pool)
+		split[fmt.Sprintf("%s/%s", iap.Namespace, iap.Name)] = []string{v4Pool.Name, v6Pool.Name}
+		if !quiet {
+			log.Printf("IPAddressPool %s/%s was split into %s and %s, update Services that request it with the %s "+
+				"annotation", iap.Namespace, iap.Name, v4Pool.Name, v6Pool.Name, ServiceAddressPoolAnnotation)
+		}
+	}
+	if len(split) == 0 {
+		return
+	}
+	c.IPAddressPoolList.Items = items
+	var l2Items []metallbv1beta1.L2Advertisement
+	for _, l2a := range c.L2AdvertisementList.Items {
+		pools := l2a.Spec.IPAddressPools
+		if len(pools) == 1 && split[fmt.Sprintf("%s/%s", l2a.Namespace, pools[0])] != nil {
+			for _, name := range split[fmt.Sprintf("%s/%s", l2a.Namespace, pools[0])] {
+				familyL2a := *l2a.DeepCopy()
+				familyL2a.Name = fmt.Sprintf("%s-l2-advertisement", name)
+				familyL2a.Spec.IPAddressPools = []string{name}
+				l2Items = append(l2Items, familyL2a)
+			}
+			continue
+		}
+		l2a.Spec.IPAddressPools = replacePools(split, l2a.Namespace, pools)
+		l2Items = append(l2Items, l2a)
+	}
+	c.L2AdvertisementList.Items = l2Items
+	for i := range c.BGPAdvertisementList.Items {
+		ba := &c.BGPAdvertisementList.Items[i]
+		ba.Spec.IPAddressPools = replacePools(split, ba.Namespace, ba.Spec.IPAddressPools)
+	}
+}
+
+// replacePools returns pools with every pool that was split into new pools, keyed by namespace/name, replaced by the
+// new pools.
+func replacePools(split map[string][]string, namespace string, pools []string) []string {
+	var result []string
+	for _, pool := range pools {
+		if names, ok := split[fmt.Sprintf("%s/%s", namespace, pool)]; ok {
+			result = append(result, names...)
+			continue
+		}
+		result = append(result, pool)
+	}
+	return result
+}
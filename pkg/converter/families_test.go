@@ -0,0 +1,73 @@
+package converter
+
+import (
+	"fmt"
+	"testing"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSplitL2Families(t *testing.T) {
+	tcs := map[string]struct {
+		protocol               string
+		addresses              []string
+		expectedPools          string
+		expectedL2Pools        string
+		expectedBGPAdvertPools string
+	}{
+		"dual-stack layer2": {
+			protocol:        ProtocolLayer2,
+			addresses:       []string{"192.168.0.0/24", "2001:db8::/120", "192.168.1.1-192.168.1.10"},
+			expectedPools:   "[ap-ipv4:[192.168.0.0/24 192.168.1.1-192.168.1.10] ap-ipv6:[2001:db8::/120]]",
+			expectedL2Pools: "[ap-ipv4-l2-advertisement:[ap-ipv4] ap-ipv6-l2-advertisement:[ap-ipv6]]",
+		},
+		"single-stack layer2": {
+			protocol:        ProtocolLayer2,
+			addresses:       []string{"2001:db8::/120"},
+			expectedPools:   "[ap:[2001:db8::/120]]",
+			expectedL2Pools: "[ap-l2-advertisement:[ap]]",
+		},
+		"dual-stack bgp": {
+			protocol:               ProtocolBGP,
+			addresses:              []string{"192.168.0.0/24", "2001:db8::/120"},
+			expectedPools:          "[ap:[192.168.0.0/24 2001:db8::/120]]",
+			expectedL2Pools:        "[]",
+			expectedBGPAdvertPools: "[ap-bgp-advertisement-0:[ap]]",
+		},
+	}
+	for desc, tc := range tcs {
+		ap := metallbv1beta1.AddressPool{
+			ObjectMeta: metav1.ObjectMeta{Name: "ap", Namespace: "metallb-system"},
+			Spec:       metallbv1beta1.AddressPoolSpec{Protocol: tc.protocol, Addresses: tc.addresses},
+		}
+		legacyObjects := &LegacyObjects{AddressPoolList: &metallbv1beta1.AddressPoolList{
+			Items: []metallbv1beta1.AddressPool{ap},
+		}}
+		currentObjects, err := legacyObjects.Convert(Options{SplitL2AddressFamilies: true})
+		if err != nil {
+			t.Fatalf("TestSplitL2Families(%s): unexpected error during conversion, err: %q", desc, err)
+		}
+		var pools, l2Pools []string
+		for _, iap := range currentObjects.IPAddressPoolList.Items {
+			pools = append(pools, fmt.Sprintf("%s:%v", iap.Name, iap.Spec.Addresses))
+		}
+		for _, l2a := range currentObjects.L2AdvertisementList.Items {
+			l2Pools = append(l2Pools, fmt.Sprintf("%s:%v", l2a.Name, l2a.Spec.IPAddressPools))
+		}
+		if fmt.Sprint(pools) != tc.expectedPools {
+			t.Fatalf("TestSplitL2Families(%s): expected pools %s but got %v", desc, tc.expectedPools, pools)
+		}
+		if fmt.Sprint(l2Pools) != tc.expectedL2Pools {
+			t.Fatalf("TestSplitL2Families(%s): expected L2Advertisements %s but got %v", desc, tc.expectedL2Pools,
+				l2Pools)
+		}
+		if tc.expectedBGPAdvertPools != "" {
+			ba := currentObjects.BGPAdvertisementList.Items[0]
+			if bgpPools := fmt.Sprintf("[%s:%v]", ba.Name, ba.Spec.IPAddressPools); bgpPools != tc.expectedBGPAdvertPools {
+				t.Fatalf("TestSplitL2Families(%s): expected BGPAdvertisements %s but got %s", desc,
+					tc.expectedBGPAdvertPools, bgpPools)
+			}
+		}
+	}
+}
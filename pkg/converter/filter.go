@@ -0,0 +1,26 @@
+package converter
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ParseSelector parses a label selector for Options.Selector, e.g. tenant=a. An empty selector returns nil, which
+// selects all AddressPools.
+func ParseSelector(selector string) (labels.Selector, error) {
+	if selector == "" {
+		return nil, nil
+	}
+	parsed, err := labels.Parse(selector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid selector %q, err: %w", selector, err)
+	}
+	return parsed, nil
+}
+
+// listOptions returns the options that restrict the legacy AddressPools that ReadLegacyObjectsFromAPI reads.
+func (o Options) listOptions() client.ListOptions {
+	return client.ListOptions{Namespace: o.Namespace, LabelSelector: o.Selector}
+}
@@ -0,0 +1,98 @@
+package converter
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestListFilter(t *testing.T) {
+	tcs := map[string]struct {
+		namespace           string
+		selector            string
+		expectedPools       []string
+		expectedPeers       int
+		expectedErrorString string
+	}{
+		"no filter": {
+			expectedPools: []string{"tenant-a/ap-a", "tenant-a/ap-test", "tenant-b/ap-b"},
+			expectedPeers: 2,
+		},
+		"namespace": {
+			namespace:     "tenant-a",
+			expectedPools: []string{"tenant-a/ap-a", "tenant-a/ap-test"},
+			expectedPeers: 1,
+		},
+		"selector": {
+			selector:      "tier!=test",
+			expectedPools: []string{"tenant-a/ap-a", "tenant-b/ap-b"},
+			expectedPeers: 2,
+		},
+		"namespace and selector": {
+			namespace:     "tenant-a",
+			selector:      "tier=test",
+			expectedPools: []string{"tenant-a/ap-test"},
+			expectedPeers: 1,
+		},
+		"invalid selector": {
+			selector:            "tier=(",
+			expectedErrorString: "invalid selector",
+		},
+	}
+	var scheme = runtime.NewScheme()
+	if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestListFilter: error adding to scheme, err: %q", err)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+	for _, ap := range []metallbv1beta1.AddressPool{
+		{ObjectMeta: metav1.ObjectMeta{Name: "ap-a", Namespace: "tenant-a", Labels: map[string]string{"tier": "prod"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "ap-test", Namespace: "tenant-a", Labels: map[string]string{"tier": "test"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "ap-b", Namespace: "tenant-b"}},
+	} {
+		ap.Spec = metallbv1beta1.AddressPoolSpec{Protocol: "layer2", Addresses: []string{"192.168.0.0/24"}}
+		if err := c.Create(context.TODO(), &ap); err != nil {
+			t.Fatalf("TestListFilter: error building fake client, err: %q", err)
+		}
+	}
+	for _, namespace := range []string{"tenant-a", "tenant-b"} {
+		peer := &metallbv1beta1.BGPPeer{ObjectMeta: metav1.ObjectMeta{Name: "peer", Namespace: namespace}}
+		if err := c.Create(context.TODO(), peer); err != nil {
+			t.Fatalf("TestListFilter: error building fake client, err: %q", err)
+		}
+	}
+	for desc, tc := range tcs {
+		selector, err := ParseSelector(tc.selector)
+		if tc.expectedErrorString != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.expectedErrorString) {
+				t.Fatalf("TestListFilter(%s): expected error %q but got %v", desc, tc.expectedErrorString, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("TestListFilter(%s): unexpected error, err: %q", desc, err)
+		}
+		opts := Options{Namespace: tc.namespace, Selector: selector}
+		legacyObjects, err := ReadLegacyObjectsFromAPI(context.TODO(), c, 0, opts)
+		if err != nil {
+			t.Fatalf("TestListFilter(%s): unexpected error, err: %q", desc, err)
+		}
+		var pools []string
+		for _, ap := range legacyObjects.AddressPoolList.Items {
+			pools = append(pools, ap.Namespace+"/"+ap.Name)
+		}
+		sort.Strings(pools)
+		if strings.Join(pools, ",") != strings.Join(tc.expectedPools, ",") {
+			t.Fatalf("TestListFilter(%s): expected AddressPools %v but got %v", desc, tc.expectedPools, pools)
+		}
+		if len(legacyObjects.BGPPeerList.Items) != tc.expectedPeers {
+			t.Fatalf("TestListFilter(%s): expected %d BGPPeers but got %d", desc, tc.expectedPeers,
+				len(legacyObjects.BGPPeerList.Items))
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package converter
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// PhaseMark creates the new resources and marks the legacy AddressPools as superseded (MigratedAnnotation).
+	PhaseMark = "mark"
+	// PhaseFinalize verifies the new resources of all marked AddressPools and only then deletes the marked pools.
+	PhaseFinalize = "finalize"
+)
+
+// Verify checks that every object exists in the API and that its spec matches the converted spec. It returns an
+// error that lists all discrepancies.
+func (c CurrentObjects) Verify(ctx context.Context, cl client.Client) error {
+	if problems := c.discrepancies(ctx, cl); len(problems) > 0 {
+		return fmt.Errorf("%w:\n%s", ErrVerificationFailed, strings.Join(problems, "\n"))
+	}
+	return nil
+}
+
+// discrepancies returns a description of every object that does not exist in the API or whose spec differs from the
+// converted spec.
+func (c CurrentObjects) discrepancies(ctx context.Context, cl client.Client) []string {
+	var problems []string
+	for _, iap := range c.IPAddressPoolList.Items {
+		current := &metallbv1beta1.IPAddressPool{}
+		key := types.NamespacedName{Namespace: iap.Namespace, Name: iap.Name}
+		if err := cl.Get(ctx, key, current); err != nil {
+			problems = append(problems, fmt.Sprintf("IPAddressPool %s/%s: %v", iap.Namespace, iap.Name, err))
+			continue
+		}
+		if !equality.Semantic.DeepEqual(defaultedIPAddressPoolSpec(iap.Spec), defaultedIPAddressPoolSpec(current.Spec)) {
+			problems = append(problems, fmt.Sprintf("IPAddressPool %s/%s: spec differs from the converted spec",
+				iap.Namespace, iap.Name))
+		}
+	}
+	for _, l2a := range c.L2AdvertisementList.Items {
+		current := &metallbv1beta1.L2Advertisement{}
+		key := types.NamespacedName{Namespace: l2a.Namespace, Name: l2a.Name}
+		if err := cl.Get(ctx, key, current); err != nil {
+			problems = append(problems, fmt.Sprintf("L2Advertisement %s/%s: %v", l2a.Namespace, l2a.Name, err))
+			continue
+		}
+		if !equality.Semantic.DeepEqual(l2a.Spec, current.Spec) {
+			problems = append(problems, fmt.Sprintf("L2Advertisement %s/%s: spec differs from the converted spec",
+				l2a.Namespace, l2a.Name))
+		}
+	}
+	for _, ba := range c.BGPAdvertisementList.Items {
+		current := &metallbv1beta1.BGPAdvertisement{}
+		key := types.NamespacedName{Namespace: ba.Namespace, Name: ba.Name}
+		if err := cl.Get(ctx, key, current); err != nil {
+			problems = append(problems, fmt.Sprintf("BGPAdvertisement %s/%s: %v", ba.Namespace, ba.Name, err))
+			continue
+		}
+		if !equality.Semantic.DeepEqual(defaultedBGPAdvertisementSpec(ba.Spec),
+			defaultedBGPAdvertisementSpec(current.Spec)) {
+			problems = append(problems, fmt.Sprintf("BGPAdvertisement %s/%s: spec differs from the converted spec",
+				ba.Namespace, ba.Name))
+		}
+	}
+	for _, community := range c.CommunityList.Items {
+		current := &metallbv1beta1.Community{}
+		key := types.NamespacedName{Namespace: community.Namespace, Name: community.Name}
+		if err := cl.Get(ctx, key, current); err != nil {
+			problems = append(problems, fmt.Sprintf("Community %s/%s: %v", community.Namespace, community.Name, err))
+			continue
+		}
+		// The Community is shared by all pools of a namespace, so it only has to contain the converted aliases.
+		existing := map[metallbv1beta1.CommunityAlias]bool{}
+		for _, alias := range current.Spec.Communities {
+			existing[alias] = true
+		}
+		for _, alias := range community.Spec.Communities {
+			if !existing[alias] {
+				problems = append(problems, fmt.Sprintf("Community %s/%s: alias %s=%s is missing",
+					community.Namespace, community.Name, alias.Name, alias.Value))
+			}
+		}
+	}
+	return problems
+}
+
+// defaultedIPAddressPoolSpec returns spec with the defaults of the IPAddressPool CRD, which the API server sets on
+// admission, so that an IPAddressPool that is read back compares equal to its converted spec.
+func defaultedIPAddressPoolSpec(spec metallbv1beta1.IPAddressPoolSpec) metallbv1beta1.IPAddressPoolSpec {
+	if spec.AutoAssign == nil {
+		autoAssign := true
+		spec.AutoAssign = &autoAssign
+	}
+	return spec
+}
+
+// defaultedBGPAdvertisementSpec returns spec with the defaults of the BGPAdvertisement CRD, see
+// defaultedIPAddressPoolSpec.
+func defaultedBGPAdvertisementSpec(spec metallbv1beta1.BGPAdvertisementSpec) metallbv1beta1.BGPAdvertisementSpec {
+	if spec.AggregationLength == nil {
+		aggregationLength := int32(32)
+		spec.AggregationLength = &aggregationLength
+	}
+	if spec.AggregationLengthV6 == nil {
+		aggregationLengthV6 := int32(128)
+		spec.AggregationLengthV6 = &aggregationLengthV6
+	}
+	return spec
+}
+
+// migratedAt returns the time at which the AddressPool was marked with MigratedAnnotation. It returns false if the
+// AddressPool is not marked or if the annotation does not hold an RFC3339 timestamp.
+func migratedAt(ap metallbv1beta1.AddressPool) (time.Time, bool) {
+	value, ok := ap.Annotations[MigratedAnnotation]
+	if !ok {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// isDue returns true if the marked AddressPool can be deleted at now, given the grace period deleteAfter. Without a
+// grace period, every marked AddressPool is due. With a grace period, AddressPools whose mark cannot be parsed are
+// never due.
+func isDue(ap metallbv1beta1.AddressPool, deleteAfter time.Duration, now time.Time) bool {
+	if deleteAfter <= 0 {
+		return true
+	}
+	t, ok := migratedAt(ap)
+	return ok && !now.Before(t.Add(deleteAfter))
+}
+
+// FinalizeMigration is the second phase of a two-phase migration. It backs up all AddressPools that were marked as
+// superseded by PhaseMark, next to the backup of the mark phase if backupDirFlag holds one, verifies that their
+// converted counterparts exist and match, and only if verification passes for every marked AddressPool, deletes the
+// marked AddressPools. Unmarked AddressPools are left untouched.
+// If deleteAfter is set, only AddressPools that were marked at least deleteAfter ago are finalized, the others are
+// left for a later run. The AddressPools are read, converted and verified, and the operations are run, according to
+// opts. The API requests are bound to ctx.
+func FinalizeMigration(ctx context.Context, c client.Client, backupDirFlag string, jsonFlag bool,
+	deleteAfter time.Duration, opts Options) error {
+	ctx, span := tracer.Start(ctx, "FinalizeMigration")
+	defer span.End()
+
+	legacyObjects, err := ReadLegacyObjectsFromAPI(ctx, c, 0, opts)
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("error during retrieval step, err: %w", err)
+	}
+	now := time.Now().UTC()
+	marked := &LegacyObjects{AddressPoolList: &metallbv1beta1.AddressPoolList{}}
+	for _, ap := range legacyObjects.AddressPoolList.Items {
+		if !isMigrated(ap) {
+			continue
+		}
+		if !isDue(ap, deleteAfter, now) {
+			if !quiet {
+				log.Printf("AddressPool %s/%s is still within its grace period of %s, skipping ...",
+					ap.Namespace, ap.Name, deleteAfter)
+			}
+			continue
+		}
+		marked.AddressPoolList.Items = append(marked.AddressPoolList.Items, ap)
+	}
+	if len(marked.AddressPoolList.Items) == 0 {
+		if !quiet {
+			log.Print("no AddressPools are due for deletion, nothing to finalize")
+		}
+		return nil
+	}
+
+	// Backup step. The backup of the mark phase in the same directory is kept.
+	_, childSpan := tracer.Start(ctx, "backup")
+	backupName := finalizeBackupName(backupDirFlag, now)
+	err = opts.FailurePoints.inject(FailurePointBackup)
+	if err == nil {
+		err = runOperation(ctx, c, opts.OperationPolicies, OperationBackup, func(ctx context.Context, _ client.Client) error {
+			return marked.writeBackup(ctx, backupDirFlag, backupName, jsonFlag, opts)
+		})
+	}
+	if err == nil && backupName != "AddressPool" && !quiet {
+		log.Printf("keeping the backup in %s, the finalized AddressPools were backed up to %s", backupDirFlag,
+			backupName)
+	}
+	childSpan.RecordError(err)
+	childSpan.End()
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("error during backup step, err: %w", err)
+	}
+
+	// Verification step.
+	_, childSpan = tracer.Start(ctx, "verify")
+	currentObjects, err := marked.Convert(opts)
+	if err == nil {
+		err = currentObjects.ResolveCommunityAliases(ctx, c, opts.ExpandCommunityAliases)
+	}
+	if err == nil {
+		err = runOperation(ctx, c, opts.OperationPolicies, OperationVerify, currentObjects.Verify)
+	}
+	childSpan.RecordError(err)
+	childSpan.End()
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("refusing to finalize the migration, err: %w", err)
+	}
+
+	// Deletion step.
+	warnManagedAddressPools(marked)
+	_, childSpan = tracer.Start(ctx, "delete")
+	defer childSpan.End()
+	for _, ap := range marked.AddressPoolList.Items {
+		if !quiet {
+			log.Printf("deleting superseded AddressPool %s/%s ...", ap.Namespace, ap.Name)
+		}
+	}
+	err = runOperation(ctx, c, opts.OperationPolicies, OperationDelete, marked.Delete)
+	if err == nil {
+		err = opts.FailurePoints.inject(FailurePointAfterDelete)
+	}
+	childSpan.RecordError(err)
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("finalization failed during legacy object deletion, err: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestFinalizeMigration(t *testing.T) {
+	tcs := map[string]struct {
+		mark                 bool
+		markedAgo            time.Duration
+		deleteAfter          time.Duration
+		deleteIPAddressPool  string
+		expectError          bool
+		expectedAddressPools int
+	}{
+		"marked pools are deleted": {
+			mark:                 true,
+			expectedAddressPools: 0,
+		},
+		"verification failure keeps the marked pools": {
+			mark:                 true,
+			deleteIPAddressPool:  "ap-bgp",
+			expectError:          true,
+			expectedAddressPools: len(validAddressPools0),
+		},
+		"marked pools within the grace period are kept": {
+			mark:                 true,
+			deleteAfter:          24 * time.Hour,
+			expectedAddressPools: len(validAddressPools0),
+		},
+		"marked pools after the grace period are deleted": {
+			mark:                 true,
+			markedAgo:            48 * time.Hour,
+			deleteAfter:          24 * time.Hour,
+			expectedAddressPools: 0,
+		},
+		"no marked pools": {
+			expectedAddressPools: len(validAddressPools0),
+		},
+	}
+	for desc, tc := range tcs {
+		var scheme = runtime.NewScheme()
+		if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+			t.Fatalf("TestFinalizeMigration(%s): error adding to scheme, err: %q", desc, err)
+		}
+		c := fake.NewClientBuilder().WithScheme(scheme).Build()
+		for _, ap := range validAddressPools0 {
+			if err := c.Create(context.TODO(), ap.DeepCopy()); err != nil {
+				t.Fatalf("TestFinalizeMigration(%s): error building fake client, err: %q", desc, err)
+			}
+		}
+		if tc.mark {
+			err := OnlineMigration(context.TODO(), c, scheme, t.TempDir(), false, OnlineMigrationOptions{KeepLegacy: true})
+			if err != nil {
+				t.Fatalf("TestFinalizeMigration(%s): unexpected error during mark phase, err: %q", desc, err)
+			}
+		}
+		if tc.markedAgo != 0 {
+			var addressPoolList metallbv1beta1.AddressPoolList
+			if err := c.List(context.TODO(), &addressPoolList); err != nil {
+				t.Fatalf("TestFinalizeMigration(%s): cannot list AddressPools, err: %q", desc, err)
+			}
+			for _, ap := range addressPoolList.Items {
+				ap.Annotations[MigratedAnnotation] = time.Now().Add(-tc.markedAgo).UTC().Format(time.RFC3339)
+				if err := c.Update(context.TODO(), ap.DeepCopy()); err != nil {
+					t.Fatalf("TestFinalizeMigration(%s): cannot update AddressPool, err: %q", desc, err)
+				}
+			}
+		}
+		if tc.deleteIPAddressPool != "" {
+			var ipAddressPoolList metallbv1beta1.IPAddressPoolList
+			if err := c.List(context.TODO(), &ipAddressPoolList); err != nil {
+				t.Fatalf("TestFinalizeMigration(%s): cannot list IPAddressPools, err: %q", desc, err)
+			}
+			for _, iap := range ipAddressPoolList.Items {
+				if iap.Name == tc.deleteIPAddressPool {
+					if err := c.Delete(context.TODO(), iap.DeepCopy()); err != nil {
+						t.Fatalf("TestFinalizeMigration(%s): cannot delete IPAddressPool, err: %q", desc, err)
+					}
+				}
+			}
+		}
+
+		err := FinalizeMigration(context.TODO(), c, t.TempDir(), false, tc.deleteAfter, Options{})
+		if tc.expectError && err == nil {
+			t.Fatalf("TestFinalizeMigration(%s): expected an error but got none", desc)
+		}
+		if !tc.expectError && err != nil {
+			t.Fatalf("TestFinalizeMigration(%s): unexpected error, err: %q", desc, err)
+		}
+
+		var addressPoolList metallbv1beta1.AddressPoolList
+		if err := c.List(context.TODO(), &addressPoolList); err != nil {
+			t.Fatalf("TestFinalizeMigration(%s): cannot list AddressPools, err: %q", desc, err)
+		}
+		if len(addressPoolList.Items) != tc.expectedAddressPools {
+			t.Fatalf("TestFinalizeMigration(%s): expected %d AddressPools but got %d",
+				desc, tc.expectedAddressPools, len(addressPoolList.Items))
+		}
+	}
+}
+
+func TestFinalizeMigrationKeepsBackup(t *testing.T) {
+	var scheme = runtime.NewScheme()
+	if err := AddToScheme(scheme, Options{}); err != nil {
+		t.Fatalf("TestFinalizeMigrationKeepsBackup: error adding to scheme, err: %q", err)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+	for _, ap := range validAddressPools0 {
+		if err := c.Create(context.TODO(), ap.DeepCopy()); err != nil {
+			t.Fatalf("TestFinalizeMigrationKeepsBackup: error building fake client, err: %q", err)
+		}
+	}
+	SetQuiet(true)
+	defer SetQuiet(false)
+	// The mark phase and the finalization use the same backup directory.
+	backupDir := t.TempDir()
+	err := OnlineMigration(context.TODO(), c, scheme, backupDir, false, OnlineMigrationOptions{KeepLegacy: true})
+	if err != nil {
+		t.Fatalf("TestFinalizeMigrationKeepsBackup: unexpected error during mark phase, err: %q", err)
+	}
+	backup, err := os.ReadFile(filepath.Join(backupDir, "AddressPool.yaml"))
+	if err != nil {
+		t.Fatalf("TestFinalizeMigrationKeepsBackup: cannot read the backup of the mark phase, err: %q", err)
+	}
+
+	if err := FinalizeMigration(context.TODO(), c, backupDir, false, 0, Options{}); err != nil {
+		t.Fatalf("TestFinalizeMigrationKeepsBackup: unexpected error, err: %q", err)
+	}
+	kept, err := os.ReadFile(filepath.Join(backupDir, "AddressPool.yaml"))
+	if err != nil || !bytes.Equal(kept, backup) {
+		t.Fatalf("TestFinalizeMigrationKeepsBackup: expected the backup of the mark phase to be kept, err: %v", err)
+	}
+	restorable, err := ReadBackup(scheme, backupDir, Options{})
+	if err != nil || len(restorable.AddressPoolList.Items) != len(validAddressPools0) {
+		t.Fatalf("TestFinalizeMigrationKeepsBackup: expected %d AddressPools in the backup but got %+v, err: %v",
+			len(validAddressPools0), restorable, err)
+	}
+	finalized, err := filepath.Glob(filepath.Join(backupDir, "finalize-*.yaml"))
+	if err != nil || len(finalized) != 1 {
+		t.Fatalf("TestFinalizeMigrationKeepsBackup: expected one backup of the finalization but got %v, err: %v",
+			finalized, err)
+	}
+	content, err := os.ReadFile(finalized[0])
+	if err != nil {
+		t.Fatalf("TestFinalizeMigrationKeepsBackup: cannot read the backup of the finalization, err: %q", err)
+	}
+	legacyObjects, err := ReadLegacyObjects(scheme, content, finalized[0], Options{})
+	if err != nil || len(legacyObjects.AddressPoolList.Items) != len(validAddressPools0) {
+		t.Fatalf("TestFinalizeMigrationKeepsBackup: expected %d finalized AddressPools in the backup but got %+v, "+
+			"err: %v", len(validAddressPools0), legacyObjects, err)
+	}
+}
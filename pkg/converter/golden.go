@@ -0,0 +1,252 @@
+package converter
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// goldenContext is the number of unchanged lines around every hunk of a golden diff.
+const goldenContext = 3
+
+// checkGolden compares the files that a conversion printed into generatedDir with the committed files of goldenDir.
+// If they differ, it prints a unified diff to standard out and returns an error that wraps ErrGoldenMismatch.
+func checkGolden(generatedDir, goldenDir string) error {
+	diff, files, err := compareGolden(generatedDir, goldenDir, "")
+	if err != nil {
+		return err
+	}
+	if files == 0 {
+		if !quiet {
+			log.Printf("no differences to golden directory %s", goldenDir)
+		}
+		return nil
+	}
+	if _, err := fmt.Fprint(stdout, diff); err != nil {
+		return fmt.Errorf("cannot print golden diff, err: %w", err)
+	}
+	return fmt.Errorf("%w %s: %d files differ", ErrGoldenMismatch, goldenDir, files)
+}
+
+// compareGolden returns a unified diff of every file that differs between goldenDir and generatedDir, that is missing
+// in goldenDir or that only goldenDir holds, and the number of such files. The IncrementalCacheFileName is disregarded.
+// The files of goldenDir are named goldenLabel/<file> in the diff, or by their path if goldenLabel is empty.
+func compareGolden(generatedDir, goldenDir, goldenLabel string) (string, int, error) {
+	if info, err := os.Stat(goldenDir); err != nil || !info.IsDir() {
+		if err == nil {
+			err = fmt.Errorf("not a directory")
+		}
+		return "", 0, fmt.Errorf("cannot read golden directory %s, err: %w", goldenDir, err)
+	}
+	generated, err := listFiles(generatedDir)
+	if err != nil {
+		return "", 0, fmt.Errorf("cannot read generated outputs, err: %w", err)
+	}
+	golden, err := listFiles(goldenDir)
+	if err != nil {
+		return "", 0, fmt.Errorf("cannot read golden directory %s, err: %w", goldenDir, err)
+	}
+	names := map[string]bool{}
+	for _, name := range append(generated, golden...) {
+		if name != IncrementalCacheFileName {
+			names[name] = true
+		}
+	}
+	var sorted []string
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	var diff strings.Builder
+	files := 0
+	for _, name := range sorted {
+		goldenName, goldenContent, err := readGoldenFile(goldenDir, name)
+		if err != nil {
+			return "", 0, fmt.Errorf("cannot read golden directory %s, err: %w", goldenDir, err)
+		}
+		generatedName, generatedContent, err := readGoldenFile(generatedDir, name)
+		if err != nil {
+			return "", 0, fmt.Errorf("cannot read generated outputs, err: %w", err)
+		}
+		if goldenName != "" && generatedName != "" && bytes.Equal(goldenContent, generatedContent) {
+			continue
+		}
+		if goldenName == "" {
+			goldenName = "/dev/null"
+		} else if goldenLabel != "" {
+			goldenName = goldenLabel + "/" + name
+		}
+		if generatedName == "" {
+			generatedName = "/dev/null"
+		} else {
+			generatedName = "converted/" + name
+		}
+		diff.WriteString(unifiedDiff(goldenName, generatedName, goldenContent, generatedContent))
+		files++
+	}
+	return diff.String(), files, nil
+}
+
+// readGoldenFile returns the path and the content of the file name of dir, or "" and nil if it does not exist.
+func readGoldenFile(dir, name string) (string, []byte, error) {
+	p := filepath.Join(dir, filepath.FromSlash(name))
+	content, err := os.ReadFile(p)
+	if os.IsNotExist(err) {
+		return "", nil, nil
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	if content == nil {
+		content = []byte{}
+	}
+	return p, content, nil
+}
+
+// listFiles returns the slash separated paths of all files of dir and its subdirectories, relative to dir.
+func listFiles(dir string) ([]string, error) {
+	var names []string
+	err := filepath.WalkDir(dir, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		name, err := filepath.Rel(dir, p)
+		if err == nil {
+			names = append(names, filepath.ToSlash(name))
+		}
+		return err
+	})
+	return names, err
+}
+
+// diffLine is a line of a diff: unchanged (' '), only in the old content ('-') or only in the new content ('+').
+type diffLine struct {
+	op   byte
+	text string
+}
+
+// unifiedDiff returns the differences between the old content a and the new content b in the unified diff format,
+// with goldenContext lines of context around every hunk.
+func unifiedDiff(aName, bName string, a, b []byte) string {
+	lines := diffLines(splitLines(a), splitLines(b))
+	// aPos and bPos hold the number of lines of a and b before every line of the diff.
+	aPos, bPos := make([]int, len(lines)+1), make([]int, len(lines)+1)
+	for k, line := range lines {
+		aPos[k+1], bPos[k+1] = aPos[k], bPos[k]
+		if line.op != '+' {
+			aPos[k+1]++
+		}
+		if line.op != '-' {
+			bPos[k+1]++
+		}
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", aName, bName)
+	for k := 0; k < len(lines); {
+		if lines[k].op == ' ' {
+			k++
+			continue
+		}
+		start := k - goldenContext
+		if start < 0 {
+			start = 0
+		}
+		// Extend the hunk over all changes that are separated by at most twice the context.
+		end := k
+		for end < len(lines) {
+			if lines[end].op != ' ' {
+				end++
+				continue
+			}
+			unchanged := end
+			for unchanged < len(lines) && lines[unchanged].op == ' ' {
+				unchanged++
+			}
+			if unchanged == len(lines) || unchanged-end > 2*goldenContext {
+				end += goldenContext
+				if end > len(lines) {
+					end = len(lines)
+				}
+				break
+			}
+			end = unchanged
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(aPos[start], aPos[end]), hunkRange(bPos[start], bPos[end]))
+		for _, line := range lines[start:end] {
+			out.WriteByte(line.op)
+			out.WriteString(line.text)
+			if !strings.HasSuffix(line.text, "\n") {
+				out.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		k = end
+	}
+	return out.String()
+}
+
+// hunkRange returns the range of the lines from (excluding) to (including) of a hunk header.
+func hunkRange(from, to int) string {
+	if to == from {
+		return fmt.Sprintf("%d,0", from)
+	}
+	if to-from == 1 {
+		return fmt.Sprintf("%d", from+1)
+	}
+	return fmt.Sprintf("%d,%d", from+1, to-from)
+}
+
+// splitLines splits content into lines that keep their line breaks.
+func splitLines(content []byte) []string {
+	if len(content) == 0 {
+		return nil
+	}
+	lines := strings.SplitAfter(string(content), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines returns the lines of a shortest edit script from a to b, based on their longest common subsequence.
+func diffLines(a, b []string) []diffLine {
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	var lines []diffLine
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, diffLine{op: ' ', text: a[i]})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, diffLine{op: '-', text: a[i]})
+			i++
+		default:
+			lines = append(lines, diffLine{op: '+', text: b[j]})
+			j++
+		}
+	}
+	return lines
+}
@@ -0,0 +1,145 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	tcs := map[string]struct {
+		a        string
+		b        string
+		expected string
+	}{
+		"changed line": {
+			a:        "1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+			b:        "1\n2\n3\n4\nfive\n6\n7\n8\n9\n",
+			expected: "--- a\n+++ b\n@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n",
+		},
+		"separate hunks": {
+			a: "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+			b: "one\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\ntwelve\n",
+			expected: "--- a\n+++ b\n@@ -1,4 +1,4 @@\n-1\n+one\n 2\n 3\n 4\n" +
+				"@@ -9,4 +9,4 @@\n 9\n 10\n 11\n-12\n+twelve\n",
+		},
+		"new file": {
+			b:        "1\n2\n",
+			expected: "--- a\n+++ b\n@@ -0,0 +1,2 @@\n+1\n+2\n",
+		},
+		"removed file": {
+			a:        "1\n",
+			expected: "--- a\n+++ b\n@@ -1 +0,0 @@\n-1\n",
+		},
+		"missing newline": {
+			a:        "1\n",
+			b:        "1\n2",
+			expected: "--- a\n+++ b\n@@ -1 +1,2 @@\n 1\n+2\n\\ No newline at end of file\n",
+		},
+	}
+	for desc, tc := range tcs {
+		diff := unifiedDiff("a", "b", []byte(tc.a), []byte(tc.b))
+		if diff != tc.expected {
+			t.Fatalf("TestUnifiedDiff(%s): expected diff\n%s\nbut got\n%s", desc, tc.expected, diff)
+		}
+	}
+}
+
+func TestGoldenOfflineMigration(t *testing.T) {
+	var scheme = runtime.NewScheme()
+	if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestGoldenOfflineMigration: error adding to scheme, err: %q", err)
+	}
+	inDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(inDir, "pools.yaml"), []byte(renderedKustomization), 0644); err != nil {
+		t.Fatalf("TestGoldenOfflineMigration: cannot write input, err: %q", err)
+	}
+	goldenDir := t.TempDir()
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+	err := OfflineMigration(context.TODO(), c, scheme, inDir, goldenDir, OutputFormatYAML, OfflineMigrationOptions{})
+	if err != nil {
+		t.Fatalf("TestGoldenOfflineMigration: cannot write golden directory, err: %q", err)
+	}
+
+	tcs := []struct {
+		desc                string
+		modify              func(dir string) error
+		expectedDiff        []string
+		expectedErrorString string
+	}{
+		{
+			desc:   "up to date",
+			modify: func(dir string) error { return nil },
+		},
+		{
+			desc: "changed file",
+			modify: func(dir string) error {
+				p := filepath.Join(dir, "IPAddressPool.yaml")
+				content, err := os.ReadFile(p)
+				if err != nil {
+					return err
+				}
+				return os.WriteFile(p, bytes.Replace(content, []byte("192.168.10.0/24"), []byte("10.0.0.0/24"), 1),
+					0644)
+			},
+			expectedDiff: []string{
+				"--- " + filepath.Join(goldenDir, "IPAddressPool.yaml") + "\n+++ converted/IPAddressPool.yaml\n",
+				"-  - 10.0.0.0/24\n+  - 192.168.10.0/24\n",
+			},
+			expectedErrorString: goldenDir + ": 1 files differ",
+		},
+		{
+			desc: "missing, stale and still changed files",
+			modify: func(dir string) error {
+				if err := os.Remove(filepath.Join(dir, "L2Advertisement.yaml")); err != nil {
+					return err
+				}
+				return os.WriteFile(filepath.Join(dir, "BGPAdvertisement.yaml"), []byte("stale\n"), 0644)
+			},
+			expectedDiff: []string{
+				"+++ /dev/null\n@@ -1 +0,0 @@\n-stale\n",
+				"--- /dev/null\n+++ converted/L2Advertisement.yaml\n@@ -0,0 +1,",
+			},
+			expectedErrorString: goldenDir + ": 3 files differ",
+		},
+	}
+	for _, tc := range tcs {
+		if err := tc.modify(goldenDir); err != nil {
+			t.Fatalf("TestGoldenOfflineMigration(%s): cannot modify golden directory, err: %q", tc.desc, err)
+		}
+		stdout = bytes.NewBuffer([]byte{})
+		err := OfflineMigration(context.TODO(), c, scheme, inDir, "", OutputFormatYAML,
+			OfflineMigrationOptions{Golden: goldenDir})
+		diff := stdout.(*bytes.Buffer).String()
+		if tc.expectedErrorString == "" {
+			if err != nil || diff != "" {
+				t.Fatalf("TestGoldenOfflineMigration(%s): expected no differences but got err %v and diff:\n%s",
+					tc.desc, err, diff)
+			}
+			continue
+		}
+		if !errors.Is(err, ErrGoldenMismatch) || !strings.Contains(err.Error(), tc.expectedErrorString) {
+			t.Fatalf("TestGoldenOfflineMigration(%s): expected error %q but got %v", tc.desc,
+				tc.expectedErrorString, err)
+		}
+		for _, expected := range tc.expectedDiff {
+			if !strings.Contains(diff, expected) {
+				t.Fatalf("TestGoldenOfflineMigration(%s): expected %q in diff but got:\n%s", tc.desc, expected, diff)
+			}
+		}
+	}
+
+	err = OfflineMigration(context.TODO(), c, scheme, inDir, "", OutputFormatYAML,
+		OfflineMigrationOptions{Golden: filepath.Join(goldenDir, "missing")})
+	if err == nil || !strings.Contains(err.Error(), "cannot read golden directory") {
+		t.Fatalf("TestGoldenOfflineMigration: expected an error for a missing golden directory but got %v", err)
+	}
+}
@@ -0,0 +1,169 @@
+package converter
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// ServiceAddressPoolAnnotation is the annotation that pins a Service to a specific address pool.
+	ServiceAddressPoolAnnotation = "metallb.universe.tf/address-pool"
+	// allPeersNode is the node that BGPAdvertisements without an explicit peer list point to.
+	allPeersNode = "all BGPPeers"
+)
+
+// graphNode is a single vertex of the relationship graph.
+type graphNode struct {
+	id    string
+	label string
+	kind  string
+}
+
+// graphEdge is a directed edge between two graphNode ids.
+type graphEdge struct {
+	from string
+	to   string
+}
+
+// graph holds the relationships between pools, advertisements, peers, communities and services. Nodes and edges are
+// kept in insertion order so that the rendered output is deterministic.
+type graph struct {
+	nodes     []graphNode
+	edges     []graphEdge
+	nodeIndex map[string]struct{}
+	edgeIndex map[graphEdge]struct{}
+}
+
+func newGraph() *graph {
+	return &graph{
+		nodeIndex: map[string]struct{}{},
+		edgeIndex: map[graphEdge]struct{}{},
+	}
+}
+
+// addNode adds a node of the given kind and label and returns its id. Adding the same node twice is a no-op.
+func (g *graph) addNode(kind, label string) string {
+	id := graphNodeID(kind, label)
+	if _, ok := g.nodeIndex[id]; ok {
+		return id
+	}
+	g.nodeIndex[id] = struct{}{}
+	g.nodes = append(g.nodes, graphNode{id: id, label: label, kind: kind})
+	return id
+}
+
+// addEdge adds a directed edge. Adding the same edge twice is a no-op.
+func (g *graph) addEdge(from, to string) {
+	e := graphEdge{from: from, to: to}
+	if _, ok := g.edgeIndex[e]; ok {
+		return
+	}
+	g.edgeIndex[e] = struct{}{}
+	g.edges = append(g.edges, e)
+}
+
+// graphNodeID builds an identifier that is valid in both DOT and mermaid syntax.
+func graphNodeID(kind, label string) string {
+	var sb strings.Builder
+	for _, r := range kind + "_" + label {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			sb.WriteRune(r)
+			continue
+		}
+		sb.WriteRune('_')
+	}
+	return sb.String()
+}
+
+// buildGraph builds the relationship graph for the provided CurrentObjects. Services that are pinned to a pool via
+// ServiceAddressPoolAnnotation are linked to that pool.
+func buildGraph(objects *CurrentObjects, services []corev1.Service) *graph {
+	g := newGraph()
+	pools := map[string]string{}
+	for _, iap := range objects.IPAddressPoolList.Items {
+		pools[iap.Name] = g.addNode("IPAddressPool", fmt.Sprintf("%s/%s", iap.Namespace, iap.Name))
+	}
+	for _, l2a := range objects.L2AdvertisementList.Items {
+		l2aID := g.addNode("L2Advertisement", fmt.Sprintf("%s/%s", l2a.Namespace, l2a.Name))
+		for _, pool := range l2a.Spec.IPAddressPools {
+			if poolID, ok := pools[pool]; ok {
+				g.addEdge(poolID, l2aID)
+			}
+		}
+	}
+	for _, ba := range objects.BGPAdvertisementList.Items {
+		baID := g.addNode("BGPAdvertisement", fmt.Sprintf("%s/%s", ba.Namespace, ba.Name))
+		for _, pool := range ba.Spec.IPAddressPools {
+			if poolID, ok := pools[pool]; ok {
+				g.addEdge(poolID, baID)
+			}
+		}
+		if len(ba.Spec.Peers) == 0 {
+			g.addEdge(baID, g.addNode("BGPPeer", allPeersNode))
+		}
+		for _, peer := range ba.Spec.Peers {
+			g.addEdge(baID, g.addNode("BGPPeer", peer))
+		}
+		for _, community := range ba.Spec.Communities {
+			g.addEdge(baID, g.addNode("Community", community))
+		}
+	}
+	for _, svc := range services {
+		pool, ok := svc.Annotations[ServiceAddressPoolAnnotation]
+		if !ok {
+			continue
+		}
+		poolID, ok := pools[pool]
+		if !ok {
+			continue
+		}
+		g.addEdge(g.addNode("Service", fmt.Sprintf("%s/%s", svc.Namespace, svc.Name)), poolID)
+	}
+	return g
+}
+
+// writeDot renders the graph in graphviz DOT format.
+func (g *graph) writeDot(w io.Writer) {
+	fmt.Fprintln(w, "digraph metallb {")
+	fmt.Fprintln(w, "  rankdir=LR;")
+	for _, n := range g.nodes {
+		fmt.Fprintf(w, "  %s [label=%q, shape=box];\n", n.id, fmt.Sprintf("%s\n%s", n.kind, n.label))
+	}
+	for _, e := range g.edges {
+		fmt.Fprintf(w, "  %s -> %s;\n", e.from, e.to)
+	}
+	fmt.Fprintln(w, "}")
+}
+
+// writeMermaid renders the graph as a mermaid flowchart.
+func (g *graph) writeMermaid(w io.Writer) {
+	fmt.Fprintln(w, "flowchart LR")
+	for _, n := range g.nodes {
+		fmt.Fprintf(w, "  %s[\"%s<br/>%s\"]\n", n.id, n.kind, strings.ReplaceAll(n.label, `"`, "#quot;"))
+	}
+	for _, e := range g.edges {
+		fmt.Fprintf(w, "  %s --> %s\n", e.from, e.to)
+	}
+}
+
+// PrintGraph renders the relationship graph of the objects in DOT or mermaid format (depending on outputFormat) either
+// to the targetDirectory or to stdout if targetDirectory == "".
+func (c CurrentObjects) PrintGraph(targetDirectory string, outputFormat string, services []corev1.Service) error {
+	g := buildGraph(&c, services)
+	fileName := "graph.dot"
+	write := g.writeDot
+	if outputFormat == OutputFormatMermaid {
+		fileName = "graph.mmd"
+		write = g.writeMermaid
+	}
+	outWriter, closeFn, err := outputWriter(targetDirectory, fileName)
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+	write(outWriter)
+	return nil
+}
@@ -0,0 +1,105 @@
+package converter
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var validAddressPools1Dot = `digraph metallb {
+  rankdir=LR;
+  IPAddressPool_metallb_system_ap_bgp [label="IPAddressPool\nmetallb-system/ap-bgp", shape=box];
+  BGPAdvertisement_metallb_system_ap_bgp_bgp_advertisement_0 [label="BGPAdvertisement\nmetallb-system/ap-bgp-bgp-advertisement-0", shape=box];
+  BGPPeer_all_BGPPeers [label="BGPPeer\nall BGPPeers", shape=box];
+  Service_default_svc [label="Service\ndefault/svc", shape=box];
+  IPAddressPool_metallb_system_ap_bgp -> BGPAdvertisement_metallb_system_ap_bgp_bgp_advertisement_0;
+  BGPAdvertisement_metallb_system_ap_bgp_bgp_advertisement_0 -> BGPPeer_all_BGPPeers;
+  Service_default_svc -> IPAddressPool_metallb_system_ap_bgp;
+}
+`
+
+var validAddressPools1Mermaid = `flowchart LR
+  IPAddressPool_metallb_system_ap_bgp["IPAddressPool<br/>metallb-system/ap-bgp"]
+  BGPAdvertisement_metallb_system_ap_bgp_bgp_advertisement_0["BGPAdvertisement<br/>metallb-system/ap-bgp-bgp-advertisement-0"]
+  BGPPeer_all_BGPPeers["BGPPeer<br/>all BGPPeers"]
+  Service_default_svc["Service<br/>default/svc"]
+  IPAddressPool_metallb_system_ap_bgp --> BGPAdvertisement_metallb_system_ap_bgp_bgp_advertisement_0
+  BGPAdvertisement_metallb_system_ap_bgp_bgp_advertisement_0 --> BGPPeer_all_BGPPeers
+  Service_default_svc --> IPAddressPool_metallb_system_ap_bgp
+`
+
+var annotatedServices = []corev1.Service{
+	{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "svc",
+			Namespace:   "default",
+			Annotations: map[string]string{ServiceAddressPoolAnnotation: "ap-bgp"},
+		},
+	},
+	{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "svc-unknown-pool",
+			Namespace:   "default",
+			Annotations: map[string]string{ServiceAddressPoolAnnotation: "does-not-exist"},
+		},
+	},
+}
+
+func TestPrintGraph(t *testing.T) {
+	tcs := map[string]struct {
+		addressPools   []metallbv1beta1.AddressPool
+		services       []corev1.Service
+		outputFormat   string
+		expectedOutput string
+	}{
+		"dot": {
+			addressPools:   validAddressPools1,
+			services:       annotatedServices,
+			outputFormat:   OutputFormatDot,
+			expectedOutput: validAddressPools1Dot,
+		},
+		"mermaid": {
+			addressPools:   validAddressPools1,
+			services:       annotatedServices,
+			outputFormat:   OutputFormatMermaid,
+			expectedOutput: validAddressPools1Mermaid,
+		},
+	}
+	for desc, tc := range tcs {
+		stdout = bytes.NewBuffer([]byte{})
+		legacyObjects := &LegacyObjects{AddressPoolList: &metallbv1beta1.AddressPoolList{Items: tc.addressPools}}
+		currentObjects, err := legacyObjects.Convert(Options{})
+		if err != nil {
+			t.Fatalf("TestPrintGraph(%s): unexpected error during conversion, err: %q", desc, err)
+		}
+		err = currentObjects.PrintGraph("", tc.outputFormat, tc.services)
+		if err != nil {
+			t.Fatalf("TestPrintGraph(%s): unexpected error during print, err: %q", desc, err)
+		}
+		if fmt.Sprint(stdout) != tc.expectedOutput {
+			t.Fatalf("TestPrintGraph(%s): Generated output does not match expected output.\nGenerated output:\n===\n```%s```\n\nExpected output:\n===\n```%s```",
+				desc, stdout, tc.expectedOutput)
+		}
+	}
+}
+
+func TestBuildGraphCommunities(t *testing.T) {
+	legacyObjects := &LegacyObjects{AddressPoolList: &metallbv1beta1.AddressPoolList{Items: validAddressPools0}}
+	currentObjects, err := legacyObjects.Convert(Options{})
+	if err != nil {
+		t.Fatalf("TestBuildGraphCommunities: unexpected error during conversion, err: %q", err)
+	}
+	g := buildGraph(currentObjects, nil)
+	// 3 pools, 1 L2 advertisement, 3 BGP advertisements, 1 peer node and 2 communities.
+	if len(g.nodes) != 10 {
+		t.Fatalf("TestBuildGraphCommunities: expected 10 nodes but got %d: %v", len(g.nodes), g.nodes)
+	}
+	// 4 pool -> advertisement edges, 3 advertisement -> peer edges and 2 advertisement -> community edges.
+	if len(g.edges) != 9 {
+		t.Fatalf("TestBuildGraphCommunities: expected 9 edges but got %d: %v", len(g.edges), g.edges)
+	}
+}
@@ -0,0 +1,71 @@
+package converter
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	// OutputGroupByKind writes one file per kind, e.g. IPAddressPool.yaml and BGPAdvertisement.yaml.
+	OutputGroupByKind = "kind"
+	// OutputGroupByPool writes one file per IPAddressPool, named <namespace>_<name>, that holds the IPAddressPool and
+	// all advertisements that reference it.
+	OutputGroupByPool = "pool"
+)
+
+// SupportedOutputGroupings lists all groupings that can be set as Options.OutputGroupBy.
+var SupportedOutputGroupings = []string{OutputGroupByKind, OutputGroupByPool}
+
+// outputGroup holds the objects of one output file, without file extension.
+type outputGroup struct {
+	name    string
+	objects []runtime.Object
+}
+
+// runtimeObjectsByPool returns one group per IPAddressPool, in order, with the IPAddressPool followed by its
+// L2Advertisements and BGPAdvertisements. Advertisements that reference several IPAddressPools belong to the group of
+// the first one. Objects that belong to no IPAddressPool, e.g. Communities, are grouped by kind after the pools.
+func (objects CurrentObjects) runtimeObjectsByPool() []outputGroup {
+	var groups []outputGroup
+	index := map[string]int{}
+	for i := range objects.IPAddressPoolList.Items {
+		iap := &objects.IPAddressPoolList.Items[i]
+		key := fmt.Sprintf("%s/%s", iap.Namespace, iap.Name)
+		index[key] = len(groups)
+		groups = append(groups, outputGroup{
+			name:    fmt.Sprintf("%s_%s", iap.Namespace, iap.Name),
+			objects: []runtime.Object{iap},
+		})
+	}
+	remaining := map[string][]runtime.Object{}
+	var kinds []string
+	add := func(kind, namespace string, pools []string, obj runtime.Object) {
+		if len(pools) > 0 {
+			if i, ok := index[fmt.Sprintf("%s/%s", namespace, pools[0])]; ok {
+				groups[i].objects = append(groups[i].objects, obj)
+				return
+			}
+		}
+		if _, ok := remaining[kind]; !ok {
+			kinds = append(kinds, kind)
+		}
+		remaining[kind] = append(remaining[kind], obj)
+	}
+	for i := range objects.L2AdvertisementList.Items {
+		l2a := &objects.L2AdvertisementList.Items[i]
+		add("L2Advertisement", l2a.Namespace, l2a.Spec.IPAddressPools, l2a)
+	}
+	for i := range objects.BGPAdvertisementList.Items {
+		ba := &objects.BGPAdvertisementList.Items[i]
+		add("BGPAdvertisement", ba.Namespace, ba.Spec.IPAddressPools, ba)
+	}
+	for i := range objects.CommunityList.Items {
+		community := &objects.CommunityList.Items[i]
+		add("Community", community.Namespace, nil, community)
+	}
+	for _, kind := range kinds {
+		groups = append(groups, outputGroup{name: kind, objects: remaining[kind]})
+	}
+	return groups
+}
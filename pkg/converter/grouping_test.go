@@ -0,0 +1,106 @@
+package converter
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestOutputGroupBy(t *testing.T) {
+	pools := []metallbv1beta1.AddressPool{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "l2", Namespace: "metallb-system"},
+			Spec:       metallbv1beta1.AddressPoolSpec{Protocol: ProtocolLayer2, Addresses: []string{"10.0.0.0/24"}},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "bgp", Namespace: "metallb-system"},
+			Spec: metallbv1beta1.AddressPoolSpec{
+				Protocol:  ProtocolBGP,
+				Addresses: []string{"10.0.1.0/24"},
+				BGPAdvertisements: []metallbv1beta1.LegacyBgpAdvertisement{
+					{LocalPref: 100}, {Communities: []string{"65535:65282"}},
+				},
+			},
+		},
+	}
+	tcs := map[string]struct {
+		grouping            string
+		expectedFiles       map[string][]string
+		expectedErrorString string
+	}{
+		"kind": {
+			grouping: OutputGroupByKind,
+			expectedFiles: map[string][]string{
+				"IPAddressPool.yaml":    {"name: l2\n", "name: bgp\n"},
+				"L2Advertisement.yaml":  {"name: l2-l2-advertisement\n"},
+				"BGPAdvertisement.yaml": {"name: bgp-bgp-advertisement-0\n", "name: bgp-bgp-advertisement-1\n"},
+			},
+		},
+		"pool": {
+			grouping: OutputGroupByPool,
+			expectedFiles: map[string][]string{
+				"metallb-system_l2.yaml": {"kind: IPAddressPool\n", "kind: L2Advertisement\n"},
+				"metallb-system_bgp.yaml": {
+					"kind: IPAddressPool\n", "name: bgp-bgp-advertisement-0\n", "name: bgp-bgp-advertisement-1\n",
+				},
+			},
+		},
+		"unsupported": {
+			grouping:            "namespace",
+			expectedErrorString: "unsupported output grouping \"namespace\", must be one of: kind, pool",
+		},
+	}
+	for desc, tc := range tcs {
+		opts := Options{OutputGroupBy: tc.grouping}
+		err := opts.Validate()
+		if tc.expectedErrorString != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.expectedErrorString) {
+				t.Fatalf("TestOutputGroupBy(%s): expected error %q but got %v", desc, tc.expectedErrorString, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("TestOutputGroupBy(%s): unexpected error, err: %q", desc, err)
+		}
+		l := &LegacyObjects{AddressPoolList: &metallbv1beta1.AddressPoolList{Items: pools}}
+		c, err := l.Convert(opts)
+		if err != nil {
+			t.Fatalf("TestOutputGroupBy(%s): unexpected error, err: %q", desc, err)
+		}
+		dir := t.TempDir()
+		if err := c.Print(dir, false, opts); err != nil {
+			t.Fatalf("TestOutputGroupBy(%s): unexpected error, err: %q", desc, err)
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatalf("TestOutputGroupBy(%s): unexpected error, err: %q", desc, err)
+		}
+		files := map[string]bool{}
+		for _, entry := range entries {
+			files[entry.Name()] = true
+		}
+		expectedFiles := map[string]bool{}
+		for name := range tc.expectedFiles {
+			expectedFiles[name] = true
+		}
+		if !reflect.DeepEqual(files, expectedFiles) {
+			t.Fatalf("TestOutputGroupBy(%s): expected files %v but got %v", desc, expectedFiles, files)
+		}
+		for name, expectedContents := range tc.expectedFiles {
+			content, err := os.ReadFile(filepath.Join(dir, name))
+			if err != nil {
+				t.Fatalf("TestOutputGroupBy(%s): unexpected error, err: %q", desc, err)
+			}
+			for _, expected := range expectedContents {
+				if !strings.Contains(string(content), expected) {
+					t.Fatalf("TestOutputGroupBy(%s): expected %q in %s but got:\n%s", desc, expected, name, content)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package converter
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// DefaultHelmCommand renders a Helm chart locally. The chart and the values files are appended as arguments.
+var DefaultHelmCommand = []string{"helm", "template"}
+
+// RenderHelmChart runs command with chart and a -f argument for every values file appended, and returns the
+// documents of its standard output that are MetalLB objects, in metallb.io or one of the API groups of opts. All other
+// resources of the chart are dropped.
+func RenderHelmChart(command []string, chart string, valuesFiles []string, opts Options) ([]byte, error) {
+	args := []string{chart}
+	for _, valuesFile := range valuesFiles {
+		args = append(args, "-f", valuesFile)
+	}
+	rendered, err := render(fmt.Sprintf("Helm chart %q", chart), command, args...)
+	if err != nil {
+		return nil, err
+	}
+	return filterMetalLBDocuments(rendered, "Helm chart "+chart, opts)
+}
+
+// filterMetalLBDocuments returns the YAML documents of content whose API group is a MetalLB API group of opts,
+// separated by "---".
+func filterMetalLBDocuments(content []byte, source string, opts Options) ([]byte, error) {
+	var filtered [][]byte
+	var dropped int
+	for _, document := range bytes.Split(content, []byte("\n---")) {
+		if len(bytes.TrimSpace(document)) == 0 {
+			continue
+		}
+		typeMeta := metav1.TypeMeta{}
+		if err := yaml.Unmarshal(document, &typeMeta); err != nil {
+			return nil, fmt.Errorf("invalid document in %s, err: %w", source, err)
+		}
+		group, _, _ := strings.Cut(typeMeta.APIVersion, "/")
+		if !opts.isMetalLBAPIGroup(group) {
+			if typeMeta.Kind != "" {
+				dropped++
+			}
+			continue
+		}
+		filtered = append(filtered, document)
+	}
+	if len(filtered) == 0 && !quiet {
+		log.Printf("warning: %s rendered no legacy MetalLB objects", source)
+	} else if dropped > 0 && !quiet {
+		log.Printf("ignoring %d resources of %s that are not MetalLB objects", dropped, source)
+	}
+	return bytes.Join(filtered, []byte("\n---")), nil
+}
@@ -0,0 +1,88 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const renderedHelmChart = `---
+# Source: network/templates/serviceaccount.yaml
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: network
+---
+# Source: network/templates/pools.yaml
+apiVersion: metallb.io/v1beta1
+kind: AddressPool
+metadata:
+  name: chart-pool
+  namespace: metallb-system
+spec:
+  protocol: bgp
+  addresses:
+  - 10.10.0.0/24
+`
+
+func TestOfflineMigrationHelm(t *testing.T) {
+	var scheme = runtime.NewScheme()
+	if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestOfflineMigrationHelm: error adding to scheme, err: %q", err)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+	chart := t.TempDir()
+	if err := os.WriteFile(filepath.Join(chart, "rendered.txt"), []byte(renderedHelmChart), 0644); err != nil {
+		t.Fatalf("TestOfflineMigrationHelm: cannot write chart, err: %q", err)
+	}
+
+	tcs := map[string]struct {
+		command             []string
+		values              []string
+		expectedOutput      string
+		expectedErrorString string
+	}{
+		"rendered": {
+			command:        []string{"sh", "-c", `cat "$1/rendered.txt"`, "sh"},
+			expectedOutput: "IPAddressPool/metallb-system/chart-pool",
+		},
+		"values files are passed": {
+			command:             []string{"sh", "-c", `echo "$@" >&2; exit 1`, "sh"},
+			values:              []string{"a.yaml", "b.yaml"},
+			expectedErrorString: fmt.Sprintf(`stderr: "%s -f a.yaml -f b.yaml"`, chart),
+		},
+		"no MetalLB objects": {
+			command: []string{"sh", "-c", "echo 'apiVersion: v1\nkind: ServiceAccount'", "sh"},
+		},
+	}
+	for desc, tc := range tcs {
+		stdout = bytes.NewBuffer([]byte{})
+		err := OfflineMigration(context.TODO(), c, scheme, "", "", OutputFormatName, OfflineMigrationOptions{
+			HelmChart:   chart,
+			HelmValues:  tc.values,
+			HelmCommand: tc.command,
+		})
+		if tc.expectedErrorString != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.expectedErrorString) {
+				t.Fatalf("TestOfflineMigrationHelm(%s): expected error %q but got %v", desc, tc.expectedErrorString,
+					err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("TestOfflineMigrationHelm(%s): unexpected error, err: %q", desc, err)
+		}
+		if !strings.Contains(fmt.Sprint(stdout), tc.expectedOutput) {
+			t.Fatalf("TestOfflineMigrationHelm(%s): expected %q in output but got:\n%s", desc, tc.expectedOutput,
+				stdout)
+		}
+	}
+}
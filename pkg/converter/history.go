@@ -0,0 +1,265 @@
+package converter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+const (
+	// HistoryConfigMapName is the name of the ConfigMap that holds the migration history.
+	HistoryConfigMapName = "metallb-converter-history"
+	// HistoryConfigMapKey is the key inside the history ConfigMap that holds the JSON encoded list of entries.
+	HistoryConfigMapKey = "history.json"
+	// HistoryMaxEntries is the maximum number of entries of the history ConfigMap. The oldest entries are dropped,
+	// except for the most recent completed full migration, which is always kept.
+	HistoryMaxEntries = 100
+
+	// HistoryModeOnlineMigration marks history entries of online migrations.
+	HistoryModeOnlineMigration = "online-migration"
+	// HistoryModeNonDestructiveMigration marks history entries of online migrations that kept the legacy objects.
+	// Such runs can be repeated safely and never count as a completed full migration.
+	HistoryModeNonDestructiveMigration = "non-destructive-migration"
+	// HistoryModePartialMigration marks history entries of online migrations that were restricted to a namespace or
+	// to the AddressPools that match a selector. They never count as a completed full migration, so that the other
+	// namespaces or AddressPools can be migrated afterwards.
+	HistoryModePartialMigration = "partial-migration"
+	// HistoryModeFinalize marks history entries of runs that finalized a two-phase migration.
+	HistoryModeFinalize = "finalize"
+	// HistoryResultSuccess marks history entries of runs that completed without error.
+	HistoryResultSuccess = "success"
+	// HistoryResultFailure marks history entries of runs that failed.
+	HistoryResultFailure = "failure"
+)
+
+// HistoryEntry describes a single converter run.
+type HistoryEntry struct {
+	Timestamp time.Time         `json:"timestamp"`
+	Version   string            `json:"version"`
+	Mode      string            `json:"mode"`
+	Flags     map[string]string `json:"flags,omitempty"`
+	Counts    map[string]int    `json:"counts,omitempty"`
+	Result    string            `json:"result"`
+	Error     string            `json:"error,omitempty"`
+	ErrorCode ErrorCode         `json:"errorCode,omitempty"`
+	BackupDir string            `json:"backupDir,omitempty"`
+}
+
+// IsCompletedFullMigration returns true if the entry describes a successful online migration of all AddressPools.
+func (e HistoryEntry) IsCompletedFullMigration() bool {
+	return e.Mode == HistoryModeOnlineMigration && e.Result == HistoryResultSuccess
+}
+
+// MigrationHistoryMode returns the mode of the history entry of an online migration with opts.
+func MigrationHistoryMode(opts OnlineMigrationOptions) string {
+	switch {
+	case opts.KeepLegacy:
+		return HistoryModeNonDestructiveMigration
+	case opts.Namespace != "" || opts.Selector != nil:
+		return HistoryModePartialMigration
+	}
+	return HistoryModeOnlineMigration
+}
+
+// HistoryRecorder counts the API mutations of a run and persists a HistoryEntry per run in the history ConfigMap.
+type HistoryRecorder struct {
+	mu        sync.Mutex
+	counts    map[string]int
+	configMap types.NamespacedName
+}
+
+// NewHistoryRecorder returns a HistoryRecorder that stores its entries in the HistoryConfigMapName ConfigMap inside
+// namespace.
+func NewHistoryRecorder(namespace string) *HistoryRecorder {
+	return &HistoryRecorder{
+		counts:    map[string]int{},
+		configMap: types.NamespacedName{Namespace: namespace, Name: HistoryConfigMapName},
+	}
+}
+
+// WrapClient returns a client that counts every successful Create, Delete, Update and Patch call per kind.
+func (h *HistoryRecorder) WrapClient(c client.Client) client.Client {
+	return &countingClient{Client: c, history: h}
+}
+
+// Entries returns all entries of the history ConfigMap. If the ConfigMap does not exist, no entries are returned.
+func (h *HistoryRecorder) Entries(ctx context.Context, c client.Client) ([]HistoryEntry, error) {
+	cm := &corev1.ConfigMap{}
+	err := c.Get(ctx, h.configMap, cm)
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot get history ConfigMap %s, err: %w", h.configMap, err)
+	}
+	return decodeHistory(cm)
+}
+
+// LastCompletedFullMigration returns the most recent completed full migration from the history or nil if there is
+// none.
+func (h *HistoryRecorder) LastCompletedFullMigration(ctx context.Context, c client.Client) (*HistoryEntry, error) {
+	entries, err := h.Entries(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].IsCompletedFullMigration() {
+			return &entries[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// Record fills in the mutation counts and timestamp of entry and appends it to the history ConfigMap, creating the
+// ConfigMap if needed. Updates that conflict with concurrent runs are retried and the history is bounded to
+// HistoryMaxEntries, see trimHistory. Use an unwrapped client so that the ConfigMap update itself is not counted.
+func (h *HistoryRecorder) Record(ctx context.Context, c client.Client, entry HistoryEntry) error {
+	h.mu.Lock()
+	entry.Counts = map[string]int{}
+	for k, v := range h.counts {
+		entry.Counts[k] = v
+	}
+	h.mu.Unlock()
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now().UTC()
+	}
+	return retryOnConflict(ctx, func() error {
+		return h.appendEntry(ctx, c, entry)
+	})
+}
+
+// appendEntry appends entry to the history ConfigMap, creating it if needed.
+func (h *HistoryRecorder) appendEntry(ctx context.Context, c client.Client, entry HistoryEntry) error {
+	cm := &corev1.ConfigMap{}
+	err := c.Get(ctx, h.configMap, cm)
+	if apierrors.IsNotFound(err) {
+		data, err := json.MarshalIndent([]HistoryEntry{entry}, "", "  ")
+		if err != nil {
+			return fmt.Errorf("cannot marshal history, err: %w", err)
+		}
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: h.configMap.Name, Namespace: h.configMap.Namespace},
+			Data:       map[string]string{HistoryConfigMapKey: string(data)},
+		}
+		err = c.Create(ctx, cm)
+		if !apierrors.IsAlreadyExists(err) {
+			if err != nil {
+				return fmt.Errorf("cannot create history ConfigMap %s, err: %w", h.configMap, err)
+			}
+			return nil
+		}
+		// A concurrent run created the ConfigMap in the meantime.
+		cm = &corev1.ConfigMap{}
+		err = c.Get(ctx, h.configMap, cm)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot get history ConfigMap %s, err: %w", h.configMap, err)
+	}
+	entries, err := decodeHistory(cm)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(trimHistory(append(entries, entry), HistoryMaxEntries), "", "  ")
+	if err != nil {
+		return fmt.Errorf("cannot marshal history, err: %w", err)
+	}
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	cm.Data[HistoryConfigMapKey] = string(data)
+	if err := c.Update(ctx, cm); err != nil {
+		return fmt.Errorf("cannot update history ConfigMap %s, err: %w", h.configMap, err)
+	}
+	return nil
+}
+
+// trimHistory drops the oldest entries until at most maxEntries are left. The most recent completed full migration is
+// kept in any case, so that it still prevents another full migration.
+func trimHistory(entries []HistoryEntry, maxEntries int) []HistoryEntry {
+	if len(entries) <= maxEntries {
+		return entries
+	}
+	kept := entries[len(entries)-maxEntries:]
+	for i := len(entries) - 1; i >= 0; i-- {
+		if !entries[i].IsCompletedFullMigration() {
+			continue
+		}
+		if i < len(entries)-maxEntries {
+			kept = append([]HistoryEntry{entries[i]}, entries[len(entries)-maxEntries+1:]...)
+		}
+		break
+	}
+	return kept
+}
+
+// decodeHistory decodes the history entries stored in cm.
+func decodeHistory(cm *corev1.ConfigMap) ([]HistoryEntry, error) {
+	data, ok := cm.Data[HistoryConfigMapKey]
+	if !ok || data == "" {
+		return nil, nil
+	}
+	var entries []HistoryEntry
+	if err := json.Unmarshal([]byte(data), &entries); err != nil {
+		return nil, fmt.Errorf("cannot decode history ConfigMap %s/%s, err: %w", cm.Namespace, cm.Name, err)
+	}
+	return entries, nil
+}
+
+// count increments the counter for operation on the kind of obj.
+func (h *HistoryRecorder) count(c client.Client, operation string, obj client.Object) {
+	kind := obj.GetObjectKind().GroupVersionKind().Kind
+	if gvk, err := apiutil.GVKForObject(obj, c.Scheme()); err == nil {
+		kind = gvk.Kind
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.counts[fmt.Sprintf("%s/%s", operation, kind)]++
+}
+
+// countingClient counts successful Create, Delete, Update and Patch calls in a HistoryRecorder.
+type countingClient struct {
+	client.Client
+	history *HistoryRecorder
+}
+
+func (cc *countingClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	err := cc.Client.Create(ctx, obj, opts...)
+	if err == nil {
+		cc.history.count(cc.Client, auditOperationCreate, obj)
+	}
+	return err
+}
+
+func (cc *countingClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	err := cc.Client.Delete(ctx, obj, opts...)
+	if err == nil {
+		cc.history.count(cc.Client, auditOperationDelete, obj)
+	}
+	return err
+}
+
+func (cc *countingClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	err := cc.Client.Update(ctx, obj, opts...)
+	if err == nil {
+		cc.history.count(cc.Client, auditOperationUpdate, obj)
+	}
+	return err
+}
+
+func (cc *countingClient) Patch(ctx context.Context, obj client.Object, patch client.Patch,
+	opts ...client.PatchOption) error {
+	err := cc.Client.Patch(ctx, obj, patch, opts...)
+	if err == nil {
+		cc.history.count(cc.Client, auditOperationPatch, obj)
+	}
+	return err
+}
@@ -0,0 +1,257 @@
+package converter
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestHistoryRecorder(t *testing.T) {
+	var scheme = runtime.NewScheme()
+	if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestHistoryRecorder: error adding to scheme, err: %q", err)
+	}
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestHistoryRecorder: error adding to scheme, err: %q", err)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+	for _, ap := range validAddressPools0 {
+		if err := c.Create(context.TODO(), ap.DeepCopy()); err != nil {
+			t.Fatalf("TestHistoryRecorder: cannot create AddressPool, err: %q", err)
+		}
+	}
+
+	// A fresh cluster has no history.
+	history := NewHistoryRecorder("metallb-system")
+	previous, err := history.LastCompletedFullMigration(context.TODO(), c)
+	if err != nil || previous != nil {
+		t.Fatalf("TestHistoryRecorder: expected no previous migration, got %+v, err: %q", previous, err)
+	}
+
+	// Record a failed run first, it must not count as a completed migration.
+	err = history.Record(context.TODO(), c, HistoryEntry{
+		Version: "v1", Mode: HistoryModeOnlineMigration, Result: HistoryResultFailure, Error: "boom",
+	})
+	if err != nil {
+		t.Fatalf("TestHistoryRecorder: cannot record history, err: %q", err)
+	}
+	previous, err = history.LastCompletedFullMigration(context.TODO(), c)
+	if err != nil || previous != nil {
+		t.Fatalf("TestHistoryRecorder: expected no completed migration, got %+v, err: %q", previous, err)
+	}
+
+	// Now run a successful migration through the counting client.
+	history = NewHistoryRecorder("metallb-system")
+	err = OnlineMigration(context.TODO(), history.WrapClient(c), scheme, t.TempDir(), false, OnlineMigrationOptions{})
+	if err != nil {
+		t.Fatalf("TestHistoryRecorder: online migration failed, err: %q", err)
+	}
+	err = history.Record(context.TODO(), c, HistoryEntry{
+		Version:   "v2",
+		Mode:      HistoryModeOnlineMigration,
+		Result:    HistoryResultSuccess,
+		BackupDir: "/backup",
+		Flags:     map[string]string{"online-migration": "true"},
+	})
+	if err != nil {
+		t.Fatalf("TestHistoryRecorder: cannot record history, err: %q", err)
+	}
+
+	entries, err := history.Entries(context.TODO(), c)
+	if err != nil {
+		t.Fatalf("TestHistoryRecorder: cannot read history, err: %q", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("TestHistoryRecorder: expected 2 history entries but got %d", len(entries))
+	}
+	previous, err = history.LastCompletedFullMigration(context.TODO(), c)
+	if err != nil || previous == nil || previous.Version != "v2" || previous.BackupDir != "/backup" {
+		t.Fatalf("TestHistoryRecorder: expected the v2 run as completed migration, got %+v, err: %q", previous, err)
+	}
+	expectedCounts := map[string]int{
+		"delete/AddressPool":      3,
+		"create/IPAddressPool":    3,
+		"create/BGPAdvertisement": 3,
+		"create/L2Advertisement":  1,
+	}
+	if len(previous.Counts) != len(expectedCounts) {
+		t.Fatalf("TestHistoryRecorder: expected counts %v but got %v", expectedCounts, previous.Counts)
+	}
+	for k, v := range expectedCounts {
+		if previous.Counts[k] != v {
+			t.Fatalf("TestHistoryRecorder: expected counts %v but got %v", expectedCounts, previous.Counts)
+		}
+	}
+}
+
+func TestHistoryRecorderCountsUpdatesAndPatches(t *testing.T) {
+	var scheme = runtime.NewScheme()
+	if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestHistoryRecorderCountsUpdatesAndPatches: error adding to scheme, err: %q", err)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(validAddressPools0[0].DeepCopy()).Build()
+	history := NewHistoryRecorder("metallb-system")
+	hc := history.WrapClient(c)
+
+	ap := &metallbv1beta1.AddressPool{}
+	if err := hc.Get(context.TODO(), client.ObjectKeyFromObject(&validAddressPools0[0]), ap); err != nil {
+		t.Fatalf("TestHistoryRecorderCountsUpdatesAndPatches: cannot get AddressPool, err: %q", err)
+	}
+	ap.Labels = map[string]string{"updated": "true"}
+	if err := hc.Update(context.TODO(), ap); err != nil {
+		t.Fatalf("TestHistoryRecorderCountsUpdatesAndPatches: cannot update AddressPool, err: %q", err)
+	}
+	patch := client.MergeFrom(ap.DeepCopy())
+	ap.Labels["patched"] = "true"
+	if err := hc.Patch(context.TODO(), ap, patch); err != nil {
+		t.Fatalf("TestHistoryRecorderCountsUpdatesAndPatches: cannot patch AddressPool, err: %q", err)
+	}
+	// Failed mutations are not counted.
+	if err := hc.Update(context.TODO(), validAddressPools0[1].DeepCopy()); err == nil {
+		t.Fatalf("TestHistoryRecorderCountsUpdatesAndPatches: expected the update of a missing AddressPool to fail")
+	}
+
+	expectedCounts := map[string]int{"update/AddressPool": 1, "patch/AddressPool": 1}
+	if fmt.Sprint(history.counts) != fmt.Sprint(expectedCounts) {
+		t.Fatalf("TestHistoryRecorderCountsUpdatesAndPatches: expected counts %v but got %v", expectedCounts,
+			history.counts)
+	}
+}
+
+func TestHistoryRecorderConflicts(t *testing.T) {
+	var scheme = runtime.NewScheme()
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestHistoryRecorderConflicts: error adding to scheme, err: %q", err)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+	history := NewHistoryRecorder("metallb-system")
+	err := history.Record(context.TODO(), c, HistoryEntry{Version: "v1", Result: HistoryResultFailure})
+	if err != nil {
+		t.Fatalf("TestHistoryRecorderConflicts: cannot record history, err: %q", err)
+	}
+
+	resource := schema.GroupResource{Resource: "configmaps"}
+	conflict := apierrors.NewConflict(resource, HistoryConfigMapName, fmt.Errorf("the object has been modified"))
+	fc := &flakyClient{Client: c, err: conflict, failures: conflictAttempts - 1}
+	err = history.Record(context.TODO(), fc, HistoryEntry{Version: "v2", Result: HistoryResultSuccess})
+	if err != nil {
+		t.Fatalf("TestHistoryRecorderConflicts: cannot record history, err: %q", err)
+	}
+	if fc.requests != conflictAttempts {
+		t.Fatalf("TestHistoryRecorderConflicts: expected %d updates but got %d", conflictAttempts, fc.requests)
+	}
+	entries, err := history.Entries(context.TODO(), c)
+	if err != nil {
+		t.Fatalf("TestHistoryRecorderConflicts: cannot read history, err: %q", err)
+	}
+	if len(entries) != 2 || entries[0].Version != "v1" || entries[1].Version != "v2" {
+		t.Fatalf("TestHistoryRecorderConflicts: expected the v1 and v2 entries but got %+v", entries)
+	}
+}
+
+func TestHistoryRecorderPartialMigrations(t *testing.T) {
+	var scheme = runtime.NewScheme()
+	if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestHistoryRecorderPartialMigrations: error adding to scheme, err: %q", err)
+	}
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestHistoryRecorderPartialMigrations: error adding to scheme, err: %q", err)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+	namespaces := []string{"tenant-a", "tenant-b"}
+	for _, namespace := range namespaces {
+		ap := &metallbv1beta1.AddressPool{
+			ObjectMeta: metav1.ObjectMeta{Name: "ap", Namespace: namespace},
+			Spec:       metallbv1beta1.AddressPoolSpec{Protocol: ProtocolLayer2, Addresses: []string{"192.168.0.0/24"}},
+		}
+		if err := c.Create(context.TODO(), ap); err != nil {
+			t.Fatalf("TestHistoryRecorderPartialMigrations: cannot create AddressPool, err: %q", err)
+		}
+	}
+
+	// Every tenant is migrated on its own, like with -namespace. None of the runs completes a full migration.
+	history := NewHistoryRecorder("metallb-system")
+	for _, namespace := range namespaces {
+		previous, err := history.LastCompletedFullMigration(context.TODO(), c)
+		if err != nil || previous != nil {
+			t.Fatalf("TestHistoryRecorderPartialMigrations(%s): expected no completed full migration, got %+v, "+
+				"err: %q", namespace, previous, err)
+		}
+		opts := OnlineMigrationOptions{Options: Options{Namespace: namespace}}
+		if err := OnlineMigration(context.TODO(), c, scheme, t.TempDir(), false, opts); err != nil {
+			t.Fatalf("TestHistoryRecorderPartialMigrations(%s): online migration failed, err: %q", namespace, err)
+		}
+		err = history.Record(context.TODO(), c, HistoryEntry{
+			Version: "v1", Mode: MigrationHistoryMode(opts), Result: HistoryResultSuccess,
+		})
+		if err != nil {
+			t.Fatalf("TestHistoryRecorderPartialMigrations(%s): cannot record history, err: %q", namespace, err)
+		}
+	}
+	addressPoolList := &metallbv1beta1.AddressPoolList{}
+	if err := c.List(context.TODO(), addressPoolList); err != nil {
+		t.Fatalf("TestHistoryRecorderPartialMigrations: cannot list AddressPools, err: %q", err)
+	}
+	if len(addressPoolList.Items) != 0 {
+		t.Fatalf("TestHistoryRecorderPartialMigrations: expected both namespaces to be migrated, got %d "+
+			"AddressPools", len(addressPoolList.Items))
+	}
+	entries, err := history.Entries(context.TODO(), c)
+	if err != nil {
+		t.Fatalf("TestHistoryRecorderPartialMigrations: cannot read history, err: %q", err)
+	}
+	for _, entry := range entries {
+		if entry.Mode != HistoryModePartialMigration {
+			t.Fatalf("TestHistoryRecorderPartialMigrations: expected only %s entries, got %+v",
+				HistoryModePartialMigration, entries)
+		}
+	}
+}
+
+func TestTrimHistory(t *testing.T) {
+	failure := HistoryEntry{Mode: HistoryModeOnlineMigration, Result: HistoryResultFailure}
+	full := HistoryEntry{Mode: HistoryModeOnlineMigration, Result: HistoryResultSuccess}
+	versions := func(results ...HistoryEntry) []HistoryEntry {
+		var entries []HistoryEntry
+		for i, entry := range results {
+			entry.Version = fmt.Sprintf("v%d", i)
+			entries = append(entries, entry)
+		}
+		return entries
+	}
+	tcs := map[string]struct {
+		entries  []HistoryEntry
+		expected []string
+	}{
+		"below the limit": {
+			entries:  versions(failure, full),
+			expected: []string{"v0", "v1"},
+		},
+		"oldest entries dropped": {
+			entries:  versions(failure, failure, full, failure),
+			expected: []string{"v1", "v2", "v3"},
+		},
+		"completed full migration kept": {
+			entries:  versions(full, failure, failure, failure, failure),
+			expected: []string{"v0", "v3", "v4"},
+		},
+	}
+	for desc, tc := range tcs {
+		var got []string
+		for _, entry := range trimHistory(tc.entries, 3) {
+			got = append(got, entry.Version)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tc.expected) {
+			t.Fatalf("TestTrimHistory(%s): expected %v but got %v", desc, tc.expected, got)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package converter
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// IgnoreFileName is the name of the file in an input directory that lists the files to exclude from parsing, in
+// gitignore syntax.
+const IgnoreFileName = ".converterignore"
+
+// ignoreRule is a single pattern of an ignore file.
+type ignoreRule struct {
+	// segments are the slash-separated parts of the pattern, "**" matches any number of path segments.
+	segments []string
+	negate   bool
+	dirOnly  bool
+}
+
+// ignoreRules are the rules of an ignore file, in order. The last rule that matches a path decides.
+type ignoreRules []ignoreRule
+
+// loadIgnoreFile reads the IgnoreFileName of dir. A missing file yields no rules.
+func loadIgnoreFile(dir string) (ignoreRules, error) {
+	content, err := os.ReadFile(filepath.Join(dir, IgnoreFileName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %s, err: %w", IgnoreFileName, err)
+	}
+	return parseIgnoreRules(content)
+}
+
+// parseIgnoreRules parses content in gitignore syntax: blank lines and lines starting with "#" are skipped, "!"
+// negates a pattern, a trailing "/" only matches directories, and patterns that contain a "/" elsewhere are relative
+// to the input directory, while all other patterns match the name of a file or directory at any depth.
+func parseIgnoreRules(content []byte) (ignoreRules, error) {
+	var rules ignoreRules
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for line := 1; scanner.Scan(); line++ {
+		pattern := strings.TrimRight(scanner.Text(), " \t\r")
+		if pattern == "" || strings.HasPrefix(pattern, "#") {
+			continue
+		}
+		rule := ignoreRule{}
+		if strings.HasPrefix(pattern, "!") {
+			rule.negate = true
+			pattern = pattern[1:]
+		} else if strings.HasPrefix(pattern, `\`) {
+			pattern = pattern[1:]
+		}
+		if strings.HasSuffix(pattern, "/") {
+			rule.dirOnly = true
+			pattern = strings.TrimRight(pattern, "/")
+		}
+		if !strings.Contains(pattern, "/") {
+			pattern = "**/" + pattern
+		}
+		pattern = strings.TrimPrefix(pattern, "/")
+		if pattern == "" {
+			return nil, fmt.Errorf("invalid pattern in %s line %d", IgnoreFileName, line)
+		}
+		rule.segments = strings.Split(pattern, "/")
+		for _, segment := range rule.segments {
+			if _, err := path.Match(segment, ""); err != nil {
+				return nil, fmt.Errorf("invalid pattern %q in %s line %d, err: %w", scanner.Text(), IgnoreFileName,
+					line, err)
+			}
+		}
+		rules = append(rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("cannot read %s, err: %w", IgnoreFileName, err)
+	}
+	return rules, nil
+}
+
+// ignores returns true if the file or directory at relPath, relative to the input directory, is excluded. The ignore
+// file itself and the IncrementalCacheFileName are always excluded.
+func (r ignoreRules) ignores(relPath string, isDir bool) bool {
+	relPath = filepath.ToSlash(relPath)
+	if relPath == IgnoreFileName || relPath == IncrementalCacheFileName {
+		return true
+	}
+	ignored := false
+	for _, rule := range r {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		if matchSegments(rule.segments, strings.Split(relPath, "/")) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+// matchSegments matches the segments of a path against the segments of a pattern, "**" matches any number of
+// segments.
+func matchSegments(pattern, segments []string) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(segments); i++ {
+			if matchSegments(pattern[1:], segments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segments) == 0 {
+		return false
+	}
+	if matched, _ := path.Match(pattern[0], segments[0]); !matched {
+		return false
+	}
+	return matchSegments(pattern[1:], segments[1:])
+}
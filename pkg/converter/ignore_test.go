@@ -0,0 +1,80 @@
+package converter
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestIgnoreRules(t *testing.T) {
+	rules, err := parseIgnoreRules([]byte(`# generated files
+*.generated.yaml
+!keep.generated.yaml
+examples/
+/README.md
+docs/**/*.json
+\#literal
+`))
+	if err != nil {
+		t.Fatalf("TestIgnoreRules: unexpected error, err: %q", err)
+	}
+	tcs := map[string]struct {
+		path     string
+		isDir    bool
+		expected bool
+	}{
+		"glob":                      {path: "pools.generated.yaml", expected: true},
+		"glob in subdirectory":      {path: "a/b/pools.generated.yaml", expected: true},
+		"negated":                   {path: "keep.generated.yaml", expected: false},
+		"directory":                 {path: "examples", isDir: true, expected: true},
+		"directory only":            {path: "examples", expected: false},
+		"anchored":                  {path: "README.md", expected: true},
+		"anchored in subdirectory":  {path: "a/README.md", expected: false},
+		"double star":               {path: "docs/a/b/pools.json", expected: true},
+		"double star zero segments": {path: "docs/pools.json", expected: true},
+		"escaped comment":           {path: "#literal", expected: true},
+		"ignore file":               {path: IgnoreFileName, expected: true},
+		"not matched":               {path: "pools.yaml", expected: false},
+	}
+	for desc, tc := range tcs {
+		if ignored := rules.ignores(tc.path, tc.isDir); ignored != tc.expected {
+			t.Fatalf("TestIgnoreRules(%s): expected %t but got %t for %q", desc, tc.expected, ignored, tc.path)
+		}
+	}
+	if _, err := parseIgnoreRules([]byte("[")); err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Fatalf("TestIgnoreRules: expected an error for an invalid pattern but got %v", err)
+	}
+}
+
+func TestReadLegacyObjectsFromDirectoryIgnore(t *testing.T) {
+	var scheme = runtime.NewScheme()
+	if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestReadLegacyObjectsFromDirectoryIgnore: error adding to scheme, err: %q", err)
+	}
+	dir := t.TempDir()
+	files := map[string]string{
+		IgnoreFileName: "*.md\nexamples/\n",
+		"pools.yaml":   renderedKustomization,
+		"README.md":    "# Legacy pools",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("TestReadLegacyObjectsFromDirectoryIgnore: cannot write %s, err: %q", name, err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "examples"), 0755); err != nil {
+		t.Fatalf("TestReadLegacyObjectsFromDirectoryIgnore: cannot create directory, err: %q", err)
+	}
+	legacyObjects, err := ReadLegacyObjectsFromDirectory(scheme, dir, Options{})
+	if err != nil {
+		t.Fatalf("TestReadLegacyObjectsFromDirectoryIgnore: unexpected error, err: %q", err)
+	}
+	if len(legacyObjects.AddressPoolList.Items) != 1 {
+		t.Fatalf("TestReadLegacyObjectsFromDirectoryIgnore: expected 1 AddressPool but got %d",
+			len(legacyObjects.AddressPoolList.Items))
+	}
+}
@@ -0,0 +1,173 @@
+package converter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// IncrementalCacheFileName is the name of the file in an output directory that records the sources and outputs of
+// the previous incremental conversion into that directory.
+const IncrementalCacheFileName = ".metallb-converter-cache.json"
+
+// incrementalCache records the hashes of the sources and of the output files of an incremental conversion.
+type incrementalCache struct {
+	// Key identifies the settings of the conversion, see OfflineMigrationOptions.CacheKey.
+	Key string `json:"key"`
+	// Sources maps the namespace/name identifiers of the legacy AddressPools to the hashes of their content.
+	Sources map[string]string `json:"sources"`
+	// Outputs maps the slash separated paths of the output files to the hashes of their content.
+	Outputs map[string]string `json:"outputs"`
+}
+
+// loadIncrementalCache reads the IncrementalCacheFileName of dir. A missing or unreadable cache yields an empty cache,
+// which makes the next conversion regenerate all outputs.
+func loadIncrementalCache(dir string) *incrementalCache {
+	cache := &incrementalCache{Sources: map[string]string{}, Outputs: map[string]string{}}
+	data, err := os.ReadFile(filepath.Join(dir, IncrementalCacheFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return cache
+	}
+	if err == nil {
+		err = json.Unmarshal(data, cache)
+	}
+	if err != nil {
+		if !quiet {
+			log.Printf("warning: ignoring invalid %s, all outputs are regenerated, err: %v",
+				IncrementalCacheFileName, err)
+		}
+		return &incrementalCache{Sources: map[string]string{}, Outputs: map[string]string{}}
+	}
+	return cache
+}
+
+// save writes the cache to the IncrementalCacheFileName of dir.
+func (c *incrementalCache) save(dir string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("cannot encode %s, err: %w", IncrementalCacheFileName, err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, IncrementalCacheFileName), data, 0644); err != nil {
+		return fmt.Errorf("cannot write %s, err: %w", IncrementalCacheFileName, err)
+	}
+	return nil
+}
+
+// upToDate returns true if the cache was written with key for the same sources, and if all output files that it
+// records are unchanged in dir.
+func (c *incrementalCache) upToDate(dir, key string, sources map[string]string) bool {
+	if c.Key != key || !equalHashes(c.Sources, sources) || len(c.Outputs) == 0 {
+		return false
+	}
+	for name, hash := range c.Outputs {
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil || contentHash(data) != hash {
+			return false
+		}
+	}
+	return true
+}
+
+// sourceHashes returns the hashes of the content of the legacy AddressPools of l, keyed by namespace/name, and of its
+// BGPPeers and BFDProfiles, keyed by kind/namespace/name. Status and server-populated metadata such as the
+// resourceVersion are disregarded.
+func sourceHashes(l *LegacyObjects) (map[string]string, error) {
+	hashes := map[string]string{}
+	for _, ap := range l.AddressPoolList.Items {
+		data, err := json.Marshal([]interface{}{ap.Labels, ap.Annotations, ap.OwnerReferences, ap.Spec})
+		if err != nil {
+			return nil, fmt.Errorf("cannot hash AddressPool %s/%s, err: %w", ap.Namespace, ap.Name, err)
+		}
+		hashes[fmt.Sprintf("%s/%s", ap.Namespace, ap.Name)] = contentHash(data)
+	}
+	if l.BGPPeerList != nil {
+		for _, peer := range l.BGPPeerList.Items {
+			data, err := json.Marshal([]interface{}{peer.Labels, peer.Annotations, peer.OwnerReferences, peer.Spec})
+			if err != nil {
+				return nil, fmt.Errorf("cannot hash BGPPeer %s/%s, err: %w", peer.Namespace, peer.Name, err)
+			}
+			hashes[fmt.Sprintf("BGPPeer/%s/%s", peer.Namespace, peer.Name)] = contentHash(data)
+		}
+	}
+	if l.BFDProfileList != nil {
+		for _, profile := range l.BFDProfileList.Items {
+			data, err := json.Marshal([]interface{}{profile.Labels, profile.Annotations, profile.OwnerReferences,
+				profile.Spec})
+			if err != nil {
+				return nil, fmt.Errorf("cannot hash BFDProfile %s/%s, err: %w", profile.Namespace, profile.Name, err)
+			}
+			hashes[fmt.Sprintf("BFDProfile/%s/%s", profile.Namespace, profile.Name)] = contentHash(data)
+		}
+	}
+	return hashes, nil
+}
+
+// syncOutputs copies the files of srcDir whose content differs from the file of the same path in dstDir into dstDir,
+// and removes the files of previous outputs that srcDir no longer contains. Subdirectories are copied recursively. It
+// returns the hashes of all files of srcDir, keyed by their slash separated path relative to srcDir.
+func syncOutputs(srcDir, dstDir string, previous map[string]string) (map[string]string, error) {
+	names, err := listFiles(srcDir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read generated outputs, err: %w", err)
+	}
+	outputs := map[string]string{}
+	var changed []string
+	for _, name := range names {
+		data, err := os.ReadFile(filepath.Join(srcDir, filepath.FromSlash(name)))
+		if err != nil {
+			return nil, fmt.Errorf("cannot read generated outputs, err: %w", err)
+		}
+		outputs[name] = contentHash(data)
+		dst := filepath.Join(dstDir, filepath.FromSlash(name))
+		existing, err := os.ReadFile(dst)
+		if err == nil && contentHash(existing) == outputs[name] {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return nil, fmt.Errorf("cannot create destination directory, err: %w", err)
+		}
+		if err := os.WriteFile(dst, data, 0644); err != nil {
+			return nil, fmt.Errorf("cannot create destination file, err: %w", err)
+		}
+		changed = append(changed, name)
+	}
+	for name := range previous {
+		if _, ok := outputs[name]; ok {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dstDir, filepath.FromSlash(name))); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("cannot remove stale output %s, err: %w", name, err)
+		}
+		changed = append(changed, name)
+	}
+	if !quiet {
+		sort.Strings(changed)
+		log.Printf("incremental conversion updated %d of %d outputs %v", len(changed), len(outputs), changed)
+	}
+	return outputs, nil
+}
+
+// equalHashes returns true if a and b hold the same keys and hashes.
+func equalHashes(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if b[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// contentHash returns the hex encoded SHA-256 hash of data.
+func contentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
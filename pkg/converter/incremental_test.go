@@ -0,0 +1,152 @@
+package converter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestIncrementalOfflineMigration(t *testing.T) {
+	var scheme = runtime.NewScheme()
+	if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestIncrementalOfflineMigration: error adding to scheme, err: %q", err)
+	}
+	inDir, outDir := t.TempDir(), t.TempDir()
+	past := time.Now().Add(-time.Hour).Truncate(time.Second)
+	tcs := []struct {
+		desc             string
+		input            string
+		cacheKey         string
+		modifyOutput     bool
+		expectedRewrites []string
+		expectedFiles    []string
+	}{
+		{
+			desc:             "first run",
+			input:            renderedKustomization,
+			cacheKey:         "a",
+			expectedRewrites: []string{"IPAddressPool.yaml", "L2Advertisement.yaml"},
+			expectedFiles:    []string{"IPAddressPool.yaml", "L2Advertisement.yaml"},
+		},
+		{
+			desc:          "unchanged",
+			input:         renderedKustomization,
+			cacheKey:      "a",
+			expectedFiles: []string{"IPAddressPool.yaml", "L2Advertisement.yaml"},
+		},
+		{
+			desc:          "other settings with the same outputs",
+			input:         renderedKustomization,
+			cacheKey:      "b",
+			expectedFiles: []string{"IPAddressPool.yaml", "L2Advertisement.yaml"},
+		},
+		{
+			desc:             "modified output",
+			input:            renderedKustomization,
+			cacheKey:         "b",
+			modifyOutput:     true,
+			expectedRewrites: []string{"IPAddressPool.yaml"},
+			expectedFiles:    []string{"IPAddressPool.yaml", "L2Advertisement.yaml"},
+		},
+		{
+			desc:             "changed source",
+			input:            strings.Replace(renderedKustomization, "layer2", "bgp", 1),
+			cacheKey:         "b",
+			expectedRewrites: []string{"BGPAdvertisement.yaml"},
+			expectedFiles:    []string{"BGPAdvertisement.yaml", "IPAddressPool.yaml"},
+		},
+	}
+	for _, tc := range tcs {
+		if err := os.WriteFile(filepath.Join(inDir, "pools.yaml"), []byte(tc.input), 0644); err != nil {
+			t.Fatalf("TestIncrementalOfflineMigration(%s): cannot write input, err: %q", tc.desc, err)
+		}
+		if tc.modifyOutput {
+			if err := os.WriteFile(filepath.Join(outDir, "IPAddressPool.yaml"), []byte("edited"), 0644); err != nil {
+				t.Fatalf("TestIncrementalOfflineMigration(%s): cannot modify output, err: %q", tc.desc, err)
+			}
+		}
+		// Reset the modification times of all outputs to tell the rewritten outputs apart.
+		entries, _ := os.ReadDir(outDir)
+		for _, entry := range entries {
+			if err := os.Chtimes(filepath.Join(outDir, entry.Name()), past, past); err != nil {
+				t.Fatalf("TestIncrementalOfflineMigration(%s): cannot reset times, err: %q", tc.desc, err)
+			}
+		}
+		err := OfflineMigration(context.TODO(), nil, scheme, inDir, outDir, OutputFormatYAML, OfflineMigrationOptions{
+			CacheKey: tc.cacheKey,
+		})
+		if err != nil {
+			t.Fatalf("TestIncrementalOfflineMigration(%s): unexpected error, err: %q", tc.desc, err)
+		}
+		entries, _ = os.ReadDir(outDir)
+		var files, rewrites []string
+		for _, entry := range entries {
+			if entry.Name() == IncrementalCacheFileName {
+				continue
+			}
+			files = append(files, entry.Name())
+			info, err := entry.Info()
+			if err != nil {
+				t.Fatalf("TestIncrementalOfflineMigration(%s): cannot stat output, err: %q", tc.desc, err)
+			}
+			if !info.ModTime().Equal(past) {
+				rewrites = append(rewrites, entry.Name())
+			}
+		}
+		if strings.Join(files, ",") != strings.Join(tc.expectedFiles, ",") {
+			t.Fatalf("TestIncrementalOfflineMigration(%s): expected outputs %v but got %v", tc.desc, tc.expectedFiles,
+				files)
+		}
+		if strings.Join(rewrites, ",") != strings.Join(tc.expectedRewrites, ",") {
+			t.Fatalf("TestIncrementalOfflineMigration(%s): expected rewrites of %v but got %v", tc.desc,
+				tc.expectedRewrites, rewrites)
+		}
+	}
+}
+
+func TestSyncOutputsSubdirectories(t *testing.T) {
+	srcDir, dstDir := t.TempDir(), t.TempDir()
+	for name, content := range map[string]string{
+		"ipaddresspools/metallb-system_a.yaml":       "a",
+		"ipaddresspools/metallb-system_b.yaml":       "b",
+		"l2advertisements/metallb-system_stale.yaml": "stale",
+	} {
+		if err := os.MkdirAll(filepath.Join(dstDir, filepath.Dir(name)), 0755); err != nil {
+			t.Fatalf("TestSyncOutputsSubdirectories: cannot prepare outputs, err: %q", err)
+		}
+		if err := os.WriteFile(filepath.Join(dstDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("TestSyncOutputsSubdirectories: cannot prepare outputs, err: %q", err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(srcDir, "ipaddresspools"), 0755); err != nil {
+		t.Fatalf("TestSyncOutputsSubdirectories: cannot prepare sources, err: %q", err)
+	}
+	for name, content := range map[string]string{"metallb-system_a.yaml": "a", "metallb-system_b.yaml": "new b"} {
+		if err := os.WriteFile(filepath.Join(srcDir, "ipaddresspools", name), []byte(content), 0644); err != nil {
+			t.Fatalf("TestSyncOutputsSubdirectories: cannot prepare sources, err: %q", err)
+		}
+	}
+	outputs, err := syncOutputs(srcDir, dstDir, map[string]string{
+		"l2advertisements/metallb-system_stale.yaml": contentHash([]byte("stale")),
+	})
+	if err != nil {
+		t.Fatalf("TestSyncOutputsSubdirectories: unexpected error, err: %q", err)
+	}
+	if len(outputs) != 2 || outputs["ipaddresspools/metallb-system_b.yaml"] != contentHash([]byte("new b")) {
+		t.Fatalf("TestSyncOutputsSubdirectories: unexpected outputs %v", outputs)
+	}
+	content, err := os.ReadFile(filepath.Join(dstDir, "ipaddresspools", "metallb-system_b.yaml"))
+	if err != nil || string(content) != "new b" {
+		t.Fatalf("TestSyncOutputsSubdirectories: expected the changed output to be rewritten, got %q, err: %v",
+			content, err)
+	}
+	if _, err := os.Stat(filepath.Join(dstDir, "l2advertisements", "metallb-system_stale.yaml")); !os.IsNotExist(err) {
+		t.Fatalf("TestSyncOutputsSubdirectories: expected the stale output to be removed, err: %v", err)
+	}
+}
@@ -0,0 +1,114 @@
+package converter
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// DefaultKustomizeCommand renders a kustomization. The directory of the kustomization is appended as last argument.
+var DefaultKustomizeCommand = []string{"kustomize", "build"}
+
+// kustomizationFileNames are the file names that kustomize recognizes as a kustomization.
+var kustomizationFileNames = []string{"kustomization.yaml", "kustomization.yml", "Kustomization"}
+
+// IsKustomization returns true if dir contains a kustomization file.
+func IsKustomization(dir string) bool {
+	for _, name := range kustomizationFileNames {
+		if fi, err := os.Stat(filepath.Join(dir, name)); err == nil && !fi.IsDir() {
+			return true
+		}
+	}
+	return false
+}
+
+// RenderKustomization runs command with dir as last argument and returns its standard output, e.g. the resources
+// that kustomize build renders from the bases and overlays of dir.
+func RenderKustomization(command []string, dir string) ([]byte, error) {
+	return render(fmt.Sprintf("kustomization %q", dir), command, dir)
+}
+
+const (
+	// KustomizationFileName is the kustomization that OfflineMigrationOptions.Kustomization writes into the output
+	// directory.
+	KustomizationFileName = "kustomization.yaml"
+	// LegacyCleanupDir is the subdirectory of the output directory with the kustomize component that deletes the
+	// legacy AddressPools, see OfflineMigrationOptions.Kustomization.
+	LegacyCleanupDir = "legacy-cleanup"
+)
+
+// kustomization is a kustomization or a kustomize component.
+type kustomization struct {
+	APIVersion string               `json:"apiVersion"`
+	Kind       string               `json:"kind"`
+	Resources  []string             `json:"resources,omitempty"`
+	Patches    []kustomizationPatch `json:"patches,omitempty"`
+}
+
+// kustomizationPatch is an inline patch of a kustomization.
+type kustomizationPatch struct {
+	Patch string `json:"patch"`
+}
+
+// writeKustomizations writes a KustomizationFileName that lists the files of groups as resources into dir, and a
+// component into LegacyCleanupDir with a $patch: delete patch for every legacy AddressPool. Bases that still hold the
+// legacy AddressPools include the component to remove them, so that GitOps tools prune them once the converted
+// resources are applied.
+func writeKustomizations(dir string, groups []outputGroup, toJSON bool, legacyObjects *LegacyObjects,
+	opts Options) error {
+	fileExtension := "yaml"
+	if toJSON {
+		fileExtension = "json"
+	}
+	var resources []string
+	for _, group := range groups {
+		resource := fmt.Sprintf("%s.%s", group.name, fileExtension)
+		if resource == KustomizationFileName || strings.HasPrefix(resource, LegacyCleanupDir+"/") {
+			return fmt.Errorf("output file %s collides with the generated kustomization", resource)
+		}
+		resources = append(resources, resource)
+	}
+	sort.Strings(resources)
+	err := writeKustomization(filepath.Join(dir, KustomizationFileName), kustomization{
+		APIVersion: "kustomize.config.k8s.io/v1beta1",
+		Kind:       "Kustomization",
+		Resources:  resources,
+	})
+	if err != nil {
+		return err
+	}
+	cleanup := kustomization{APIVersion: "kustomize.config.k8s.io/v1alpha1", Kind: "Component"}
+	for _, ap := range legacyObjects.AddressPoolList.Items {
+		patch, err := yaml.Marshal(map[string]interface{}{
+			"$patch":     "delete",
+			"apiVersion": opts.sourceAPIGroup() + "/v1beta1",
+			"kind":       "AddressPool",
+			"metadata":   map[string]string{"name": ap.Name, "namespace": ap.Namespace},
+		})
+		if err != nil {
+			return fmt.Errorf("cannot encode the delete patch of AddressPool %s/%s, err: %w", ap.Namespace,
+				ap.Name, err)
+		}
+		cleanup.Patches = append(cleanup.Patches, kustomizationPatch{Patch: string(patch)})
+	}
+	return writeKustomization(filepath.Join(dir, LegacyCleanupDir, KustomizationFileName), cleanup)
+}
+
+// writeKustomization writes k as YAML to fileName.
+func writeKustomization(fileName string, k kustomization) error {
+	data, err := yaml.Marshal(k)
+	if err != nil {
+		return fmt.Errorf("cannot encode %s, err: %w", fileName, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(fileName), 0755); err != nil {
+		return fmt.Errorf("cannot create destination directory, err: %w", err)
+	}
+	if err := os.WriteFile(fileName, data, 0644); err != nil {
+		return fmt.Errorf("cannot create destination file, err: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/yaml"
+)
+
+const renderedKustomization = `apiVersion: metallb.io/v1beta1
+kind: AddressPool
+metadata:
+  name: overlay-pool
+  namespace: metallb-system
+spec:
+  protocol: layer2
+  addresses:
+  - 192.168.10.0/24
+`
+
+func TestOfflineMigrationKustomize(t *testing.T) {
+	var scheme = runtime.NewScheme()
+	if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestOfflineMigrationKustomize: error adding to scheme, err: %q", err)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+	dir := t.TempDir()
+	// The kustomization refers to a base that does not exist, it is only rendered by the fake command.
+	files := map[string]string{
+		"kustomization.yaml": "resources:\n- ../base\n",
+		"rendered.txt":       renderedKustomization,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("TestOfflineMigrationKustomize: cannot write %s, err: %q", name, err)
+		}
+	}
+	if !IsKustomization(dir) || IsKustomization(t.TempDir()) {
+		t.Fatalf("TestOfflineMigrationKustomize: unexpected result of IsKustomization")
+	}
+
+	tcs := map[string]struct {
+		command             []string
+		expectedOutput      string
+		expectedErrorString string
+	}{
+		"rendered": {
+			command:        []string{"sh", "-c", `cat "$1/rendered.txt"`, "sh"},
+			expectedOutput: "IPAddressPool/metallb-system/overlay-pool",
+		},
+		"render failure": {
+			command:             []string{"sh", "-c", `echo "missing base" >&2; exit 1`, "sh"},
+			expectedErrorString: `stderr: "missing base"`,
+		},
+		"not rendered": {
+			expectedErrorString: "contains a kustomization that must be rendered first",
+		},
+	}
+	for desc, tc := range tcs {
+		stdout = bytes.NewBuffer([]byte{})
+		err := OfflineMigration(context.TODO(), c, scheme, dir, "", OutputFormatName,
+			OfflineMigrationOptions{Kustomize: tc.command})
+		if tc.expectedErrorString != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.expectedErrorString) {
+				t.Fatalf("TestOfflineMigrationKustomize(%s): expected error %q but got %v", desc,
+					tc.expectedErrorString, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("TestOfflineMigrationKustomize(%s): unexpected error, err: %q", desc, err)
+		}
+		if !strings.Contains(fmt.Sprint(stdout), tc.expectedOutput) {
+			t.Fatalf("TestOfflineMigrationKustomize(%s): expected %q in output but got:\n%s", desc, tc.expectedOutput,
+				stdout)
+		}
+	}
+}
+
+func TestOfflineMigrationKustomization(t *testing.T) {
+	tcs := map[string]struct {
+		outputFormat      string
+		layout            string
+		expectedResources []string
+	}{
+		"yaml": {
+			outputFormat: OutputFormatYAML,
+			layout:       OutputLayoutFlat,
+			expectedResources: []string{"BFDProfile.yaml", "BGPAdvertisement.yaml", "BGPPeer.yaml",
+				"IPAddressPool.yaml", "Secret.yaml"},
+		},
+		"json per kind directory": {
+			outputFormat: OutputFormatJSON,
+			layout:       OutputLayoutPerKindDir,
+			expectedResources: []string{"bfdprofiles/metallb-system_fast.json",
+				"bgpadvertisements/metallb-system_bgp-bgp-advertisement-0.json", "bgppeers/metallb-system_peer-a.json",
+				"ipaddresspools/metallb-system_bgp.json", "secrets/metallb-system_peer-a-bgp-password.json"},
+		},
+	}
+	var scheme = runtime.NewScheme()
+	if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestOfflineMigrationKustomization: error adding to scheme, err: %q", err)
+	}
+	SetQuiet(true)
+	defer SetQuiet(false)
+	inDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(inDir, "config.yaml"), []byte(legacyBGPResources), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for desc, tc := range tcs {
+		outDir := t.TempDir()
+		err := OfflineMigration(context.TODO(), nil, scheme, inDir, outDir, tc.outputFormat,
+			OfflineMigrationOptions{Options: Options{OutputLayout: tc.layout}, Kustomization: true})
+		if err != nil {
+			t.Fatalf("TestOfflineMigrationKustomization(%s): unexpected error, err: %q", desc, err)
+		}
+		k := kustomization{}
+		data, err := os.ReadFile(filepath.Join(outDir, KustomizationFileName))
+		if err == nil {
+			err = yaml.Unmarshal(data, &k)
+		}
+		if err != nil {
+			t.Fatalf("TestOfflineMigrationKustomization(%s): cannot read kustomization, err: %q", desc, err)
+		}
+		if k.Kind != "Kustomization" || !reflect.DeepEqual(k.Resources, tc.expectedResources) {
+			t.Fatalf("TestOfflineMigrationKustomization(%s): expected resources %v but got %s", desc,
+				tc.expectedResources, string(data))
+		}
+		for _, resource := range k.Resources {
+			if _, err := os.Stat(filepath.Join(outDir, resource)); err != nil {
+				t.Fatalf("TestOfflineMigrationKustomization(%s): resource %s was not written, err: %q", desc,
+					resource, err)
+			}
+		}
+		cleanup := kustomization{}
+		data, err = os.ReadFile(filepath.Join(outDir, LegacyCleanupDir, KustomizationFileName))
+		if err == nil {
+			err = yaml.Unmarshal(data, &cleanup)
+		}
+		if err != nil {
+			t.Fatalf("TestOfflineMigrationKustomization(%s): cannot read legacy cleanup component, err: %q", desc,
+				err)
+		}
+		expectedPatch := "$patch: delete\napiVersion: metallb.io/v1beta1\nkind: AddressPool\nmetadata:\n" +
+			"  name: bgp\n  namespace: metallb-system\n"
+		if cleanup.Kind != "Component" || len(cleanup.Patches) != 1 || cleanup.Patches[0].Patch != expectedPatch {
+			t.Fatalf("TestOfflineMigrationKustomization(%s): expected a delete patch for AddressPool bgp but got %s",
+				desc, string(data))
+		}
+	}
+}
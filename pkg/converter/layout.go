@@ -0,0 +1,55 @@
+package converter
+
+import (
+	"fmt"
+	"path"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	// OutputLayoutFlat writes all files directly into the output directory.
+	OutputLayoutFlat = "flat"
+	// OutputLayoutPerKindDir writes one file per resource, named <namespace>_<name>, into one subdirectory per kind,
+	// e.g. ipaddresspools/ and bgpadvertisements/.
+	OutputLayoutPerKindDir = "per-kind-dir"
+)
+
+// SupportedOutputLayouts lists all layouts that can be set as Options.OutputLayout.
+var SupportedOutputLayouts = []string{OutputLayoutFlat, OutputLayoutPerKindDir}
+
+// kindDirectories maps the kinds of the converted resources to their subdirectories with OutputLayoutPerKindDir.
+var kindDirectories = map[string]string{
+	"IPAddressPool":    "ipaddresspools",
+	"L2Advertisement":  "l2advertisements",
+	"BGPAdvertisement": "bgpadvertisements",
+	"Community":        "communities",
+	"BGPPeer":          "bgppeers",
+	"BFDProfile":       "bfdprofiles",
+	"Secret":           "secrets",
+}
+
+// runtimeObjectsPerKindDir returns one group per object of the kind groups of runtimeObjectsByKind, named after the
+// subdirectory of its kind and its namespace and name.
+func runtimeObjectsPerKindDir(kindGroups [][]runtime.Object) ([]outputGroup, error) {
+	var groups []outputGroup
+	for _, runtimeObjects := range kindGroups {
+		for _, runtimeObject := range runtimeObjects {
+			kind := runtimeObject.GetObjectKind().GroupVersionKind().Kind
+			dir, ok := kindDirectories[kind]
+			if !ok {
+				return nil, fmt.Errorf("no output directory for kind %q", kind)
+			}
+			accessor, err := meta.Accessor(runtimeObject)
+			if err != nil {
+				return nil, fmt.Errorf("cannot access metadata of %s, err: %w", kind, err)
+			}
+			groups = append(groups, outputGroup{
+				name:    path.Join(dir, fmt.Sprintf("%s_%s", accessor.GetNamespace(), accessor.GetName())),
+				objects: []runtime.Object{runtimeObject},
+			})
+		}
+	}
+	return groups, nil
+}
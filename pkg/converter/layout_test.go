@@ -0,0 +1,102 @@
+package converter
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestOutputLayout(t *testing.T) {
+	pools := []metallbv1beta1.AddressPool{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "l2", Namespace: "metallb-system"},
+			Spec:       metallbv1beta1.AddressPoolSpec{Protocol: ProtocolLayer2, Addresses: []string{"10.0.0.0/24"}},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "bgp", Namespace: "metallb-system"},
+			Spec: metallbv1beta1.AddressPoolSpec{
+				Protocol:          ProtocolBGP,
+				Addresses:         []string{"10.0.1.0/24"},
+				BGPAdvertisements: []metallbv1beta1.LegacyBgpAdvertisement{{LocalPref: 100}, {LocalPref: 200}},
+			},
+		},
+	}
+	tcs := map[string]struct {
+		layout              string
+		expectedFiles       []string
+		expectedErrorString string
+	}{
+		"flat": {
+			layout:        OutputLayoutFlat,
+			expectedFiles: []string{"BGPAdvertisement.yaml", "IPAddressPool.yaml", "L2Advertisement.yaml"},
+		},
+		"per kind dir": {
+			layout: OutputLayoutPerKindDir,
+			expectedFiles: []string{
+				"bgpadvertisements/metallb-system_bgp-bgp-advertisement-0.yaml",
+				"bgpadvertisements/metallb-system_bgp-bgp-advertisement-1.yaml",
+				"ipaddresspools/metallb-system_bgp.yaml",
+				"ipaddresspools/metallb-system_l2.yaml",
+				"l2advertisements/metallb-system_l2-l2-advertisement.yaml",
+			},
+		},
+		"unsupported": {
+			layout:              "nested",
+			expectedErrorString: "unsupported output layout \"nested\", must be one of: flat, per-kind-dir",
+		},
+	}
+	for desc, tc := range tcs {
+		opts := Options{OutputLayout: tc.layout}
+		err := opts.Validate()
+		if tc.expectedErrorString != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.expectedErrorString) {
+				t.Fatalf("TestOutputLayout(%s): expected error %q but got %v", desc, tc.expectedErrorString, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("TestOutputLayout(%s): unexpected error, err: %q", desc, err)
+		}
+		l := &LegacyObjects{AddressPoolList: &metallbv1beta1.AddressPoolList{Items: pools}}
+		c, err := l.Convert(opts)
+		if err != nil {
+			t.Fatalf("TestOutputLayout(%s): unexpected error, err: %q", desc, err)
+		}
+		dir := t.TempDir()
+		if err := c.Print(dir, false, opts); err != nil {
+			t.Fatalf("TestOutputLayout(%s): unexpected error, err: %q", desc, err)
+		}
+		var files []string
+		err = filepath.WalkDir(dir, func(p string, entry os.DirEntry, err error) error {
+			if err != nil || entry.IsDir() {
+				return err
+			}
+			name, err := filepath.Rel(dir, p)
+			files = append(files, filepath.ToSlash(name))
+			return err
+		})
+		if err != nil {
+			t.Fatalf("TestOutputLayout(%s): unexpected error, err: %q", desc, err)
+		}
+		sort.Strings(files)
+		if !reflect.DeepEqual(files, tc.expectedFiles) {
+			t.Fatalf("TestOutputLayout(%s): expected files %v but got %v", desc, tc.expectedFiles, files)
+		}
+		if tc.layout != OutputLayoutPerKindDir {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(dir, "ipaddresspools", "metallb-system_l2.yaml"))
+		if err != nil {
+			t.Fatalf("TestOutputLayout(%s): unexpected error, err: %q", desc, err)
+		}
+		if strings.Count(string(content), "kind: ") != 1 || !strings.Contains(string(content), "name: l2\n") {
+			t.Fatalf("TestOutputLayout(%s): expected only IPAddressPool l2 but got:\n%s", desc, content)
+		}
+	}
+}
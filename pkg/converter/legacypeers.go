@@ -0,0 +1,138 @@
+package converter
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	metallbv1beta2 "go.universe.tf/metallb/api/v1beta2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	// PeerPasswordsSecret moves the plaintext passwords of legacy peers into Secrets that the converted BGPPeers
+	// reference with passwordSecret.
+	PeerPasswordsSecret = "secret"
+	// PeerPasswordsInline keeps the plaintext passwords of legacy peers in the password field of the BGPPeers.
+	PeerPasswordsInline = "inline"
+
+	// peerPasswordKey is the key of the password in the Secret of a BGPPeer, as MetalLB expects it.
+	peerPasswordKey = "password"
+)
+
+// SupportedPeerPasswords lists all values of Options.PeerPasswords.
+var SupportedPeerPasswords = []string{PeerPasswordsSecret, PeerPasswordsInline}
+
+// convertPeers converts the peers of the legacy ConfigMap that the AddressPools were read from into BGPPeers in
+// namespace. With the PeerPasswordsSecret mode, every password is moved into a Secret of type kubernetes.io/basic-auth
+// that the BGPPeer references. It returns the BGPPeers and the Secrets grouped by kind, or nil if there are no peers.
+func (l LegacyObjects) convertPeers(namespace, passwordsMode string) ([][]runtime.Object, error) {
+	var peers, secrets []runtime.Object
+	used := map[string]bool{}
+	if l.config == nil {
+		return nil, nil
+	}
+	for _, lp := range l.config.Peers {
+		name := legacyPeerName(lp, used)
+		peer := &metallbv1beta2.BGPPeer{
+			TypeMeta:   metav1.TypeMeta{Kind: "BGPPeer", APIVersion: metallbV1beta2APIVersion},
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+			Spec: metallbv1beta2.BGPPeerSpec{
+				MyASN:         lp.MyASN,
+				ASN:           lp.PeerASN,
+				Address:       lp.PeerAddress,
+				SrcAddress:    lp.SourceAddress,
+				Port:          lp.PeerPort,
+				RouterID:      lp.RouterID,
+				BFDProfile:    lp.BFDProfile,
+				EBGPMultiHop:  lp.EBGPMultiHop,
+				NodeSelectors: nodeSelectorsOf(lp.NodeSelectors),
+			},
+		}
+		var err error
+		if peer.Spec.HoldTime, err = parsePeerDuration(lp.HoldTime); err != nil {
+			return nil, fmt.Errorf("invalid hold-time of peer %s, err: %w", lp.PeerAddress, err)
+		}
+		if peer.Spec.KeepaliveTime, err = parsePeerDuration(lp.KeepaliveTime); err != nil {
+			return nil, fmt.Errorf("invalid keepalive-time of peer %s, err: %w", lp.PeerAddress, err)
+		}
+		if secret := setPeerPassword(peer, lp.Password, passwordsMode); secret != nil {
+			secrets = append(secrets, secret)
+		}
+		peers = append(peers, peer)
+	}
+	var groups [][]runtime.Object
+	for _, group := range [][]runtime.Object{peers, secrets} {
+		if len(group) > 0 {
+			groups = append(groups, group)
+		}
+	}
+	return groups, nil
+}
+
+// setPeerPassword sets password as the password of peer according to mode, one of SupportedPeerPasswords. With
+// PeerPasswordsSecret, it returns the Secret of type kubernetes.io/basic-auth that peer references, named after peer.
+// It returns nil if password is empty or kept inline.
+func setPeerPassword(peer *metallbv1beta2.BGPPeer, password, mode string) *corev1.Secret {
+	if password == "" {
+		return nil
+	}
+	if mode == PeerPasswordsInline {
+		peer.Spec.Password = password
+		return nil
+	}
+	secret := &corev1.Secret{
+		TypeMeta:   metav1.TypeMeta{Kind: "Secret", APIVersion: "v1"},
+		ObjectMeta: metav1.ObjectMeta{Name: peer.Name + "-bgp-password", Namespace: peer.Namespace},
+		Type:       corev1.SecretTypeBasicAuth,
+		Data:       map[string][]byte{peerPasswordKey: []byte(password)},
+	}
+	peer.Spec.Password = ""
+	peer.Spec.PasswordSecret = corev1.SecretReference{Name: secret.Name, Namespace: peer.Namespace}
+	if !quiet {
+		log.Printf("warning: Secret %s/%s holds the password of BGPPeer %s, encrypt or seal it before "+
+			"committing it", secret.Namespace, secret.Name, peer.Name)
+	}
+	return secret
+}
+
+// legacyPeerName returns the name of the BGPPeer of lp, derived from its address. Names that are already used, e.g.
+// by peers of the same address with different node selectors, get the suffix -2, -3, ...
+func legacyPeerName(lp legacyPeer, used map[string]bool) string {
+	base := "peer-" + strings.NewReplacer(".", "-", ":", "-").Replace(strings.ToLower(lp.PeerAddress))
+	name := base
+	for i := 2; used[name]; i++ {
+		name = fmt.Sprintf("%s-%d", base, i)
+	}
+	used[name] = true
+	return name
+}
+
+// parsePeerDuration parses a hold-time or keepalive-time of a legacy peer. An empty value is the zero duration.
+func parsePeerDuration(value string) (metav1.Duration, error) {
+	if value == "" {
+		return metav1.Duration{}, nil
+	}
+	d, err := time.ParseDuration(value)
+	return metav1.Duration{Duration: d}, err
+}
+
+// nodeSelectorsOf converts the node-selectors of a legacy peer to label selectors, see legacyNodeSelectors.
+func nodeSelectorsOf(selectors []legacyNodeSelector) []metav1.LabelSelector {
+	var result []metav1.LabelSelector
+	for _, selector := range selectors {
+		ls := metav1.LabelSelector{MatchLabels: selector.MatchLabels}
+		for _, expression := range selector.MatchExpressions {
+			ls.MatchExpressions = append(ls.MatchExpressions, metav1.LabelSelectorRequirement{
+				Key:      expression.Key,
+				Operator: metav1.LabelSelectorOperator(expression.Operator),
+				Values:   expression.Values,
+			})
+		}
+		result = append(result, ls)
+	}
+	return result
+}
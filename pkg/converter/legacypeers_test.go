@@ -0,0 +1,178 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	metallbv1beta2 "go.universe.tf/metallb/api/v1beta2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const legacyPeersConfig = `peers:
+- peer-address: 10.0.0.1
+  peer-asn: 64501
+  my-asn: 64500
+  password: s3cret
+  hold-time: 90s
+  node-selectors:
+  - match-labels:
+      rack: a
+- peer-address: 10.0.0.1
+  peer-asn: 64501
+  my-asn: 64500
+  node-selectors:
+  - match-expressions:
+    - key: rack
+      operator: In
+      values: [b]
+- peer-address: 2001:DB8::1
+  peer-asn: 64502
+  my-asn: 64500
+  ebgp-multihop: true
+`
+
+func TestConvertPeers(t *testing.T) {
+	tcs := map[string]struct {
+		mode                string
+		config              string
+		expectedPeers       []metallbv1beta2.BGPPeerSpec
+		expectedNames       []string
+		expectedSecrets     []string
+		expectedErrorString string
+	}{
+		"secret": {
+			mode:   PeerPasswordsSecret,
+			config: legacyPeersConfig,
+			expectedPeers: []metallbv1beta2.BGPPeerSpec{
+				{
+					MyASN: 64500, ASN: 64501, Address: "10.0.0.1",
+					HoldTime:       metav1.Duration{Duration: 90 * time.Second},
+					NodeSelectors:  []metav1.LabelSelector{{MatchLabels: map[string]string{"rack": "a"}}},
+					PasswordSecret: corev1.SecretReference{Name: "peer-10-0-0-1-bgp-password", Namespace: "ns"},
+				},
+				{
+					MyASN: 64500, ASN: 64501, Address: "10.0.0.1",
+					NodeSelectors: []metav1.LabelSelector{{MatchExpressions: []metav1.LabelSelectorRequirement{
+						{Key: "rack", Operator: metav1.LabelSelectorOpIn, Values: []string{"b"}},
+					}}},
+				},
+				{MyASN: 64500, ASN: 64502, Address: "2001:DB8::1", EBGPMultiHop: true},
+			},
+			expectedNames:   []string{"peer-10-0-0-1", "peer-10-0-0-1-2", "peer-2001-db8--1"},
+			expectedSecrets: []string{"peer-10-0-0-1-bgp-password"},
+		},
+		"inline": {
+			mode:   PeerPasswordsInline,
+			config: "peers:\n- peer-address: 10.0.0.1\n  peer-asn: 64501\n  my-asn: 64500\n  password: s3cret\n",
+			expectedPeers: []metallbv1beta2.BGPPeerSpec{
+				{MyASN: 64500, ASN: 64501, Address: "10.0.0.1", Password: "s3cret"},
+			},
+			expectedNames: []string{"peer-10-0-0-1"},
+		},
+		"no peers": {
+			mode:   PeerPasswordsSecret,
+			config: "address-pools: []\n",
+		},
+		"invalid keepalive-time": {
+			mode:                PeerPasswordsSecret,
+			config:              "peers:\n- peer-address: 10.0.0.1\n  keepalive-time: often\n",
+			expectedErrorString: "invalid keepalive-time of peer 10.0.0.1",
+		},
+		"unsupported mode": {
+			mode:                "plaintext",
+			expectedErrorString: `unsupported peer passwords mode "plaintext", must be one of: secret, inline`,
+		},
+	}
+	for desc, tc := range tcs {
+		err := (Options{PeerPasswords: tc.mode}).Validate()
+		var groups [][]runtime.Object
+		if err == nil {
+			var legacyObjects *LegacyObjects
+			legacyObjects, err = ParseLegacyConfig([]byte(tc.config), "ns")
+			if err != nil {
+				t.Fatalf("TestConvertPeers(%s): cannot parse config, err: %q", desc, err)
+			}
+			groups, err = legacyObjects.convertPeers("ns", tc.mode)
+		}
+		if tc.expectedErrorString != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.expectedErrorString) {
+				t.Fatalf("TestConvertPeers(%s): expected error %q but got %v", desc, tc.expectedErrorString, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("TestConvertPeers(%s): unexpected error, err: %q", desc, err)
+		}
+		var specs []metallbv1beta2.BGPPeerSpec
+		var names, secrets []string
+		for _, group := range groups {
+			for _, obj := range group {
+				switch o := obj.(type) {
+				case *metallbv1beta2.BGPPeer:
+					specs = append(specs, o.Spec)
+					names = append(names, o.Name)
+				case *corev1.Secret:
+					if o.Type != corev1.SecretTypeBasicAuth || string(o.Data[peerPasswordKey]) != "s3cret" {
+						t.Fatalf("TestConvertPeers(%s): unexpected Secret %+v", desc, o)
+					}
+					secrets = append(secrets, o.Name)
+				default:
+					t.Fatalf("TestConvertPeers(%s): unexpected object %T", desc, obj)
+				}
+			}
+		}
+		if !reflect.DeepEqual(specs, tc.expectedPeers) {
+			t.Fatalf("TestConvertPeers(%s): expected peers %+v but got %+v", desc, tc.expectedPeers, specs)
+		}
+		if !reflect.DeepEqual(names, tc.expectedNames) || !reflect.DeepEqual(secrets, tc.expectedSecrets) {
+			t.Fatalf("TestConvertPeers(%s): expected names %v and Secrets %v but got %v and %v", desc,
+				tc.expectedNames, tc.expectedSecrets, names, secrets)
+		}
+	}
+}
+
+func TestOfflineMigrationFromConfigMapPeers(t *testing.T) {
+	var scheme = runtime.NewScheme()
+	if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestOfflineMigrationFromConfigMapPeers: error adding to scheme, err: %q", err)
+	}
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestOfflineMigrationFromConfigMapPeers: error adding to scheme, err: %q", err)
+	}
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "config", Namespace: "metallb-system"},
+		Data:       map[string]string{LegacyConfigMapKey: legacyPeersConfig},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(cm).Build()
+
+	stdout = bytes.NewBuffer([]byte{})
+	err := OfflineMigration(context.TODO(), c, scheme, "", "", OutputFormatYAML, OfflineMigrationOptions{
+		FromConfigMap: types.NamespacedName{Namespace: "metallb-system", Name: "config"},
+	})
+	if err != nil {
+		t.Fatalf("TestOfflineMigrationFromConfigMapPeers: unexpected error, err: %q", err)
+	}
+	output := stdout.(*bytes.Buffer).String()
+	for _, expected := range []string{
+		"apiVersion: metallb.io/v1beta2\nkind: BGPPeer\n",
+		"  passwordSecret:\n    name: peer-10-0-0-1-bgp-password\n    namespace: metallb-system\n",
+		"apiVersion: v1\ndata:\n  password: czNjcmV0\nkind: Secret\n",
+		"type: kubernetes.io/basic-auth\n",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Fatalf("TestOfflineMigrationFromConfigMapPeers: expected %q in output but got:\n%s", expected, output)
+		}
+	}
+	if strings.Contains(output, "password: s3cret") {
+		t.Fatalf("TestOfflineMigrationFromConfigMapPeers: expected no inline password but got:\n%s", output)
+	}
+}
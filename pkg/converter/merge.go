@@ -0,0 +1,161 @@
+package converter
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// MergedL2AdvertisementName is the name of the L2Advertisement of a namespace that references all of its layer2
+// pools, see SetMergeL2.
+const MergedL2AdvertisementName = "l2-advertisement"
+
+// addressSetKey returns a key that is equal for all pools of the same namespace with the same addresses and
+// auto-assign setting, regardless of address order, duplicates and textual form.
+func addressSetKey(namespace string, addresses []string, autoAssign *bool) string {
+	assign := "default"
+	if autoAssign != nil {
+		assign = fmt.Sprint(*autoAssign)
+	}
+	return fmt.Sprintf("%s|%s|%s", namespace, assign, strings.Join(normalizeAddressList(addresses), ","))
+}
+
+// IdenticalAddressPools returns groups of namespace/name identifiers of AddressPools that have identical address
+// sets. Only groups with more than one AddressPool are returned, in order of first appearance.
+func IdenticalAddressPools(l *LegacyObjects) [][]string {
+	var keys []string
+	groups := map[string][]string{}
+	for _, ap := range l.AddressPoolList.Items {
+		key := addressSetKey(ap.Namespace, ap.Spec.Addresses, ap.Spec.AutoAssign)
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], fmt.Sprintf("%s/%s", ap.Namespace, ap.Name))
+	}
+	var identical [][]string
+	for _, key := range keys {
+		if len(groups[key]) > 1 {
+			identical = append(identical, groups[key])
+		}
+	}
+	return identical
+}
+
+// mergePools keeps the first IPAddressPool of every group of pools with identical address sets, drops the others
+// and points the advertisements of the dropped pools to the kept pool.
+func (c *CurrentObjects) mergePools() {
+	kept := map[string]string{}
+	renamed := map[string]string{}
+	var items []metallbv1beta1.IPAddressPool
+	for _, iap := range c.IPAddressPoolList.Items {
+		key := addressSetKey(iap.Namespace, iap.Spec.Addresses, iap.Spec.AutoAssign)
+		if name, ok := kept[key]; ok {
+			renamed[fmt.Sprintf("%s/%s", iap.Namespace, iap.Name)] = name
+			continue
+		}
+		kept[key] = iap.Name
+		items = append(items, iap)
+	}
+	c.IPAddressPoolList.Items = items
+	rename := func(namespace string, pools []string) {
+		for i, pool := range pools {
+			if name, ok := renamed[fmt.Sprintf("%s/%s", namespace, pool)]; ok {
+				pools[i] = name
+			}
+		}
+	}
+	for i := range c.L2AdvertisementList.Items {
+		l2a := &c.L2AdvertisementList.Items[i]
+		rename(l2a.Namespace, l2a.Spec.IPAddressPools)
+	}
+	for i := range c.BGPAdvertisementList.Items {
+		ba := &c.BGPAdvertisementList.Items[i]
+		rename(ba.Namespace, ba.Spec.IPAddressPools)
+	}
+}
+
+// advertisementKey returns a key that is equal for all advertisements of the same namespace with the same metadata
+// and the same spec apart from their IPAddressPools. spec must not reference any IPAddressPools. The provenance
+// metadata is disregarded, see Options.Provenance.
+func advertisementKey(meta metav1.ObjectMeta, spec interface{}) string {
+	meta = withoutProvenance(meta)
+	data, _ := json.Marshal([]interface{}{meta.Namespace, meta.Labels, meta.Annotations, meta.OwnerReferences, spec})
+	return string(data)
+}
+
+// mergeAdvertisements merges the L2Advertisements and the BGPAdvertisements of the same namespace that only differ
+// in their IPAddressPools into the first of them, which references the pools of all merged advertisements.
+func (c *CurrentObjects) mergeAdvertisements() {
+	merge := func(pools *[]string, others []string) {
+		for _, pool := range others {
+			if !contains(*pools, pool) {
+				*pools = append(*pools, pool)
+			}
+		}
+	}
+	l2Kept := map[string]int{}
+	var l2as []metallbv1beta1.L2Advertisement
+	for _, l2a := range c.L2AdvertisementList.Items {
+		spec := l2a.Spec
+		spec.IPAddressPools = nil
+		key := advertisementKey(l2a.ObjectMeta, spec)
+		if i, ok := l2Kept[key]; ok {
+			merge(&l2as[i].Spec.IPAddressPools, l2a.Spec.IPAddressPools)
+			continue
+		}
+		l2Kept[key] = len(l2as)
+		l2as = append(l2as, l2a)
+	}
+	c.L2AdvertisementList.Items = l2as
+	bgpKept := map[string]int{}
+	var bas []metallbv1beta1.BGPAdvertisement
+	for _, ba := range c.BGPAdvertisementList.Items {
+		spec := ba.Spec
+		spec.IPAddressPools = nil
+		key := advertisementKey(ba.ObjectMeta, spec)
+		if i, ok := bgpKept[key]; ok {
+			merge(&bas[i].Spec.IPAddressPools, ba.Spec.IPAddressPools)
+			continue
+		}
+		bgpKept[key] = len(bas)
+		bas = append(bas, ba)
+	}
+	c.BGPAdvertisementList.Items = bas
+}
+
+// mergeL2Advertisements merges the L2Advertisements of every namespace into one named MergedL2AdvertisementName that
+// references all of their pools and keeps the metadata of the first. Advertisements that restrict the interfaces or
+// nodes that announce their pools cannot be merged without changing how their pools are announced. They are kept as
+// they are and a warning is logged.
+func (c *CurrentObjects) mergeL2Advertisements() {
+	merged := map[string]int{}
+	var l2as []metallbv1beta1.L2Advertisement
+	for _, l2a := range c.L2AdvertisementList.Items {
+		if len(l2a.Spec.Interfaces) > 0 || len(l2a.Spec.NodeSelectors) > 0 {
+			if !quiet {
+				log.Printf("warning: L2Advertisement %s/%s restricts its interfaces or nodes and is not merged",
+					l2a.Namespace, l2a.Name)
+			}
+			l2as = append(l2as, l2a)
+			continue
+		}
+		i, ok := merged[l2a.Namespace]
+		if !ok {
+			merged[l2a.Namespace] = len(l2as)
+			l2a.Name = MergedL2AdvertisementName
+			l2a.Spec.IPAddressPools = append([]string{}, l2a.Spec.IPAddressPools...)
+			l2as = append(l2as, l2a)
+			continue
+		}
+		for _, pool := range l2a.Spec.IPAddressPools {
+			if !contains(l2as[i].Spec.IPAddressPools, pool) {
+				l2as[i].Spec.IPAddressPools = append(l2as[i].Spec.IPAddressPools, pool)
+			}
+		}
+	}
+	c.L2AdvertisementList.Items = l2as
+}
@@ -0,0 +1,140 @@
+package converter
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/andreaskaris/metallb-converter/pkg/features"
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+)
+
+func TestIdenticalAddressPools(t *testing.T) {
+	differentAddresses := *validAddressPools0[2].DeepCopy()
+	differentAddresses.Spec.Addresses = []string{"10.0.0.0/24"}
+	otherNamespace := *validAddressPools0[1].DeepCopy()
+	otherNamespace.Namespace = "other"
+
+	tcs := map[string]struct {
+		addressPools []metallbv1beta1.AddressPool
+		expected     [][]string
+	}{
+		"all identical": {
+			addressPools: validAddressPools0,
+			expected:     [][]string{{"metallb-system/ap-l2", "metallb-system/ap-bgp", "metallb-system/ap-bgp2"}},
+		},
+		"different addresses and namespaces": {
+			addressPools: []metallbv1beta1.AddressPool{validAddressPools0[0], otherNamespace, differentAddresses},
+		},
+	}
+	for desc, tc := range tcs {
+		legacyObjects := &LegacyObjects{AddressPoolList: &metallbv1beta1.AddressPoolList{Items: tc.addressPools}}
+		got := IdenticalAddressPools(legacyObjects)
+		if !reflect.DeepEqual(got, tc.expected) {
+			t.Fatalf("TestIdenticalAddressPools(%s): expected %v but got %v", desc, tc.expected, got)
+		}
+	}
+}
+
+func TestMergeIdenticalPools(t *testing.T) {
+	legacyObjects := &LegacyObjects{AddressPoolList: &metallbv1beta1.AddressPoolList{Items: validAddressPools0}}
+	currentObjects, err := legacyObjects.Convert(Options{MergeIdenticalPools: true})
+	if err != nil {
+		t.Fatalf("TestMergeIdenticalPools: unexpected error during conversion, err: %q", err)
+	}
+	if len(currentObjects.IPAddressPoolList.Items) != 1 || currentObjects.IPAddressPoolList.Items[0].Name != "ap-l2" {
+		t.Fatalf("TestMergeIdenticalPools: expected only IPAddressPool ap-l2 but got %v",
+			currentObjects.IPAddressPoolList.Items)
+	}
+	if len(currentObjects.L2AdvertisementList.Items) != 1 || len(currentObjects.BGPAdvertisementList.Items) != 3 {
+		t.Fatalf("TestMergeIdenticalPools: expected all advertisements to be kept")
+	}
+	for _, ba := range currentObjects.BGPAdvertisementList.Items {
+		if !reflect.DeepEqual(ba.Spec.IPAddressPools, []string{"ap-l2"}) {
+			t.Fatalf("TestMergeIdenticalPools: BGPAdvertisement %s references %v instead of the merged pool",
+				ba.Name, ba.Spec.IPAddressPools)
+		}
+	}
+	// The legacy objects must not be modified by the merge.
+	if validAddressPools0[1].Name != "ap-bgp" {
+		t.Fatalf("TestMergeIdenticalPools: legacy AddressPools were modified")
+	}
+}
+
+func TestMergeAdvertisements(t *testing.T) {
+	if err := features.Set("MergeAdvertisements=true"); err != nil {
+		t.Fatalf("TestMergeAdvertisements: cannot enable the feature gate, err: %q", err)
+	}
+	defer features.Set("")
+
+	var addressPools []metallbv1beta1.AddressPool
+	for _, name := range []string{"l2-a", "l2-b", "bgp-a", "bgp-b"} {
+		ap := *validAddressPools0[0].DeepCopy()
+		ap.Name = name
+		ap.Spec.Addresses = []string{"10.0." + fmt.Sprint(len(addressPools)) + ".0/24"}
+		if strings.HasPrefix(name, "bgp") {
+			ap.Spec.Protocol = ProtocolBGP
+		}
+		addressPools = append(addressPools, ap)
+	}
+	// Advertisements with a different spec are kept.
+	addressPools[3].Spec.BGPAdvertisements = []metallbv1beta1.LegacyBgpAdvertisement{{LocalPref: 100}}
+	legacyObjects := &LegacyObjects{AddressPoolList: &metallbv1beta1.AddressPoolList{Items: addressPools}}
+	currentObjects, err := legacyObjects.Convert(Options{})
+	if err != nil {
+		t.Fatalf("TestMergeAdvertisements: unexpected error during conversion, err: %q", err)
+	}
+	if len(currentObjects.IPAddressPoolList.Items) != 4 {
+		t.Fatalf("TestMergeAdvertisements: expected all IPAddressPools to be kept")
+	}
+	l2as := currentObjects.L2AdvertisementList.Items
+	if len(l2as) != 1 || l2as[0].Name != "l2-a-l2-advertisement" ||
+		!reflect.DeepEqual(l2as[0].Spec.IPAddressPools, []string{"l2-a", "l2-b"}) {
+		t.Fatalf("TestMergeAdvertisements: expected one L2Advertisement for all layer2 pools but got %v", l2as)
+	}
+	bas := currentObjects.BGPAdvertisementList.Items
+	if len(bas) != 2 || !reflect.DeepEqual(bas[0].Spec.IPAddressPools, []string{"bgp-a"}) ||
+		!reflect.DeepEqual(bas[1].Spec.IPAddressPools, []string{"bgp-b"}) {
+		t.Fatalf("TestMergeAdvertisements: expected the BGPAdvertisements with different specs to be kept but got %v",
+			bas)
+	}
+}
+
+func TestMergeL2Advertisements(t *testing.T) {
+	SetQuiet(true)
+	defer SetQuiet(false)
+
+	var addressPools []metallbv1beta1.AddressPool
+	for _, name := range []string{"l2-a", "l2-b", "bgp-a", "l2-eth1", "l2-c"} {
+		ap := *validAddressPools0[0].DeepCopy()
+		ap.Name = name
+		ap.Spec.Addresses = []string{"10.0." + fmt.Sprint(len(addressPools)) + ".0/24"}
+		if strings.HasPrefix(name, "bgp") {
+			ap.Spec.Protocol = ProtocolBGP
+		}
+		addressPools = append(addressPools, ap)
+	}
+	addressPools[3].Annotations = map[string]string{L2InterfacesAnnotation: "eth1"}
+	addressPools[4].Namespace = "other"
+	legacyObjects := &LegacyObjects{AddressPoolList: &metallbv1beta1.AddressPoolList{Items: addressPools}}
+	currentObjects, err := legacyObjects.Convert(Options{MergeL2: true})
+	if err != nil {
+		t.Fatalf("TestMergeL2Advertisements: unexpected error during conversion, err: %q", err)
+	}
+	var got []string
+	for _, l2a := range currentObjects.L2AdvertisementList.Items {
+		got = append(got, fmt.Sprintf("%s/%s=%s", l2a.Namespace, l2a.Name, strings.Join(l2a.Spec.IPAddressPools, ",")))
+	}
+	expected := []string{
+		"metallb-system/" + MergedL2AdvertisementName + "=l2-a,l2-b",
+		"metallb-system/l2-eth1-l2-advertisement=l2-eth1",
+		"other/" + MergedL2AdvertisementName + "=l2-c",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("TestMergeL2Advertisements: expected L2Advertisements %v but got %v", expected, got)
+	}
+	if len(currentObjects.BGPAdvertisementList.Items) != 1 {
+		t.Fatalf("TestMergeL2Advertisements: expected the BGPAdvertisement to be kept")
+	}
+}
@@ -0,0 +1,199 @@
+package converter
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"strings"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/yaml"
+)
+
+// MergeGroup combines all legacy AddressPools that match one of its name patterns or its label selector into a single
+// IPAddressPool called Name. Patterns use path.Match syntax and match the name of a pool, or <namespace>/<name> if they
+// contain a slash. Pools are only merged with pools of the same namespace.
+type MergeGroup struct {
+	Name     string                `json:"name"`
+	Pools    []string              `json:"pools,omitempty"`
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+}
+
+// MergePolicy is a list of MergeGroups. A pool that matches more than one group is merged into the first one.
+type MergePolicy []MergeGroup
+
+// LoadMergePolicy reads a MergePolicy from a YAML or JSON file.
+func LoadMergePolicy(filePath string) (MergePolicy, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read merge policy %q, err: %w", filePath, err)
+	}
+	policy := MergePolicy{}
+	if err := yaml.UnmarshalStrict(data, &policy); err != nil {
+		return nil, fmt.Errorf("cannot parse merge policy %q, err: %w", filePath, err)
+	}
+	names := map[string]bool{}
+	for _, group := range policy {
+		if group.Name == "" {
+			return nil, fmt.Errorf("merge policy %q contains a group without a name", filePath)
+		}
+		if names[group.Name] {
+			return nil, fmt.Errorf("merge policy %q contains group %q more than once", filePath, group.Name)
+		}
+		names[group.Name] = true
+		if len(group.Pools) == 0 && group.Selector == nil {
+			return nil, fmt.Errorf("merge policy %q: group %q neither has pools nor a selector", filePath, group.Name)
+		}
+		for _, pattern := range group.Pools {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("merge policy %q: group %q has an invalid pattern %q", filePath, group.Name,
+					pattern)
+			}
+		}
+		if group.Selector != nil {
+			if _, err := metav1.LabelSelectorAsSelector(group.Selector); err != nil {
+				return nil, fmt.Errorf("merge policy %q: group %q has an invalid selector, err: %w", filePath,
+					group.Name, err)
+			}
+		}
+	}
+	return policy, nil
+}
+
+// matches returns true if the AddressPool matches one of the patterns or the selector of the group.
+func (g MergeGroup) matches(ap metallbv1beta1.AddressPool) bool {
+	for _, pattern := range g.Pools {
+		subject := ap.Name
+		if strings.Contains(pattern, "/") {
+			subject = fmt.Sprintf("%s/%s", ap.Namespace, ap.Name)
+		}
+		if ok, _ := path.Match(pattern, subject); ok {
+			return true
+		}
+	}
+	if g.Selector == nil {
+		return false
+	}
+	selector, err := metav1.LabelSelectorAsSelector(g.Selector)
+	return err == nil && selector.Matches(labels.Set(ap.Labels))
+}
+
+// groupFor returns the name of the first group that the AddressPool matches, or "" if it matches none.
+func (p MergePolicy) groupFor(ap metallbv1beta1.AddressPool) string {
+	for _, group := range p {
+		if group.matches(ap) {
+			return group.Name
+		}
+	}
+	return ""
+}
+
+// mergeByPolicy merges the IPAddressPools of every group into one IPAddressPool named after the group. groups maps the
+// namespace/name of every pool that the policy matches to the name of its group. The merged pool keeps the metadata
+// of the first pool of the group and the union of the addresses of all pools. All pools of a group must agree on
+// autoAssign.
+func (c *CurrentObjects) mergeByPolicy(groups map[string]string) error {
+	merged := map[string]int{}
+	renamed := map[string]string{}
+	members := map[string][]string{}
+	var items []metallbv1beta1.IPAddressPool
+	for _, iap := range c.IPAddressPoolList.Items {
+		group, ok := groups[fmt.Sprintf("%s/%s", iap.Namespace, iap.Name)]
+		if !ok {
+			items = append(items, iap)
+			continue
+		}
+		renamed[fmt.Sprintf("%s/%s", iap.Namespace, iap.Name)] = group
+		key := fmt.Sprintf("%s/%s", iap.Namespace, group)
+		members[key] = append(members[key], iap.Name)
+		i, ok := merged[key]
+		if !ok {
+			merged[key] = len(items)
+			iap.Name = group
+			items = append(items, iap)
+			continue
+		}
+		target := &items[i]
+		if !equality.Semantic.DeepEqual(target.Spec.AutoAssign, iap.Spec.AutoAssign) {
+			return fmt.Errorf("cannot merge IPAddressPool %s/%s into %s, autoAssign differs", iap.Namespace,
+				iap.Name, key)
+		}
+		for _, address := range iap.Spec.Addresses {
+			if !containsAddress(target.Spec.Addresses, address) {
+				target.Spec.Addresses = append(target.Spec.Addresses, address)
+			}
+		}
+	}
+	for i, iap := range items {
+		key := fmt.Sprintf("%s/%s", iap.Namespace, iap.Name)
+		if j, ok := merged[key]; ok && i != j {
+			return fmt.Errorf("merge group %s collides with an IPAddressPool of the same name", key)
+		}
+	}
+	c.IPAddressPoolList.Items = items
+	if !quiet {
+		for _, iap := range items {
+			key := fmt.Sprintf("%s/%s", iap.Namespace, iap.Name)
+			if _, ok := merged[key]; ok {
+				log.Printf("IPAddressPools %s were merged into %s, update Services that request them with the %s "+
+					"annotation", strings.Join(members[key], ", "), key, ServiceAddressPoolAnnotation)
+			}
+		}
+	}
+
+	rename := func(namespace string, pools []string) []string {
+		var result []string
+		for _, pool := range pools {
+			if name, ok := renamed[fmt.Sprintf("%s/%s", namespace, pool)]; ok {
+				pool = name
+			}
+			if !contains(result, pool) {
+				result = append(result, pool)
+			}
+		}
+		return result
+	}
+	var l2Items []metallbv1beta1.L2Advertisement
+	for _, l2a := range c.L2AdvertisementList.Items {
+		l2a.Spec.IPAddressPools = rename(l2a.Namespace, l2a.Spec.IPAddressPools)
+		duplicate := false
+		for _, kept := range l2Items {
+			if kept.Namespace == l2a.Namespace && equality.Semantic.DeepEqual(kept.Spec, l2a.Spec) {
+				duplicate = true
+			}
+		}
+		if !duplicate {
+			l2Items = append(l2Items, l2a)
+		}
+	}
+	c.L2AdvertisementList.Items = l2Items
+	var bgpItems []metallbv1beta1.BGPAdvertisement
+	for _, ba := range c.BGPAdvertisementList.Items {
+		ba.Spec.IPAddressPools = rename(ba.Namespace, ba.Spec.IPAddressPools)
+		duplicate := false
+		for _, kept := range bgpItems {
+			if kept.Namespace == ba.Namespace && equality.Semantic.DeepEqual(kept.Spec, ba.Spec) {
+				duplicate = true
+			}
+		}
+		if !duplicate {
+			bgpItems = append(bgpItems, ba)
+		}
+	}
+	c.BGPAdvertisementList.Items = bgpItems
+	return nil
+}
+
+// containsAddress returns true if addresses contains address, compared in canonical form.
+func containsAddress(addresses []string, address string) bool {
+	for _, a := range addresses {
+		if canonicalAddress(a) == canonicalAddress(address) {
+			return true
+		}
+	}
+	return false
+}
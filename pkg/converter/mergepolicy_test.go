@@ -0,0 +1,177 @@
+package converter
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+)
+
+func TestLoadMergePolicy(t *testing.T) {
+	tcs := map[string]struct {
+		policy              string
+		expectedGroups      int
+		expectedErrorString string
+	}{
+		"valid policy": {
+			policy:         "- name: shared\n  pools:\n  - ap-*\n- name: edge\n  selector:\n    matchLabels:\n      a: b\n",
+			expectedGroups: 2,
+		},
+		"no name": {
+			policy:              "- pools:\n  - ap\n",
+			expectedErrorString: "group without a name",
+		},
+		"duplicate name": {
+			policy:              "- name: a\n  pools:\n  - ap\n- name: a\n  pools:\n  - ap2\n",
+			expectedErrorString: "contains group \"a\" more than once",
+		},
+		"no pools and no selector": {
+			policy:              "- name: a\n",
+			expectedErrorString: "neither has pools nor a selector",
+		},
+		"invalid pattern": {
+			policy:              "- name: a\n  pools:\n  - \"ap-[\"\n",
+			expectedErrorString: "invalid pattern",
+		},
+		"unknown field": {
+			policy:              "- name: a\n  pool: ap\n",
+			expectedErrorString: "cannot parse merge policy",
+		},
+	}
+	for desc, tc := range tcs {
+		policyFile := path.Join(t.TempDir(), "policy.yaml")
+		if err := os.WriteFile(policyFile, []byte(tc.policy), 0600); err != nil {
+			t.Fatalf("TestLoadMergePolicy(%s): cannot write policy, err: %q", desc, err)
+		}
+		policy, err := LoadMergePolicy(policyFile)
+		if tc.expectedErrorString != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.expectedErrorString) {
+				t.Fatalf("TestLoadMergePolicy(%s): expected error %q but got %v", desc, tc.expectedErrorString, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("TestLoadMergePolicy(%s): unexpected error, err: %q", desc, err)
+		}
+		if len(policy) != tc.expectedGroups {
+			t.Fatalf("TestLoadMergePolicy(%s): expected %d groups but got %v", desc, tc.expectedGroups, policy)
+		}
+	}
+}
+
+func TestMergeByPolicy(t *testing.T) {
+	pool := func(name, namespace, protocol string, labels map[string]string,
+		addresses ...string) metallbv1beta1.AddressPool {
+		return metallbv1beta1.AddressPool{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: labels},
+			Spec:       metallbv1beta1.AddressPoolSpec{Protocol: protocol, Addresses: addresses},
+		}
+	}
+	tcs := map[string]struct {
+		policy              MergePolicy
+		pools               []metallbv1beta1.AddressPool
+		expectedPools       string
+		expectedL2Pools     string
+		expectedBGPAds      string
+		expectedErrorString string
+	}{
+		"merge by pattern": {
+			policy: MergePolicy{{Name: "shared", Pools: []string{"ap-*"}}},
+			pools: []metallbv1beta1.AddressPool{
+				pool("ap-a", "metallb-system", ProtocolLayer2, nil, "10.0.0.0/24", "10.0.1.0/24"),
+				pool("ap-b", "metallb-system", ProtocolLayer2, nil, "10.0.1.0/24", "10.0.2.0/24"),
+				pool("other", "metallb-system", ProtocolLayer2, nil, "10.0.3.0/24"),
+			},
+			expectedPools:   "[shared:[10.0.0.0/24 10.0.1.0/24 10.0.2.0/24] other:[10.0.3.0/24]]",
+			expectedL2Pools: "[ap-a-l2-advertisement:[shared] other-l2-advertisement:[other]]",
+			expectedBGPAds:  "[]",
+		},
+		"merge by selector per namespace": {
+			policy: MergePolicy{{Name: "edge", Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{"tier": "edge"},
+			}}},
+			pools: []metallbv1beta1.AddressPool{
+				pool("a", "ns1", ProtocolBGP, map[string]string{"tier": "edge"}, "10.0.0.0/24"),
+				pool("b", "ns2", ProtocolBGP, map[string]string{"tier": "edge"}, "10.0.1.0/24"),
+				pool("c", "ns1", ProtocolBGP, map[string]string{"tier": "core"}, "10.0.2.0/24"),
+			},
+			expectedPools:   "[edge:[10.0.0.0/24] edge:[10.0.1.0/24] c:[10.0.2.0/24]]",
+			expectedL2Pools: "[]",
+			expectedBGPAds: "[a-bgp-advertisement-0:[edge]:0 b-bgp-advertisement-0:[edge]:0 " +
+				"c-bgp-advertisement-0:[c]:0]",
+		},
+		"different advertisements are kept": {
+			policy: MergePolicy{{Name: "shared", Pools: []string{"metallb-system/*"}}},
+			pools: []metallbv1beta1.AddressPool{
+				pool("a", "metallb-system", ProtocolBGP, nil, "10.0.0.0/24"),
+				func() metallbv1beta1.AddressPool {
+					ap := pool("b", "metallb-system", ProtocolBGP, nil, "10.0.1.0/24")
+					ap.Spec.BGPAdvertisements = []metallbv1beta1.LegacyBgpAdvertisement{{}, {LocalPref: 100}}
+					return ap
+				}(),
+			},
+			expectedPools:   "[shared:[10.0.0.0/24 10.0.1.0/24]]",
+			expectedL2Pools: "[]",
+			expectedBGPAds:  "[a-bgp-advertisement-0:[shared]:0 b-bgp-advertisement-1:[shared]:100]",
+		},
+		"autoAssign differs": {
+			policy: MergePolicy{{Name: "shared", Pools: []string{"*"}}},
+			pools: []metallbv1beta1.AddressPool{
+				pool("a", "metallb-system", ProtocolLayer2, nil, "10.0.0.0/24"),
+				func() metallbv1beta1.AddressPool {
+					ap := pool("b", "metallb-system", ProtocolLayer2, nil, "10.0.1.0/24")
+					ap.Spec.AutoAssign = pointer.Bool(false)
+					return ap
+				}(),
+			},
+			expectedErrorString: "autoAssign differs",
+		},
+		"name collision": {
+			policy: MergePolicy{{Name: "c", Pools: []string{"a", "b"}}},
+			pools: []metallbv1beta1.AddressPool{
+				pool("a", "metallb-system", ProtocolLayer2, nil, "10.0.0.0/24"),
+				pool("c", "metallb-system", ProtocolLayer2, nil, "10.0.1.0/24"),
+			},
+			expectedErrorString: "collides with an IPAddressPool of the same name",
+		},
+	}
+	for desc, tc := range tcs {
+		legacyObjects := &LegacyObjects{AddressPoolList: &metallbv1beta1.AddressPoolList{Items: tc.pools}}
+		currentObjects, err := legacyObjects.Convert(Options{MergePolicy: tc.policy})
+		if tc.expectedErrorString != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.expectedErrorString) {
+				t.Fatalf("TestMergeByPolicy(%s): expected error %q but got %v", desc, tc.expectedErrorString, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("TestMergeByPolicy(%s): unexpected error during conversion, err: %q", desc, err)
+		}
+		var pools, l2Pools, bgpAds []string
+		for _, iap := range currentObjects.IPAddressPoolList.Items {
+			pools = append(pools, fmt.Sprintf("%s:%v", iap.Name, iap.Spec.Addresses))
+		}
+		for _, l2a := range currentObjects.L2AdvertisementList.Items {
+			l2Pools = append(l2Pools, fmt.Sprintf("%s:%v", l2a.Name, l2a.Spec.IPAddressPools))
+		}
+		for _, ba := range currentObjects.BGPAdvertisementList.Items {
+			bgpAds = append(bgpAds, fmt.Sprintf("%s:%v:%d", ba.Name, ba.Spec.IPAddressPools, ba.Spec.LocalPref))
+		}
+		if fmt.Sprint(pools) != tc.expectedPools {
+			t.Fatalf("TestMergeByPolicy(%s): expected pools %s but got %v", desc, tc.expectedPools, pools)
+		}
+		if fmt.Sprint(l2Pools) != tc.expectedL2Pools {
+			t.Fatalf("TestMergeByPolicy(%s): expected L2Advertisements %s but got %v", desc, tc.expectedL2Pools,
+				l2Pools)
+		}
+		if fmt.Sprint(bgpAds) != tc.expectedBGPAds {
+			t.Fatalf("TestMergeByPolicy(%s): expected BGPAdvertisements %s but got %v", desc, tc.expectedBGPAds,
+				bgpAds)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// ParseLabels parses a list of key=value pairs into a map of labels. Keys and values must be valid label keys and
+// values.
+func ParseLabels(pairs []string) (map[string]string, error) {
+	return parseKeyValues(pairs, "label", validation.IsValidLabelValue)
+}
+
+// ParseAnnotations parses a list of key=value pairs into a map of annotations. Keys must be valid annotation keys,
+// values are arbitrary.
+func ParseAnnotations(pairs []string) (map[string]string, error) {
+	return parseKeyValues(pairs, "annotation", func(string) []string { return nil })
+}
+
+// parseKeyValues parses a list of key=value pairs into a map. Keys must be qualified names and values must pass
+// validateValue. Every key may only be given once.
+func parseKeyValues(pairs []string, kind string, validateValue func(string) []string) (map[string]string, error) {
+	if len(pairs) == 0 {
+		return nil, nil
+	}
+	result := map[string]string{}
+	for _, pair := range pairs {
+		key, value, found := strings.Cut(pair, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid %s %q, expected key=value", kind, pair)
+		}
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid %s key %q: %s", kind, key, strings.Join(errs, ", "))
+		}
+		if errs := validateValue(value); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid %s value %q: %s", kind, value, strings.Join(errs, ", "))
+		}
+		if _, ok := result[key]; ok {
+			return nil, fmt.Errorf("%s %q is given more than once", kind, key)
+		}
+		result[key] = value
+	}
+	return result, nil
+}
+
+// addExtraMetadata adds the extra labels and annotations to all objects.
+func (c *CurrentObjects) addExtraMetadata(extraLabels, extraAnnotations map[string]string) error {
+	for _, obj := range c.objects() {
+		accessor, err := meta.Accessor(obj)
+		if err != nil {
+			return fmt.Errorf("cannot add labels and annotations, err: %w", err)
+		}
+		accessor.SetLabels(mergeStringMaps(accessor.GetLabels(), extraLabels))
+		accessor.SetAnnotations(mergeStringMaps(accessor.GetAnnotations(), extraAnnotations))
+	}
+	return nil
+}
+
+// mergeStringMaps returns a copy of base with all entries of extra. It returns base unchanged if extra is empty.
+func mergeStringMaps(base, extra map[string]string) map[string]string {
+	if len(extra) == 0 {
+		return base
+	}
+	merged := map[string]string{}
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range extra {
+		merged[k] = v
+	}
+	return merged
+}
@@ -0,0 +1,101 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseKeyValues(t *testing.T) {
+	tcs := map[string]struct {
+		pairs               []string
+		annotations         bool
+		expected            map[string]string
+		expectedErrorString string
+	}{
+		"labels": {
+			pairs:    []string{"team=network", "example.com/cost-center=42", "empty="},
+			expected: map[string]string{"team": "network", "example.com/cost-center": "42", "empty": ""},
+		},
+		"annotation with arbitrary value": {
+			pairs:       []string{"example.com/note=migrated from a=b"},
+			annotations: true,
+			expected:    map[string]string{"example.com/note": "migrated from a=b"},
+		},
+		"invalid label value": {
+			pairs:               []string{"note=migrated from a"},
+			expectedErrorString: "invalid label value \"migrated from a\"",
+		},
+		"missing value": {
+			pairs:               []string{"team"},
+			expectedErrorString: "invalid label \"team\", expected key=value",
+		},
+		"invalid key": {
+			pairs:               []string{"-team=a"},
+			annotations:         true,
+			expectedErrorString: "invalid annotation key \"-team\"",
+		},
+		"duplicate key": {
+			pairs:               []string{"team=a", "team=b"},
+			expectedErrorString: "label \"team\" is given more than once",
+		},
+	}
+	for desc, tc := range tcs {
+		parse := ParseLabels
+		if tc.annotations {
+			parse = ParseAnnotations
+		}
+		result, err := parse(tc.pairs)
+		if tc.expectedErrorString != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.expectedErrorString) {
+				t.Fatalf("TestParseKeyValues(%s): expected error %q but got %v", desc, tc.expectedErrorString, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("TestParseKeyValues(%s): unexpected error, err: %q", desc, err)
+		}
+		if fmt.Sprint(result) != fmt.Sprint(tc.expected) {
+			t.Fatalf("TestParseKeyValues(%s): expected %v but got %v", desc, tc.expected, result)
+		}
+	}
+}
+
+func TestAddExtraMetadata(t *testing.T) {
+	ap := metallbv1beta1.AddressPool{
+		ObjectMeta: metav1.ObjectMeta{Name: "ap", Namespace: "metallb-system", Labels: map[string]string{
+			"team": "legacy",
+			"app":  "metallb",
+		}},
+		Spec: metallbv1beta1.AddressPoolSpec{Protocol: ProtocolBGP, Addresses: []string{"10.0.0.0/24"}},
+	}
+	legacyObjects := &LegacyObjects{AddressPoolList: &metallbv1beta1.AddressPoolList{
+		Items: []metallbv1beta1.AddressPool{ap},
+	}}
+	currentObjects, err := legacyObjects.Convert(Options{
+		ExtraLabels:      map[string]string{"team": "network"},
+		ExtraAnnotations: map[string]string{"example.com/owner": "a"},
+		OperatorCompat:   true,
+	})
+	if err != nil {
+		t.Fatalf("TestAddExtraMetadata: unexpected error during conversion, err: %q", err)
+	}
+	objects := currentObjects.objects()
+	if len(objects) != 2 {
+		t.Fatalf("TestAddExtraMetadata: expected 2 objects but got %d", len(objects))
+	}
+	for _, obj := range objects {
+		objectMeta := obj.(metav1.Object)
+		labels, annotations := fmt.Sprint(objectMeta.GetLabels()), fmt.Sprint(objectMeta.GetAnnotations())
+		if labels != "map[app:metallb team:network]" || annotations != "map[example.com/owner:a]" {
+			t.Fatalf("TestAddExtraMetadata: unexpected labels %s or annotations %s of %s", labels, annotations,
+				objectMeta.GetName())
+		}
+	}
+	if ap.Labels["team"] != "legacy" {
+		t.Fatalf("TestAddExtraMetadata: the labels of the legacy AddressPool were modified")
+	}
+}
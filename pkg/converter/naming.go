@@ -0,0 +1,36 @@
+package converter
+
+import (
+	"fmt"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+)
+
+const (
+	// AdvertisementNamingIndex names BGPAdvertisements after the position of their legacy bgpAdvertisement, e.g.
+	// pool-bgp-advertisement-0.
+	AdvertisementNamingIndex = "index"
+	// AdvertisementNamingHash names BGPAdvertisements after a hash of the spec of their legacy bgpAdvertisement, e.g.
+	// pool-bgp-advertisement-1a2b3c4d, so that reordering the legacy bgpAdvertisements does not rename them.
+	AdvertisementNamingHash = "hash"
+)
+
+// SupportedAdvertisementNamings lists all naming modes that can be set as Options.AdvertisementNaming.
+var SupportedAdvertisementNamings = []string{AdvertisementNamingIndex, AdvertisementNamingHash}
+
+// bgpAdvertisementName returns the name of the BGPAdvertisement of the i-th legacy advertisement of the IPAddressPool
+// poolName. With AdvertisementNamingHash, advertisements with identical specs get a -2, -3, ... suffix in order of
+// appearance; used holds the names that were already given to advertisements of the pool.
+func (o Options) bgpAdvertisementName(poolName string, i int, advertisement metallbv1beta1.LegacyBgpAdvertisement,
+	used map[string]bool) string {
+	if o.AdvertisementNaming != AdvertisementNamingHash {
+		return fmt.Sprintf("%s-bgp-advertisement-%d", poolName, i)
+	}
+	base := fmt.Sprintf("%s-bgp-advertisement-%s", poolName, specHash(advertisement)[:8])
+	name := base
+	for n := 2; used[name]; n++ {
+		name = fmt.Sprintf("%s-%d", base, n)
+	}
+	used[name] = true
+	return name
+}
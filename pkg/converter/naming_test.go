@@ -0,0 +1,99 @@
+package converter
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestAdvertisementNaming(t *testing.T) {
+	localPref := metallbv1beta1.LegacyBgpAdvertisement{LocalPref: 100}
+	community := metallbv1beta1.LegacyBgpAdvertisement{Communities: []string{"65535:65282"}}
+	tcs := map[string]struct {
+		naming              string
+		advertisements      []metallbv1beta1.LegacyBgpAdvertisement
+		expectedNames       []string
+		expectedErrorString string
+	}{
+		"index": {
+			naming:         AdvertisementNamingIndex,
+			advertisements: []metallbv1beta1.LegacyBgpAdvertisement{localPref, community},
+			expectedNames:  []string{"ap-bgp-advertisement-0", "ap-bgp-advertisement-1"},
+		},
+		"index reordered": {
+			naming:         AdvertisementNamingIndex,
+			advertisements: []metallbv1beta1.LegacyBgpAdvertisement{community, localPref},
+			expectedNames:  []string{"ap-bgp-advertisement-0", "ap-bgp-advertisement-1"},
+		},
+		"hash": {
+			naming:         AdvertisementNamingHash,
+			advertisements: []metallbv1beta1.LegacyBgpAdvertisement{localPref, community},
+			expectedNames: []string{
+				"ap-bgp-advertisement-" + specHash(localPref)[:8],
+				"ap-bgp-advertisement-" + specHash(community)[:8],
+			},
+		},
+		"hash reordered": {
+			naming:         AdvertisementNamingHash,
+			advertisements: []metallbv1beta1.LegacyBgpAdvertisement{community, localPref},
+			expectedNames: []string{
+				"ap-bgp-advertisement-" + specHash(community)[:8],
+				"ap-bgp-advertisement-" + specHash(localPref)[:8],
+			},
+		},
+		"hash of identical specs": {
+			naming:         AdvertisementNamingHash,
+			advertisements: []metallbv1beta1.LegacyBgpAdvertisement{localPref, localPref},
+			expectedNames: []string{
+				"ap-bgp-advertisement-" + specHash(localPref)[:8],
+				"ap-bgp-advertisement-" + specHash(localPref)[:8] + "-2",
+			},
+		},
+		"hash without advertisements": {
+			naming:        AdvertisementNamingHash,
+			expectedNames: []string{"ap-bgp-advertisement-" + specHash(metallbv1beta1.LegacyBgpAdvertisement{})[:8]},
+		},
+		"unsupported": {
+			naming:              "random",
+			expectedErrorString: "unsupported advertisement naming \"random\", must be one of: index, hash",
+		},
+	}
+	for desc, tc := range tcs {
+		opts := Options{AdvertisementNaming: tc.naming}
+		err := opts.Validate()
+		if tc.expectedErrorString != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.expectedErrorString) {
+				t.Fatalf("TestAdvertisementNaming(%s): expected error %q but got %v", desc, tc.expectedErrorString,
+					err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("TestAdvertisementNaming(%s): unexpected error, err: %q", desc, err)
+		}
+		l := &LegacyObjects{AddressPoolList: &metallbv1beta1.AddressPoolList{
+			Items: []metallbv1beta1.AddressPool{{
+				ObjectMeta: metav1.ObjectMeta{Name: "ap", Namespace: "metallb-system"},
+				Spec: metallbv1beta1.AddressPoolSpec{
+					Protocol:          ProtocolBGP,
+					Addresses:         []string{"192.168.0.0/24"},
+					BGPAdvertisements: tc.advertisements,
+				},
+			}},
+		}}
+		c, err := l.Convert(opts)
+		if err != nil {
+			t.Fatalf("TestAdvertisementNaming(%s): unexpected error, err: %q", desc, err)
+		}
+		var names []string
+		for _, ba := range c.BGPAdvertisementList.Items {
+			names = append(names, ba.Name)
+		}
+		if !reflect.DeepEqual(names, tc.expectedNames) {
+			t.Fatalf("TestAdvertisementNaming(%s): expected names %v but got %v", desc, tc.expectedNames, names)
+		}
+	}
+}
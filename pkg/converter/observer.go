@@ -0,0 +1,34 @@
+package converter
+
+import "time"
+
+// ConversionObserver is notified of every conversion of an offline or online migration with the number of legacy
+// AddressPools and the number of resulting objects per kind. Online migrations notify it once per migrated pool.
+type ConversionObserver func(addressPools int, objects map[string]int)
+
+// observeConversion notifies the ConversionObserver of the options about the conversion of l into c.
+func (o Options) observeConversion(l *LegacyObjects, c *CurrentObjects) {
+	if o.ConversionObserver == nil {
+		return
+	}
+	o.ConversionObserver(len(l.AddressPoolList.Items), map[string]int{
+		"IPAddressPool":    len(c.IPAddressPoolList.Items),
+		"L2Advertisement":  len(c.L2AdvertisementList.Items),
+		"BGPAdvertisement": len(c.BGPAdvertisementList.Items),
+		"Community":        len(c.CommunityList.Items),
+	})
+}
+
+// MigrationObserver is notified of the online migration of every legacy AddressPool with its namespace and name, the
+// duration of the migration and its error, or nil if it succeeded. AddressPools that are skipped, or that are not
+// started because the migration is stopped, are not notified.
+type MigrationObserver func(namespace, name string, duration time.Duration, err error)
+
+// observeMigration notifies the MigrationObserver of the options about the migration of the AddressPool
+// namespace/name.
+func (o Options) observeMigration(namespace, name string, duration time.Duration, err error) {
+	if o.MigrationObserver == nil {
+		return
+	}
+	o.MigrationObserver(namespace, name, duration, err)
+}
@@ -0,0 +1,78 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestObserveConversion(t *testing.T) {
+	var observedPools int
+	observedObjects := map[string]int{}
+	opts := Options{ConversionObserver: func(addressPools int, objects map[string]int) {
+		observedPools += addressPools
+		for kind, count := range objects {
+			observedObjects[kind] += count
+		}
+	}}
+
+	legacyObjects := &LegacyObjects{AddressPoolList: &metallbv1beta1.AddressPoolList{Items: validAddressPools0}}
+	currentObjects, err := legacyObjects.Convert(opts)
+	if err != nil {
+		t.Fatalf("TestObserveConversion: unexpected error during conversion, err: %q", err)
+	}
+	opts.observeConversion(legacyObjects, currentObjects)
+	if observedPools != len(validAddressPools0) || observedObjects["IPAddressPool"] != len(validAddressPools0) ||
+		observedObjects["L2Advertisement"] != 1 || observedObjects["BGPAdvertisement"] != 3 {
+		t.Fatalf("TestObserveConversion: unexpected observation of %d AddressPools and objects %v", observedPools,
+			observedObjects)
+	}
+}
+
+func TestObserveMigration(t *testing.T) {
+	var scheme = runtime.NewScheme()
+	if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestObserveMigration: error adding to scheme, err: %q", err)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+	for _, ap := range validAddressPools0 {
+		if err := c.Create(context.TODO(), ap.DeepCopy()); err != nil {
+			t.Fatalf("TestObserveMigration: error building fake client, err: %q", err)
+		}
+	}
+	var migrated, failed []string
+	failurePoints, err := ParseFailurePoints(FailurePointBeforeCreate + ":2")
+	if err != nil {
+		t.Fatalf("TestObserveMigration: cannot set failure points, err: %q", err)
+	}
+	observer := func(namespace, name string, duration time.Duration, err error) {
+		if duration <= 0 {
+			t.Fatalf("TestObserveMigration: unexpected duration %s of AddressPool %s/%s", duration, namespace, name)
+		}
+		if err != nil {
+			failed = append(failed, name)
+			return
+		}
+		migrated = append(migrated, name)
+	}
+	SetQuiet(true)
+	defer SetQuiet(false)
+	stdout = bytes.NewBuffer([]byte{})
+
+	err = OnlineMigration(context.TODO(), c, scheme, t.TempDir(), false, OnlineMigrationOptions{
+		Options: Options{FailurePoints: failurePoints, MigrationObserver: observer},
+	})
+	if !errors.Is(err, ErrInjectedFailure) {
+		t.Fatalf("TestObserveMigration: expected the injected failure but got %v", err)
+	}
+	if len(migrated) != 1 || len(failed) != 1 {
+		t.Fatalf("TestObserveMigration: expected 1 migrated and 1 failed AddressPool but got %v and %v", migrated,
+			failed)
+	}
+}
@@ -0,0 +1,150 @@
+package converter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// OperationBackup writes the backup of the legacy AddressPools.
+	OperationBackup = "backup"
+	// OperationDelete deletes the legacy AddressPools, or removes the resources of a rejected or rolled back AddressPool.
+	OperationDelete = "delete"
+	// OperationMark marks the legacy AddressPools that a migration keeps as migrated. It is an update of the
+	// AddressPools, whose conflicts and latency differ from the ones of their deletion.
+	OperationMark = "mark"
+	// OperationCreate creates the converted resources.
+	OperationCreate = "create"
+	// OperationVerify verifies the converted resources in the API before the finalization of a migration.
+	OperationVerify = "verify"
+)
+
+// SupportedOperations lists the operations that an OperationPolicies file may configure.
+var SupportedOperations = []string{OperationBackup, OperationDelete, OperationMark, OperationCreate, OperationVerify}
+
+// OperationPolicy configures how an operation of a migration is retried. An operation is attempted up to 1+Retries
+// times, as long as its attempts fail transiently: the API server or the connection to it failed, an API request ran
+// into a conflict, or the attempt ran into its Timeout. The wait before the n-th retry is Backoff*2^(n-1). Timeout
+// limits every attempt, i.e. its API requests or the write of the backup, 0 means no limit.
+type OperationPolicy struct {
+	Retries int             `json:"retries,omitempty"`
+	Backoff metav1.Duration `json:"backoff,omitempty"`
+	Timeout metav1.Duration `json:"timeout,omitempty"`
+}
+
+// OperationPolicies maps operations to their OperationPolicy. Operations without a policy are attempted once,
+// without a timeout.
+type OperationPolicies map[string]OperationPolicy
+
+// LoadOperationPolicies reads OperationPolicies from a YAML or JSON file, e.g.:
+//
+//	delete:
+//	  retries: 3
+//	  backoff: 2s
+//	  timeout: 30s
+func LoadOperationPolicies(filePath string) (OperationPolicies, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read operation policy %q, err: %w", filePath, err)
+	}
+	policies := OperationPolicies{}
+	if err := yaml.UnmarshalStrict(data, &policies); err != nil {
+		return nil, fmt.Errorf("cannot parse operation policy %q, err: %w", filePath, err)
+	}
+	var operations []string
+	for operation := range policies {
+		operations = append(operations, operation)
+	}
+	sort.Strings(operations)
+	for _, operation := range operations {
+		policy := policies[operation]
+		if !contains(SupportedOperations, operation) {
+			return nil, fmt.Errorf("operation policy %q: unsupported operation %q, must be one of: %s", filePath,
+				operation, strings.Join(SupportedOperations, ", "))
+		}
+		if policy.Retries < 0 || policy.Backoff.Duration < 0 || policy.Timeout.Duration < 0 {
+			return nil, fmt.Errorf("operation policy %q: retries, backoff and timeout of operation %q must not "+
+				"be negative", filePath, operation)
+		}
+	}
+	return policies, nil
+}
+
+// operationRetriesKey marks the context of an attempt of an operation whose policy retries it. The API requests of
+// such an attempt are not retried by a RetryPolicy as well, so that the two retries do not multiply.
+type operationRetriesKey struct{}
+
+// runOperation runs fn according to the policy of operation in policies. fn must use the context and the client that
+// it is passed, which bound all API requests by the timeout of the policy. Only attempts that fail transiently are
+// retried, see OperationPolicy. On retries, objects that a previous attempt already created are accepted. If the policy
+// retries the operation, it replaces the retries of its API requests.
+func runOperation(ctx context.Context, cl client.Client, policies OperationPolicies, operation string,
+	fn func(context.Context, client.Client) error) error {
+	policy := policies[operation]
+	var err error
+	attempt := 0
+	for ; attempt <= policy.Retries; attempt++ {
+		if attempt > 0 {
+			backoff := policy.Backoff.Duration << (attempt - 1)
+			if !quiet {
+				log.Printf("warning: operation %s failed, retrying it in %s (%d/%d), err: %v", operation, backoff,
+					attempt, policy.Retries, err)
+			}
+			timer := time.NewTimer(backoff)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return fmt.Errorf("%s interrupted, last err: %w", operation, err)
+			case <-timer.C:
+			}
+		}
+		attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+		if policy.Timeout.Duration > 0 {
+			attemptCtx, cancel = context.WithTimeout(ctx, policy.Timeout.Duration)
+		}
+		if policy.Retries > 0 {
+			attemptCtx = context.WithValue(attemptCtx, operationRetriesKey{}, true)
+		}
+		err = fn(attemptCtx, &operationClient{Client: cl, retry: attempt > 0})
+		cancel()
+		if err == nil {
+			return nil
+		}
+		if !isTransientAPIError(err, true) && !(errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil) {
+			break
+		}
+	}
+	if attempt > policy.Retries {
+		attempt = policy.Retries
+	}
+	if attempt > 0 {
+		return fmt.Errorf("%s failed after %d attempts, err: %w", operation, attempt+1, err)
+	}
+	return err
+}
+
+// operationClient accepts on retries of an operation that an object to create already exists, because a previous
+// attempt may have created it before it failed.
+type operationClient struct {
+	client.Client
+	retry bool
+}
+
+func (oc *operationClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	err := oc.Client.Create(ctx, obj, opts...)
+	if oc.retry && apierrors.IsAlreadyExists(err) {
+		return nil
+	}
+	return err
+}
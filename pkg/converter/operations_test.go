@@ -0,0 +1,232 @@
+package converter
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestLoadOperationPolicies(t *testing.T) {
+	tcs := map[string]struct {
+		policy              string
+		expectedErrorString string
+	}{
+		"valid policy": {
+			policy: "delete:\n  retries: 3\n  backoff: 2s\n  timeout: 30s\nmark:\n  retries: 2\nverify:\n  retries: 5\n",
+		},
+		"unsupported operation": {
+			policy:              "restore:\n  retries: 3\n",
+			expectedErrorString: "unsupported operation \"restore\"",
+		},
+		"negative retries": {
+			policy:              "create:\n  retries: -1\n",
+			expectedErrorString: "must not be negative",
+		},
+		"invalid duration": {
+			policy:              "create:\n  timeout: soon\n",
+			expectedErrorString: "cannot parse operation policy",
+		},
+	}
+	for desc, tc := range tcs {
+		policyFile := path.Join(t.TempDir(), "policy.yaml")
+		if err := os.WriteFile(policyFile, []byte(tc.policy), 0600); err != nil {
+			t.Fatalf("TestLoadOperationPolicies(%s): cannot write policy, err: %q", desc, err)
+		}
+		policies, err := LoadOperationPolicies(policyFile)
+		if tc.expectedErrorString != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.expectedErrorString) {
+				t.Fatalf("TestLoadOperationPolicies(%s): expected error %q but got %v", desc, tc.expectedErrorString,
+					err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("TestLoadOperationPolicies(%s): unexpected error, err: %q", desc, err)
+		}
+		if policies[OperationDelete].Timeout.Duration != 30*time.Second {
+			t.Fatalf("TestLoadOperationPolicies(%s): unexpected policies %v", desc, policies)
+		}
+	}
+}
+
+func TestRunOperation(t *testing.T) {
+	errFlaky := apierrors.NewServiceUnavailable("flaky")
+	errInvalid := apierrors.NewBadRequest("invalid")
+	tcs := map[string]struct {
+		policy OperationPolicy
+		// errs are the errors of the attempts, the attempts after them succeed.
+		errs                []error
+		expectedAttempts    int
+		expectedErrorString string
+	}{
+		"no policy": {
+			errs:                []error{errFlaky},
+			expectedAttempts:    1,
+			expectedErrorString: "flaky",
+		},
+		"succeeds on retry": {
+			policy:           OperationPolicy{Retries: 2, Backoff: metav1.Duration{Duration: time.Millisecond}},
+			errs:             []error{errFlaky, errFlaky},
+			expectedAttempts: 3,
+		},
+		"retries exhausted": {
+			policy:              OperationPolicy{Retries: 1},
+			errs:                []error{errFlaky, errFlaky, errFlaky},
+			expectedAttempts:    2,
+			expectedErrorString: "create failed after 2 attempts, err: flaky",
+		},
+		"permanent error is not retried": {
+			policy:              OperationPolicy{Retries: 3},
+			errs:                []error{errInvalid, errInvalid},
+			expectedAttempts:    1,
+			expectedErrorString: "invalid",
+		},
+		"permanent error after a transient one": {
+			policy:              OperationPolicy{Retries: 3},
+			errs:                []error{errFlaky, errInvalid},
+			expectedAttempts:    2,
+			expectedErrorString: "create failed after 2 attempts, err: invalid",
+		},
+		"timed out attempt is retried": {
+			policy:           OperationPolicy{Retries: 1, Timeout: metav1.Duration{Duration: time.Millisecond}},
+			errs:             []error{context.DeadlineExceeded},
+			expectedAttempts: 2,
+		},
+	}
+	SetQuiet(true)
+	defer SetQuiet(false)
+	for desc, tc := range tcs {
+		policies := OperationPolicies{OperationCreate: tc.policy}
+		attempts := 0
+		err := runOperation(context.Background(), nil, policies, OperationCreate, func(ctx context.Context,
+			_ client.Client) error {
+			attempts++
+			if attempts > len(tc.errs) {
+				return nil
+			}
+			if tc.errs[attempts-1] == context.DeadlineExceeded {
+				// Run into the timeout of the attempt.
+				<-ctx.Done()
+				return ctx.Err()
+			}
+			return tc.errs[attempts-1]
+		})
+		if attempts != tc.expectedAttempts {
+			t.Fatalf("TestRunOperation(%s): expected %d attempts but got %d", desc, tc.expectedAttempts, attempts)
+		}
+		if tc.expectedErrorString == "" && err != nil {
+			t.Fatalf("TestRunOperation(%s): unexpected error, err: %q", desc, err)
+		}
+		if tc.expectedErrorString != "" && (err == nil || !errors.Is(err, tc.errs[tc.expectedAttempts-1]) ||
+			!strings.Contains(err.Error(), tc.expectedErrorString)) {
+			t.Fatalf("TestRunOperation(%s): expected error %q but got %v", desc, tc.expectedErrorString, err)
+		}
+	}
+}
+
+func TestRunOperationClient(t *testing.T) {
+	var scheme = runtime.NewScheme()
+	if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestRunOperationClient: error adding to scheme, err: %q", err)
+	}
+	iap := &metallbv1beta1.IPAddressPool{ObjectMeta: metav1.ObjectMeta{Name: "iap", Namespace: "metallb-system"}}
+	c := &lostResponseClient{Client: fake.NewClientBuilder().WithScheme(scheme).Build()}
+	SetQuiet(true)
+	defer SetQuiet(false)
+	policies := OperationPolicies{
+		OperationCreate: {Retries: 1, Timeout: metav1.Duration{Duration: time.Minute}},
+	}
+
+	var errs []error
+	err := runOperation(context.Background(), c, policies, OperationCreate, func(ctx context.Context,
+		cl client.Client) error {
+		if _, ok := ctx.Deadline(); !ok {
+			t.Fatalf("TestRunOperationClient: expected the requests of an attempt to have a deadline")
+		}
+		err := cl.Create(ctx, iap.DeepCopy())
+		errs = append(errs, err)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("TestRunOperationClient: expected the object of the first attempt to be accepted on retry, "+
+			"err: %q", err)
+	}
+	if len(errs) != 2 || errs[0] == nil {
+		t.Fatalf("TestRunOperationClient: expected the first attempt to fail, got %v", errs)
+	}
+}
+
+// lostResponseClient creates the object of the first Create request but fails it with a timeout, as if the response
+// was lost.
+type lostResponseClient struct {
+	client.Client
+	lost bool
+}
+
+func (lc *lostResponseClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	err := lc.Client.Create(ctx, obj, opts...)
+	if err == nil && !lc.lost {
+		lc.lost = true
+		return apierrors.NewServerTimeout(schema.GroupResource{Resource: "ipaddresspools"}, "create", 1)
+	}
+	return err
+}
+
+// deadlineClient records every Create and Update request that reaches it without a deadline.
+type deadlineClient struct {
+	client.Client
+	withoutDeadline []string
+}
+
+func (dc *deadlineClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	if _, ok := ctx.Deadline(); !ok {
+		dc.withoutDeadline = append(dc.withoutDeadline, obj.GetName())
+	}
+	return dc.Client.Update(ctx, obj, opts...)
+}
+
+func (dc *deadlineClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	if _, ok := ctx.Deadline(); !ok {
+		dc.withoutDeadline = append(dc.withoutDeadline, obj.GetName())
+	}
+	return dc.Client.Create(ctx, obj, opts...)
+}
+
+func TestMigrationOperationTimeout(t *testing.T) {
+	var scheme = runtime.NewScheme()
+	if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestMigrationOperationTimeout: error adding to scheme, err: %q", err)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+	for _, ap := range validAddressPools0 {
+		if err := c.Create(context.TODO(), ap.DeepCopy()); err != nil {
+			t.Fatalf("TestMigrationOperationTimeout: error building fake client, err: %q", err)
+		}
+	}
+	// The legacy AddressPools are kept and marked as migrated under their own policy.
+	opts := OnlineMigrationOptions{Options: Options{OperationPolicies: OperationPolicies{
+		OperationCreate: {Timeout: metav1.Duration{Duration: time.Minute}},
+		OperationMark:   {Timeout: metav1.Duration{Duration: time.Minute}},
+	}}, KeepLegacy: true}
+
+	dc := &deadlineClient{Client: c}
+	if err := OnlineMigration(context.TODO(), dc, scheme, t.TempDir(), false, opts); err != nil {
+		t.Fatalf("TestMigrationOperationTimeout: unexpected error during migration, err: %q", err)
+	}
+	if len(dc.withoutDeadline) > 0 {
+		t.Fatalf("TestMigrationOperationTimeout: expected every create and mark to be bound to its timeout, "+
+			"got requests without a deadline for %v", dc.withoutDeadline)
+	}
+}
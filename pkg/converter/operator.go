@@ -0,0 +1,102 @@
+package converter
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// metallbOperatorGVK is the kind of the resource that the MetalLB Operator (e.g. on OpenShift) deploys MetalLB from.
+var metallbOperatorGVK = schema.GroupVersionKind{Group: metallbAPIGroup, Version: "v1beta1", Kind: "MetalLB"}
+
+// convertedObjectMeta returns the metadata of an object with the given name that is converted from ap, either its
+// IPAddressPool or one of its advertisements.
+func (o Options) convertedObjectMeta(ap metallbv1beta1.AddressPool, name string, advertisement bool) metav1.ObjectMeta {
+	objectMeta := metav1.ObjectMeta{
+		Name:        name,
+		Namespace:   ap.Namespace,
+		Labels:      o.propagatedMetadata(ap.Labels, advertisement),
+		Annotations: o.propagatedMetadata(ap.Annotations, advertisement),
+	}
+	if o.OperatorCompat {
+		objectMeta.Labels = mergeStringMaps(objectMeta.Labels, ap.Labels)
+		for _, ownerReference := range ap.OwnerReferences {
+			objectMeta.OwnerReferences = append(objectMeta.OwnerReferences, *ownerReference.DeepCopy())
+		}
+	}
+	if o.Provenance {
+		addProvenance(&objectMeta, ap)
+	}
+	return objectMeta
+}
+
+// DetectMetalLBOperator returns the namespace/name identifiers of all MetalLB resources of the MetalLB Operator. If
+// the operator's CRD is not installed, nothing is returned.
+func DetectMetalLBOperator(ctx context.Context, c client.Client) ([]string, error) {
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(metallbOperatorGVK.GroupVersion().WithKind(metallbOperatorGVK.Kind + "List"))
+	err := c.List(ctx, list)
+	if meta.IsNoMatchError(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s resources in cluster: %w", metallbOperatorGVK.Kind, err)
+	}
+	var instances []string
+	for _, item := range list.Items {
+		instances = append(instances, fmt.Sprintf("%s/%s", item.GetNamespace(), item.GetName()))
+	}
+	return instances, nil
+}
+
+// OwnedAddressPools returns a description of every AddressPool that is owned by another resource.
+func OwnedAddressPools(l *LegacyObjects) []string {
+	var owned []string
+	for _, ap := range l.AddressPoolList.Items {
+		for _, ownerReference := range ap.OwnerReferences {
+			owned = append(owned, fmt.Sprintf("AddressPool %s/%s is owned by %s %s", ap.Namespace, ap.Name,
+				ownerReference.Kind, ownerReference.Name))
+		}
+	}
+	return owned
+}
+
+// warnMetalLBOperator logs a warning if the MetalLB Operator manages the cluster.
+func warnMetalLBOperator(ctx context.Context, c client.Client) error {
+	if quiet {
+		return nil
+	}
+	instances, err := DetectMetalLBOperator(ctx, c)
+	if err != nil {
+		return err
+	}
+	for _, instance := range instances {
+		log.Printf("warning: MetalLB is managed by the MetalLB Operator (MetalLB %s), make sure that the operator "+
+			"version supports the converted resources", instance)
+	}
+	return nil
+}
+
+// warnOwnedAddressPools logs a warning for every legacy AddressPool that is owned by another resource. The owner may
+// recreate deleted AddressPools or ignore the converted resources. operatorCompat tells whether the owner references
+// are copied to the converted resources.
+func warnOwnedAddressPools(l *LegacyObjects, operatorCompat bool) {
+	if quiet {
+		return
+	}
+	for _, owned := range OwnedAddressPools(l) {
+		if operatorCompat {
+			log.Printf("warning: %s, the owner reference is copied to the converted resources", owned)
+			continue
+		}
+		log.Printf("warning: %s, the owner may recreate it or ignore the converted resources; copy the owner "+
+			"references and labels with the operator compatibility mode", owned)
+	}
+}
@@ -0,0 +1,80 @@
+package converter
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestDetectMetalLBOperator(t *testing.T) {
+	tcs := map[string]struct {
+		objects           []client.Object
+		expectedInstances string
+	}{
+		"no operator": {
+			expectedInstances: "[]",
+		},
+		"operator": {
+			objects: []client.Object{func() client.Object {
+				u := &unstructured.Unstructured{}
+				u.SetGroupVersionKind(metallbOperatorGVK)
+				u.SetNamespace("metallb-system")
+				u.SetName("metallb")
+				return u
+			}()},
+			expectedInstances: "[metallb-system/metallb]",
+		},
+	}
+	for desc, tc := range tcs {
+		var scheme = runtime.NewScheme()
+		if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+			t.Fatalf("TestDetectMetalLBOperator(%s): error adding to scheme, err: %q", desc, err)
+		}
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(tc.objects...).Build()
+		instances, err := DetectMetalLBOperator(context.TODO(), c)
+		if err != nil {
+			t.Fatalf("TestDetectMetalLBOperator(%s): unexpected error, err: %q", desc, err)
+		}
+		if fmt.Sprint(instances) != tc.expectedInstances {
+			t.Fatalf("TestDetectMetalLBOperator(%s): expected %s but got %v", desc, tc.expectedInstances, instances)
+		}
+	}
+}
+
+func TestOperatorCompat(t *testing.T) {
+	ap := *validAddressPools0[1].DeepCopy()
+	ap.Labels = map[string]string{"app": "metallb"}
+	ap.OwnerReferences = []metav1.OwnerReference{{APIVersion: "metallb.io/v1beta1", Kind: "MetalLB", Name: "metallb"}}
+	legacyObjects := &LegacyObjects{AddressPoolList: &metallbv1beta1.AddressPoolList{
+		Items: []metallbv1beta1.AddressPool{ap},
+	}}
+	expectedOwned := "[AddressPool metallb-system/ap-bgp is owned by MetalLB metallb]"
+	if owned := fmt.Sprint(OwnedAddressPools(legacyObjects)); owned != expectedOwned {
+		t.Fatalf("TestOperatorCompat: expected %s but got %s", expectedOwned, owned)
+	}
+
+	for _, enabled := range []bool{false, true} {
+		currentObjects, err := legacyObjects.Convert(Options{OperatorCompat: enabled})
+		if err != nil {
+			t.Fatalf("TestOperatorCompat(%t): unexpected error during conversion, err: %q", enabled, err)
+		}
+		var metas []metav1.ObjectMeta
+		metas = append(metas, currentObjects.IPAddressPoolList.Items[0].ObjectMeta)
+		for _, ba := range currentObjects.BGPAdvertisementList.Items {
+			metas = append(metas, ba.ObjectMeta)
+		}
+		for _, objectMeta := range metas {
+			inherited := objectMeta.Labels["app"] == "metallb" && len(objectMeta.OwnerReferences) == 1
+			if inherited != enabled {
+				t.Fatalf("TestOperatorCompat(%t): unexpected metadata %+v", enabled, objectMeta)
+			}
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/andreaskaris/metallb-converter/pkg/schema"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Options configures how legacy objects are read, converted, validated, printed and migrated. The zero value behaves
+// like the command line tool without flags. Options are passed to every conversion and migration, so that several of
+// them can run side by side with different settings, e.g. in the conversion server. The progress log, see SetQuiet,
+// the tracer, see SetTracer, and the feature gates of package features are not part of Options: they are shared by
+// all conversions and migrations of the process and must be set before the first one starts.
+type Options struct {
+	// SourceAPIGroup is the API group that legacy AddressPools are read from and TargetAPIGroup the API group of the
+	// converted resources, for distributions that serve the MetalLB types under a forked group. Empty groups default
+	// to metallb.io. The scheme of the client and of the input decoding must be built with AddToScheme and the same
+	// groups.
+	SourceAPIGroup string
+	TargetAPIGroup string
+	// IgnoreUnknown makes the readers of legacy objects skip the documents that are not legacy MetalLB objects, e.g.
+	// other Kubernetes manifests, files that are no YAML or JSON and subdirectories, instead of failing. The skipped
+	// documents are summarized in a log message.
+	IgnoreUnknown bool
+	// DuplicatePolicy sets how ReadLegacyObjectsFromDirectory handles AddressPools with the same namespace and name,
+	// one of SupportedDuplicatePolicies. Defaults to DuplicatePolicyFail.
+	DuplicatePolicy string
+	// Namespace restricts the legacy AddressPools that are read from the API to a namespace, or to all namespaces if
+	// it is empty, and Selector to the AddressPools whose labels match it, if it is not nil. The BGPPeers and
+	// BFDProfiles that are read along with them are only restricted to Namespace.
+	Namespace string
+	Selector  labels.Selector
+
+	// NormalizeAddresses sorts the addresses of every IPAddressPool, removes exact duplicates and canonicalizes their
+	// textual form (e.g. IPv6 addresses according to RFC 5952).
+	NormalizeAddresses bool
+	// CommunityResources extracts all distinct community values of the BGPAdvertisements into one Community CR per
+	// namespace and rewrites the advertisements to reference the aliases of that CR.
+	CommunityResources bool
+	// ExpandCommunityAliases makes ResolveCommunityAliases replace references to the aliases of existing Community CRs
+	// with their values.
+	ExpandCommunityAliases bool
+	// AssignPriorities assigns a priority to every IPAddressPool, derived from the order of the legacy AddressPools in
+	// the input, to preserve the implicit allocation preferences of the legacy configuration.
+	AssignPriorities bool
+	// PeersPolicy sets spec.peers of the BGPAdvertisements of every AddressPool that the policy maps. The legacy
+	// format cannot restrict advertisements to a subset of the peers, so without a policy advertisements are sent to
+	// all peers.
+	PeersPolicy PeersPolicy
+	// MergeIdenticalPools merges legacy AddressPools of the same namespace whose address sets are identical into a
+	// single IPAddressPool. The advertisements of all merged pools are kept and reference the IPAddressPool of the
+	// first pool.
+	MergeIdenticalPools bool
+	// MergePolicy merges the pools of every group of the policy into one IPAddressPool with the union of their
+	// addresses. References of advertisements are updated and advertisements that become identical are dropped.
+	MergePolicy MergePolicy
+	// MergeL2 emits a single L2Advertisement named MergedL2AdvertisementName per namespace that references every
+	// layer2 pool of the namespace, instead of one L2Advertisement per pool.
+	MergeL2 bool
+	// SplitL2AddressFamilies splits every dual-stack IPAddressPool that is advertised in layer2 mode into an IPv4 and
+	// an IPv6 IPAddressPool, each with its own L2Advertisement. This allows to restrict the advertisements of each
+	// family to different nodes or interfaces, for clusters whose L2 domains differ per family.
+	SplitL2AddressFamilies bool
+	// SplitPoolsPerRange emits one IPAddressPool per address range of a legacy AddressPool, named <name>-<index>, so
+	// that the ranges can be managed individually after the migration.
+	SplitPoolsPerRange bool
+	// NameCollisionPolicy sets how converted objects of the same kind, namespace and name are handled, and how
+	// migrations handle converted objects that already exist in the cluster, one of SupportedNameCollisionPolicies.
+	// Defaults to NameCollisionsFail.
+	NameCollisionPolicy string
+	// AdvertisementNaming sets how the BGPAdvertisements of the legacy bgpAdvertisements are named, one of
+	// SupportedAdvertisementNamings. Defaults to AdvertisementNamingIndex.
+	AdvertisementNaming string
+	// ExtraLabels and ExtraAnnotations are added to every generated object. They take precedence over the labels and
+	// annotations that the conversion sets itself.
+	ExtraLabels      map[string]string
+	ExtraAnnotations map[string]string
+	// SkipMetadataPropagation does not copy the labels and annotations of every legacy AddressPool to its
+	// IPAddressPool, which is done by default. Keys that are set by kubectl, Kubernetes, the converter itself or a
+	// managing system such as Helm or ArgoCD are never copied, see isPropagated.
+	SkipMetadataPropagation bool
+	// AdvertisementMetadataKeys are the label and annotation keys that are copied to the advertisements of an
+	// AddressPool, too. A key that ends with * matches every key with the preceding prefix, e.g. example.com/*, see
+	// ValidateMetadataKeys.
+	AdvertisementMetadataKeys []string
+	// OperatorCompat copies the labels and owner references of every legacy AddressPool to the objects that are
+	// converted from it. On clusters where the MetalLB Operator or another controller manages the legacy resources,
+	// the converted resources then carry the same ownership and selection labels.
+	OperatorCompat bool
+	// Provenance stamps every IPAddressPool and advertisement with the labels SourceNameLabel and SourceUIDLabel and
+	// the annotations SourceHashAnnotation and ConvertedAtAnnotation, so that the objects can later be traced back to
+	// their legacy AddressPool, e.g. for audits, garbage collection or to find out whether the AddressPool changed
+	// since its conversion. Objects that are merged from several AddressPools keep the provenance of the first.
+	Provenance bool
+	// PeerPasswords sets how the passwords of the peers of the legacy ConfigMap are converted, one of
+	// SupportedPeerPasswords. Defaults to PeerPasswordsSecret.
+	PeerPasswords string
+	// Target is the schema bundle of the MetalLB release that the converted objects are meant for, see schema.Load.
+	// The conversion then refuses to generate fields that this release does not support, because its webhooks would
+	// reject them. Ignored if nil.
+	Target *schema.Bundle
+	// StripUnsupportedFields strips the fields that Target does not support instead of returning an error.
+	StripUnsupportedFields bool
+
+	// FailOnLocalPrefConflicts turns the localPref conflict warnings of migrations into errors.
+	FailOnLocalPrefConflicts bool
+	// StrictValidation makes OfflineMigration fail with ErrStrictValidation if package validate finds problems in the
+	// legacy AddressPools or the converted resources. By default, the problems are logged as warnings.
+	StrictValidation bool
+	// ValidateAgainst validates the converted objects against a schema bundle before they are printed or created,
+	// e.g. the embedded CRD schemas of a MetalLB release or the schemas that LoadServedSchemas reads from the
+	// cluster. Ignored if nil.
+	ValidateAgainst *schema.Bundle
+	// DryRunValidator validates the converted objects of OfflineMigration with dry-run creates, e.g. against a local
+	// API server with the MetalLB CRDs installed, see package envtest. Unlike ValidateAgainst, this applies the
+	// defaulting and the complete validation of the API server. Ignored if nil.
+	DryRunValidator client.Client
+	// SimulateAssignments makes offline migrations from the API and online migrations simulate MetalLB's IP
+	// assignment for all LoadBalancer Services over the converted pools and report every Service whose IP would
+	// change.
+	SimulateAssignments bool
+
+	// OutputGroupBy sets how the objects are distributed over the files of an output directory, one of
+	// SupportedOutputGroupings. Defaults to OutputGroupByKind.
+	OutputGroupBy string
+	// OutputLayout sets how the files of an output directory are laid out, one of SupportedOutputLayouts. Defaults to
+	// OutputLayoutFlat.
+	OutputLayout string
+
+	// ServerSideApply applies the IPAddressPools and advertisements with server-side apply and FieldManager instead
+	// of creating them. Objects that a previous, partial run created are then updated to the converted spec instead of
+	// failing with AlreadyExists, so that online migrations can be run again.
+	ServerSideApply bool
+	// OperationPolicies sets the retry and timeout policies of the operations of online migrations and finalizations.
+	OperationPolicies OperationPolicies
+	// FailurePoints arms failure points for testing the recovery from failed migrations, see ParseFailurePoints.
+	// Ignored if nil.
+	FailurePoints *FailurePoints
+	// ConversionObserver and MigrationObserver are notified of the conversions and of the migrated AddressPools, e.g.
+	// to record metrics. Ignored if nil.
+	ConversionObserver ConversionObserver
+	MigrationObserver  MigrationObserver
+}
+
+// Validate returns an error if a policy, a naming or a layout of the options is not supported, if an API group is
+// invalid or if options exclude each other.
+func (o Options) Validate() error {
+	for _, group := range []string{o.SourceAPIGroup, o.TargetAPIGroup} {
+		if group == "" {
+			continue
+		}
+		if problems := validation.IsDNS1123Subdomain(group); len(problems) > 0 {
+			return fmt.Errorf("invalid API group %q: %s", group, strings.Join(problems, ", "))
+		}
+	}
+	choices := []struct {
+		description string
+		value       string
+		supported   []string
+	}{
+		{description: "duplicate policy", value: o.DuplicatePolicy, supported: SupportedDuplicatePolicies},
+		{description: "name collision policy", value: o.NameCollisionPolicy, supported: SupportedNameCollisionPolicies},
+		{description: "advertisement naming", value: o.AdvertisementNaming, supported: SupportedAdvertisementNamings},
+		{description: "peer passwords mode", value: o.PeerPasswords, supported: SupportedPeerPasswords},
+		{description: "output grouping", value: o.OutputGroupBy, supported: SupportedOutputGroupings},
+		{description: "output layout", value: o.OutputLayout, supported: SupportedOutputLayouts},
+	}
+	for _, choice := range choices {
+		if choice.value != "" && !contains(choice.supported, choice.value) {
+			return fmt.Errorf("unsupported %s %q, must be one of: %s", choice.description, choice.value,
+				strings.Join(choice.supported, ", "))
+		}
+	}
+	if o.OutputLayout == OutputLayoutPerKindDir && o.OutputGroupBy == OutputGroupByPool {
+		return fmt.Errorf("output layout %s cannot be combined with output grouping %s", OutputLayoutPerKindDir,
+			OutputGroupByPool)
+	}
+	return ValidateMetadataKeys(o.AdvertisementMetadataKeys)
+}
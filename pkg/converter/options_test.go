@@ -0,0 +1,70 @@
+package converter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestOptionsValidate(t *testing.T) {
+	tcs := map[string]struct {
+		opts                Options
+		expectedErrorString string
+	}{
+		"zero value": {},
+		"supported choices": {
+			opts: Options{
+				DuplicatePolicy:     DuplicatePolicyKeepLast,
+				NameCollisionPolicy: NameCollisionsSuffix,
+				AdvertisementNaming: AdvertisementNamingHash,
+				PeerPasswords:       PeerPasswordsInline,
+				OutputGroupBy:       OutputGroupByPool,
+				OutputLayout:        OutputLayoutFlat,
+			},
+		},
+		"invalid API group": {
+			opts:                Options{TargetAPIGroup: "Metallb_IO"},
+			expectedErrorString: "invalid API group \"Metallb_IO\"",
+		},
+		"unsupported duplicate policy": {
+			opts:                Options{DuplicatePolicy: "invalid"},
+			expectedErrorString: "unsupported duplicate policy \"invalid\"",
+		},
+		"unsupported name collision policy": {
+			opts:                Options{NameCollisionPolicy: "invalid"},
+			expectedErrorString: "unsupported name collision policy \"invalid\"",
+		},
+		"unsupported advertisement naming": {
+			opts:                Options{AdvertisementNaming: "invalid"},
+			expectedErrorString: "unsupported advertisement naming \"invalid\"",
+		},
+		"unsupported peer passwords mode": {
+			opts:                Options{PeerPasswords: "invalid"},
+			expectedErrorString: "unsupported peer passwords mode \"invalid\"",
+		},
+		"unsupported output grouping": {
+			opts:                Options{OutputGroupBy: "invalid"},
+			expectedErrorString: "unsupported output grouping \"invalid\"",
+		},
+		"unsupported output layout": {
+			opts:                Options{OutputLayout: "invalid"},
+			expectedErrorString: "unsupported output layout \"invalid\"",
+		},
+		"per-kind-dir layout grouped by pool": {
+			opts:                Options{OutputLayout: OutputLayoutPerKindDir, OutputGroupBy: OutputGroupByPool},
+			expectedErrorString: "cannot be combined with output grouping",
+		},
+		"invalid advertisement metadata key": {
+			opts:                Options{AdvertisementMetadataKeys: []string{"in valid"}},
+			expectedErrorString: "in valid",
+		},
+	}
+	for desc, tc := range tcs {
+		err := tc.opts.Validate()
+		if tc.expectedErrorString == "" && err != nil {
+			t.Fatalf("TestOptionsValidate(%s): unexpected error, err: %q", desc, err)
+		}
+		if tc.expectedErrorString != "" && (err == nil || !strings.Contains(err.Error(), tc.expectedErrorString)) {
+			t.Fatalf("TestOptionsValidate(%s): expected error %q but got %v", desc, tc.expectedErrorString, err)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	// NameOverrideAnnotation on a legacy AddressPool sets the name of its IPAddressPool. The names of the
+	// advertisements are derived from it.
+	NameOverrideAnnotation = "metallb-converter.io/name"
+	// MergeGroupAnnotation on a legacy AddressPool merges it into the IPAddressPool with the given name, together
+	// with all other pools of the namespace with the same annotation value. It takes precedence over the merge policy.
+	MergeGroupAnnotation = "metallb-converter.io/merge-group"
+	// L2InterfacesAnnotation on a legacy layer2 AddressPool is a comma separated list of interfaces that its
+	// L2Advertisement is restricted to.
+	L2InterfacesAnnotation = "metallb-converter.io/l2-interfaces"
+)
+
+// convertedPoolName returns the name of the IPAddressPool of ap: the value of the NameOverrideAnnotation or the name
+// of ap.
+func convertedPoolName(ap metallbv1beta1.AddressPool) string {
+	if name := ap.Annotations[NameOverrideAnnotation]; name != "" {
+		return name
+	}
+	return ap.Name
+}
+
+// l2Interfaces returns the interfaces of the L2InterfacesAnnotation of ap.
+func l2Interfaces(ap metallbv1beta1.AddressPool) []string {
+	var interfaces []string
+	for _, iface := range strings.Split(ap.Annotations[L2InterfacesAnnotation], ",") {
+		if iface = strings.TrimSpace(iface); iface != "" {
+			interfaces = append(interfaces, iface)
+		}
+	}
+	return interfaces
+}
+
+// validateOverrides returns an error that lists all invalid override annotations of addressPools and all pools of a
+// namespace whose IPAddressPools would get the same name.
+func validateOverrides(addressPools []metallbv1beta1.AddressPool) error {
+	var problems []string
+	names := map[string]string{}
+	for _, ap := range addressPools {
+		for _, annotation := range []string{NameOverrideAnnotation, MergeGroupAnnotation} {
+			value, ok := ap.Annotations[annotation]
+			if !ok {
+				continue
+			}
+			if errs := validation.IsDNS1123Subdomain(value); len(errs) > 0 {
+				problems = append(problems, fmt.Sprintf("AddressPool %s/%s: invalid %s annotation %q: %s",
+					ap.Namespace, ap.Name, annotation, value, strings.Join(errs, ", ")))
+			}
+		}
+		if _, ok := ap.Annotations[L2InterfacesAnnotation]; ok {
+			if ap.Spec.Protocol != ProtocolLayer2 {
+				problems = append(problems, fmt.Sprintf("AddressPool %s/%s: the %s annotation requires protocol %s",
+					ap.Namespace, ap.Name, L2InterfacesAnnotation, ProtocolLayer2))
+			} else if len(l2Interfaces(ap)) == 0 {
+				problems = append(problems, fmt.Sprintf("AddressPool %s/%s: the %s annotation lists no interfaces",
+					ap.Namespace, ap.Name, L2InterfacesAnnotation))
+			}
+		}
+		key := fmt.Sprintf("%s/%s", ap.Namespace, convertedPoolName(ap))
+		if other, ok := names[key]; ok {
+			problems = append(problems, fmt.Sprintf("AddressPools %s and %s/%s would both be converted to "+
+				"IPAddressPool %s", other, ap.Namespace, ap.Name, key))
+			continue
+		}
+		names[key] = fmt.Sprintf("%s/%s", ap.Namespace, ap.Name)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid conversion overrides:\n%s", strings.Join(problems, "\n"))
+	}
+	return nil
+}
+
+// rejectMergeGroups returns an error if any AddressPool of l has the MergeGroupAnnotation. Online migrations migrate
+// pools one by one and cannot merge them.
+func rejectMergeGroups(l *LegacyObjects) error {
+	var pools []string
+	for _, ap := range l.AddressPoolList.Items {
+		if _, ok := ap.Annotations[MergeGroupAnnotation]; ok {
+			pools = append(pools, fmt.Sprintf("%s/%s", ap.Namespace, ap.Name))
+		}
+	}
+	if len(pools) > 0 {
+		return fmt.Errorf("the %s annotation is not supported for online migrations, pools are migrated one by "+
+			"one: %s", MergeGroupAnnotation, strings.Join(pools, ", "))
+	}
+	return nil
+}
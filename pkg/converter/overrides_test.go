@@ -0,0 +1,122 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestValidateOverrides(t *testing.T) {
+	pool := func(name, protocol string, annotations map[string]string) metallbv1beta1.AddressPool {
+		return metallbv1beta1.AddressPool{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "metallb-system", Annotations: annotations},
+			Spec:       metallbv1beta1.AddressPoolSpec{Protocol: protocol, Addresses: []string{"10.0.0.0/24"}},
+		}
+	}
+	tcs := map[string]struct {
+		pools               []metallbv1beta1.AddressPool
+		expectedErrorString string
+	}{
+		"valid overrides": {
+			pools: []metallbv1beta1.AddressPool{
+				pool("a", ProtocolLayer2, map[string]string{NameOverrideAnnotation: "b", L2InterfacesAnnotation: "eth0"}),
+				pool("b", ProtocolBGP, map[string]string{NameOverrideAnnotation: "a", MergeGroupAnnotation: "group"}),
+			},
+		},
+		"invalid name": {
+			pools: []metallbv1beta1.AddressPool{
+				pool("a", ProtocolLayer2, map[string]string{NameOverrideAnnotation: "A_"}),
+			},
+			expectedErrorString: "invalid metallb-converter.io/name annotation \"A_\"",
+		},
+		"invalid merge group": {
+			pools: []metallbv1beta1.AddressPool{
+				pool("a", ProtocolLayer2, map[string]string{MergeGroupAnnotation: ""}),
+			},
+			expectedErrorString: "invalid metallb-converter.io/merge-group annotation",
+		},
+		"interfaces for bgp": {
+			pools: []metallbv1beta1.AddressPool{
+				pool("a", ProtocolBGP, map[string]string{L2InterfacesAnnotation: "eth0"}),
+			},
+			expectedErrorString: "requires protocol layer2",
+		},
+		"no interfaces": {
+			pools: []metallbv1beta1.AddressPool{
+				pool("a", ProtocolLayer2, map[string]string{L2InterfacesAnnotation: " , "}),
+			},
+			expectedErrorString: "lists no interfaces",
+		},
+		"name collision": {
+			pools: []metallbv1beta1.AddressPool{
+				pool("a", ProtocolLayer2, nil),
+				pool("b", ProtocolLayer2, map[string]string{NameOverrideAnnotation: "a"}),
+			},
+			expectedErrorString: "AddressPools metallb-system/a and metallb-system/b would both be converted",
+		},
+	}
+	for desc, tc := range tcs {
+		err := validateOverrides(tc.pools)
+		if tc.expectedErrorString == "" {
+			if err != nil {
+				t.Fatalf("TestValidateOverrides(%s): unexpected error, err: %q", desc, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tc.expectedErrorString) {
+			t.Fatalf("TestValidateOverrides(%s): expected error %q but got %v", desc, tc.expectedErrorString, err)
+		}
+	}
+}
+
+func TestConvertOverrides(t *testing.T) {
+	pools := []metallbv1beta1.AddressPool{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "ap-l2", Namespace: "metallb-system", Annotations: map[string]string{
+				NameOverrideAnnotation: "office",
+				L2InterfacesAnnotation: "eth0, eth1",
+			}},
+			Spec: metallbv1beta1.AddressPoolSpec{Protocol: ProtocolLayer2, Addresses: []string{"10.0.0.0/24"}},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "ap-bgp1", Namespace: "metallb-system", Annotations: map[string]string{
+				MergeGroupAnnotation: "bgp",
+			}},
+			Spec: metallbv1beta1.AddressPoolSpec{Protocol: ProtocolBGP, Addresses: []string{"10.0.1.0/24"}},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "ap-bgp2", Namespace: "metallb-system", Annotations: map[string]string{
+				MergeGroupAnnotation: "bgp",
+			}},
+			Spec: metallbv1beta1.AddressPoolSpec{Protocol: ProtocolBGP, Addresses: []string{"10.0.2.0/24"}},
+		},
+	}
+	// The annotation takes precedence over the merge policy.
+	legacyObjects := &LegacyObjects{AddressPoolList: &metallbv1beta1.AddressPoolList{Items: pools}}
+	currentObjects, err := legacyObjects.Convert(Options{MergePolicy: MergePolicy{{Name: "all", Pools: []string{"*"}}}})
+	if err != nil {
+		t.Fatalf("TestConvertOverrides: unexpected error during conversion, err: %q", err)
+	}
+	var iaps, l2as, bas []string
+	for _, iap := range currentObjects.IPAddressPoolList.Items {
+		iaps = append(iaps, fmt.Sprintf("%s:%v", iap.Name, iap.Spec.Addresses))
+	}
+	for _, l2a := range currentObjects.L2AdvertisementList.Items {
+		l2as = append(l2as, fmt.Sprintf("%s:%v:%v", l2a.Name, l2a.Spec.IPAddressPools, l2a.Spec.Interfaces))
+	}
+	for _, ba := range currentObjects.BGPAdvertisementList.Items {
+		bas = append(bas, fmt.Sprintf("%s:%v", ba.Name, ba.Spec.IPAddressPools))
+	}
+	expected := "[all:[10.0.0.0/24] bgp:[10.0.1.0/24 10.0.2.0/24]] [office-l2-advertisement:[all]:[eth0 eth1]] " +
+		"[ap-bgp1-bgp-advertisement-0:[bgp]]"
+	if got := fmt.Sprint(iaps, " ", l2as, " ", bas); got != expected {
+		t.Fatalf("TestConvertOverrides: expected %s but got %s", expected, got)
+	}
+	if err := rejectMergeGroups(legacyObjects); err == nil ||
+		!strings.Contains(err.Error(), "metallb-system/ap-bgp1, metallb-system/ap-bgp2") {
+		t.Fatalf("TestConvertOverrides: expected merge groups to be rejected for online migrations but got %v", err)
+	}
+}
@@ -0,0 +1,63 @@
+package converter
+
+import (
+	"fmt"
+	"log"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+)
+
+const (
+	// managedByLabel is the recommended Kubernetes label that names the tool that manages a resource.
+	managedByLabel = "app.kubernetes.io/managed-by"
+	// helmReleaseNameAnnotation and helmReleaseNamespaceAnnotation mark resources that belong to a Helm release.
+	helmReleaseNameAnnotation      = "meta.helm.sh/release-name"
+	helmReleaseNamespaceAnnotation = "meta.helm.sh/release-namespace"
+	// argoCDTrackingIDAnnotation is set by ArgoCD on the resources of an application with annotation based tracking.
+	argoCDTrackingIDAnnotation = "argocd.argoproj.io/tracking-id"
+	// argoCDInstanceLabel is set by ArgoCD on the resources of an application with label based tracking (default).
+	argoCDInstanceLabel = "app.kubernetes.io/instance"
+)
+
+// poolManagers returns the systems, such as Helm or ArgoCD, that manage ap according to its labels and annotations.
+func poolManagers(ap metallbv1beta1.AddressPool) []string {
+	var managers []string
+	if release, ok := ap.Annotations[helmReleaseNameAnnotation]; ok {
+		managers = append(managers, fmt.Sprintf("Helm release %s/%s", ap.Annotations[helmReleaseNamespaceAnnotation],
+			release))
+	}
+	if trackingID, ok := ap.Annotations[argoCDTrackingIDAnnotation]; ok {
+		managers = append(managers, fmt.Sprintf("ArgoCD (tracking-id %s)", trackingID))
+	} else if instance, ok := ap.Labels[argoCDInstanceLabel]; ok {
+		managers = append(managers, fmt.Sprintf("ArgoCD application %s", instance))
+	}
+	if tool, ok := ap.Labels[managedByLabel]; ok && len(managers) == 0 {
+		managers = append(managers, tool)
+	}
+	return managers
+}
+
+// ManagedAddressPools returns a description of every legacy AddressPool that carries the labels or annotations of a
+// managing system such as Helm or ArgoCD. Such systems may revert in-place changes or report drift.
+func ManagedAddressPools(l *LegacyObjects) []string {
+	var managed []string
+	for _, ap := range l.AddressPoolList.Items {
+		for _, manager := range poolManagers(ap) {
+			managed = append(managed, fmt.Sprintf("AddressPool %s/%s is managed by %s", ap.Namespace, ap.Name, manager))
+		}
+	}
+	return managed
+}
+
+// warnManagedAddressPools logs a warning for every legacy AddressPool that is managed by another system, before the
+// AddressPools are edited or deleted in place.
+func warnManagedAddressPools(l *LegacyObjects) {
+	if quiet {
+		return
+	}
+	for _, managed := range ManagedAddressPools(l) {
+		log.Printf("warning: %s, editing or deleting it in place may be reverted or cause drift; consider "+
+			"converting offline to an output directory and committing the result to the managing system instead",
+			managed)
+	}
+}
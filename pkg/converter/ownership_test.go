@@ -0,0 +1,69 @@
+package converter
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestManagedAddressPools(t *testing.T) {
+	tcs := map[string]struct {
+		labels          map[string]string
+		annotations     map[string]string
+		expectedManaged string
+	}{
+		"unmanaged": {
+			expectedManaged: "[]",
+		},
+		"helm": {
+			labels: map[string]string{managedByLabel: "Helm"},
+			annotations: map[string]string{
+				helmReleaseNameAnnotation: "metallb", helmReleaseNamespaceAnnotation: "metallb-system",
+			},
+			expectedManaged: "[AddressPool metallb-system/ap-l2 is managed by Helm release metallb-system/metallb]",
+		},
+		"argocd label": {
+			labels:          map[string]string{argoCDInstanceLabel: "network"},
+			expectedManaged: "[AddressPool metallb-system/ap-l2 is managed by ArgoCD application network]",
+		},
+		"argocd annotation": {
+			annotations: map[string]string{argoCDTrackingIDAnnotation: "network:metallb.io/AddressPool:ns/ap-l2"},
+			expectedManaged: "[AddressPool metallb-system/ap-l2 is managed by ArgoCD (tracking-id " +
+				"network:metallb.io/AddressPool:ns/ap-l2)]",
+		},
+		"other tool": {
+			labels:          map[string]string{managedByLabel: "kustomize"},
+			expectedManaged: "[AddressPool metallb-system/ap-l2 is managed by kustomize]",
+		},
+	}
+	for desc, tc := range tcs {
+		ap := *validAddressPools0[0].DeepCopy()
+		ap.Labels = tc.labels
+		ap.Annotations = tc.annotations
+		legacyObjects := &LegacyObjects{AddressPoolList: &metallbv1beta1.AddressPoolList{
+			Items: []metallbv1beta1.AddressPool{ap},
+		}}
+		if managed := fmt.Sprint(ManagedAddressPools(legacyObjects)); managed != tc.expectedManaged {
+			t.Fatalf("TestManagedAddressPools(%s): expected %s but got %s", desc, tc.expectedManaged, managed)
+		}
+
+		// The confirmation prompt of an online migration names the managing system as well.
+		var scheme = runtime.NewScheme()
+		if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+			t.Fatalf("TestManagedAddressPools(%s): error adding to scheme, err: %q", desc, err)
+		}
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(ap.DeepCopy()).Build()
+		actions, err := PlanOnlineMigration(context.TODO(), c, OnlineMigrationOptions{})
+		if err != nil {
+			t.Fatalf("TestManagedAddressPools(%s): unexpected error, err: %q", desc, err)
+		}
+		if mentioned := strings.Contains(fmt.Sprint(actions), "managed by"); mentioned != (tc.expectedManaged != "[]") {
+			t.Fatalf("TestManagedAddressPools(%s): unexpected actions %v", desc, actions)
+		}
+	}
+}
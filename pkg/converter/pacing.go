@@ -0,0 +1,56 @@
+package converter
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// pacer enforces a minimum interval between consecutive operations of one kind.
+type pacer struct {
+	name     string
+	interval time.Duration
+	last     time.Time
+}
+
+// wait blocks until the interval has passed since the previous call to wait, or until ctx is done. The first call
+// returns immediately.
+func (p *pacer) wait(ctx context.Context) error {
+	if p.interval > 0 && !p.last.IsZero() {
+		if remaining := p.interval - time.Since(p.last); remaining > 0 {
+			if !quiet {
+				log.Printf("waiting %s before the next %s ...", remaining.Round(time.Millisecond), p.name)
+			}
+			timer := time.NewTimer(remaining)
+			defer timer.Stop()
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-timer.C:
+			}
+		}
+	}
+	p.last = time.Now()
+	return nil
+}
+
+// migrationPacers holds the pacers of the destructive and the constructive step of an online migration, which are
+// paced independently. Waits end early when stop is done.
+type migrationPacers struct {
+	delete *pacer
+	create *pacer
+	stop   context.Context
+}
+
+// newMigrationPacers returns the pacers for the DeleteInterval and CreateInterval of opts.
+func newMigrationPacers(opts OnlineMigrationOptions) migrationPacers {
+	stop := opts.Stop
+	if stop == nil {
+		stop = context.Background()
+	}
+	return migrationPacers{
+		delete: &pacer{name: "deletion", interval: opts.DeleteInterval},
+		create: &pacer{name: "creation", interval: opts.CreateInterval},
+		stop:   stop,
+	}
+}
@@ -0,0 +1,108 @@
+package converter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestPacerWait(t *testing.T) {
+	tcs := map[string]struct {
+		interval        time.Duration
+		calls           int
+		minimumDuration time.Duration
+	}{
+		"no interval": {
+			calls: 3,
+		},
+		"first call is immediate": {
+			interval: time.Hour,
+			calls:    1,
+		},
+		"interval between calls": {
+			interval:        20 * time.Millisecond,
+			calls:           3,
+			minimumDuration: 40 * time.Millisecond,
+		},
+	}
+	SetQuiet(true)
+	defer SetQuiet(false)
+	for desc, tc := range tcs {
+		p := &pacer{name: "test", interval: tc.interval}
+		start := time.Now()
+		for i := 0; i < tc.calls; i++ {
+			if err := p.wait(context.Background()); err != nil {
+				t.Fatalf("TestPacerWait(%s): unexpected error, err: %q", desc, err)
+			}
+		}
+		if elapsed := time.Since(start); elapsed < tc.minimumDuration || elapsed > tc.minimumDuration+time.Second {
+			t.Fatalf("TestPacerWait(%s): expected to wait at least %s but waited %s", desc, tc.minimumDuration,
+				elapsed)
+		}
+	}
+}
+
+func TestPacerWaitCanceled(t *testing.T) {
+	SetQuiet(true)
+	defer SetQuiet(false)
+	p := &pacer{name: "test", interval: time.Hour}
+	if err := p.wait(context.Background()); err != nil {
+		t.Fatalf("TestPacerWaitCanceled: unexpected error, err: %q", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := p.wait(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("TestPacerWaitCanceled: expected context.Canceled but got %v", err)
+	}
+}
+
+// stoppingClient calls stop on the first deletion, like a termination signal that arrives while a pool is migrated.
+type stoppingClient struct {
+	client.Client
+	stop context.CancelFunc
+}
+
+func (sc *stoppingClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	sc.stop()
+	return sc.Client.Delete(ctx, obj, opts...)
+}
+
+func TestOnlineMigrationStop(t *testing.T) {
+	var scheme = runtime.NewScheme()
+	if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestOnlineMigrationStop: error adding to scheme, err: %q", err)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+	for _, ap := range validAddressPools0 {
+		if err := c.Create(context.TODO(), ap.DeepCopy()); err != nil {
+			t.Fatalf("TestOnlineMigrationStop: error building fake client, err: %q", err)
+		}
+	}
+	SetQuiet(true)
+	defer SetQuiet(false)
+	stop, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	err := OnlineMigration(context.TODO(), &stoppingClient{Client: c, stop: cancel}, scheme, t.TempDir(), false,
+		OnlineMigrationOptions{CreateInterval: time.Hour, Stop: stop})
+	if !errors.Is(err, ErrMigrationStopped) {
+		t.Fatalf("TestOnlineMigrationStop: expected ErrMigrationStopped but got %v", err)
+	}
+	addressPools := &metallbv1beta1.AddressPoolList{}
+	ipAddressPools := &metallbv1beta1.IPAddressPoolList{}
+	if err := c.List(context.TODO(), addressPools); err != nil {
+		t.Fatalf("TestOnlineMigrationStop: cannot list AddressPools, err: %q", err)
+	}
+	if err := c.List(context.TODO(), ipAddressPools); err != nil {
+		t.Fatalf("TestOnlineMigrationStop: cannot list IPAddressPools, err: %q", err)
+	}
+	if len(addressPools.Items) != len(validAddressPools0)-1 || len(ipAddressPools.Items) != 1 {
+		t.Fatalf("TestOnlineMigrationStop: expected exactly the pool in flight to be migrated, got %d "+
+			"AddressPools and %d IPAddressPools", len(addressPools.Items), len(ipAddressPools.Items))
+	}
+}
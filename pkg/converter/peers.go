@@ -0,0 +1,59 @@
+package converter
+
+import (
+	"fmt"
+	"os"
+
+	"sigs.k8s.io/yaml"
+)
+
+// PeersPolicy maps legacy AddressPools to the names of the BGPPeers that their BGPAdvertisements are sent to. Keys
+// are either <namespace>/<name>, or <name> which matches AddressPools of that name in every namespace. A namespaced
+// key takes precedence over a plain one.
+type PeersPolicy map[string][]string
+
+// LoadPeersPolicy reads a PeersPolicy from a YAML or JSON file.
+func LoadPeersPolicy(path string) (PeersPolicy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read peers policy %q, err: %w", path, err)
+	}
+	policy := PeersPolicy{}
+	if err := yaml.UnmarshalStrict(data, &policy); err != nil {
+		return nil, fmt.Errorf("cannot parse peers policy %q, err: %w", path, err)
+	}
+	for pool, peers := range policy {
+		if pool == "" {
+			return nil, fmt.Errorf("peers policy %q contains an empty pool reference", path)
+		}
+		if len(peers) == 0 {
+			return nil, fmt.Errorf("peers policy %q maps pool %q to no peers", path, pool)
+		}
+		for _, peer := range peers {
+			if peer == "" {
+				return nil, fmt.Errorf("peers policy %q maps pool %q to an empty peer name", path, pool)
+			}
+		}
+	}
+	return policy, nil
+}
+
+// peersFor returns the peers that the policy maps the AddressPool namespace/name to, or nil if it is not mapped.
+func (p PeersPolicy) peersFor(namespace, name string) []string {
+	if peers, ok := p[fmt.Sprintf("%s/%s", namespace, name)]; ok {
+		return peers
+	}
+	return p[name]
+}
+
+// applyPeersPolicy sets spec.peers of every BGPAdvertisement whose pool is mapped by peersPolicy. It must run before
+// mergePools, while every BGPAdvertisement still references the pool that it was converted from.
+func (c *CurrentObjects) applyPeersPolicy(peersPolicy PeersPolicy) {
+	for i, ba := range c.BGPAdvertisementList.Items {
+		for _, pool := range ba.Spec.IPAddressPools {
+			if peers := peersPolicy.peersFor(ba.Namespace, pool); peers != nil {
+				c.BGPAdvertisementList.Items[i].Spec.Peers = append([]string{}, peers...)
+			}
+		}
+	}
+}
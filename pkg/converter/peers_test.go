@@ -0,0 +1,79 @@
+package converter
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+)
+
+func TestLoadPeersPolicy(t *testing.T) {
+	tcs := map[string]struct {
+		policy              string
+		expectedPolicy      PeersPolicy
+		expectedErrorString string
+	}{
+		"valid policy": {
+			policy:         "metallb-system/ap-bgp:\n- peer-a\n- peer-b\nap-bgp2:\n- peer-c\n",
+			expectedPolicy: PeersPolicy{"metallb-system/ap-bgp": {"peer-a", "peer-b"}, "ap-bgp2": {"peer-c"}},
+		},
+		"no peers": {
+			policy:              "ap-bgp: []\n",
+			expectedErrorString: "maps pool \"ap-bgp\" to no peers",
+		},
+		"empty peer": {
+			policy:              "ap-bgp:\n- \"\"\n",
+			expectedErrorString: "empty peer name",
+		},
+		"invalid format": {
+			policy:              "ap-bgp: peer-a\n",
+			expectedErrorString: "cannot parse peers policy",
+		},
+	}
+	for desc, tc := range tcs {
+		policyFile := path.Join(t.TempDir(), "policy.yaml")
+		if err := os.WriteFile(policyFile, []byte(tc.policy), 0600); err != nil {
+			t.Fatalf("TestLoadPeersPolicy(%s): cannot write policy, err: %q", desc, err)
+		}
+		policy, err := LoadPeersPolicy(policyFile)
+		if tc.expectedErrorString != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.expectedErrorString) {
+				t.Fatalf("TestLoadPeersPolicy(%s): expected error %q but got %v", desc, tc.expectedErrorString, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("TestLoadPeersPolicy(%s): unexpected error, err: %q", desc, err)
+		}
+		if fmt.Sprint(policy) != fmt.Sprint(tc.expectedPolicy) {
+			t.Fatalf("TestLoadPeersPolicy(%s): expected policy %v but got %v", desc, tc.expectedPolicy, policy)
+		}
+	}
+}
+
+func TestApplyPeersPolicy(t *testing.T) {
+	policy := PeersPolicy{
+		"metallb-system/ap-bgp": {"peer-a"},
+		"ap-bgp":                {"peer-b"},
+		"ap-bgp2":               {"peer-c"},
+		"other-namespace/ap-l2": {"peer-d"},
+	}
+	legacyObjects := &LegacyObjects{AddressPoolList: &metallbv1beta1.AddressPoolList{Items: validAddressPools0}}
+	currentObjects, err := legacyObjects.Convert(Options{PeersPolicy: policy})
+	if err != nil {
+		t.Fatalf("TestApplyPeersPolicy: unexpected error during conversion, err: %q", err)
+	}
+	expectedPeers := map[string]string{
+		"ap-bgp-bgp-advertisement-0":  "[peer-a]",
+		"ap-bgp-bgp-advertisement-1":  "[peer-a]",
+		"ap-bgp2-bgp-advertisement-0": "[peer-c]",
+	}
+	for _, ba := range currentObjects.BGPAdvertisementList.Items {
+		if peers := fmt.Sprint(ba.Spec.Peers); peers != expectedPeers[ba.Name] {
+			t.Fatalf("TestApplyPeersPolicy: expected peers %s for %s but got %s", expectedPeers[ba.Name], ba.Name, peers)
+		}
+	}
+}
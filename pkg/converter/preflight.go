@@ -0,0 +1,132 @@
+package converter
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// AccessReviewer reviews whether the user of the migration may perform an action, e.g. the SelfSubjectAccessReviews
+// client of client-go. The reviews are not sent through the client of the migration, so that they are neither
+// audited nor recorded as mutations.
+type AccessReviewer interface {
+	Create(ctx context.Context, review *authorizationv1.SelfSubjectAccessReview,
+		opts metav1.CreateOptions) (*authorizationv1.SelfSubjectAccessReview, error)
+}
+
+// preflightKinds are the kinds that an online migration creates for every AddressPool, with their resource names.
+var preflightKinds = []struct {
+	kind     string
+	resource string
+	list     func() client.ObjectList
+}{
+	{kind: "IPAddressPool", resource: "ipaddresspools",
+		list: func() client.ObjectList { return &metallbv1beta1.IPAddressPoolList{} }},
+	{kind: "L2Advertisement", resource: "l2advertisements",
+		list: func() client.ObjectList { return &metallbv1beta1.L2AdvertisementList{} }},
+	{kind: "BGPAdvertisement", resource: "bgpadvertisements",
+		list: func() client.ObjectList { return &metallbv1beta1.BGPAdvertisementList{} }},
+}
+
+// preflight checks that an online migration of pending can run to completion before anything is modified: the
+// cluster must serve the v1beta1 kinds that the AddressPools are converted into, which requires MetalLB v0.13 or
+// newer, and the user must be allowed to create them and to delete the AddressPools, or to update them if
+// opts.KeepLegacy is set. The permissions are only checked if opts.AccessReviewer is set. It returns an error that
+// wraps ErrPreflightFailed and lists every problem.
+func preflight(ctx context.Context, c client.Client, pending *LegacyObjects, opts OnlineMigrationOptions) error {
+	if len(pending.AddressPoolList.Items) == 0 {
+		return nil
+	}
+	var problems []string
+	for _, k := range preflightKinds {
+		err := c.List(ctx, k.list(), client.Limit(1))
+		if meta.IsNoMatchError(err) {
+			problems = append(problems, fmt.Sprintf("the cluster does not serve %s/v1beta1 %ss, install the CRDs "+
+				"of MetalLB v0.13 or newer", opts.targetAPIGroup(), k.kind))
+			continue
+		}
+		if apierrors.IsForbidden(err) {
+			problems = append(problems, fmt.Sprintf("not allowed to list %s.%s: %v", k.resource,
+				opts.targetAPIGroup(), err))
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("cannot list %ss, err: %w", k.kind, err)
+		}
+	}
+
+	if opts.AccessReviewer != nil {
+		namespaces := map[string]bool{}
+		for _, ap := range pending.AddressPoolList.Items {
+			namespaces[ap.Namespace] = true
+		}
+		var sorted []string
+		for namespace := range namespaces {
+			sorted = append(sorted, namespace)
+		}
+		sort.Strings(sorted)
+		legacyVerb := "delete"
+		if opts.KeepLegacy {
+			legacyVerb = "update"
+		}
+		var attributes []authorizationv1.ResourceAttributes
+		for _, namespace := range sorted {
+			attributes = append(attributes, authorizationv1.ResourceAttributes{
+				Namespace: namespace, Verb: legacyVerb, Group: opts.sourceAPIGroup(), Resource: "addresspools",
+			})
+			for _, k := range preflightKinds {
+				attributes = append(attributes, authorizationv1.ResourceAttributes{
+					Namespace: namespace, Verb: "create", Group: opts.targetAPIGroup(), Resource: k.resource,
+				})
+			}
+		}
+		for i := range attributes {
+			problem, err := reviewAccess(ctx, opts.AccessReviewer, &attributes[i])
+			if err != nil {
+				// The API server decides for good when the migration runs, the review is only a courtesy.
+				if !quiet {
+					log.Printf("warning: cannot review the permissions of the migration, err: %q", err)
+				}
+				break
+			}
+			if problem != "" {
+				problems = append(problems, problem)
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%w:\n%s", ErrPreflightFailed, strings.Join(problems, "\n"))
+	}
+	return nil
+}
+
+// reviewAccess returns a description of the missing permission if the user may not perform the action of attributes,
+// or "" if it may.
+func reviewAccess(ctx context.Context, reviewer AccessReviewer,
+	attributes *authorizationv1.ResourceAttributes) (string, error) {
+	review, err := reviewer.Create(ctx, &authorizationv1.SelfSubjectAccessReview{
+		Spec: authorizationv1.SelfSubjectAccessReviewSpec{ResourceAttributes: attributes},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return "", err
+	}
+	if review.Status.Allowed {
+		return "", nil
+	}
+	problem := fmt.Sprintf("not allowed to %s %s.%s in namespace %s", attributes.Verb, attributes.Resource,
+		attributes.Group, attributes.Namespace)
+	if review.Status.Reason != "" {
+		problem += ": " + review.Status.Reason
+	}
+	return problem, nil
+}
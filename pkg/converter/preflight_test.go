@@ -0,0 +1,164 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// fakeAccessReviewer denies the actions of denied, keyed by "<verb> <resource>", and allows all other actions.
+type fakeAccessReviewer struct {
+	denied  map[string]bool
+	err     error
+	reviews []string
+}
+
+func (r *fakeAccessReviewer) Create(_ context.Context, review *authorizationv1.SelfSubjectAccessReview,
+	_ metav1.CreateOptions) (*authorizationv1.SelfSubjectAccessReview, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	attributes := review.Spec.ResourceAttributes
+	key := fmt.Sprintf("%s %s", attributes.Verb, attributes.Resource)
+	r.reviews = append(r.reviews, key)
+	review = review.DeepCopy()
+	review.Status.Allowed = !r.denied[key]
+	if !review.Status.Allowed {
+		review.Status.Reason = "RBAC: access denied"
+	}
+	return review, nil
+}
+
+// unservedKindClient fails to list L2Advertisements like a cluster whose MetalLB does not serve them.
+type unservedKindClient struct {
+	client.Client
+}
+
+func (c *unservedKindClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	if _, ok := list.(*metallbv1beta1.L2AdvertisementList); ok {
+		return &meta.NoKindMatchError{GroupKind: schema.GroupKind{Group: metallbAPIGroup, Kind: "L2Advertisement"},
+			SearchedVersions: []string{"v1beta1"}}
+	}
+	return c.Client.List(ctx, list, opts...)
+}
+
+func TestPreflight(t *testing.T) {
+	tcs := map[string]struct {
+		unserved            bool
+		nothingPending      bool
+		keepLegacy          bool
+		reviewer            *fakeAccessReviewer
+		expectedReviews     []string
+		expectedErrorString string
+	}{
+		"passed": {
+			reviewer: &fakeAccessReviewer{},
+			expectedReviews: []string{"delete addresspools", "create ipaddresspools", "create l2advertisements",
+				"create bgpadvertisements"},
+		},
+		"without access reviewer": {},
+		"kind not served": {
+			unserved:            true,
+			expectedErrorString: "the cluster does not serve metallb.io/v1beta1 L2Advertisements",
+		},
+		"nothing pending": {
+			unserved:       true,
+			nothingPending: true,
+			reviewer:       &fakeAccessReviewer{},
+		},
+		"delete denied": {
+			reviewer: &fakeAccessReviewer{denied: map[string]bool{"delete addresspools": true}},
+			expectedErrorString: "not allowed to delete addresspools.metallb.io in namespace metallb-system: " +
+				"RBAC: access denied",
+		},
+		"update denied with keep legacy": {
+			keepLegacy:          true,
+			reviewer:            &fakeAccessReviewer{denied: map[string]bool{"update addresspools": true}},
+			expectedErrorString: "not allowed to update addresspools.metallb.io",
+		},
+		"review fails": {
+			reviewer: &fakeAccessReviewer{err: fmt.Errorf("forbidden")},
+		},
+	}
+	var scheme = runtime.NewScheme()
+	if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestPreflight: error adding to scheme, err: %q", err)
+	}
+	SetQuiet(true)
+	defer SetQuiet(false)
+	for desc, tc := range tcs {
+		var c client.Client = fake.NewClientBuilder().WithScheme(scheme).Build()
+		if tc.unserved {
+			c = &unservedKindClient{Client: c}
+		}
+		pending := &LegacyObjects{AddressPoolList: &metallbv1beta1.AddressPoolList{}}
+		if !tc.nothingPending {
+			pending.AddressPoolList.Items = validAddressPools0
+		}
+		opts := OnlineMigrationOptions{KeepLegacy: tc.keepLegacy}
+		if tc.reviewer != nil {
+			opts.AccessReviewer = tc.reviewer
+		}
+		err := preflight(context.TODO(), c, pending, opts)
+		if tc.expectedErrorString == "" {
+			if err != nil {
+				t.Fatalf("TestPreflight(%s): unexpected error, err: %q", desc, err)
+			}
+		} else if !errors.Is(err, ErrPreflightFailed) || !strings.Contains(err.Error(), tc.expectedErrorString) {
+			t.Fatalf("TestPreflight(%s): expected error %q but got %v", desc, tc.expectedErrorString, err)
+		}
+		if tc.expectedReviews != nil && strings.Join(tc.reviewer.reviews, ",") !=
+			strings.Join(tc.expectedReviews, ",") {
+			t.Fatalf("TestPreflight(%s): expected reviews %v but got %v", desc, tc.expectedReviews,
+				tc.reviewer.reviews)
+		}
+	}
+}
+
+func TestOnlineMigrationPreflight(t *testing.T) {
+	var scheme = runtime.NewScheme()
+	if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestOnlineMigrationPreflight: error adding to scheme, err: %q", err)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+	for _, ap := range validAddressPools0 {
+		ap := ap.DeepCopy()
+		if err := c.Create(context.TODO(), ap); err != nil {
+			t.Fatalf("TestOnlineMigrationPreflight: error building fake client, err: %q", err)
+		}
+	}
+	SetQuiet(true)
+	defer SetQuiet(false)
+	stdout = bytes.NewBuffer([]byte{})
+	backupDir := t.TempDir()
+	reviewer := &fakeAccessReviewer{denied: map[string]bool{"create bgpadvertisements": true}}
+	err := OnlineMigration(context.TODO(), c, scheme, backupDir, false,
+		OnlineMigrationOptions{AccessReviewer: reviewer})
+	if !errors.Is(err, ErrPreflightFailed) || !strings.Contains(err.Error(), "error during preflight step") {
+		t.Fatalf("TestOnlineMigrationPreflight: expected the preflight checks to fail but got %v", err)
+	}
+	addressPoolList := &metallbv1beta1.AddressPoolList{}
+	if err := c.List(context.TODO(), addressPoolList); err != nil {
+		t.Fatalf("TestOnlineMigrationPreflight: cannot list AddressPools, err: %q", err)
+	}
+	if len(addressPoolList.Items) != len(validAddressPools0) {
+		t.Fatalf("TestOnlineMigrationPreflight: expected no AddressPool to be migrated but %d are left",
+			len(addressPoolList.Items))
+	}
+	if entries, err := os.ReadDir(backupDir); err != nil || len(entries) > 0 {
+		t.Fatalf("TestOnlineMigrationPreflight: expected no backup to be written, got %v, err: %v", entries, err)
+	}
+}
@@ -0,0 +1,22 @@
+package converter
+
+import "strconv"
+
+// PriorityAnnotation records the allocation priority of a converted IPAddressPool, where 1 is the most preferred
+// pool. The MetalLB API that the converter is built against (v0.13.7) has no spec.serviceAllocation.priority field
+// yet, so the priority is kept as an annotation. It can be copied into spec.serviceAllocation.priority once the
+// cluster runs a MetalLB version that supports it.
+const PriorityAnnotation = "metallb-converter.io/priority"
+
+// assignPoolPriorities sets PriorityAnnotation on all IPAddressPools in order of appearance. Priorities are counted
+// per namespace.
+func (c *CurrentObjects) assignPoolPriorities() {
+	next := map[string]int{}
+	for i, iap := range c.IPAddressPoolList.Items {
+		next[iap.Namespace]++
+		if c.IPAddressPoolList.Items[i].Annotations == nil {
+			c.IPAddressPoolList.Items[i].Annotations = map[string]string{}
+		}
+		c.IPAddressPoolList.Items[i].Annotations[PriorityAnnotation] = strconv.Itoa(next[iap.Namespace])
+	}
+}
@@ -0,0 +1,35 @@
+package converter
+
+import (
+	"testing"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+)
+
+func TestAssignPriorities(t *testing.T) {
+	tcs := map[string]struct {
+		enabled            bool
+		expectedPriorities []string
+	}{
+		"disabled": {
+			expectedPriorities: []string{"", "", ""},
+		},
+		"enabled": {
+			enabled:            true,
+			expectedPriorities: []string{"1", "2", "3"},
+		},
+	}
+	for desc, tc := range tcs {
+		legacyObjects := &LegacyObjects{AddressPoolList: &metallbv1beta1.AddressPoolList{Items: validAddressPools0}}
+		currentObjects, err := legacyObjects.Convert(Options{AssignPriorities: tc.enabled})
+		if err != nil {
+			t.Fatalf("TestAssignPriorities(%s): unexpected error during conversion, err: %q", desc, err)
+		}
+		for i, iap := range currentObjects.IPAddressPoolList.Items {
+			if iap.Annotations[PriorityAnnotation] != tc.expectedPriorities[i] {
+				t.Fatalf("TestAssignPriorities(%s): expected priority %q for %s but got %q", desc,
+					tc.expectedPriorities[i], iap.Name, iap.Annotations[PriorityAnnotation])
+			}
+		}
+	}
+}
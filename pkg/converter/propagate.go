@@ -0,0 +1,77 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// ValidateMetadataKeys returns an error if a key of Options.AdvertisementMetadataKeys is neither a qualified
+// name nor a prefix that ends with *.
+func ValidateMetadataKeys(keys []string) error {
+	for _, key := range keys {
+		if prefix := strings.TrimSuffix(key, "*"); prefix != key {
+			if strings.Contains(prefix, "*") {
+				return fmt.Errorf("invalid metadata key %q, * is only allowed at the end", key)
+			}
+			continue
+		}
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return fmt.Errorf("invalid metadata key %q: %s", key, strings.Join(errs, ", "))
+		}
+	}
+	return nil
+}
+
+// isPropagated returns true if a label or annotation key of a legacy AddressPool may be copied to the objects that are
+// converted from it. Keys of the kubernetes.io and k8s.io domains, e.g. kubectl's last-applied-configuration, are
+// managed by Kubernetes, apart from the recommended app.kubernetes.io labels. The keys of the converter carry
+// directives and state of the legacy AddressPool, and the keys of Helm and ArgoCD would make them adopt objects that
+// they did not create.
+func isPropagated(key string) bool {
+	switch key {
+	case managedByLabel, argoCDInstanceLabel:
+		return false
+	}
+	domain, _, found := strings.Cut(key, "/")
+	if !found {
+		return true
+	}
+	for _, system := range []string{"kubernetes.io", "k8s.io", "metallb-converter.io", "meta.helm.sh",
+		"argocd.argoproj.io"} {
+		if domain == system || strings.HasSuffix(domain, "."+system) {
+			return domain == "app.kubernetes.io"
+		}
+	}
+	return true
+}
+
+// isAdvertisementMetadataKey returns true if key matches one of the AdvertisementMetadataKeys of the options.
+func (o Options) isAdvertisementMetadataKey(key string) bool {
+	for _, k := range o.AdvertisementMetadataKeys {
+		if prefix := strings.TrimSuffix(k, "*"); prefix != k && strings.HasPrefix(key, prefix) || k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// propagatedMetadata returns the labels or annotations of a legacy AddressPool that are copied to its IPAddressPool,
+// or to its advertisements if advertisement is true. It returns nil if nothing is copied.
+func (o Options) propagatedMetadata(m map[string]string, advertisement bool) map[string]string {
+	if o.SkipMetadataPropagation {
+		return nil
+	}
+	var propagated map[string]string
+	for k, v := range m {
+		if !isPropagated(k) || advertisement && !o.isAdvertisementMetadataKey(k) {
+			continue
+		}
+		if propagated == nil {
+			propagated = map[string]string{}
+		}
+		propagated[k] = v
+	}
+	return propagated
+}
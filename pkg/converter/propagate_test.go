@@ -0,0 +1,115 @@
+package converter
+
+import (
+	"reflect"
+	"testing"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+)
+
+func TestIsPropagated(t *testing.T) {
+	tcs := map[string]struct {
+		key      string
+		expected bool
+	}{
+		"unprefixed":                {key: "team", expected: true},
+		"user domain":               {key: "example.com/cost-center", expected: true},
+		"recommended label":         {key: "app.kubernetes.io/name", expected: true},
+		"kubectl":                   {key: "kubectl.kubernetes.io/last-applied-configuration"},
+		"kubernetes":                {key: "kubernetes.io/description"},
+		"k8s.io subdomain":          {key: "node.k8s.io/zone"},
+		"managed-by label":          {key: managedByLabel},
+		"argocd instance label":     {key: argoCDInstanceLabel},
+		"argocd tracking id":        {key: argoCDTrackingIDAnnotation},
+		"helm release":              {key: helmReleaseNameAnnotation},
+		"converter directive":       {key: NameOverrideAnnotation},
+		"converter migration state": {key: MigratedAnnotation},
+	}
+	for desc, tc := range tcs {
+		if propagated := isPropagated(tc.key); propagated != tc.expected {
+			t.Fatalf("TestIsPropagated(%s): expected %t for %q but got %t", desc, tc.expected, tc.key, propagated)
+		}
+	}
+}
+
+func TestValidateMetadataKeys(t *testing.T) {
+	tcs := map[string]struct {
+		keys        []string
+		expectError bool
+	}{
+		"keys and prefixes": {keys: []string{"team", "example.com/cost-center", "example.com/*", "*"}},
+		"invalid key":       {keys: []string{"team", "not a key"}, expectError: true},
+		"inner wildcard":    {keys: []string{"example.*/team"}, expectError: true},
+	}
+	for desc, tc := range tcs {
+		err := ValidateMetadataKeys(tc.keys)
+		if tc.expectError != (err != nil) {
+			t.Fatalf("TestValidateMetadataKeys(%s): expected error %t but got %v", desc, tc.expectError, err)
+		}
+	}
+}
+
+func TestPropagateMetadata(t *testing.T) {
+	tcs := map[string]struct {
+		disabled                         bool
+		advertisementKeys                []string
+		expectedPoolLabels               map[string]string
+		expectedPoolAnnotations          map[string]string
+		expectedAdvertisementLabels      map[string]string
+		expectedAdvertisementAnnotations map[string]string
+	}{
+		"default": {
+			expectedPoolLabels:      map[string]string{"team": "network", "example.com/tier": "gold"},
+			expectedPoolAnnotations: map[string]string{"example.com/owner": "network team"},
+		},
+		"advertisement keys": {
+			advertisementKeys:                []string{"team", "example.com/*"},
+			expectedPoolLabels:               map[string]string{"team": "network", "example.com/tier": "gold"},
+			expectedPoolAnnotations:          map[string]string{"example.com/owner": "network team"},
+			expectedAdvertisementLabels:      map[string]string{"team": "network", "example.com/tier": "gold"},
+			expectedAdvertisementAnnotations: map[string]string{"example.com/owner": "network team"},
+		},
+		"disabled": {
+			disabled:          true,
+			advertisementKeys: []string{"team"},
+		},
+	}
+	ap := *validAddressPools0[1].DeepCopy()
+	ap.Labels = map[string]string{"team": "network", "example.com/tier": "gold", managedByLabel: "Helm"}
+	ap.Annotations = map[string]string{
+		"example.com/owner": "network team",
+		"kubectl.kubernetes.io/last-applied-configuration": "{}",
+		helmReleaseNameAnnotation:                          "metallb",
+		PriorityAnnotation:                                 "10",
+	}
+	legacyObjects := &LegacyObjects{AddressPoolList: &metallbv1beta1.AddressPoolList{
+		Items: []metallbv1beta1.AddressPool{ap},
+	}}
+	for desc, tc := range tcs {
+		currentObjects, err := legacyObjects.Convert(Options{
+			SkipMetadataPropagation:   tc.disabled,
+			AdvertisementMetadataKeys: tc.advertisementKeys,
+		})
+		if err != nil {
+			t.Fatalf("TestPropagateMetadata(%s): unexpected error during conversion, err: %q", desc, err)
+		}
+		iap := currentObjects.IPAddressPoolList.Items[0]
+		if !reflect.DeepEqual(iap.Labels, tc.expectedPoolLabels) ||
+			!reflect.DeepEqual(iap.Annotations, tc.expectedPoolAnnotations) {
+			t.Fatalf("TestPropagateMetadata(%s): expected labels %v and annotations %v on the IPAddressPool but got "+
+				"%v and %v", desc, tc.expectedPoolLabels, tc.expectedPoolAnnotations, iap.Labels, iap.Annotations)
+		}
+		for _, ba := range currentObjects.BGPAdvertisementList.Items {
+			if !reflect.DeepEqual(ba.Labels, tc.expectedAdvertisementLabels) ||
+				!reflect.DeepEqual(ba.Annotations, tc.expectedAdvertisementAnnotations) {
+				t.Fatalf("TestPropagateMetadata(%s): expected labels %v and annotations %v on BGPAdvertisement %s "+
+					"but got %v and %v", desc, tc.expectedAdvertisementLabels, tc.expectedAdvertisementAnnotations,
+					ba.Name, ba.Labels, ba.Annotations)
+			}
+		}
+	}
+	// The legacy objects must not be modified by the conversion.
+	if len(legacyObjects.AddressPoolList.Items[0].Labels) != 3 {
+		t.Fatalf("TestPropagateMetadata: legacy AddressPool labels were modified")
+	}
+}
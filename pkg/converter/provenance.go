@@ -0,0 +1,65 @@
+package converter
+
+import (
+	"time"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	// SourceNameLabel records the name of the legacy AddressPool that an object was converted from. It is omitted if
+	// the name is no valid label value, e.g. longer than 63 characters.
+	SourceNameLabel = "metallb-converter.io/source-name"
+	// SourceUIDLabel records the UID of the legacy AddressPool that an object was converted from. AddressPools that
+	// are read from files usually have no UID, it is omitted then.
+	SourceUIDLabel = "metallb-converter.io/source-uid"
+	// SourceHashAnnotation records the hash of the spec of the legacy AddressPool that an object was converted from.
+	SourceHashAnnotation = "metallb-converter.io/source-hash"
+	// ConvertedAtAnnotation records when an object was converted, in RFC 3339 format.
+	ConvertedAtAnnotation = "metallb-converter.io/converted-at"
+)
+
+// provenanceNow returns the time that ConvertedAtAnnotation records. Tests replace it.
+var provenanceNow = func() time.Time { return time.Now().UTC() }
+
+// addProvenance adds the provenance labels and annotations of ap to objectMeta.
+func addProvenance(objectMeta *metav1.ObjectMeta, ap metallbv1beta1.AddressPool) {
+	labels := map[string]string{}
+	if len(validation.IsValidLabelValue(ap.Name)) == 0 {
+		labels[SourceNameLabel] = ap.Name
+	}
+	if ap.UID != "" {
+		labels[SourceUIDLabel] = string(ap.UID)
+	}
+	objectMeta.Labels = mergeStringMaps(objectMeta.Labels, labels)
+	objectMeta.Annotations = mergeStringMaps(objectMeta.Annotations, map[string]string{
+		SourceHashAnnotation:  specHash(ap.Spec),
+		ConvertedAtAnnotation: provenanceNow().Format(time.RFC3339),
+	})
+}
+
+// withoutProvenance returns a copy of objectMeta without the provenance labels and annotations, so that objects that
+// are converted from different AddressPools can be compared.
+func withoutProvenance(objectMeta metav1.ObjectMeta) metav1.ObjectMeta {
+	strip := func(m map[string]string, keys ...string) map[string]string {
+		if len(m) == 0 {
+			return m
+		}
+		stripped := map[string]string{}
+		for k, v := range m {
+			stripped[k] = v
+		}
+		for _, key := range keys {
+			delete(stripped, key)
+		}
+		if len(stripped) == 0 {
+			return nil
+		}
+		return stripped
+	}
+	objectMeta.Labels = strip(objectMeta.Labels, SourceNameLabel, SourceUIDLabel)
+	objectMeta.Annotations = strip(objectMeta.Annotations, SourceHashAnnotation, ConvertedAtAnnotation)
+	return objectMeta
+}
@@ -0,0 +1,121 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andreaskaris/metallb-converter/pkg/features"
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestProvenance(t *testing.T) {
+	longName := strings.Repeat("a", 64)
+	tcs := map[string]struct {
+		enabled        bool
+		name           string
+		uid            types.UID
+		expectedLabels map[string]string
+	}{
+		"disabled": {
+			name: "ap-bgp",
+			uid:  "2d3b6c1e-0f4a-4b6e-9c1d-6a7e8f9b0c1d",
+		},
+		"enabled": {
+			enabled: true,
+			name:    "ap-bgp",
+			uid:     "2d3b6c1e-0f4a-4b6e-9c1d-6a7e8f9b0c1d",
+			expectedLabels: map[string]string{
+				SourceNameLabel: "ap-bgp",
+				SourceUIDLabel:  "2d3b6c1e-0f4a-4b6e-9c1d-6a7e8f9b0c1d",
+			},
+		},
+		"enabled without uid": {
+			enabled:        true,
+			name:           "ap-bgp",
+			expectedLabels: map[string]string{SourceNameLabel: "ap-bgp"},
+		},
+		"enabled with a name that is no label value": {
+			enabled:        true,
+			name:           longName,
+			uid:            "2d3b6c1e-0f4a-4b6e-9c1d-6a7e8f9b0c1d",
+			expectedLabels: map[string]string{SourceUIDLabel: "2d3b6c1e-0f4a-4b6e-9c1d-6a7e8f9b0c1d"},
+		},
+	}
+	convertedAt := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	defer func(now func() time.Time) { provenanceNow = now }(provenanceNow)
+	provenanceNow = func() time.Time { return convertedAt }
+	for desc, tc := range tcs {
+		ap := *validAddressPools0[1].DeepCopy()
+		ap.Name, ap.UID = tc.name, tc.uid
+		legacyObjects := &LegacyObjects{AddressPoolList: &metallbv1beta1.AddressPoolList{
+			Items: []metallbv1beta1.AddressPool{ap},
+		}}
+		currentObjects, err := legacyObjects.Convert(Options{Provenance: tc.enabled})
+		if err != nil {
+			t.Fatalf("TestProvenance(%s): unexpected error during conversion, err: %q", desc, err)
+		}
+		metas := []metav1.ObjectMeta{currentObjects.IPAddressPoolList.Items[0].ObjectMeta}
+		for _, ba := range currentObjects.BGPAdvertisementList.Items {
+			metas = append(metas, ba.ObjectMeta)
+		}
+		for _, objectMeta := range metas {
+			if len(objectMeta.Labels) != len(tc.expectedLabels) {
+				t.Fatalf("TestProvenance(%s): expected labels %v but got %v", desc, tc.expectedLabels,
+					objectMeta.Labels)
+			}
+			for k, v := range tc.expectedLabels {
+				if objectMeta.Labels[k] != v {
+					t.Fatalf("TestProvenance(%s): expected labels %v but got %v", desc, tc.expectedLabels,
+						objectMeta.Labels)
+				}
+			}
+			if !tc.enabled {
+				if len(objectMeta.Annotations) != 0 {
+					t.Fatalf("TestProvenance(%s): expected no annotations but got %v", desc, objectMeta.Annotations)
+				}
+				continue
+			}
+			if hash := objectMeta.Annotations[SourceHashAnnotation]; hash != specHash(ap.Spec) {
+				t.Fatalf("TestProvenance(%s): expected source hash %s but got %q", desc, specHash(ap.Spec), hash)
+			}
+			if at := objectMeta.Annotations[ConvertedAtAnnotation]; at != "2023-01-02T03:04:05Z" {
+				t.Fatalf("TestProvenance(%s): expected conversion time 2023-01-02T03:04:05Z but got %q", desc, at)
+			}
+		}
+	}
+}
+
+func TestProvenanceMergeAdvertisements(t *testing.T) {
+	if err := features.Set("MergeAdvertisements=true"); err != nil {
+		t.Fatalf("TestProvenanceMergeAdvertisements: cannot enable the feature gate, err: %q", err)
+	}
+	defer features.Set("")
+
+	var addressPools []metallbv1beta1.AddressPool
+	for _, name := range []string{"l2-a", "l2-b"} {
+		ap := *validAddressPools0[0].DeepCopy()
+		ap.Name = name
+		ap.Spec.Addresses = []string{"10.0." + fmt.Sprint(len(addressPools)) + ".0/24"}
+		addressPools = append(addressPools, ap)
+	}
+	legacyObjects := &LegacyObjects{AddressPoolList: &metallbv1beta1.AddressPoolList{Items: addressPools}}
+	currentObjects, err := legacyObjects.Convert(Options{Provenance: true})
+	if err != nil {
+		t.Fatalf("TestProvenanceMergeAdvertisements: unexpected error during conversion, err: %q", err)
+	}
+	l2as := currentObjects.L2AdvertisementList.Items
+	if len(l2as) != 1 || l2as[0].Labels[SourceNameLabel] != "l2-a" {
+		t.Fatalf("TestProvenanceMergeAdvertisements: expected one L2Advertisement with the provenance of l2-a but "+
+			"got %v", l2as)
+	}
+	for _, iap := range currentObjects.IPAddressPoolList.Items {
+		if iap.Labels[SourceNameLabel] != iap.Name {
+			t.Fatalf("TestProvenanceMergeAdvertisements: expected IPAddressPool %s to record its own source but got "+
+				"%v", iap.Name, iap.Labels)
+		}
+	}
+}
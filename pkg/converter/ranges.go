@@ -0,0 +1,84 @@
+package converter
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+)
+
+// splitPerRange splits every IPAddressPool with more than one address range into one IPAddressPool per range. An
+// advertisement that only references a split pool is duplicated for every new pool. All other references are
+// replaced by references to all new pools.
+func (c *CurrentObjects) splitPerRange() {
+	split := map[string][]string{}
+	var items []metallbv1beta1.IPAddressPool
+	for _, iap := range c.IPAddressPoolList.Items {
+		if len(iap.Spec.Addresses) < 2 {
+			items = append(items, iap)
+			continue
+		}
+		var names []string
+		for i, address := range iap.Spec.Addresses {
+			rangePool := *iap.DeepCopy()
+			rangePool.Name = fmt.Sprintf("%s-%d", iap.Name, i)
+			rangePool.Spec.Addresses = []string{address}
+			items = append(items, rangePool)
+			names = append(names, rangePool.Name)
+		}
+		split[fmt.Sprintf("%s/%s", iap.Namespace, iap.Name)] = names
+		if !quiet {
+			log.Printf("IPAddressPool %s/%s was split into %s, update Services that request it with the %s "+
+				"annotation", iap.Namespace, iap.Name, strings.Join(names, ", "), ServiceAddressPoolAnnotation)
+		}
+	}
+	if len(split) == 0 {
+		return
+	}
+	c.IPAddressPoolList.Items = items
+
+	var l2Items []metallbv1beta1.L2Advertisement
+	for _, l2a := range c.L2AdvertisementList.Items {
+		pools := l2a.Spec.IPAddressPools
+		if len(pools) == 1 && split[fmt.Sprintf("%s/%s", l2a.Namespace, pools[0])] != nil {
+			for _, name := range split[fmt.Sprintf("%s/%s", l2a.Namespace, pools[0])] {
+				rangeL2a := *l2a.DeepCopy()
+				rangeL2a.Name = duplicatedName(l2a.Name, pools[0], name)
+				rangeL2a.Spec.IPAddressPools = []string{name}
+				l2Items = append(l2Items, rangeL2a)
+			}
+			continue
+		}
+		l2a.Spec.IPAddressPools = replacePools(split, l2a.Namespace, pools)
+		l2Items = append(l2Items, l2a)
+	}
+	c.L2AdvertisementList.Items = l2Items
+
+	var bgpItems []metallbv1beta1.BGPAdvertisement
+	for _, ba := range c.BGPAdvertisementList.Items {
+		pools := ba.Spec.IPAddressPools
+		if len(pools) == 1 && split[fmt.Sprintf("%s/%s", ba.Namespace, pools[0])] != nil {
+			for _, name := range split[fmt.Sprintf("%s/%s", ba.Namespace, pools[0])] {
+				rangeBa := *ba.DeepCopy()
+				rangeBa.Name = duplicatedName(ba.Name, pools[0], name)
+				rangeBa.Spec.IPAddressPools = []string{name}
+				bgpItems = append(bgpItems, rangeBa)
+			}
+			continue
+		}
+		ba.Spec.IPAddressPools = replacePools(split, ba.Namespace, pools)
+		bgpItems = append(bgpItems, ba)
+	}
+	c.BGPAdvertisementList.Items = bgpItems
+}
+
+// duplicatedName returns the name of the copy of the advertisement name of pool for the new pool newPool. If name
+// starts with the pool name, as all converted advertisements do, the pool name is replaced. Otherwise, the suffix of
+// newPool is appended.
+func duplicatedName(name, pool, newPool string) string {
+	if strings.HasPrefix(name, pool+"-") {
+		return newPool + strings.TrimPrefix(name, pool)
+	}
+	return name + strings.TrimPrefix(newPool, pool)
+}
@@ -0,0 +1,101 @@
+package converter
+
+import (
+	"fmt"
+	"testing"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+)
+
+func TestSplitPerRange(t *testing.T) {
+	tcs := map[string]struct {
+		protocol        string
+		addresses       []string
+		advertisements  []metallbv1beta1.LegacyBgpAdvertisement
+		expectedPools   string
+		expectedL2Pools string
+		expectedBGPAds  string
+	}{
+		"layer2 with two ranges": {
+			protocol:        ProtocolLayer2,
+			addresses:       []string{"192.168.0.0/24", "192.168.1.1-192.168.1.10"},
+			expectedPools:   "[ap-0:[192.168.0.0/24] ap-1:[192.168.1.1-192.168.1.10]]",
+			expectedL2Pools: "[ap-0-l2-advertisement:[ap-0] ap-1-l2-advertisement:[ap-1]]",
+			expectedBGPAds:  "[]",
+		},
+		"layer2 with a single range": {
+			protocol:        ProtocolLayer2,
+			addresses:       []string{"2001:db8::/120"},
+			expectedPools:   "[ap:[2001:db8::/120]]",
+			expectedL2Pools: "[ap-l2-advertisement:[ap]]",
+			expectedBGPAds:  "[]",
+		},
+		"bgp with two ranges and two advertisements": {
+			protocol:  ProtocolBGP,
+			addresses: []string{"192.168.0.0/24", "2001:db8::/120"},
+			advertisements: []metallbv1beta1.LegacyBgpAdvertisement{
+				{LocalPref: 10},
+				{LocalPref: 20, AggregationLength: pointer.Int32(24)},
+			},
+			expectedPools:   "[ap-0:[192.168.0.0/24] ap-1:[2001:db8::/120]]",
+			expectedL2Pools: "[]",
+			expectedBGPAds: "[ap-0-bgp-advertisement-0:[ap-0]:10 ap-1-bgp-advertisement-0:[ap-1]:10 " +
+				"ap-0-bgp-advertisement-1:[ap-0]:20 ap-1-bgp-advertisement-1:[ap-1]:20]",
+		},
+	}
+	for desc, tc := range tcs {
+		ap := metallbv1beta1.AddressPool{
+			ObjectMeta: metav1.ObjectMeta{Name: "ap", Namespace: "metallb-system"},
+			Spec: metallbv1beta1.AddressPoolSpec{
+				Protocol:          tc.protocol,
+				Addresses:         tc.addresses,
+				BGPAdvertisements: tc.advertisements,
+			},
+		}
+		legacyObjects := &LegacyObjects{AddressPoolList: &metallbv1beta1.AddressPoolList{
+			Items: []metallbv1beta1.AddressPool{ap},
+		}}
+		currentObjects, err := legacyObjects.Convert(Options{SplitPoolsPerRange: true})
+		if err != nil {
+			t.Fatalf("TestSplitPerRange(%s): unexpected error during conversion, err: %q", desc, err)
+		}
+		var pools, l2Pools, bgpAds []string
+		for _, iap := range currentObjects.IPAddressPoolList.Items {
+			pools = append(pools, fmt.Sprintf("%s:%v", iap.Name, iap.Spec.Addresses))
+		}
+		for _, l2a := range currentObjects.L2AdvertisementList.Items {
+			l2Pools = append(l2Pools, fmt.Sprintf("%s:%v", l2a.Name, l2a.Spec.IPAddressPools))
+		}
+		for _, ba := range currentObjects.BGPAdvertisementList.Items {
+			bgpAds = append(bgpAds, fmt.Sprintf("%s:%v:%d", ba.Name, ba.Spec.IPAddressPools, ba.Spec.LocalPref))
+		}
+		if fmt.Sprint(pools) != tc.expectedPools {
+			t.Fatalf("TestSplitPerRange(%s): expected pools %s but got %v", desc, tc.expectedPools, pools)
+		}
+		if fmt.Sprint(l2Pools) != tc.expectedL2Pools {
+			t.Fatalf("TestSplitPerRange(%s): expected L2Advertisements %s but got %v", desc, tc.expectedL2Pools,
+				l2Pools)
+		}
+		if fmt.Sprint(bgpAds) != tc.expectedBGPAds {
+			t.Fatalf("TestSplitPerRange(%s): expected BGPAdvertisements %s but got %v", desc, tc.expectedBGPAds,
+				bgpAds)
+		}
+	}
+}
+
+func TestDuplicatedName(t *testing.T) {
+	tcs := map[string]struct {
+		name     string
+		expected string
+	}{
+		"converted name": {name: "ap-l2-advertisement", expected: "ap-1-l2-advertisement"},
+		"custom name":    {name: "custom", expected: "custom-1"},
+	}
+	for desc, tc := range tcs {
+		if name := duplicatedName(tc.name, "ap", "ap-1"); name != tc.expected {
+			t.Fatalf("TestDuplicatedName(%s): expected %q but got %q", desc, tc.expected, name)
+		}
+	}
+}
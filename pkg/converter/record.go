@@ -0,0 +1,239 @@
+package converter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// RecordingFileName is the name of the file inside the recording directory that holds the recording.
+const RecordingFileName = "recording.json"
+
+// Recording holds all objects that a run read from the API and all mutations that it sent to the API.
+type Recording struct {
+	Version   string                   `json:"version"`
+	Args      []string                 `json:"args,omitempty"`
+	Objects   []map[string]interface{} `json:"objects"`
+	Mutations []RecordedMutation       `json:"mutations"`
+}
+
+// RecordedMutation describes a single Create, Delete, Update or Patch call.
+type RecordedMutation struct {
+	Operation string                 `json:"operation"`
+	Kind      string                 `json:"kind"`
+	Namespace string                 `json:"namespace,omitempty"`
+	Name      string                 `json:"name"`
+	Object    map[string]interface{} `json:"object,omitempty"`
+	Error     string                 `json:"error,omitempty"`
+}
+
+// String returns a short description of the mutation.
+func (m RecordedMutation) String() string {
+	s := fmt.Sprintf("%s %s %s/%s", m.Operation, m.Kind, m.Namespace, m.Name)
+	if m.Error != "" {
+		s = fmt.Sprintf("%s (failed: %s)", s, m.Error)
+	}
+	return s
+}
+
+// Recorder captures every object that is read through a wrapped client and every mutation that is sent through it,
+// so that a run can be replayed without cluster access.
+type Recorder struct {
+	mu        sync.Mutex
+	seen      map[string]bool
+	recording Recording
+}
+
+// NewRecorder returns a Recorder. version and args identify the run in the recording.
+func NewRecorder(version string, args []string) *Recorder {
+	return &Recorder{seen: map[string]bool{}, recording: Recording{Version: version, Args: args}}
+}
+
+// WrapClient returns a client that records the results of Get and List and every Create, Delete, Update and Patch.
+func (r *Recorder) WrapClient(c client.Client) client.Client {
+	return &recordingClient{Client: c, recorder: r}
+}
+
+// Mutations returns the mutations that were recorded so far.
+func (r *Recorder) Mutations() []RecordedMutation {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]RecordedMutation(nil), r.recording.Mutations...)
+}
+
+// Save writes the recording to RecordingFileName inside dir.
+func (r *Recorder) Save(dir string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	data, err := json.MarshalIndent(r.recording, "", "  ")
+	if err != nil {
+		return fmt.Errorf("cannot marshal recording, err: %w", err)
+	}
+	if err := os.WriteFile(path.Join(dir, RecordingFileName), data, 0600); err != nil {
+		return fmt.Errorf("cannot write recording, err: %w", err)
+	}
+	return nil
+}
+
+// read records obj, which was read from the API. Only the state of an object before the run changed it is recorded:
+// later reads of the same object and reads of objects that the run mutated before are ignored.
+func (r *Recorder) read(c client.Client, obj runtime.Object) {
+	u, err := toUnstructured(c, obj)
+	if err != nil {
+		log.Printf("warning: cannot record object, err: %q", err)
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if key := recordingKey(u); !r.seen[key] {
+		r.seen[key] = true
+		r.recording.Objects = append(r.recording.Objects, u.Object)
+	}
+}
+
+// mutate records a mutation of obj.
+func (r *Recorder) mutate(c client.Client, operation string, obj client.Object, opErr error) {
+	m := RecordedMutation{Operation: operation, Namespace: obj.GetNamespace(), Name: obj.GetName()}
+	u, err := toUnstructured(c, obj)
+	if err == nil {
+		m.Kind = u.GetKind()
+		m.Object = u.Object
+	}
+	if opErr != nil {
+		m.Error = opErr.Error()
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if u != nil {
+		r.seen[recordingKey(u)] = true
+	}
+	r.recording.Mutations = append(r.recording.Mutations, m)
+}
+
+// recordingKey identifies the object u in a Recording.
+func recordingKey(u *unstructured.Unstructured) string {
+	return fmt.Sprintf("%s/%s/%s", u.GroupVersionKind(), u.GetNamespace(), u.GetName())
+}
+
+// toUnstructured returns a copy of obj with its apiVersion and kind set.
+func toUnstructured(c client.Client, obj runtime.Object) (*unstructured.Unstructured, error) {
+	gvk, err := apiutil.GVKForObject(obj, c.Scheme())
+	if err != nil {
+		return nil, err
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj.DeepCopyObject())
+	if err != nil {
+		return nil, err
+	}
+	u := &unstructured.Unstructured{Object: content}
+	u.SetGroupVersionKind(gvk)
+	return u, nil
+}
+
+// LoadRecording reads the recording inside dir.
+func LoadRecording(dir string) (*Recording, error) {
+	data, err := os.ReadFile(path.Join(dir, RecordingFileName))
+	if err != nil {
+		return nil, fmt.Errorf("cannot read recording, err: %w", err)
+	}
+	recording := &Recording{}
+	if err := json.Unmarshal(data, recording); err != nil {
+		return nil, fmt.Errorf("cannot decode recording, err: %w", err)
+	}
+	return recording, nil
+}
+
+// Client returns a client that serves the recorded objects and that is not connected to any cluster. Mutations only
+// change the state of the returned client.
+func (rec *Recording) Client(scheme *runtime.Scheme) client.Client {
+	var objects []client.Object
+	for _, object := range rec.Objects {
+		objects = append(objects, &unstructured.Unstructured{Object: object})
+	}
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+}
+
+// CompareMutations returns a description of every difference between the recorded mutations and mutations, e.g. the
+// mutations of a replay. Only the operation, kind, namespace and name are compared.
+func (rec *Recording) CompareMutations(mutations []RecordedMutation) []string {
+	var differences []string
+	for i := 0; i < len(rec.Mutations) || i < len(mutations); i++ {
+		switch {
+		case i >= len(mutations):
+			differences = append(differences, fmt.Sprintf("recorded %s was not replayed", rec.Mutations[i]))
+		case i >= len(rec.Mutations):
+			differences = append(differences, fmt.Sprintf("replayed %s was not recorded", mutations[i]))
+		case rec.Mutations[i].String() != mutations[i].String():
+			differences = append(differences, fmt.Sprintf("recorded %s, but replayed %s", rec.Mutations[i],
+				mutations[i]))
+		}
+	}
+	return differences
+}
+
+// recordingClient records reads and mutations in a Recorder.
+type recordingClient struct {
+	client.Client
+	recorder *Recorder
+}
+
+func (rc *recordingClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object,
+	opts ...client.GetOption) error {
+	err := rc.Client.Get(ctx, key, obj, opts...)
+	if err == nil {
+		rc.recorder.read(rc.Client, obj)
+	}
+	return err
+}
+
+func (rc *recordingClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	err := rc.Client.List(ctx, list, opts...)
+	if err != nil {
+		return err
+	}
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		log.Printf("warning: cannot record list, err: %q", err)
+		return nil
+	}
+	for _, item := range items {
+		rc.recorder.read(rc.Client, item)
+	}
+	return nil
+}
+
+func (rc *recordingClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	err := rc.Client.Create(ctx, obj, opts...)
+	rc.recorder.mutate(rc.Client, auditOperationCreate, obj, err)
+	return err
+}
+
+func (rc *recordingClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	err := rc.Client.Delete(ctx, obj, opts...)
+	rc.recorder.mutate(rc.Client, auditOperationDelete, obj, err)
+	return err
+}
+
+func (rc *recordingClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	err := rc.Client.Update(ctx, obj, opts...)
+	rc.recorder.mutate(rc.Client, auditOperationUpdate, obj, err)
+	return err
+}
+
+func (rc *recordingClient) Patch(ctx context.Context, obj client.Object, patch client.Patch,
+	opts ...client.PatchOption) error {
+	err := rc.Client.Patch(ctx, obj, patch, opts...)
+	rc.recorder.mutate(rc.Client, auditOperationPatch, obj, err)
+	return err
+}
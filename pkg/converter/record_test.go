@@ -0,0 +1,58 @@
+package converter
+
+import (
+	"context"
+	"testing"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	var scheme = runtime.NewScheme()
+	if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestRecordAndReplay: error adding to scheme, err: %q", err)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+	for _, ap := range validAddressPools0 {
+		if err := c.Create(context.TODO(), ap.DeepCopy()); err != nil {
+			t.Fatalf("TestRecordAndReplay: error building fake client, err: %q", err)
+		}
+	}
+
+	recordDir := t.TempDir()
+	recorder := NewRecorder("test", []string{"-online-migration"})
+	err := OnlineMigration(context.TODO(), recorder.WrapClient(c), scheme, t.TempDir(), false, OnlineMigrationOptions{})
+	if err != nil {
+		t.Fatalf("TestRecordAndReplay: unexpected error during recorded migration, err: %q", err)
+	}
+	if err := recorder.Save(recordDir); err != nil {
+		t.Fatalf("TestRecordAndReplay: cannot save recording, err: %q", err)
+	}
+	recording, err := LoadRecording(recordDir)
+	if err != nil {
+		t.Fatalf("TestRecordAndReplay: cannot load recording, err: %q", err)
+	}
+	if len(recording.Objects) != len(validAddressPools0) {
+		t.Fatalf("TestRecordAndReplay: expected the %d AddressPools to be recorded, got %d objects",
+			len(validAddressPools0), len(recording.Objects))
+	}
+	if len(recording.Mutations) == 0 {
+		t.Fatalf("TestRecordAndReplay: expected mutations to be recorded")
+	}
+
+	replayer := NewRecorder("test", nil)
+	replayClient := replayer.WrapClient(recording.Client(scheme))
+	if err := OnlineMigration(context.TODO(), replayClient, scheme, t.TempDir(), false,
+		OnlineMigrationOptions{}); err != nil {
+		t.Fatalf("TestRecordAndReplay: unexpected error during replay, err: %q", err)
+	}
+	if differences := recording.CompareMutations(replayer.Mutations()); len(differences) > 0 {
+		t.Fatalf("TestRecordAndReplay: replay differs from the recording: %v", differences)
+	}
+	if differences := recording.CompareMutations(nil); len(differences) != len(recording.Mutations) {
+		t.Fatalf("TestRecordAndReplay: expected %d differences to an empty replay, got %v", len(recording.Mutations),
+			differences)
+	}
+}
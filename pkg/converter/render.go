@@ -0,0 +1,24 @@
+package converter
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// render runs command with args appended and returns its standard output. what names the rendered input in errors.
+func render(what string, command []string, args ...string) ([]byte, error) {
+	if len(command) == 0 {
+		return nil, fmt.Errorf("cannot render %s, no command given", what)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(command[0], append(append([]string{}, command[1:]...), args...)...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("cannot render %s with %q, stderr: %q, err: %w", what, strings.Join(command, " "),
+			strings.TrimSpace(stderr.String()), err)
+	}
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,315 @@
+package converter
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	metallbv1beta2 "go.universe.tf/metallb/api/v1beta2"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// MigrationModeOffline is the mode of the report of OfflineMigration.
+	MigrationModeOffline = "offline"
+	// MigrationModeOnline is the mode of the report of OnlineMigration.
+	MigrationModeOnline = "online"
+)
+
+const (
+	// MigrationConverted is the status of a legacy object that an offline migration converted.
+	MigrationConverted = "converted"
+	// MigrationMigrated is the status of a legacy object that an online migration migrated.
+	MigrationMigrated = "migrated"
+	// MigrationSkipped is the status of a legacy object that was not processed, the reason tells why.
+	MigrationSkipped = "skipped"
+	// MigrationFailed is the status of a legacy object that could not be converted or migrated.
+	MigrationFailed = "failed"
+)
+
+// MigrationReport is the machine-readable record of an OfflineMigration or OnlineMigration: every legacy object that
+// was processed, the new objects that were generated from it, the skipped objects and the errors. The migrations
+// fill it if it is set in their options.
+type MigrationReport struct {
+	Mode      string            `json:"mode"`
+	Timestamp time.Time         `json:"timestamp"`
+	Summary   MigrationSummary  `json:"summary"`
+	Objects   []ObjectMigration `json:"objects"`
+	Errors    []string          `json:"errors,omitempty"`
+}
+
+// MigrationSummary counts the legacy objects of the report by their status, and the generated objects.
+type MigrationSummary struct {
+	LegacyObjects    int `json:"legacyObjects"`
+	Converted        int `json:"converted"`
+	Migrated         int `json:"migrated"`
+	Skipped          int `json:"skipped"`
+	Failed           int `json:"failed"`
+	GeneratedObjects int `json:"generatedObjects"`
+}
+
+// ObjectMigration is the result of a single legacy object.
+type ObjectMigration struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Status    string `json:"status"`
+	// Reason tells why the object was skipped.
+	Reason string `json:"reason,omitempty"`
+	// Generated are the new objects that the legacy object was converted into.
+	Generated []ComplianceResource `json:"generated,omitempty"`
+	Error     string               `json:"error,omitempty"`
+}
+
+// start resets r for a migration in mode. All methods of MigrationReport do nothing on a nil report.
+func (r *MigrationReport) start(mode string) {
+	if r == nil {
+		return
+	}
+	*r = MigrationReport{Mode: mode, Timestamp: time.Now().UTC(), Objects: []ObjectMigration{}}
+}
+
+// finish records err, if any, and counts the objects of r.
+func (r *MigrationReport) finish(err error) {
+	if r == nil {
+		return
+	}
+	if err != nil {
+		r.Errors = append(r.Errors, err.Error())
+	}
+	r.Summary = MigrationSummary{LegacyObjects: len(r.Objects)}
+	for _, object := range r.Objects {
+		switch object.Status {
+		case MigrationConverted:
+			r.Summary.Converted++
+		case MigrationMigrated:
+			r.Summary.Migrated++
+		case MigrationSkipped:
+			r.Summary.Skipped++
+		case MigrationFailed:
+			r.Summary.Failed++
+		}
+		r.Summary.GeneratedObjects += len(object.Generated)
+	}
+}
+
+// addPool records the result of the legacy AddressPool namespace/name.
+func (r *MigrationReport) addPool(namespace, name, status, reason string, generated []ComplianceResource,
+	err error) {
+	if r == nil {
+		return
+	}
+	object := ObjectMigration{
+		Kind: "AddressPool", Namespace: namespace, Name: name, Status: status, Reason: reason, Generated: generated,
+	}
+	if err != nil {
+		object.Error = err.Error()
+	}
+	r.Objects = append(r.Objects, object)
+}
+
+// addPools records the same result for all AddressPools of legacy.
+func (r *MigrationReport) addPools(legacy *LegacyObjects, status, reason string, err error) {
+	if r == nil || legacy == nil || legacy.AddressPoolList == nil {
+		return
+	}
+	for _, ap := range legacy.AddressPoolList.Items {
+		r.addPool(ap.Namespace, ap.Name, status, reason, nil, err)
+	}
+}
+
+// addConversion records the batch conversion of legacy into current and the extra objects of an offline migration.
+// The new objects of an AddressPool are its IPAddressPools, the advertisements that reference them and the
+// Communities of these advertisements. Pools that were merged or split are attributed by their addresses. The
+// extra objects are attributed to the legacy BGPPeers and BFDProfiles of the same name, the remaining ones to
+// the legacy ConfigMap configMap.
+func (r *MigrationReport) addConversion(legacy *LegacyObjects, current *CurrentObjects, extra [][]runtime.Object,
+	configMap types.NamespacedName) {
+	if r == nil {
+		return
+	}
+	for _, ap := range legacy.AddressPoolList.Items {
+		r.addPool(ap.Namespace, ap.Name, MigrationConverted, "", current.poolResources(ap), nil)
+	}
+
+	legacyPeers := map[string]bool{}
+	if legacy.BGPPeerList != nil {
+		for _, peer := range legacy.BGPPeerList.Items {
+			legacyPeers[peer.Namespace+"/"+peer.Name] = true
+		}
+	}
+	legacyProfiles := map[string]bool{}
+	if legacy.BFDProfileList != nil {
+		for _, profile := range legacy.BFDProfileList.Items {
+			legacyProfiles[profile.Namespace+"/"+profile.Name] = true
+		}
+	}
+	peers := map[string][]ComplianceResource{}
+	profiles := map[string][]ComplianceResource{}
+	secrets := map[string]string{}
+	var remaining []ComplianceResource
+	for _, group := range extra {
+		for _, obj := range group {
+			resource := reportResource(obj)
+			key := resource.Namespace + "/" + resource.Name
+			switch o := obj.(type) {
+			case *metallbv1beta2.BGPPeer:
+				if legacyPeers[key] {
+					peers[key] = append(peers[key], resource)
+					if o.Spec.PasswordSecret.Name != "" {
+						secrets[o.Namespace+"/"+o.Spec.PasswordSecret.Name] = key
+					}
+					continue
+				}
+			case *metallbv1beta1.BFDProfile:
+				if legacyProfiles[key] {
+					profiles[key] = append(profiles[key], resource)
+					continue
+				}
+			}
+			remaining = append(remaining, resource)
+		}
+	}
+	var configResources []ComplianceResource
+	for _, resource := range remaining {
+		if peer, ok := secrets[resource.Namespace+"/"+resource.Name]; ok && resource.Kind == "Secret" {
+			peers[peer] = append(peers[peer], resource)
+			continue
+		}
+		configResources = append(configResources, resource)
+	}
+	if legacy.BGPPeerList != nil {
+		for _, peer := range legacy.BGPPeerList.Items {
+			r.Objects = append(r.Objects, ObjectMigration{Kind: "BGPPeer", Namespace: peer.Namespace,
+				Name: peer.Name, Status: MigrationConverted, Generated: peers[peer.Namespace+"/"+peer.Name]})
+		}
+	}
+	if legacy.BFDProfileList != nil {
+		for _, profile := range legacy.BFDProfileList.Items {
+			r.Objects = append(r.Objects, ObjectMigration{Kind: "BFDProfile", Namespace: profile.Namespace,
+				Name: profile.Name, Status: MigrationConverted,
+				Generated: profiles[profile.Namespace+"/"+profile.Name]})
+		}
+	}
+	if configMap.Name != "" {
+		r.Objects = append(r.Objects, ObjectMigration{Kind: "ConfigMap", Namespace: configMap.Namespace,
+			Name: configMap.Name, Status: MigrationConverted, Generated: configResources})
+	}
+}
+
+// reportResource returns the kind, namespace and name of obj.
+func reportResource(obj runtime.Object) ComplianceResource {
+	resource := ComplianceResource{Kind: obj.GetObjectKind().GroupVersionKind().Kind}
+	if accessor, err := meta.Accessor(obj); err == nil {
+		resource.Namespace, resource.Name = accessor.GetNamespace(), accessor.GetName()
+	}
+	return resource
+}
+
+// poolResources returns the objects of c that were generated from the legacy AddressPool ap, see addConversion.
+func (c *CurrentObjects) poolResources(ap metallbv1beta1.AddressPool) []ComplianceResource {
+	legacyAddresses := map[string]bool{}
+	for _, address := range ap.Spec.Addresses {
+		legacyAddresses[address] = true
+	}
+	// The IPAddressPool of the same name, or else the IPAddressPools that only contain addresses of ap.
+	var matched []metallbv1beta1.IPAddressPool
+	for _, iap := range c.IPAddressPoolList.Items {
+		if iap.Namespace == ap.Namespace && iap.Name == convertedPoolName(ap) {
+			matched = append(matched, iap)
+		}
+	}
+	for _, iap := range c.IPAddressPoolList.Items {
+		if len(matched) > 0 && matched[0].Name == convertedPoolName(ap) {
+			break
+		}
+		fromAddresses := iap.Namespace == ap.Namespace && len(iap.Spec.Addresses) > 0
+		for _, address := range iap.Spec.Addresses {
+			fromAddresses = fromAddresses && legacyAddresses[address]
+		}
+		if fromAddresses {
+			matched = append(matched, iap)
+		}
+	}
+	var resources []ComplianceResource
+	pools := map[string]bool{}
+	for _, iap := range matched {
+		pools[iap.Name] = true
+		resources = append(resources, ComplianceResource{Kind: "IPAddressPool", Namespace: iap.Namespace,
+			Name: iap.Name})
+	}
+	references := func(namespace string, names []string) bool {
+		for _, name := range names {
+			if namespace == ap.Namespace && pools[name] {
+				return true
+			}
+		}
+		return false
+	}
+	for _, l2a := range c.L2AdvertisementList.Items {
+		if references(l2a.Namespace, l2a.Spec.IPAddressPools) {
+			resources = append(resources, ComplianceResource{Kind: "L2Advertisement", Namespace: l2a.Namespace,
+				Name: l2a.Name})
+		}
+	}
+	aliases := map[string]bool{}
+	for _, ba := range c.BGPAdvertisementList.Items {
+		if references(ba.Namespace, ba.Spec.IPAddressPools) {
+			resources = append(resources, ComplianceResource{Kind: "BGPAdvertisement", Namespace: ba.Namespace,
+				Name: ba.Name})
+			for _, community := range ba.Spec.Communities {
+				aliases[community] = true
+			}
+		}
+	}
+	for _, community := range c.CommunityList.Items {
+		if community.Namespace != ap.Namespace {
+			continue
+		}
+		for _, alias := range community.Spec.Communities {
+			if aliases[alias.Name] {
+				resources = append(resources, ComplianceResource{Kind: "Community", Namespace: community.Namespace,
+					Name: community.Name})
+				break
+			}
+		}
+	}
+	return resources
+}
+
+// Print writes the report to w as YAML, or as JSON if toJSON is set.
+func (r *MigrationReport) Print(w io.Writer, toJSON bool) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err == nil && !toJSON {
+		data, err = yaml.JSONToYAML(data)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot encode migration report, err: %w", err)
+	}
+	if toJSON {
+		data = append(data, '\n')
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// WriteFile writes the report to the file path, as JSON if path ends in .json and as YAML otherwise.
+func (r *MigrationReport) WriteFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("cannot create migration report %q, err: %w", path, err)
+	}
+	err = r.Print(f, strings.EqualFold(filepath.Ext(path), ".json"))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,177 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/yaml"
+)
+
+// reportGenerated returns the object kind/name of report and the kinds and names of the objects generated from it,
+// or false if the report has no such object.
+func reportGenerated(report *MigrationReport, kind, name string) (ObjectMigration, []string, bool) {
+	for _, object := range report.Objects {
+		if object.Kind != kind || object.Name != name {
+			continue
+		}
+		var generated []string
+		for _, resource := range object.Generated {
+			generated = append(generated, resource.Kind+"/"+resource.Name)
+		}
+		return object, generated, true
+	}
+	return ObjectMigration{}, nil, false
+}
+
+func TestOfflineMigrationReport(t *testing.T) {
+	var scheme = runtime.NewScheme()
+	if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestOfflineMigrationReport: error adding to scheme, err: %q", err)
+	}
+	inDir := t.TempDir()
+	if err := os.WriteFile(path.Join(inDir, "config.yaml"), []byte(legacyBGPResources), 0644); err != nil {
+		t.Fatal(err)
+	}
+	SetQuiet(true)
+	defer SetQuiet(false)
+	stdout = bytes.NewBuffer([]byte{})
+	report := &MigrationReport{}
+	err := OfflineMigration(context.TODO(), nil, scheme, inDir, "", OutputFormatYAML,
+		OfflineMigrationOptions{Report: report})
+	if err != nil {
+		t.Fatalf("TestOfflineMigrationReport: unexpected error, err: %q", err)
+	}
+	expectedSummary := MigrationSummary{LegacyObjects: 3, Converted: 3, GeneratedObjects: 5}
+	if report.Mode != MigrationModeOffline || report.Summary != expectedSummary || len(report.Errors) > 0 {
+		t.Fatalf("TestOfflineMigrationReport: expected mode %q and summary %+v but got %q, %+v and errors %v",
+			MigrationModeOffline, expectedSummary, report.Mode, report.Summary, report.Errors)
+	}
+	tcs := map[string]struct {
+		kind              string
+		name              string
+		expectedGenerated []string
+	}{
+		"AddressPool": {
+			kind:              "AddressPool",
+			name:              "bgp",
+			expectedGenerated: []string{"IPAddressPool/bgp", "BGPAdvertisement/bgp-bgp-advertisement-0"},
+		},
+		"BGPPeer with a password Secret": {
+			kind:              "BGPPeer",
+			name:              "peer-a",
+			expectedGenerated: []string{"BGPPeer/peer-a", "Secret/peer-a-bgp-password"},
+		},
+		"BFDProfile": {
+			kind:              "BFDProfile",
+			name:              "fast",
+			expectedGenerated: []string{"BFDProfile/fast"},
+		},
+	}
+	for desc, tc := range tcs {
+		object, generated, ok := reportGenerated(report, tc.kind, tc.name)
+		if !ok {
+			t.Fatalf("TestOfflineMigrationReport(%s): %s/%s is missing in report %+v", desc, tc.kind, tc.name, report)
+		}
+		if object.Status != MigrationConverted || !reflect.DeepEqual(generated, tc.expectedGenerated) {
+			t.Fatalf("TestOfflineMigrationReport(%s): expected status %q and generated objects %v but got %q and %v",
+				desc, MigrationConverted, tc.expectedGenerated, object.Status, generated)
+		}
+	}
+
+	// The report is printed as YAML or JSON.
+	out := &bytes.Buffer{}
+	if err := report.Print(out, false); err != nil {
+		t.Fatalf("TestOfflineMigrationReport: cannot print report, err: %q", err)
+	}
+	printed := &MigrationReport{}
+	if err := yaml.Unmarshal(out.Bytes(), printed); err != nil || printed.Summary != expectedSummary {
+		t.Fatalf("TestOfflineMigrationReport: cannot read back YAML report %s, err: %v", out.String(), err)
+	}
+	reportFile := path.Join(t.TempDir(), "report.json")
+	if err := report.WriteFile(reportFile); err != nil {
+		t.Fatalf("TestOfflineMigrationReport: cannot write report, err: %q", err)
+	}
+	data, err := os.ReadFile(reportFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	printed = &MigrationReport{}
+	if err := json.Unmarshal(data, printed); err != nil || printed.Summary != expectedSummary {
+		t.Fatalf("TestOfflineMigrationReport: cannot read back JSON report %s, err: %v", string(data), err)
+	}
+}
+
+func TestOnlineMigrationReport(t *testing.T) {
+	var scheme = runtime.NewScheme()
+	if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestOnlineMigrationReport: error adding to scheme, err: %q", err)
+	}
+	SetQuiet(true)
+	defer SetQuiet(false)
+	stdout = bytes.NewBuffer([]byte{})
+
+	tcs := map[string]struct {
+		failurePoints    string
+		expectedSummary  MigrationSummary
+		expectedStatuses map[string]string
+	}{
+		"migrated and skipped AddressPools": {
+			expectedSummary: MigrationSummary{LegacyObjects: 3, Migrated: 2, Skipped: 1, GeneratedObjects: 5},
+			expectedStatuses: map[string]string{
+				"ap-bgp": MigrationMigrated, "ap-bgp2": MigrationMigrated, "ap-l2": MigrationSkipped,
+			},
+		},
+		"failed AddressPool": {
+			failurePoints:   FailurePointBeforeCreate,
+			expectedSummary: MigrationSummary{LegacyObjects: 3, Skipped: 2, Failed: 1, GeneratedObjects: 3},
+			expectedStatuses: map[string]string{
+				"ap-bgp": MigrationFailed, "ap-bgp2": MigrationSkipped, "ap-l2": MigrationSkipped,
+			},
+		},
+	}
+	for desc, tc := range tcs {
+		c := fake.NewClientBuilder().WithScheme(scheme).Build()
+		for _, ap := range validAddressPools0 {
+			ap := ap.DeepCopy()
+			if ap.Name == "ap-l2" {
+				ap.Annotations = map[string]string{MigratedAnnotation: "2022-01-01T00:00:00Z"}
+			}
+			if err := c.Create(context.TODO(), ap); err != nil {
+				t.Fatalf("TestOnlineMigrationReport(%s): error building fake client, err: %q", desc, err)
+			}
+		}
+		failurePoints, err := ParseFailurePoints(tc.failurePoints)
+		if err != nil {
+			t.Fatalf("TestOnlineMigrationReport(%s): unexpected error, err: %q", desc, err)
+		}
+		report := &MigrationReport{}
+		err = OnlineMigration(context.TODO(), c, scheme, "", false, OnlineMigrationOptions{
+			Options: Options{FailurePoints: failurePoints},
+			Report:  report,
+		})
+		if tc.failurePoints != "" && !errors.Is(err, ErrInjectedFailure) || tc.failurePoints == "" && err != nil {
+			t.Fatalf("TestOnlineMigrationReport(%s): unexpected error %v", desc, err)
+		}
+		if report.Mode != MigrationModeOnline || report.Summary != tc.expectedSummary ||
+			(err != nil) != (len(report.Errors) == 1) {
+			t.Fatalf("TestOnlineMigrationReport(%s): expected summary %+v but got %+v and errors %v", desc,
+				tc.expectedSummary, report.Summary, report.Errors)
+		}
+		for name, expectedStatus := range tc.expectedStatuses {
+			object, _, ok := reportGenerated(report, "AddressPool", name)
+			if !ok || object.Status != expectedStatus || (expectedStatus == MigrationFailed) != (object.Error != "") {
+				t.Fatalf("TestOnlineMigrationReport(%s): expected status %q for AddressPool %s but got %+v", desc,
+					expectedStatus, name, object)
+			}
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package converter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// RestoreActionCreate recreates an AddressPool of the backup that does not exist.
+	RestoreActionCreate = "create"
+	// RestoreActionSkip leaves an AddressPool of the backup alone that exists with the same spec.
+	RestoreActionSkip = "skip"
+	// RestoreActionConflict leaves an AddressPool of the backup alone that exists with a different spec.
+	RestoreActionConflict = "conflict"
+)
+
+// RestoreAction is what a restore does with one AddressPool of a backup.
+type RestoreAction struct {
+	Action      string
+	AddressPool metallbv1beta1.AddressPool
+	// ConvertedPoolExists is true if the IPAddressPool that the AddressPool was converted to still exists.
+	ConvertedPoolExists bool
+}
+
+func (a RestoreAction) String() string {
+	ap := a.AddressPool
+	var s string
+	switch a.Action {
+	case RestoreActionCreate:
+		s = fmt.Sprintf("create AddressPool %s/%s (protocol %s, %d addresses, %d BGP advertisements)", ap.Namespace,
+			ap.Name, ap.Spec.Protocol, len(ap.Spec.Addresses), len(ap.Spec.BGPAdvertisements))
+	case RestoreActionSkip:
+		s = fmt.Sprintf("skip AddressPool %s/%s, it exists with the same spec", ap.Namespace, ap.Name)
+	default:
+		s = fmt.Sprintf("skip AddressPool %s/%s, it exists with a different spec", ap.Namespace, ap.Name)
+	}
+	if a.ConvertedPoolExists {
+		s += fmt.Sprintf(", its converted IPAddressPool %s/%s still exists", ap.Namespace, convertedPoolName(ap))
+	}
+	return s
+}
+
+// ReadBackup reads the legacy AddressPools of the AddressPool.yaml or AddressPool.json file of the backupDir of an
+// online migration and verifies that they are complete: every AddressPool must have a name, a namespace, a valid
+// protocol and valid addresses. The AddressPools are decoded and converted according to opts.
+func ReadBackup(scheme *runtime.Scheme, backupDir string, opts Options) (*LegacyObjects, error) {
+	var legacyObjects *LegacyObjects
+	for _, name := range []string{"AddressPool.yaml", "AddressPool.json"} {
+		content, err := os.ReadFile(filepath.Join(backupDir, name))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot read backup, err: %w", err)
+		}
+		if legacyObjects != nil {
+			return nil, fmt.Errorf("backup directory %s holds both AddressPool.yaml and AddressPool.json", backupDir)
+		}
+		legacyObjects, err = ReadLegacyObjects(scheme, content, name, opts)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read backup, err: %w", err)
+		}
+	}
+	if legacyObjects == nil {
+		return nil, fmt.Errorf("backup directory %s holds neither AddressPool.yaml nor AddressPool.json", backupDir)
+	}
+	for _, ap := range legacyObjects.AddressPoolList.Items {
+		if ap.Name == "" || ap.Namespace == "" {
+			return nil, fmt.Errorf("backup holds an AddressPool without name or namespace: %+v", ap.ObjectMeta)
+		}
+	}
+	// Converting the AddressPools verifies their protocols and addresses.
+	if _, err := legacyObjects.Convert(opts); err != nil {
+		return nil, fmt.Errorf("backup holds invalid AddressPools, err: %w", err)
+	}
+	return legacyObjects, nil
+}
+
+// PlanRestore returns what a restore of the AddressPools of backupDir would do against the API. The backup is read
+// according to opts. The API requests are bound to ctx.
+func PlanRestore(ctx context.Context, c client.Client, scheme *runtime.Scheme, backupDir string,
+	opts Options) ([]RestoreAction, error) {
+	legacyObjects, err := ReadBackup(scheme, backupDir, opts)
+	if err != nil {
+		return nil, err
+	}
+	var actions []RestoreAction
+	for _, ap := range legacyObjects.AddressPoolList.Items {
+		action := RestoreAction{Action: RestoreActionCreate, AddressPool: ap}
+		existing := &metallbv1beta1.AddressPool{}
+		err := c.Get(ctx, types.NamespacedName{Namespace: ap.Namespace, Name: ap.Name}, existing)
+		if err == nil {
+			action.Action = RestoreActionConflict
+			if equality.Semantic.DeepEqual(existing.Spec, ap.Spec) {
+				action.Action = RestoreActionSkip
+			}
+		} else if !apierrors.IsNotFound(err) {
+			return nil, fmt.Errorf("cannot get AddressPool %s/%s, err: %w", ap.Namespace, ap.Name, err)
+		}
+		iap := &metallbv1beta1.IPAddressPool{}
+		err = c.Get(ctx, types.NamespacedName{Namespace: ap.Namespace, Name: convertedPoolName(ap)}, iap)
+		if err == nil {
+			action.ConvertedPoolExists = true
+		} else if !apierrors.IsNotFound(err) {
+			return nil, fmt.Errorf("cannot get IPAddressPool %s/%s, err: %w", ap.Namespace, convertedPoolName(ap),
+				err)
+		}
+		actions = append(actions, action)
+	}
+	return actions, nil
+}
+
+// Restore recreates the AddressPools of the backupDir of an online migration that do not exist, and prints what it
+// does to stdout. AddressPools that exist are left alone. With dryRun, the backup is only verified and the actions
+// are printed, but the API is not modified. The backup is read according to opts. The API requests are bound to ctx.
+func Restore(ctx context.Context, c client.Client, scheme *runtime.Scheme, backupDir string, dryRun bool,
+	opts Options) error {
+	actions, err := PlanRestore(ctx, c, scheme, backupDir, opts)
+	if err != nil {
+		return err
+	}
+	if dryRun {
+		fmt.Fprintf(stdout, "The backup in %s holds %d valid AddressPools, a restore would:\n", backupDir,
+			len(actions))
+	}
+	for _, action := range actions {
+		if dryRun {
+			fmt.Fprintf(stdout, "  - %s\n", action)
+			continue
+		}
+		if action.Action == RestoreActionCreate {
+			ap := action.AddressPool
+			// Server-populated metadata of the backup must not be sent back to the API.
+			ap.ObjectMeta = metav1.ObjectMeta{
+				Name:            ap.Name,
+				Namespace:       ap.Namespace,
+				Labels:          ap.Labels,
+				Annotations:     ap.Annotations,
+				OwnerReferences: ap.OwnerReferences,
+			}
+			if err := c.Create(ctx, &ap); err != nil {
+				return fmt.Errorf("cannot restore AddressPool %s/%s, err: %w", ap.Namespace, ap.Name,
+					classifyAPIError(err))
+			}
+		}
+		if !quiet {
+			log.Print(action)
+		}
+	}
+	return nil
+}
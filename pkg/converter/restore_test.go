@@ -0,0 +1,121 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func restoreTestPool(name, protocol string, addresses ...string) metallbv1beta1.AddressPool {
+	return metallbv1beta1.AddressPool{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "metallb-system", ResourceVersion: "42"},
+		Spec:       metallbv1beta1.AddressPoolSpec{Protocol: protocol, Addresses: addresses},
+	}
+}
+
+func TestRestore(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := AddToScheme(scheme, Options{}); err != nil {
+		t.Fatalf("TestRestore: error adding to scheme, err: %q", err)
+	}
+	backup := []metallbv1beta1.AddressPool{
+		restoreTestPool("missing", ProtocolLayer2, "10.0.0.0/24"),
+		restoreTestPool("same", ProtocolBGP, "10.0.1.0/24"),
+		restoreTestPool("changed", ProtocolLayer2, "10.0.2.0/24"),
+	}
+	existing := []metallbv1beta1.AddressPool{
+		restoreTestPool("same", ProtocolBGP, "10.0.1.0/24"),
+		restoreTestPool("changed", ProtocolLayer2, "10.0.3.0/24"),
+	}
+	tcs := map[string]struct {
+		backup              []metallbv1beta1.AddressPool
+		dryRun              bool
+		expectedOutput      []string
+		expectedRestored    bool
+		expectedErrorString string
+	}{
+		"dry run": {
+			backup: backup,
+			dryRun: true,
+			expectedOutput: []string{
+				"The backup in ",
+				" holds 3 valid AddressPools, a restore would:\n",
+				"  - create AddressPool metallb-system/missing (protocol layer2, 1 addresses, 0 BGP advertisements)\n",
+				"  - skip AddressPool metallb-system/same, it exists with the same spec\n",
+				"  - skip AddressPool metallb-system/changed, it exists with a different spec\n",
+			},
+		},
+		"restore": {
+			backup:           backup,
+			expectedRestored: true,
+		},
+		"missing backup": {
+			dryRun:              true,
+			expectedErrorString: "holds neither AddressPool.yaml nor AddressPool.json",
+		},
+		"invalid protocol": {
+			backup:              []metallbv1beta1.AddressPool{restoreTestPool("missing", "ospf", "10.0.0.0/24")},
+			dryRun:              true,
+			expectedErrorString: "backup holds invalid AddressPools, err: invalid protocol \"ospf\"",
+		},
+		"invalid addresses": {
+			backup:              []metallbv1beta1.AddressPool{restoreTestPool("missing", ProtocolLayer2, "10.0.0")},
+			dryRun:              true,
+			expectedErrorString: "backup holds invalid AddressPools, err: invalid addresses",
+		},
+	}
+	for desc, tc := range tcs {
+		dir := t.TempDir()
+		if tc.backup != nil {
+			stdout = bytes.NewBuffer([]byte{})
+			l := LegacyObjects{AddressPoolList: &metallbv1beta1.AddressPoolList{
+				Items: append([]metallbv1beta1.AddressPool{}, tc.backup...),
+			}}
+			if err := l.Print(dir, false, Options{}); err != nil {
+				t.Fatalf("TestRestore(%s): cannot write backup, err: %q", desc, err)
+			}
+		}
+		c := fake.NewClientBuilder().WithScheme(scheme).Build()
+		for _, ap := range existing {
+			ap.ResourceVersion = ""
+			if err := c.Create(context.TODO(), &ap); err != nil {
+				t.Fatalf("TestRestore(%s): cannot create AddressPool, err: %q", desc, err)
+			}
+		}
+		stdout = bytes.NewBuffer([]byte{})
+		err := Restore(context.TODO(), c, scheme, dir, tc.dryRun, Options{})
+		if tc.expectedErrorString != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.expectedErrorString) {
+				t.Fatalf("TestRestore(%s): expected error %q but got %v", desc, tc.expectedErrorString, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("TestRestore(%s): unexpected error, err: %q", desc, err)
+		}
+		for _, expected := range tc.expectedOutput {
+			if !strings.Contains(stdout.(*bytes.Buffer).String(), expected) {
+				t.Fatalf("TestRestore(%s): expected %q in output but got:\n%s", desc, expected, stdout)
+			}
+		}
+		restored := &metallbv1beta1.AddressPool{}
+		err = c.Get(context.TODO(), types.NamespacedName{Namespace: "metallb-system", Name: "missing"}, restored)
+		if (err == nil) != tc.expectedRestored {
+			t.Fatalf("TestRestore(%s): expected AddressPool missing to be restored=%t, err: %v", desc,
+				tc.expectedRestored, err)
+		}
+		changed := &metallbv1beta1.AddressPool{}
+		err = c.Get(context.TODO(), types.NamespacedName{Namespace: "metallb-system", Name: "changed"}, changed)
+		if err != nil || changed.Spec.Addresses[0] != "10.0.3.0/24" {
+			t.Fatalf("TestRestore(%s): expected AddressPool changed to be left alone, got %v, err: %v", desc,
+				changed.Spec.Addresses, err)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package converter
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// DefaultMaxRetries is the number of times that a failed API request is retried if no RetryPolicy is given.
+	DefaultMaxRetries = 3
+	// DefaultRetryInterval is the wait before the first retry of a failed API request if no RetryPolicy is given.
+	DefaultRetryInterval = time.Second
+
+	// conflictAttempts and conflictInterval are the number of attempts of retryOnConflict and the wait between them,
+	// the same as retry.DefaultRetry of client-go.
+	conflictAttempts = 5
+	conflictInterval = 10 * time.Millisecond
+)
+
+// retryOnConflict runs fn until it does not fail with a conflict, at most conflictAttempts times, like
+// retry.RetryOnConflict of client-go. fn must read the object that it updates again in every attempt. It returns the
+// error of the last attempt.
+func retryOnConflict(ctx context.Context, fn func() error) error {
+	var err error
+	for attempt := 0; attempt < conflictAttempts; attempt++ {
+		if err = fn(); !apierrors.IsConflict(err) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(conflictInterval):
+		}
+	}
+	return err
+}
+
+// RetryPolicy configures how the API requests of a migration are retried when the API server fails transiently,
+// e.g. with a timeout, with too many requests or while it is unavailable. A request is attempted 1+MaxRetries times.
+// The wait before the n-th retry is Interval*2^(n-1), or the delay that the API server asks for if it is longer.
+type RetryPolicy struct {
+	MaxRetries int
+	Interval   time.Duration
+}
+
+// WrapClient returns a client that retries the requests of c according to the policy. Conflicts are retried for Create
+// and Delete only, a conflict on Update or Patch means that the object changed and must be read again by the caller.
+// A retried Create that finds its object, or a retried Delete that misses it, succeeds, since the previous attempt
+// may have succeeded before its response was lost. Requests of operations that are retried by their OperationPolicy
+// are attempted once.
+func (p RetryPolicy) WrapClient(c client.Client) client.Client {
+	if p.MaxRetries <= 0 {
+		return c
+	}
+	return &retryingClient{Client: c, policy: p}
+}
+
+// isTransientAPIError returns true if err is a failure of the API server or of the connection to it that may succeed
+// when it is retried, or a conflict if conflicts is set.
+func isTransientAPIError(err error, conflicts bool) bool {
+	return apierrors.IsServerTimeout(err) || apierrors.IsTimeout(err) || apierrors.IsTooManyRequests(err) ||
+		apierrors.IsServiceUnavailable(err) || apierrors.IsInternalError(err) || apierrors.IsUnexpectedServerError(err) ||
+		utilnet.IsConnectionReset(err) || utilnet.IsConnectionRefused(err) || utilnet.IsProbableEOF(err) ||
+		conflicts && apierrors.IsConflict(err)
+}
+
+// retryingClient retries the requests of a client according to a RetryPolicy.
+type retryingClient struct {
+	client.Client
+	policy RetryPolicy
+}
+
+// retry runs fn until it succeeds, fails with an error that is not transient, or the retries of the policy are used
+// up. It returns the error of the last attempt. request describes fn in log messages. Within an operation that its
+// OperationPolicy retries, fn is attempted once.
+func (rc *retryingClient) retry(ctx context.Context, request string, conflicts bool, fn func(retry bool) error) error {
+	err := fn(false)
+	if ctx.Value(operationRetriesKey{}) != nil {
+		return err
+	}
+	for attempt := 1; attempt <= rc.policy.MaxRetries && isTransientAPIError(err, conflicts); attempt++ {
+		backoff := rc.policy.Interval << (attempt - 1)
+		if seconds, ok := apierrors.SuggestsClientDelay(err); ok && time.Duration(seconds)*time.Second > backoff {
+			backoff = time.Duration(seconds) * time.Second
+		}
+		if !quiet {
+			log.Printf("warning: API request %s failed transiently, retrying it in %s (%d/%d), err: %v", request,
+				backoff, attempt, rc.policy.MaxRetries, err)
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		err = fn(true)
+	}
+	return err
+}
+
+func (rc *retryingClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object,
+	opts ...client.GetOption) error {
+	return rc.retry(ctx, fmt.Sprintf("get of %s", key), false, func(bool) error {
+		return rc.Client.Get(ctx, key, obj, opts...)
+	})
+}
+
+func (rc *retryingClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	return rc.retry(ctx, fmt.Sprintf("list of %T", list), false, func(bool) error {
+		return rc.Client.List(ctx, list, opts...)
+	})
+}
+
+func (rc *retryingClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	return rc.retry(ctx, fmt.Sprintf("create of %s/%s", obj.GetNamespace(), obj.GetName()), true,
+		func(retry bool) error {
+			err := rc.Client.Create(ctx, obj, opts...)
+			if retry && apierrors.IsAlreadyExists(err) {
+				return nil
+			}
+			return err
+		})
+}
+
+func (rc *retryingClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	return rc.retry(ctx, fmt.Sprintf("delete of %s/%s", obj.GetNamespace(), obj.GetName()), true,
+		func(retry bool) error {
+			err := rc.Client.Delete(ctx, obj, opts...)
+			if retry && apierrors.IsNotFound(err) {
+				return nil
+			}
+			return err
+		})
+}
+
+func (rc *retryingClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	return rc.retry(ctx, fmt.Sprintf("update of %s/%s", obj.GetNamespace(), obj.GetName()), false,
+		func(bool) error {
+			return rc.Client.Update(ctx, obj, opts...)
+		})
+}
+
+func (rc *retryingClient) Patch(ctx context.Context, obj client.Object, patch client.Patch,
+	opts ...client.PatchOption) error {
+	return rc.retry(ctx, fmt.Sprintf("patch of %s/%s", obj.GetNamespace(), obj.GetName()), false,
+		func(bool) error {
+			return rc.Client.Patch(ctx, obj, patch, opts...)
+		})
+}
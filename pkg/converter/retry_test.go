@@ -0,0 +1,246 @@
+package converter
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// flakyClient fails the first failures requests with err. If succeed is set, the failed requests are passed on to
+// the wrapped client anyway, like requests whose response was lost.
+type flakyClient struct {
+	client.Client
+	err      error
+	failures int
+	succeed  bool
+	requests int
+}
+
+func (c *flakyClient) fail(fn func(lost bool) error) error {
+	c.requests++
+	if c.requests > c.failures {
+		return fn(false)
+	}
+	if c.succeed {
+		if err := fn(true); err != nil {
+			return err
+		}
+	}
+	return c.err
+}
+
+func (c *flakyClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	return c.fail(func(bool) error { return c.Client.List(ctx, list, opts...) })
+}
+
+func (c *flakyClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	return c.fail(func(lost bool) error {
+		if lost {
+			// The response is lost, so obj is not updated.
+			obj = obj.DeepCopyObject().(client.Object)
+		}
+		return c.Client.Create(ctx, obj, opts...)
+	})
+}
+
+func (c *flakyClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	return c.fail(func(bool) error { return c.Client.Delete(ctx, obj, opts...) })
+}
+
+func (c *flakyClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	return c.fail(func(bool) error { return c.Client.Update(ctx, obj, opts...) })
+}
+
+func TestRetryingClient(t *testing.T) {
+	resource := schema.GroupResource{Group: metallbAPIGroup, Resource: "addresspools"}
+	serverTimeout := apierrors.NewServerTimeout(resource, "create", 0)
+	conflict := apierrors.NewConflict(resource, "pool0", fmt.Errorf("the object has been modified"))
+	tcs := map[string]struct {
+		request          string
+		err              error
+		failures         int
+		succeed          bool
+		expectedRequests int
+		expectedError    func(error) bool
+	}{
+		"list succeeds on retry": {
+			request:          "list",
+			err:              serverTimeout,
+			failures:         2,
+			expectedRequests: 3,
+		},
+		"create retries exhausted": {
+			request:          "create",
+			err:              serverTimeout,
+			failures:         5,
+			expectedRequests: 4,
+			expectedError:    apierrors.IsServerTimeout,
+		},
+		"create lost response": {
+			request:          "create",
+			err:              apierrors.NewTooManyRequests("slow down", 0),
+			failures:         1,
+			succeed:          true,
+			expectedRequests: 2,
+		},
+		"delete conflict": {
+			request:          "delete",
+			err:              conflict,
+			failures:         1,
+			expectedRequests: 2,
+		},
+		"delete lost response": {
+			request:          "delete",
+			err:              apierrors.NewServiceUnavailable("unavailable"),
+			failures:         1,
+			succeed:          true,
+			expectedRequests: 2,
+		},
+		"update conflict is not retried": {
+			request:          "update",
+			err:              conflict,
+			failures:         1,
+			expectedRequests: 1,
+			expectedError:    apierrors.IsConflict,
+		},
+		"not found is not retried": {
+			request:          "create",
+			err:              apierrors.NewNotFound(resource, "pool0"),
+			failures:         1,
+			expectedRequests: 1,
+			expectedError:    apierrors.IsNotFound,
+		},
+	}
+	var scheme = runtime.NewScheme()
+	if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestRetryingClient: error adding to scheme, err: %q", err)
+	}
+	SetQuiet(true)
+	defer SetQuiet(false)
+	for desc, tc := range tcs {
+		fc := &flakyClient{Client: fake.NewClientBuilder().WithScheme(scheme).Build(), err: tc.err,
+			failures: tc.failures, succeed: tc.succeed}
+		c := RetryPolicy{MaxRetries: 3, Interval: time.Millisecond}.WrapClient(fc)
+		ap := &metallbv1beta1.AddressPool{
+			ObjectMeta: metav1.ObjectMeta{Name: "pool0", Namespace: "metallb-system"},
+			Spec:       metallbv1beta1.AddressPoolSpec{Protocol: "layer2", Addresses: []string{"10.0.0.0/24"}},
+		}
+		if tc.request == "delete" || tc.request == "update" {
+			if err := fc.Client.Create(context.TODO(), ap); err != nil {
+				t.Fatalf("TestRetryingClient(%s): error building fake client, err: %q", desc, err)
+			}
+		}
+		var err error
+		switch tc.request {
+		case "list":
+			err = c.List(context.TODO(), &metallbv1beta1.AddressPoolList{})
+		case "create":
+			err = c.Create(context.TODO(), ap)
+		case "delete":
+			err = c.Delete(context.TODO(), ap)
+		case "update":
+			err = c.Update(context.TODO(), ap)
+		}
+		if tc.expectedError == nil && err != nil {
+			t.Fatalf("TestRetryingClient(%s): unexpected error, err: %q", desc, err)
+		}
+		if tc.expectedError != nil && !tc.expectedError(err) {
+			t.Fatalf("TestRetryingClient(%s): unexpected error, err: %v", desc, err)
+		}
+		if fc.requests != tc.expectedRequests {
+			t.Fatalf("TestRetryingClient(%s): expected %d requests but got %d", desc, tc.expectedRequests,
+				fc.requests)
+		}
+	}
+}
+
+func TestRetryingClientCanceled(t *testing.T) {
+	var scheme = runtime.NewScheme()
+	if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestRetryingClientCanceled: error adding to scheme, err: %q", err)
+	}
+	SetQuiet(true)
+	defer SetQuiet(false)
+	fc := &flakyClient{Client: fake.NewClientBuilder().WithScheme(scheme).Build(),
+		err: apierrors.NewServiceUnavailable("unavailable"), failures: 5}
+	c := RetryPolicy{MaxRetries: 3, Interval: time.Hour}.WrapClient(fc)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := c.List(ctx, &metallbv1beta1.AddressPoolList{})
+	if !apierrors.IsServiceUnavailable(err) || fc.requests != 1 {
+		t.Fatalf("TestRetryingClientCanceled: expected the retries to stop after 1 request but got %d, err: %v",
+			fc.requests, err)
+	}
+}
+
+func TestOnlineMigrationRetries(t *testing.T) {
+	var scheme = runtime.NewScheme()
+	if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestOnlineMigrationRetries: error adding to scheme, err: %q", err)
+	}
+	fc := &flakyClient{Client: fake.NewClientBuilder().WithScheme(scheme).Build(),
+		err: apierrors.NewServiceUnavailable("unavailable"), failures: 2}
+	for _, ap := range validAddressPools0 {
+		if err := fc.Client.Create(context.TODO(), ap.DeepCopy()); err != nil {
+			t.Fatalf("TestOnlineMigrationRetries: error building fake client, err: %q", err)
+		}
+	}
+	SetQuiet(true)
+	defer SetQuiet(false)
+	c := RetryPolicy{MaxRetries: DefaultMaxRetries, Interval: time.Millisecond}.WrapClient(fc)
+	if err := OnlineMigration(context.TODO(), c, scheme, t.TempDir(), false, OnlineMigrationOptions{}); err != nil {
+		t.Fatalf("TestOnlineMigrationRetries: unexpected error, err: %q", err)
+	}
+	addressPoolList := &metallbv1beta1.AddressPoolList{}
+	if err := fc.Client.List(context.TODO(), addressPoolList); err != nil || len(addressPoolList.Items) > 0 {
+		t.Fatalf("TestOnlineMigrationRetries: expected all AddressPools to be migrated, got %d, err: %v",
+			len(addressPoolList.Items), err)
+	}
+}
+
+func TestRetriedOperationRequests(t *testing.T) {
+	var scheme = runtime.NewScheme()
+	if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestRetriedOperationRequests: error adding to scheme, err: %q", err)
+	}
+	SetQuiet(true)
+	defer SetQuiet(false)
+	tcs := map[string]struct {
+		policies         OperationPolicies
+		expectedRequests int
+	}{
+		"requests are retried without operation retries": {
+			expectedRequests: 4,
+		},
+		"operation retries replace request retries": {
+			policies:         OperationPolicies{OperationCreate: {Retries: 1}},
+			expectedRequests: 2,
+		},
+	}
+	for desc, tc := range tcs {
+		fc := &flakyClient{Client: fake.NewClientBuilder().WithScheme(scheme).Build(),
+			err: apierrors.NewServiceUnavailable("unavailable"), failures: 10}
+		c := RetryPolicy{MaxRetries: 3, Interval: time.Millisecond}.WrapClient(fc)
+		ap := &metallbv1beta1.AddressPool{ObjectMeta: metav1.ObjectMeta{Name: "pool0", Namespace: "metallb-system"}}
+		err := runOperation(context.TODO(), c, tc.policies, OperationCreate, func(ctx context.Context,
+			cl client.Client) error {
+			return cl.Create(ctx, ap.DeepCopy())
+		})
+		if !apierrors.IsServiceUnavailable(err) {
+			t.Fatalf("TestRetriedOperationRequests(%s): expected the operation to fail but got %v", desc, err)
+		}
+		if fc.requests != tc.expectedRequests {
+			t.Fatalf("TestRetriedOperationRequests(%s): expected %d requests but got %d", desc, tc.expectedRequests,
+				fc.requests)
+		}
+	}
+}
@@ -0,0 +1,292 @@
+package converter
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/netip"
+	"sort"
+	"strings"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ServiceLoadBalancerIPsAnnotation is the annotation that requests specific IPs for a Service, a comma separated list.
+const ServiceLoadBalancerIPsAnnotation = "metallb.universe.tf/loadBalancerIPs"
+
+// simulatedPool holds the properties of a legacy or converted pool that MetalLB's IP assignment depends on.
+type simulatedPool struct {
+	name          string
+	ranges        [][2]netip.Addr
+	autoAssign    bool
+	avoidBuggyIPs bool
+}
+
+// newSimulatedPool returns the simulatedPool of a pool with the given properties. Addresses that cannot be parsed are
+// ignored, they were already rejected by validateAddressPools.
+func newSimulatedPool(name string, addresses []string, autoAssign *bool, avoidBuggyIPs bool) simulatedPool {
+	pool := simulatedPool{name: name, autoAssign: autoAssign == nil || *autoAssign, avoidBuggyIPs: avoidBuggyIPs}
+	for _, address := range addresses {
+		first, last, err := parseAddressRange(address)
+		if err != nil {
+			continue
+		}
+		pool.ranges = append(pool.ranges, [2]netip.Addr{first, last})
+	}
+	return pool
+}
+
+// contains returns true if MetalLB may assign ip from the pool.
+func (p simulatedPool) contains(ip netip.Addr) bool {
+	if p.avoidBuggyIPs && ip.Is4() && (ip.As4()[3] == 0 || ip.As4()[3] == 255) {
+		return false
+	}
+	return p.covers(ip)
+}
+
+// covers returns true if ip is inside one of the address ranges of the pool.
+func (p simulatedPool) covers(ip netip.Addr) bool {
+	for _, r := range p.ranges {
+		if r[0].Compare(ip) <= 0 && ip.Compare(r[1]) <= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// legacySimulatedPools returns the simulatedPools of the legacy AddressPools.
+func legacySimulatedPools(l *LegacyObjects) []simulatedPool {
+	var pools []simulatedPool
+	for _, ap := range l.AddressPoolList.Items {
+		pools = append(pools, newSimulatedPool(ap.Name, ap.Spec.Addresses, ap.Spec.AutoAssign, false))
+	}
+	return pools
+}
+
+// poolsAfterMigration returns the simulatedPools of the state after the migration: the converted IPAddressPools that
+// were added to cc and all IPAddressPools that already exist in the API and are not replaced by a converted one.
+func (cc *clusterChecks) poolsAfterMigration(ctx context.Context, cl client.Client) ([]simulatedPool, error) {
+	pools := append([]simulatedPool{}, cc.convertedPools...)
+	existing := &metallbv1beta1.IPAddressPoolList{}
+	if err := cl.List(ctx, existing); err != nil && !meta.IsNoMatchError(err) {
+		return nil, fmt.Errorf("failed to list IPAddressPools in cluster: %w", err)
+	}
+	for _, iap := range existing.Items {
+		if cc.converted[fmt.Sprintf("%s/%s", iap.Namespace, iap.Name)] {
+			continue
+		}
+		pools = append(pools, newSimulatedPool(iap.Name, iap.Spec.Addresses, iap.Spec.AutoAssign,
+			iap.Spec.AvoidBuggyIPs))
+	}
+	return pools, nil
+}
+
+// loadBalancerServices returns all Services of type LoadBalancer. If the scheme of the client does not know Services,
+// no Services are returned.
+func loadBalancerServices(ctx context.Context, cl client.Client) ([]corev1.Service, error) {
+	serviceList := &corev1.ServiceList{}
+	err := cl.List(ctx, serviceList)
+	if runtime.IsNotRegisteredError(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list Services in cluster: %w", err)
+	}
+	var services []corev1.Service
+	for _, svc := range serviceList.Items {
+		if svc.Spec.Type == corev1.ServiceTypeLoadBalancer {
+			services = append(services, svc)
+		}
+	}
+	return services, nil
+}
+
+// assignedIPs returns the valid IPs of the load balancer status of svc.
+func assignedIPs(svc corev1.Service) []netip.Addr {
+	var ips []netip.Addr
+	for _, ingress := range svc.Status.LoadBalancer.Ingress {
+		if ip, err := netip.ParseAddr(ingress.IP); err == nil {
+			ips = append(ips, ip)
+		}
+	}
+	return ips
+}
+
+// keepingPool returns the name of the pool that MetalLB keeps the assigned ip of svc in, or "" if MetalLB would
+// assign a new IP. An IP is kept if it belongs to a pool and, if svc requests a pool, to that pool.
+func keepingPool(pools []simulatedPool, svc corev1.Service, ip netip.Addr) string {
+	requested := svc.Annotations[ServiceAddressPoolAnnotation]
+	for _, pool := range pools {
+		if (requested == "" || requested == pool.name) && pool.contains(ip) {
+			return pool.name
+		}
+	}
+	return ""
+}
+
+// eligiblePools returns the sorted names of the pools that MetalLB may assign a new IP to svc from: the requested
+// pool, or all pools with autoAssign enabled.
+func eligiblePools(pools []simulatedPool, svc corev1.Service) []string {
+	requested := svc.Annotations[ServiceAddressPoolAnnotation]
+	var names []string
+	for _, pool := range pools {
+		if (requested != "" && requested == pool.name) || (requested == "" && pool.autoAssign) {
+			names = append(names, pool.name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// assignmentChanges simulates MetalLB's IP assignment for services over the legacy pools and over the pools after the
+// migration, and returns a description of every Service whose IP would change: assigned IPs that would not be kept
+// and Services without an IP whose eligible pools change.
+func assignmentChanges(services []corev1.Service, legacy, converted []simulatedPool) []string {
+	var changes []string
+	for _, svc := range services {
+		ips := assignedIPs(svc)
+		if len(ips) == 0 {
+			before, after := eligiblePools(legacy, svc), eligiblePools(converted, svc)
+			if strings.Join(before, ",") != strings.Join(after, ",") {
+				changes = append(changes, fmt.Sprintf("Service %s/%s has no IP yet and would be assigned one from "+
+					"pools [%s] instead of [%s]", svc.Namespace, svc.Name, strings.Join(after, ", "),
+					strings.Join(before, ", ")))
+			}
+			continue
+		}
+		for _, ip := range ips {
+			if keepingPool(converted, svc, ip) != "" {
+				continue
+			}
+			reason := "no converted pool contains it"
+			if requested := svc.Annotations[ServiceAddressPoolAnnotation]; requested != "" {
+				reason = fmt.Sprintf("the requested pool %s does not contain it", requested)
+			}
+			changes = append(changes, fmt.Sprintf("Service %s/%s would lose its IP %s, %s", svc.Namespace, svc.Name,
+				ip, reason))
+		}
+	}
+	return changes
+}
+
+// uncoveredIPs returns a description of every assigned IP of services that is inside the address ranges of a legacy
+// pool, but not of any converted pool. IPs outside of all legacy pools were not assigned by MetalLB and are ignored.
+func uncoveredIPs(services []corev1.Service, legacy, converted []simulatedPool) []string {
+	var uncovered []string
+	for _, svc := range services {
+		for _, ip := range assignedIPs(svc) {
+			if !anyCovers(legacy, ip) || anyCovers(converted, ip) {
+				continue
+			}
+			uncovered = append(uncovered, fmt.Sprintf("Service %s/%s: %s", svc.Namespace, svc.Name, ip))
+		}
+	}
+	return uncovered
+}
+
+// requestedIPs returns the IPs that svc requests with spec.loadBalancerIP or the ServiceLoadBalancerIPsAnnotation.
+// Values that are not IPs are returned as invalid.
+func requestedIPs(svc corev1.Service) ([]netip.Addr, []string) {
+	values := []string{svc.Spec.LoadBalancerIP}
+	values = append(values, strings.Split(svc.Annotations[ServiceLoadBalancerIPsAnnotation], ",")...)
+	var ips []netip.Addr
+	var invalid []string
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		ip, err := netip.ParseAddr(value)
+		if err != nil {
+			invalid = append(invalid, value)
+			continue
+		}
+		ips = append(ips, ip)
+	}
+	return ips, invalid
+}
+
+// unsatisfiableRequests returns a description of every IP that services request explicitly, but that no converted
+// pool can assign to them. Requests that the legacy pools can satisfy are reported as becoming unsatisfiable.
+func unsatisfiableRequests(services []corev1.Service, legacy, converted []simulatedPool) []string {
+	var requests []string
+	for _, svc := range services {
+		ips, invalid := requestedIPs(svc)
+		for _, value := range invalid {
+			requests = append(requests, fmt.Sprintf("Service %s/%s requests the invalid IP %q", svc.Namespace,
+				svc.Name, value))
+		}
+		for _, ip := range ips {
+			if keepingPool(converted, svc, ip) != "" {
+				continue
+			}
+			if keepingPool(legacy, svc, ip) != "" {
+				requests = append(requests, fmt.Sprintf("Service %s/%s requests IP %s, which no converted pool "+
+					"can assign after the migration", svc.Namespace, svc.Name, ip))
+				continue
+			}
+			requests = append(requests, fmt.Sprintf("Service %s/%s requests IP %s, which no pool can assign, "+
+				"neither before nor after the migration", svc.Namespace, svc.Name, ip))
+		}
+	}
+	return requests
+}
+
+// anyCovers returns true if any of the pools covers ip.
+func anyCovers(pools []simulatedPool, ip netip.Addr) bool {
+	for _, pool := range pools {
+		if pool.covers(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkServices returns an error that lists every LoadBalancer Service whose assigned IP would no longer be covered
+// by any IPAddressPool after the migration of legacy to current, because this guarantees an outage. It logs a warning
+// for every explicitly requested IP that cannot be assigned after the migration and, if simulate is true, for every
+// Service whose IP would change.
+func checkServices(ctx context.Context, cl client.Client, legacy *LegacyObjects, current *CurrentObjects,
+	simulate bool) error {
+	var checks clusterChecks
+	checks.add(legacy, current)
+	return checks.checkServices(ctx, cl, simulate)
+}
+
+// checkServices runs checkServices for the legacy and converted pools that were added to cc.
+func (cc *clusterChecks) checkServices(ctx context.Context, cl client.Client, simulate bool) error {
+	services, err := loadBalancerServices(ctx, cl)
+	if err != nil || len(services) == 0 {
+		return err
+	}
+	converted, err := cc.poolsAfterMigration(ctx, cl)
+	if err != nil {
+		return err
+	}
+	legacyPools := cc.legacyPools
+	if uncovered := uncoveredIPs(services, legacyPools, converted); len(uncovered) > 0 {
+		return fmt.Errorf("%w:\n%s", ErrServiceIPsUncovered, strings.Join(uncovered, "\n"))
+	}
+	if !quiet {
+		for _, request := range unsatisfiableRequests(services, legacyPools, converted) {
+			log.Printf("warning: %s", request)
+		}
+	}
+	if !simulate {
+		return nil
+	}
+	changes := assignmentChanges(services, legacyPools, converted)
+	if !quiet {
+		for _, change := range changes {
+			log.Printf("warning: %s", change)
+		}
+		if len(changes) == 0 {
+			log.Printf("the IPs of all %d LoadBalancer Services would be kept", len(services))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,251 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"strings"
+	"testing"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// loadBalancerService returns a LoadBalancer Service with the given pool annotation and assigned IPs.
+func loadBalancerService(name, pool string, ips ...string) corev1.Service {
+	svc := corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec:       corev1.ServiceSpec{Type: corev1.ServiceTypeLoadBalancer},
+	}
+	if pool != "" {
+		svc.Annotations = map[string]string{ServiceAddressPoolAnnotation: pool}
+	}
+	for _, ip := range ips {
+		svc.Status.LoadBalancer.Ingress = append(svc.Status.LoadBalancer.Ingress, corev1.LoadBalancerIngress{IP: ip})
+	}
+	return svc
+}
+
+func TestAssignmentChanges(t *testing.T) {
+	legacy := []simulatedPool{
+		newSimulatedPool("a", []string{"10.0.0.0/24"}, nil, false),
+		newSimulatedPool("b", []string{"10.0.1.0/24"}, pointer.Bool(false), false),
+	}
+	tcs := map[string]struct {
+		service         corev1.Service
+		converted       []simulatedPool
+		expectedChanges []string
+	}{
+		"kept": {
+			service:   loadBalancerService("svc", "", "10.0.0.5"),
+			converted: legacy,
+		},
+		"kept in another pool": {
+			service: loadBalancerService("svc", "", "10.0.0.5"),
+			converted: []simulatedPool{
+				newSimulatedPool("c", []string{"10.0.0.0/16"}, nil, false),
+			},
+		},
+		"not covered": {
+			service: loadBalancerService("svc", "", "10.0.0.5"),
+			converted: []simulatedPool{
+				newSimulatedPool("a", []string{"10.0.0.128/25"}, nil, false),
+			},
+			expectedChanges: []string{"Service default/svc would lose its IP 10.0.0.5, no converted pool contains it"},
+		},
+		"requested pool was renamed": {
+			service: loadBalancerService("svc", "a", "10.0.0.5"),
+			converted: []simulatedPool{
+				newSimulatedPool("a-ipv4", []string{"10.0.0.0/24"}, nil, false),
+			},
+			expectedChanges: []string{
+				"Service default/svc would lose its IP 10.0.0.5, the requested pool a does not contain it",
+			},
+		},
+		"buggy IP is avoided": {
+			service: loadBalancerService("svc", "", "10.0.0.255"),
+			converted: []simulatedPool{
+				newSimulatedPool("a", []string{"10.0.0.0/24"}, nil, true),
+			},
+			expectedChanges: []string{"Service default/svc would lose its IP 10.0.0.255, no converted pool contains it"},
+		},
+		"pending with unchanged pools": {
+			service:   loadBalancerService("svc", ""),
+			converted: legacy,
+		},
+		"pending with changed pools": {
+			service: loadBalancerService("svc", ""),
+			converted: []simulatedPool{
+				newSimulatedPool("a", []string{"10.0.0.0/24"}, nil, false),
+				newSimulatedPool("b", []string{"10.0.1.0/24"}, nil, false),
+			},
+			expectedChanges: []string{
+				"Service default/svc has no IP yet and would be assigned one from pools [a, b] instead of [a]",
+			},
+		},
+	}
+	for desc, tc := range tcs {
+		changes := assignmentChanges([]corev1.Service{tc.service}, legacy, tc.converted)
+		if strings.Join(changes, "\n") != strings.Join(tc.expectedChanges, "\n") {
+			t.Fatalf("TestAssignmentChanges(%s): expected changes %v but got %v", desc, tc.expectedChanges, changes)
+		}
+	}
+}
+
+func TestCheckServices(t *testing.T) {
+	var scheme = runtime.NewScheme()
+	if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestCheckServices: error adding to scheme, err: %q", err)
+	}
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestCheckServices: error adding to scheme, err: %q", err)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+	ap := &metallbv1beta1.AddressPool{
+		ObjectMeta: metav1.ObjectMeta{Name: "ap-ds", Namespace: "metallb-system"},
+		Spec: metallbv1beta1.AddressPoolSpec{
+			Protocol:  ProtocolLayer2,
+			Addresses: []string{"10.0.0.0/24", "fd00::/120"},
+		},
+	}
+	if err := c.Create(context.TODO(), ap); err != nil {
+		t.Fatalf("TestCheckServices: cannot create AddressPool, err: %q", err)
+	}
+	for _, svc := range []corev1.Service{
+		loadBalancerService("pinned", "ap-ds", "10.0.0.5"),
+		loadBalancerService("unpinned", "", "10.0.0.6"),
+	} {
+		if err := c.Create(context.TODO(), svc.DeepCopy()); err != nil {
+			t.Fatalf("TestCheckServices: cannot create Service, err: %q", err)
+		}
+	}
+
+	stdout = bytes.NewBuffer([]byte{})
+	logs := bytes.NewBuffer([]byte{})
+	log.SetOutput(logs)
+	err := OfflineMigration(context.TODO(), c, scheme, "", "", OutputFormatYAML, OfflineMigrationOptions{
+		Options: Options{SimulateAssignments: true, SplitL2AddressFamilies: true},
+	})
+	log.SetOutput(os.Stderr)
+	if err != nil {
+		t.Fatalf("TestCheckServices: unexpected error, err: %q", err)
+	}
+	expected := "Service default/pinned would lose its IP 10.0.0.5, the requested pool ap-ds does not contain it"
+	if !strings.Contains(logs.String(), expected) {
+		t.Fatalf("TestCheckServices: expected warning %q, got logs %q", expected, logs)
+	}
+	if strings.Contains(logs.String(), "default/unpinned") {
+		t.Fatalf("TestCheckServices: unexpected warning for Service default/unpinned, got logs %q", logs)
+	}
+}
+
+func TestUncoveredIPs(t *testing.T) {
+	legacy := []simulatedPool{newSimulatedPool("a", []string{"10.0.0.0/24", "fd00::/120"}, nil, false)}
+	tcs := map[string]struct {
+		service           corev1.Service
+		converted         []simulatedPool
+		expectedUncovered []string
+	}{
+		"covered": {
+			service:   loadBalancerService("svc", "", "10.0.0.5", "fd00::5"),
+			converted: []simulatedPool{newSimulatedPool("b", []string{"10.0.0.0/16", "fd00::/64"}, nil, true)},
+		},
+		"not assigned by MetalLB": {
+			service:   loadBalancerService("svc", "", "172.16.0.1"),
+			converted: []simulatedPool{newSimulatedPool("a", []string{"10.0.0.0/24"}, nil, false)},
+		},
+		"one family uncovered": {
+			service:           loadBalancerService("svc", "", "10.0.0.5", "fd00::5"),
+			converted:         []simulatedPool{newSimulatedPool("a", []string{"10.0.0.0/24"}, nil, false)},
+			expectedUncovered: []string{"Service default/svc: fd00::5"},
+		},
+		"no converted pools": {
+			service:           loadBalancerService("svc", "a", "10.0.0.5"),
+			expectedUncovered: []string{"Service default/svc: 10.0.0.5"},
+		},
+	}
+	for desc, tc := range tcs {
+		uncovered := uncoveredIPs([]corev1.Service{tc.service}, legacy, tc.converted)
+		if strings.Join(uncovered, "\n") != strings.Join(tc.expectedUncovered, "\n") {
+			t.Fatalf("TestUncoveredIPs(%s): expected %v but got %v", desc, tc.expectedUncovered, uncovered)
+		}
+	}
+}
+
+func TestCheckServicesUncovered(t *testing.T) {
+	var scheme = runtime.NewScheme()
+	if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestCheckServicesUncovered: error adding to scheme, err: %q", err)
+	}
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestCheckServicesUncovered: error adding to scheme, err: %q", err)
+	}
+	svc := loadBalancerService("svc", "", "192.168.100.100")
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&svc).Build()
+	legacyObjects := &LegacyObjects{AddressPoolList: &metallbv1beta1.AddressPoolList{Items: validAddressPools0}}
+	currentObjects, err := legacyObjects.Convert(Options{})
+	if err != nil {
+		t.Fatalf("TestCheckServicesUncovered: unexpected error during conversion, err: %q", err)
+	}
+	if err := checkServices(context.TODO(), c, legacyObjects, currentObjects, false); err != nil {
+		t.Fatalf("TestCheckServicesUncovered: unexpected error, err: %q", err)
+	}
+	currentObjects.IPAddressPoolList.Items = nil
+	err = checkServices(context.TODO(), c, legacyObjects, currentObjects, false)
+	if err == nil || !strings.Contains(err.Error(), "Service default/svc: 192.168.100.100") {
+		t.Fatalf("TestCheckServicesUncovered: expected an error for Service default/svc but got %v", err)
+	}
+}
+
+func TestUnsatisfiableRequests(t *testing.T) {
+	legacy := []simulatedPool{newSimulatedPool("a", []string{"10.0.0.0/24"}, nil, false)}
+	converted := []simulatedPool{newSimulatedPool("a-ipv4", []string{"10.0.0.0/25"}, nil, false)}
+	tcs := map[string]struct {
+		loadBalancerIP   string
+		annotations      map[string]string
+		expectedRequests []string
+	}{
+		"no request": {},
+		"satisfiable": {
+			loadBalancerIP: "10.0.0.5",
+		},
+		"becomes unsatisfiable": {
+			annotations: map[string]string{ServiceLoadBalancerIPsAnnotation: "10.0.0.5, 10.0.0.200"},
+			expectedRequests: []string{
+				"Service default/svc requests IP 10.0.0.200, which no converted pool can assign after the migration",
+			},
+		},
+		"requested pool was renamed": {
+			loadBalancerIP: "10.0.0.5",
+			annotations:    map[string]string{ServiceAddressPoolAnnotation: "a"},
+			expectedRequests: []string{
+				"Service default/svc requests IP 10.0.0.5, which no converted pool can assign after the migration",
+			},
+		},
+		"already unsatisfiable": {
+			loadBalancerIP: "172.16.0.1",
+			expectedRequests: []string{
+				"Service default/svc requests IP 172.16.0.1, which no pool can assign, neither before nor after " +
+					"the migration",
+			},
+		},
+		"invalid": {
+			annotations:      map[string]string{ServiceLoadBalancerIPsAnnotation: "10.0.0.300"},
+			expectedRequests: []string{`Service default/svc requests the invalid IP "10.0.0.300"`},
+		},
+	}
+	for desc, tc := range tcs {
+		svc := loadBalancerService("svc", "")
+		svc.Spec.LoadBalancerIP = tc.loadBalancerIP
+		svc.Annotations = tc.annotations
+		requests := unsatisfiableRequests([]corev1.Service{svc}, legacy, converted)
+		if strings.Join(requests, "\n") != strings.Join(tc.expectedRequests, "\n") {
+			t.Fatalf("TestUnsatisfiableRequests(%s): expected %v but got %v", desc, tc.expectedRequests, requests)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package converter
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DefaultServiceTimeout is the time that WaitServices migrations wait for the Services of an AddressPool to get an
+// IP again if OnlineMigrationOptions.ServiceTimeout is not set.
+const DefaultServiceTimeout = 5 * time.Minute
+
+// servicePollInterval is the time between two checks whether the Services of an AddressPool got an IP again.
+var servicePollInterval = time.Second
+
+// poolServices returns the LoadBalancer Services that are assigned an IP of ap.
+func poolServices(ctx context.Context, cl client.Client,
+	ap metallbv1beta1.AddressPool) ([]types.NamespacedName, error) {
+	services, err := loadBalancerServices(ctx, cl)
+	if err != nil {
+		return nil, err
+	}
+	pool := newSimulatedPool(ap.Name, ap.Spec.Addresses, ap.Spec.AutoAssign, false)
+	var assigned []types.NamespacedName
+	for _, svc := range services {
+		for _, ip := range assignedIPs(svc) {
+			if pool.covers(ip) {
+				assigned = append(assigned, types.NamespacedName{Namespace: svc.Namespace, Name: svc.Name})
+				break
+			}
+		}
+	}
+	return assigned, nil
+}
+
+// waitServices waits until every Service of services that still exists as a LoadBalancer Service is assigned an IP,
+// or until timeout. It returns an error that wraps ErrVerificationFailed and lists the Services without an IP.
+func waitServices(ctx context.Context, cl client.Client, services []types.NamespacedName,
+	timeout time.Duration) error {
+	if len(services) == 0 {
+		return nil
+	}
+	if timeout <= 0 {
+		timeout = DefaultServiceTimeout
+	}
+	if !quiet {
+		log.Printf("waiting up to %s for %d Services to get an IP ...", timeout, len(services))
+	}
+	deadline := time.After(timeout)
+	for {
+		var waiting []string
+		for _, key := range services {
+			svc := &corev1.Service{}
+			err := cl.Get(ctx, key, svc)
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("cannot get Service %s, err: %w", key, err)
+			}
+			if svc.Spec.Type == corev1.ServiceTypeLoadBalancer && len(assignedIPs(*svc)) == 0 {
+				waiting = append(waiting, fmt.Sprintf("Service %s", key))
+			}
+		}
+		if len(waiting) == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-deadline:
+			return fmt.Errorf("%w: the Services were not assigned an IP within %s:\n%s", ErrVerificationFailed,
+				timeout, strings.Join(waiting, "\n"))
+		case <-time.After(servicePollInterval):
+		}
+	}
+}
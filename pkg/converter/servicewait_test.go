@@ -0,0 +1,162 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestWaitServices(t *testing.T) {
+	// A Service that was deleted in the meantime is not waited for.
+	tcs := map[string]struct {
+		waitFor             []string
+		assignAfter         time.Duration
+		expectedErrorString string
+	}{
+		"all services have an ip": {
+			waitFor: []string{"a", "deleted"},
+		},
+		"ip is reassigned": {
+			waitFor:     []string{"a", "b", "deleted"},
+			assignAfter: 30 * time.Millisecond,
+		},
+		"ip is not reassigned": {
+			waitFor:             []string{"a", "b", "deleted"},
+			expectedErrorString: "Service default/b",
+		},
+	}
+	var scheme = runtime.NewScheme()
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestWaitServices: error adding to scheme, err: %q", err)
+	}
+	SetQuiet(true)
+	defer SetQuiet(false)
+	defer func(interval time.Duration) { servicePollInterval = interval }(servicePollInterval)
+	servicePollInterval = 5 * time.Millisecond
+	for desc, tc := range tcs {
+		c := fake.NewClientBuilder().WithScheme(scheme).Build()
+		for _, svc := range []corev1.Service{loadBalancerService("a", "", "10.0.0.1"), loadBalancerService("b", "")} {
+			if err := c.Create(context.TODO(), svc.DeepCopy()); err != nil {
+				t.Fatalf("TestWaitServices(%s): cannot create Service, err: %q", desc, err)
+			}
+		}
+		var waitFor []types.NamespacedName
+		for _, name := range tc.waitFor {
+			waitFor = append(waitFor, types.NamespacedName{Namespace: "default", Name: name})
+		}
+		if tc.assignAfter > 0 {
+			go func() {
+				time.Sleep(tc.assignAfter)
+				svc := &corev1.Service{}
+				if err := c.Get(context.TODO(), client.ObjectKey{Namespace: "default", Name: "b"}, svc); err != nil {
+					return
+				}
+				svc.Status.LoadBalancer.Ingress = []corev1.LoadBalancerIngress{{IP: "10.0.0.2"}}
+				_ = c.Status().Update(context.TODO(), svc)
+			}()
+		}
+		err := waitServices(context.TODO(), c, waitFor, 200*time.Millisecond)
+		if tc.expectedErrorString == "" {
+			if err != nil {
+				t.Fatalf("TestWaitServices(%s): unexpected error, err: %q", desc, err)
+			}
+			continue
+		}
+		if !errors.Is(err, ErrVerificationFailed) || !strings.Contains(err.Error(), tc.expectedErrorString) ||
+			strings.Contains(err.Error(), "default/a") {
+			t.Fatalf("TestWaitServices(%s): expected error %q but got %v", desc, tc.expectedErrorString, err)
+		}
+	}
+}
+
+func TestOnlineMigrationWaitServices(t *testing.T) {
+	var scheme = runtime.NewScheme()
+	if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestOnlineMigrationWaitServices: error adding to scheme, err: %q", err)
+	}
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestOnlineMigrationWaitServices: error adding to scheme, err: %q", err)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+	var addressPools []metallbv1beta1.AddressPool
+	for i, ap := range validAddressPools0 {
+		ap := *ap.DeepCopy()
+		ap.Spec.Addresses = []string{fmt.Sprintf("192.168.100.%d", i+1)}
+		if err := c.Create(context.TODO(), &ap); err != nil {
+			t.Fatalf("TestOnlineMigrationWaitServices: error building fake client, err: %q", err)
+		}
+		addressPools = append(addressPools, ap)
+	}
+	// The Service of ap-bgp, which is migrated first, does not get its IP back. Services without an IP and with an
+	// IP of no pool are not waited for.
+	for _, svc := range []corev1.Service{
+		loadBalancerService("lost", "", "192.168.100.2"),
+		loadBalancerService("pending", "ap-bgp"),
+		loadBalancerService("other", "", "10.10.10.10"),
+	} {
+		if err := c.Create(context.TODO(), svc.DeepCopy()); err != nil {
+			t.Fatalf("TestOnlineMigrationWaitServices: cannot create Service, err: %q", err)
+		}
+	}
+	services, err := poolServices(context.TODO(), c, addressPools[1])
+	expected := []types.NamespacedName{{Namespace: "default", Name: "lost"}}
+	if err != nil || !reflect.DeepEqual(services, expected) {
+		t.Fatalf("TestOnlineMigrationWaitServices: expected Services %v but got %v, err: %v", expected, services,
+			err)
+	}
+	deleteIPs := &ipRemovingClient{Client: c, service: expected[0]}
+
+	SetQuiet(true)
+	defer SetQuiet(false)
+	stdout = bytes.NewBuffer([]byte{})
+	defer func(interval time.Duration) { servicePollInterval = interval }(servicePollInterval)
+	servicePollInterval = 5 * time.Millisecond
+	opts := OnlineMigrationOptions{WaitServices: true, ServiceTimeout: 50 * time.Millisecond}
+	err = OnlineMigration(context.TODO(), deleteIPs, scheme, t.TempDir(), false, opts)
+	if !errors.Is(err, ErrVerificationFailed) || !strings.Contains(err.Error(), "AddressPool metallb-system/ap-bgp "+
+		"was migrated") || !strings.Contains(err.Error(), "Service default/lost") {
+		t.Fatalf("TestOnlineMigrationWaitServices: expected the migration to stop after ap-bgp but got %v", err)
+	}
+	addressPoolList := &metallbv1beta1.AddressPoolList{}
+	if err := c.List(context.TODO(), addressPoolList); err != nil {
+		t.Fatalf("TestOnlineMigrationWaitServices: cannot list AddressPools, err: %q", err)
+	}
+	if len(addressPoolList.Items) != len(validAddressPools0)-1 {
+		t.Fatalf("TestOnlineMigrationWaitServices: expected only ap-bgp to be migrated but %d AddressPools are left",
+			len(addressPoolList.Items))
+	}
+}
+
+// ipRemovingClient removes the load balancer IPs of service when an AddressPool is deleted, like MetalLB does when
+// the pool of an IP disappears.
+type ipRemovingClient struct {
+	client.Client
+	service client.ObjectKey
+}
+
+func (c *ipRemovingClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	if err := c.Client.Delete(ctx, obj, opts...); err != nil {
+		return err
+	}
+	if _, ok := obj.(*metallbv1beta1.AddressPool); !ok {
+		return nil
+	}
+	svc := &corev1.Service{}
+	if err := c.Client.Get(ctx, c.service, svc); err != nil {
+		return err
+	}
+	svc.Status.LoadBalancer.Ingress = nil
+	return c.Client.Status().Update(ctx, svc)
+}
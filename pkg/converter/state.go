@@ -0,0 +1,226 @@
+package converter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// StateInProgress is the state of an AddressPool whose migration was started but not completed. The cluster may
+	// hold the AddressPool, its new resources, or both.
+	StateInProgress = "in-progress"
+	// StateMigrated is the state of an AddressPool whose migration was completed.
+	StateMigrated = "migrated"
+)
+
+// migrationState is the journal of an online migration that OnlineMigrationOptions.StateFile persists. A migration
+// that is run again with the same state file skips the migrated AddressPools and completes the ones in progress.
+type migrationState struct {
+	Pools []poolState `json:"pools"`
+	path  string
+}
+
+// poolState is the state of a single AddressPool of the journal.
+type poolState struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	State     string `json:"state"`
+	// AddressPool is the legacy AddressPool while its migration is in progress, so that its new resources can still
+	// be created if it was deleted before the migration was interrupted.
+	AddressPool *metallbv1beta1.AddressPool `json:"addressPool,omitempty"`
+}
+
+// loadMigrationState reads the state file path. A missing file yields an empty journal. All methods of
+// migrationState do nothing on a nil journal, which loadMigrationState returns if path is empty.
+func loadMigrationState(path string) (*migrationState, error) {
+	if path == "" {
+		return nil, nil
+	}
+	state := &migrationState{path: path}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err == nil {
+		err = json.Unmarshal(data, state)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read state file %q, err: %w", path, err)
+	}
+	return state, nil
+}
+
+// lookup returns the state of the AddressPool namespace/name, or nil if the journal does not know it.
+func (s *migrationState) lookup(namespace, name string) *poolState {
+	if s == nil {
+		return nil
+	}
+	for i := range s.Pools {
+		if s.Pools[i].Namespace == namespace && s.Pools[i].Name == name {
+			return &s.Pools[i]
+		}
+	}
+	return nil
+}
+
+// migrated returns true if the journal records the migration of the AddressPool namespace/name as completed.
+func (s *migrationState) migrated(namespace, name string) bool {
+	pool := s.lookup(namespace, name)
+	return pool != nil && pool.State == StateMigrated
+}
+
+// inProgress returns the AddressPools whose migration was started but not completed.
+func (s *migrationState) inProgress() []metallbv1beta1.AddressPool {
+	if s == nil {
+		return nil
+	}
+	var pools []metallbv1beta1.AddressPool
+	for _, pool := range s.Pools {
+		if pool.State == StateInProgress && pool.AddressPool != nil {
+			pools = append(pools, *pool.AddressPool)
+		}
+	}
+	return pools
+}
+
+// start records that the migration of ap starts and persists the journal.
+func (s *migrationState) start(ap metallbv1beta1.AddressPool) error {
+	if s == nil {
+		return nil
+	}
+	stored := ap.DeepCopy()
+	stored.ObjectMeta = metav1.ObjectMeta{
+		Name: ap.Name, Namespace: ap.Namespace, Labels: ap.Labels, Annotations: ap.Annotations,
+		OwnerReferences: ap.OwnerReferences,
+	}
+	stored.Status = metallbv1beta1.AddressPoolStatus{}
+	if pool := s.lookup(ap.Namespace, ap.Name); pool != nil {
+		pool.State, pool.AddressPool = StateInProgress, stored
+	} else {
+		s.Pools = append(s.Pools, poolState{
+			Namespace: ap.Namespace, Name: ap.Name, State: StateInProgress, AddressPool: stored,
+		})
+	}
+	return s.save()
+}
+
+// finish records that the migration of the AddressPool namespace/name was completed and persists the journal.
+func (s *migrationState) finish(namespace, name string) error {
+	pool := s.lookup(namespace, name)
+	if pool == nil {
+		return nil
+	}
+	pool.State, pool.AddressPool = StateMigrated, nil
+	return s.save()
+}
+
+// abort drops the AddressPool namespace/name from the journal, after its migration was undone, and persists the
+// journal.
+func (s *migrationState) abort(namespace, name string) error {
+	if s == nil {
+		return nil
+	}
+	for i := range s.Pools {
+		if s.Pools[i].Namespace == namespace && s.Pools[i].Name == name {
+			s.Pools = append(s.Pools[:i], s.Pools[i+1:]...)
+			return s.save()
+		}
+	}
+	return nil
+}
+
+// save writes the journal to a temporary file that then replaces the state file, so that an interruption never
+// leaves a truncated state file behind.
+func (s *migrationState) save() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("cannot encode state file %q, err: %w", s.path, err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err == nil {
+		_, err = tmp.Write(append(data, '\n'))
+		if closeErr := tmp.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil {
+			err = os.Rename(tmp.Name(), s.path)
+		}
+		if err != nil {
+			os.Remove(tmp.Name())
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("cannot write state file %q, err: %w", s.path, err)
+	}
+	return nil
+}
+
+// resumeDeleted completes the migrations in progress whose AddressPool no longer exists: the migration was
+// interrupted after the AddressPool was deleted, so its new resources are created from the journal. Resources that the
+// interrupted migration already created are accepted. legacyObjects are the AddressPools of the cluster.
+func (s *migrationState) resumeDeleted(ctx context.Context, c client.Client, legacyObjects *LegacyObjects,
+	opts OnlineMigrationOptions) error {
+	for _, ap := range s.inProgress() {
+		exists := false
+		for _, current := range legacyObjects.AddressPoolList.Items {
+			exists = exists || current.Namespace == ap.Namespace && current.Name == ap.Name
+		}
+		if exists {
+			continue
+		}
+		if !quiet {
+			log.Printf("resuming the migration of AddressPool %s/%s, it was deleted by the interrupted migration",
+				ap.Namespace, ap.Name)
+		}
+		pending := &LegacyObjects{AddressPoolList: &metallbv1beta1.AddressPoolList{
+			Items: []metallbv1beta1.AddressPool{ap},
+		}}
+		currentObjects, err := pending.Convert(opts.Options)
+		if err == nil {
+			err = currentObjects.ResolveCommunityAliases(ctx, c, opts.ExpandCommunityAliases)
+		}
+		if err == nil {
+			err = runOperation(ctx, &resumingClient{Client: c}, opts.OperationPolicies, OperationCreate,
+				func(ctx context.Context, cl client.Client) error {
+					return currentObjects.Create(ctx, cl, opts.Options)
+				})
+		}
+		if err != nil {
+			opts.Report.addPool(ap.Namespace, ap.Name, MigrationFailed, "", nil, err)
+			return fmt.Errorf("cannot resume the migration of AddressPool %s/%s, err: %w", ap.Namespace, ap.Name,
+				err)
+		}
+		var generated []ComplianceResource
+		for _, obj := range currentObjects.objects() {
+			generated = append(generated, reportResource(obj))
+		}
+		opts.Report.addPool(ap.Namespace, ap.Name, MigrationMigrated, "", generated, nil)
+		if err := s.finish(ap.Namespace, ap.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resumingClient accepts that an object to create already exists, because the interrupted migration created it.
+type resumingClient struct {
+	client.Client
+}
+
+func (rc *resumingClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	err := rc.Client.Create(ctx, obj, opts...)
+	if apierrors.IsAlreadyExists(err) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,113 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path"
+	"testing"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestResumeOnlineMigration(t *testing.T) {
+	tcs := map[string]struct {
+		failurePoints          string
+		createFirst            bool
+		noStateFile            bool
+		expectedIPAddressPools int
+	}{
+		"interrupted after the deletion": {
+			failurePoints:          FailurePointAfterDelete,
+			expectedIPAddressPools: len(validAddressPools0),
+		},
+		"interrupted before the creation of the second AddressPool": {
+			failurePoints:          FailurePointBeforeCreate + ":2",
+			expectedIPAddressPools: len(validAddressPools0),
+		},
+		"interrupted after the deletion with create-first": {
+			failurePoints:          FailurePointAfterDelete,
+			createFirst:            true,
+			expectedIPAddressPools: len(validAddressPools0),
+		},
+		"interrupted after the deletion without state file": {
+			failurePoints: FailurePointAfterDelete,
+			noStateFile:   true,
+			// The resources of the AddressPool that was deleted are lost.
+			expectedIPAddressPools: len(validAddressPools0) - 1,
+		},
+	}
+	var scheme = runtime.NewScheme()
+	if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestResumeOnlineMigration: error adding to scheme, err: %q", err)
+	}
+	SetQuiet(true)
+	defer SetQuiet(false)
+	stdout = bytes.NewBuffer([]byte{})
+	for desc, tc := range tcs {
+		c := fake.NewClientBuilder().WithScheme(scheme).Build()
+		for _, ap := range validAddressPools0 {
+			if err := c.Create(context.TODO(), ap.DeepCopy()); err != nil {
+				t.Fatalf("TestResumeOnlineMigration(%s): error building fake client, err: %q", desc, err)
+			}
+		}
+		opts := OnlineMigrationOptions{CreateFirst: tc.createFirst}
+		if !tc.noStateFile {
+			opts.StateFile = path.Join(t.TempDir(), "state.json")
+		}
+		failurePoints, err := ParseFailurePoints(tc.failurePoints)
+		if err != nil {
+			t.Fatalf("TestResumeOnlineMigration(%s): cannot arm failure points, err: %q", desc, err)
+		}
+		opts.FailurePoints = failurePoints
+		backupDir := t.TempDir()
+		err = OnlineMigration(context.TODO(), c, scheme, backupDir, false, opts)
+		if !errors.Is(err, ErrInjectedFailure) {
+			t.Fatalf("TestResumeOnlineMigration(%s): expected an injected failure but got %v", desc, err)
+		}
+		opts.FailurePoints = nil
+		// Without a journal, the run is not a resumption and needs an empty backup directory.
+		resumeBackupDir := backupDir
+		if tc.noStateFile {
+			resumeBackupDir = t.TempDir()
+		}
+		if err := OnlineMigration(context.TODO(), c, scheme, resumeBackupDir, false, opts); err != nil {
+			t.Fatalf("TestResumeOnlineMigration(%s): unexpected error when run again, err: %q", desc, err)
+		}
+		backup, err := ReadLegacyObjectsFromDirectory(scheme, backupDir, Options{})
+		if err != nil {
+			t.Fatalf("TestResumeOnlineMigration(%s): cannot read the backup, err: %q", desc, err)
+		}
+		if len(backup.AddressPoolList.Items) != len(validAddressPools0) {
+			t.Fatalf("TestResumeOnlineMigration(%s): expected the backup of %d AddressPools to be kept, got %d",
+				desc, len(validAddressPools0), len(backup.AddressPoolList.Items))
+		}
+		addressPoolList := &metallbv1beta1.AddressPoolList{}
+		if err := c.List(context.TODO(), addressPoolList); err != nil {
+			t.Fatalf("TestResumeOnlineMigration(%s): cannot list AddressPools, err: %q", desc, err)
+		}
+		ipAddressPoolList := &metallbv1beta1.IPAddressPoolList{}
+		if err := c.List(context.TODO(), ipAddressPoolList); err != nil {
+			t.Fatalf("TestResumeOnlineMigration(%s): cannot list IPAddressPools, err: %q", desc, err)
+		}
+		if len(addressPoolList.Items) != 0 || len(ipAddressPoolList.Items) != tc.expectedIPAddressPools {
+			t.Fatalf("TestResumeOnlineMigration(%s): expected 0 AddressPools and %d IPAddressPools, got %d and %d",
+				desc, tc.expectedIPAddressPools, len(addressPoolList.Items), len(ipAddressPoolList.Items))
+		}
+		if tc.noStateFile {
+			continue
+		}
+		state, err := loadMigrationState(opts.StateFile)
+		if err != nil {
+			t.Fatalf("TestResumeOnlineMigration(%s): cannot load state file, err: %q", desc, err)
+		}
+		for _, ap := range validAddressPools0 {
+			if !state.migrated(ap.Namespace, ap.Name) {
+				t.Fatalf("TestResumeOnlineMigration(%s): expected AddressPool %s to be migrated in state %+v", desc,
+					ap.Name, state.Pools)
+			}
+		}
+	}
+}
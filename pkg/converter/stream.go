@@ -0,0 +1,387 @@
+package converter
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/andreaskaris/metallb-converter/pkg/features"
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/cli-runtime/pkg/printers"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DefaultStreamPageSize is the number of AddressPools that StreamingMigration reads from the API, or from standard
+// input, at once if no page size is given.
+const DefaultStreamPageSize = 500
+
+// legacyBatches returns the next batch of legacy objects of a source, or nil once the source is exhausted. Duplicate
+// AddressPools are not resolved yet, see legacyContent.legacyObjects.
+type legacyBatches func() (*legacyContent, error)
+
+// newLegacyBatches returns the legacy objects of the input of offline and streaming migrations in batches: pages of
+// pageSize AddressPools of the API if inDir is empty or of standard input if inDir is StdinInput, or else the files of
+// the directory inDir. A pageSize of 0 reads all AddressPools of the API or of standard input at once.
+func newLegacyBatches(ctx context.Context, c client.Client, scheme *runtime.Scheme, inDir string, pageSize int,
+	opts Options) (legacyBatches, error) {
+	switch inDir {
+	case "":
+		return apiBatches(ctx, c, pageSize, opts), nil
+	case StdinInput:
+		return readerBatches(scheme, stdin, "stdin", pageSize, opts), nil
+	default:
+		return directoryBatches(scheme, inDir, opts)
+	}
+}
+
+// readAllBatches reads all batches of next and resolves duplicate AddressPools across them according to
+// duplicatePolicy.
+func readAllBatches(next legacyBatches, duplicatePolicy string) (*LegacyObjects, error) {
+	var content legacyContent
+	for {
+		batch, err := next()
+		if err != nil {
+			return nil, err
+		}
+		if batch == nil {
+			break
+		}
+		content.add(*batch)
+	}
+	legacyObjects, err := content.legacyObjects(duplicatePolicy)
+	if err != nil {
+		return nil, fmt.Errorf("could not read legacy objects, err: %w", err)
+	}
+	return legacyObjects, nil
+}
+
+// StreamingMigration runs an offline migration like OfflineMigration, but reads, converts and prints the legacy
+// objects batch by batch instead of holding all of them in memory: a page of pageSize AddressPools of the API or of
+// standard input, or a file of the input directory. The objects are printed to standard out as they are converted, or
+// appended to one file per kind of outDirFlag, which then holds the same files as with OfflineMigration. Only the
+// names of the converted objects are kept, to detect duplicates and name collisions across batches.
+//
+// The settings that need all AddressPools at once are rejected, see streamingConflicts, and the checks that compare
+// AddressPools with each other, e.g. for overlapping addresses, only see the AddressPools of the same batch. The checks
+// of the Services and BGPPeers in the cluster see all AddressPools, but run after all objects were printed. The
+// objects are read, converted and printed according to opts.
+func StreamingMigration(ctx context.Context, c client.Client, scheme *runtime.Scheme, inDirFlag, outDirFlag,
+	outputFormat string, pageSize int, opts Options) error {
+	ctx, span := tracer.Start(ctx, "StreamingMigration")
+	defer span.End()
+	err := streamingMigration(ctx, c, scheme, inDirFlag, outDirFlag, outputFormat, pageSize, opts)
+	span.RecordError(err)
+	return err
+}
+
+func streamingMigration(ctx context.Context, c client.Client, scheme *runtime.Scheme, inDirFlag, outDirFlag,
+	outputFormat string, pageSize int, opts Options) error {
+	if outputFormat != OutputFormatYAML && outputFormat != OutputFormatJSON {
+		return fmt.Errorf("streaming only supports the %s and %s output formats", OutputFormatYAML, OutputFormatJSON)
+	}
+	if conflicts := streamingConflicts(opts); len(conflicts) > 0 {
+		return fmt.Errorf("streaming cannot be combined with %s, they need all AddressPools at once",
+			strings.Join(conflicts, ", "))
+	}
+	if pageSize <= 0 {
+		pageSize = DefaultStreamPageSize
+	}
+	next, err := newLegacyBatches(ctx, c, scheme, inDirFlag, pageSize, opts)
+	if err != nil {
+		return fmt.Errorf("error during retrieval step, err: %w", err)
+	}
+	fromCluster := inDirFlag == ""
+
+	printer := newStreamPrinter(outDirFlag, outputFormat == OutputFormatJSON, opts)
+	defer printer.close()
+	// seenPools and seenObjects are the namespace/name of every AddressPool and the names of every object that was
+	// printed, to detect duplicates across batches.
+	seenPools, seenObjects := map[string]bool{}, map[string]bool{}
+	pools := 0
+	var checks clusterChecks
+	for {
+		batch, err := next()
+		if err != nil {
+			return fmt.Errorf("error during retrieval step, err: %w", err)
+		}
+		if batch == nil {
+			break
+		}
+		legacyObjects, err := batch.legacyObjects(opts.DuplicatePolicy)
+		if err != nil {
+			return fmt.Errorf("error during retrieval step, err: %w", err)
+		}
+		for _, ap := range legacyObjects.AddressPoolList.Items {
+			key := fmt.Sprintf("%s/%s", ap.Namespace, ap.Name)
+			if seenPools[key] {
+				return fmt.Errorf("error during retrieval step, err: AddressPool %s is defined more than once, "+
+					"streaming only supports the %s duplicate policy", key, DuplicatePolicyFail)
+			}
+			seenPools[key] = true
+			if group := ap.Annotations[MergeGroupAnnotation]; group != "" {
+				return fmt.Errorf("error during conversion step, err: AddressPool %s is annotated with %s, "+
+					"merge groups are not supported when streaming", key, MergeGroupAnnotation)
+			}
+		}
+
+		currentObjects, bgpObjects, err := convertLegacyObjects(ctx, c, legacyObjects, fromCluster, opts)
+		if err == nil {
+			for _, name := range currentObjects.Names() {
+				if seenObjects[name] {
+					err = fmt.Errorf("%w:\n%s is generated by more than one batch", ErrNameCollision, name)
+					break
+				}
+				seenObjects[name] = true
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("error during conversion step, err: %w", err)
+		}
+		checks.add(legacyObjects, currentObjects)
+		opts.observeConversion(legacyObjects, currentObjects)
+		pools += len(legacyObjects.AddressPoolList.Items)
+
+		groups, err := currentObjects.runtimeObjectsByKind()
+		if err == nil {
+			err = printer.print(append(groups, bgpObjects...))
+		}
+		if err != nil {
+			return fmt.Errorf("error during print step, err: %w", err)
+		}
+	}
+	if fromCluster {
+		if err := checks.run(ctx, c, opts.SimulateAssignments); err != nil {
+			return fmt.Errorf("error during conversion step, err: %w", err)
+		}
+	}
+	if !quiet {
+		log.Printf("streamed the conversion of %d AddressPools into %d resources", pools, len(seenObjects))
+	}
+	return nil
+}
+
+// streamingConflicts returns the settings that StreamingMigration does not support because they merge, deduplicate or
+// rename objects across AddressPools, or group the output in a way that needs all objects at once. They are named
+// after their flags.
+func streamingConflicts(opts Options) []string {
+	var conflicts []string
+	for _, setting := range []struct {
+		name string
+		set  bool
+	}{
+		{name: "merge-identical-pools", set: opts.MergeIdenticalPools},
+		{name: "merge-l2", set: opts.MergeL2},
+		{name: "merge-policy", set: len(opts.MergePolicy) > 0},
+		{name: "assign-priorities", set: opts.AssignPriorities},
+		{name: "community-resources", set: opts.CommunityResources},
+		{name: "the " + string(features.MergeAdvertisements) + " feature", set: features.Enabled(
+			features.MergeAdvertisements)},
+		{name: "duplicates=" + opts.DuplicatePolicy, set: opts.DuplicatePolicy != "" &&
+			opts.DuplicatePolicy != DuplicatePolicyFail},
+		{name: "name-collisions=" + opts.NameCollisionPolicy, set: opts.NameCollisionPolicy != "" &&
+			opts.NameCollisionPolicy != NameCollisionsFail},
+		{name: "output-group-by=" + opts.OutputGroupBy, set: opts.OutputGroupBy != "" &&
+			opts.OutputGroupBy != OutputGroupByKind},
+		{name: "output-layout=" + opts.OutputLayout, set: opts.OutputLayout != "" &&
+			opts.OutputLayout != OutputLayoutFlat},
+	} {
+		if setting.set {
+			conflicts = append(conflicts, setting.name)
+		}
+	}
+	return conflicts
+}
+
+// apiBatches returns the AddressPools of the API that match the Namespace and the Selector of opts in pages of
+// pageSize. The BGPPeers and BFDProfiles are returned along with the last page.
+func apiBatches(ctx context.Context, c client.Client, pageSize int, opts Options) legacyBatches {
+	continueToken, done := "", false
+	return func() (*legacyContent, error) {
+		if done {
+			return nil, nil
+		}
+		filter := opts.listOptions()
+		addressPoolList := &metallbv1beta1.AddressPoolList{}
+		err := c.List(ctx, addressPoolList, &filter, client.Limit(pageSize), client.Continue(continueToken))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list AddressPools in cluster: %w", err)
+		}
+		var batch legacyContent
+		for _, ap := range addressPoolList.Items {
+			ap.ObjectMeta = sanitizeObjectMeta(ap.ObjectMeta)
+			batch.pools = append(batch.pools, sourcedAddressPool{addressPool: ap, source: "api"})
+		}
+		continueToken = addressPoolList.Continue
+		if continueToken != "" {
+			return &batch, nil
+		}
+		done = true
+		bgpPeerList, bfdProfileList, err := listBGPResources(ctx, c, filter.Namespace)
+		if err != nil {
+			return nil, err
+		}
+		batch.bgpPeers, batch.bfdProfiles = bgpPeerList.Items, bfdProfileList.Items
+		return &batch, nil
+	}
+}
+
+// directoryBatches returns the legacy objects of dir file by file, in the order of ReadLegacyObjectsFromDirectory.
+// Only the file that is converted is held in memory.
+func directoryBatches(scheme *runtime.Scheme, dir string, opts Options) (legacyBatches, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("could not read legacy objects from directory, err: %w", err)
+	}
+	ignore, err := loadIgnoreFile(dir)
+	if err != nil {
+		return nil, fmt.Errorf("could not read legacy objects from directory, err: %w", err)
+	}
+	return func() (*legacyContent, error) {
+		for len(files) > 0 {
+			file := files[0]
+			files = files[1:]
+			if ignore.ignores(file.Name(), file.IsDir()) {
+				continue
+			}
+			if opts.IgnoreUnknown && file.IsDir() {
+				return &legacyContent{skipped: []string{fmt.Sprintf("%s: directory", file.Name())}}, nil
+			}
+			content, err := os.ReadFile(path.Join(dir, file.Name()))
+			if err != nil {
+				return nil, fmt.Errorf("could not read legacy objects from directory, err: %w", err)
+			}
+			decoded, err := decodeLegacyContent(scheme, content, file.Name(), opts)
+			if err != nil {
+				return nil, fmt.Errorf("could not read legacy objects from directory, err: %w", err)
+			}
+			return &decoded, nil
+		}
+		return nil, nil
+	}, nil
+}
+
+// readerBatches returns the legacy objects of the YAML documents of r in batches of at least pageSize AddressPools,
+// and of the remaining AddressPools at the end, or in a single batch if pageSize is 0. source names the stream in error
+// messages.
+func readerBatches(scheme *runtime.Scheme, r io.Reader, source string, pageSize int, opts Options) legacyBatches {
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(r))
+	done := false
+	return func() (*legacyContent, error) {
+		var batch legacyContent
+		for !done && (pageSize <= 0 || len(batch.pools) < pageSize) {
+			document, err := reader.Read()
+			if errors.Is(err, io.EOF) {
+				done = true
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("could not read legacy objects from %s, err: %w", source, err)
+			}
+			decoded, err := decodeLegacyContent(scheme, document, source, opts)
+			if err != nil {
+				return nil, fmt.Errorf("could not read legacy objects, err: %w", err)
+			}
+			batch.add(decoded)
+		}
+		if done && len(batch.pools) == 0 && len(batch.bgpPeers) == 0 && len(batch.bfdProfiles) == 0 &&
+			len(batch.skipped) == 0 {
+			return nil, nil
+		}
+		return &batch, nil
+	}
+}
+
+// streamPrinter prints the objects of a streaming conversion as they are converted: to stdout, or appended to one file
+// per kind of dir. Every file keeps its printer, so that the files match those that printGroups writes at once.
+type streamPrinter struct {
+	dir     string
+	toJSON  bool
+	opts    Options
+	stdout  printers.ResourcePrinter
+	files   map[string]*os.File
+	writers map[string]printers.ResourcePrinter
+}
+
+func newStreamPrinter(dir string, toJSON bool, opts Options) *streamPrinter {
+	return &streamPrinter{
+		dir:     dir,
+		toJSON:  toJSON,
+		opts:    opts,
+		files:   map[string]*os.File{},
+		writers: map[string]printers.ResourcePrinter{},
+	}
+}
+
+// newPrinter returns a new YAML or JSON printer.
+func (p *streamPrinter) newPrinter() printers.ResourcePrinter {
+	if p.toJSON {
+		return &printers.JSONPrinter{}
+	}
+	return &printers.YAMLPrinter{}
+}
+
+// print prints groups of objects of the same kind.
+func (p *streamPrinter) print(groups [][]runtime.Object) error {
+	for _, group := range groups {
+		if len(group) == 0 {
+			continue
+		}
+		w, printer, err := p.writer(group[0].GetObjectKind().GroupVersionKind().Kind)
+		if err != nil {
+			return err
+		}
+		for _, obj := range group {
+			printed, err := printObj(p.opts.withAPIGroup(obj), printer)
+			if err != nil {
+				return fmt.Errorf("cannot print object, err: %w\nruntime object: %+v", err, obj)
+			}
+			if _, err := fmt.Fprint(w, printed); err != nil {
+				return fmt.Errorf("cannot print object, err: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// writer returns the writer and the printer of the objects of kind. The file of kind is truncated when it is first
+// written to.
+func (p *streamPrinter) writer(kind string) (io.Writer, printers.ResourcePrinter, error) {
+	if p.dir == "" {
+		if p.stdout == nil {
+			p.stdout = p.newPrinter()
+		}
+		return stdout, p.stdout, nil
+	}
+	if f, ok := p.files[kind]; ok {
+		return f, p.writers[kind], nil
+	}
+	fileExtension := "yaml"
+	if p.toJSON {
+		fileExtension = "json"
+	}
+	if err := os.MkdirAll(p.dir, 0755); err != nil {
+		return nil, nil, fmt.Errorf("cannot create destination directory, err: %w", err)
+	}
+	f, err := os.OpenFile(path.Join(p.dir, fmt.Sprintf("%s.%s", kind, fileExtension)),
+		os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot create destination file, err: %w", err)
+	}
+	p.files[kind], p.writers[kind] = f, p.newPrinter()
+	return f, p.writers[kind], nil
+}
+
+// close closes all files.
+func (p *streamPrinter) close() {
+	for _, f := range p.files {
+		f.Close()
+	}
+}
@@ -0,0 +1,257 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/yaml"
+)
+
+// pagingClient pages AddressPoolLists by the limit and the continue token of the list options, which the fake client
+// ignores. The continue token is the index of the next AddressPool.
+type pagingClient struct {
+	client.Client
+	pages int
+}
+
+func (c *pagingClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	addressPoolList, ok := list.(*metallbv1beta1.AddressPoolList)
+	if !ok {
+		return c.Client.List(ctx, list, opts...)
+	}
+	listOpts := &client.ListOptions{}
+	listOpts.ApplyOptions(opts)
+	if err := c.Client.List(ctx, addressPoolList, &client.ListOptions{Namespace: listOpts.Namespace,
+		LabelSelector: listOpts.LabelSelector}); err != nil {
+		return err
+	}
+	c.pages++
+	start := 0
+	if listOpts.Continue != "" {
+		var err error
+		if start, err = strconv.Atoi(listOpts.Continue); err != nil {
+			return err
+		}
+	}
+	end := len(addressPoolList.Items)
+	if listOpts.Limit > 0 && start+int(listOpts.Limit) < end {
+		end = start + int(listOpts.Limit)
+		addressPoolList.Continue = strconv.Itoa(end)
+	}
+	addressPoolList.Items = addressPoolList.Items[start:end]
+	return nil
+}
+
+// streamedAddressPools returns n layer2 AddressPools with distinct addresses.
+func streamedAddressPools(n int) []metallbv1beta1.AddressPool {
+	var pools []metallbv1beta1.AddressPool
+	for i := 0; i < n; i++ {
+		pools = append(pools, metallbv1beta1.AddressPool{
+			TypeMeta:   metav1.TypeMeta{Kind: "AddressPool", APIVersion: "metallb.io/v1beta1"},
+			ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("pool%d", i), Namespace: "metallb-system"},
+			Spec: metallbv1beta1.AddressPoolSpec{
+				Protocol:  "layer2",
+				Addresses: []string{fmt.Sprintf("10.0.%d.0/24", i)},
+			},
+		})
+	}
+	return pools
+}
+
+// readOutputs returns the content of the files of dir by name.
+func readOutputs(t *testing.T, dir string) map[string]string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("cannot read output directory %s, err: %q", dir, err)
+	}
+	outputs := map[string]string{}
+	for _, entry := range entries {
+		content, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			t.Fatalf("cannot read output %s, err: %q", entry.Name(), err)
+		}
+		outputs[entry.Name()] = string(content)
+	}
+	return outputs
+}
+
+func TestStreamingMigration(t *testing.T) {
+	var scheme = runtime.NewScheme()
+	if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestStreamingMigration: error adding to scheme, err: %q", err)
+	}
+	pools := streamedAddressPools(5)
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+	inDir := t.TempDir()
+	var documents []string
+	for _, ap := range pools {
+		out, err := yaml.Marshal(ap)
+		if err != nil {
+			t.Fatalf("TestStreamingMigration: cannot marshal AddressPool, err: %q", err)
+		}
+		documents = append(documents, string(out))
+		if err := c.Create(context.TODO(), ap.DeepCopy()); err != nil {
+			t.Fatalf("TestStreamingMigration: error building fake client, err: %q", err)
+		}
+	}
+	// The input directory holds one file with the first two AddressPools and one with the others.
+	files := map[string]string{
+		"a.yaml": strings.Join(documents[:2], "---\n"),
+		"b.yaml": strings.Join(documents[2:], "---\n"),
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(inDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("TestStreamingMigration: cannot write %s, err: %q", name, err)
+		}
+	}
+	duplicateDir := t.TempDir()
+	for _, name := range []string{"a.yaml", "b.yaml"} {
+		if err := os.WriteFile(filepath.Join(duplicateDir, name), []byte(documents[0]), 0644); err != nil {
+			t.Fatalf("TestStreamingMigration: cannot write %s, err: %q", name, err)
+		}
+	}
+
+	tcs := map[string]struct {
+		inDir               string
+		stdin               string
+		outputFormat        string
+		opts                Options
+		expectedPages       int
+		expectedErrorString string
+	}{
+		"api": {
+			expectedPages: 3,
+		},
+		"directory": {
+			inDir: inDir,
+		},
+		"stdin": {
+			inDir: StdinInput,
+			stdin: strings.Join(documents, "---\n"),
+		},
+		"json": {
+			inDir:        inDir,
+			outputFormat: OutputFormatJSON,
+		},
+		"unsupported format": {
+			inDir:               inDir,
+			outputFormat:        OutputFormatCSV,
+			expectedErrorString: "streaming only supports the yaml and json output formats",
+		},
+		"duplicate across files": {
+			inDir:               duplicateDir,
+			expectedErrorString: "AddressPool metallb-system/pool0 is defined more than once",
+		},
+		"merge l2": {
+			inDir:               inDir,
+			opts:                Options{MergeL2: true},
+			expectedErrorString: "streaming cannot be combined with merge-l2",
+		},
+		"name collisions suffix": {
+			inDir:               inDir,
+			opts:                Options{NameCollisionPolicy: NameCollisionsSuffix},
+			expectedErrorString: "streaming cannot be combined with name-collisions=suffix",
+		},
+	}
+	SetQuiet(true)
+	defer SetQuiet(false)
+	defer func() { stdin = os.Stdin }()
+	for desc, tc := range tcs {
+		outputFormat := tc.outputFormat
+		if outputFormat == "" {
+			outputFormat = OutputFormatYAML
+		}
+		stdout = bytes.NewBuffer([]byte{})
+		stdin = strings.NewReader(tc.stdin)
+		streamDir := t.TempDir()
+		pc := &pagingClient{Client: c}
+		err := StreamingMigration(context.TODO(), pc, scheme, tc.inDir, streamDir, outputFormat, 2, tc.opts)
+		if tc.expectedErrorString != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.expectedErrorString) {
+				t.Fatalf("TestStreamingMigration(%s): expected error %q but got %v", desc, tc.expectedErrorString,
+					err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("TestStreamingMigration(%s): unexpected error, err: %q", desc, err)
+		}
+		if tc.expectedPages != 0 && pc.pages != tc.expectedPages {
+			t.Fatalf("TestStreamingMigration(%s): expected %d pages but got %d", desc, tc.expectedPages, pc.pages)
+		}
+
+		// The output directory must hold the same files as that of an offline migration.
+		stdin = strings.NewReader(tc.stdin)
+		outDir := t.TempDir()
+		if err := OfflineMigration(context.TODO(), c, scheme, tc.inDir, outDir, outputFormat,
+			OfflineMigrationOptions{}); err != nil {
+			t.Fatalf("TestStreamingMigration(%s): unexpected error during offline migration, err: %q", desc, err)
+		}
+		streamed, expected := readOutputs(t, streamDir), readOutputs(t, outDir)
+		if len(streamed) != len(expected) {
+			t.Fatalf("TestStreamingMigration(%s): expected files %v but got %v", desc, expected, streamed)
+		}
+		for name, content := range expected {
+			if streamed[name] != content {
+				t.Fatalf("TestStreamingMigration(%s): expected %s:\n%s\nbut got:\n%s", desc, name, content,
+					streamed[name])
+			}
+		}
+	}
+}
+
+func TestStreamingMigrationClusterChecks(t *testing.T) {
+	var scheme = runtime.NewScheme()
+	if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestStreamingMigrationClusterChecks: error adding to scheme, err: %q", err)
+	}
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestStreamingMigrationClusterChecks: error adding to scheme, err: %q", err)
+	}
+	pools := streamedAddressPools(3)
+	pools[0].Spec.Protocol = ProtocolBGP
+	svc := loadBalancerService("svc", "", "10.0.2.5")
+	builder := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&svc)
+	for i := range pools {
+		builder = builder.WithObjects(&pools[i])
+	}
+	pc := &pagingClient{Client: builder.Build()}
+
+	stdout = bytes.NewBuffer([]byte{})
+	logs := bytes.NewBuffer([]byte{})
+	log.SetOutput(logs)
+	err := StreamingMigration(context.TODO(), pc, scheme, "", "", OutputFormatYAML, 1,
+		Options{SimulateAssignments: true})
+	log.SetOutput(os.Stderr)
+	if err != nil {
+		t.Fatalf("TestStreamingMigrationClusterChecks: unexpected error, err: %q", err)
+	}
+	if pc.pages != 3 {
+		t.Fatalf("TestStreamingMigrationClusterChecks: expected 3 pages but got %d", pc.pages)
+	}
+	// The Service's IP is in the pool of the last page, the checks must see the pools of all pages.
+	for _, expected := range []string{
+		"1 BGPAdvertisements were converted but there are no BGPPeers",
+		"the IPs of all 1 LoadBalancer Services would be kept",
+	} {
+		if !strings.Contains(logs.String(), expected) {
+			t.Fatalf("TestStreamingMigrationClusterChecks: expected log %q, got logs %q", expected, logs)
+		}
+	}
+	if strings.Contains(logs.String(), "would lose its IP") {
+		t.Fatalf("TestStreamingMigrationClusterChecks: unexpected warning, got logs %q", logs)
+	}
+}
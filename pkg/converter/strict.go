@@ -0,0 +1,36 @@
+package converter
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/andreaskaris/metallb-converter/pkg/validate"
+)
+
+// ValidationProblems runs the checks of package validate on the legacy AddressPools and the converted resources and
+// returns a description of every problem.
+func ValidationProblems(legacyObjects *LegacyObjects, currentObjects *CurrentObjects) []string {
+	problems := validate.AddressPools(legacyObjects.AddressPoolList.Items)
+	return append(problems, validate.Resources(currentObjects.IPAddressPoolList.Items,
+		currentObjects.L2AdvertisementList.Items, currentObjects.BGPAdvertisementList.Items,
+		currentObjects.CommunityList.Items)...)
+}
+
+// checkValidate returns an error that lists every problem of ValidationProblems if strict is true and logs them as
+// warnings otherwise.
+func checkValidate(legacyObjects *LegacyObjects, currentObjects *CurrentObjects, strict bool) error {
+	problems := ValidationProblems(legacyObjects, currentObjects)
+	if len(problems) == 0 {
+		return nil
+	}
+	if strict {
+		return fmt.Errorf("%w:\n%s", ErrStrictValidation, strings.Join(problems, "\n"))
+	}
+	if !quiet {
+		for _, problem := range problems {
+			log.Printf("warning: %s", problem)
+		}
+	}
+	return nil
+}
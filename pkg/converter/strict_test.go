@@ -0,0 +1,80 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// legacyAggregatedPool is an AddressPool whose aggregation length is shorter than its CIDR, which MetalLB rejects.
+const legacyAggregatedPool = `apiVersion: metallb.io/v1beta1
+kind: AddressPool
+metadata:
+  name: aggregated
+  namespace: metallb-system
+spec:
+  addresses:
+  - 192.168.10.0/24
+  protocol: bgp
+  bgpAdvertisements:
+  - aggregationLength: 16
+`
+
+func TestStrictValidation(t *testing.T) {
+	tcs := map[string]struct {
+		strict              bool
+		input               string
+		expectedErrorString string
+	}{
+		"valid input in strict mode": {
+			strict: true,
+			input:  legacyBGPResources,
+		},
+		"invalid aggregation length in warn mode": {
+			input: legacyAggregatedPool,
+		},
+		"invalid aggregation length in strict mode": {
+			strict:              true,
+			input:               legacyAggregatedPool,
+			expectedErrorString: "invalid IPv4 aggregation length 16",
+		},
+	}
+	var scheme = runtime.NewScheme()
+	if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestStrictValidation: error adding to scheme, err: %q", err)
+	}
+	SetQuiet(true)
+	defer SetQuiet(false)
+	for desc, tc := range tcs {
+		stdout = bytes.NewBuffer([]byte{})
+		inDir := t.TempDir()
+		if err := os.WriteFile(path.Join(inDir, "config.yaml"), []byte(tc.input), 0644); err != nil {
+			t.Fatal(err)
+		}
+		err := OfflineMigration(context.TODO(), nil, scheme, inDir, "", OutputFormatYAML, OfflineMigrationOptions{
+			Options: Options{StrictValidation: tc.strict},
+		})
+		if tc.expectedErrorString == "" {
+			if err != nil {
+				t.Fatalf("TestStrictValidation(%s): unexpected error, err: %q", desc, err)
+			}
+			if !strings.Contains(stdout.(*bytes.Buffer).String(), "kind: IPAddressPool") {
+				t.Fatalf("TestStrictValidation(%s): expected the converted resources to be printed", desc)
+			}
+			continue
+		}
+		if !errors.Is(err, ErrStrictValidation) || !strings.Contains(err.Error(), tc.expectedErrorString) {
+			t.Fatalf("TestStrictValidation(%s): expected error %q but got %v", desc, tc.expectedErrorString, err)
+		}
+		if code := Code(err); code != ErrorCodeStrictValidation {
+			t.Fatalf("TestStrictValidation(%s): expected code %q but got %q", desc, ErrorCodeStrictValidation, code)
+		}
+	}
+}
@@ -0,0 +1,357 @@
+package converter
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// SyncLegacyHashAnnotation records the hash of the spec of a legacy AddressPool at its last sync.
+	SyncLegacyHashAnnotation = "metallb-converter.io/sync-legacy-hash"
+	// SyncCurrentHashAnnotation records the hash of the specs of the converted resources of a legacy AddressPool at
+	// its last sync.
+	SyncCurrentHashAnnotation = "metallb-converter.io/sync-current-hash"
+)
+
+// SyncResult describes the outcome of a Sync.
+type SyncResult struct {
+	// Updated lists the objects that were updated to propagate a change.
+	Updated []string
+	// Conflicts lists the AddressPools that could not be synced, and why.
+	Conflicts []string
+}
+
+// Sync keeps the legacy AddressPools in the API and the resources that they were converted to consistent in both
+// directions, for clusters that must run with both representations for a while. Only AddressPools whose IPAddressPool
+// exists are synced. Sync records the hashes of both sides on the AddressPool, see SyncLegacyHashAnnotation and
+// SyncCurrentHashAnnotation, to find the side that changed since the last sync:
+//   - if the AddressPool changed, its converted resources are updated,
+//   - if the converted resources changed, the AddressPool is updated, provided that the change can be represented by
+//     an AddressPool that converts back to exactly the same resources,
+//   - if both sides changed, or the sides differ and were never synced, a conflict is reported and nothing is changed.
+//
+// The AddressPools are read and converted according to opts.
+func Sync(ctx context.Context, cl client.Client, opts Options) (*SyncResult, error) {
+	legacyObjects, err := ReadLegacyObjectsFromAPI(ctx, cl, 0, opts)
+	if err != nil {
+		return nil, err
+	}
+	result := &SyncResult{}
+	for _, ap := range legacyObjects.AddressPoolList.Items {
+		if err := syncPool(ctx, cl, ap, result, opts); err != nil {
+			return result, fmt.Errorf("cannot sync AddressPool %s/%s, err: %w", ap.Namespace, ap.Name, err)
+		}
+	}
+	return result, nil
+}
+
+// syncPool syncs a single AddressPool with its converted resources and adds the outcome to result.
+func syncPool(ctx context.Context, cl client.Client, ap metallbv1beta1.AddressPool, result *SyncResult,
+	opts Options) error {
+	conflict := func(format string, args ...interface{}) {
+		result.Conflicts = append(result.Conflicts, fmt.Sprintf("AddressPool %s/%s: ", ap.Namespace, ap.Name)+
+			fmt.Sprintf(format, args...))
+	}
+	desired, err := convertSingle(ctx, cl, ap, opts)
+	if err != nil {
+		return err
+	}
+	migrated, err := desired.migrated(ctx, cl)
+	if err != nil {
+		return err
+	}
+	if !migrated {
+		if isMigrated(ap) {
+			conflict("marked as migrated, but its IPAddressPool does not exist")
+		}
+		return nil
+	}
+	live, missing, err := liveObjects(ctx, cl, *desired)
+	if err != nil {
+		return err
+	}
+	if len(missing) > 0 {
+		conflict("%s of its converted resources do not exist", missing)
+		return nil
+	}
+	legacyHash, currentHash := specHash(ap.Spec), live.specHash()
+	recordedLegacyHash := ap.Annotations[SyncLegacyHashAnnotation]
+	recordedCurrentHash := ap.Annotations[SyncCurrentHashAnnotation]
+	legacyChanged, currentChanged := legacyHash != recordedLegacyHash, currentHash != recordedCurrentHash
+
+	switch {
+	case desired.specHash() == currentHash:
+		// Both sides are consistent, only record their hashes.
+	case recordedLegacyHash == "" || recordedCurrentHash == "":
+		conflict("differs from its converted resources and was never synced, convert it again or update it manually")
+		return nil
+	case legacyChanged && currentChanged:
+		conflict("both the AddressPool and its converted resources changed since the last sync")
+		return nil
+	case legacyChanged:
+		updated, err := live.updateSpecs(ctx, cl, *desired)
+		if err != nil {
+			return err
+		}
+		result.Updated = append(result.Updated, updated...)
+		currentHash = desired.specHash()
+	case currentChanged:
+		spec, err := legacySpecFrom(ctx, cl, ap, live, opts)
+		if err != nil {
+			conflict("the change of its converted resources cannot be synced back, %v", err)
+			return nil
+		}
+		ap.Spec = spec
+		legacyHash = specHash(ap.Spec)
+		result.Updated = append(result.Updated, fmt.Sprintf("AddressPool %s/%s", ap.Namespace, ap.Name))
+	}
+	if legacyHash == recordedLegacyHash && currentHash == recordedCurrentHash {
+		return nil
+	}
+	return recordSync(ctx, cl, ap, legacyHash, currentHash)
+}
+
+// convertSingle converts a single AddressPool on its own according to opts, as an online migration would.
+func convertSingle(ctx context.Context, cl client.Client, ap metallbv1beta1.AddressPool,
+	opts Options) (*CurrentObjects, error) {
+	single := &LegacyObjects{AddressPoolList: &metallbv1beta1.AddressPoolList{
+		Items: []metallbv1beta1.AddressPool{ap},
+	}}
+	currentObjects, err := single.Convert(opts)
+	if err == nil {
+		err = currentObjects.ResolveCommunityAliases(ctx, cl, opts.ExpandCommunityAliases)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot convert AddressPool %s/%s, err: %w", ap.Namespace, ap.Name, err)
+	}
+	return currentObjects, nil
+}
+
+// liveObjects returns the live IPAddressPools, L2Advertisements and BGPAdvertisements of the objects of c, in the same
+// order, and the objects that do not exist. Communities are shared by all pools of a namespace and are not returned.
+func liveObjects(ctx context.Context, cl client.Client, c CurrentObjects) (*CurrentObjects, []string, error) {
+	live := &CurrentObjects{
+		IPAddressPoolList:    &metallbv1beta1.IPAddressPoolList{},
+		L2AdvertisementList:  &metallbv1beta1.L2AdvertisementList{},
+		BGPAdvertisementList: &metallbv1beta1.BGPAdvertisementList{},
+		CommunityList:        &metallbv1beta1.CommunityList{},
+	}
+	var missing []string
+	get := func(kind string, key types.NamespacedName, obj client.Object) (bool, error) {
+		err := cl.Get(ctx, key, obj)
+		if client.IgnoreNotFound(err) != nil {
+			return false, fmt.Errorf("cannot get %s %s, err: %w", kind, key, err)
+		}
+		if err != nil {
+			missing = append(missing, fmt.Sprintf("%s %s", kind, key))
+			return false, nil
+		}
+		return true, nil
+	}
+	for _, iap := range c.IPAddressPoolList.Items {
+		current := metallbv1beta1.IPAddressPool{}
+		found, err := get("IPAddressPool", types.NamespacedName{Namespace: iap.Namespace, Name: iap.Name}, &current)
+		if err != nil {
+			return nil, nil, err
+		}
+		if found {
+			live.IPAddressPoolList.Items = append(live.IPAddressPoolList.Items, current)
+		}
+	}
+	for _, l2a := range c.L2AdvertisementList.Items {
+		current := metallbv1beta1.L2Advertisement{}
+		found, err := get("L2Advertisement", types.NamespacedName{Namespace: l2a.Namespace, Name: l2a.Name}, &current)
+		if err != nil {
+			return nil, nil, err
+		}
+		if found {
+			live.L2AdvertisementList.Items = append(live.L2AdvertisementList.Items, current)
+		}
+	}
+	for _, ba := range c.BGPAdvertisementList.Items {
+		current := metallbv1beta1.BGPAdvertisement{}
+		found, err := get("BGPAdvertisement", types.NamespacedName{Namespace: ba.Namespace, Name: ba.Name}, &current)
+		if err != nil {
+			return nil, nil, err
+		}
+		if found {
+			live.BGPAdvertisementList.Items = append(live.BGPAdvertisementList.Items, current)
+		}
+	}
+	return live, missing, nil
+}
+
+// updateSpecs sets the specs of the live objects of c to the specs of the corresponding objects of desired and
+// returns the objects that were updated.
+func (c CurrentObjects) updateSpecs(ctx context.Context, cl client.Client, desired CurrentObjects) ([]string, error) {
+	var updated []string
+	update := func(kind string, obj client.Object) error {
+		if err := cl.Update(ctx, obj); err != nil {
+			return fmt.Errorf("cannot update %s %s/%s, err: %w", kind, obj.GetNamespace(), obj.GetName(),
+				classifyAPIError(err))
+		}
+		updated = append(updated, fmt.Sprintf("%s %s/%s", kind, obj.GetNamespace(), obj.GetName()))
+		return nil
+	}
+	for i := range c.IPAddressPoolList.Items {
+		iap := &c.IPAddressPoolList.Items[i]
+		if equality.Semantic.DeepEqual(iap.Spec, desired.IPAddressPoolList.Items[i].Spec) {
+			continue
+		}
+		iap.Spec = desired.IPAddressPoolList.Items[i].Spec
+		if err := update("IPAddressPool", iap); err != nil {
+			return updated, err
+		}
+	}
+	for i := range c.L2AdvertisementList.Items {
+		l2a := &c.L2AdvertisementList.Items[i]
+		if equality.Semantic.DeepEqual(l2a.Spec, desired.L2AdvertisementList.Items[i].Spec) {
+			continue
+		}
+		l2a.Spec = desired.L2AdvertisementList.Items[i].Spec
+		if err := update("L2Advertisement", l2a); err != nil {
+			return updated, err
+		}
+	}
+	for i := range c.BGPAdvertisementList.Items {
+		ba := &c.BGPAdvertisementList.Items[i]
+		if equality.Semantic.DeepEqual(ba.Spec, desired.BGPAdvertisementList.Items[i].Spec) {
+			continue
+		}
+		ba.Spec = desired.BGPAdvertisementList.Items[i].Spec
+		if err := update("BGPAdvertisement", ba); err != nil {
+			return updated, err
+		}
+	}
+	return updated, nil
+}
+
+// legacySpecFrom returns the spec of an AddressPool that converts to the live objects, which are the converted
+// resources of ap. It returns an error if no such spec exists, e.g. because the live objects use features that the
+// legacy format cannot represent. The spec is converted according to opts to compare it with the live objects.
+func legacySpecFrom(ctx context.Context, cl client.Client, ap metallbv1beta1.AddressPool,
+	live *CurrentObjects, opts Options) (metallbv1beta1.AddressPoolSpec, error) {
+	if len(live.IPAddressPoolList.Items) != 1 {
+		return metallbv1beta1.AddressPoolSpec{}, fmt.Errorf("the AddressPool was converted to %d IPAddressPools",
+			len(live.IPAddressPoolList.Items))
+	}
+	downgrade := newDowngradeObjects()
+	downgrade.IPAddressPoolList.Items = live.IPAddressPoolList.Items
+	downgrade.L2AdvertisementList.Items = live.L2AdvertisementList.Items
+	downgrade.BGPAdvertisementList.Items = live.BGPAdvertisementList.Items
+	if err := cl.List(ctx, downgrade.CommunityList, client.InNamespace(ap.Namespace)); err != nil {
+		return metallbv1beta1.AddressPoolSpec{}, fmt.Errorf("cannot list Communities, err: %w", err)
+	}
+	config, _, err := downgrade.toLegacyConfig(ap.Namespace)
+	if err != nil {
+		return metallbv1beta1.AddressPoolSpec{}, err
+	}
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		return metallbv1beta1.AddressPoolSpec{}, err
+	}
+	parsed, err := ParseLegacyConfig(data, ap.Namespace)
+	if err != nil {
+		return metallbv1beta1.AddressPoolSpec{}, err
+	}
+	reverted := ap.DeepCopy()
+	reverted.Spec = parsed.AddressPoolList.Items[0].Spec
+	converted, err := convertSingle(ctx, cl, *reverted, opts)
+	if err != nil {
+		return metallbv1beta1.AddressPoolSpec{}, err
+	}
+	if converted.specHash() != live.specHash() {
+		return metallbv1beta1.AddressPoolSpec{}, fmt.Errorf("the legacy format cannot represent it")
+	}
+	return reverted.Spec, nil
+}
+
+// recordSync updates the AddressPool with the hashes of both sides of the sync.
+func recordSync(ctx context.Context, cl client.Client, ap metallbv1beta1.AddressPool,
+	legacyHash, currentHash string) error {
+	current := &metallbv1beta1.AddressPool{}
+	if err := cl.Get(ctx, types.NamespacedName{Namespace: ap.Namespace, Name: ap.Name}, current); err != nil {
+		return fmt.Errorf("cannot get AddressPool, err: %w", err)
+	}
+	current.Spec = ap.Spec
+	if current.Annotations == nil {
+		current.Annotations = map[string]string{}
+	}
+	current.Annotations[SyncLegacyHashAnnotation] = legacyHash
+	current.Annotations[SyncCurrentHashAnnotation] = currentHash
+	if err := cl.Update(ctx, current); err != nil {
+		return fmt.Errorf("cannot update AddressPool, err: %w", classifyAPIError(err))
+	}
+	return nil
+}
+
+// specHash returns the hash of the specs of the IPAddressPools, L2Advertisements and BGPAdvertisements of c.
+func (c CurrentObjects) specHash() string {
+	var specs []interface{}
+	for _, iap := range c.IPAddressPoolList.Items {
+		specs = append(specs, iap.Spec)
+	}
+	for _, l2a := range c.L2AdvertisementList.Items {
+		specs = append(specs, l2a.Spec)
+	}
+	for _, ba := range c.BGPAdvertisementList.Items {
+		specs = append(specs, ba.Spec)
+	}
+	return specHash(specs)
+}
+
+// specHash returns the first 16 hex encoded characters of the sha256 sum of the JSON representation of spec.
+func specHash(spec interface{}) string {
+	b, err := json.Marshal(spec)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+// MonitorSync runs Sync every interval and logs all updates and conflicts until ctx is done. If interval is 0, the
+// sides are synced once and an error is returned if there is any conflict, so that the result can be used in scripts.
+func MonitorSync(ctx context.Context, cl client.Client, interval time.Duration, opts Options) error {
+	for {
+		result, err := Sync(ctx, cl, opts)
+		if err != nil {
+			if interval == 0 {
+				return fmt.Errorf("cannot sync, err: %w", err)
+			}
+			log.Printf("cannot sync, err: %q", err)
+		}
+		if result != nil {
+			for _, updated := range result.Updated {
+				if !quiet {
+					log.Printf("synced %s", updated)
+				}
+			}
+			for _, conflict := range result.Conflicts {
+				log.Printf("conflict: %s", conflict)
+			}
+		}
+		if interval == 0 {
+			if result != nil && len(result.Conflicts) > 0 {
+				return fmt.Errorf("%w: %d AddressPools could not be synced", ErrSyncConflict, len(result.Conflicts))
+			}
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
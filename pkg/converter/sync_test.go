@@ -0,0 +1,155 @@
+package converter
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// modifyAddressPool updates the spec of the legacy AddressPool metallb-system/name.
+func modifyAddressPool(c client.Client, name string, modify func(*metallbv1beta1.AddressPoolSpec)) error {
+	ap := &metallbv1beta1.AddressPool{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Namespace: "metallb-system", Name: name}, ap); err != nil {
+		return err
+	}
+	modify(&ap.Spec)
+	return c.Update(context.TODO(), ap)
+}
+
+// modifyIPAddressPool updates the spec of the IPAddressPool metallb-system/name.
+func modifyIPAddressPool(c client.Client, name string, modify func(*metallbv1beta1.IPAddressPoolSpec)) error {
+	iap := &metallbv1beta1.IPAddressPool{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Namespace: "metallb-system", Name: name}, iap); err != nil {
+		return err
+	}
+	modify(&iap.Spec)
+	return c.Update(context.TODO(), iap)
+}
+
+func TestSync(t *testing.T) {
+	changeLegacy := func(c client.Client) error {
+		return modifyAddressPool(c, "ap-l2", func(spec *metallbv1beta1.AddressPoolSpec) {
+			spec.Addresses = []string{"10.0.0.0/24"}
+		})
+	}
+	changeCurrent := func(c client.Client) error {
+		return modifyIPAddressPool(c, "ap-l2", func(spec *metallbv1beta1.IPAddressPoolSpec) {
+			spec.Addresses = []string{"10.0.1.0/24"}
+		})
+	}
+	tcs := map[string]struct {
+		initialSync       bool
+		modify            []func(c client.Client) error
+		expectedUpdated   []string
+		expectedConflicts []string
+		expectedAddresses string
+	}{
+		"in sync": {
+			expectedAddresses: "[192.168.100.100]",
+		},
+		"AddressPool changed": {
+			initialSync:       true,
+			modify:            []func(c client.Client) error{changeLegacy},
+			expectedUpdated:   []string{"IPAddressPool metallb-system/ap-l2"},
+			expectedAddresses: "[10.0.0.0/24]",
+		},
+		"IPAddressPool changed": {
+			initialSync:       true,
+			modify:            []func(c client.Client) error{changeCurrent},
+			expectedUpdated:   []string{"AddressPool metallb-system/ap-l2"},
+			expectedAddresses: "[10.0.1.0/24]",
+		},
+		"IPAddressPool changed without legacy representation": {
+			initialSync: true,
+			modify: []func(c client.Client) error{func(c client.Client) error {
+				return modifyIPAddressPool(c, "ap-l2", func(spec *metallbv1beta1.IPAddressPoolSpec) {
+					spec.AvoidBuggyIPs = true
+				})
+			}},
+			expectedConflicts: []string{"AddressPool metallb-system/ap-l2: the change of its converted resources " +
+				"cannot be synced back"},
+			expectedAddresses: "[192.168.100.100]",
+		},
+		"both changed": {
+			initialSync: true,
+			modify:      []func(c client.Client) error{changeLegacy, changeCurrent},
+			expectedConflicts: []string{"AddressPool metallb-system/ap-l2: both the AddressPool and its converted " +
+				"resources changed since the last sync"},
+			expectedAddresses: "[10.0.0.0/24]",
+		},
+		"never synced": {
+			modify: []func(c client.Client) error{changeCurrent},
+			expectedConflicts: []string{"AddressPool metallb-system/ap-l2: differs from its converted resources and " +
+				"was never synced"},
+			expectedAddresses: "[192.168.100.100]",
+		},
+	}
+	for desc, tc := range tcs {
+		var scheme = runtime.NewScheme()
+		if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+			t.Fatalf("TestSync(%s): error adding to scheme, err: %q", desc, err)
+		}
+		c := fake.NewClientBuilder().WithScheme(scheme).Build()
+		for _, ap := range validAddressPools0 {
+			if err := c.Create(context.TODO(), ap.DeepCopy()); err != nil {
+				t.Fatalf("TestSync(%s): error building fake client, err: %q", desc, err)
+			}
+		}
+		err := OnlineMigration(context.TODO(), c, scheme, t.TempDir(), false, OnlineMigrationOptions{KeepLegacy: true})
+		if err != nil {
+			t.Fatalf("TestSync(%s): unexpected error during migration, err: %q", desc, err)
+		}
+		if tc.initialSync {
+			result, err := Sync(context.TODO(), c, Options{})
+			if err != nil || len(result.Updated) > 0 || len(result.Conflicts) > 0 {
+				t.Fatalf("TestSync(%s): unexpected result of the initial sync %v, err: %v", desc, result, err)
+			}
+		}
+		for _, modify := range tc.modify {
+			if err := modify(c); err != nil {
+				t.Fatalf("TestSync(%s): cannot modify resources, err: %q", desc, err)
+			}
+		}
+		result, err := Sync(context.TODO(), c, Options{})
+		if err != nil {
+			t.Fatalf("TestSync(%s): unexpected error, err: %q", desc, err)
+		}
+		if fmt.Sprint(result.Updated) != fmt.Sprint(tc.expectedUpdated) {
+			t.Fatalf("TestSync(%s): expected updates %v but got %v", desc, tc.expectedUpdated, result.Updated)
+		}
+		if len(result.Conflicts) != len(tc.expectedConflicts) {
+			t.Fatalf("TestSync(%s): expected conflicts %v but got %v", desc, tc.expectedConflicts, result.Conflicts)
+		}
+		for i, conflict := range result.Conflicts {
+			if !strings.HasPrefix(conflict, tc.expectedConflicts[i]) {
+				t.Fatalf("TestSync(%s): expected conflict %q but got %q", desc, tc.expectedConflicts[i], conflict)
+			}
+		}
+		ap := &metallbv1beta1.AddressPool{}
+		if err := c.Get(context.TODO(), types.NamespacedName{Namespace: "metallb-system", Name: "ap-l2"}, ap); err != nil {
+			t.Fatalf("TestSync(%s): cannot get AddressPool, err: %q", desc, err)
+		}
+		if fmt.Sprint(ap.Spec.Addresses) != tc.expectedAddresses {
+			t.Fatalf("TestSync(%s): expected AddressPool addresses %s but got %v", desc, tc.expectedAddresses,
+				ap.Spec.Addresses)
+		}
+		if len(tc.expectedConflicts) > 0 {
+			continue
+		}
+		// Once synced, both sides are consistent and a further sync changes nothing.
+		result, err = Sync(context.TODO(), c, Options{})
+		if err != nil || len(result.Updated) > 0 || len(result.Conflicts) > 0 {
+			t.Fatalf("TestSync(%s): unexpected result of a repeated sync %v, err: %v", desc, result, err)
+		}
+		if drift, err := DetectDrift(context.TODO(), c, Options{}); err != nil || len(drift) > 0 {
+			t.Fatalf("TestSync(%s): unexpected drift after the sync %v, err: %v", desc, drift, err)
+		}
+	}
+}
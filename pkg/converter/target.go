@@ -0,0 +1,63 @@
+package converter
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+
+	"github.com/andreaskaris/metallb-converter/pkg/schema"
+)
+
+// shapeForTarget checks all objects against the schema bundle of the target release. Objects of kinds that the
+// release does not serve are always an error. Unsupported fields are an error, or are stripped if
+// stripUnsupportedFields is true.
+func (c *CurrentObjects) shapeForTarget(targetBundle *schema.Bundle, stripUnsupportedFields bool) error {
+	var problems []string
+	names := c.Names()
+	for i, obj := range c.objects() {
+		data, err := json.Marshal(obj)
+		if err != nil {
+			return fmt.Errorf("cannot marshal %s, err: %w", names[i], err)
+		}
+		document := map[string]interface{}{}
+		if err := json.Unmarshal(data, &document); err != nil {
+			return fmt.Errorf("cannot unmarshal %s, err: %w", names[i], err)
+		}
+		if !targetBundle.Serves(document) {
+			problems = append(problems, fmt.Sprintf("%s: kind is not served by MetalLB %s", names[i],
+				targetBundle.Version))
+			continue
+		}
+		fields := targetBundle.UnsupportedFields(document, stripUnsupportedFields)
+		if len(fields) == 0 {
+			continue
+		}
+		if !stripUnsupportedFields {
+			for _, field := range fields {
+				problems = append(problems, fmt.Sprintf("%s: %s is not supported by MetalLB %s", names[i], field,
+					targetBundle.Version))
+			}
+			continue
+		}
+		data, err = json.Marshal(document)
+		if err != nil {
+			return fmt.Errorf("cannot marshal %s, err: %w", names[i], err)
+		}
+		v := reflect.ValueOf(obj).Elem()
+		v.Set(reflect.Zero(v.Type()))
+		if err := json.Unmarshal(data, obj); err != nil {
+			return fmt.Errorf("cannot unmarshal %s, err: %w", names[i], err)
+		}
+		if !quiet {
+			log.Printf("stripped %s from %s, MetalLB %s does not support them", strings.Join(fields, ", "),
+				names[i], targetBundle.Version)
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("the converted objects are not supported by MetalLB %s, strip the unsupported fields or "+
+			"target a later release:\n%s", targetBundle.Version, strings.Join(problems, "\n"))
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/andreaskaris/metallb-converter/pkg/schema"
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+)
+
+func TestShapeForTarget(t *testing.T) {
+	tcs := map[string]struct {
+		version             string
+		strip               bool
+		expectedInterfaces  string
+		expectedErrorString string
+	}{
+		"supported": {
+			version:            "v0.13.7",
+			expectedInterfaces: "[eth0]",
+		},
+		"refused": {
+			version: "v0.13.5",
+			expectedErrorString: "L2Advertisement/metallb-system/ap-l2-l2-advertisement: spec.interfaces is not " +
+				"supported by MetalLB v0.13.5",
+		},
+		"stripped": {
+			version:            "v0.13.5",
+			strip:              true,
+			expectedInterfaces: "[]",
+		},
+	}
+	for desc, tc := range tcs {
+		target, err := schema.Load(tc.version)
+		if err != nil {
+			t.Fatalf("TestShapeForTarget(%s): cannot load target version, err: %q", desc, err)
+		}
+		legacyObjects := &LegacyObjects{AddressPoolList: &metallbv1beta1.AddressPoolList{Items: validAddressPools0}}
+		currentObjects, err := legacyObjects.Convert(Options{})
+		if err != nil {
+			t.Fatalf("TestShapeForTarget(%s): unexpected error during conversion, err: %q", desc, err)
+		}
+		currentObjects.L2AdvertisementList.Items[0].Spec.Interfaces = []string{"eth0"}
+		err = currentObjects.shapeForTarget(target, tc.strip)
+		if tc.expectedErrorString != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.expectedErrorString) {
+				t.Fatalf("TestShapeForTarget(%s): expected error %q but got %v", desc, tc.expectedErrorString, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("TestShapeForTarget(%s): unexpected error, err: %q", desc, err)
+		}
+		l2a := currentObjects.L2AdvertisementList.Items[0]
+		if interfaces := fmt.Sprint(l2a.Spec.Interfaces); interfaces != tc.expectedInterfaces {
+			t.Fatalf("TestShapeForTarget(%s): expected interfaces %s but got %s", desc, tc.expectedInterfaces, interfaces)
+		}
+		if fmt.Sprint(l2a.Spec.IPAddressPools) != "[ap-l2]" || l2a.Kind != "L2Advertisement" {
+			t.Fatalf("TestShapeForTarget(%s): unexpected L2Advertisement after shaping %+v", desc, l2a)
+		}
+	}
+}
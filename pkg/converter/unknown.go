@@ -0,0 +1,58 @@
+package converter
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// decodedKinds are the kinds of the source API group that the readers of legacy objects decode.
+var decodedKinds = map[string]bool{
+	"AddressPool": true, "AddressPoolList": true,
+	"BGPPeer": true, "BGPPeerList": true,
+	"BFDProfile": true, "BFDProfileList": true,
+}
+
+// unknownDocument returns a description of document from source if it is neither a legacy MetalLB object that
+// decodeLegacyDocument can decode from sourceAPIGroup nor a v1 List, whose items are checked one by one, and ""
+// otherwise.
+func unknownDocument(document []byte, source, sourceAPIGroup string) string {
+	object := struct {
+		metav1.TypeMeta `json:",inline"`
+		Metadata        struct {
+			Name      string `json:"name"`
+			Namespace string `json:"namespace"`
+		} `json:"metadata"`
+	}{}
+	if err := yaml.Unmarshal(document, &object); err != nil {
+		return fmt.Sprintf("%s: not a YAML or JSON object", source)
+	}
+	if object.Kind == "" {
+		return fmt.Sprintf("%s: document without kind", source)
+	}
+	if object.APIVersion == "v1" && object.Kind == "List" {
+		return ""
+	}
+	group, version, found := strings.Cut(object.APIVersion, "/")
+	_, supported := supportedLegacyGKVVersions[version]
+	if found && group == sourceAPIGroup && supported && decodedKinds[object.Kind] {
+		return ""
+	}
+	name := object.Metadata.Name
+	if object.Metadata.Namespace != "" {
+		name = object.Metadata.Namespace + "/" + name
+	}
+	return fmt.Sprintf("%s: %s %s %s", source, object.APIVersion, object.Kind, name)
+}
+
+// logSkipped logs the summary of the documents that were skipped with Options.IgnoreUnknown.
+func logSkipped(skipped []string) {
+	if quiet || len(skipped) == 0 {
+		return
+	}
+	log.Printf("skipped %d documents that are not legacy MetalLB objects:\n%s", len(skipped),
+		strings.Join(skipped, "\n"))
+}
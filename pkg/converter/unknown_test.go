@@ -0,0 +1,139 @@
+package converter
+
+import (
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// mixedManifests are the files of a manifest repository with legacy AddressPools among other documents.
+var mixedManifests = map[string]string{
+	"addresspool.yaml": `apiVersion: metallb.io/v1beta1
+kind: AddressPool
+metadata:
+  name: ap-l2
+  namespace: metallb-system
+spec:
+  addresses:
+  - 192.168.100.0/24
+  protocol: layer2
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx
+  namespace: default
+---
+`,
+	"list.yaml": `apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: settings
+    namespace: default
+- apiVersion: metallb.io/v1beta1
+  kind: AddressPool
+  metadata:
+    name: ap-bgp
+    namespace: metallb-system
+  spec:
+    addresses:
+    - 192.168.200.0/24
+    protocol: bgp
+`,
+	"ipaddresspool.yaml": `apiVersion: metallb.io/v1beta1
+kind: IPAddressPool
+metadata:
+  name: converted
+  namespace: metallb-system
+`,
+	"README.md":   "# Manifests\n\nThese are the manifests of the cluster.\n",
+	"broken.json": "{\"kind\": ",
+	"values.yaml": "replicas: 3\n",
+}
+
+func TestIgnoreUnknown(t *testing.T) {
+	var scheme = runtime.NewScheme()
+	if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestIgnoreUnknown: error adding to scheme, err: %q", err)
+	}
+	dir := t.TempDir()
+	for name, content := range mixedManifests {
+		if err := os.WriteFile(path.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(path.Join(dir, "charts"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	SetQuiet(true)
+	defer SetQuiet(false)
+
+	if _, err := ReadLegacyObjectsFromDirectory(scheme, dir, Options{}); err == nil {
+		t.Fatalf("TestIgnoreUnknown: expected an error without ignore-unknown")
+	}
+	legacyObjects, err := ReadLegacyObjectsFromDirectory(scheme, dir, Options{IgnoreUnknown: true})
+	if err != nil {
+		t.Fatalf("TestIgnoreUnknown: unexpected error, err: %q", err)
+	}
+	var names []string
+	for _, ap := range legacyObjects.AddressPoolList.Items {
+		names = append(names, ap.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"ap-l2", "ap-bgp"}) {
+		t.Fatalf("TestIgnoreUnknown: expected AddressPools [ap-l2 ap-bgp] but got %v", names)
+	}
+
+	tcs := map[string]struct {
+		source          string
+		expectedSkipped []string
+	}{
+		"other manifest": {
+			source:          "addresspool.yaml",
+			expectedSkipped: []string{"addresspool.yaml: apps/v1 Deployment default/nginx"},
+		},
+		"item of a List": {
+			source:          "list.yaml",
+			expectedSkipped: []string{"list.yaml: v1 ConfigMap default/settings"},
+		},
+		"kind of the target API": {
+			source:          "ipaddresspool.yaml",
+			expectedSkipped: []string{"ipaddresspool.yaml: metallb.io/v1beta1 IPAddressPool metallb-system/converted"},
+		},
+		"not yaml": {
+			source:          "README.md",
+			expectedSkipped: []string{"README.md: not a YAML or JSON object"},
+		},
+		"truncated json": {
+			source:          "broken.json",
+			expectedSkipped: []string{"broken.json: not a YAML or JSON object"},
+		},
+		"without kind": {
+			source:          "values.yaml",
+			expectedSkipped: []string{"values.yaml: document without kind"},
+		},
+	}
+	for desc, tc := range tcs {
+		decoded, err := decodeLegacyContent(scheme, []byte(mixedManifests[tc.source]), tc.source, Options{IgnoreUnknown: true})
+		if err != nil {
+			t.Fatalf("TestIgnoreUnknown(%s): unexpected error, err: %q", desc, err)
+		}
+		if !reflect.DeepEqual(decoded.skipped, tc.expectedSkipped) {
+			t.Fatalf("TestIgnoreUnknown(%s): expected skipped documents %v but got %v", desc, tc.expectedSkipped,
+				decoded.skipped)
+		}
+	}
+
+	// Legacy objects that cannot be decoded still fail.
+	invalid := strings.Replace(mixedManifests["addresspool.yaml"], "protocol: layer2", "protocol: [layer2]", 1)
+	if _, err := decodeLegacyContent(scheme, []byte(invalid), "invalid.yaml", Options{IgnoreUnknown: true}); err == nil {
+		t.Fatalf("TestIgnoreUnknown: expected an error for an invalid AddressPool")
+	}
+}
@@ -0,0 +1,120 @@
+package converter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/andreaskaris/metallb-converter/pkg/schema"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// servedSchemasVersion names the schemas of LoadServedSchemas in messages, in place of a MetalLB release.
+const servedSchemasVersion = "of the cluster"
+
+// LoadServedSchemas reads the CRDs of the MetalLB API group from the cluster and returns the schemas of all served
+// versions, to catch version skew between the compiled-in types and what the cluster actually serves. The CRDs are
+// looked up in the target API group of opts.
+func LoadServedSchemas(ctx context.Context, cl client.Client, opts Options) (*schema.Bundle, error) {
+	crds := &apiextensionsv1.CustomResourceDefinitionList{}
+	if err := cl.List(ctx, crds); err != nil {
+		return nil, fmt.Errorf("cannot list CustomResourceDefinitions, err: %w", err)
+	}
+	targetAPIGroup := opts.targetAPIGroup()
+	bundle, err := schema.FromCRDs(servedSchemasVersion, targetAPIGroup, crds.Items)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the schemas served by the cluster, err: %w", err)
+	}
+	// The converted objects carry metallb.io until they are printed or sent to the API, see Options.withAPIGroup.
+	kinds := map[string]*schema.Schema{}
+	for key, s := range bundle.Kinds {
+		kinds[metallbAPIGroup+strings.TrimPrefix(key, targetAPIGroup)] = s
+	}
+	bundle.Kinds = kinds
+	return bundle, nil
+}
+
+// objects returns all objects as runtime.Objects.
+func (c CurrentObjects) objects() []runtime.Object {
+	var objects []runtime.Object
+	for i := range c.IPAddressPoolList.Items {
+		objects = append(objects, &c.IPAddressPoolList.Items[i])
+	}
+	for i := range c.L2AdvertisementList.Items {
+		objects = append(objects, &c.L2AdvertisementList.Items[i])
+	}
+	for i := range c.BGPAdvertisementList.Items {
+		objects = append(objects, &c.BGPAdvertisementList.Items[i])
+	}
+	for i := range c.CommunityList.Items {
+		objects = append(objects, &c.CommunityList.Items[i])
+	}
+	return objects
+}
+
+// ValidateSchema validates all objects against the CRD schemas of bundle. It returns an error that lists every
+// violation.
+func (c CurrentObjects) ValidateSchema(bundle *schema.Bundle) error {
+	var problems []string
+	names := c.Names()
+	for i, obj := range c.objects() {
+		data, err := json.Marshal(obj)
+		if err != nil {
+			return fmt.Errorf("cannot marshal %s, err: %w", names[i], err)
+		}
+		document := map[string]interface{}{}
+		if err := json.Unmarshal(data, &document); err != nil {
+			return fmt.Errorf("cannot unmarshal %s, err: %w", names[i], err)
+		}
+		for _, problem := range bundle.Validate(document) {
+			problems = append(problems, fmt.Sprintf("%s: %s", names[i], problem))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%w for MetalLB %s:\n%s", ErrSchemaViolation, bundle.Version, strings.Join(problems, "\n"))
+	}
+	return nil
+}
+
+// validateSchema validates the objects against bundle, if it is not nil.
+func (c CurrentObjects) validateSchema(bundle *schema.Bundle) error {
+	if bundle == nil {
+		return nil
+	}
+	return c.ValidateSchema(bundle)
+}
+
+// validateWithDryRun creates all objects with dry-run against dryRunValidator, if it is not nil. It returns an error
+// that lists every rejected object. The namespaces of the objects are created for real, dry-run creates fail in
+// namespaces that do not exist.
+func (c CurrentObjects) validateWithDryRun(ctx context.Context, dryRunValidator client.Client) error {
+	if dryRunValidator == nil {
+		return nil
+	}
+	var problems []string
+	namespaces := map[string]bool{}
+	names := c.Names()
+	for i, obj := range c.objects() {
+		o := obj.DeepCopyObject().(client.Object)
+		if ns := o.GetNamespace(); ns != "" && !namespaces[ns] {
+			namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: ns}}
+			if err := dryRunValidator.Create(ctx, namespace); err != nil && !apierrors.IsAlreadyExists(err) {
+				return fmt.Errorf("cannot create namespace %s for validation, err: %w", ns, err)
+			}
+			namespaces[ns] = true
+		}
+		if err := dryRunValidator.Create(ctx, o, client.DryRunAll); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", names[i], err))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%w by the API server:\n%s", ErrSchemaViolation, strings.Join(problems, "\n"))
+	}
+	return nil
+}
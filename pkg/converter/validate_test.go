@@ -0,0 +1,234 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	metallbschema "github.com/andreaskaris/metallb-converter/pkg/schema"
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestValidateSchema(t *testing.T) {
+	tcs := map[string]struct {
+		version             string
+		modify              func(c *CurrentObjects)
+		expectedErrorString string
+	}{
+		"valid": {
+			version: "v0.13.7",
+			modify:  func(c *CurrentObjects) {},
+		},
+		"out of range": {
+			version: "v0.13.12",
+			modify: func(c *CurrentObjects) {
+				length := int32(33)
+				c.BGPAdvertisementList.Items[0].Spec.AggregationLength = &length
+			},
+			expectedErrorString: "BGPAdvertisement/metallb-system/ap-bgp-bgp-advertisement-0: spec.aggregationLength: " +
+				"33 is greater than the maximum of 32",
+		},
+		"unknown version": {
+			version:             "v0.1.0",
+			expectedErrorString: "no schema bundle",
+		},
+	}
+	for desc, tc := range tcs {
+		bundle, err := metallbschema.Load(tc.version)
+		if err == nil {
+			legacyObjects := &LegacyObjects{AddressPoolList: &metallbv1beta1.AddressPoolList{Items: validAddressPools0}}
+			currentObjects, convertErr := legacyObjects.Convert(Options{})
+			if convertErr != nil {
+				t.Fatalf("TestValidateSchema(%s): unexpected error during conversion, err: %q", desc, convertErr)
+			}
+			tc.modify(currentObjects)
+			err = currentObjects.validateSchema(bundle)
+		}
+		if tc.expectedErrorString == "" && err != nil {
+			t.Fatalf("TestValidateSchema(%s): unexpected error, err: %q", desc, err)
+		}
+		if tc.expectedErrorString != "" && (err == nil || !strings.Contains(err.Error(), tc.expectedErrorString)) {
+			t.Fatalf("TestValidateSchema(%s): expected error %q but got %v", desc, tc.expectedErrorString, err)
+		}
+	}
+}
+
+func TestLoadServedSchemas(t *testing.T) {
+	// The cluster serves IPAddressPools only, without any of their fields.
+	crd := &apiextensionsv1.CustomResourceDefinition{}
+	crd.Name = "ipaddresspools.metallb.io"
+	crd.Spec.Group = metallbAPIGroup
+	crd.Spec.Names.Kind = "IPAddressPool"
+	crd.Spec.Versions = []apiextensionsv1.CustomResourceDefinitionVersion{{
+		Name:   "v1beta1",
+		Served: true,
+		Schema: &apiextensionsv1.CustomResourceValidation{OpenAPIV3Schema: &apiextensionsv1.JSONSchemaProps{
+			Type: "object",
+			Properties: map[string]apiextensionsv1.JSONSchemaProps{
+				"apiVersion": {Type: "string"},
+				"kind":       {Type: "string"},
+				"spec":       {Type: "object"},
+			},
+		}},
+	}}
+	tcs := map[string]struct {
+		objects             []client.Object
+		expectedErrorString string
+		expectedProblems    []string
+	}{
+		"served CRDs": {
+			objects: []client.Object{crd},
+			expectedProblems: []string{
+				"IPAddressPool/metallb-system/ap-l2: spec.addresses: field is not supported by MetalLB of the cluster",
+				"metallb.io/v1beta1 L2Advertisement is not served by MetalLB of the cluster",
+			},
+		},
+		"no CRDs": {
+			expectedErrorString: "cannot read the schemas served by the cluster",
+		},
+	}
+	scheme := runtime.NewScheme()
+	if err := apiextensionsv1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestLoadServedSchemas: cannot build scheme, err: %q", err)
+	}
+	for desc, tc := range tcs {
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(tc.objects...).Build()
+		bundle, err := LoadServedSchemas(context.TODO(), c, Options{})
+		if tc.expectedErrorString != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.expectedErrorString) {
+				t.Fatalf("TestLoadServedSchemas(%s): expected error %q but got %v", desc, tc.expectedErrorString, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("TestLoadServedSchemas(%s): unexpected error, err: %q", desc, err)
+		}
+		legacyObjects := &LegacyObjects{AddressPoolList: &metallbv1beta1.AddressPoolList{Items: validAddressPools0}}
+		currentObjects, err := legacyObjects.Convert(Options{})
+		if err != nil {
+			t.Fatalf("TestLoadServedSchemas(%s): unexpected error during conversion, err: %q", desc, err)
+		}
+		err = currentObjects.validateSchema(bundle)
+		if !errors.Is(err, ErrSchemaViolation) {
+			t.Fatalf("TestLoadServedSchemas(%s): expected a schema violation but got %v", desc, err)
+		}
+		for _, problem := range tc.expectedProblems {
+			if !strings.Contains(err.Error(), problem) {
+				t.Fatalf("TestLoadServedSchemas(%s): expected problem %q but got %q", desc, problem, err)
+			}
+		}
+	}
+}
+
+// rejectingAPIServer rejects the creation of L2Advertisements, as an API server with an incompatible CRD would.
+type rejectingAPIServer struct {
+	client.Client
+}
+
+func (rc *rejectingAPIServer) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	if _, ok := obj.(*metallbv1beta1.L2Advertisement); ok {
+		return apierrors.NewInvalid(schema.GroupKind{Group: "metallb.io", Kind: "L2Advertisement"}, obj.GetName(),
+			nil)
+	}
+	return rc.Client.Create(ctx, obj, opts...)
+}
+
+func TestValidateWithDryRun(t *testing.T) {
+	tcs := map[string]struct {
+		rejecting           bool
+		expectedErrorString string
+	}{
+		"accepted": {},
+		"rejected": {
+			rejecting:           true,
+			expectedErrorString: "L2Advertisement/metallb-system/ap-l2-l2-advertisement: L2Advertisement.metallb.io",
+		},
+	}
+	scheme := runtime.NewScheme()
+	if err := AddToScheme(scheme, Options{}); err != nil {
+		t.Fatalf("TestValidateWithDryRun: cannot build scheme, err: %q", err)
+	}
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestValidateWithDryRun: cannot build scheme, err: %q", err)
+	}
+	for desc, tc := range tcs {
+		var c client.Client = fake.NewClientBuilder().WithScheme(scheme).Build()
+		if tc.rejecting {
+			c = &rejectingAPIServer{Client: c}
+		}
+		legacyObjects := &LegacyObjects{AddressPoolList: &metallbv1beta1.AddressPoolList{Items: validAddressPools0}}
+		currentObjects, err := legacyObjects.Convert(Options{})
+		if err != nil {
+			t.Fatalf("TestValidateWithDryRun(%s): unexpected error during conversion, err: %q", desc, err)
+		}
+		err = currentObjects.validateWithDryRun(context.TODO(), c)
+		if tc.expectedErrorString != "" {
+			if !errors.Is(err, ErrSchemaViolation) || !strings.Contains(err.Error(), tc.expectedErrorString) {
+				t.Fatalf("TestValidateWithDryRun(%s): expected error %q but got %v", desc, tc.expectedErrorString, err)
+			}
+		} else if err != nil {
+			t.Fatalf("TestValidateWithDryRun(%s): unexpected error, err: %q", desc, err)
+		}
+		if err := c.Get(context.TODO(), client.ObjectKey{Name: "metallb-system"}, &corev1.Namespace{}); err != nil {
+			t.Fatalf("TestValidateWithDryRun(%s): expected the namespace to be created, err: %q", desc, err)
+		}
+		pools := &metallbv1beta1.IPAddressPoolList{}
+		if err := c.List(context.TODO(), pools); err != nil || len(pools.Items) != 0 {
+			t.Fatalf("TestValidateWithDryRun(%s): expected no IPAddressPools to be created but got %d, err: %v",
+				desc, len(pools.Items), err)
+		}
+	}
+}
+
+func TestOfflineMigrationValidateOnly(t *testing.T) {
+	var scheme = runtime.NewScheme()
+	if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestOfflineMigrationValidateOnly: error adding to scheme, err: %q", err)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+	tcs := map[string]struct {
+		input               string
+		expectedErrorString string
+	}{
+		"valid": {
+			input: renderedKustomization,
+		},
+		"invalid protocol": {
+			input:               strings.Replace(renderedKustomization, "layer2", "layer3", 1),
+			expectedErrorString: `invalid protocol "layer3"`,
+		},
+	}
+	for desc, tc := range tcs {
+		inDir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(inDir, "pools.yaml"), []byte(tc.input), 0644); err != nil {
+			t.Fatalf("TestOfflineMigrationValidateOnly(%s): cannot write input, err: %q", desc, err)
+		}
+		stdout = bytes.NewBuffer([]byte{})
+		err := OfflineMigration(context.TODO(), c, scheme, inDir, "", OutputFormatYAML,
+			OfflineMigrationOptions{ValidateOnly: true})
+		if tc.expectedErrorString != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.expectedErrorString) {
+				t.Fatalf("TestOfflineMigrationValidateOnly(%s): expected error %q but got %v", desc,
+					tc.expectedErrorString, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("TestOfflineMigrationValidateOnly(%s): unexpected error, err: %q", desc, err)
+		}
+		if output := stdout.(*bytes.Buffer).String(); output != "" {
+			t.Fatalf("TestOfflineMigrationValidateOnly(%s): expected no output but got:\n%s", desc, output)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package converter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+// VerificationReport is the machine-readable result of VerifyMigration. Every discrepancy is listed in the section of
+// the check that found it.
+type VerificationReport struct {
+	Timestamp time.Time           `json:"timestamp"`
+	BackupDir string              `json:"backupDir"`
+	Passed    bool                `json:"passed"`
+	Summary   VerificationSummary `json:"summary"`
+	// LegacyAddressPools are the legacy AddressPools that still exist in the cluster.
+	LegacyAddressPools []string `json:"legacyAddressPools,omitempty"`
+	// Objects are the converted objects of the backup that do not exist or whose spec differs.
+	Objects []string `json:"objects,omitempty"`
+	// Services are the LoadBalancer Services that lost their IP of the legacy AddressPools.
+	Services []string `json:"services,omitempty"`
+}
+
+// VerificationSummary counts what VerifyMigration checked and the discrepancies that it found.
+type VerificationSummary struct {
+	BackedUpAddressPools int `json:"backedUpAddressPools"`
+	ExpectedObjects      int `json:"expectedObjects"`
+	CheckedServices      int `json:"checkedServices"`
+	Discrepancies        int `json:"discrepancies"`
+}
+
+// CheckMigration verifies a completed online migration against the legacy AddressPools of its backupDir: no legacy
+// AddressPool may remain in the cluster, every object that the backup converts into must exist with the converted
+// spec, and every LoadBalancer Service that is served from the legacy AddressPools must still have an IP of them.
+// The backup is converted according to opts, which must match the options of the migration.
+func CheckMigration(ctx context.Context, c client.Client, scheme *runtime.Scheme, backupDir string,
+	opts Options) (*VerificationReport, error) {
+	report := &VerificationReport{Timestamp: time.Now().UTC(), BackupDir: backupDir}
+	backup, err := ReadBackup(scheme, backupDir, opts)
+	if err != nil {
+		return nil, err
+	}
+	report.Summary.BackedUpAddressPools = len(backup.AddressPoolList.Items)
+
+	remaining, err := ReadLegacyObjectsFromAPI(ctx, c, 0, opts)
+	if err != nil {
+		return nil, err
+	}
+	for _, ap := range remaining.AddressPoolList.Items {
+		problem := fmt.Sprintf("AddressPool %s/%s: still exists", ap.Namespace, ap.Name)
+		if isMigrated(ap) {
+			problem += fmt.Sprintf(", it is marked as migrated, run the %s phase to delete it", PhaseFinalize)
+		}
+		report.LegacyAddressPools = append(report.LegacyAddressPools, problem)
+	}
+
+	expected, err := backup.Convert(opts)
+	if err == nil {
+		err = expected.ResolveCommunityAliases(ctx, c, opts.ExpandCommunityAliases)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot convert backup, err: %w", err)
+	}
+	report.Summary.ExpectedObjects = len(expected.objects())
+	report.Objects = expected.discrepancies(ctx, c)
+
+	services, err := loadBalancerServices(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	legacy := legacySimulatedPools(backup)
+	for _, svc := range services {
+		problem, checked := serviceProblem(svc, legacy)
+		if checked {
+			report.Summary.CheckedServices++
+		}
+		if problem != "" {
+			report.Services = append(report.Services, problem)
+		}
+	}
+
+	report.Summary.Discrepancies = len(report.LegacyAddressPools) + len(report.Objects) + len(report.Services)
+	report.Passed = report.Summary.Discrepancies == 0
+	return report, nil
+}
+
+// serviceProblem returns a description of why svc is no longer served from the legacy pools, or "" if it is. checked
+// is false if svc is not served from the legacy pools at all: it has an IP outside of them, or no IP and none of them
+// could assign one.
+func serviceProblem(svc corev1.Service, legacy []simulatedPool) (problem string, checked bool) {
+	ips := assignedIPs(svc)
+	if len(ips) == 0 {
+		eligible := eligiblePools(legacy, svc)
+		if len(eligible) == 0 {
+			return "", false
+		}
+		return fmt.Sprintf("Service %s/%s: has no external IP, it was served from the AddressPools [%s]",
+			svc.Namespace, svc.Name, strings.Join(eligible, ", ")), true
+	}
+	for _, ip := range ips {
+		if keepingPool(legacy, svc, ip) != "" {
+			return "", true
+		}
+	}
+	requested := svc.Annotations[ServiceAddressPoolAnnotation]
+	for _, pool := range legacy {
+		if pool.name == requested {
+			return fmt.Sprintf("Service %s/%s: has no external IP of its requested AddressPool %s", svc.Namespace,
+				svc.Name, requested), true
+		}
+	}
+	return "", false
+}
+
+// Print writes the report to w as YAML, or as JSON if toJSON is set.
+func (r *VerificationReport) Print(w io.Writer, toJSON bool) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err == nil && !toJSON {
+		data, err = yaml.JSONToYAML(data)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot encode verification report, err: %w", err)
+	}
+	if toJSON {
+		data = append(data, '\n')
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// VerifyMigration runs CheckMigration and prints its report to stdout. It returns an error that wraps
+// ErrVerificationFailed if the report lists any discrepancy.
+func VerifyMigration(ctx context.Context, c client.Client, scheme *runtime.Scheme, backupDir string, toJSON bool,
+	opts Options) error {
+	report, err := CheckMigration(ctx, c, scheme, backupDir, opts)
+	if err != nil {
+		return err
+	}
+	if err := report.Print(stdout, toJSON); err != nil {
+		return err
+	}
+	if !report.Passed {
+		return fmt.Errorf("%w: the migration has %d discrepancies", ErrVerificationFailed,
+			report.Summary.Discrepancies)
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestVerifyMigration(t *testing.T) {
+	backup := []metallbv1beta1.AddressPool{
+		restoreTestPool("l2", ProtocolLayer2, "10.0.0.0/24"),
+		restoreTestPool("bgp", ProtocolBGP, "10.0.1.0/24"),
+	}
+	tcs := map[string]struct {
+		remaining          []metallbv1beta1.AddressPool
+		skipObject         string
+		services           []corev1.Service
+		expectedChecked    int
+		expectedProblems   []string
+		unexpectedProblems []string
+	}{
+		"passed": {
+			services: []corev1.Service{
+				loadBalancerService("a", "", "10.0.0.5"),
+				loadBalancerService("b", "bgp", "10.0.1.5"),
+				loadBalancerService("external", "", "172.16.0.1"),
+			},
+			expectedChecked: 2,
+		},
+		"legacy pool remains": {
+			remaining:        []metallbv1beta1.AddressPool{backup[1]},
+			expectedProblems: []string{"AddressPool metallb-system/bgp: still exists"},
+		},
+		"converted object is missing": {
+			skipObject:       "l2",
+			expectedProblems: []string{"IPAddressPool metallb-system/l2: "},
+		},
+		"services lost their ips": {
+			services: []corev1.Service{
+				loadBalancerService("pending", ""),
+				loadBalancerService("moved", "bgp", "10.0.0.6"),
+				loadBalancerService("external", "other", "172.16.0.1"),
+			},
+			expectedChecked: 2,
+			expectedProblems: []string{
+				"Service default/pending: has no external IP, it was served from the AddressPools [bgp, l2]",
+				"Service default/moved: has no external IP of its requested AddressPool bgp",
+			},
+			unexpectedProblems: []string{"default/external"},
+		},
+	}
+	scheme := runtime.NewScheme()
+	if err := AddToScheme(scheme, Options{}); err != nil {
+		t.Fatalf("TestVerifyMigration: error adding to scheme, err: %q", err)
+	}
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestVerifyMigration: error adding to scheme, err: %q", err)
+	}
+	for desc, tc := range tcs {
+		dir := t.TempDir()
+		stdout = bytes.NewBuffer([]byte{})
+		legacyObjects := LegacyObjects{AddressPoolList: &metallbv1beta1.AddressPoolList{
+			Items: append([]metallbv1beta1.AddressPool{}, backup...),
+		}}
+		if err := legacyObjects.Print(dir, false, Options{}); err != nil {
+			t.Fatalf("TestVerifyMigration(%s): cannot write backup, err: %q", desc, err)
+		}
+		currentObjects, err := legacyObjects.Convert(Options{})
+		if err != nil {
+			t.Fatalf("TestVerifyMigration(%s): unexpected error during conversion, err: %q", desc, err)
+		}
+		c := fake.NewClientBuilder().WithScheme(scheme).Build()
+		var objects []client.Object
+		for _, obj := range currentObjects.objects() {
+			objects = append(objects, obj.(client.Object))
+		}
+		for _, ap := range tc.remaining {
+			objects = append(objects, ap.DeepCopy())
+		}
+		for _, svc := range tc.services {
+			objects = append(objects, svc.DeepCopy())
+		}
+		for _, obj := range objects {
+			if obj.GetName() == tc.skipObject {
+				continue
+			}
+			obj.SetResourceVersion("")
+			if err := c.Create(context.TODO(), obj); err != nil {
+				t.Fatalf("TestVerifyMigration(%s): cannot create %s, err: %q", desc, obj.GetName(), err)
+			}
+		}
+
+		report, err := CheckMigration(context.TODO(), c, scheme, dir, Options{})
+		if err != nil {
+			t.Fatalf("TestVerifyMigration(%s): unexpected error, err: %q", desc, err)
+		}
+		if report.Summary.BackedUpAddressPools != 2 || report.Summary.CheckedServices != tc.expectedChecked {
+			t.Fatalf("TestVerifyMigration(%s): unexpected summary %+v", desc, report.Summary)
+		}
+		problems := append(append(append([]string{}, report.LegacyAddressPools...), report.Objects...),
+			report.Services...)
+		if report.Passed != (len(tc.expectedProblems) == 0) || len(problems) != len(tc.expectedProblems) {
+			t.Fatalf("TestVerifyMigration(%s): expected problems %q but got %q", desc, tc.expectedProblems, problems)
+		}
+		all := strings.Join(problems, "\n")
+		for _, expected := range tc.expectedProblems {
+			if !strings.Contains(all, expected) {
+				t.Fatalf("TestVerifyMigration(%s): expected problem %q but got %q", desc, expected, problems)
+			}
+		}
+		for _, unexpected := range tc.unexpectedProblems {
+			if strings.Contains(all, unexpected) {
+				t.Fatalf("TestVerifyMigration(%s): unexpected problem %q in %q", desc, unexpected, problems)
+			}
+		}
+
+		err = VerifyMigration(context.TODO(), c, scheme, dir, true, Options{})
+		if report.Passed != (err == nil) || err != nil && !errors.Is(err, ErrVerificationFailed) {
+			t.Fatalf("TestVerifyMigration(%s): expected passed=%t but got %v", desc, report.Passed, err)
+		}
+		if !strings.Contains(stdout.(*bytes.Buffer).String(), `"backupDir": "`+dir+`"`) {
+			t.Fatalf("TestVerifyMigration(%s): expected a JSON report but got:\n%s", desc, stdout)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package converter
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// watchDebounce is the time that WatchInput waits for further changes before it converts again, so that editors and
+// tools that write several files at once trigger a single conversion.
+const watchDebounce = 200 * time.Millisecond
+
+// WatchInput runs OfflineMigration of the input directory inDir, and runs it again whenever a file in inDir changes,
+// until ctx is done. Changes of files that the IgnoreFileName of inDir excludes are disregarded. Conversion errors are
+// logged and do not stop the watch, so that manifests can be fixed in place.
+func WatchInput(ctx context.Context, c client.Client, scheme *runtime.Scheme, inDir, outDir, outputFormat string,
+	opts OfflineMigrationOptions) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("cannot watch %s, err: %w", inDir, err)
+	}
+	defer watcher.Close()
+	if err := watcher.Add(inDir); err != nil {
+		return fmt.Errorf("cannot watch %s, err: %w", inDir, err)
+	}
+	convert := func() {
+		if err := OfflineMigration(ctx, c, scheme, inDir, outDir, outputFormat, opts); err != nil {
+			log.Printf("cannot convert %s, err: %q", inDir, err)
+		} else if !quiet {
+			log.Printf("converted %s", inDir)
+		}
+	}
+	convert()
+	if !quiet {
+		log.Printf("watching %s for changes ...", inDir)
+	}
+	debounce := time.NewTimer(watchDebounce)
+	debounce.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return fmt.Errorf("stopped watching %s", inDir)
+			}
+			if event.Op == fsnotify.Chmod || watchIgnores(inDir, event.Name) {
+				continue
+			}
+			debounce.Reset(watchDebounce)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return fmt.Errorf("stopped watching %s", inDir)
+			}
+			return fmt.Errorf("cannot watch %s, err: %w", inDir, err)
+		case <-debounce.C:
+			convert()
+		}
+	}
+}
+
+// watchIgnores returns true if the changed file name in inDir is excluded by the IgnoreFileName of inDir. Changes of
+// the ignore file itself are not ignored, as they change the files that are converted.
+func watchIgnores(inDir, name string) bool {
+	rel, err := filepath.Rel(inDir, name)
+	if err != nil || rel == IgnoreFileName {
+		return false
+	}
+	ignore, err := loadIgnoreFile(inDir)
+	if err != nil {
+		return false
+	}
+	return ignore.ignores(rel, false)
+}
@@ -0,0 +1,63 @@
+package converter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestWatchInput(t *testing.T) {
+	var scheme = runtime.NewScheme()
+	if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestWatchInput: error adding to scheme, err: %q", err)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+	inDir, outDir := t.TempDir(), t.TempDir()
+	if err := os.WriteFile(filepath.Join(inDir, "pools.yaml"), []byte(renderedKustomization), 0644); err != nil {
+		t.Fatalf("TestWatchInput: cannot write input, err: %q", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- WatchInput(ctx, c, scheme, inDir, outDir, OutputFormatYAML, OfflineMigrationOptions{})
+	}()
+
+	// The directory is watched before the initial conversion, so the change after it must trigger another one.
+	waitForOutput(t, outDir, "overlay-pool")
+	changed := strings.ReplaceAll(renderedKustomization, "overlay-pool", "changed-pool")
+	if err := os.WriteFile(filepath.Join(inDir, "pools.yaml"), []byte(changed), 0644); err != nil {
+		t.Fatalf("TestWatchInput: cannot write input, err: %q", err)
+	}
+	waitForOutput(t, outDir, "changed-pool")
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("TestWatchInput: unexpected error, err: %q", err)
+	}
+
+	if err := WatchInput(context.Background(), c, scheme, filepath.Join(inDir, "missing"), outDir,
+		OutputFormatYAML, OfflineMigrationOptions{}); err == nil {
+		t.Fatalf("TestWatchInput: expected an error for a missing input directory")
+	}
+}
+
+// waitForOutput waits until the IPAddressPools in outDir contain expected.
+func waitForOutput(t *testing.T, outDir, expected string) {
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		content, _ := os.ReadFile(filepath.Join(outDir, "IPAddressPool.yaml"))
+		if strings.Contains(string(content), expected) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("TestWatchInput: %q was not converted, output:\n%s", expected, content)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
@@ -0,0 +1,337 @@
+// Package envtest runs a local kube-apiserver with the MetalLB CRDs installed, so that converted resources can be
+// validated at the API level, including defaulting and the full CRD schemas, without a real cluster. Like the envtest
+// package of controller-runtime, it runs the etcd and kube-apiserver binaries of KUBEBUILDER_ASSETS, e.g. as installed
+// by setup-envtest.
+package envtest
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// AssetsEnv is the environment variable that Environment.AssetsDir defaults to.
+	AssetsEnv = "KUBEBUILDER_ASSETS"
+	// DefaultStartTimeout is how long Start waits for etcd, the kube-apiserver and the CRDs by default.
+	DefaultStartTimeout = time.Minute
+)
+
+// Environment is a local etcd and kube-apiserver with CRDs installed.
+type Environment struct {
+	// AssetsDir holds the etcd and kube-apiserver binaries. Defaults to $KUBEBUILDER_ASSETS.
+	AssetsDir string
+	// CRDDirs hold the YAML or JSON manifests of the CRDs to install, e.g. config/crd/bases of MetalLB.
+	CRDDirs []string
+	// StartTimeout defaults to DefaultStartTimeout.
+	StartTimeout time.Duration
+
+	dir       string
+	processes []*exec.Cmd
+}
+
+// Start starts etcd and the kube-apiserver, installs the CRDs and returns a client of the API server with scheme.
+// Stop must be called when the environment is no longer needed, also if Start fails.
+func (e *Environment) Start(scheme *runtime.Scheme) (client.Client, error) {
+	assetsDir := e.AssetsDir
+	if assetsDir == "" {
+		assetsDir = os.Getenv(AssetsEnv)
+	}
+	if assetsDir == "" {
+		return nil, fmt.Errorf("no directory with the etcd and kube-apiserver binaries, set %s", AssetsEnv)
+	}
+	timeout := e.StartTimeout
+	if timeout == 0 {
+		timeout = DefaultStartTimeout
+	}
+	crds, err := ReadCRDs(e.CRDDirs)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	e.dir, err = os.MkdirTemp("", "metallb-converter-envtest-")
+	if err != nil {
+		return nil, fmt.Errorf("cannot create envtest directory, err: %w", err)
+	}
+	ports, err := freePorts(3)
+	if err != nil {
+		return nil, err
+	}
+	etcdURL := fmt.Sprintf("http://127.0.0.1:%d", ports[0])
+	err = e.run(filepath.Join(assetsDir, "etcd"), "etcd",
+		"--data-dir="+filepath.Join(e.dir, "etcd"),
+		"--listen-client-urls="+etcdURL,
+		"--advertise-client-urls="+etcdURL,
+		fmt.Sprintf("--listen-peer-urls=http://127.0.0.1:%d", ports[1]),
+		"--unsafe-no-fsync=true",
+	)
+	if err == nil {
+		err = e.waitHealthy(ctx, "etcd", etcdURL+"/health")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	keyFile := filepath.Join(e.dir, "sa.key")
+	if err := writeServiceAccountKey(keyFile); err != nil {
+		return nil, err
+	}
+	apiServerURL := fmt.Sprintf("https://127.0.0.1:%d", ports[2])
+	err = e.run(filepath.Join(assetsDir, "kube-apiserver"), "kube-apiserver",
+		"--etcd-servers="+etcdURL,
+		"--cert-dir="+filepath.Join(e.dir, "certs"),
+		"--bind-address=127.0.0.1",
+		"--advertise-address=127.0.0.1",
+		"--secure-port="+strconv.Itoa(ports[2]),
+		"--service-cluster-ip-range=10.0.0.0/24",
+		"--service-account-issuer="+apiServerURL,
+		"--service-account-key-file="+keyFile,
+		"--service-account-signing-key-file="+keyFile,
+		"--authorization-mode=AlwaysAllow",
+		"--anonymous-auth=true",
+		"--disable-admission-plugins=ServiceAccount",
+	)
+	if err == nil {
+		err = e.waitHealthy(ctx, "kube-apiserver", apiServerURL+"/readyz")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	config := &rest.Config{Host: apiServerURL, TLSClientConfig: rest.TLSClientConfig{Insecure: true}}
+	if err := installCRDs(ctx, config, crds); err != nil {
+		return nil, err
+	}
+	// The client discovers the API when it is created, so it is only created once the CRDs are served.
+	for {
+		c, err := client.New(config, client.Options{Scheme: scheme})
+		if err == nil {
+			err = servesCRDs(c, crds)
+		}
+		if err == nil {
+			return c, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("the CRDs are not served after %s, err: %w", timeout, err)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// Stop stops the kube-apiserver and etcd and removes their data.
+func (e *Environment) Stop() error {
+	for i := len(e.processes) - 1; i >= 0; i-- {
+		p := e.processes[i]
+		if err := p.Process.Kill(); err != nil {
+			return fmt.Errorf("cannot stop %s, err: %w", p.Path, err)
+		}
+		_ = p.Wait()
+	}
+	e.processes = nil
+	if e.dir == "" {
+		return nil
+	}
+	if err := os.RemoveAll(e.dir); err != nil {
+		return fmt.Errorf("cannot remove envtest directory, err: %w", err)
+	}
+	e.dir = ""
+	return nil
+}
+
+// ReadCRDs reads the CustomResourceDefinitions of the YAML and JSON files in dirs. Other kinds of resources are
+// skipped, and it is an error if the files hold no CRDs at all.
+func ReadCRDs(dirs []string) ([]apiextensionsv1.CustomResourceDefinition, error) {
+	var crds []apiextensionsv1.CustomResourceDefinition
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read CRD directory, err: %w", err)
+		}
+		for _, entry := range entries {
+			switch filepath.Ext(entry.Name()) {
+			case ".yaml", ".yml", ".json":
+			default:
+				continue
+			}
+			if entry.IsDir() {
+				continue
+			}
+			content, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+			if err != nil {
+				return nil, fmt.Errorf("cannot read CRD file, err: %w", err)
+			}
+			decoder := utilyaml.NewYAMLOrJSONDecoder(bytes.NewReader(content), 4096)
+			for {
+				crd := apiextensionsv1.CustomResourceDefinition{}
+				err := decoder.Decode(&crd)
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					return nil, fmt.Errorf("invalid CRD file %s, err: %w", entry.Name(), err)
+				}
+				if crd.Kind == "CustomResourceDefinition" {
+					crds = append(crds, crd)
+				}
+			}
+		}
+	}
+	if len(crds) == 0 {
+		return nil, fmt.Errorf("no CustomResourceDefinitions found in %v", dirs)
+	}
+	return crds, nil
+}
+
+// run starts the binary with args and logs its output to a file in the environment's directory.
+func (e *Environment) run(binary, name string, args ...string) error {
+	out, err := os.Create(filepath.Join(e.dir, name+".log"))
+	if err != nil {
+		return fmt.Errorf("cannot create log of %s, err: %w", name, err)
+	}
+	cmd := exec.Command(binary, args...)
+	cmd.Stdout, cmd.Stderr = out, out
+	if err := cmd.Start(); err != nil {
+		out.Close()
+		return fmt.Errorf("cannot start %s, err: %w", name, err)
+	}
+	e.processes = append(e.processes, cmd)
+	return nil
+}
+
+// waitHealthy polls url until it returns 200 OK. The last lines of the log of name are part of the error if it does
+// not become healthy.
+func (e *Environment) waitHealthy(ctx context.Context, name, url string) error {
+	httpClient := &http.Client{
+		Timeout:   time.Second,
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+	}
+	for {
+		resp, err := httpClient.Get(url)
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				return nil
+			}
+		}
+		select {
+		case <-ctx.Done():
+			output, _ := os.ReadFile(filepath.Join(e.dir, name+".log"))
+			if len(output) > 2048 {
+				output = output[len(output)-2048:]
+			}
+			return fmt.Errorf("%s did not become healthy, output:\n%s", name, output)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// installCRDs creates crds and waits until all of them are established.
+func installCRDs(ctx context.Context, config *rest.Config, crds []apiextensionsv1.CustomResourceDefinition) error {
+	scheme := runtime.NewScheme()
+	if err := apiextensionsv1.AddToScheme(scheme); err != nil {
+		return err
+	}
+	c, err := client.New(config, client.Options{Scheme: scheme})
+	if err != nil {
+		return fmt.Errorf("cannot connect to kube-apiserver, err: %w", err)
+	}
+	for i := range crds {
+		crd := crds[i].DeepCopy()
+		crd.ResourceVersion = ""
+		if err := c.Create(ctx, crd); err != nil {
+			return fmt.Errorf("cannot install CRD %s, err: %w", crd.Name, err)
+		}
+	}
+	for _, crd := range crds {
+		for {
+			installed := &apiextensionsv1.CustomResourceDefinition{}
+			err := c.Get(ctx, client.ObjectKey{Name: crd.Name}, installed)
+			if err == nil && established(installed) {
+				break
+			}
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("CRD %s is not established, err: %v", crd.Name, err)
+			case <-time.After(100 * time.Millisecond):
+			}
+		}
+	}
+	return nil
+}
+
+// established returns true if the Established condition of crd is true.
+func established(crd *apiextensionsv1.CustomResourceDefinition) bool {
+	for _, condition := range crd.Status.Conditions {
+		if condition.Type == apiextensionsv1.Established {
+			return condition.Status == apiextensionsv1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// servesCRDs returns an error if the REST mapper of c does not know all served versions of crds yet.
+func servesCRDs(c client.Client, crds []apiextensionsv1.CustomResourceDefinition) error {
+	for _, crd := range crds {
+		for _, version := range crd.Spec.Versions {
+			if !version.Served {
+				continue
+			}
+			gk := schema.GroupKind{Group: crd.Spec.Group, Kind: crd.Spec.Names.Kind}
+			if _, err := c.RESTMapper().RESTMapping(gk, version.Name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// freePorts returns n distinct free TCP ports on the loopback interface.
+func freePorts(n int) ([]int, error) {
+	var ports []int
+	for i := 0; i < n; i++ {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return nil, fmt.Errorf("cannot find a free port, err: %w", err)
+		}
+		defer l.Close()
+		ports = append(ports, l.Addr().(*net.TCPAddr).Port)
+	}
+	return ports, nil
+}
+
+// writeServiceAccountKey writes a new RSA key to path, which the kube-apiserver requires to sign service account
+// tokens.
+func writeServiceAccountKey(path string) error {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return fmt.Errorf("cannot generate service account key, err: %w", err)
+	}
+	block := &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0600); err != nil {
+		return fmt.Errorf("cannot write service account key, err: %w", err)
+	}
+	return nil
+}
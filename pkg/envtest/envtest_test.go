@@ -0,0 +1,110 @@
+package envtest
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestReadCRDs(t *testing.T) {
+	emptyDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(emptyDir, "README.md"), []byte("kind: CustomResourceDefinition"),
+		0644); err != nil {
+		t.Fatalf("TestReadCRDs: cannot prepare directory, err: %q", err)
+	}
+	tcs := map[string]struct {
+		dirs                []string
+		expectedNames       []string
+		expectedErrorString string
+	}{
+		"crds": {
+			dirs:          []string{"testdata"},
+			expectedNames: []string{"ipaddresspools.metallb.io"},
+		},
+		"no crds": {
+			dirs:                []string{emptyDir},
+			expectedErrorString: "no CustomResourceDefinitions found",
+		},
+		"missing directory": {
+			dirs:                []string{filepath.Join(emptyDir, "missing")},
+			expectedErrorString: "cannot read CRD directory",
+		},
+	}
+	for desc, tc := range tcs {
+		crds, err := ReadCRDs(tc.dirs)
+		if tc.expectedErrorString != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.expectedErrorString) {
+				t.Fatalf("TestReadCRDs(%s): expected error %q but got %v", desc, tc.expectedErrorString, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("TestReadCRDs(%s): unexpected error, err: %q", desc, err)
+		}
+		var names []string
+		for _, crd := range crds {
+			names = append(names, crd.Name)
+		}
+		if strings.Join(names, ",") != strings.Join(tc.expectedNames, ",") {
+			t.Fatalf("TestReadCRDs(%s): expected CRDs %v but got %v", desc, tc.expectedNames, names)
+		}
+	}
+}
+
+func TestStartWithoutAssets(t *testing.T) {
+	t.Setenv(AssetsEnv, "")
+	e := &Environment{CRDDirs: []string{"testdata"}}
+	_, err := e.Start(runtime.NewScheme())
+	if err == nil || !strings.Contains(err.Error(), "set "+AssetsEnv) {
+		t.Fatalf("TestStartWithoutAssets: expected an error about %s but got %v", AssetsEnv, err)
+	}
+	if err := e.Stop(); err != nil {
+		t.Fatalf("TestStartWithoutAssets: unexpected error, err: %q", err)
+	}
+}
+
+// TestStart runs etcd and kube-apiserver, it is skipped unless KUBEBUILDER_ASSETS points to their binaries.
+func TestStart(t *testing.T) {
+	if os.Getenv(AssetsEnv) == "" {
+		t.Skipf("TestStart: %s is not set", AssetsEnv)
+	}
+	scheme := runtime.NewScheme()
+	if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestStart: error adding to scheme, err: %q", err)
+	}
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestStart: error adding to scheme, err: %q", err)
+	}
+	e := &Environment{CRDDirs: []string{"testdata"}}
+	defer func() {
+		if err := e.Stop(); err != nil {
+			t.Fatalf("TestStart: cannot stop environment, err: %q", err)
+		}
+	}()
+	c, err := e.Start(scheme)
+	if err != nil {
+		t.Fatalf("TestStart: unexpected error, err: %q", err)
+	}
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "metallb-system"}}
+	if err := c.Create(context.TODO(), namespace); err != nil {
+		t.Fatalf("TestStart: cannot create namespace, err: %q", err)
+	}
+	iap := &metallbv1beta1.IPAddressPool{
+		ObjectMeta: metav1.ObjectMeta{Name: "pool", Namespace: "metallb-system"},
+		Spec:       metallbv1beta1.IPAddressPoolSpec{Addresses: []string{"10.0.0.0/24"}},
+	}
+	if err := c.Create(context.TODO(), iap, client.DryRunAll); err != nil {
+		t.Fatalf("TestStart: unexpected error on dry-run create, err: %q", err)
+	}
+	if iap.Spec.AutoAssign == nil || !*iap.Spec.AutoAssign {
+		t.Fatalf("TestStart: expected the API server to default autoAssign, got %v", iap.Spec.AutoAssign)
+	}
+}
@@ -0,0 +1,94 @@
+// Package features implements the feature gates of experimental behaviors. Experimental behaviors ship disabled by
+// default and are enabled per run, e.g. with -feature-gates MergeAdvertisements=true,WebhookBypass=true.
+package features
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Feature is the name of a feature gate.
+type Feature string
+
+const (
+	// MergeAdvertisements makes conversions merge the L2Advertisements and the BGPAdvertisements of a namespace that
+	// only differ in their IPAddressPools into one advertisement that references all of their pools.
+	MergeAdvertisements Feature = "MergeAdvertisements"
+	// WebhookBypass makes create-first online migrations fall back to deleting the AddressPool first if
+	// an admission webhook rejects its converted resources while it exists, e.g. because of overlapping addresses.
+	WebhookBypass Feature = "WebhookBypass"
+)
+
+// Spec describes a feature gate.
+type Spec struct {
+	Default     bool
+	Stage       string
+	Description string
+}
+
+var specs = map[Feature]Spec{
+	MergeAdvertisements: {
+		Stage:       "ALPHA",
+		Description: "merge advertisements that only differ in their IPAddressPools",
+	},
+	WebhookBypass: {
+		Stage:       "ALPHA",
+		Description: "with create-first, delete the AddressPool first if a webhook rejects its converted resources",
+	},
+}
+
+var enabled = defaults()
+
+// defaults returns the default state of all feature gates.
+func defaults() map[Feature]bool {
+	gates := map[Feature]bool{}
+	for feature, spec := range specs {
+		gates[feature] = spec.Default
+	}
+	return gates
+}
+
+// Known returns a description of every feature gate, in alphabetical order, for usage messages.
+func Known() []string {
+	var known []string
+	for feature, spec := range specs {
+		known = append(known, fmt.Sprintf("%s=true|false (%s - default=%t): %s", feature, spec.Stage, spec.Default,
+			spec.Description))
+	}
+	sort.Strings(known)
+	return known
+}
+
+// Set resets all feature gates to their defaults and applies value, a comma separated list of <feature>=<bool>
+// pairs. Unknown features and invalid values are an error.
+func Set(value string) error {
+	gates := defaults()
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, rawValue, found := strings.Cut(pair, "=")
+		if !found {
+			return fmt.Errorf("invalid feature gate %q, must be <feature>=<bool>", pair)
+		}
+		feature := Feature(strings.TrimSpace(name))
+		if _, ok := specs[feature]; !ok {
+			return fmt.Errorf("unknown feature gate %q", feature)
+		}
+		on, err := strconv.ParseBool(strings.TrimSpace(rawValue))
+		if err != nil {
+			return fmt.Errorf("invalid value %q of feature gate %s, err: %w", rawValue, feature, err)
+		}
+		gates[feature] = on
+	}
+	enabled = gates
+	return nil
+}
+
+// Enabled returns true if feature is enabled.
+func Enabled(feature Feature) bool {
+	return enabled[feature]
+}
@@ -0,0 +1,77 @@
+package features
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSet(t *testing.T) {
+	tcs := map[string]struct {
+		value               string
+		expectedEnabled     []Feature
+		expectedErrorString string
+	}{
+		"defaults": {},
+		"enabled": {
+			value:           "MergeAdvertisements=true, WebhookBypass=true",
+			expectedEnabled: []Feature{MergeAdvertisements, WebhookBypass},
+		},
+		"disabled again": {
+			value:           "MergeAdvertisements=true,MergeAdvertisements=false,WebhookBypass=1",
+			expectedEnabled: []Feature{WebhookBypass},
+		},
+		"unknown feature": {
+			value:               "Teleport=true",
+			expectedErrorString: `unknown feature gate "Teleport"`,
+		},
+		"missing value": {
+			value:               "MergeAdvertisements",
+			expectedErrorString: "must be <feature>=<bool>",
+		},
+		"invalid value": {
+			value:               "MergeAdvertisements=maybe",
+			expectedErrorString: `invalid value "maybe" of feature gate MergeAdvertisements`,
+		},
+	}
+	for desc, tc := range tcs {
+		if err := Set("MergeAdvertisements=true"); err != nil {
+			t.Fatalf("TestSet(%s): cannot prepare the gates, err: %q", desc, err)
+		}
+		err := Set(tc.value)
+		if tc.expectedErrorString != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.expectedErrorString) {
+				t.Fatalf("TestSet(%s): expected error %q but got %v", desc, tc.expectedErrorString, err)
+			}
+			if !Enabled(MergeAdvertisements) {
+				t.Fatalf("TestSet(%s): expected the gates to be unchanged after an error", desc)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("TestSet(%s): unexpected error, err: %q", desc, err)
+		}
+		expected := map[Feature]bool{}
+		for _, feature := range tc.expectedEnabled {
+			expected[feature] = true
+		}
+		for feature := range specs {
+			if Enabled(feature) != expected[feature] {
+				t.Fatalf("TestSet(%s): expected %s to be enabled=%t", desc, feature, expected[feature])
+			}
+		}
+	}
+	if err := Set(""); err != nil {
+		t.Fatalf("TestSet: cannot reset the gates, err: %q", err)
+	}
+}
+
+func TestKnown(t *testing.T) {
+	known := Known()
+	if len(known) != len(specs) {
+		t.Fatalf("TestKnown: expected %d feature gates but got %d", len(specs), len(known))
+	}
+	expected := "MergeAdvertisements=true|false (ALPHA - default=false): "
+	if !strings.HasPrefix(known[0], expected) {
+		t.Fatalf("TestKnown: expected the first gate to start with %q but got %q", expected, known[0])
+	}
+}
@@ -0,0 +1,287 @@
+// Package metrics records the metrics of a single converter run and pushes them to a Prometheus Pushgateway. One-shot
+// runs end before any scraper could collect them, so they are pushed once the run is done. Long-lived runs can serve
+// them to Prometheus instead.
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+const (
+	// DefaultJob is the job label that runs are pushed with by default.
+	DefaultJob = "metallb-converter"
+	// ModeLabel is the grouping label of the mode of a run, e.g. online-migration.
+	ModeLabel = "mode"
+
+	operationCreate = "create"
+	operationDelete = "delete"
+
+	resultSuccess = "success"
+	resultFailure = "failure"
+)
+
+// ParseGrouping parses a list of name=value pairs into grouping labels. Names must be valid Prometheus label names.
+func ParseGrouping(pairs []string) (map[string]string, error) {
+	grouping := map[string]string{}
+	for _, pair := range pairs {
+		name, value, found := strings.Cut(pair, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid grouping label %q, must be name=value", pair)
+		}
+		if !model.LabelName(name).IsValid() || strings.HasPrefix(name, "__") {
+			return nil, fmt.Errorf("invalid grouping label name %q", name)
+		}
+		if name == ModeLabel || name == "job" {
+			return nil, fmt.Errorf("grouping label %q is reserved", name)
+		}
+		grouping[name] = value
+	}
+	return grouping, nil
+}
+
+// Run holds the metrics of a single converter run.
+type Run struct {
+	mode  string
+	start time.Time
+
+	registry       *prometheus.Registry
+	addressPools   prometheus.Counter
+	converted      *prometheus.CounterVec
+	mutations      *prometheus.CounterVec
+	errors         *prometheus.CounterVec
+	poolDurations  *prometheus.HistogramVec
+	complete       prometheus.Gauge
+	duration       prometheus.Gauge
+	success        prometheus.Gauge
+	exitCode       prometheus.Gauge
+	lastCompletion prometheus.Gauge
+
+	once sync.Once
+}
+
+// NewRun returns the metrics of a run in mode that starts now.
+func NewRun(mode string) *Run {
+	r := &Run{
+		mode:     mode,
+		start:    time.Now(),
+		registry: prometheus.NewRegistry(),
+		addressPools: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "metallb_converter_address_pools_converted_total",
+			Help: "Number of legacy AddressPools that were converted.",
+		}),
+		converted: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "metallb_converter_objects_converted_total",
+			Help: "Number of resources that legacy AddressPools were converted to, by kind.",
+		}, []string{"kind"}),
+		mutations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "metallb_converter_api_mutations_total",
+			Help: "Number of successful API requests that created or deleted resources, by operation and kind.",
+		}, []string{"operation", "kind"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "metallb_converter_errors_total",
+			Help: "Number of failed runs and failed migrations of legacy AddressPools, by error code.",
+		}, []string{"code"}),
+		poolDurations: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "metallb_converter_address_pool_migration_duration_seconds",
+			Help:    "Duration of the online migration of a legacy AddressPool, by result.",
+			Buckets: prometheus.ExponentialBuckets(0.5, 2, 12),
+		}, []string{"result"}),
+		complete: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "metallb_converter_migration_complete",
+			Help: "1 if the online migration of all legacy AddressPools completed, 0 otherwise.",
+		}),
+		duration: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "metallb_converter_run_duration_seconds",
+			Help: "Duration of the run.",
+		}),
+		success: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "metallb_converter_run_success",
+			Help: "1 if the run completed without error, 0 otherwise.",
+		}),
+		exitCode: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "metallb_converter_run_exit_code",
+			Help: "Exit code of the run.",
+		}),
+		lastCompletion: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "metallb_converter_run_last_completion_timestamp_seconds",
+			Help: "Unix time at which the run ended.",
+		}),
+	}
+	r.registry.MustRegister(r.addressPools, r.converted, r.mutations, r.errors, r.poolDurations, r.complete,
+		r.duration, r.success, r.exitCode, r.lastCompletion)
+	return r
+}
+
+// Registry returns the registry of the metrics of the run.
+func (r *Run) Registry() *prometheus.Registry {
+	return r.registry
+}
+
+// Handler returns the HTTP handler that serves the metrics of the run to Prometheus, for runs that last long enough
+// to be scraped.
+func (r *Run) Handler() http.Handler {
+	return promhttp.HandlerFor(r.registry, promhttp.HandlerOpts{})
+}
+
+// ObserveConversion counts a conversion of addressPools legacy AddressPools into objects, which are counted by kind.
+func (r *Run) ObserveConversion(addressPools int, objects map[string]int) {
+	r.addressPools.Add(float64(addressPools))
+	for kind, count := range objects {
+		r.converted.WithLabelValues(kind).Add(float64(count))
+	}
+}
+
+// ObserveMigration records the online migration of a legacy AddressPool that took duration. errorCode is the error
+// code of the failure of the migration, or empty if it succeeded. Failures are counted as errors.
+func (r *Run) ObserveMigration(duration time.Duration, errorCode string) {
+	result := resultSuccess
+	if errorCode != "" {
+		result = resultFailure
+		r.errors.WithLabelValues(errorCode).Inc()
+	}
+	r.poolDurations.WithLabelValues(result).Observe(duration.Seconds())
+}
+
+// ObserveError counts a failure of the run with errorCode.
+func (r *Run) ObserveError(errorCode string) {
+	r.errors.WithLabelValues(errorCode).Inc()
+}
+
+// MigrationComplete records that the online migration of all legacy AddressPools completed.
+func (r *Run) MigrationComplete() {
+	r.complete.Set(1)
+}
+
+// WrapClient returns a client that counts every successful Create and Delete call per kind.
+func (r *Run) WrapClient(c client.Client) client.Client {
+	return &countingClient{Client: c, run: r}
+}
+
+// Finish records the duration and the result of the run. exitCode is the exit code of the process. Only the first
+// call has an effect.
+func (r *Run) Finish(exitCode int) {
+	r.once.Do(func() {
+		end := time.Now()
+		r.duration.Set(end.Sub(r.start).Seconds())
+		if exitCode == 0 {
+			r.success.Set(1)
+		}
+		r.exitCode.Set(float64(exitCode))
+		r.lastCompletion.Set(float64(end.Unix()))
+	})
+}
+
+// Push replaces the metrics of the group of the run at the Pushgateway at gatewayURL with the metrics of the run. The
+// group is identified by job, the mode of the run and the grouping labels of ParseGrouping, e.g. cluster=prod-1.
+func (r *Run) Push(ctx context.Context, gatewayURL, job string, grouping map[string]string) error {
+	if job == "" {
+		job = DefaultJob
+	}
+	labels := map[string]string{ModeLabel: r.mode}
+	for name, value := range grouping {
+		labels[name] = value
+	}
+	families, err := r.registry.Gather()
+	if err != nil {
+		return fmt.Errorf("cannot gather metrics, err: %w", err)
+	}
+	body := &bytes.Buffer{}
+	encoder := expfmt.NewEncoder(body, expfmt.FmtText)
+	for _, family := range families {
+		if err := encoder.Encode(family); err != nil {
+			return fmt.Errorf("cannot encode metrics, err: %w", err)
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, groupURL(gatewayURL, job, labels), body)
+	if err != nil {
+		return fmt.Errorf("invalid Pushgateway URL %q, err: %w", gatewayURL, err)
+	}
+	req.Header.Set("Content-Type", string(expfmt.FmtText))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("cannot push metrics to %s, err: %w", gatewayURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("cannot push metrics to %s, status %s: %s", gatewayURL, resp.Status,
+			strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+// groupURL returns the URL of the group of job and labels at the Pushgateway at gatewayURL. Label values that cannot
+// be part of a URL path segment are base64 encoded, as the Pushgateway API specifies.
+func groupURL(gatewayURL, job string, labels map[string]string) string {
+	segments := []string{strings.TrimSuffix(gatewayURL, "/"), "metrics"}
+	appendLabel := func(name, value string) {
+		if value == "" || strings.Contains(value, "/") {
+			encoded := base64.RawURLEncoding.EncodeToString([]byte(value))
+			if encoded == "" {
+				// An empty value is encoded as a single padding character.
+				encoded = "="
+			}
+			segments = append(segments, name+"@base64", encoded)
+			return
+		}
+		segments = append(segments, name, url.PathEscape(value))
+	}
+	appendLabel("job", job)
+	var names []string
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		appendLabel(name, labels[name])
+	}
+	return strings.Join(segments, "/")
+}
+
+// count increments the mutation counter for operation on the kind of obj.
+func (r *Run) count(c client.Client, operation string, obj client.Object) {
+	kind := obj.GetObjectKind().GroupVersionKind().Kind
+	if gvk, err := apiutil.GVKForObject(obj, c.Scheme()); err == nil {
+		kind = gvk.Kind
+	}
+	r.mutations.WithLabelValues(operation, kind).Inc()
+}
+
+// countingClient counts successful Create and Delete calls in a Run.
+type countingClient struct {
+	client.Client
+	run *Run
+}
+
+func (cc *countingClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	err := cc.Client.Create(ctx, obj, opts...)
+	if err == nil {
+		cc.run.count(cc.Client, operationCreate, obj)
+	}
+	return err
+}
+
+func (cc *countingClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	err := cc.Client.Delete(ctx, obj, opts...)
+	if err == nil {
+		cc.run.count(cc.Client, operationDelete, obj)
+	}
+	return err
+}
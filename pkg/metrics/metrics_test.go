@@ -0,0 +1,157 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestParseGrouping(t *testing.T) {
+	tcs := map[string]struct {
+		pairs               []string
+		expected            map[string]string
+		expectedErrorString string
+	}{
+		"valid": {
+			pairs:    []string{"cluster=prod-1", "region="},
+			expected: map[string]string{"cluster": "prod-1", "region": ""},
+		},
+		"missing value": {
+			pairs:               []string{"cluster"},
+			expectedErrorString: "must be name=value",
+		},
+		"invalid name": {
+			pairs:               []string{"k8s.io/cluster=prod"},
+			expectedErrorString: `invalid grouping label name "k8s.io/cluster"`,
+		},
+		"reserved name": {
+			pairs:               []string{"mode=x"},
+			expectedErrorString: `grouping label "mode" is reserved`,
+		},
+	}
+	for desc, tc := range tcs {
+		grouping, err := ParseGrouping(tc.pairs)
+		if tc.expectedErrorString != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.expectedErrorString) {
+				t.Fatalf("TestParseGrouping(%s): expected error %q but got %v", desc, tc.expectedErrorString, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("TestParseGrouping(%s): unexpected error, err: %q", desc, err)
+		}
+		if len(grouping) != len(tc.expected) {
+			t.Fatalf("TestParseGrouping(%s): expected %v but got %v", desc, tc.expected, grouping)
+		}
+		for name, value := range tc.expected {
+			if grouping[name] != value {
+				t.Fatalf("TestParseGrouping(%s): expected %v but got %v", desc, tc.expected, grouping)
+			}
+		}
+	}
+}
+
+func TestGroupURL(t *testing.T) {
+	tcs := map[string]struct {
+		labels   map[string]string
+		expected string
+	}{
+		"plain": {
+			labels:   map[string]string{"mode": "online-migration", "cluster": "prod 1"},
+			expected: "http://gw:9091/metrics/job/metallb-converter/cluster/prod%201/mode/online-migration",
+		},
+		"base64": {
+			labels:   map[string]string{"path": "a/b", "empty": ""},
+			expected: "http://gw:9091/metrics/job/metallb-converter/empty@base64/=/path@base64/YS9i",
+		},
+	}
+	for desc, tc := range tcs {
+		if got := groupURL("http://gw:9091/", DefaultJob, tc.labels); got != tc.expected {
+			t.Fatalf("TestGroupURL(%s): expected %q but got %q", desc, tc.expected, got)
+		}
+	}
+}
+
+func TestPush(t *testing.T) {
+	var method, path, body string
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		method, path, body = r.Method, r.URL.Path, string(data)
+		if strings.Contains(r.URL.Path, "fail") {
+			http.Error(w, "pushed metrics are invalid", http.StatusBadRequest)
+		}
+	}))
+	defer gateway.Close()
+
+	scheme := runtime.NewScheme()
+	if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestPush: error adding to scheme, err: %q", err)
+	}
+	run := NewRun("online-migration")
+	c := run.WrapClient(fake.NewClientBuilder().WithScheme(scheme).Build())
+	iap := &metallbv1beta1.IPAddressPool{ObjectMeta: metav1.ObjectMeta{Name: "pool", Namespace: "metallb-system"}}
+	if err := c.Create(context.TODO(), iap); err != nil {
+		t.Fatalf("TestPush: cannot create IPAddressPool, err: %q", err)
+	}
+	run.ObserveConversion(2, map[string]int{"IPAddressPool": 2, "L2Advertisement": 1})
+	run.Finish(0)
+	run.Finish(21)
+
+	err := run.Push(context.TODO(), gateway.URL, "", map[string]string{"cluster": "prod-1"})
+	if err != nil {
+		t.Fatalf("TestPush: unexpected error, err: %q", err)
+	}
+	if method != http.MethodPut || path != "/metrics/job/metallb-converter/cluster/prod-1/mode/online-migration" {
+		t.Fatalf("TestPush: unexpected request %s %s", method, path)
+	}
+	for _, expected := range []string{
+		"metallb_converter_address_pools_converted_total 2",
+		`metallb_converter_objects_converted_total{kind="L2Advertisement"} 1`,
+		`metallb_converter_api_mutations_total{kind="IPAddressPool",operation="create"} 1`,
+		"metallb_converter_run_success 1",
+		"metallb_converter_run_exit_code 0",
+	} {
+		if !strings.Contains(body, expected) {
+			t.Fatalf("TestPush: expected %q in the pushed metrics but got:\n%s", expected, body)
+		}
+	}
+
+	err = run.Push(context.TODO(), gateway.URL, "fail", nil)
+	if err == nil || !strings.Contains(err.Error(), "pushed metrics are invalid") {
+		t.Fatalf("TestPush: expected the error of the Pushgateway but got %v", err)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	run := NewRun("online-migration")
+	run.ObserveMigration(3*time.Second, "")
+	run.ObserveMigration(time.Second, "INJECTED_FAILURE")
+	run.ObserveError("INJECTED_FAILURE")
+	run.MigrationComplete()
+
+	recorder := httptest.NewRecorder()
+	run.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("TestHandler: unexpected status %d", recorder.Code)
+	}
+	for _, expected := range []string{
+		`metallb_converter_address_pool_migration_duration_seconds_count{result="success"} 1`,
+		`metallb_converter_address_pool_migration_duration_seconds_sum{result="success"} 3`,
+		`metallb_converter_address_pool_migration_duration_seconds_count{result="failure"} 1`,
+		`metallb_converter_errors_total{code="INJECTED_FAILURE"} 2`,
+		"metallb_converter_migration_complete 1",
+	} {
+		if !strings.Contains(recorder.Body.String(), expected) {
+			t.Fatalf("TestHandler: expected %q in the metrics but got:\n%s", expected, recorder.Body.String())
+		}
+	}
+}
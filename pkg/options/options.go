@@ -0,0 +1,105 @@
+// Package options fills in command line flags from a configuration file and from environment variables. Values are
+// applied with the following precedence: command line flags, environment variables, configuration file, defaults.
+package options
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// EnvPrefix is the prefix of the environment variables that configure flags. Dashes in flag names are replaced
+	// with underscores and the name is upper cased, e.g. flag backup-dir is read from METALLB_CONVERTER_BACKUP_DIR.
+	EnvPrefix = "METALLB_CONVERTER_"
+	// ConfigFlag is the name of the flag that points to the configuration file.
+	ConfigFlag = "config"
+)
+
+// EnvName returns the name of the environment variable that configures flag name.
+func EnvName(name string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// Apply sets every flag of fs that was not set on the command line from the environment (looked up with getenv) or
+// from the YAML configuration file configFile. The configuration file is a map of flag names to values, e.g.:
+//
+//	online-migration: true
+//	backup-dir: /var/backup
+//
+// An empty configFile is ignored. Unknown keys in the configuration file are an error.
+func Apply(fs *flag.FlagSet, configFile string, getenv func(string) string) error {
+	fileValues, err := readConfigFile(fs, configFile)
+	if err != nil {
+		return err
+	}
+	setOnCommandLine := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		setOnCommandLine[f.Name] = true
+	})
+	var errs []string
+	fs.VisitAll(func(f *flag.Flag) {
+		if setOnCommandLine[f.Name] || f.Name == ConfigFlag {
+			return
+		}
+		value, source := getenv(EnvName(f.Name)), EnvName(f.Name)
+		if value == "" {
+			var ok bool
+			if value, ok = fileValues[f.Name]; !ok {
+				return
+			}
+			source = configFile
+		}
+		if err := fs.Set(f.Name, value); err != nil {
+			errs = append(errs, fmt.Sprintf("invalid value %q for flag %s from %s: %v", value, f.Name, source, err))
+		}
+	})
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "\n"))
+	}
+	return nil
+}
+
+// readConfigFile reads the flag values of configFile.
+func readConfigFile(fs *flag.FlagSet, configFile string) (map[string]string, error) {
+	if configFile == "" {
+		return nil, nil
+	}
+	content, err := os.ReadFile(configFile)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read configuration file %s, err: %w", configFile, err)
+	}
+	raw := map[string]interface{}{}
+	if err := yaml.Unmarshal(content, &raw); err != nil {
+		return nil, fmt.Errorf("cannot parse configuration file %s, err: %w", configFile, err)
+	}
+	values := map[string]string{}
+	var unknown []string
+	for k, v := range raw {
+		if fs.Lookup(k) == nil || k == ConfigFlag {
+			unknown = append(unknown, k)
+			continue
+		}
+		switch v := v.(type) {
+		case map[string]interface{}, []interface{}:
+			return nil, fmt.Errorf("invalid value for key %s in configuration file %s, must be a scalar", k,
+				configFile)
+		case nil:
+			values[k] = ""
+		case float64:
+			values[k] = strconv.FormatFloat(v, 'f', -1, 64)
+		default:
+			values[k] = fmt.Sprint(v)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("unknown keys in configuration file %s: %s", configFile, strings.Join(unknown, ", "))
+	}
+	return values, nil
+}
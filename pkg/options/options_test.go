@@ -0,0 +1,94 @@
+package options
+
+import (
+	"flag"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestApply(t *testing.T) {
+	tcs := map[string]struct {
+		args        []string
+		config      string
+		env         map[string]string
+		expectError bool
+		expected    map[string]string
+	}{
+		"defaults": {
+			expected: map[string]string{
+				"backup-dir": "", "online-migration": "false", "delete-after": "0s", "limit": "0",
+			},
+		},
+		"config file": {
+			config: "online-migration: true\nbackup-dir: /backup\ndelete-after: 24h\nlimit: 1000000\n",
+			expected: map[string]string{
+				"backup-dir": "/backup", "online-migration": "true", "delete-after": "24h0m0s", "limit": "1000000",
+			},
+		},
+		"environment overrides config file": {
+			config: "backup-dir: /backup\n",
+			env:    map[string]string{"METALLB_CONVERTER_BACKUP_DIR": "/env", "METALLB_CONVERTER_ONLINE_MIGRATION": "1"},
+			expected: map[string]string{
+				"backup-dir": "/env", "online-migration": "true", "delete-after": "0s", "limit": "0",
+			},
+		},
+		"command line overrides environment": {
+			args: []string{"-backup-dir", "/cli"},
+			env:  map[string]string{"METALLB_CONVERTER_BACKUP_DIR": "/env"},
+			expected: map[string]string{
+				"backup-dir": "/cli", "online-migration": "false", "delete-after": "0s", "limit": "0",
+			},
+		},
+		"unknown key": {
+			config:      "backup-directory: /backup\n",
+			expectError: true,
+		},
+		"invalid value": {
+			env:         map[string]string{"METALLB_CONVERTER_DELETE_AFTER": "tomorrow"},
+			expectError: true,
+		},
+		"non scalar value": {
+			config:      "backup-dir:\n- a\n- b\n",
+			expectError: true,
+		},
+	}
+	for desc, tc := range tcs {
+		fs := flag.NewFlagSet(desc, flag.ContinueOnError)
+		configFile := fs.String(ConfigFlag, "", "")
+		fs.String("backup-dir", "", "")
+		fs.Bool("online-migration", false, "")
+		fs.Duration("delete-after", 0, "")
+		fs.Int("limit", 0, "")
+		if tc.config != "" {
+			tc.args = append(tc.args, "-"+ConfigFlag, path.Join(t.TempDir(), "converter.yaml"))
+			if err := os.WriteFile(tc.args[len(tc.args)-1], []byte(tc.config), 0600); err != nil {
+				t.Fatalf("TestApply(%s): cannot write configuration file, err: %q", desc, err)
+			}
+		}
+		if err := fs.Parse(tc.args); err != nil {
+			t.Fatalf("TestApply(%s): cannot parse flags, err: %q", desc, err)
+		}
+		err := Apply(fs, *configFile, func(key string) string { return tc.env[key] })
+		if tc.expectError {
+			if err == nil {
+				t.Fatalf("TestApply(%s): expected an error but got none", desc)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("TestApply(%s): unexpected error, err: %q", desc, err)
+		}
+		for name, expected := range tc.expected {
+			if got := fs.Lookup(name).Value.String(); got != expected {
+				t.Fatalf("TestApply(%s): expected flag %s to be %q but got %q", desc, name, expected, got)
+			}
+		}
+	}
+}
+
+func TestEnvName(t *testing.T) {
+	if got := EnvName("backup-dir"); got != "METALLB_CONVERTER_BACKUP_DIR" {
+		t.Fatalf("TestEnvName: unexpected environment variable name %q", got)
+	}
+}
@@ -0,0 +1,77 @@
+package options
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ProfileFlag is the name of the flag that selects a profile.
+const ProfileFlag = "profile"
+
+// Profiles maps profile names to the flag values they bundle. Profile values have the lowest precedence: they are
+// only applied to flags that were neither set on the command line, nor via the environment or the configuration file.
+var Profiles = map[string]map[string]string{
+	// conservative never deletes anything: the legacy AddressPools are kept and marked, they can be removed later
+	// with a finalize run.
+	"conservative": {
+		"keep-legacy": "true",
+	},
+	// consolidate centralizes shared settings in as few resources as possible.
+	"consolidate": {
+		"community-resources":   "true",
+		"merge-identical-pools": "true",
+		"merge-l2":              "true",
+	},
+	// gitops keeps the output deterministic and free of progress messages so that it can be committed to a
+	// repository as is and diffs stay small.
+	"gitops": {
+		"normalize-addresses": "true",
+		"quiet":               "true",
+	},
+}
+
+// ProfileNames returns the sorted names of all profiles.
+func ProfileNames() []string {
+	var names []string
+	for name := range Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ApplyProfile sets every flag of fs that was not set yet to the value of profile name. An empty name is ignored.
+func ApplyProfile(fs *flag.FlagSet, name string) error {
+	if name == "" {
+		return nil
+	}
+	profile, ok := Profiles[name]
+	if !ok {
+		return fmt.Errorf("unknown profile %q, must be one of: %s", name, strings.Join(ProfileNames(), ", "))
+	}
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	for _, k := range sortedKeys(profile) {
+		if set[k] {
+			continue
+		}
+		if err := fs.Set(k, profile[k]); err != nil {
+			return fmt.Errorf("invalid value %q for flag %s in profile %s, err: %w", profile[k], k, name, err)
+		}
+	}
+	return nil
+}
+
+// sortedKeys returns the sorted keys of m.
+func sortedKeys(m map[string]string) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
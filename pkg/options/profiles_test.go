@@ -0,0 +1,61 @@
+package options
+
+import (
+	"flag"
+	"testing"
+)
+
+func TestApplyProfile(t *testing.T) {
+	tcs := map[string]struct {
+		args        []string
+		profile     string
+		expectError bool
+		expected    map[string]string
+	}{
+		"no profile": {
+			expected: map[string]string{"keep-legacy": "false", "o": "", "quiet": "false"},
+		},
+		"conservative": {
+			profile:  "conservative",
+			expected: map[string]string{"keep-legacy": "true", "o": "", "quiet": "false"},
+		},
+		"gitops": {
+			profile:  "gitops",
+			expected: map[string]string{"keep-legacy": "false", "quiet": "true", "normalize-addresses": "true"},
+		},
+		"explicit flags win": {
+			args:     []string{"-quiet=false"},
+			profile:  "gitops",
+			expected: map[string]string{"keep-legacy": "false", "o": "", "quiet": "false"},
+		},
+		"unknown profile": {
+			profile:     "does-not-exist",
+			expectError: true,
+		},
+	}
+	for desc, tc := range tcs {
+		fs := flag.NewFlagSet(desc, flag.ContinueOnError)
+		fs.Bool("keep-legacy", false, "")
+		fs.String("o", "", "")
+		fs.Bool("quiet", false, "")
+		fs.Bool("normalize-addresses", false, "")
+		if err := fs.Parse(tc.args); err != nil {
+			t.Fatalf("TestApplyProfile(%s): cannot parse flags, err: %q", desc, err)
+		}
+		err := ApplyProfile(fs, tc.profile)
+		if tc.expectError {
+			if err == nil {
+				t.Fatalf("TestApplyProfile(%s): expected an error but got none", desc)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("TestApplyProfile(%s): unexpected error, err: %q", desc, err)
+		}
+		for name, expected := range tc.expected {
+			if got := fs.Lookup(name).Value.String(); got != expected {
+				t.Fatalf("TestApplyProfile(%s): expected flag %s to be %q but got %q", desc, name, expected, got)
+			}
+		}
+	}
+}
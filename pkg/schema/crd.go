@@ -0,0 +1,94 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+)
+
+// FromCRDs returns a bundle with the schemas of all served versions of the CRDs of group, e.g. the CRDs that a
+// cluster serves. version names the bundle in messages.
+func FromCRDs(version, group string, crds []apiextensionsv1.CustomResourceDefinition) (*Bundle, error) {
+	bundle := &Bundle{Version: version, Kinds: map[string]*Schema{}}
+	for _, crd := range crds {
+		if crd.Spec.Group != group {
+			continue
+		}
+		for _, crdVersion := range crd.Spec.Versions {
+			if !crdVersion.Served {
+				continue
+			}
+			if crdVersion.Schema == nil || crdVersion.Schema.OpenAPIV3Schema == nil {
+				return nil, fmt.Errorf("CRD %s version %s has no schema", crd.Name, crdVersion.Name)
+			}
+			s, err := fromJSONSchemaProps(crdVersion.Schema.OpenAPIV3Schema)
+			if err != nil {
+				return nil, fmt.Errorf("cannot read the schema of CRD %s version %s, err: %w", crd.Name,
+					crdVersion.Name, err)
+			}
+			// The API server validates metadata itself, the CRD schemas do not describe it.
+			if s.Properties != nil {
+				s.Properties["metadata"] = &Schema{Type: "object", PreserveUnknownFields: true}
+			}
+			bundle.Kinds[fmt.Sprintf("%s/%s/%s", group, crdVersion.Name, crd.Spec.Names.Kind)] = s
+		}
+	}
+	if len(bundle.Kinds) == 0 {
+		return nil, fmt.Errorf("no CRDs of group %s are served", group)
+	}
+	return bundle, nil
+}
+
+// fromJSONSchemaProps converts the subset of an OpenAPI v3 schema of a CRD that Schema supports.
+func fromJSONSchemaProps(p *apiextensionsv1.JSONSchemaProps) (*Schema, error) {
+	s := &Schema{
+		Type:     p.Type,
+		Required: p.Required,
+		Minimum:  p.Minimum,
+		Maximum:  p.Maximum,
+	}
+	if p.XPreserveUnknownFields != nil {
+		s.PreserveUnknownFields = *p.XPreserveUnknownFields
+	}
+	if len(p.Properties) > 0 {
+		s.Properties = map[string]*Schema{}
+		for name := range p.Properties {
+			property := p.Properties[name]
+			converted, err := fromJSONSchemaProps(&property)
+			if err != nil {
+				return nil, err
+			}
+			s.Properties[name] = converted
+		}
+	}
+	if p.AdditionalProperties != nil {
+		if p.AdditionalProperties.Schema != nil {
+			converted, err := fromJSONSchemaProps(p.AdditionalProperties.Schema)
+			if err != nil {
+				return nil, err
+			}
+			s.AdditionalProperties = converted
+		} else if p.AdditionalProperties.Allows {
+			s.AdditionalProperties = &Schema{PreserveUnknownFields: true}
+		}
+	}
+	if p.Items != nil {
+		if p.Items.Schema == nil {
+			return nil, fmt.Errorf("items with a list of schemas are not supported")
+		}
+		converted, err := fromJSONSchemaProps(p.Items.Schema)
+		if err != nil {
+			return nil, err
+		}
+		s.Items = converted
+	}
+	for _, value := range p.Enum {
+		var decoded interface{}
+		if err := json.Unmarshal(value.Raw, &decoded); err != nil {
+			return nil, fmt.Errorf("invalid enum value %s, err: %w", value.Raw, err)
+		}
+		s.Enum = append(s.Enum, decoded)
+	}
+	return s, nil
+}
@@ -0,0 +1,100 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+)
+
+// testCRD returns an IPAddressPool CRD of group that serves v1beta1 and has an unserved v1alpha1.
+func testCRD(group string) apiextensionsv1.CustomResourceDefinition {
+	items := &apiextensionsv1.JSONSchemaPropsOrArray{Schema: &apiextensionsv1.JSONSchemaProps{Type: "string"}}
+	spec := apiextensionsv1.JSONSchemaProps{
+		Type: "object",
+		Properties: map[string]apiextensionsv1.JSONSchemaProps{
+			"addresses":  {Type: "array", Items: items},
+			"autoAssign": {Type: "boolean"},
+			"protocol":   {Type: "string", Enum: []apiextensionsv1.JSON{{Raw: []byte(`"layer2"`)}, {Raw: []byte(`"bgp"`)}}},
+		},
+		Required: []string{"addresses"},
+	}
+	root := &apiextensionsv1.JSONSchemaProps{
+		Type: "object",
+		Properties: map[string]apiextensionsv1.JSONSchemaProps{
+			"apiVersion": {Type: "string"},
+			"kind":       {Type: "string"},
+			"metadata":   {Type: "object"},
+			"spec":       spec,
+		},
+	}
+	crd := apiextensionsv1.CustomResourceDefinition{}
+	crd.Name = "ipaddresspools." + group
+	crd.Spec.Group = group
+	crd.Spec.Names.Kind = "IPAddressPool"
+	crd.Spec.Versions = []apiextensionsv1.CustomResourceDefinitionVersion{
+		{Name: "v1beta1", Served: true, Schema: &apiextensionsv1.CustomResourceValidation{OpenAPIV3Schema: root}},
+		{Name: "v1alpha1", Served: false, Schema: &apiextensionsv1.CustomResourceValidation{OpenAPIV3Schema: root}},
+	}
+	return crd
+}
+
+func TestFromCRDs(t *testing.T) {
+	tcs := map[string]struct {
+		object           map[string]interface{}
+		expectedProblems []string
+	}{
+		"valid pool": {
+			object: map[string]interface{}{
+				"apiVersion": "metallb.io/v1beta1", "kind": "IPAddressPool",
+				"metadata": map[string]interface{}{"name": "pool", "labels": map[string]interface{}{"a": "b"}},
+				"spec":     map[string]interface{}{"addresses": []interface{}{"192.168.0.0/24"}, "autoAssign": true},
+			},
+		},
+		"field that the cluster does not serve": {
+			object: map[string]interface{}{
+				"apiVersion": "metallb.io/v1beta1", "kind": "IPAddressPool",
+				"spec": map[string]interface{}{
+					"addresses":         []interface{}{"192.168.0.0/24"},
+					"serviceAllocation": map[string]interface{}{"priority": float64(1)},
+				},
+			},
+			expectedProblems: []string{"spec.serviceAllocation: field is not supported by MetalLB of the cluster"},
+		},
+		"enum and required field": {
+			object: map[string]interface{}{
+				"apiVersion": "metallb.io/v1beta1", "kind": "IPAddressPool",
+				"spec": map[string]interface{}{"protocol": "ospf"},
+			},
+			expectedProblems: []string{
+				"spec.addresses: required field is missing",
+				"spec.protocol: ospf is not one of [layer2 bgp]",
+			},
+		},
+		"unserved version": {
+			object: map[string]interface{}{
+				"apiVersion": "metallb.io/v1alpha1", "kind": "IPAddressPool",
+			},
+			expectedProblems: []string{"metallb.io/v1alpha1 IPAddressPool is not served by MetalLB of the cluster"},
+		},
+	}
+	bundle, err := FromCRDs("of the cluster", "metallb.io",
+		[]apiextensionsv1.CustomResourceDefinition{testCRD("metallb.io"), testCRD("example.com")})
+	if err != nil {
+		t.Fatalf("TestFromCRDs: cannot build bundle, err: %q", err)
+	}
+	if len(bundle.Kinds) != 1 {
+		t.Fatalf("TestFromCRDs: expected only the served version of the metallb.io CRD but got %v", bundle.Kinds)
+	}
+	for desc, tc := range tcs {
+		problems := bundle.Validate(tc.object)
+		if fmt.Sprint(problems) != fmt.Sprint(tc.expectedProblems) {
+			t.Fatalf("TestFromCRDs(%s): expected problems %q but got %q", desc, tc.expectedProblems, problems)
+		}
+	}
+	_, err = FromCRDs("of the cluster", "metallb.io", []apiextensionsv1.CustomResourceDefinition{testCRD("example.com")})
+	if err == nil || !strings.Contains(err.Error(), "no CRDs of group metallb.io are served") {
+		t.Fatalf("TestFromCRDs: expected an error without metallb.io CRDs but got %v", err)
+	}
+}
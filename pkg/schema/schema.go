@@ -0,0 +1,262 @@
+// Package schema validates MetalLB resources against the CRD schemas of specific MetalLB releases. The schemas are
+// embedded into the binary so that validation works without cluster access. FromCRDs builds a bundle from the CRDs that
+// a cluster serves instead.
+package schema
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed bundles/*.json
+var bundles embed.FS
+
+// Schema is the subset of an OpenAPI v3 schema that the MetalLB CRDs use. References in the form
+// "#/definitions/<name>" point to the definitions of the Bundle.
+type Schema struct {
+	Ref                   string             `json:"$ref,omitempty"`
+	Type                  string             `json:"type,omitempty"`
+	Properties            map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties  *Schema            `json:"additionalProperties,omitempty"`
+	Items                 *Schema            `json:"items,omitempty"`
+	Required              []string           `json:"required,omitempty"`
+	Enum                  []interface{}      `json:"enum,omitempty"`
+	Minimum               *float64           `json:"minimum,omitempty"`
+	Maximum               *float64           `json:"maximum,omitempty"`
+	PreserveUnknownFields bool               `json:"x-kubernetes-preserve-unknown-fields,omitempty"`
+}
+
+// Bundle holds the schemas of all MetalLB CRDs of a single release, keyed by <group>/<version>/<kind>.
+type Bundle struct {
+	Version     string             `json:"version"`
+	Definitions map[string]*Schema `json:"definitions,omitempty"`
+	Kinds       map[string]*Schema `json:"kinds"`
+}
+
+// Versions returns the MetalLB releases that a schema bundle is embedded for, in ascending order.
+func Versions() []string {
+	entries, _ := bundles.ReadDir("bundles")
+	var versions []string
+	for _, entry := range entries {
+		versions = append(versions, strings.TrimSuffix(entry.Name(), ".json"))
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return CompareVersions(versions[i], versions[j]) < 0
+	})
+	return versions
+}
+
+// CompareVersions compares two vX.Y.Z release versions and returns -1, 0 or 1.
+func CompareVersions(a, b string) int {
+	aFields := strings.Split(strings.TrimPrefix(a, "v"), ".")
+	bFields := strings.Split(strings.TrimPrefix(b, "v"), ".")
+	for i := 0; i < len(aFields) || i < len(bFields); i++ {
+		var x, y int
+		if i < len(aFields) {
+			x, _ = strconv.Atoi(aFields[i])
+		}
+		if i < len(bFields) {
+			y, _ = strconv.Atoi(bFields[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// Load returns the embedded schema bundle of a MetalLB release.
+func Load(version string) (*Bundle, error) {
+	data, err := bundles.ReadFile(fmt.Sprintf("bundles/%s.json", version))
+	if err != nil {
+		return nil, fmt.Errorf("no schema bundle for MetalLB %q, must be one of: %s", version,
+			strings.Join(Versions(), ", "))
+	}
+	bundle := &Bundle{}
+	if err := json.Unmarshal(data, bundle); err != nil {
+		return nil, fmt.Errorf("cannot decode schema bundle for MetalLB %q, err: %w", version, err)
+	}
+	return bundle, nil
+}
+
+// Validate validates a single JSON decoded object against the schema of its kind. It returns one description per
+// violation; unknown kinds and fields are violations as well, because the API server would reject or prune them.
+func (b *Bundle) Validate(object map[string]interface{}) []string {
+	apiVersion, _ := object["apiVersion"].(string)
+	kind, _ := object["kind"].(string)
+	s, ok := b.Kinds[fmt.Sprintf("%s/%s", apiVersion, kind)]
+	if !ok {
+		return []string{fmt.Sprintf("%s %s is not served by MetalLB %s", apiVersion, kind, b.Version)}
+	}
+	return b.validate(s, object, "")
+}
+
+// Serves returns true if the release serves the kind of object.
+func (b *Bundle) Serves(object map[string]interface{}) bool {
+	apiVersion, _ := object["apiVersion"].(string)
+	kind, _ := object["kind"].(string)
+	_, ok := b.Kinds[fmt.Sprintf("%s/%s", apiVersion, kind)]
+	return ok
+}
+
+// UnsupportedFields returns the paths of all fields of object that the release does not know, in sorted order. If
+// prune is set, these fields are removed from object. Objects of kinds that the release does not serve are returned
+// unchanged.
+func (b *Bundle) UnsupportedFields(object map[string]interface{}, prune bool) []string {
+	apiVersion, _ := object["apiVersion"].(string)
+	kind, _ := object["kind"].(string)
+	s, ok := b.Kinds[fmt.Sprintf("%s/%s", apiVersion, kind)]
+	if !ok {
+		return nil
+	}
+	return b.unsupportedFields(s, object, "", prune)
+}
+
+// unsupportedFields implements UnsupportedFields for value at path.
+func (b *Bundle) unsupportedFields(s *Schema, value interface{}, path string, prune bool) []string {
+	s = b.resolve(s)
+	var fields []string
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if s.Type != "object" || s.PreserveUnknownFields {
+			return nil
+		}
+		for _, field := range sortedFields(v) {
+			child, ok := s.Properties[field]
+			if !ok {
+				child = s.AdditionalProperties
+			}
+			if child == nil {
+				fields = append(fields, displayPath(path+"."+field))
+				if prune {
+					delete(v, field)
+				}
+				continue
+			}
+			fields = append(fields, b.unsupportedFields(child, v[field], path+"."+field, prune)...)
+		}
+	case []interface{}:
+		if s.Type != "array" || s.Items == nil {
+			return nil
+		}
+		for i, item := range v {
+			fields = append(fields, b.unsupportedFields(s.Items, item, fmt.Sprintf("%s[%d]", path, i), prune)...)
+		}
+	}
+	return fields
+}
+
+// resolve follows the reference of s, if any.
+func (b *Bundle) resolve(s *Schema) *Schema {
+	if s.Ref == "" {
+		return s
+	}
+	if resolved, ok := b.Definitions[strings.TrimPrefix(s.Ref, "#/definitions/")]; ok {
+		return resolved
+	}
+	return &Schema{PreserveUnknownFields: true}
+}
+
+// validate validates value against s. path is the JSON path of value, used in the descriptions of violations.
+func (b *Bundle) validate(s *Schema, value interface{}, path string) []string {
+	s = b.resolve(s)
+	if value == nil {
+		return nil
+	}
+	var problems []string
+	switch s.Type {
+	case "object":
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected an object", displayPath(path))}
+		}
+		for _, field := range s.Required {
+			if _, ok := m[field]; !ok {
+				problems = append(problems, fmt.Sprintf("%s: required field is missing", displayPath(path+"."+field)))
+			}
+		}
+		for _, field := range sortedFields(m) {
+			child, ok := s.Properties[field]
+			if !ok {
+				child = s.AdditionalProperties
+			}
+			if child == nil {
+				if !s.PreserveUnknownFields {
+					problems = append(problems, fmt.Sprintf("%s: field is not supported by MetalLB %s",
+						displayPath(path+"."+field), b.Version))
+				}
+				continue
+			}
+			problems = append(problems, b.validate(child, m[field], path+"."+field)...)
+		}
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected an array", displayPath(path))}
+		}
+		if s.Items != nil {
+			for i, item := range items {
+				problems = append(problems, b.validate(s.Items, item, fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+	case "string":
+		if _, ok := value.(string); !ok {
+			return []string{fmt.Sprintf("%s: expected a string", displayPath(path))}
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return []string{fmt.Sprintf("%s: expected a boolean", displayPath(path))}
+		}
+	case "integer":
+		n, ok := value.(float64)
+		if !ok || n != math.Trunc(n) {
+			return []string{fmt.Sprintf("%s: expected an integer", displayPath(path))}
+		}
+		if s.Minimum != nil && n < *s.Minimum {
+			problems = append(problems, fmt.Sprintf("%s: %v is less than the minimum of %v", displayPath(path), n,
+				*s.Minimum))
+		}
+		if s.Maximum != nil && n > *s.Maximum {
+			problems = append(problems, fmt.Sprintf("%s: %v is greater than the maximum of %v", displayPath(path), n,
+				*s.Maximum))
+		}
+	}
+	if len(s.Enum) > 0 {
+		allowed := false
+		for _, e := range s.Enum {
+			if e == value {
+				allowed = true
+			}
+		}
+		if !allowed {
+			problems = append(problems, fmt.Sprintf("%s: %v is not one of %v", displayPath(path), value, s.Enum))
+		}
+	}
+	return problems
+}
+
+// displayPath returns path without its leading dot.
+func displayPath(path string) string {
+	if path == "" {
+		return "."
+	}
+	return strings.TrimPrefix(path, ".")
+}
+
+// sortedFields returns the keys of m in sorted order.
+func sortedFields(m map[string]interface{}) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
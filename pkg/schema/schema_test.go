@@ -0,0 +1,160 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestVersions(t *testing.T) {
+	expected := "[v0.13.5 v0.13.7 v0.13.10 v0.13.12 v0.14.3]"
+	if versions := fmt.Sprint(Versions()); versions != expected {
+		t.Fatalf("TestVersions: expected %s but got %s", expected, versions)
+	}
+	for _, version := range Versions() {
+		if _, err := Load(version); err != nil {
+			t.Fatalf("TestVersions: cannot load bundle %s, err: %q", version, err)
+		}
+	}
+	if _, err := Load("v0.1.0"); err == nil || !strings.Contains(err.Error(), "must be one of") {
+		t.Fatalf("TestVersions: expected an error for an unknown version but got %v", err)
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tcs := map[string]struct {
+		a, b     string
+		expected int
+	}{
+		"equal":         {a: "v0.13.7", b: "v0.13.7", expected: 0},
+		"patch":         {a: "v0.13.7", b: "v0.13.10", expected: -1},
+		"minor":         {a: "v0.14.0", b: "v0.13.12", expected: 1},
+		"missing patch": {a: "v0.13", b: "v0.13.0", expected: 0},
+	}
+	for desc, tc := range tcs {
+		if result := CompareVersions(tc.a, tc.b); result != tc.expected {
+			t.Fatalf("TestCompareVersions(%s): expected %d but got %d", desc, tc.expected, result)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tcs := map[string]struct {
+		version          string
+		object           map[string]interface{}
+		expectedProblems []string
+	}{
+		"valid pool": {
+			version: "v0.13.7",
+			object: map[string]interface{}{
+				"apiVersion": "metallb.io/v1beta1", "kind": "IPAddressPool",
+				"metadata": map[string]interface{}{"name": "pool", "creationTimestamp": nil},
+				"spec":     map[string]interface{}{"addresses": []interface{}{"192.168.0.0/24"}, "autoAssign": true},
+			},
+		},
+		"field of a later release": {
+			version: "v0.13.7",
+			object: map[string]interface{}{
+				"apiVersion": "metallb.io/v1beta1", "kind": "IPAddressPool",
+				"spec": map[string]interface{}{
+					"addresses":         []interface{}{"192.168.0.0/24"},
+					"serviceAllocation": map[string]interface{}{"priority": float64(1)},
+				},
+			},
+			expectedProblems: []string{"spec.serviceAllocation: field is not supported by MetalLB v0.13.7"},
+		},
+		"field of the release": {
+			version: "v0.13.12",
+			object: map[string]interface{}{
+				"apiVersion": "metallb.io/v1beta1", "kind": "IPAddressPool",
+				"spec": map[string]interface{}{
+					"addresses":         []interface{}{"192.168.0.0/24"},
+					"serviceAllocation": map[string]interface{}{"priority": float64(1)},
+				},
+			},
+		},
+		"missing required field and out of range": {
+			version: "v0.13.7",
+			object: map[string]interface{}{
+				"apiVersion": "metallb.io/v1beta2", "kind": "BGPPeer",
+				"spec": map[string]interface{}{"myASN": float64(64500), "peerASN": float64(64501), "peerPort": float64(20000)},
+			},
+			expectedProblems: []string{
+				"spec.peerAddress: required field is missing",
+				"spec.peerPort: 20000 is greater than the maximum of 16384",
+			},
+		},
+		"label selector": {
+			version: "v0.13.7",
+			object: map[string]interface{}{
+				"apiVersion": "metallb.io/v1beta1", "kind": "L2Advertisement",
+				"spec": map[string]interface{}{"nodeSelectors": []interface{}{
+					map[string]interface{}{"matchLabels": map[string]interface{}{"rack": float64(1)}},
+				}},
+			},
+			expectedProblems: []string{"spec.nodeSelectors[0].matchLabels.rack: expected a string"},
+		},
+		"removed kind": {
+			version: "v0.14.3",
+			object: map[string]interface{}{
+				"apiVersion": "metallb.io/v1beta1", "kind": "AddressPool",
+			},
+			expectedProblems: []string{"metallb.io/v1beta1 AddressPool is not served by MetalLB v0.14.3"},
+		},
+	}
+	for desc, tc := range tcs {
+		bundle, err := Load(tc.version)
+		if err != nil {
+			t.Fatalf("TestValidate(%s): cannot load bundle, err: %q", desc, err)
+		}
+		problems := bundle.Validate(tc.object)
+		if fmt.Sprint(problems) != fmt.Sprint(tc.expectedProblems) {
+			t.Fatalf("TestValidate(%s): expected problems %q but got %q", desc, tc.expectedProblems, problems)
+		}
+	}
+}
+
+func TestUnsupportedFields(t *testing.T) {
+	tcs := map[string]struct {
+		version        string
+		prune          bool
+		expectedFields []string
+		expectedSpec   string
+	}{
+		"supported": {
+			version:      "v0.13.7",
+			expectedSpec: "map[interfaces:[eth0] ipAddressPools:[pool]]",
+		},
+		"unsupported": {
+			version:        "v0.13.5",
+			expectedFields: []string{"spec.interfaces"},
+			expectedSpec:   "map[interfaces:[eth0] ipAddressPools:[pool]]",
+		},
+		"pruned": {
+			version:        "v0.13.5",
+			prune:          true,
+			expectedFields: []string{"spec.interfaces"},
+			expectedSpec:   "map[ipAddressPools:[pool]]",
+		},
+	}
+	for desc, tc := range tcs {
+		bundle, err := Load(tc.version)
+		if err != nil {
+			t.Fatalf("TestUnsupportedFields(%s): cannot load bundle, err: %q", desc, err)
+		}
+		object := map[string]interface{}{
+			"apiVersion": "metallb.io/v1beta1", "kind": "L2Advertisement",
+			"metadata": map[string]interface{}{"name": "l2", "labels": map[string]interface{}{"a": "b"}},
+			"spec": map[string]interface{}{
+				"ipAddressPools": []interface{}{"pool"}, "interfaces": []interface{}{"eth0"},
+			},
+		}
+		fields := bundle.UnsupportedFields(object, tc.prune)
+		if fmt.Sprint(fields) != fmt.Sprint(tc.expectedFields) {
+			t.Fatalf("TestUnsupportedFields(%s): expected fields %v but got %v", desc, tc.expectedFields, fields)
+		}
+		if spec := fmt.Sprint(object["spec"]); spec != tc.expectedSpec {
+			t.Fatalf("TestUnsupportedFields(%s): expected spec %s but got %s", desc, tc.expectedSpec, spec)
+		}
+	}
+}
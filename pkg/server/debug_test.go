@@ -0,0 +1,41 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDebugHandler(t *testing.T) {
+	handler := DebugHandler()
+	tcs := map[string]struct {
+		path             string
+		expectedStatus   int
+		expectedContains string
+	}{
+		"index": {
+			path:             "/debug/pprof/",
+			expectedStatus:   http.StatusOK,
+			expectedContains: "goroutine",
+		},
+		"heap profile": {
+			path:           "/debug/pprof/heap?debug=1",
+			expectedStatus: http.StatusOK,
+		},
+		"conversion is not served": {
+			path:           "/convert",
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+	for desc, tc := range tcs {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, tc.path, nil))
+		if recorder.Code != tc.expectedStatus {
+			t.Fatalf("TestDebugHandler(%s): expected status %d but got %d", desc, tc.expectedStatus, recorder.Code)
+		}
+		if !strings.Contains(recorder.Body.String(), tc.expectedContains) {
+			t.Fatalf("TestDebugHandler(%s): expected body to contain %q", desc, tc.expectedContains)
+		}
+	}
+}
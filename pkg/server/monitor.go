@@ -0,0 +1,57 @@
+package server
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	// MonitorKindServiceMonitor selects a Prometheus Operator ServiceMonitor, which scrapes the pods behind a Service.
+	MonitorKindServiceMonitor = "ServiceMonitor"
+	// MonitorKindPodMonitor selects a Prometheus Operator PodMonitor, which scrapes the pods directly.
+	MonitorKindPodMonitor = "PodMonitor"
+	// AppLabel is the label that the monitors select the Service or pods of the server with.
+	AppLabel = "app.kubernetes.io/name"
+	// AppName is the value of AppLabel that the monitors select.
+	AppName = "metallb-converter"
+
+	monitoringAPIVersion = "monitoring.coreos.com/v1"
+)
+
+// SupportedMonitorKinds lists the kinds that MonitorManifest supports.
+var SupportedMonitorKinds = []string{MonitorKindServiceMonitor, MonitorKindPodMonitor}
+
+// MonitorManifest returns a Prometheus Operator ServiceMonitor or PodMonitor in namespace that scrapes the MetricsPath
+// of the server on the named port of the Service or pods that carry the AppLabel.
+func MonitorManifest(kind, namespace, port string) (*unstructured.Unstructured, error) {
+	endpointsField := "endpoints"
+	switch kind {
+	case MonitorKindServiceMonitor:
+	case MonitorKindPodMonitor:
+		endpointsField = "podMetricsEndpoints"
+	default:
+		return nil, fmt.Errorf("unsupported monitor kind %q, must be one of: %s, %s", kind, MonitorKindServiceMonitor,
+			MonitorKindPodMonitor)
+	}
+	if port == "" {
+		return nil, fmt.Errorf("the port of the %s must not be empty", kind)
+	}
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": monitoringAPIVersion,
+		"kind":       kind,
+		"metadata": map[string]interface{}{
+			"name":      AppName,
+			"namespace": namespace,
+			"labels":    map[string]interface{}{AppLabel: AppName},
+		},
+		"spec": map[string]interface{}{
+			"selector": map[string]interface{}{
+				"matchLabels": map[string]interface{}{AppLabel: AppName},
+			},
+			endpointsField: []interface{}{
+				map[string]interface{}{"port": port, "path": MetricsPath},
+			},
+		},
+	}}, nil
+}
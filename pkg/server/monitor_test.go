@@ -0,0 +1,62 @@
+package server
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestMonitorManifest(t *testing.T) {
+	tcs := map[string]struct {
+		kind                string
+		port                string
+		expectedField       string
+		expectedErrorString string
+	}{
+		"service monitor": {
+			kind:          MonitorKindServiceMonitor,
+			port:          "http",
+			expectedField: "endpoints",
+		},
+		"pod monitor": {
+			kind:          MonitorKindPodMonitor,
+			port:          "metrics",
+			expectedField: "podMetricsEndpoints",
+		},
+		"unsupported kind": {
+			kind:                "Probe",
+			port:                "http",
+			expectedErrorString: "unsupported monitor kind \"Probe\"",
+		},
+		"empty port": {
+			kind:                MonitorKindServiceMonitor,
+			expectedErrorString: "port of the ServiceMonitor must not be empty",
+		},
+	}
+	for desc, tc := range tcs {
+		monitor, err := MonitorManifest(tc.kind, "monitoring", tc.port)
+		if tc.expectedErrorString != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.expectedErrorString) {
+				t.Fatalf("TestMonitorManifest(%s): expected error %q but got %v", desc, tc.expectedErrorString, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("TestMonitorManifest(%s): unexpected error, err: %q", desc, err)
+		}
+		if monitor.GetKind() != tc.kind || monitor.GetNamespace() != "monitoring" {
+			t.Fatalf("TestMonitorManifest(%s): unexpected kind %q or namespace %q", desc, monitor.GetKind(),
+				monitor.GetNamespace())
+		}
+		endpoints, found, err := unstructured.NestedSlice(monitor.Object, "spec", tc.expectedField)
+		if err != nil || !found || len(endpoints) != 1 {
+			t.Fatalf("TestMonitorManifest(%s): expected one endpoint in spec.%s, got %v", desc, tc.expectedField,
+				monitor.Object)
+		}
+		endpoint := endpoints[0].(map[string]interface{})
+		if endpoint["port"] != tc.port || endpoint["path"] != MetricsPath {
+			t.Fatalf("TestMonitorManifest(%s): unexpected endpoint %v", desc, endpoint)
+		}
+	}
+}
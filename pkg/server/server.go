@@ -0,0 +1,172 @@
+// Package server exposes the conversion of legacy MetalLB resources as an HTTP API, so that other platforms can
+// integrate the converter without shelling out to the command line tool.
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/andreaskaris/metallb-converter/pkg/converter"
+	"github.com/andreaskaris/metallb-converter/pkg/schema"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	// MaxRequestSize is the maximum size of a conversion request body in bytes.
+	MaxRequestSize = 10 << 20
+	// ReadHeaderTimeout, ReadTimeout, WriteTimeout and IdleTimeout bound the time that the servers of NewHTTPServer
+	// spend on reading a request, on writing its response and on keeping an idle connection open, so that slow or
+	// stalled clients cannot hold connections forever.
+	ReadHeaderTimeout = 10 * time.Second
+	ReadTimeout       = time.Minute
+	WriteTimeout      = 2 * time.Minute
+	IdleTimeout       = 2 * time.Minute
+	// MetricsPath is the path that the Prometheus metrics of the server are served on.
+	MetricsPath = "/metrics"
+)
+
+// ConvertResponse is the response of POST /convert.
+type ConvertResponse struct {
+	// Manifests holds the converted resources in the requested output format.
+	Manifests string `json:"manifests"`
+	// Warnings lists problems of the input that did not prevent the conversion.
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// ErrorResponse is the response of a failed request.
+type ErrorResponse struct {
+	Error string `json:"error"`
+	// Code is the converter.ErrorCode of a failed conversion.
+	Code converter.ErrorCode `json:"code,omitempty"`
+}
+
+// New returns the HTTP handler of the API. scheme must know the legacy MetalLB types, see converter.AddToScheme.
+// Requests are converted according to opts.
+//
+//	POST /convert?output=yaml|json&validate-for=<version>  converts the legacy YAML or JSON resources of the
+//	                                                       request body and optionally validates the result
+//	                                                       against the schemas of a MetalLB release.
+//	GET /healthz                                           returns 200 once the server is up.
+//	GET /metrics                                           returns the Prometheus metrics of the server.
+func New(scheme *runtime.Scheme, opts converter.Options) http.Handler {
+	registry := prometheus.NewRegistry()
+	requests := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "metallb_converter_convert_requests_total",
+		Help: "Number of conversion requests by HTTP status code.",
+	}, []string{"code"})
+	registry.MustRegister(requests)
+
+	mux := http.NewServeMux()
+	mux.Handle("/convert", promhttp.InstrumentHandlerCounter(requests, http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			handleConvert(w, r, scheme, opts)
+		})))
+	mux.Handle(MetricsPath, promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+	return mux
+}
+
+// NewHTTPServer returns an HTTP server that serves handler on addr with the timeouts of this package.
+func NewHTTPServer(addr string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: ReadHeaderTimeout,
+		ReadTimeout:       ReadTimeout,
+		WriteTimeout:      WriteTimeout,
+		IdleTimeout:       IdleTimeout,
+	}
+}
+
+// handleConvert implements POST /convert.
+func handleConvert(w http.ResponseWriter, r *http.Request, scheme *runtime.Scheme, opts converter.Options) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "only POST is supported"})
+		return
+	}
+	output := r.URL.Query().Get("output")
+	if output == "" {
+		output = converter.OutputFormatYAML
+	}
+	if output != converter.OutputFormatYAML && output != converter.OutputFormatJSON {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{
+			Error: fmt.Sprintf("unsupported output format %q, must be one of: %s, %s", output,
+				converter.OutputFormatYAML, converter.OutputFormatJSON),
+		})
+		return
+	}
+	if version := r.URL.Query().Get("validate-for"); version != "" {
+		bundle, err := schema.Load(version)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+			return
+		}
+		opts.ValidateAgainst = bundle
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxRequestSize))
+	if err != nil {
+		writeJSON(w, http.StatusRequestEntityTooLarge, ErrorResponse{Error: fmt.Sprintf("cannot read request, err: %v",
+			err)})
+		return
+	}
+	response, err := Convert(scheme, body, output == converter.OutputFormatJSON, opts)
+	if err != nil {
+		writeJSON(w, http.StatusUnprocessableEntity, ErrorResponse{Error: err.Error(), Code: converter.Code(err)})
+		return
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// Convert converts the legacy YAML or JSON resources in content and returns the converted manifests together with
+// the warnings that the command line tool would log, including the problems that package validate finds. With
+// opts.StrictValidation, these problems fail the conversion instead. If opts.ValidateAgainst is set, schema violations
+// are returned as warnings as well.
+func Convert(scheme *runtime.Scheme, content []byte, toJSON bool, opts converter.Options) (*ConvertResponse, error) {
+	legacyObjects, err := converter.ReadLegacyObjects(scheme, content, "request", opts)
+	if err != nil {
+		return nil, err
+	}
+	response := &ConvertResponse{}
+	for _, group := range converter.IdenticalAddressPools(legacyObjects) {
+		response.Warnings = append(response.Warnings, fmt.Sprintf("AddressPools %s have identical addresses, "+
+			"consider merging them", strings.Join(group, ", ")))
+	}
+	currentObjects, err := legacyObjects.Convert(opts)
+	if err != nil {
+		return nil, err
+	}
+	response.Warnings = append(response.Warnings, currentObjects.LocalPrefConflicts()...)
+	problems := converter.ValidationProblems(legacyObjects, currentObjects)
+	if opts.StrictValidation && len(problems) > 0 {
+		return nil, fmt.Errorf("%w:\n%s", converter.ErrStrictValidation, strings.Join(problems, "\n"))
+	}
+	response.Warnings = append(response.Warnings, problems...)
+	if opts.ValidateAgainst != nil {
+		if err := currentObjects.ValidateSchema(opts.ValidateAgainst); err != nil {
+			response.Warnings = append(response.Warnings, err.Error())
+		}
+	}
+	buf := &bytes.Buffer{}
+	if err := currentObjects.Encode(buf, toJSON, opts); err != nil {
+		return nil, err
+	}
+	response.Manifests = buf.String()
+	return response, nil
+}
+
+// writeJSON writes v as the JSON body of a response with the given status code.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
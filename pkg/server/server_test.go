@@ -0,0 +1,213 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andreaskaris/metallb-converter/pkg/converter"
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const legacyAddressPools = `apiVersion: metallb.io/v1beta1
+kind: AddressPool
+metadata:
+  name: ap-l2
+  namespace: metallb-system
+spec:
+  protocol: layer2
+  addresses:
+  - 192.168.0.0/24
+---
+apiVersion: metallb.io/v1beta1
+kind: AddressPool
+metadata:
+  name: ap-l2-copy
+  namespace: metallb-system
+spec:
+  protocol: layer2
+  addresses:
+  - 192.168.0.0/24
+`
+
+// legacyAggregatedPool is an AddressPool whose aggregation length is shorter than its CIDR, which package validate
+// reports.
+const legacyAggregatedPool = `apiVersion: metallb.io/v1beta1
+kind: AddressPool
+metadata:
+  name: aggregated
+  namespace: metallb-system
+spec:
+  protocol: bgp
+  addresses:
+  - 192.168.10.0/24
+  bgpAdvertisements:
+  - aggregationLength: 16
+`
+
+func TestConvertHandler(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestConvertHandler: cannot build scheme, err: %q", err)
+	}
+	tcs := map[string]struct {
+		opts               converter.Options
+		method             string
+		path               string
+		body               string
+		expectedStatus     int
+		expectedManifests  []string
+		expectedWarnings   []string
+		expectedErrorMatch string
+		expectedCode       converter.ErrorCode
+	}{
+		"yaml": {
+			method:            http.MethodPost,
+			path:              "/convert",
+			body:              legacyAddressPools,
+			expectedStatus:    http.StatusOK,
+			expectedManifests: []string{"kind: IPAddressPool", "name: ap-l2-copy", "kind: L2Advertisement"},
+			expectedWarnings:  []string{"AddressPools metallb-system/ap-l2, metallb-system/ap-l2-copy have identical"},
+		},
+		"validation problems": {
+			method:            http.MethodPost,
+			path:              "/convert",
+			body:              legacyAggregatedPool,
+			expectedStatus:    http.StatusOK,
+			expectedManifests: []string{"kind: BGPAdvertisement"},
+			expectedWarnings:  []string{"invalid IPv4 aggregation length 16"},
+		},
+		"validation problems in strict mode": {
+			opts:               converter.Options{StrictValidation: true},
+			method:             http.MethodPost,
+			path:               "/convert",
+			body:               legacyAggregatedPool,
+			expectedStatus:     http.StatusUnprocessableEntity,
+			expectedErrorMatch: "invalid IPv4 aggregation length 16",
+			expectedCode:       converter.ErrorCodeStrictValidation,
+		},
+		"json": {
+			method:            http.MethodPost,
+			path:              "/convert?output=json",
+			body:              legacyAddressPools,
+			expectedStatus:    http.StatusOK,
+			expectedManifests: []string{`"kind": "IPAddressPool"`},
+		},
+		"validate for unknown version": {
+			method:             http.MethodPost,
+			path:               "/convert?validate-for=v0.1.0",
+			body:               legacyAddressPools,
+			expectedStatus:     http.StatusBadRequest,
+			expectedErrorMatch: "no schema bundle",
+		},
+		"unsupported output": {
+			method:             http.MethodPost,
+			path:               "/convert?output=dot",
+			body:               legacyAddressPools,
+			expectedStatus:     http.StatusBadRequest,
+			expectedErrorMatch: "unsupported output format",
+		},
+		"invalid input": {
+			method:             http.MethodPost,
+			path:               "/convert",
+			body:               "kind: [",
+			expectedStatus:     http.StatusUnprocessableEntity,
+			expectedErrorMatch: "request",
+			expectedCode:       converter.ErrorCodeUnknown,
+		},
+		"unsupported kind": {
+			method:             http.MethodPost,
+			path:               "/convert",
+			body:               "apiVersion: metallb.io/v1beta1\nkind: IPAddressPool\nmetadata:\n  name: iap\n",
+			expectedStatus:     http.StatusUnprocessableEntity,
+			expectedErrorMatch: "unsupported kind IPAddressPool",
+			expectedCode:       converter.ErrorCodeUnsupportedKind,
+		},
+		"wrong method": {
+			method:             http.MethodGet,
+			path:               "/convert",
+			expectedStatus:     http.StatusMethodNotAllowed,
+			expectedErrorMatch: "only POST",
+		},
+		"healthz": {
+			method:         http.MethodGet,
+			path:           "/healthz",
+			expectedStatus: http.StatusOK,
+		},
+	}
+	for desc, tc := range tcs {
+		handler := New(scheme, tc.opts)
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body)))
+		if recorder.Code != tc.expectedStatus {
+			t.Fatalf("TestConvertHandler(%s): expected status %d but got %d, body: %s", desc, tc.expectedStatus,
+				recorder.Code, recorder.Body.String())
+		}
+		if tc.expectedErrorMatch != "" {
+			response := ErrorResponse{}
+			if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+				t.Fatalf("TestConvertHandler(%s): cannot decode response, err: %q", desc, err)
+			}
+			if !strings.Contains(response.Error, tc.expectedErrorMatch) {
+				t.Fatalf("TestConvertHandler(%s): expected error %q but got %q", desc, tc.expectedErrorMatch,
+					response.Error)
+			}
+			if response.Code != tc.expectedCode {
+				t.Fatalf("TestConvertHandler(%s): expected code %q but got %q", desc, tc.expectedCode, response.Code)
+			}
+			continue
+		}
+		if tc.path == "/healthz" {
+			continue
+		}
+		response := ConvertResponse{}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+			t.Fatalf("TestConvertHandler(%s): cannot decode response, err: %q", desc, err)
+		}
+		for _, expected := range tc.expectedManifests {
+			if !strings.Contains(response.Manifests, expected) {
+				t.Fatalf("TestConvertHandler(%s): expected manifests to contain %q, got:\n%s", desc, expected,
+					response.Manifests)
+			}
+		}
+		warnings := strings.Join(response.Warnings, "\n")
+		for _, expected := range tc.expectedWarnings {
+			if !strings.Contains(warnings, expected) {
+				t.Fatalf("TestConvertHandler(%s): expected warning %q, got %v", desc, expected, response.Warnings)
+			}
+		}
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatalf("TestMetrics: cannot build scheme, err: %q", err)
+	}
+	handler := New(scheme, converter.Options{})
+	for _, body := range []string{legacyAddressPools, legacyAddressPools, "kind: ["} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/convert",
+			strings.NewReader(body)))
+	}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, MetricsPath, nil))
+	for _, expected := range []string{
+		`metallb_converter_convert_requests_total{code="200"} 2`,
+		`metallb_converter_convert_requests_total{code="422"} 1`,
+	} {
+		if !strings.Contains(recorder.Body.String(), expected) {
+			t.Fatalf("TestMetrics: expected metrics to contain %q, got:\n%s", expected, recorder.Body.String())
+		}
+	}
+}
+
+func TestNewHTTPServer(t *testing.T) {
+	s := NewHTTPServer("127.0.0.1:0", http.NotFoundHandler())
+	if s.ReadHeaderTimeout != ReadHeaderTimeout || s.ReadTimeout != ReadTimeout || s.WriteTimeout != WriteTimeout ||
+		s.IdleTimeout != IdleTimeout {
+		t.Fatalf("TestNewHTTPServer: unexpected timeouts of server %+v", s)
+	}
+}
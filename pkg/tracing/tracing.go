@@ -0,0 +1,331 @@
+// Package tracing provides a minimal OpenTelemetry compatible tracer. Finished spans are exported in batches to an
+// OTLP/HTTP collector (JSON encoding) while the program runs, whenever a batch is full or the export interval elapsed,
+// and the remaining spans when Flush or Shutdown is called. This keeps the converter free of the (large) OpenTelemetry
+// SDK dependency tree while still allowing long migration runs to be traced.
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// EndpointEnvVar is the standard OpenTelemetry environment variable for the OTLP endpoint.
+	EndpointEnvVar = "OTEL_EXPORTER_OTLP_ENDPOINT"
+	// ServiceName is reported as the service.name resource attribute.
+	ServiceName = "metallb-converter"
+
+	// DefaultBatchSize is the number of finished spans that NewTracer exports at once, and DefaultExportInterval the
+	// longest time that it holds a finished span before exporting it.
+	DefaultBatchSize      = 512
+	DefaultExportInterval = 5 * time.Second
+
+	tracesPath = "/v1/traces"
+
+	statusCodeOk     = 1
+	statusCodeError  = 2
+	spanKindInternal = 1
+)
+
+type spanContextKey struct{}
+
+// Tracer collects spans that belong to a single trace and exports them in batches. A nil *Tracer is valid and records
+// nothing.
+type Tracer struct {
+	endpoint  string
+	traceID   string
+	client    *http.Client
+	batchSize int
+	// full is signaled when a batch of spans is ready, done stops the background export and stopped is closed once it
+	// stopped.
+	full    chan struct{}
+	done    chan struct{}
+	stopped chan struct{}
+
+	mu    sync.Mutex
+	spans []*Span
+	// exportErr is the first error of a background export, it is returned by the next Flush.
+	exportErr error
+	// exportMu serializes the exports, so that Flush returns only after a background export in flight finished.
+	exportMu sync.Mutex
+	shutdown sync.Once
+}
+
+// Span is a single timed operation.
+type Span struct {
+	tracer       *Tracer
+	name         string
+	spanID       string
+	parentSpanID string
+	start        time.Time
+	end          time.Time
+	attributes   map[string]string
+	err          error
+}
+
+// NewTracer returns a Tracer that exports to the OTLP/HTTP endpoint (e.g. http://localhost:4318) in batches of
+// DefaultBatchSize spans, at least every DefaultExportInterval. If endpoint is empty, nil is returned and tracing is
+// disabled.
+func NewTracer(endpoint string) *Tracer {
+	return NewBatchTracer(endpoint, DefaultBatchSize, DefaultExportInterval)
+}
+
+// NewBatchTracer returns a Tracer like NewTracer that exports as soon as batchSize spans finished, and every interval
+// the spans that finished since the last export. Shutdown stops the background export.
+func NewBatchTracer(endpoint string, batchSize int, interval time.Duration) *Tracer {
+	if endpoint == "" {
+		return nil
+	}
+	t := &Tracer{
+		endpoint:  strings.TrimSuffix(endpoint, "/"),
+		traceID:   randomHex(16),
+		client:    &http.Client{Timeout: 10 * time.Second},
+		batchSize: batchSize,
+		full:      make(chan struct{}, 1),
+		done:      make(chan struct{}),
+		stopped:   make(chan struct{}),
+	}
+	go t.exportInBackground(interval)
+	return t
+}
+
+// exportInBackground exports the finished spans whenever a batch is full or interval elapsed, until done is closed.
+func (t *Tracer) exportInBackground(interval time.Duration) {
+	defer close(t.stopped)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.done:
+			return
+		case <-ticker.C:
+		case <-t.full:
+		}
+		if err := t.export(context.Background()); err != nil {
+			t.mu.Lock()
+			if t.exportErr == nil {
+				t.exportErr = err
+			}
+			t.mu.Unlock()
+		}
+	}
+}
+
+// Start starts a new span. If ctx carries a span, the new span becomes its child. The returned context carries the
+// new span.
+func (t *Tracer) Start(ctx context.Context, name string) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+	s := &Span{
+		tracer:     t,
+		name:       name,
+		spanID:     randomHex(8),
+		start:      time.Now(),
+		attributes: map[string]string{},
+	}
+	if parent, ok := ctx.Value(spanContextKey{}).(*Span); ok && parent != nil {
+		s.parentSpanID = parent.spanID
+	}
+	return context.WithValue(ctx, spanContextKey{}, s), s
+}
+
+// SetAttribute adds a string attribute to the span.
+func (s *Span) SetAttribute(key, value string) {
+	if s == nil {
+		return
+	}
+	s.attributes[key] = value
+}
+
+// RecordError marks the span as failed. A nil err is ignored.
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.err = err
+}
+
+// End finishes the span and hands it to its tracer, which exports it with the next batch.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.end = time.Now()
+	t := s.tracer
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.spans = append(t.spans, s)
+	if len(t.spans) >= t.batchSize {
+		select {
+		case t.full <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Flush exports all finished spans to the OTLP endpoint. It returns the error of the export, or else the first error
+// of a background export since the last Flush.
+func (t *Tracer) Flush(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+	err := t.export(ctx)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err == nil {
+		err = t.exportErr
+	}
+	t.exportErr = nil
+	return err
+}
+
+// Shutdown stops the background export and flushes the remaining spans. Spans that finish afterwards are only
+// exported by Flush.
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+	t.shutdown.Do(func() {
+		close(t.done)
+		<-t.stopped
+	})
+	return t.Flush(ctx)
+}
+
+// export exports the spans that finished since the last export.
+func (t *Tracer) export(ctx context.Context) error {
+	t.exportMu.Lock()
+	defer t.exportMu.Unlock()
+	t.mu.Lock()
+	spans := t.spans
+	t.spans = nil
+	t.mu.Unlock()
+	if len(spans) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(t.exportRequest(spans))
+	if err != nil {
+		return fmt.Errorf("cannot marshal trace export request, err: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint+tracesPath, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("cannot build trace export request, err: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("cannot export traces to %s, err: %w", t.endpoint, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("cannot export traces to %s, unexpected status %q", t.endpoint, resp.Status)
+	}
+	return nil
+}
+
+// The following types mirror the OTLP JSON encoding of ExportTraceServiceRequest.
+type exportRequest struct {
+	ResourceSpans []resourceSpans `json:"resourceSpans"`
+}
+
+type resourceSpans struct {
+	Resource   resource     `json:"resource"`
+	ScopeSpans []scopeSpans `json:"scopeSpans"`
+}
+
+type resource struct {
+	Attributes []keyValue `json:"attributes"`
+}
+
+type scopeSpans struct {
+	Scope scope      `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type scope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string     `json:"traceId"`
+	SpanID            string     `json:"spanId"`
+	ParentSpanID      string     `json:"parentSpanId,omitempty"`
+	Name              string     `json:"name"`
+	Kind              int        `json:"kind"`
+	StartTimeUnixNano string     `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string     `json:"endTimeUnixNano"`
+	Attributes        []keyValue `json:"attributes,omitempty"`
+	Status            status     `json:"status"`
+}
+
+type keyValue struct {
+	Key   string   `json:"key"`
+	Value anyValue `json:"value"`
+}
+
+type anyValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type status struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+// exportRequest converts the spans into their OTLP representation.
+func (t *Tracer) exportRequest(spans []*Span) exportRequest {
+	var otlpSpans []otlpSpan
+	for _, s := range spans {
+		span := otlpSpan{
+			TraceID:           t.traceID,
+			SpanID:            s.spanID,
+			ParentSpanID:      s.parentSpanID,
+			Name:              s.name,
+			Kind:              spanKindInternal,
+			StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+			Status:            status{Code: statusCodeOk},
+		}
+		keys := make([]string, 0, len(s.attributes))
+		for k := range s.attributes {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			span.Attributes = append(span.Attributes, keyValue{Key: k, Value: anyValue{StringValue: s.attributes[k]}})
+		}
+		if s.err != nil {
+			span.Status = status{Code: statusCodeError, Message: s.err.Error()}
+		}
+		otlpSpans = append(otlpSpans, span)
+	}
+	return exportRequest{
+		ResourceSpans: []resourceSpans{
+			{
+				Resource: resource{
+					Attributes: []keyValue{{Key: "service.name", Value: anyValue{StringValue: ServiceName}}},
+				},
+				ScopeSpans: []scopeSpans{{Scope: scope{Name: ServiceName}, Spans: otlpSpans}},
+			},
+		},
+	}
+}
+
+// randomHex returns n random bytes in hex encoding.
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
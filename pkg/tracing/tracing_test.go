@@ -0,0 +1,150 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNilTracer(t *testing.T) {
+	tracer := NewTracer("")
+	if tracer != nil {
+		t.Fatalf("TestNilTracer: expected a nil tracer for an empty endpoint")
+	}
+	ctx, span := tracer.Start(context.Background(), "noop")
+	span.SetAttribute("key", "value")
+	span.RecordError(errors.New("ignored"))
+	span.End()
+	if ctx == nil {
+		t.Fatalf("TestNilTracer: expected the provided context to be returned")
+	}
+	if err := tracer.Flush(context.Background()); err != nil {
+		t.Fatalf("TestNilTracer: expected no error on flush, got %q", err)
+	}
+}
+
+func TestFlush(t *testing.T) {
+	var received exportRequest
+	var receivedPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedPath = r.URL.Path
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	tracer := NewTracer(server.URL + "/")
+	ctx, root := tracer.Start(context.Background(), "root")
+	_, child := tracer.Start(ctx, "child")
+	child.SetAttribute("addresspool", "metallb-system/ap")
+	child.RecordError(errors.New("child failed"))
+	child.End()
+	root.End()
+
+	if err := tracer.Flush(context.Background()); err != nil {
+		t.Fatalf("TestFlush: unexpected error, err: %q", err)
+	}
+	if receivedPath != tracesPath {
+		t.Fatalf("TestFlush: expected export to %s but got %s", tracesPath, receivedPath)
+	}
+	if len(received.ResourceSpans) != 1 || len(received.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("TestFlush: unexpected export request structure: %+v", received)
+	}
+	spans := received.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("TestFlush: expected 2 spans but got %d", len(spans))
+	}
+	childSpan, rootSpan := spans[0], spans[1]
+	if childSpan.ParentSpanID != rootSpan.SpanID || rootSpan.ParentSpanID != "" {
+		t.Fatalf("TestFlush: unexpected span hierarchy, child: %+v, root: %+v", childSpan, rootSpan)
+	}
+	if childSpan.TraceID != rootSpan.TraceID || len(rootSpan.TraceID) != 32 {
+		t.Fatalf("TestFlush: unexpected trace IDs, child: %q, root: %q", childSpan.TraceID, rootSpan.TraceID)
+	}
+	if childSpan.Status.Code != statusCodeError || childSpan.Status.Message != "child failed" {
+		t.Fatalf("TestFlush: expected child span to carry the error status, got %+v", childSpan.Status)
+	}
+	if len(childSpan.Attributes) != 1 || childSpan.Attributes[0].Value.StringValue != "metallb-system/ap" {
+		t.Fatalf("TestFlush: unexpected child span attributes %+v", childSpan.Attributes)
+	}
+
+	// A second flush has nothing left to export.
+	receivedPath = ""
+	if err := tracer.Flush(context.Background()); err != nil || receivedPath != "" {
+		t.Fatalf("TestFlush: expected a no-op flush, got err %q and request to %q", err, receivedPath)
+	}
+}
+
+func TestFlushError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	tracer := NewTracer(server.URL)
+	_, span := tracer.Start(context.Background(), "root")
+	span.End()
+	err := tracer.Flush(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "unexpected status") {
+		t.Fatalf("TestFlushError: expected an unexpected status error but got %q", err)
+	}
+}
+
+func TestBatchExport(t *testing.T) {
+	tcs := map[string]struct {
+		batchSize int
+		interval  time.Duration
+		spans     int
+	}{
+		"full batch": {
+			batchSize: 2,
+			interval:  time.Hour,
+			spans:     2,
+		},
+		"interval elapsed": {
+			batchSize: 100,
+			interval:  10 * time.Millisecond,
+			spans:     1,
+		},
+	}
+	for desc, tc := range tcs {
+		exported := make(chan int, 10)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var received exportRequest
+			if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			exported <- len(received.ResourceSpans[0].ScopeSpans[0].Spans)
+		}))
+
+		tracer := NewBatchTracer(server.URL, tc.batchSize, tc.interval)
+		for i := 0; i < tc.spans; i++ {
+			_, span := tracer.Start(context.Background(), "span")
+			span.End()
+		}
+		select {
+		case n := <-exported:
+			if n != tc.spans {
+				t.Fatalf("TestBatchExport(%s): expected %d exported spans but got %d", desc, tc.spans, n)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("TestBatchExport(%s): the spans were not exported before Flush", desc)
+		}
+		if err := tracer.Shutdown(context.Background()); err != nil {
+			t.Fatalf("TestBatchExport(%s): unexpected error on shutdown, err: %q", desc, err)
+		}
+		if len(exported) != 0 {
+			t.Fatalf("TestBatchExport(%s): expected no further export on shutdown", desc)
+		}
+		server.Close()
+	}
+}
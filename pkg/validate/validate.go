@@ -0,0 +1,270 @@
+// Package validate checks legacy AddressPools and the resources that they are converted into for values that MetalLB
+// rejects or misinterprets: addresses that cannot be parsed, malformed BGP communities, aggregation lengths that do
+// not fit the addresses of a pool and names that are not DNS-1123 subdomains. The checks only look at the objects
+// themselves and never access a cluster.
+package validate
+
+import (
+	"fmt"
+	"math/bits"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// largeCommunityPrefix is the prefix of large BGP communities, e.g. large:64500:1:2.
+const largeCommunityPrefix = "large:"
+
+// Address returns an error if address is not a valid CIDR, <first>-<last> range or single address. Zone identifiers,
+// ranges that mix address families and reversed ranges are rejected.
+func Address(address string) error {
+	first, last, err := addressRange(address)
+	if err != nil {
+		return err
+	}
+	if first.Zone() != "" || last.Zone() != "" {
+		return fmt.Errorf("invalid address %q, zone identifiers are not allowed", address)
+	}
+	if first.Is4() != last.Is4() {
+		return fmt.Errorf("invalid range %q, the first and the last address must be of the same address family",
+			address)
+	}
+	if last.Less(first) {
+		return fmt.Errorf("invalid range %q, the first address must not be greater than the last address", address)
+	}
+	return nil
+}
+
+// addressRange returns the first and the last address of a CIDR, range or single address.
+func addressRange(address string) (netip.Addr, netip.Addr, error) {
+	if addr, err := netip.ParseAddr(address); err == nil {
+		return addr, addr, nil
+	}
+	if first, last, found := strings.Cut(address, "-"); found {
+		from, err := netip.ParseAddr(strings.TrimSpace(first))
+		if err != nil {
+			return netip.Addr{}, netip.Addr{}, fmt.Errorf("invalid range %q, err: %w", address, err)
+		}
+		to, err := netip.ParseAddr(strings.TrimSpace(last))
+		if err != nil {
+			return netip.Addr{}, netip.Addr{}, fmt.Errorf("invalid range %q, err: %w", address, err)
+		}
+		return from, to, nil
+	}
+	prefix, err := netip.ParsePrefix(address)
+	if err != nil {
+		return netip.Addr{}, netip.Addr{}, fmt.Errorf("invalid CIDR %q, err: %w", address, err)
+	}
+	prefix = prefix.Masked()
+	return prefix.Addr(), lastAddress(prefix), nil
+}
+
+// lastAddress returns the last address of prefix, which must be masked.
+func lastAddress(prefix netip.Prefix) netip.Addr {
+	b := prefix.Addr().AsSlice()
+	for i := prefix.Bits(); i < len(b)*8; i++ {
+		b[i/8] |= 0x80 >> (i % 8)
+	}
+	last, _ := netip.AddrFromSlice(b)
+	return last
+}
+
+// Community returns an error if value is neither a standard community of two 16 bit numbers, e.g. 64500:100, nor a
+// large community of three 32 bit numbers, e.g. large:64500:1:2. Values without a colon are the names of Community
+// aliases and are accepted if they are not empty.
+func Community(value string) error {
+	if value == "" {
+		return fmt.Errorf("invalid community %q, must not be empty", value)
+	}
+	if !strings.Contains(value, ":") {
+		return nil
+	}
+	fields, bitSize, format := strings.Split(value, ":"), 16, "<0-65535>:<0-65535>"
+	if strings.HasPrefix(value, largeCommunityPrefix) {
+		fields, bitSize = strings.Split(strings.TrimPrefix(value, largeCommunityPrefix), ":"), 32
+		format = "large:<0-4294967295>:<0-4294967295>:<0-4294967295>"
+		if len(fields) != 3 {
+			return fmt.Errorf("invalid large community %q, must be %s", value, format)
+		}
+	} else if len(fields) != 2 {
+		return fmt.Errorf("invalid community %q, must be %s", value, format)
+	}
+	for _, field := range fields {
+		if _, err := strconv.ParseUint(field, 10, bitSize); err != nil {
+			return fmt.Errorf("invalid community %q, must be %s", value, format)
+		}
+	}
+	return nil
+}
+
+// AggregationLength returns an error if length does not fit the addresses of the address family of a pool: it must
+// be between 0 and the bit length of the family, and MetalLB rejects aggregation lengths that are shorter than a CIDR
+// that the addresses consist of. Addresses of the other family and invalid addresses are disregarded.
+func AggregationLength(length int32, addresses []string, ipv6 bool) error {
+	family, maxLength := "IPv4", int32(32)
+	if ipv6 {
+		family, maxLength = "IPv6", 128
+	}
+	if length < 0 || length > maxLength {
+		return fmt.Errorf("invalid %s aggregation length %d, must be between 0 and %d", family, length, maxLength)
+	}
+	for _, address := range addresses {
+		if Address(address) != nil {
+			continue
+		}
+		first, last, _ := addressRange(address)
+		if first.Is6() != ipv6 {
+			continue
+		}
+		if prefixLen := longestPrefix(first, last); int32(prefixLen) > length {
+			return fmt.Errorf("invalid %s aggregation length %d, address %s contains the more specific /%d",
+				family, length, address, prefixLen)
+		}
+	}
+	return nil
+}
+
+// longestPrefix returns the longest prefix length of the CIDRs that the range from first to last consists of.
+func longestPrefix(first, last netip.Addr) int {
+	longest := 0
+	for start := first; ; {
+		// The largest CIDR that starts at start and ends at or before last.
+		prefixLen := start.BitLen() - trailingZeros(start)
+		for last.Less(lastAddress(netip.PrefixFrom(start, prefixLen))) {
+			prefixLen++
+		}
+		if prefixLen > longest {
+			longest = prefixLen
+		}
+		end := lastAddress(netip.PrefixFrom(start, prefixLen))
+		if end == last {
+			return longest
+		}
+		start = end.Next()
+	}
+}
+
+// trailingZeros returns the number of trailing zero bits of addr.
+func trailingZeros(addr netip.Addr) int {
+	b := addr.AsSlice()
+	zeros := 0
+	for i := len(b) - 1; i >= 0; i-- {
+		if b[i] != 0 {
+			return zeros + bits.TrailingZeros8(b[i])
+		}
+		zeros += 8
+	}
+	return zeros
+}
+
+// Name returns an error if name is not a DNS-1123 subdomain, which the names of MetalLB resources must be.
+func Name(name string) error {
+	if problems := validation.IsDNS1123Subdomain(name); len(problems) > 0 {
+		return fmt.Errorf("invalid name %q, %s", name, strings.Join(problems, ", "))
+	}
+	return nil
+}
+
+// AddressPools validates the addresses and the BGP advertisements of legacy AddressPools and returns a description
+// of every problem.
+func AddressPools(pools []metallbv1beta1.AddressPool) []string {
+	var problems []string
+	for _, ap := range pools {
+		add := func(err error) {
+			problems = append(problems, fmt.Sprintf("AddressPool %s/%s: %v", ap.Namespace, ap.Name, err))
+		}
+		for _, address := range ap.Spec.Addresses {
+			if err := Address(address); err != nil {
+				add(err)
+			}
+		}
+		for _, adv := range ap.Spec.BGPAdvertisements {
+			for _, err := range advertisementErrors(adv.AggregationLength, adv.AggregationLengthV6, adv.Communities,
+				ap.Spec.Addresses) {
+				add(err)
+			}
+		}
+	}
+	return problems
+}
+
+// Resources validates the names, addresses, communities and aggregation lengths of the resources of a conversion
+// and returns a description of every problem. The aggregation lengths of a BGPAdvertisement are checked against the
+// addresses of the IPAddressPools that it references.
+func Resources(pools []metallbv1beta1.IPAddressPool, l2Advertisements []metallbv1beta1.L2Advertisement,
+	bgpAdvertisements []metallbv1beta1.BGPAdvertisement, communities []metallbv1beta1.Community) []string {
+	var problems []string
+	add := func(kind, namespace, name string, err error) {
+		problems = append(problems, fmt.Sprintf("%s %s/%s: %v", kind, namespace, name, err))
+	}
+	addresses := map[string][]string{}
+	for _, iap := range pools {
+		if err := Name(iap.Name); err != nil {
+			add("IPAddressPool", iap.Namespace, iap.Name, err)
+		}
+		for _, address := range iap.Spec.Addresses {
+			if err := Address(address); err != nil {
+				add("IPAddressPool", iap.Namespace, iap.Name, err)
+			}
+		}
+		addresses[iap.Namespace+"/"+iap.Name] = iap.Spec.Addresses
+	}
+	for _, l2a := range l2Advertisements {
+		if err := Name(l2a.Name); err != nil {
+			add("L2Advertisement", l2a.Namespace, l2a.Name, err)
+		}
+	}
+	for _, ba := range bgpAdvertisements {
+		if err := Name(ba.Name); err != nil {
+			add("BGPAdvertisement", ba.Namespace, ba.Name, err)
+		}
+		var poolAddresses []string
+		for _, pool := range ba.Spec.IPAddressPools {
+			poolAddresses = append(poolAddresses, addresses[ba.Namespace+"/"+pool]...)
+		}
+		for _, err := range advertisementErrors(ba.Spec.AggregationLength, ba.Spec.AggregationLengthV6,
+			ba.Spec.Communities, poolAddresses) {
+			add("BGPAdvertisement", ba.Namespace, ba.Name, err)
+		}
+	}
+	for _, community := range communities {
+		if err := Name(community.Name); err != nil {
+			add("Community", community.Namespace, community.Name, err)
+		}
+		for _, alias := range community.Spec.Communities {
+			if !strings.Contains(alias.Value, ":") {
+				add("Community", community.Namespace, community.Name,
+					fmt.Errorf("invalid value %q of alias %q, must be a community value", alias.Value, alias.Name))
+			} else if err := Community(alias.Value); err != nil {
+				add("Community", community.Namespace, community.Name, err)
+			}
+		}
+	}
+	return problems
+}
+
+// advertisementErrors validates the aggregation lengths and communities of a BGP advertisement of a pool with
+// addresses. Unset aggregation lengths default to the bit length of the family and are always valid.
+func advertisementErrors(aggregationLength, aggregationLengthV6 *int32, communities []string,
+	addresses []string) []error {
+	var errs []error
+	if aggregationLength != nil {
+		if err := AggregationLength(*aggregationLength, addresses, false); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if aggregationLengthV6 != nil {
+		if err := AggregationLength(*aggregationLengthV6, addresses, true); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, community := range communities {
+		if err := Community(community); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
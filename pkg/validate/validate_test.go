@@ -0,0 +1,245 @@
+package validate
+
+import (
+	"strings"
+	"testing"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestAddress(t *testing.T) {
+	tcs := map[string]struct {
+		address     string
+		expectError bool
+	}{
+		"cidr":           {address: "192.168.0.0/24"},
+		"range":          {address: "2001:db8::1 - 2001:db8::ff"},
+		"single address": {address: "10.0.0.1"},
+		"garbage":        {address: "not-an-address", expectError: true},
+		"reversed range": {address: "10.0.0.5-10.0.0.1", expectError: true},
+		"mixed families": {address: "10.0.0.1-2001:db8::1", expectError: true},
+		"zone":           {address: "fe80::1%eth0", expectError: true},
+	}
+	for desc, tc := range tcs {
+		err := Address(tc.address)
+		if tc.expectError != (err != nil) {
+			t.Fatalf("TestAddress(%s): expected error %t for %q but got %v", desc, tc.expectError, tc.address, err)
+		}
+	}
+}
+
+func TestCommunity(t *testing.T) {
+	tcs := map[string]struct {
+		value       string
+		expectError bool
+	}{
+		"standard":                 {value: "64500:100"},
+		"standard maximum":         {value: "65535:65535"},
+		"large":                    {value: "large:4200000000:1:2"},
+		"alias":                    {value: "no-export"},
+		"empty":                    {value: "", expectError: true},
+		"standard field too large": {value: "65536:100", expectError: true},
+		"standard too many fields": {value: "64500:100:1", expectError: true},
+		"standard not a number":    {value: "64500:abc", expectError: true},
+		"standard empty field":     {value: "64500:", expectError: true},
+		"large field too large":    {value: "large:4294967296:1:2", expectError: true},
+		"large too few fields":     {value: "large:64500:1", expectError: true},
+		"negative":                 {value: "-1:100", expectError: true},
+	}
+	for desc, tc := range tcs {
+		err := Community(tc.value)
+		if tc.expectError != (err != nil) {
+			t.Fatalf("TestCommunity(%s): expected error %t for %q but got %v", desc, tc.expectError, tc.value, err)
+		}
+	}
+}
+
+func TestAggregationLength(t *testing.T) {
+	tcs := map[string]struct {
+		length              int32
+		addresses           []string
+		ipv6                bool
+		expectedErrorString string
+	}{
+		"host routes": {
+			length:    32,
+			addresses: []string{"192.168.0.0/24"},
+		},
+		"aggregated cidr": {
+			length:    24,
+			addresses: []string{"192.168.0.0/24", "2001:db8::/64"},
+		},
+		"aggregation shorter than cidr": {
+			length:              16,
+			addresses:           []string{"192.168.0.0/24"},
+			expectedErrorString: "contains the more specific /24",
+		},
+		"unaligned range": {
+			length:              24,
+			addresses:           []string{"192.168.0.1-192.168.0.255"},
+			expectedErrorString: "contains the more specific /32",
+		},
+		"aligned range": {
+			length:    25,
+			addresses: []string{"192.168.0.0-192.168.0.255"},
+		},
+		"aligned range of two cidrs": {
+			length:              24,
+			addresses:           []string{"192.168.0.0-192.168.0.191"},
+			expectedErrorString: "contains the more specific /26",
+		},
+		"whole address space": {
+			length:    0,
+			addresses: []string{"0.0.0.0/0"},
+		},
+		"ipv4 length too long": {
+			length:              33,
+			expectedErrorString: "must be between 0 and 32",
+		},
+		"negative length": {
+			length:              -1,
+			ipv6:                true,
+			expectedErrorString: "must be between 0 and 128",
+		},
+		"ipv6 aggregation shorter than cidr": {
+			length:              48,
+			addresses:           []string{"192.168.0.0/16", "2001:db8::/64"},
+			ipv6:                true,
+			expectedErrorString: "contains the more specific /64",
+		},
+		"ipv6 host routes": {
+			length:    128,
+			addresses: []string{"2001:db8::1-2001:db8::ff"},
+			ipv6:      true,
+		},
+	}
+	for desc, tc := range tcs {
+		err := AggregationLength(tc.length, tc.addresses, tc.ipv6)
+		if tc.expectedErrorString == "" && err != nil {
+			t.Fatalf("TestAggregationLength(%s): unexpected error, err: %q", desc, err)
+		}
+		if tc.expectedErrorString != "" && (err == nil || !strings.Contains(err.Error(), tc.expectedErrorString)) {
+			t.Fatalf("TestAggregationLength(%s): expected error %q but got %v", desc, tc.expectedErrorString, err)
+		}
+	}
+}
+
+func TestName(t *testing.T) {
+	tcs := map[string]struct {
+		name        string
+		expectError bool
+	}{
+		"valid":            {name: "bgp-bgp-advertisement-0"},
+		"dots":             {name: "pool.example.com"},
+		"upper case":       {name: "Pool", expectError: true},
+		"underscore":       {name: "my_pool", expectError: true},
+		"leading dash":     {name: "-pool", expectError: true},
+		"empty":            {name: "", expectError: true},
+		"longer than 253":  {name: strings.Repeat("a", 254), expectError: true},
+		"exactly 253 long": {name: strings.Repeat("a", 253)},
+	}
+	for desc, tc := range tcs {
+		err := Name(tc.name)
+		if tc.expectError != (err != nil) {
+			t.Fatalf("TestName(%s): expected error %t for %q but got %v", desc, tc.expectError, tc.name, err)
+		}
+	}
+}
+
+func TestAddressPools(t *testing.T) {
+	aggregationLength := int32(16)
+	pools := []metallbv1beta1.AddressPool{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "valid", Namespace: "metallb-system"},
+			Spec: metallbv1beta1.AddressPoolSpec{
+				Protocol:          "bgp",
+				Addresses:         []string{"192.168.0.0/16"},
+				BGPAdvertisements: []metallbv1beta1.LegacyBgpAdvertisement{{AggregationLength: &aggregationLength}},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "invalid", Namespace: "metallb-system"},
+			Spec: metallbv1beta1.AddressPoolSpec{
+				Protocol:  "bgp",
+				Addresses: []string{"192.168.1.0/24", "10.0.0.300"},
+				BGPAdvertisements: []metallbv1beta1.LegacyBgpAdvertisement{{
+					AggregationLength: &aggregationLength,
+					Communities:       []string{"64500:100", "70000:1"},
+				}},
+			},
+		},
+	}
+	expectedProblems := []string{
+		`AddressPool metallb-system/invalid: invalid CIDR "10.0.0.300"`,
+		"AddressPool metallb-system/invalid: invalid IPv4 aggregation length 16, address 192.168.1.0/24 contains",
+		`AddressPool metallb-system/invalid: invalid community "70000:1"`,
+	}
+	problems := AddressPools(pools)
+	if len(problems) != len(expectedProblems) {
+		t.Fatalf("TestAddressPools: expected %d problems but got %q", len(expectedProblems), problems)
+	}
+	for i, expected := range expectedProblems {
+		if !strings.HasPrefix(problems[i], expected) {
+			t.Fatalf("TestAddressPools: expected problem %q but got %q", expected, problems[i])
+		}
+	}
+}
+
+func TestResources(t *testing.T) {
+	aggregationLength := int32(24)
+	pools := []metallbv1beta1.IPAddressPool{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "pool-a", Namespace: "metallb-system"},
+			Spec:       metallbv1beta1.IPAddressPoolSpec{Addresses: []string{"192.168.0.0/24"}},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "Pool_B", Namespace: "metallb-system"},
+			Spec:       metallbv1beta1.IPAddressPoolSpec{Addresses: []string{"10.0.0.0/26"}},
+		},
+	}
+	l2Advertisements := []metallbv1beta1.L2Advertisement{
+		{ObjectMeta: metav1.ObjectMeta{Name: "pool-a-l2-advertisement", Namespace: "metallb-system"}},
+	}
+	bgpAdvertisements := []metallbv1beta1.BGPAdvertisement{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "pool-a-bgp-advertisement-0", Namespace: "metallb-system"},
+			Spec: metallbv1beta1.BGPAdvertisementSpec{
+				IPAddressPools:    []string{"pool-a"},
+				AggregationLength: &aggregationLength,
+				Communities:       []string{"no-advertise"},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "pool-b-bgp-advertisement-0", Namespace: "metallb-system"},
+			Spec: metallbv1beta1.BGPAdvertisementSpec{
+				IPAddressPools:    []string{"Pool_B"},
+				AggregationLength: &aggregationLength,
+			},
+		},
+	}
+	communities := []metallbv1beta1.Community{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "communities", Namespace: "metallb-system"},
+			Spec: metallbv1beta1.CommunitySpec{Communities: []metallbv1beta1.CommunityAlias{
+				{Name: "no-advertise", Value: "65535:65282"},
+				{Name: "broken", Value: "65535"},
+			}},
+		},
+	}
+	expectedProblems := []string{
+		`IPAddressPool metallb-system/Pool_B: invalid name "Pool_B"`,
+		"BGPAdvertisement metallb-system/pool-b-bgp-advertisement-0: invalid IPv4 aggregation length 24, address " +
+			"10.0.0.0/26 contains the more specific /26",
+		`Community metallb-system/communities: invalid value "65535" of alias "broken"`,
+	}
+	problems := Resources(pools, l2Advertisements, bgpAdvertisements, communities)
+	if len(problems) != len(expectedProblems) {
+		t.Fatalf("TestResources: expected %d problems but got %q", len(expectedProblems), problems)
+	}
+	for i, expected := range expectedProblems {
+		if !strings.HasPrefix(problems[i], expected) {
+			t.Fatalf("TestResources: expected problem %q but got %q", expected, problems[i])
+		}
+	}
+}
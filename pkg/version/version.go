@@ -0,0 +1,75 @@
+// Package version holds the build metadata of the converter.
+package version
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// MetalLBModule is the module path of the MetalLB API that the converter is compiled against.
+const MetalLBModule = "go.universe.tf/metallb"
+
+// Version, Commit and BuildDate are overwritten at build time, e.g.:
+//
+//	go build -ldflags "-X github.com/andreaskaris/metallb-converter/pkg/version.Version=v0.1.0"
+var (
+	Version   = "dev"
+	Commit    = "unknown"
+	BuildDate = "unknown"
+)
+
+// Info describes the build of the converter.
+type Info struct {
+	Version       string `json:"version"`
+	Commit        string `json:"commit"`
+	BuildDate     string `json:"buildDate"`
+	GoVersion     string `json:"goVersion"`
+	MetalLBModule string `json:"metallbModule"`
+}
+
+// Get returns the build metadata of the running binary. If Commit or BuildDate were not set at build time, they are
+// taken from the VCS information that the Go toolchain embeds, if any.
+func Get() Info {
+	info := Info{
+		Version:       Version,
+		Commit:        Commit,
+		BuildDate:     BuildDate,
+		GoVersion:     runtime.Version(),
+		MetalLBModule: "unknown",
+	}
+	buildInfo, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	info.MetalLBModule = moduleVersion(buildInfo, MetalLBModule)
+	for _, setting := range buildInfo.Settings {
+		switch {
+		case setting.Key == "vcs.revision" && info.Commit == "unknown":
+			info.Commit = setting.Value
+		case setting.Key == "vcs.time" && info.BuildDate == "unknown":
+			info.BuildDate = setting.Value
+		}
+	}
+	return info
+}
+
+// moduleVersion returns the version of module path among the dependencies of buildInfo, honoring replace directives.
+func moduleVersion(buildInfo *debug.BuildInfo, path string) string {
+	for _, dep := range buildInfo.Deps {
+		if dep.Path != path {
+			continue
+		}
+		if dep.Replace != nil {
+			return fmt.Sprintf("%s => %s %s", dep.Version, dep.Replace.Path, dep.Replace.Version)
+		}
+		return dep.Version
+	}
+	return "unknown"
+}
+
+// String returns a human readable representation of the build metadata.
+func (i Info) String() string {
+	return fmt.Sprintf("metallb-converter %s\ncommit: %s\nbuild date: %s\ngo: %s\n%s: %s", i.Version, i.Commit,
+		i.BuildDate, i.GoVersion, MetalLBModule, i.MetalLBModule)
+}
@@ -0,0 +1,43 @@
+package version
+
+import (
+	"runtime/debug"
+	"testing"
+)
+
+func TestModuleVersion(t *testing.T) {
+	tcs := map[string]struct {
+		deps     []*debug.Module
+		expected string
+	}{
+		"missing": {
+			deps:     []*debug.Module{{Path: "k8s.io/api", Version: "v0.25.0"}},
+			expected: "unknown",
+		},
+		"plain": {
+			deps:     []*debug.Module{{Path: MetalLBModule, Version: "v0.13.7"}},
+			expected: "v0.13.7",
+		},
+		"replaced": {
+			deps: []*debug.Module{{
+				Path:    MetalLBModule,
+				Version: "v0.13.7",
+				Replace: &debug.Module{Path: "github.com/fork/metallb", Version: "v0.13.8"},
+			}},
+			expected: "v0.13.7 => github.com/fork/metallb v0.13.8",
+		},
+	}
+	for desc, tc := range tcs {
+		got := moduleVersion(&debug.BuildInfo{Deps: tc.deps}, MetalLBModule)
+		if got != tc.expected {
+			t.Fatalf("TestModuleVersion(%s): expected %q but got %q", desc, tc.expected, got)
+		}
+	}
+}
+
+func TestGet(t *testing.T) {
+	info := Get()
+	if info.Version != Version || info.GoVersion == "" {
+		t.Fatalf("TestGet: unexpected build metadata %+v", info)
+	}
+}
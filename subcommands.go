@@ -0,0 +1,237 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/andreaskaris/metallb-converter/pkg/options"
+)
+
+// subcommand is a mode of the tool with its own flags and help text, e.g. convert. A subcommand selects its mode, see
+// modes, by setting the mode flags of the flat command line, so both forms share all validation and behavior.
+type subcommand struct {
+	name string
+	// args is the synopsis of the positional argument, if any.
+	args string
+	// description is the first paragraph of the help text.
+	description string
+	// arg is the flag that the positional argument sets, if the subcommand takes one.
+	arg string
+	// withoutArg are the flag values that select the mode of the subcommand if the positional argument is omitted.
+	// If it is nil, the positional argument is required.
+	withoutArg map[string]string
+	// set are the flag values that select the mode of the subcommand.
+	set map[string]string
+	// flags are the flags of the subcommand in addition to commonFlags.
+	flags [][]string
+}
+
+var (
+	// commonFlags apply to every subcommand.
+	commonFlags = []string{options.ConfigFlag, options.ProfileFlag, "quiet", "no-color", "kubeconfig", "context",
+		"as", "as-group", "qps", "burst", "request-timeout", "feature-gates", "source-api-group", "target-api-group",
+		"otlp-endpoint", "pushgateway-url", "pushgateway-job", "pushgateway-grouping", "metrics-address",
+		"pprof-address", injectFailuresFlagName}
+	// conversionFlags tune how legacy AddressPools are converted.
+	conversionFlags = []string{"duplicates", "name-collisions", "advertisement-names", "peer-passwords",
+		"community-resources", "expand-community-aliases", "assign-priorities", "normalize-addresses",
+		"merge-identical-pools", "merge-l2", "fail-on-localpref-conflicts", "split-l2-address-families",
+		"split-pools-per-range", "peers-policy", "merge-policy", "add-label", "add-annotation", "target-version",
+		"strip-unsupported-fields", "operator-compat",
+		"provenance", "propagate-metadata", "advertisement-metadata"}
+	// filterFlags select the legacy resources of the cluster.
+	filterFlags = []string{"namespace", "all-namespaces", "selector"}
+	// inputFlags select the legacy resources of an offline conversion.
+	inputFlags = append([]string{"input-dir", "from-configmap", "input-helm-chart", "input-helm-values", "helm-command",
+		"kustomize", "kustomize-command", "record", "replay", "ignore-unknown"}, filterFlags...)
+	// validationFlags validate the converted resources.
+	validationFlags = []string{"validate-for", "validate-live", "validate-with", "envtest-crd-dir",
+		"envtest-assets-dir", "simulate-assignments", "strict"}
+	// formatFlags select the output format.
+	formatFlags = []string{"o", "json"}
+	// outputFlags select where and how an offline conversion writes its output.
+	outputFlags = []string{"output-dir", "output-group-by", "output-layout", "incremental", "watch-input", "report",
+		"kustomization", "stream", "stream-page-size"}
+
+	subcommands = []subcommand{
+		{
+			name:        "convert",
+			description: "Convert legacy AddressPools into the new resources offline, without modifying the cluster.",
+			flags:       [][]string{inputFlags, conversionFlags, validationFlags, formatFlags, outputFlags},
+		},
+		{
+			name: "migrate",
+			description: "Migrate the legacy AddressPools of the cluster to the new resources one by one.\n" +
+				"WARNING: This will reset your BGP sessions, L2 advertisements, and SVC external IPs.",
+			set: map[string]string{"online-migration": "true"},
+			flags: [][]string{conversionFlags, filterFlags, {"backup-dir", "keep-legacy", "phase", "delete-after",
+				"delete-interval", "create-interval", "create-first", "accept-timeout", "wait-services",
+				"wait-services-timeout", "server-side-apply", "state-file", "operation-policy", "max-retries",
+				"retry-interval", "events", "yes", "force", "audit-log", "audit-configmap", "operator", "history-namespace",
+				"record", "replay", "report"}},
+		},
+		{
+			name:        "backup",
+			args:        "<dir>",
+			description: "Write a backup of the legacy AddressPools of the cluster to <dir> without migrating them.",
+			arg:         "backup-dir",
+			set:         map[string]string{"backup": "true"},
+			flags:       [][]string{filterFlags, formatFlags},
+		},
+		{
+			name:        "restore",
+			args:        "<dir>",
+			description: "Restore the legacy AddressPools of the backup in <dir> that do not exist in the cluster.",
+			arg:         "restore",
+			flags:       [][]string{{"dry-run"}},
+		},
+		{
+			name:        "verify",
+			args:        "<dir>",
+			description: "Verify a completed online migration against the backup in <dir>.",
+			arg:         "verify",
+			flags:       [][]string{conversionFlags, formatFlags},
+		},
+		{
+			name:        "validate",
+			description: "Convert and validate legacy AddressPools, but print nothing.",
+			set:         map[string]string{"validate-only": "true"},
+			flags:       [][]string{inputFlags, conversionFlags, validationFlags, {"report"}},
+		},
+		{
+			name: "diff",
+			args: "[<dir>]",
+			description: "Convert legacy AddressPools and print a unified diff against <dir> or the cluster.\n" +
+				"Without <dir>, the output is compared with the objects of the cluster that it would create or update.",
+			arg:        "golden-dir",
+			withoutArg: map[string]string{"diff-cluster": "true"},
+			flags:      [][]string{inputFlags, conversionFlags, formatFlags, {"output-group-by", "output-layout"}},
+		},
+		{
+			name:        "version",
+			description: "Print the version, git commit, build date and the MetalLB API module version.",
+			set:         map[string]string{"version": "true"},
+			flags:       [][]string{formatFlags},
+		},
+	}
+)
+
+// lookupSubcommand returns the subcommand of name, or nil if there is none.
+func lookupSubcommand(name string) *subcommand {
+	for i := range subcommands {
+		if subcommands[i].name == name {
+			return &subcommands[i]
+		}
+	}
+	return nil
+}
+
+// parse parses the arguments of the subcommand into the flags of commandLine, usually flag.CommandLine, and sets its
+// mode flags. Flags that do not belong to the subcommand are rejected. errorHandling applies to the flags of the
+// subcommand like to those of a flag.FlagSet.
+func (s *subcommand) parse(commandLine *flag.FlagSet, args []string, errorHandling flag.ErrorHandling) error {
+	fs := s.flagSet(commandLine, errorHandling)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if s.arg == "" && fs.NArg() > 0 {
+		return fmt.Errorf("%s takes no arguments, got %q", s.name, strings.Join(fs.Args(), " "))
+	}
+	set := s.set
+	if s.arg != "" {
+		switch {
+		case fs.NArg() == 0 && s.withoutArg != nil:
+			set = s.withoutArg
+		case fs.NArg() > 1 && s.withoutArg != nil:
+			return fmt.Errorf("%s takes at most one argument: %s", s.name, s.args)
+		case fs.NArg() != 1 && s.withoutArg == nil:
+			return fmt.Errorf("%s requires exactly one argument: %s", s.name, s.args)
+		default:
+			if err := commandLine.Set(s.arg, fs.Arg(0)); err != nil {
+				return err
+			}
+		}
+	}
+	var names []string
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := commandLine.Set(name, set[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flagSet returns the flags of the subcommand. They set the flags of the same name of commandLine, so that
+// options.Apply and flag.Visit see them as set on the command line.
+func (s *subcommand) flagSet(commandLine *flag.FlagSet, errorHandling flag.ErrorHandling) *flag.FlagSet {
+	fs := flag.NewFlagSet(fmt.Sprintf("%s %s", os.Args[0], s.name), errorHandling)
+	fs.SetOutput(commandLine.Output())
+	for _, group := range append([][]string{commonFlags}, s.flags...) {
+		for _, name := range group {
+			f := commandLine.Lookup(name)
+			if f == nil || fs.Lookup(name) != nil {
+				continue
+			}
+			fs.Var(&commandLineValue{commandLine: commandLine, name: name, value: f.Value}, name, f.Usage)
+			fs.Lookup(name).DefValue = f.DefValue
+		}
+	}
+	fs.Usage = func() {
+		out := fs.Output()
+		fmt.Fprintf(out, "Usage: %s %s [flags] %s\n\n%s\n\nFlags:\n", os.Args[0], s.name, s.args, s.description)
+		visible := flag.NewFlagSet(fs.Name(), flag.ContinueOnError)
+		visible.SetOutput(out)
+		// The flags of commandLine are printed, their types tell the usage message the names of the values.
+		fs.VisitAll(func(f *flag.Flag) {
+			if !hiddenFlags[f.Name] {
+				visible.Var(commandLine.Lookup(f.Name).Value, f.Name, f.Usage)
+				visible.Lookup(f.Name).DefValue = f.DefValue
+			}
+		})
+		visible.PrintDefaults()
+	}
+	return fs
+}
+
+// commandLineValue is a flag.Value that sets the flag name of commandLine.
+type commandLineValue struct {
+	commandLine *flag.FlagSet
+	name        string
+	value       flag.Value
+}
+
+func (v *commandLineValue) String() string {
+	if v == nil || v.value == nil {
+		return ""
+	}
+	return v.value.String()
+}
+
+func (v *commandLineValue) Set(value string) error {
+	return v.commandLine.Set(v.name, value)
+}
+
+// IsBoolFlag allows boolean flags without a value, e.g. -yes.
+func (v *commandLineValue) IsBoolFlag() bool {
+	b, ok := v.value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// printSubcommands prints the subcommands and their descriptions.
+func printSubcommands() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Commands:\n")
+	for _, s := range subcommands {
+		description, _, _ := strings.Cut(s.description, "\n")
+		fmt.Fprintf(out, "  %-10s %s\n", s.name, description)
+	}
+	fmt.Fprintf(out, "\nRun '%s <command> -h' for the flags of a command. Without a command, all modes are "+
+		"selected with the\nflags below.\n\n", os.Args[0])
+}